@@ -0,0 +1,81 @@
+#!/bin/bash
+
+# Fetch the server private key from Vault into the in-memory keys volume.
+# With the KV engine the key is read from the secret at WG_VAULT_PATH, with
+# the Transit engine the sealed key of the mounted Secret is decrypted with
+# the transit key WG_VAULT_PATH. The key file is only rewritten when the key
+# changed, so that the watchdog picks up rotations.
+#
+# Usage: fetch-key.sh
+
+set -e
+
+WG_KEY_FILE=${WG_KEY_FILE:-/etc/wireguard/keys/server_private}
+WG_VAULT_AUTH_MOUNT=${WG_VAULT_AUTH_MOUNT:-kubernetes}
+WG_VAULT_ENGINE=${WG_VAULT_ENGINE:-KV}
+WG_VAULT_KEY_FIELD=${WG_VAULT_KEY_FIELD:-server_private}
+WG_VAULT_TOKEN_FILE=${WG_VAULT_TOKEN_FILE:-/var/run/secrets/kubernetes.io/serviceaccount/token}
+WG_STATE_DIR=${WG_STATE_DIR:-/run/wireflow}
+VAULT_TOKEN_CACHE=$WG_STATE_DIR/vault-token
+
+if [ -z "$WG_VAULT_ADDR" ]; then
+    echo "WG_VAULT_ADDR is not set" >&2
+    exit 1
+fi
+
+vault_api() {
+    local method=$1 path=$2 token=$3 data=$4
+    curl -sf -X "$method" \
+        ${token:+-H "X-Vault-Token: $token"} \
+        ${data:+-H "Content-Type: application/json" -d "$data"} \
+        "${WG_VAULT_ADDR%/}/v1/$path"
+}
+
+# A cached token is reused until Vault rejects it
+login() {
+    local jwt
+    jwt=$(cat "$WG_VAULT_TOKEN_FILE")
+    vault_api POST "auth/$WG_VAULT_AUTH_MOUNT/login" "" \
+        "$(jq -nc --arg role "$WG_VAULT_ROLE" --arg jwt "$jwt" '{role: $role, jwt: $jwt}')" |
+        jq -er '.auth.client_token'
+}
+
+fetch() {
+    local token=$1 ciphertext
+    case $WG_VAULT_ENGINE in
+    Transit)
+        ciphertext=$(tr -d '[:space:]' < "$WG_VAULT_SEALED_KEY_FILE" 2>/dev/null) || return 1
+        [ -n "$ciphertext" ] || return 1
+        vault_api POST "$WG_VAULT_MOUNT/decrypt/$WG_VAULT_PATH" "$token" \
+            "$(jq -nc --arg c "$ciphertext" '{ciphertext: $c}')" |
+            jq -er '.data.plaintext' | base64 -d
+        ;;
+    *)
+        vault_api GET "$WG_VAULT_MOUNT/data/$WG_VAULT_PATH" "$token" |
+            jq -er --arg field "$WG_VAULT_KEY_FIELD" '.data.data[$field]'
+        ;;
+    esac
+}
+
+mkdir -p "$WG_STATE_DIR"
+TOKEN=$(cat "$VAULT_TOKEN_CACHE" 2>/dev/null || true)
+if [ -z "$TOKEN" ] || ! KEY=$(fetch "$TOKEN"); then
+    if ! TOKEN=$(login); then
+        echo "Failed to log in to Vault at $WG_VAULT_ADDR with role $WG_VAULT_ROLE" >&2
+        exit 1
+    fi
+    (umask 077 && echo "$TOKEN" > "$VAULT_TOKEN_CACHE")
+    if ! KEY=$(fetch "$TOKEN"); then
+        echo "Failed to fetch the server key from $WG_VAULT_ENGINE $WG_VAULT_MOUNT/$WG_VAULT_PATH" >&2
+        exit 1
+    fi
+fi
+
+KEY=$(echo "$KEY" | tr -d '[:space:]')
+if [ -s "$WG_KEY_FILE" ] && [ "$(tr -d '[:space:]' < "$WG_KEY_FILE")" = "$KEY" ]; then
+    exit 0
+fi
+# Written next to the key and renamed, so readers never see a partial key
+(umask 077 && echo "$KEY" > "$WG_KEY_FILE.tmp")
+mv "$WG_KEY_FILE.tmp" "$WG_KEY_FILE"
+echo "Fetched the server key from Vault"
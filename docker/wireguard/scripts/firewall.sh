@@ -0,0 +1,500 @@
+#!/bin/bash
+
+# Program the forwarding and NAT rules of the WireGuard interface with
+# nftables or iptables-legacy, whichever the node uses. When session
+# metadata recording is enforced, forwarding is limited to the addresses of
+# peers admitted by the session recorder. Additional listen ports are
+# redirected to the listen port of the interface. With client isolation
+# peers cannot reach each other, except within the exception groups read
+# from the isolation file rendered by the operator. Ingress maps read from
+# the ingress file are forwarded with DNAT between the cluster and peers.
+# The traffic of peers is filtered by the rules read from the firewall file,
+# in order, before it is forwarded.
+#
+# Usage: firewall.sh up|down <interface>
+#        firewall.sh admit|revoke <interface> <cidr>
+#        firewall.sh isolate|ingress|filter <interface>
+
+set -e
+
+WG_FIREWALL_BACKEND=${WG_FIREWALL_BACKEND:-auto}
+WG_EGRESS_INTERFACE=${WG_EGRESS_INTERFACE:-eth0}
+WG_PORT=${WG_PORT:-51820}
+WG_ADDITIONAL_PORTS=${WG_ADDITIONAL_PORTS:-}
+WG_CLIENT_ISOLATION=${WG_CLIENT_ISOLATION:-false}
+WG_ISOLATION_CONFIG=${WG_ISOLATION_CONFIG:-/etc/wireguard/rendered/isolation}
+WG_INGRESS_CONFIG=${WG_INGRESS_CONFIG:-/etc/wireguard/rendered/ingress}
+WG_FIREWALL_CONFIG=${WG_FIREWALL_CONFIG:-/etc/wireguard/rendered/firewall}
+WG_SESSION_METADATA=${WG_SESSION_METADATA:-disabled}
+WG_STATE_DIR=${WG_STATE_DIR:-/run/wireflow}
+NFT_TABLE=wireflow
+SESSION_CHAIN=WIREFLOW-SESSIONS
+ISOLATION_CHAIN=WIREFLOW-ISOLATION
+INGRESS_CHAIN=WIREFLOW-INGRESS
+FIREWALL_CHAIN=WIREFLOW-FIREWALL
+SESSION_DIR=$WG_STATE_DIR/sessions
+BACKEND_STATE=$WG_STATE_DIR/firewall-backend
+
+ACTION=$1
+IFACE=$2
+CIDR=$3
+
+usage() {
+    echo "Usage: $0 up|down <interface>"
+    echo "       $0 admit|revoke <interface> <cidr>"
+    echo "       $0 isolate|ingress|filter <interface>"
+    exit 1
+}
+
+if [ -z "$ACTION" ] || [ -z "$IFACE" ]; then
+    usage
+fi
+
+# Count the rules programmed through a backend
+count_legacy_rules() {
+    iptables-legacy-save 2>/dev/null | grep -c '^-' || true
+}
+
+count_nft_rules() {
+    nft list ruleset 2>/dev/null | grep -c -E '^\s+(ip|ip6|iifname|oifname|meta|ct|tcp|udp|counter|jump|goto|accept|drop|masquerade|dnat|snat)' || true
+}
+
+# Pick the backend: an explicit override wins, otherwise follow the backend
+# already holding rules (kube-proxy, CNI) so ours are evaluated alongside them
+detect_backend() {
+    case "$WG_FIREWALL_BACKEND" in
+        nftables|iptables-legacy)
+            echo "$WG_FIREWALL_BACKEND"
+            return
+            ;;
+        auto)
+            ;;
+        *)
+            echo "Unknown firewall backend: $WG_FIREWALL_BACKEND" >&2
+            exit 1
+            ;;
+    esac
+
+    if ! command -v nft > /dev/null 2>&1 || ! nft list tables > /dev/null 2>&1; then
+        echo iptables-legacy
+    elif [ "$(count_legacy_rules)" -gt "$(count_nft_rules)" ]; then
+        echo iptables-legacy
+    else
+        echo nftables
+    fi
+}
+
+# Forwarding rules of the interface. Traffic between peers first passes
+# the isolation chain, then all traffic of peers the firewall chain. With
+# enforced session recording only admitted peer addresses may pass.
+nftables_forward_rules() {
+    if [ "$WG_CLIENT_ISOLATION" = "true" ]; then
+        echo "        iifname \"$IFACE\" oifname \"$IFACE\" jump isolation"
+    fi
+    echo "        iifname \"$IFACE\" jump firewall"
+    if [ "$WG_SESSION_METADATA" = "enforce" ]; then
+        cat << NFT
+        iifname "$IFACE" ip saddr @admitted accept
+        iifname "$IFACE" ip6 saddr @admitted6 accept
+        oifname "$IFACE" ip daddr @admitted accept
+        oifname "$IFACE" ip6 daddr @admitted6 accept
+        iifname "$IFACE" drop
+        oifname "$IFACE" drop
+NFT
+    else
+        cat << NFT
+        iifname "$IFACE" accept
+        oifname "$IFACE" accept
+NFT
+    fi
+}
+
+# Redirect of the additional listen ports, for clients on networks that only
+# allow specific UDP ports
+nftables_redirect_chain() {
+    [ -n "$WG_ADDITIONAL_PORTS" ] || return 0
+    cat << NFT
+    chain prerouting {
+        type nat hook prerouting priority -100; policy accept;
+        iifname != "$IFACE" udp dport { $WG_ADDITIONAL_PORTS } redirect to :$WG_PORT
+    }
+NFT
+}
+
+# Until the exceptions are read, isolation drops all traffic between peers
+nftables_isolation_chain() {
+    [ "$WG_CLIENT_ISOLATION" = "true" ] || return 0
+    cat << NFT
+    chain isolation {
+        drop
+    }
+NFT
+}
+
+nftables_up() {
+    nft -f - << NFT
+table inet $NFT_TABLE {
+    set admitted {
+        type ipv4_addr; flags interval;
+    }
+    set admitted6 {
+        type ipv6_addr; flags interval;
+    }
+$(nftables_isolation_chain)
+    chain firewall {
+    }
+    chain forward {
+        type filter hook forward priority 0; policy accept;
+$(nftables_forward_rules)
+    }
+    chain postrouting {
+        type nat hook postrouting priority 100; policy accept;
+        oifname "$WG_EGRESS_INTERFACE" masquerade
+        oifname "$IFACE" ct status dnat masquerade
+    }
+    chain ingress {
+        type nat hook prerouting priority -100; policy accept;
+    }
+$(nftables_redirect_chain)
+}
+NFT
+}
+
+nftables_down() {
+    nft delete table inet $NFT_TABLE 2>/dev/null || true
+}
+
+nftables_set() {
+    case "$CIDR" in
+        *:*) echo admitted6 ;;
+        *) echo admitted ;;
+    esac
+}
+
+nftables_admit() {
+    nft add element inet $NFT_TABLE "$(nftables_set)" "{ $CIDR }"
+}
+
+nftables_revoke() {
+    nft delete element inet $NFT_TABLE "$(nftables_set)" "{ $CIDR }" 2>/dev/null || true
+}
+
+# Members of an exception group, of one address family, one per line
+isolation_members() {
+    local family=$1
+    shift
+    for address in "$@"; do
+        case "$address" in
+            *:*) [ "$family" = 6 ] && echo "$address" ;;
+            *) [ "$family" = 4 ] && echo "$address" ;;
+        esac
+    done
+}
+
+# Rebuild the isolation chain from the exceptions: traffic between members
+# of the same group returns to the forward chain, everything else between
+# peers is dropped
+nftables_isolate() {
+    {
+        echo "flush chain inet $NFT_TABLE isolation"
+        if [ -f "$WG_ISOLATION_CONFIG" ]; then
+            while read -r group addresses; do
+                [ -n "$group" ] || continue
+                v4=$(isolation_members 4 $addresses | paste -sd, -)
+                v6=$(isolation_members 6 $addresses | paste -sd, -)
+                [ -z "$v4" ] || echo "add rule inet $NFT_TABLE isolation ip saddr { $v4 } ip daddr { $v4 } return comment \"$group\""
+                [ -z "$v6" ] || echo "add rule inet $NFT_TABLE isolation ip6 saddr { $v6 } ip6 daddr { $v6 } return comment \"$group\""
+            done < "$WG_ISOLATION_CONFIG"
+        fi
+        echo "add rule inet $NFT_TABLE isolation drop"
+    } | nft -f -
+}
+
+# Rebuild the ingress chain from the ingress maps. Maps on the cluster side
+# forward connections to the pod from outside the tunnel to a peer, maps on the tunnel side forward
+# connections from peers to the server's own address to a cluster Service.
+# Forwarded traffic leaving through the tunnel is masqueraded so that peers
+# reply through the server.
+nftables_ingress() {
+    {
+        echo "flush chain inet $NFT_TABLE ingress"
+        if [ -f "$WG_INGRESS_CONFIG" ]; then
+            while read -r side protocol port address target_port; do
+                [ -n "$side" ] || continue
+                case "$side" in
+                    cluster) match="iifname != \"$IFACE\"" ;;
+                    tunnel) match="iifname \"$IFACE\" fib daddr type local" ;;
+                    *) continue ;;
+                esac
+                case "$address" in
+                    *:*) family=ip6 nfproto=ipv6 destination="[$address]:$target_port" ;;
+                    *) family=ip nfproto=ipv4 destination="$address:$target_port" ;;
+                esac
+                echo "add rule inet $NFT_TABLE ingress $match meta nfproto $nfproto $protocol dport $port dnat $family to $destination"
+            done < "$WG_INGRESS_CONFIG"
+        fi
+    } | nft -f -
+}
+
+# Rebuild the firewall chain from the rules. Replies to allowed connections
+# pass first; allowed traffic returns to the forward chain and denied
+# traffic is dropped. Rules naming networks of one family only match that
+# family.
+nftables_filter() {
+    {
+        echo "flush chain inet $NFT_TABLE firewall"
+        echo "add rule inet $NFT_TABLE firewall ct state established,related return"
+        if [ -f "$WG_FIREWALL_CONFIG" ]; then
+            while read -r action protocol sources destinations ports name; do
+                case "$action" in
+                    allow) verdict=return ;;
+                    deny) verdict=drop ;;
+                    default)
+                        # The last line holds the default action
+                        [ "$protocol" != deny ] || echo "add rule inet $NFT_TABLE firewall drop"
+                        continue
+                        ;;
+                    *) continue ;;
+                esac
+                for family in 4 6; do
+                    [ "$sources" = - ] || src=$(firewall_networks $family "$sources")
+                    [ "$destinations" = - ] || dst=$(firewall_networks $family "$destinations")
+                    match=""
+                    if [ "$sources" != - ] || [ "$destinations" != - ]; then
+                        # Networks of the other family only
+                        [ "$sources" = - ] || [ -n "$src" ] || continue
+                        [ "$destinations" = - ] || [ -n "$dst" ] || continue
+                        ip=ip
+                        [ "$family" = 4 ] || ip=ip6
+                        [ "$sources" = - ] || match+=" $ip saddr { $src }"
+                        [ "$destinations" = - ] || match+=" $ip daddr { $dst }"
+                    elif [ "$family" = 6 ]; then
+                        continue
+                    fi
+                    case "$protocol" in
+                        tcp|udp)
+                            match+=" meta l4proto $protocol"
+                            [ "$ports" = - ] || match+=" th dport { $ports }"
+                            ;;
+                        icmp) match+=" meta l4proto { icmp, ipv6-icmp }" ;;
+                    esac
+                    echo "add rule inet $NFT_TABLE firewall$match $verdict comment \"$name\""
+                done
+            done < "$WG_FIREWALL_CONFIG"
+        fi
+    } | nft -f -
+}
+
+# Networks of a comma separated list of one address family, comma separated
+firewall_networks() {
+    local family=$1
+    isolation_members "$family" ${2//,/ } | paste -sd, -
+}
+
+iptables_legacy_up() {
+    if [ "$WG_SESSION_METADATA" = "enforce" ]; then
+        iptables-legacy -N $SESSION_CHAIN
+        iptables-legacy -A $SESSION_CHAIN -j DROP
+        iptables-legacy -A FORWARD -i "$IFACE" -j $SESSION_CHAIN
+        iptables-legacy -A FORWARD -o "$IFACE" -j $SESSION_CHAIN
+    else
+        iptables-legacy -A FORWARD -i "$IFACE" -j ACCEPT
+        iptables-legacy -A FORWARD -o "$IFACE" -j ACCEPT
+    fi
+    iptables-legacy -N $FIREWALL_CHAIN
+    iptables-legacy -I FORWARD 1 -i "$IFACE" -j $FIREWALL_CHAIN
+    if [ "$WG_CLIENT_ISOLATION" = "true" ]; then
+        iptables-legacy -N $ISOLATION_CHAIN
+        iptables-legacy -A $ISOLATION_CHAIN -j DROP
+        iptables-legacy -I FORWARD 1 -i "$IFACE" -o "$IFACE" -j $ISOLATION_CHAIN
+    fi
+    iptables-legacy -t nat -A POSTROUTING -o "$WG_EGRESS_INTERFACE" -j MASQUERADE
+    iptables-legacy -t nat -A POSTROUTING -o "$IFACE" -m conntrack --ctstate DNAT -j MASQUERADE
+    iptables-legacy -t nat -N $INGRESS_CHAIN
+    iptables-legacy -t nat -A PREROUTING -j $INGRESS_CHAIN
+    for port in ${WG_ADDITIONAL_PORTS//,/ }; do
+        iptables-legacy -t nat -A PREROUTING ! -i "$IFACE" -p udp --dport "$port" -j REDIRECT --to-ports "$WG_PORT"
+    done
+}
+
+iptables_legacy_down() {
+    if iptables-legacy -n -L $FIREWALL_CHAIN > /dev/null 2>&1; then
+        iptables-legacy -D FORWARD -i "$IFACE" -j $FIREWALL_CHAIN || true
+        iptables-legacy -F $FIREWALL_CHAIN
+        iptables-legacy -X $FIREWALL_CHAIN
+    fi
+    if iptables-legacy -n -L $ISOLATION_CHAIN > /dev/null 2>&1; then
+        iptables-legacy -D FORWARD -i "$IFACE" -o "$IFACE" -j $ISOLATION_CHAIN || true
+        iptables-legacy -F $ISOLATION_CHAIN
+        iptables-legacy -X $ISOLATION_CHAIN
+    fi
+    if iptables-legacy -n -L $SESSION_CHAIN > /dev/null 2>&1; then
+        iptables-legacy -D FORWARD -i "$IFACE" -j $SESSION_CHAIN || true
+        iptables-legacy -D FORWARD -o "$IFACE" -j $SESSION_CHAIN || true
+        iptables-legacy -F $SESSION_CHAIN
+        iptables-legacy -X $SESSION_CHAIN
+    else
+        iptables-legacy -D FORWARD -i "$IFACE" -j ACCEPT || true
+        iptables-legacy -D FORWARD -o "$IFACE" -j ACCEPT || true
+    fi
+    iptables-legacy -t nat -D POSTROUTING -o "$WG_EGRESS_INTERFACE" -j MASQUERADE || true
+    iptables-legacy -t nat -D POSTROUTING -o "$IFACE" -m conntrack --ctstate DNAT -j MASQUERADE || true
+    if iptables-legacy -t nat -n -L $INGRESS_CHAIN > /dev/null 2>&1; then
+        iptables-legacy -t nat -D PREROUTING -j $INGRESS_CHAIN || true
+        iptables-legacy -t nat -F $INGRESS_CHAIN
+        iptables-legacy -t nat -X $INGRESS_CHAIN
+    fi
+    for port in ${WG_ADDITIONAL_PORTS//,/ }; do
+        iptables-legacy -t nat -D PREROUTING ! -i "$IFACE" -p udp --dport "$port" -j REDIRECT --to-ports "$WG_PORT" || true
+    done
+}
+
+# The session chain only covers IPv4, IPv6 forwarding is not programmed
+# through iptables-legacy
+iptables_legacy_admit() {
+    case "$CIDR" in *:*) return ;; esac
+    iptables-legacy -I $SESSION_CHAIN -s "$CIDR" -j ACCEPT
+    iptables-legacy -I $SESSION_CHAIN -d "$CIDR" -j ACCEPT
+}
+
+# iptables-legacy has no sets, so an exception group costs a rule per pair
+# of its members; prefer nftables for large groups. The IPv6 forwarding of
+# the interface is not programmed through iptables-legacy.
+iptables_legacy_isolate() {
+    iptables-legacy -F $ISOLATION_CHAIN
+    if [ -f "$WG_ISOLATION_CONFIG" ]; then
+        while read -r group addresses; do
+            [ -n "$group" ] || continue
+            members=$(isolation_members 4 $addresses)
+            for src in $members; do
+                for dst in $members; do
+                    [ "$src" = "$dst" ] || iptables-legacy -A $ISOLATION_CHAIN -s "$src" -d "$dst" -j RETURN
+                done
+            done
+        done < "$WG_ISOLATION_CONFIG"
+    fi
+    iptables-legacy -A $ISOLATION_CHAIN -j DROP
+}
+
+# Ingress maps to IPv6 targets are not programmed through iptables-legacy
+iptables_legacy_ingress() {
+    iptables-legacy -t nat -F $INGRESS_CHAIN
+    [ -f "$WG_INGRESS_CONFIG" ] || return 0
+    while read -r side protocol port address target_port; do
+        case "$address" in *:*|"") continue ;; esac
+        case "$side" in
+            cluster)
+                iptables-legacy -t nat -A $INGRESS_CHAIN ! -i "$IFACE" -p "$protocol" --dport "$port" \
+                    -j DNAT --to-destination "$address:$target_port"
+                ;;
+            tunnel)
+                iptables-legacy -t nat -A $INGRESS_CHAIN -i "$IFACE" -m addrtype --dst-type LOCAL -p "$protocol" --dport "$port" \
+                    -j DNAT --to-destination "$address:$target_port"
+                ;;
+        esac
+    done < "$WG_INGRESS_CONFIG"
+}
+
+# Rules with IPv6 networks only are not programmed through iptables-legacy,
+# like the IPv6 forwarding of the interface. Lists of networks expand into a
+# rule per pair.
+iptables_legacy_filter() {
+    local rule
+    iptables-legacy -F $FIREWALL_CHAIN
+    iptables-legacy -A $FIREWALL_CHAIN -m conntrack --ctstate ESTABLISHED,RELATED -j RETURN
+    [ -f "$WG_FIREWALL_CONFIG" ] || return 0
+    while read -r action protocol sources destinations ports name; do
+        case "$action" in
+            allow) verdict=RETURN ;;
+            deny) verdict=DROP ;;
+            default)
+                [ "$protocol" != deny ] || iptables-legacy -A $FIREWALL_CHAIN -j DROP
+                continue
+                ;;
+            *) continue ;;
+        esac
+        rule=()
+        if [ "$sources" != - ]; then
+            src=$(firewall_networks 4 "$sources")
+            [ -n "$src" ] || continue
+            rule+=(-s "$src")
+        fi
+        if [ "$destinations" != - ]; then
+            dst=$(firewall_networks 4 "$destinations")
+            [ -n "$dst" ] || continue
+            rule+=(-d "$dst")
+        fi
+        case "$protocol" in
+            tcp|udp)
+                rule+=(-p "$protocol")
+                [ "$ports" = - ] || rule+=(-m multiport --dports "${ports//-/:}")
+                ;;
+            icmp) rule+=(-p icmp) ;;
+        esac
+        iptables-legacy -A $FIREWALL_CHAIN "${rule[@]}" -m comment --comment "$name" -j $verdict
+    done < "$WG_FIREWALL_CONFIG"
+}
+
+iptables_legacy_revoke() {
+    case "$CIDR" in *:*) return ;; esac
+    iptables-legacy -D $SESSION_CHAIN -s "$CIDR" -j ACCEPT 2>/dev/null || true
+    iptables-legacy -D $SESSION_CHAIN -d "$CIDR" -j ACCEPT 2>/dev/null || true
+}
+
+case "$ACTION" in
+    up)
+        BACKEND=$(detect_backend)
+        mkdir -p "$(dirname $BACKEND_STATE)"
+        echo "$BACKEND" > $BACKEND_STATE
+        echo "Programming firewall rules for $IFACE with $BACKEND"
+        ;;
+    down)
+        # Tear down with the backend the rules were programmed with
+        BACKEND=$(cat $BACKEND_STATE 2>/dev/null || detect_backend)
+        ;;
+    admit|revoke)
+        [ -n "$CIDR" ] || usage
+        BACKEND=$(cat $BACKEND_STATE)
+        ;;
+    isolate)
+        [ "$WG_CLIENT_ISOLATION" = "true" ] || exit 0
+        BACKEND=$(cat $BACKEND_STATE)
+        ;;
+    ingress|filter)
+        BACKEND=$(cat $BACKEND_STATE)
+        ;;
+    *)
+        usage
+        ;;
+esac
+
+run() {
+    case "$BACKEND" in
+        nftables) nftables_$1 ;;
+        iptables-legacy) iptables_legacy_$1 ;;
+    esac
+}
+
+run $ACTION
+
+# Re-admit the peers of sessions already recorded when the interface is
+# brought back up
+if [ "$ACTION" = "up" ] && [ "$WG_SESSION_METADATA" = "enforce" ]; then
+    for state in "$SESSION_DIR"/*; do
+        [ -f "$state" ] || continue
+        for CIDR in $(sed -n 3p "$state" | tr ',' ' '); do
+            [ "$CIDR" = "(none)" ] || run admit
+        done
+    done
+fi
+
+# Apply the isolation exceptions rendered so far
+if [ "$ACTION" = "up" ] && [ "$WG_CLIENT_ISOLATION" = "true" ]; then
+    run isolate
+fi
+
+# Apply the ingress maps and firewall rules rendered so far
+if [ "$ACTION" = "up" ]; then
+    run ingress
+    run filter
+fi
@@ -0,0 +1,87 @@
+#!/bin/bash
+
+# Install routes to the peer networks of VPN servers on the node, so that
+# return traffic from pods reaches remote sites. Runs in the host network
+# namespace as part of the node-routes DaemonSet.
+#
+# The operator publishes the routes in a ConfigMap, one entry per server
+# holding "<cidr> <server pod IP>" lines. Installed routes are tagged with
+# a dedicated protocol so that routes no longer published can be removed
+# without touching others.
+
+WG_ROUTES_NAMESPACE=${WG_ROUTES_NAMESPACE:-vpn-system}
+WG_ROUTES_CONFIGMAP=${WG_ROUTES_CONFIGMAP:-wireflow-node-routes}
+WG_ROUTES_INTERVAL=${WG_ROUTES_INTERVAL:-5}
+# Route protocol of the routes we own, see /etc/iproute2/rt_protos
+WG_ROUTES_PROTO=${WG_ROUTES_PROTO:-211}
+
+SA=/var/run/secrets/kubernetes.io/serviceaccount
+
+# Fetch the desired routes as "<cidr> <gateway>" lines
+desired_routes() {
+    curl -sf \
+        --cacert "$SA/ca.crt" \
+        -H "Authorization: Bearer $(cat $SA/token)" \
+        "https://kubernetes.default.svc/api/v1/namespaces/$WG_ROUTES_NAMESPACE/configmaps/$WG_ROUTES_CONFIGMAP" \
+        | jq -r '.data // {} | to_entries[] | .value' \
+        | grep -v '^$' | sort -u -k1,1
+}
+
+# Resolve the next hop of a gateway pod IP. Pods on other nodes are reached
+# through the CNI, pods on this node through their interface.
+nexthop() {
+    local gateway=$1 route
+    route=$(ip route get "$gateway" 2>/dev/null | head -1)
+    [ -n "$route" ] || return 1
+
+    local via dev
+    via=$(echo "$route" | sed -n 's/.* via \([^ ]*\).*/\1/p')
+    dev=$(echo "$route" | sed -n 's/.* dev \([^ ]*\).*/\1/p')
+    [ -n "$dev" ] || return 1
+    echo "via ${via:-$gateway} dev $dev onlink"
+}
+
+installed_routes() {
+    {
+        ip -4 route show proto "$WG_ROUTES_PROTO"
+        ip -6 route show proto "$WG_ROUTES_PROTO"
+    } | awk '{ if ($1 ~ /\//) print $1; else if ($1 ~ /:/) print $1 "/128"; else print $1 "/32" }'
+}
+
+sync_routes() {
+    local desired
+    if ! desired=$(desired_routes); then
+        # Keep the routes in place while the API server is unreachable
+        echo "Unable to fetch routes from $WG_ROUTES_NAMESPACE/$WG_ROUTES_CONFIGMAP"
+        return
+    fi
+
+    local cidr gateway hop
+    while read -r cidr gateway; do
+        [ -n "$cidr" ] || continue
+        if ! hop=$(nexthop "$gateway"); then
+            echo "No route to gateway $gateway of $cidr"
+            continue
+        fi
+        # shellcheck disable=SC2086
+        ip route replace "$cidr" $hop proto "$WG_ROUTES_PROTO" || echo "Failed to install route to $cidr"
+    done <<< "$desired"
+
+    for cidr in $(installed_routes); do
+        if ! echo "$desired" | grep -q "^$cidr "; then
+            echo "Removing route to $cidr"
+            ip route del "$cidr" proto "$WG_ROUTES_PROTO" || true
+        fi
+    done
+}
+
+# Routes are left in place on exit so that traffic keeps flowing while the
+# DaemonSet is updated
+trap 'exit 0' TERM INT
+
+echo "Syncing routes from $WG_ROUTES_NAMESPACE/$WG_ROUTES_CONFIGMAP every ${WG_ROUTES_INTERVAL}s"
+while true; do
+    sync_routes
+    sleep "$WG_ROUTES_INTERVAL" &
+    wait $!
+done
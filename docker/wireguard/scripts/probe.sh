@@ -0,0 +1,73 @@
+#!/bin/bash
+
+# Probe of a VPNConnectivityCheck: connect to the server as the probe peer
+# with a key generated here, then ping the tunnel address of the server and
+# push each sample on our own pod, once or every WG_PROBE_INTERVAL seconds.
+
+set -e
+
+WG_INTERFACE=${WG_INTERFACE:-wg0}
+WG_PROBE_PINGS=${WG_PROBE_PINGS:-10}
+WG_PROBE_INTERVAL=${WG_PROBE_INTERVAL:-0}
+PROBE_PUBLIC_KEY_ANNOTATION="vpn.vpn-devops.com/probe-public-key"
+PROBE_SAMPLE_ANNOTATION="vpn.vpn-devops.com/probe-sample"
+
+# Call the API of the cluster with the service account of the pod, on a
+# path under the namespace of the pod
+SERVICE_ACCOUNT=/var/run/secrets/kubernetes.io/serviceaccount
+kube_api() {
+    local method=$1 path=$2 content_type=$3 body=$4
+    [ -f "$SERVICE_ACCOUNT/token" ] || return 0
+    curl -sf -X "$method" \
+        --cacert "$SERVICE_ACCOUNT/ca.crt" \
+        -H "Authorization: Bearer $(cat $SERVICE_ACCOUNT/token)" \
+        -H "Content-Type: $content_type" \
+        -d "$body" \
+        "https://kubernetes.default.svc/api/v1/namespaces/$(cat $SERVICE_ACCOUNT/namespace)/$path" > /dev/null
+}
+
+annotate() {
+    kube_api PATCH "pods/$HOSTNAME" application/merge-patch+json \
+        "$(jq -nc --arg key "$1" --arg value "$2" '{metadata: {annotations: {($key): $value}}}')"
+}
+
+echo "Probing $WG_PROBE_TARGET through $WG_SERVER_ENDPOINT as $WG_PROBE_ADDRESS"
+
+umask 077
+wg genkey > /tmp/probe_private
+PUBLIC_KEY=$(wg pubkey < /tmp/probe_private)
+
+ip link add "$WG_INTERFACE" type wireguard
+wg set "$WG_INTERFACE" private-key /tmp/probe_private \
+    peer "$WG_SERVER_PUBLIC_KEY" endpoint "$WG_SERVER_ENDPOINT" \
+    allowed-ips "$WG_PROBE_TARGET" persistent-keepalive 25
+ip address add "$WG_PROBE_ADDRESS" dev "$WG_INTERFACE"
+ip link set up dev "$WG_INTERFACE"
+ip route add "$WG_PROBE_TARGET" dev "$WG_INTERFACE"
+
+# The server only accepts the key once the operator set it on the probe
+# peer and the server reloaded it
+until annotate "$PROBE_PUBLIC_KEY_ANNOTATION" "$PUBLIC_KEY"; do
+    sleep 5
+done
+echo "Waiting for the first handshake..."
+until [ "$(wg show "$WG_INTERFACE" latest-handshakes | awk '{print $2}')" != "0" ]; do
+    ping -c 1 -W 1 "$WG_PROBE_TARGET" > /dev/null 2>&1 || true
+    sleep 2
+done
+
+while true; do
+    output=$(ping -q -c "$WG_PROBE_PINGS" -i 0.2 -W 1 "$WG_PROBE_TARGET" 2>/dev/null || true)
+    received=$(sed -n 's/.* \([0-9]*\) received.*/\1/p' <<< "$output")
+    loss=$(( (WG_PROBE_PINGS - ${received:-0}) * 100 / WG_PROBE_PINGS ))
+    latency=$(sed -n 's|^rtt .* = [0-9.]*/\([0-9.]*\)/.*|\1|p' <<< "$output")
+    sample=$(jq -nc --argjson time "$(date +%s)" --arg latency "$latency" --argjson loss "$loss" \
+        '{time: $time, latency: (if $latency == "" then null else ($latency | tonumber) end), loss: $loss}')
+    echo "Sample: $sample"
+    annotate "$PROBE_SAMPLE_ANNOTATION" "$sample" || echo "Failed to report the sample" >&2
+    [ "$WG_PROBE_INTERVAL" -gt 0 ] || break
+    sleep "$WG_PROBE_INTERVAL"
+done
+
+# A probe of the Once mode is removed once its sample is recorded
+sleep infinity
@@ -0,0 +1,217 @@
+#!/bin/bash
+
+# Record the metadata of peer sessions (start and stop times, transferred
+# bytes and endpoints) and ship it to the configured sink. Traffic contents
+# are never recorded.
+#
+# With WG_SESSION_METADATA=enforce a peer is only admitted through the
+# firewall once the start of its session has been shipped. When the sink
+# cannot be reached, fail-closed keeps the peer blocked and fail-open admits
+# it and spools the record until the sink recovers.
+#
+# Usage: session-recorder.sh <interface>
+
+WG_SESSION_METADATA=${WG_SESSION_METADATA:-disabled}
+WG_SESSION_FAILURE_POLICY=${WG_SESSION_FAILURE_POLICY:-fail-closed}
+WG_SESSION_SINK_URL=${WG_SESSION_SINK_URL:-}
+WG_SESSION_SINK_TOKEN=${WG_SESSION_SINK_TOKEN:-}
+WG_SESSION_SERVER=${WG_SESSION_SERVER:-$HOSTNAME}
+WG_SESSION_POLL_INTERVAL=${WG_SESSION_POLL_INTERVAL:-2}
+# A session without a handshake for this long has ended, it is WireGuard's
+# reject-after-time plus a grace period
+WG_SESSION_IDLE_TIMEOUT=${WG_SESSION_IDLE_TIMEOUT:-200}
+WG_STATE_DIR=${WG_STATE_DIR:-/run/wireflow}
+WG_SCRIPTS_DIR=${WG_SCRIPTS_DIR:-/scripts}
+SESSION_DIR=$WG_STATE_DIR/sessions
+SPOOL_DIR=$WG_STATE_DIR/session-spool
+
+IFACE=$1
+
+if [ -z "$IFACE" ]; then
+    echo "Usage: $0 <interface>"
+    exit 1
+fi
+
+case "$WG_SESSION_METADATA" in
+    enabled|enforce) ;;
+    *) exit 0 ;;
+esac
+
+if [ -z "$WG_SESSION_SINK_URL" ]; then
+    echo "WG_SESSION_SINK_URL is required to record session metadata"
+    exit 1
+fi
+
+mkdir -p $SESSION_DIR $SPOOL_DIR
+
+# POST a record to the sink
+ship() {
+    local auth=()
+    if [ -n "$WG_SESSION_SINK_TOKEN" ]; then
+        auth=(-H "Authorization: Bearer $WG_SESSION_SINK_TOKEN")
+    fi
+    curl -sf --max-time 5 -X POST \
+        -H "Content-Type: application/json" \
+        "${auth[@]}" \
+        -d "$1" \
+        "$WG_SESSION_SINK_URL" > /dev/null
+}
+
+# Keep a record that could not be shipped for a later attempt
+spool() {
+    echo "$1" > "$SPOOL_DIR/$(date +%s%N)"
+}
+
+# Ship spooled records in order, stopping at the first failure
+flush_spool() {
+    local file
+    for file in $(ls $SPOOL_DIR | sort -n); do
+        ship "$(cat $SPOOL_DIR/$file)" || return 1
+        rm -f "$SPOOL_DIR/$file"
+    done
+}
+
+# Build a session record
+record() {
+    jq -cn \
+        --arg type "$1" \
+        --arg server "$WG_SESSION_SERVER" \
+        --arg interface "$IFACE" \
+        --arg node "$HOSTNAME" \
+        --arg publicKey "$2" \
+        --arg endpoint "$3" \
+        --arg allowedIPs "$4" \
+        --arg start "$5" \
+        --arg time "$(date -u +%Y-%m-%dT%H:%M:%SZ)" \
+        --argjson receiveBytes "${6:-0}" \
+        --argjson transmitBytes "${7:-0}" \
+        '{type: $type, server: $server, interface: $interface, node: $node,
+          publicKey: $publicKey, endpoint: $endpoint,
+          allowedIPs: ($allowedIPs | split(",") | map(select(. != "(none)"))),
+          start: $start, time: $time,
+          receiveBytes: $receiveBytes, transmitBytes: $transmitBytes}'
+}
+
+admit() {
+    [ "$WG_SESSION_METADATA" = "enforce" ] || return 0
+    local cidr
+    for cidr in ${1//,/ }; do
+        [ "$cidr" = "(none)" ] || "$WG_SCRIPTS_DIR/firewall.sh" admit "$IFACE" "$cidr"
+    done
+}
+
+revoke() {
+    [ "$WG_SESSION_METADATA" = "enforce" ] || return 0
+    local cidr
+    for cidr in ${1//,/ }; do
+        [ "$cidr" = "(none)" ] || "$WG_SCRIPTS_DIR/firewall.sh" revoke "$IFACE" "$cidr"
+    done
+}
+
+# The state file of a session is named after the peer public key
+state_file() {
+    echo "$SESSION_DIR/$(echo "$1" | tr '/+' '_-')"
+}
+
+start_session() {
+    local key=$1 endpoint=$2 allowed=$3 rx=$4 tx=$5
+    local start
+    start=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+    local rec
+    rec=$(record start "$key" "$endpoint" "$allowed" "$start")
+
+    # Older records must reach the sink first so it sees sessions in order
+    if ! { flush_spool && ship "$rec"; }; then
+        if [ "$WG_SESSION_METADATA" = "enforce" ] && [ "$WG_SESSION_FAILURE_POLICY" = "fail-closed" ]; then
+            echo "Session sink unavailable, peer $key stays blocked"
+            return
+        fi
+        echo "Session sink unavailable, spooling session start of peer $key"
+        spool "$rec"
+    fi
+
+    printf '%s\n%s\n%s\n%s\n%s\n' "$start" "$endpoint" "$allowed" "$rx" "$tx" > "$(state_file "$key")"
+    admit "$allowed"
+}
+
+stop_session() {
+    local key=$1 rx=${2:-} tx=${3:-}
+    local file
+    file=$(state_file "$key")
+    local start endpoint allowed rx0 tx0
+    { read -r start; read -r endpoint; read -r allowed; read -r rx0; read -r tx0; } < "$file"
+
+    revoke "$allowed"
+    # A removed peer has no final counters, report what was last seen
+    rx=${rx:-$rx0}
+    tx=${tx:-$tx0}
+    local rec
+    rec=$(record stop "$key" "$endpoint" "$allowed" "$start" $((rx - rx0)) $((tx - tx0)))
+    if ! { flush_spool && ship "$rec"; }; then
+        spool "$rec"
+    fi
+    rm -f "$file"
+}
+
+# Report endpoint changes of a running session
+roam_session() {
+    local key=$1 endpoint=$2
+    local file
+    file=$(state_file "$key")
+    local start allowed
+    start=$(sed -n 1p "$file")
+    allowed=$(sed -n 3p "$file")
+    local rec
+    rec=$(record roam "$key" "$endpoint" "$allowed" "$start")
+    if ! { flush_spool && ship "$rec"; }; then
+        spool "$rec"
+    fi
+    sed -i "2s|.*|$endpoint|" "$file"
+}
+
+poll() {
+    local now seen=" "
+    now=$(date +%s)
+
+    # Peer lines of the dump: public-key preshared-key endpoint allowed-ips
+    # latest-handshake transfer-rx transfer-tx persistent-keepalive
+    while read -r key _ endpoint allowed handshake rx tx _; do
+        seen="$seen$key "
+        local file
+        file=$(state_file "$key")
+        local active=false
+        if [ "$handshake" -gt 0 ] && [ $((now - handshake)) -lt "$WG_SESSION_IDLE_TIMEOUT" ]; then
+            active=true
+        fi
+
+        if [ "$active" = true ] && [ ! -f "$file" ]; then
+            start_session "$key" "$endpoint" "$allowed" "$rx" "$tx"
+        elif [ "$active" = true ] && [ "$(sed -n 2p "$file")" != "$endpoint" ]; then
+            roam_session "$key" "$endpoint"
+        elif [ "$active" = false ] && [ -f "$file" ]; then
+            stop_session "$key" "$rx" "$tx"
+        fi
+    done < <("$WG_SCRIPTS_DIR/wg-op.sh" dump "$IFACE" wg show "$IFACE" dump | tail -n +2)
+
+    # End the sessions of peers removed from the device
+    local file
+    for file in "$SESSION_DIR"/*; do
+        [ -f "$file" ] || continue
+        local key
+        key=$(basename "$file" | tr '_-' '/+')
+        case "$seen" in
+            *" $key "*) ;;
+            *) stop_session "$key" ;;
+        esac
+    done
+
+    flush_spool > /dev/null 2>&1 || true
+}
+
+echo "Recording session metadata of $IFACE ($WG_SESSION_METADATA, $WG_SESSION_FAILURE_POLICY)"
+while true; do
+    if "$WG_SCRIPTS_DIR/wg-op.sh" show "$IFACE" wg show "$IFACE" > /dev/null 2>&1; then
+        poll
+    fi
+    sleep "$WG_SESSION_POLL_INTERVAL"
+done
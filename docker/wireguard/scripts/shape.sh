@@ -0,0 +1,111 @@
+#!/bin/bash
+
+# Shape the traffic of peers to the bandwidth limits read from the bandwidth
+# file rendered by the operator, one "<cidr> <rate bits/s> <burst bytes>"
+# line per peer. Downloads are shaped with an HTB class per peer on the
+# egress of the WireGuard interface, matching the peer address as
+# destination. Uploads are redirected from the ingress of the interface to
+# an ifb device and shaped there, matching it as source. Traffic of peers
+# without a limit is left unclassified and passes unshaped.
+#
+# The result of each peer, its rate and burst or the error that prevented
+# shaping it, is written to the bandwidth results file as a JSON object.
+#
+# Usage: shape.sh apply|clear <interface>
+
+set -e
+
+WG_BANDWIDTH_CONFIG=${WG_BANDWIDTH_CONFIG:-/etc/wireguard/rendered/bandwidth}
+WG_STATE_DIR=${WG_STATE_DIR:-/run/wireflow}
+RESULTS=$WG_STATE_DIR/bandwidth-results
+
+ACTION=$1
+IFACE=$2
+
+usage() {
+    echo "Usage: $0 apply|clear <interface>"
+    exit 1
+}
+
+if [ -z "$ACTION" ] || [ -z "$IFACE" ]; then
+    usage
+fi
+
+# Interface names are limited to 15 characters
+IFB=$(echo "ifb-$IFACE" | cut -c1-15)
+
+clear_shaping() {
+    tc qdisc del dev "$IFACE" root 2>/dev/null || true
+    tc qdisc del dev "$IFACE" ingress 2>/dev/null || true
+    ip link del dev "$IFB" 2>/dev/null || true
+}
+
+# Set up the root HTB qdiscs: on the interface for downloads, on the ifb
+# device for uploads. Uploads stay unshaped if the ifb module is missing.
+setup_qdiscs() {
+    tc qdisc add dev "$IFACE" root handle 1: htb default ffff || return 1
+    if ! ip link add dev "$IFB" type ifb 2>/dev/null || ! ip link set dev "$IFB" up; then
+        ip link del dev "$IFB" 2>/dev/null || true
+        return 0
+    fi
+    tc qdisc add dev "$IFB" root handle 1: htb default ffff &&
+        tc qdisc add dev "$IFACE" handle ffff: ingress &&
+        tc filter add dev "$IFACE" parent ffff: protocol all prio 1 u32 match u32 0 0 \
+            action mirred egress redirect dev "$IFB"
+}
+
+# Add the class and filter of a peer on a device, matching its address as
+# dst or src
+shape_peer() {
+    local dev=$1 direction=$2 classid=$3 cidr=$4 rate=$5 burst=$6
+    tc class add dev "$dev" parent 1: classid "1:$classid" htb rate "${rate}bit" burst "${burst}b" cburst "${burst}b" &&
+        tc qdisc add dev "$dev" parent "1:$classid" fq_codel &&
+        case $cidr in
+            *:*) tc filter add dev "$dev" parent 1: protocol ipv6 prio 2 u32 match ip6 "$direction" "$cidr" flowid "1:$classid" ;;
+            *) tc filter add dev "$dev" parent 1: protocol ip prio 1 u32 match ip "$direction" "$cidr" flowid "1:$classid" ;;
+        esac
+}
+
+# Shape each peer of the bandwidth file, recording its result. A failed
+# qdisc setup fails every peer, a failed class only its own peer.
+apply_shaping() {
+    local results='{}' id=16 setup_error="" uploads=false cidr rate burst classid error
+    clear_shaping
+    if [ -s "$WG_BANDWIDTH_CONFIG" ]; then
+        if ! setup_error=$(setup_qdiscs 2>&1); then
+            setup_error="setting up the qdiscs of $IFACE: ${setup_error:-tc failed}"
+            clear_shaping
+        fi
+        ! ip link show dev "$IFB" > /dev/null 2>&1 || uploads=true
+    fi
+    while read -r cidr rate burst; do
+        [ -n "$cidr" ] || continue
+        error=$setup_error
+        if [ -z "$error" ]; then
+            classid=$(printf '%x' $id)
+            id=$((id + 1))
+            if ! error=$(shape_peer "$IFACE" dst "$classid" "$cidr" "$rate" "$burst" 2>&1); then
+                error="download: ${error:-tc failed}"
+            elif [ "$uploads" != true ]; then
+                error="upload: the ifb module is not available"
+            elif ! error=$(shape_peer "$IFB" src "$classid" "$cidr" "$rate" "$burst" 2>&1); then
+                error="upload: ${error:-tc failed}"
+            else
+                error=""
+            fi
+        fi
+        results=$(jq -c --arg cidr "$cidr" --argjson rate "$rate" --argjson burst "$burst" --arg error "$error" \
+            '.[$cidr] = if $error == "" then {rate: $rate, burst: $burst} else {error: $error} end' <<< "$results")
+    done < <(cat "$WG_BANDWIDTH_CONFIG" 2>/dev/null)
+    mkdir -p "$WG_STATE_DIR"
+    echo "$results" > "$RESULTS"
+}
+
+case "$ACTION" in
+    apply) apply_shaping ;;
+    clear)
+        clear_shaping
+        rm -f "$RESULTS"
+        ;;
+    *) usage ;;
+esac
@@ -8,11 +8,94 @@ WG_HOST=${WG_HOST:-$(curl -s ifconfig.me)}
 WG_PORT=${WG_PORT:-51820}
 WG_DEFAULT_ADDRESS=${WG_DEFAULT_ADDRESS:-10.0.0.1}
 WG_DEFAULT_DNS=${WG_DEFAULT_DNS:-8.8.8.8}
+WG_IMPLEMENTATION=${WG_IMPLEMENTATION:-kernel}
+WG_RENDERED_CONFIG=${WG_RENDERED_CONFIG:-/etc/wireguard/rendered/$WG_INTERFACE.conf}
+export WG_ISOLATION_CONFIG=${WG_ISOLATION_CONFIG:-$(dirname "$WG_RENDERED_CONFIG")/isolation}
+export WG_INGRESS_CONFIG=${WG_INGRESS_CONFIG:-$(dirname "$WG_RENDERED_CONFIG")/ingress}
+export WG_FIREWALL_CONFIG=${WG_FIREWALL_CONFIG:-$(dirname "$WG_RENDERED_CONFIG")/firewall}
+WG_SPLIT_DNS_CONFIG=${WG_SPLIT_DNS_CONFIG:-$(dirname "$WG_RENDERED_CONFIG")/split-dns}
+WG_SHARDS_CONFIG=${WG_SHARDS_CONFIG:-$(dirname "$WG_RENDERED_CONFIG")/shards}
+export WG_BANDWIDTH_CONFIG=${WG_BANDWIDTH_CONFIG:-$(dirname "$WG_RENDERED_CONFIG")/bandwidth}
+PODINFO_ANNOTATIONS=${PODINFO_ANNOTATIONS:-/etc/podinfo/annotations}
+WG_STATE_DIR=${WG_STATE_DIR:-/run/wireflow}
+WG_METRICS_PORT=${WG_METRICS_PORT:-9090}
+WG_WATCHDOG_INTERVAL=${WG_WATCHDOG_INTERVAL:-2}
+WG_WATCHDOG_MAX_FAILURES=${WG_WATCHDOG_MAX_FAILURES:-5}
+METRICS_DIR=$WG_STATE_DIR/metrics
+CONFIG_CHECKSUM_ANNOTATION="vpn.vpn-devops.com/config-checksum"
+APPLIED_CHECKSUM_ANNOTATION="vpn.vpn-devops.com/applied-config-checksum"
+MTU_PROBES_ANNOTATION="vpn.vpn-devops.com/mtu-probes"
+MTU_PROBE_RESULTS_ANNOTATION="vpn.vpn-devops.com/mtu-probe-results"
+PEER_STATS_ANNOTATION="vpn.vpn-devops.com/peer-stats"
+CONFIG_DRIFT_ANNOTATION="vpn.vpn-devops.com/config-drift"
+APPLIED_PERFORMANCE_ANNOTATION="vpn.vpn-devops.com/applied-performance"
+REVOKED_PEERS_ANNOTATION="vpn.vpn-devops.com/revoked-peers"
+DATA_PLANE_ANNOTATION="vpn.vpn-devops.com/data-plane"
+APPLIED_BANDWIDTH_ANNOTATION="vpn.vpn-devops.com/applied-bandwidth"
+HA_ROLE_LABEL="vpn.vpn-devops.com/ha-role"
+WG_STATS_INTERVAL=${WG_STATS_INTERVAL:-10}
+WG_STATS_MAX_INTERVAL=${WG_STATS_MAX_INTERVAL:-300}
+WG_STATS_BYTES_THRESHOLD=${WG_STATS_BYTES_THRESHOLD:-67108864}
+WG_STATS_HANDSHAKE_FRESHNESS=${WG_STATS_HANDSHAKE_FRESHNESS:-180}
+WG_DRIFT_INTERVAL=${WG_DRIFT_INTERVAL:-300}
+
+# DaemonSet replicas listen on the port of their node when it has one, e.g.
+# "node-a=51821,node-b=51822"
+if [ -n "${WG_NODE_PORTS:-}" ] && [ -n "${WG_NODE_NAME:-}" ]; then
+    for entry in ${WG_NODE_PORTS//,/ }; do
+        if [ "${entry%%=*}" = "$WG_NODE_NAME" ]; then
+            WG_PORT=${entry#*=}
+        fi
+    done
+fi
+# StatefulSet replicas listen on the port of the range at their ordinal
+if [ -n "${WG_PORT_RANGE:-}" ] && [ -n "${WG_POD_NAME:-}" ]; then
+    ordinal=${WG_POD_NAME##*-}
+    if ! [[ "$ordinal" =~ ^[0-9]+$ ]] || [ $((${WG_PORT_RANGE%-*} + ordinal)) -gt "${WG_PORT_RANGE#*-}" ]; then
+        echo "Pod $WG_POD_NAME has no port in the listen port range $WG_PORT_RANGE" >&2
+        exit 1
+    fi
+    WG_PORT=$((${WG_PORT_RANGE%-*} + ordinal))
+fi
+# The firewall redirects the additional listen ports to our port
+export WG_PORT
+# Replicas of sharded servers serve the peers assigned to their ordinal
+SHARD_ORDINAL=""
+if [ "${WG_SHARDING:-false}" = "true" ] && [ -n "${WG_POD_NAME:-}" ]; then
+    SHARD_ORDINAL=${WG_POD_NAME##*-}
+    echo "Shard: $SHARD_ORDINAL"
+fi
+
+# Interface settings of spec.interfaceMTU and spec.fwmark
+INTERFACE_OPTIONS=""
+if [ -n "${WG_MTU:-}" ]; then
+    INTERFACE_OPTIONS+="MTU = $WG_MTU"$'\n'
+fi
+if [ -n "${WG_FWMARK:-}" ]; then
+    INTERFACE_OPTIONS+="FwMark = $WG_FWMARK"$'\n'
+fi
+
+# On SIGTERM the agent stops between device operations, so that an apply in
+# progress, such as a config reload, completes rather than leaving a
+# half-applied peer set. The pod's termination grace period bounds the wait.
+STOPPING=false
+trap 'STOPPING=true' TERM INT
 
 echo "Starting WireGuard VPN Server..."
 echo "Host: $WG_HOST"
 echo "Port: $WG_PORT"
 echo "Interface: $WG_INTERFACE"
+echo "Implementation: $WG_IMPLEMENTATION"
+
+# Sandboxed runtimes such as gVisor have no WireGuard kernel module; wg-quick
+# then falls back to the userspace implementation on a TUN device
+if [ "$WG_IMPLEMENTATION" = "userspace" ]; then
+    if [ ! -c /dev/net/tun ]; then
+        echo "Userspace WireGuard requires /dev/net/tun" >&2
+        exit 1
+    fi
+    export WG_QUICK_USERSPACE_IMPLEMENTATION=wireguard-go
+fi
 
 # Generate server keys if they don't exist
 if [ ! -f /etc/wireguard/keys/server_private ]; then
@@ -24,7 +107,12 @@ fi
 
 # Read server keys
 SERVER_PRIVATE_KEY=$(cat /etc/wireguard/keys/server_private)
-SERVER_PUBLIC_KEY=$(cat /etc/wireguard/keys/server_public)
+# Keys projected from a Secret may come without the public key
+if [ -f /etc/wireguard/keys/server_public ]; then
+    SERVER_PUBLIC_KEY=$(cat /etc/wireguard/keys/server_public)
+else
+    SERVER_PUBLIC_KEY=$(echo "$SERVER_PRIVATE_KEY" | wg pubkey)
+fi
 
 echo "Server Public Key: $SERVER_PUBLIC_KEY"
 
@@ -34,32 +122,761 @@ cat > /etc/wireguard/$WG_INTERFACE.conf << EOF
 PrivateKey = $SERVER_PRIVATE_KEY
 Address = $WG_DEFAULT_ADDRESS/24
 ListenPort = $WG_PORT
-PostUp = iptables -A FORWARD -i %i -j ACCEPT; iptables -A FORWARD -o %i -j ACCEPT; iptables -t nat -A POSTROUTING -o eth0 -j MASQUERADE
-PostDown = iptables -D FORWARD -i %i -j ACCEPT; iptables -D FORWARD -o %i -j ACCEPT; iptables -t nat -D POSTROUTING -o eth0 -j MASQUERADE
+${INTERFACE_OPTIONS}PostUp = /scripts/firewall.sh up %i
+PostDown = /scripts/firewall.sh down %i
 
 # Client configurations will be added here dynamically
 EOF
 
+# Forward the logs of the WireGuard device to the container log when
+# WG_DEVICE_LOG is true, so that kubectl wireflow logs can show them.
+# wireguard-go logs to our output at the verbose level; the kernel module
+# logs to the kernel log through dynamic debug, which needs debugfs and
+# /dev/kmsg and is enabled for every WireGuard interface of the node.
+if [ "${WG_DEVICE_LOG:-false}" = "true" ] && [ "$WG_IMPLEMENTATION" = "userspace" ]; then
+    export LOG_LEVEL=verbose
+fi
+forward_kernel_log() {
+    [ "${WG_DEVICE_LOG:-false}" = "true" ] && [ "$WG_IMPLEMENTATION" != "userspace" ] || return 0
+    if [ ! -r /dev/kmsg ] || ! echo "module wireguard +p" > /sys/kernel/debug/dynamic_debug/control 2>/dev/null; then
+        echo "WireGuard device logs need debugfs and /dev/kmsg, which are not available" >&2
+        return 0
+    fi
+    # Records are "priority,sequence,timestamp,flags;message"
+    while IFS= read -r record; do
+        case ${record#*;} in
+        wireguard:*) echo "${record#*;}" ;;
+        esac
+    done < /dev/kmsg &
+}
+
 # Set up IP forwarding
 echo 'net.ipv4.ip_forward = 1' >> /etc/sysctl.conf
 sysctl -p
 
-# Start WireGuard
+# Start WireGuard. Device operations go through wg-op.sh, which serializes
+# them per interface and retries transient netlink errors.
 echo "Starting WireGuard interface..."
-wg-quick up $WG_INTERFACE
+/scripts/wg-op.sh up $WG_INTERFACE wg-quick up $WG_INTERFACE
+forward_kernel_log
+
+# Record session metadata when compliance requires it
+if [ "${WG_SESSION_METADATA:-disabled}" != "disabled" ]; then
+    /scripts/session-recorder.sh $WG_INTERFACE &
+fi
 
 # Keep the container running and show status
 echo "WireGuard is running!"
 echo "Interface status:"
 wg show
 
-# Monitor WireGuard status
-while true; do
-    sleep 30
-    if ! wg show $WG_INTERFACE > /dev/null 2>&1; then
-        echo "WireGuard interface is down, restarting..."
-        wg-quick down $WG_INTERFACE || true
-        wg-quick up $WG_INTERFACE
+# Agent metrics, in the Prometheus text format, served on /metrics
+INTERFACE_RECREATIONS=0
+write_metrics() {
+    mkdir -p "$METRICS_DIR"
+    cat > "$METRICS_DIR/metrics.tmp" << METRICS
+# HELP wireflow_interface_recreations_total Times the agent recreated the WireGuard interface after it disappeared.
+# TYPE wireflow_interface_recreations_total counter
+wireflow_interface_recreations_total{interface="$WG_INTERFACE"} $INTERFACE_RECREATIONS
+# HELP wireflow_netlink_operations_total Attempts of WireGuard device operations.
+# TYPE wireflow_netlink_operations_total counter
+# HELP wireflow_netlink_errors_total Failed attempts of WireGuard device operations, by error.
+# TYPE wireflow_netlink_errors_total counter
+METRICS
+    local file iface operation series error
+    for file in "$WG_STATE_DIR"/netlink/*; do
+        [ -f "$file" ] || continue
+        IFS=: read -r iface operation series error <<< "$(basename "$file")"
+        if [ "$series" = errors ]; then
+            echo "wireflow_netlink_errors_total{interface=\"$iface\",operation=\"$operation\",error=\"$error\"} $(cat "$file")"
+        else
+            echo "wireflow_netlink_operations_total{interface=\"$iface\",operation=\"$operation\"} $(cat "$file")"
+        fi
+    done >> "$METRICS_DIR/metrics.tmp"
+    mv "$METRICS_DIR/metrics.tmp" "$METRICS_DIR/metrics"
+}
+write_metrics
+if [ -n "$WG_METRICS_PORT" ]; then
+    busybox-extras httpd -f -p "$WG_METRICS_PORT" -h "$METRICS_DIR" &
+fi
+
+# Desired config checksum published by the operator through the downward API
+desired_checksum() {
+    [ -f "$PODINFO_ANNOTATIONS" ] || return 0
+    sed -n "s|^$CONFIG_CHECKSUM_ANNOTATION=\"\(.*\)\"\$|\1|p" "$PODINFO_ANNOTATIONS"
+}
+
+# Public keys of the revoked and deleted peers published by the operator
+# through the downward API, one per line
+revoked_peers() {
+    [ -f "$PODINFO_ANNOTATIONS" ] || return 0
+    sed -n "s|^$REVOKED_PEERS_ANNOTATION=\"\(.*\)\"\$|\1|p" "$PODINFO_ANNOTATIONS" | tr ',' '\n' | sed 's|@.*||'
+}
+
+# Call the API of the cluster with the service account of the pod, on a
+# path under the namespace of the pod
+SERVICE_ACCOUNT=/var/run/secrets/kubernetes.io/serviceaccount
+kube_api() {
+    local method=$1 path=$2 content_type=$3 body=$4
+    [ -f "$SERVICE_ACCOUNT/token" ] || return 0
+    curl -sf -X "$method" \
+        --cacert "$SERVICE_ACCOUNT/ca.crt" \
+        -H "Authorization: Bearer $(cat $SERVICE_ACCOUNT/token)" \
+        -H "Content-Type: $content_type" \
+        -d "$body" \
+        "https://kubernetes.default.svc/api/v1/namespaces/$(cat $SERVICE_ACCOUNT/namespace)/$path" > /dev/null
+}
+
+# Report the applied checksum on our own pod so the operator can surface it
+report_applied_checksum() {
+    kube_api PATCH "pods/$HOSTNAME" application/merge-patch+json \
+        "{\"metadata\":{\"annotations\":{\"$APPLIED_CHECKSUM_ANNOTATION\":\"$1\"}}}"
+}
+
+# Record an event on our own pod
+emit_event() {
+    local type=$1 reason=$2 message=$3 now namespace
+    [ -f "$SERVICE_ACCOUNT/namespace" ] || return 0
+    now=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+    namespace=$(cat $SERVICE_ACCOUNT/namespace)
+    kube_api POST events application/json "$(jq -n \
+        --arg pod "$HOSTNAME" --arg namespace "$namespace" --arg now "$now" \
+        --arg type "$type" --arg reason "$reason" --arg message "$message" \
+        '{metadata: {generateName: ($pod + "."), namespace: $namespace},
+          involvedObject: {apiVersion: "v1", kind: "Pod", name: $pod, namespace: $namespace},
+          type: $type, reason: $reason, message: $message, count: 1,
+          firstTimestamp: $now, lastTimestamp: $now, source: {component: "wireflow-agent"}}')"
+}
+
+# Public keys of the peers the operator assigned to other replicas of a
+# sharded server
+other_shard_peers() {
+    [ -n "$SHARD_ORDINAL" ] && [ -f "$WG_SHARDS_CONFIG" ] || return 0
+    awk -v ordinal="$SHARD_ORDINAL" '$1 != ordinal { print $2 }' "$WG_SHARDS_CONFIG"
+}
+
+# strip_config prints the mounted config for wg, with our listen port in
+# place of the port of the server where they differ, and our firewall mark.
+# Revoked peers are left out, should the mounted config still list them, and
+# so are the peers of other shards.
+strip_config() {
+    wg-quick strip "$WG_RENDERED_CONFIG" | sed \
+        -e "s/^ListenPort[ \t]*=.*/ListenPort = $WG_PORT/" \
+        -e "${WG_FWMARK:+/^\[Interface\]/a FwMark = $WG_FWMARK}" \
+        | awk -v revoked="$({ revoked_peers; other_shard_peers; } | tr '\n' ' ')" '
+            BEGIN { n = split(revoked, keys, " "); for (i = 1; i <= n; i++) drop[keys[i]] = 1 }
+            function flush() { if (block != "" && !skip) printf "%s", block; block = ""; skip = 0 }
+            /^\[/ { flush() }
+            /^PublicKey[ \t]*=/ { key = $0; sub(/^PublicKey[ \t]*=[ \t]*/, "", key); if (key in drop) skip = 1 }
+            { block = block $0 "\n" }
+            END { flush() }'
+}
+
+# Checksum of the mounted rendered files as the operator computes it: each
+# file listed with its checksum in the order of their names, hashed
+rendered_checksum() {
+    (cd "$(dirname "$WG_RENDERED_CONFIG")" && LC_ALL=C sha256sum $(LC_ALL=C ls)) | sha256sum | cut -d' ' -f1
+}
+
+# Hot-reload the device once the mounted files match the desired checksum,
+# and apply the files rendered with the config before reporting it applied
+APPLIED_CHECKSUM=""
+reload_config() {
+    local desired
+    desired=$(desired_checksum)
+    [ -n "$desired" ] && [ "$desired" != "$APPLIED_CHECKSUM" ] || return 0
+    [ -f "$WG_RENDERED_CONFIG" ] || return 0
+    # The kubelet may not have synced the mounted files yet
+    [ "$(rendered_checksum)" = "$desired" ] || return 0
+
+    echo "Reloading WireGuard configuration ($desired)..."
+    # Retried attempts read the stripped config again, it cannot be a pipe
+    (umask 077 && strip_config > "$WG_STATE_DIR/$WG_INTERFACE.stripped")
+    if /scripts/wg-op.sh syncconf $WG_INTERFACE wg syncconf $WG_INTERFACE "$WG_STATE_DIR/$WG_INTERFACE.stripped"; then
+        sync_shards
+        sync_isolation
+        sync_ingress
+        sync_firewall
+        sync_bandwidth
+        sync_split_dns
+        APPLIED_CHECKSUM=$desired
+        report_applied_checksum "$desired" || echo "Failed to report applied config checksum"
+    fi
+}
+
+# Reload the device when the shard assignments change, e.g. after the server
+# was scaled, which moves peers between replicas without changing the config
+APPLIED_SHARDS=""
+sync_shards() {
+    [ -n "$SHARD_ORDINAL" ] || return 0
+    local current=""
+    [ -f "$WG_SHARDS_CONFIG" ] && current=$(sha256sum "$WG_SHARDS_CONFIG" | cut -d' ' -f1)
+    [ "$current" != "$APPLIED_SHARDS" ] || return 0
+    APPLIED_SHARDS=$current
+    APPLIED_CHECKSUM=""
+}
+
+# Call the Lease API of the cluster on the lease of the server
+ha_lease_api() {
+    local method=$1 body=$2
+    curl -sf -X "$method" \
+        --cacert "$SERVICE_ACCOUNT/ca.crt" \
+        -H "Authorization: Bearer $(cat $SERVICE_ACCOUNT/token)" \
+        ${body:+-H "Content-Type: application/json" -d "$body"} \
+        "https://kubernetes.default.svc/apis/coordination.k8s.io/v1/namespaces/$(cat $SERVICE_ACCOUNT/namespace)/leases/$WG_HA_LEASE"
+}
+
+# Acquire the lease of the server when it is free or expired, or renew it
+# when we hold it. Writes carry the resourceVersion that was read, so that
+# only one of concurrent standbys takes over an expired lease.
+acquire_lease() {
+    local lease now
+    lease=$(ha_lease_api GET) || return 1
+    now=$(date -u +%Y-%m-%dT%H:%M:%S.000000Z)
+    lease=$(jq -c --arg me "$HOSTNAME" --arg now "$now" --argjson duration "$WG_HA_LEASE_DURATION" '
+        (.spec.holderIdentity // "") as $holder
+        | ((.spec.renewTime // "1970-01-01T00:00:00.000000Z") | sub("\\.[0-9]+Z$"; "Z") | fromdateiso8601) as $renewed
+        | if $holder == $me or $holder == "" or now - $renewed >= (.spec.leaseDurationSeconds // $duration) then
+            .spec.holderIdentity = $me | .spec.renewTime = $now
+            | if $holder == $me then . else
+                .spec.acquireTime = $now | .spec.leaseTransitions = ((.spec.leaseTransitions // 0) + 1) end
+          else empty end' <<< "$lease")
+    [ -n "$lease" ] || return 1
+    ha_lease_api PUT "$lease" > /dev/null
+}
+
+# Label our pod with its role, which the Service of the server selects the
+# active replica on. The operator labels the replicas after the lease too.
+set_ha_role() {
+    kube_api PATCH "pods/$HOSTNAME" application/merge-patch+json \
+        "{\"metadata\":{\"labels\":{\"$HA_ROLE_LABEL\":\"$1\"}}}" || echo "Failed to label the pod $1"
+}
+
+# Elect the active replica of a server in the high availability mode. The
+# standbys keep the peers configured, and the replica taking over re-applies
+# the peer state it may have missed. A failed renewal keeps the active role
+# until the lease would expire, so that API hiccups do not drop the tunnels.
+HA_ROLE=""
+LEASE_RENEWED=0
+sync_ha() {
+    [ -n "${WG_HA_LEASE:-}" ] || return 0
+    if acquire_lease; then
+        LEASE_RENEWED=$(date +%s)
+        [ "$HA_ROLE" != active ] || return 0
+        HA_ROLE=active
+        echo "Acquired lease $WG_HA_LEASE, terminating the tunnels"
+        set_ha_role active
+        APPLIED_CHECKSUM=""
+        APPLIED_ISOLATION=""
+        APPLIED_INGRESS=""
+        APPLIED_FIREWALL=""
+        reload_config
+        sync_isolation
+        sync_ingress
+        sync_firewall
+        emit_event Normal BecameActive "Acquired lease $WG_HA_LEASE and terminates the tunnels" ||
+            echo "Failed to record the takeover event"
+        return 0
+    fi
+    if [ "$HA_ROLE" = active ] && [ $(($(date +%s) - LEASE_RENEWED)) -lt "$WG_HA_LEASE_DURATION" ]; then
+        return 0
+    fi
+    [ "$HA_ROLE" != standby ] || return 0
+    if [ "$HA_ROLE" = active ]; then
+        emit_event Warning LostLease "Unable to renew lease $WG_HA_LEASE, standing by" || true
+    fi
+    HA_ROLE=standby
+    echo "Lease $WG_HA_LEASE is held by another replica, standing by"
+    set_ha_role standby
+}
+
+# Release the lease when stopping, so that a standby takes over at once
+# rather than after the lease expires
+release_lease() {
+    [ -n "${WG_HA_LEASE:-}" ] && [ "$HA_ROLE" = active ] || return 0
+    local lease
+    lease=$(ha_lease_api GET) || return 0
+    lease=$(jq -c --arg me "$HOSTNAME" 'select(.spec.holderIdentity == $me) | .spec.holderIdentity = null' <<< "$lease")
+    [ -n "$lease" ] || return 0
+    ha_lease_api PUT "$lease" > /dev/null && echo "Released lease $WG_HA_LEASE"
+}
+
+# Remove the revoked and deleted peers from the live interface as soon as
+# the operator publishes them, without waiting for a new config
+sync_revocations() {
+    local live key
+    live=$(/scripts/wg-op.sh peers $WG_INTERFACE wg show $WG_INTERFACE peers) || return 0
+    for key in $(revoked_peers); do
+        grep -qxF "$key" <<< "$live" || continue
+        echo "Removing revoked peer $key..."
+        if /scripts/wg-op.sh revoke $WG_INTERFACE wg set $WG_INTERFACE peer "$key" remove; then
+            emit_event Normal PeerRevoked "Removed revoked peer $key from $WG_INTERFACE" ||
+                echo "Failed to record the revocation event" >&2
+        else
+            emit_event Warning PeerRevocationFailed "Unable to remove revoked peer $key from $WG_INTERFACE" ||
+                echo "Failed to record the revocation failure" >&2
+        fi
+    done
+}
+
+# Re-apply the isolation exceptions when the operator renders new ones. They
+# change with group membership, independently of the WireGuard config.
+APPLIED_ISOLATION=""
+sync_isolation() {
+    [ "${WG_CLIENT_ISOLATION:-false}" = "true" ] || return 0
+    local current=""
+    [ -f "$WG_ISOLATION_CONFIG" ] && current=$(sha256sum "$WG_ISOLATION_CONFIG" | cut -d' ' -f1)
+    [ "$current" != "$APPLIED_ISOLATION" ] || return 0
+    if /scripts/firewall.sh isolate $WG_INTERFACE; then
+        APPLIED_ISOLATION=$current
+    fi
+}
+
+# Re-apply the ingress maps when the operator renders new ones
+APPLIED_INGRESS=""
+sync_ingress() {
+    local current=""
+    [ -f "$WG_INGRESS_CONFIG" ] && current=$(sha256sum "$WG_INGRESS_CONFIG" | cut -d' ' -f1)
+    [ "$current" != "$APPLIED_INGRESS" ] || return 0
+    if /scripts/firewall.sh ingress $WG_INTERFACE; then
+        APPLIED_INGRESS=$current
+    fi
+}
+
+# Re-apply the firewall rules when the operator renders new ones. A failure
+# is recorded once per rendering, and retried on every monitor interval.
+APPLIED_FIREWALL=""
+FAILED_FIREWALL=""
+sync_firewall() {
+    local current=""
+    [ -f "$WG_FIREWALL_CONFIG" ] && current=$(sha256sum "$WG_FIREWALL_CONFIG" | cut -d' ' -f1)
+    [ "$current" != "$APPLIED_FIREWALL" ] || return 0
+    if /scripts/firewall.sh filter $WG_INTERFACE; then
+        APPLIED_FIREWALL=$current
+        FAILED_FIREWALL=""
+    elif [ "$current" != "$FAILED_FIREWALL" ]; then
+        FAILED_FIREWALL=$current
+        emit_event Warning FirewallRulesFailed "Unable to program the firewall rules of $WG_INTERFACE" ||
+            echo "Failed to record the firewall rules failure"
+    fi
+}
+
+# Re-apply the bandwidth limits when the operator renders new ones and
+# report the limits in effect on our own pod, which the operator reports in
+# the shaping status of the peers. The shaping goes with the interface, so
+# it is applied again when the interface is recreated.
+APPLIED_BANDWIDTH=""
+BANDWIDTH_REPORTED=true
+sync_bandwidth() {
+    local current="" report
+    [ -s "$WG_BANDWIDTH_CONFIG" ] && current=$(sha256sum "$WG_BANDWIDTH_CONFIG" | cut -d' ' -f1)
+    if [ "$current" != "$APPLIED_BANDWIDTH" ]; then
+        if ! /scripts/shape.sh apply $WG_INTERFACE; then
+            echo "Failed to apply the bandwidth limits"
+            return 0
+        fi
+        APPLIED_BANDWIDTH=$current
+        BANDWIDTH_REPORTED=false
+    fi
+    [ "$BANDWIDTH_REPORTED" = false ] || return 0
+    report=$(jq -c --argjson now "$(date +%s)" '{reportedAt: $now, peers: .}' "$WG_STATE_DIR/bandwidth-results") || return 0
+    if kube_api PATCH "pods/$HOSTNAME" application/merge-patch+json "$(jq -n \
+        --arg key "$APPLIED_BANDWIDTH_ANNOTATION" --arg value "$report" \
+        '{metadata: {annotations: {($key): $value}}}')"; then
+        BANDWIDTH_REPORTED=true
+        echo "Bandwidth limits: $(jq -r '[.peers[] | select(.error == null)] | length' <<< "$report") peers shaped"
+    else
+        echo "Failed to report the bandwidth limits"
+    fi
+}
+
+# Run the split-DNS forwarder on the tunnel interface while the operator
+# renders a config for it, restarting it when the config changes or it exits
+APPLIED_SPLIT_DNS=""
+SPLIT_DNS_PID=""
+sync_split_dns() {
+    local current=""
+    [ -s "$WG_SPLIT_DNS_CONFIG" ] && current=$(sha256sum "$WG_SPLIT_DNS_CONFIG" | cut -d' ' -f1)
+    if [ -n "$SPLIT_DNS_PID" ] && ! kill -0 "$SPLIT_DNS_PID" 2>/dev/null; then
+        echo "Split-DNS forwarder exited"
+        SPLIT_DNS_PID=""
+        APPLIED_SPLIT_DNS=""
+    fi
+    [ "$current" != "$APPLIED_SPLIT_DNS" ] || return 0
+    stop_split_dns
+    APPLIED_SPLIT_DNS=$current
+    [ -n "$current" ] || return 0
+    echo "Starting the split-DNS forwarder on $WG_INTERFACE"
+    dnsmasq --keep-in-foreground --conf-file="$WG_SPLIT_DNS_CONFIG" --no-hosts \
+        --interface="$WG_INTERFACE" --except-interface=lo --bind-dynamic &
+    SPLIT_DNS_PID=$!
+}
+
+stop_split_dns() {
+    [ -n "$SPLIT_DNS_PID" ] || return 0
+    kill "$SPLIT_DNS_PID" 2>/dev/null || true
+    wait "$SPLIT_DNS_PID" 2>/dev/null || true
+    SPLIT_DNS_PID=""
+}
+
+# Switch the interface to the server key in the keys volume when the
+# operator rotates it, without dropping the peers
+SERVER_KEY_FILE=/etc/wireguard/keys/server_private
+APPLIED_KEY=$(sha256sum "$SERVER_KEY_FILE" | cut -d' ' -f1)
+sync_server_key() {
+    local current
+    [ -s "$SERVER_KEY_FILE" ] || return 0
+    current=$(sha256sum "$SERVER_KEY_FILE" | cut -d' ' -f1)
+    [ "$current" != "$APPLIED_KEY" ] || return 0
+    if /scripts/wg-op.sh setkey $WG_INTERFACE wg set $WG_INTERFACE private-key "$SERVER_KEY_FILE"; then
+        APPLIED_KEY=$current
+        SERVER_PRIVATE_KEY=$(cat "$SERVER_KEY_FILE")
+        SERVER_PUBLIC_KEY=$(echo "$SERVER_PRIVATE_KEY" | wg pubkey)
+        # Recreations of the interface start from the new key
+        sed -i "s|^PrivateKey = .*|PrivateKey = $SERVER_PRIVATE_KEY|" /etc/wireguard/$WG_INTERFACE.conf
+        echo "Switched to server key $SERVER_PUBLIC_KEY"
+        emit_event Normal ServerKeySwitched "Switched $WG_INTERFACE to server key $SERVER_PUBLIC_KEY" ||
+            echo "Failed to record server key switch event"
+    fi
+}
+
+# Largest packet that reaches a host with fragmentation prohibited, 0 if the
+# host does not answer
+path_mtu() {
+    local host=$1 header=28 low=576 high dev mid
+    case $host in *:*) header=48; low=1280 ;; esac
+    dev=$(ip route get "$host" 2>/dev/null | sed -n 's/.* dev \([^ ]*\).*/\1/p')
+    high=$(cat "/sys/class/net/$dev/mtu" 2>/dev/null || echo 1500)
+    probe() { ping -c 1 -W 1 -M do -s $(($1 - header)) "$host" > /dev/null 2>&1; }
+    if ! probe $low; then
+        echo 0
+        return
+    fi
+    if probe $high; then
+        echo $high
+        return
+    fi
+    while [ $((high - low)) -gt 1 ]; do
+        mid=$(((low + high) / 2))
+        if probe $mid; then low=$mid; else high=$mid; fi
+    done
+    echo $low
+}
+
+# Run the path MTU probes the operator requests through the downward API and
+# report the results on our own pod. Each request is probed once; results of
+# requests the operator no longer lists are dropped.
+MTU_PROBE_RESULTS=$WG_STATE_DIR/mtu-probe-results
+sync_mtu_probes() {
+    local requests entry peer request host previous results='{}'
+    [ -f "$PODINFO_ANNOTATIONS" ] || return 0
+    requests=$(sed -n "s|^$MTU_PROBES_ANNOTATION=\"\(.*\)\"\$|\1|p" "$PODINFO_ANNOTATIONS")
+    previous=$(cat "$MTU_PROBE_RESULTS" 2>/dev/null || echo '{}')
+    for entry in $requests; do
+        IFS='|' read -r peer request host <<< "$entry"
+        if [ "$(jq -r --arg peer "$peer" '.[$peer].request // ""' <<< "$previous")" = "$request" ]; then
+            results=$(jq -c --arg peer "$peer" --argjson prior "$previous" '.[$peer] = $prior[$peer]' <<< "$results")
+            continue
+        fi
+        echo "Probing path MTU to peer $peer ($host)..."
+        results=$(jq -c --arg peer "$peer" --arg request "$request" --argjson mtu "$(path_mtu "$host")" \
+            '.[$peer] = {request: $request, pathMTU: $mtu}' <<< "$results")
+    done
+    [ "$results" != "$previous" ] || return 0
+    if kube_api PATCH "pods/$HOSTNAME" application/merge-patch+json "$(jq -n \
+        --arg key "$MTU_PROBE_RESULTS_ANNOTATION" --arg value "$results" \
+        '{metadata: {annotations: {($key): $value}}}')"; then
+        mkdir -p "$WG_STATE_DIR"
+        echo "$results" > "$MTU_PROBE_RESULTS"
+    else
+        echo "Failed to report path MTU probe results"
+    fi
+}
+
+# Sample the peers of the device and push a summary on our own pod, which
+# the operator aggregates into the server status. A summary is only pushed
+# when a peer connects, goes stale or roams, when the traffic grew by the
+# bytes threshold, or at the max interval, so that large fleets do not
+# write the pod on every sample. Peers that never handshook are left out.
+# Entries are [endpoint, latest handshake, received bytes, sent bytes,
+# round-trip time in milliseconds], the round-trip time null if unknown.
+PEER_STATS=$WG_STATE_DIR/peer-stats
+PEER_STATS_PUSHED_AT=0
+
+# Estimate the round-trip times of the connected peers keeping the tunnel
+# alive, the links of networks and sites rather than clients, with a ping
+# of their tunnel address. Prints the public key and milliseconds of each.
+peer_round_trips() {
+    local dump=$1 now=$2 key ips handshake keepalive address rtt
+    tail -n +2 <<< "$dump" | while IFS=$'\t' read -r key _ _ ips handshake _ _ keepalive; do
+        [ "$keepalive" != off ] && [ $((now - handshake)) -le "$WG_STATS_HANDSHAKE_FRESHNESS" ] || continue
+        address=${ips%%,*}
+        [ "${address%/32}" != "$address" ] || continue
+        rtt=$(ping -c 1 -W 1 "${address%/32}" 2>/dev/null | sed -n 's/.*time=\([0-9.]*\) ms.*/\1/p')
+        [ -z "$rtt" ] || echo "$key $rtt"
+    done
+}
+
+sync_peer_stats() {
+    local dump now summary previous significant
+    dump=$(/scripts/wg-op.sh dump $WG_INTERFACE wg show $WG_INTERFACE dump) || return 0
+    now=$(date +%s)
+    summary=$(tail -n +2 <<< "$dump" | jq -R -s -c --argjson now "$now" '
+        [split("\n")[] | select(. != "") | split("\t") | select(.[4] != "0")
+         | {key: .[0], value: [(if .[2] == "(none)" then "" else .[2] end),
+                               (.[4] | tonumber), (.[5] | tonumber), (.[6] | tonumber)]}]
+        | {sampledAt: $now, peers: from_entries}')
+    previous=$(cat "$PEER_STATS" 2>/dev/null || echo '{"peers": {}}')
+    significant=$(jq -n --argjson a "$previous" --argjson b "$summary" --argjson now "$now" \
+        --argjson fresh "$WG_STATS_HANDSHAKE_FRESHNESS" --argjson bytes "$WG_STATS_BYTES_THRESHOLD" '
+        def connected(s): [s.peers | to_entries[] | select($now - .value[1] <= $fresh) | .key] | sort;
+        def endpoints(s): s.peers | map_values(.[0]);
+        def traffic(s): [s.peers[] | .[2] + .[3]] | add // 0;
+        connected($a) != connected($b) or endpoints($a) != endpoints($b)
+            or (traffic($b) - traffic($a) | fabs) >= $bytes')
+    if [ "$significant" != true ]; then
+        [ $((now - PEER_STATS_PUSHED_AT)) -ge "$WG_STATS_MAX_INTERVAL" ] || return 0
+        [ "$(jq -c '.peers | map_values(.[:4])' <<< "$previous")" != "$(jq -c .peers <<< "$summary")" ] || return 0
+    fi
+    # Only measured for the summaries pushed, a ping per peer
+    summary=$(jq -c --arg rtts "$(peer_round_trips "$dump" "$now")" '
+        ($rtts | split("\n") | map(select(. != "") | split(" ") | {key: .[0], value: (.[1] | tonumber)}) | from_entries) as $rtt
+        | .peers |= with_entries(.value += [$rtt[.key]])' <<< "$summary")
+    if kube_api PATCH "pods/$HOSTNAME" application/merge-patch+json "$(jq -n \
+        --arg key "$PEER_STATS_ANNOTATION" --arg value "$summary" \
+        '{metadata: {annotations: {($key): $value}}}')"; then
+        mkdir -p "$WG_STATE_DIR"
+        echo "$summary" > "$PEER_STATS"
+        PEER_STATS_PUSHED_AT=$now
+    else
+        echo "Failed to report peer stats"
+    fi
+}
+
+# Compare the live device with the desired configuration and push the
+# differences on our own pod, which the operator reports as config drift.
+# This catches peers changed out of band as well as reloads that failed.
+# The report is pushed when it changes and refreshed every drift interval,
+# so that its check time proves the device was compared recently.
+# Changed peers map to the settings that differ.
+CONFIG_DRIFT=$WG_STATE_DIR/config-drift
+CONFIG_DRIFT_PUSHED_AT=0
+check_drift() {
+    local desired wanted dump now report previous
+    desired=$(desired_checksum)
+    [ -n "$desired" ] && [ -f "$WG_RENDERED_CONFIG" ] || return 0
+    # Only compare against the desired config, not one the kubelet is replacing
+    [ "$(rendered_checksum)" = "$desired" ] || return 0
+    wanted=$(strip_config | awk '
+        function flush() { if (key != "") print key "\t" psk "\t" ips "\t" keepalive; key = "" }
+        { name = $0; sub(/[ \t]*=.*/, "", name); value = $0; sub(/^[^=]*=/, "", value); gsub(/[ \t]/, "", value) }
+        /^\[/ { flush(); peer = ($0 ~ /^\[Peer\]/); psk = "(none)"; ips = ""; keepalive = "off"; next }
+        !peer && name == "ListenPort" { print "listen-port\t" value }
+        peer && name == "PublicKey" { key = value }
+        peer && name == "PresharedKey" { psk = value }
+        peer && name == "AllowedIPs" { ips = (ips == "" ? value : ips "," value) }
+        peer && name == "PersistentKeepalive" { keepalive = (value == "0" ? "off" : value) }
+        END { flush() }') || return 0
+    dump=$(/scripts/wg-op.sh dump $WG_INTERFACE wg show $WG_INTERFACE dump) || return 0
+    now=$(date +%s)
+    report=$(jq -n -c --arg wanted "$wanted" --arg dump "$dump" --arg checksum "$desired" --argjson now "$now" '
+        def rows(s): s | split("\n") | map(select(. != "") | split("\t"));
+        def ips(s): s | split(",") | map(select(. != "" and . != "(none)")) | sort;
+        rows($wanted) as $w | rows($dump) as $d
+        | ($w | map(select(length == 4) | {key: .[0], value: {psk: .[1], ips: ips(.[2]), keepalive: .[3]}}) | from_entries) as $want
+        | ($d[1:] | map({key: .[0], value: {psk: .[1], ips: ips(.[3]), keepalive: .[7]}}) | from_entries) as $live
+        | {checkedAt: $now, checksum: $checksum,
+           missing: [$want | keys[] | select($live[.] == null)],
+           unexpected: [$live | keys[] | select($want[.] == null)],
+           changed: ([$want | to_entries[] | select($live[.key] != null) | .key as $key | .value as $v
+                      | {key: $key, value: [
+                          (if $v.ips != $live[$key].ips then "allowed-ips" else empty end),
+                          (if $v.psk != $live[$key].psk then "preshared-key" else empty end),
+                          (if $v.keepalive != $live[$key].keepalive then "persistent-keepalive" else empty end)]}
+                      | select(.value != [])] | from_entries),
+           interface: [$w[] | select(.[0] == "listen-port" and .[1] != $d[0][2]) | .[0]]}') || return 0
+    previous=$(cat "$CONFIG_DRIFT" 2>/dev/null || echo '{}')
+    if [ "$(jq -c 'del(.checkedAt)' <<< "$previous")" = "$(jq -c 'del(.checkedAt)' <<< "$report")" ]; then
+        [ $((now - CONFIG_DRIFT_PUSHED_AT)) -ge "$WG_DRIFT_INTERVAL" ] || return 0
+    fi
+    if kube_api PATCH "pods/$HOSTNAME" application/merge-patch+json "$(jq -n \
+        --arg key "$CONFIG_DRIFT_ANNOTATION" --arg value "$report" \
+        '{metadata: {annotations: {($key): $value}}}')"; then
+        mkdir -p "$WG_STATE_DIR"
+        echo "$report" > "$CONFIG_DRIFT"
+        CONFIG_DRIFT_PUSHED_AT=$now
+    else
+        echo "Failed to report config drift"
+    fi
+}
+
+# Apply the data plane tuning of spec.performance: the socket buffer
+# sysctls, the transmit queue of the interface and the RPS/XPS masks of the
+# queues of the uplink. Sysctls the kernel does not namespace and queues
+# the uplink lacks keep their defaults, so the values are read back and
+# reported on our own pod rather than assumed.
+PERFORMANCE_ERRORS=()
+PERFORMANCE_REPORTED=true
+uplink_device() {
+    ip route show default 2>/dev/null | sed -n 's/.* dev \([^ ]*\).*/\1/p' | head -n 1
+}
+apply_performance() {
+    local errors=() uplink queue
+    [ -n "${WG_RMEM_MAX:-}${WG_WMEM_MAX:-}${WG_TXQUEUELEN:-}${WG_RPS_CPUS:-}${WG_XPS_CPUS:-}" ] || return 0
+    if [ -n "${WG_RMEM_MAX:-}" ]; then
+        sysctl -qw net.core.rmem_max="$WG_RMEM_MAX" net.core.rmem_default="$WG_RMEM_MAX" > /dev/null 2>&1 ||
+            errors+=("receiveBufferBytes: net.core.rmem_max cannot be set from the pod")
+    fi
+    if [ -n "${WG_WMEM_MAX:-}" ]; then
+        sysctl -qw net.core.wmem_max="$WG_WMEM_MAX" net.core.wmem_default="$WG_WMEM_MAX" > /dev/null 2>&1 ||
+            errors+=("sendBufferBytes: net.core.wmem_max cannot be set from the pod")
+    fi
+    if [ -n "${WG_TXQUEUELEN:-}" ]; then
+        ip link set dev "$WG_INTERFACE" txqueuelen "$WG_TXQUEUELEN" 2>/dev/null ||
+            errors+=("txQueueLen: cannot be set on $WG_INTERFACE")
+    fi
+    uplink=$(uplink_device)
+    if [ -n "${WG_RPS_CPUS:-}" ]; then
+        for queue in /sys/class/net/$uplink/queues/rx-*/rps_cpus; do
+            if [ ! -w "$queue" ] || ! echo "$WG_RPS_CPUS" 2>/dev/null > "$queue"; then
+                errors+=("rpsCPUs: cannot be set on the receive queues of ${uplink:-the uplink}")
+                break
+            fi
+        done
+    fi
+    if [ -n "${WG_XPS_CPUS:-}" ]; then
+        for queue in /sys/class/net/$uplink/queues/tx-*/xps_cpus; do
+            if [ ! -w "$queue" ] || ! echo "$WG_XPS_CPUS" 2>/dev/null > "$queue"; then
+                errors+=("xpsCPUs: cannot be set on the transmit queues of ${uplink:-the uplink}")
+                break
+            fi
+        done
+    fi
+    PERFORMANCE_ERRORS=("${errors[@]}")
+    [ ${#errors[@]} -eq 0 ] || echo "Data plane tuning incomplete: ${errors[*]}" >&2
+    report_performance
+}
+
+report_performance() {
+    local uplink report
+    uplink=$(uplink_device)
+    report=$(jq -n -c --argjson now "$(date +%s)" \
+        --argjson rmem "$(cat /proc/sys/net/core/rmem_max 2>/dev/null || echo 0)" \
+        --argjson wmem "$(cat /proc/sys/net/core/wmem_max 2>/dev/null || echo 0)" \
+        --argjson txqueuelen "$(cat /sys/class/net/$WG_INTERFACE/tx_queue_len 2>/dev/null || echo 0)" \
+        --arg rps "$(cat /sys/class/net/$uplink/queues/rx-0/rps_cpus 2>/dev/null)" \
+        --arg xps "$(cat /sys/class/net/$uplink/queues/tx-0/xps_cpus 2>/dev/null)" \
+        '{reportedAt: $now, rmemMax: $rmem, wmemMax: $wmem, txQueueLen: $txqueuelen,
+          rpsCPUs: $rps, xpsCPUs: $xps, errors: $ARGS.positional}' \
+        --args "${PERFORMANCE_ERRORS[@]}") || return 0
+    if kube_api PATCH "pods/$HOSTNAME" application/merge-patch+json "$(jq -n \
+        --arg key "$APPLIED_PERFORMANCE_ANNOTATION" --arg value "$report" \
+        '{metadata: {annotations: {($key): $value}}}')"; then
+        PERFORMANCE_REPORTED=true
+    else
+        PERFORMANCE_REPORTED=false
+        echo "Failed to report data plane tuning"
+    fi
+}
+apply_performance
+
+# The implementation the interface runs on, userspace when wg-quick fell
+# back to wireguard-go for a missing kernel module, reported on our own pod
+DATA_PLANE_REPORTED=""
+report_data_plane() {
+    local data_plane=kernel
+    [ ! -S "/var/run/wireguard/$WG_INTERFACE.sock" ] || data_plane=userspace
+    [ "$data_plane" != "$DATA_PLANE_REPORTED" ] || return 0
+    if kube_api PATCH "pods/$HOSTNAME" application/merge-patch+json "$(jq -n \
+        --arg key "$DATA_PLANE_ANNOTATION" --arg value "$data_plane" \
+        '{metadata: {annotations: {($key): $value}}}')"; then
+        DATA_PLANE_REPORTED=$data_plane
+        echo "Data plane: $data_plane"
+    else
+        echo "Failed to report the data plane"
+    fi
+}
+report_data_plane
+sync_bandwidth
+
+# Recreate the interface after it was deleted out of band, e.g. by node
+# scripts or a CNI restart, and reapply the rendered configuration. The
+# agent exits after repeated failures so that the container is restarted
+# rather than staying up without a tunnel.
+WATCHDOG_FAILURES=0
+recreate_interface() {
+    echo "WireGuard interface $WG_INTERFACE disappeared, recreating..."
+    /scripts/wg-op.sh down $WG_INTERFACE wg-quick down $WG_INTERFACE > /dev/null 2>&1 || true
+    if ! /scripts/wg-op.sh up $WG_INTERFACE wg-quick up $WG_INTERFACE; then
+        WATCHDOG_FAILURES=$((WATCHDOG_FAILURES + 1))
+        echo "Failed to recreate $WG_INTERFACE ($WATCHDOG_FAILURES/$WG_WATCHDOG_MAX_FAILURES)" >&2
+        if [ "$WATCHDOG_FAILURES" -ge "$WG_WATCHDOG_MAX_FAILURES" ]; then
+            emit_event Warning InterfaceRecreationFailed "Unable to recreate WireGuard interface $WG_INTERFACE, restarting" || true
+            exit 1
+        fi
+        return 1
+    fi
+    WATCHDOG_FAILURES=0
+    INTERFACE_RECREATIONS=$((INTERFACE_RECREATIONS + 1))
+    write_metrics
+    APPLIED_CHECKSUM=""
+    APPLIED_ISOLATION=""
+    APPLIED_INGRESS=""
+    APPLIED_FIREWALL=""
+    APPLIED_BANDWIDTH=""
+    reload_config
+    sync_isolation
+    sync_ingress
+    sync_firewall
+    sync_bandwidth
+    # The transmit queue length goes with the interface
+    apply_performance
+    report_data_plane
+    emit_event Warning InterfaceRecreated "WireGuard interface $WG_INTERFACE disappeared and was recreated" ||
+        echo "Failed to record interface recreation event"
+}
+
+# Watch the interface every few seconds, sample the peers every stats
+# interval and sync the configuration every monitor interval
+ELAPSED=0
+STATS_ELAPSED=0
+while [ "$STOPPING" = false ]; do
+    # Waiting on a background sleep lets the trap interrupt it
+    sleep $WG_WATCHDOG_INTERVAL &
+    wait $! || true
+    [ "$STOPPING" = false ] || break
+    ELAPSED=$((ELAPSED + WG_WATCHDOG_INTERVAL))
+    if ! ip link show dev $WG_INTERFACE > /dev/null 2>&1 || ! /scripts/wg-op.sh show $WG_INTERFACE wg show $WG_INTERFACE > /dev/null 2>&1; then
+        recreate_interface || continue
+    fi
+    sync_ha
+    sync_revocations
+    STATS_ELAPSED=$((STATS_ELAPSED + WG_WATCHDOG_INTERVAL))
+    if [ "$STATS_ELAPSED" -ge "$WG_STATS_INTERVAL" ]; then
+        STATS_ELAPSED=0
+        sync_peer_stats
+    fi
+    [ "$ELAPSED" -ge "${WG_MONITOR_INTERVAL:-30}" ] || continue
+    ELAPSED=0
+    write_metrics
+    # With a Vault key store the key is fetched again to pick up rotations
+    if [ -n "${WG_VAULT_ADDR:-}" ]; then
+        /scripts/fetch-key.sh || echo "Failed to fetch the server key from Vault"
     fi
+    sync_server_key
+    sync_shards
+    reload_config
+    sync_isolation
+    sync_ingress
+    sync_firewall
+    sync_bandwidth
+    sync_split_dns
+    sync_mtu_probes
+    check_drift
+    [ "$PERFORMANCE_REPORTED" = true ] || report_performance
+    report_data_plane
 done
+echo "Stopping WireGuard agent"
+release_lease || true
+stop_split_dns
 
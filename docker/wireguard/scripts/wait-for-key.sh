@@ -0,0 +1,42 @@
+#!/bin/bash
+
+# Wait until the server private key has been delivered (Secret volume or
+# key-store fetch) and is a valid WireGuard key, so the main container does
+# not crash-loop while the key Secret lags behind pod creation.
+
+set -e
+
+WG_KEY_FILE=${WG_KEY_FILE:-/etc/wireguard/keys/server_private}
+WG_KEY_WAIT_TIMEOUT=${WG_KEY_WAIT_TIMEOUT:-600}
+WG_KEY_WAIT_INTERVAL=${WG_KEY_WAIT_INTERVAL:-5}
+
+# A WireGuard key is 32 bytes of base64, 44 characters with padding
+valid_key() {
+    local key
+    key=$(tr -d '[:space:]' < "$WG_KEY_FILE")
+    [ ${#key} -eq 44 ] || return 1
+    [ "$(echo "$key" | base64 -d 2>/dev/null | wc -c)" -eq 32 ] || return 1
+    echo "$key" | wg pubkey > /dev/null 2>&1
+}
+
+echo "Waiting for WireGuard key at $WG_KEY_FILE..."
+ELAPSED=0
+while true; do
+    if [ -n "${WG_VAULT_ADDR:-}" ]; then
+        /scripts/fetch-key.sh || echo "Failed to fetch WireGuard key from Vault"
+    fi
+    if [ -s "$WG_KEY_FILE" ]; then
+        if valid_key; then
+            echo "WireGuard key is available"
+            exit 0
+        fi
+        echo "WireGuard key at $WG_KEY_FILE is malformed"
+    fi
+
+    if [ "$ELAPSED" -ge "$WG_KEY_WAIT_TIMEOUT" ]; then
+        echo "Timed out after ${WG_KEY_WAIT_TIMEOUT}s waiting for WireGuard key"
+        exit 1
+    fi
+    sleep "$WG_KEY_WAIT_INTERVAL"
+    ELAPSED=$((ELAPSED + WG_KEY_WAIT_INTERVAL))
+done
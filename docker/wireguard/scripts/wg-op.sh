@@ -0,0 +1,80 @@
+#!/bin/bash
+
+# Run an operation on a WireGuard device. Operations on the same interface
+# are serialized across the agent's processes, so that a reload does not
+# race the session recorder's dumps or a recreation of the interface, and
+# transient netlink errors are retried with exponential backoff. Attempts
+# and errors are counted per operation for the agent metrics.
+#
+# Usage: wg-op.sh <operation> <interface> <command> [args...]
+#
+# e.g. wg-op.sh syncconf wg0 wg syncconf wg0 /run/wireflow/wg0.conf
+
+WG_STATE_DIR=${WG_STATE_DIR:-/run/wireflow}
+WG_OP_ATTEMPTS=${WG_OP_ATTEMPTS:-5}
+WG_OP_BACKOFF=${WG_OP_BACKOFF:-0.1}
+WG_OP_LOCK_TIMEOUT=${WG_OP_LOCK_TIMEOUT:-30}
+COUNTER_DIR=$WG_STATE_DIR/netlink
+
+OPERATION=$1
+IFACE=$2
+
+if [ $# -lt 3 ]; then
+    echo "Usage: $0 <operation> <interface> <command> [args...]" >&2
+    exit 2
+fi
+shift 2
+
+mkdir -p "$COUNTER_DIR"
+
+# Classify the error of a failed attempt from its output. Busy devices,
+# exhausted socket buffers and interrupted dumps go away on their own.
+classify() {
+    case "$1" in
+        *"Device or resource busy"*) echo EBUSY ;;
+        *"Resource temporarily unavailable"*) echo EAGAIN ;;
+        *"No buffer space available"*) echo ENOBUFS ;;
+        *"Interrupted system call"*) echo EINTR ;;
+        *"Dump was interrupted"*) echo EINTR ;;
+        *) echo other ;;
+    esac
+}
+
+# Increment a counter file, named <interface>:<operation>:<series>. Interface
+# names cannot contain colons. Callers hold the interface lock.
+count() {
+    local file=$COUNTER_DIR/$1 value=0
+    [ -f "$file" ] && value=$(cat "$file")
+    echo $((value + 1)) > "$file"
+}
+
+exec 9> "$WG_STATE_DIR/$IFACE.lock"
+if ! flock -w "$WG_OP_LOCK_TIMEOUT" 9; then
+    echo "Timed out waiting for the lock of $IFACE" >&2
+    exit 1
+fi
+
+attempt=1
+delay=$WG_OP_BACKOFF
+while true; do
+    count "$IFACE:$OPERATION:operations"
+    output=$("$@" 2> "$WG_STATE_DIR/$IFACE.stderr")
+    status=$?
+    errors=$(cat "$WG_STATE_DIR/$IFACE.stderr")
+    if [ $status -eq 0 ]; then
+        [ -z "$output" ] || printf '%s\n' "$output"
+        [ -z "$errors" ] || printf '%s\n' "$errors" >&2
+        exit 0
+    fi
+
+    error=$(classify "$errors")
+    count "$IFACE:$OPERATION:errors:$error"
+    if [ "$error" = other ] || [ $attempt -ge "$WG_OP_ATTEMPTS" ]; then
+        printf '%s\n' "$errors" >&2
+        exit $status
+    fi
+    echo "$OPERATION on $IFACE failed with $error, retrying in ${delay}s ($attempt/$WG_OP_ATTEMPTS)" >&2
+    sleep "$delay"
+    delay=$(awk "BEGIN { print $delay * 2 }")
+    attempt=$((attempt + 1))
+done
@@ -0,0 +1,176 @@
+package v1alpha1
+
+const (
+	// ConfigChecksumAnnotation is set by the operator on VPN server pods to
+	// the checksum of the desired WireGuard configuration. The agent watches
+	// it through the downward API and hot-reloads the device when it changes.
+	ConfigChecksumAnnotation = "vpn.vpn-devops.com/config-checksum"
+
+	// AppliedConfigChecksumAnnotation is set by the agent on its own pod to
+	// the checksum of the configuration currently loaded into the device.
+	AppliedConfigChecksumAnnotation = "vpn.vpn-devops.com/applied-config-checksum"
+
+	// CascadeDeleteAnnotation allows deleting a VPNServer that still has
+	// peers bound to it when set to "true". The peers are deleted with it.
+	CascadeDeleteAnnotation = "vpn.vpn-devops.com/cascade"
+
+	// KeyEscrowLabel marks Secrets holding WireGuard private keys that are
+	// escrowed to the organisation recovery key when set to "true"
+	KeyEscrowLabel = "vpn.vpn-devops.com/escrow"
+
+	// NICSaturatedLabel is set by the operator on nodes whose NIC
+	// utilization is above the saturation threshold
+	NICSaturatedLabel = "vpn.vpn-devops.com/nic-saturated"
+
+	// PlacementAnnotation is set by the operator on server pods to the
+	// rationale of their placement once they are scheduled
+	PlacementAnnotation = "vpn.vpn-devops.com/placement"
+
+	// ProbeMTUAnnotation requests a path MTU probe of a VPNPeer when set to
+	// a new value, e.g. the current time
+	ProbeMTUAnnotation = "vpn.vpn-devops.com/probe-mtu"
+
+	// MTUProbesAnnotation is set by the operator on VPN server pods to the
+	// pending path MTU probes, as space separated peer|request|address
+	// entries. The agent watches it through the downward API.
+	MTUProbesAnnotation = "vpn.vpn-devops.com/mtu-probes"
+
+	// MTUProbeResultsAnnotation is set by the agent on its own pod to the
+	// results of the probes, a JSON object of the discovered path MTU and
+	// the request of each peer. The path MTU is 0 if the peer did not answer.
+	MTUProbeResultsAnnotation = "vpn.vpn-devops.com/mtu-probe-results"
+
+	// PeerStatsAnnotation is set by the agent on its own pod to a summary of
+	// the peers of its device, pushed on significant changes only. It is a
+	// JSON object of the sample time and, by public key, the endpoint,
+	// latest handshake, received and sent bytes and round-trip time of the
+	// peers that handshook.
+	PeerStatsAnnotation = "vpn.vpn-devops.com/peer-stats"
+
+	// ConfigDriftAnnotation is set by the agent on its own pod to the
+	// differences between its live device and the desired configuration. It
+	// is a JSON object of the check time, the checksum of the configuration
+	// compared against and the missing, unexpected and changed peers.
+	ConfigDriftAnnotation = "vpn.vpn-devops.com/config-drift"
+
+	// AppliedPerformanceAnnotation is set by the agent on its own pod to the
+	// data plane tuning in effect, read back after applying
+	// spec.performance. It is a JSON object of the report time, the socket
+	// buffer sizes, the transmit queue length, the RPS and XPS masks and
+	// the errors of the knobs that failed.
+	AppliedPerformanceAnnotation = "vpn.vpn-devops.com/applied-performance"
+
+	// DataPlaneAnnotation is set by the agent on its own pod to the
+	// WireGuard implementation its interface runs on, kernel or userspace.
+	DataPlaneAnnotation = "vpn.vpn-devops.com/data-plane"
+
+	// AppliedBandwidthAnnotation is set by the agent on its own pod to the
+	// bandwidth limits in effect on its interface. It is a JSON object of
+	// the report time and, by peer address, the rate and burst applied or
+	// the error that prevented it.
+	AppliedBandwidthAnnotation = "vpn.vpn-devops.com/applied-bandwidth"
+
+	// ClientConfigChecksumAnnotation is set by the operator on client config
+	// Secrets to the checksum of the config they hold, so that sealed
+	// configs are only sealed again when the config changes
+	ClientConfigChecksumAnnotation = "vpn.vpn-devops.com/client-config-checksum"
+
+	// ChatIntegrationLabel is set on the VPNPeers created through a
+	// VPNChatIntegration to its name
+	ChatIntegrationLabel = "vpn.vpn-devops.com/chat-integration"
+
+	// ChatUserLabel is set on the VPNPeers created through a
+	// VPNChatIntegration to the lowercased chat user ID of their owner
+	ChatUserLabel = "vpn.vpn-devops.com/chat-user"
+
+	// NetworkLabel is set on the VPNPeers linking the servers of a
+	// VPNNetwork to its name
+	NetworkLabel = "vpn.vpn-devops.com/network"
+
+	// NetworkServerLabel is set on the VPNPeers linking the servers of a
+	// VPNNetwork to the name of the server on the other side of the link
+	NetworkServerLabel = "vpn.vpn-devops.com/network-server"
+
+	// NetworkPreviousLinkAnnotation is set on the VPNPeers linking the
+	// servers of a VPNNetwork while a change of the network is verified, to
+	// the JSON spec of the peer before the change, or empty if the change
+	// created it. The change is rolled back from it.
+	NetworkPreviousLinkAnnotation = "vpn.vpn-devops.com/previous-link"
+
+	// SelfEnrollmentLabel is set on the VPNPeers enrolled through a
+	// VPNSelfEnrollment to its name
+	SelfEnrollmentLabel = "vpn.vpn-devops.com/self-enrollment"
+
+	// SelfEnrollmentUserLabel is set on the VPNPeers enrolled through a
+	// VPNSelfEnrollment to the name of the user who enrolled them
+	SelfEnrollmentUserLabel = "vpn.vpn-devops.com/self-enrollment-user"
+
+	// SelfEnrollmentDeviceLabel is set on the VPNPeers enrolled through a
+	// VPNSelfEnrollment to the name of their device
+	SelfEnrollmentDeviceLabel = "vpn.vpn-devops.com/self-enrollment-device"
+
+	// OrphanedLabel is set to "true" on the resources a server kept when it
+	// was deleted with the Retain deletion policy
+	OrphanedLabel = "vpn.vpn-devops.com/orphaned"
+
+	// ShardLabel is set on the Services of the replicas of sharded servers
+	// to the ordinal of their replica
+	ShardLabel = "vpn.vpn-devops.com/shard"
+
+	// HARoleLabel is set on the pods of servers in the high availability
+	// mode to HARoleActive or HARoleStandby. The Service of the server only
+	// selects the active replica.
+	HARoleLabel = "vpn.vpn-devops.com/ha-role"
+
+	// PeeringLabel is set on the VPNPeers established by a VPNPeering to
+	// its name
+	PeeringLabel = "vpn.vpn-devops.com/peering"
+
+	// MaintenanceOverrideAnnotation is set on servers by wireflow maintenance
+	// --force-now to the RFC 3339 time until which disruptive operations run
+	// regardless of the maintenance windows, for emergencies
+	MaintenanceOverrideAnnotation = "vpn.vpn-devops.com/maintenance-override-until"
+
+	// ServerNamespaceLabel is set on the cluster-scoped resources of a
+	// server to its namespace, along with the labels of the server
+	ServerNamespaceLabel = "vpn.vpn-devops.com/server-namespace"
+
+	// NodeEndpointAddressAnnotation is set on nodes to the address clients
+	// reach the replicas of DaemonSet servers on the node with, when it is
+	// neither the external nor the internal address of the node, e.g. a
+	// floating IP
+	NodeEndpointAddressAnnotation = "vpn.vpn-devops.com/endpoint-address"
+
+	// RevokedPeersAnnotation is set on server pods to the public keys of
+	// the revoked and deleted peers of the server, with the Unix time of
+	// their revocation, e.g. key1@1700000000,key2@1700000060. The agent
+	// removes them from the live interface as soon as it reads them.
+	RevokedPeersAnnotation = "vpn.vpn-devops.com/revoked-peers"
+
+	// OrphanedFromAnnotation is set on orphaned resources to the server they
+	// were kept from
+	OrphanedFromAnnotation = "vpn.vpn-devops.com/orphaned-from"
+
+	// ConnectivityCheckLabel is set on the probe VPNPeer and pods of a
+	// VPNConnectivityCheck to its name
+	ConnectivityCheckLabel = "vpn.vpn-devops.com/connectivity-check"
+
+	// ProbePublicKeyAnnotation is set by the probe of a VPNConnectivityCheck
+	// on its own pod to the public key it generated. The operator sets it on
+	// the probe VPNPeer.
+	ProbePublicKeyAnnotation = "vpn.vpn-devops.com/probe-public-key"
+
+	// ProbeSampleAnnotation is set by the probe of a VPNConnectivityCheck on
+	// its own pod to its latest sample, a JSON object of the sample time,
+	// the average round-trip time in milliseconds, null if no ping was
+	// answered, and the loss in percent
+	ProbeSampleAnnotation = "vpn.vpn-devops.com/probe-sample"
+)
+
+const (
+	// HARoleActive is the HARoleLabel of the replica holding the lease
+	HARoleActive = "active"
+
+	// HARoleStandby is the HARoleLabel of the other replicas
+	HARoleStandby = "standby"
+)
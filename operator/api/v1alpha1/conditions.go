@@ -0,0 +1,176 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Condition statuses
+const (
+	ConditionTrue    = "True"
+	ConditionFalse   = "False"
+	ConditionUnknown = "Unknown"
+)
+
+// Condition types reported by the operator
+const (
+	// ConditionReady indicates whether the resource is fully reconciled
+	ConditionReady = "Ready"
+
+	// ConditionFederated indicates whether a VPNServer placed on a member
+	// cluster has been propagated to it
+	ConditionFederated = "Federated"
+
+	// ConditionHealthAlert indicates whether one of the server's alerting
+	// thresholds is breached
+	ConditionHealthAlert = "HealthAlert"
+
+	// ConditionEndpointOverridden indicates whether the endpoint of a
+	// VPNServer is taken from spec.endpointOverride rather than discovered
+	ConditionEndpointOverridden = "EndpointOverridden"
+
+	// ConditionEndpointDiscovered indicates whether the endpoint of a
+	// VPNServer was discovered from its Service
+	ConditionEndpointDiscovered = "EndpointDiscovered"
+
+	// ConditionEndpointResolutionDegraded is True while the host name of
+	// the endpoint of a server does not resolve. It clears once the name
+	// resolves again.
+	ConditionEndpointResolutionDegraded = "EndpointResolutionDegraded"
+
+	// ConditionPublicKeyOverridden indicates whether the public key of a
+	// VPNServer is taken from spec.publicKeyOverride rather than discovered
+	ConditionPublicKeyOverridden = "PublicKeyOverridden"
+
+	// ConditionFeatureUnsupportedOnNode indicates whether a VPNServer uses
+	// features the kernel of some node it can be scheduled on lacks
+	ConditionFeatureUnsupportedOnNode = "FeatureUnsupportedOnNode"
+
+	// ConditionPoolNearlyExhausted indicates whether an IP pool is predicted
+	// to run out of addresses within its threshold
+	ConditionPoolNearlyExhausted = "PoolNearlyExhausted"
+
+	// ConditionMTUMismatch is True when the tunnel MTU of a peer is larger
+	// than its probed path allows, so that its packets are fragmented or
+	// dropped
+	ConditionMTUMismatch = "MTUMismatch"
+
+	// ConditionOperatorRestarting is True on VPNServers while the operator
+	// shuts down and their changes wait for it to run again
+	ConditionOperatorRestarting = "OperatorRestarting"
+
+	// ConditionDeviceMismatch is True when the key of a peer bound to a
+	// device was re-validated from another device, and Unknown when its
+	// re-validation is overdue
+	ConditionDeviceMismatch = "DeviceMismatch"
+
+	// ConditionRevoked is True once a peer is revoked. Its transition time
+	// is when it was revoked.
+	ConditionRevoked = "Revoked"
+
+	// ConditionClientOutdated is True when the client of a peer reported a
+	// version below the minimum client version of its server
+	ConditionClientOutdated = "ClientOutdated"
+
+	// ConditionKeyRotationPending is True during the grace period of a
+	// server key rotation, while the next key is published but not active
+	ConditionKeyRotationPending = "KeyRotationPending"
+
+	// ConditionAddressConflict is True when the address of a VPNPeer is
+	// taken by an older peer or a server of its namespace
+	ConditionAddressConflict = "AddressConflict"
+
+	// ConditionConfigDrift is True when the live device of a replica of a
+	// VPNServer differs from the desired configuration, and Unknown when no
+	// replica compared them recently
+	ConditionConfigDrift = "ConfigDrift"
+
+	// ConditionPerformanceTuned is True when every ready replica of a
+	// VPNServer reports the tuning of spec.performance in effect, and
+	// Unknown while some did not report it yet
+	ConditionPerformanceTuned = "PerformanceTuned"
+
+	// ConditionDataPlaneFallback is True when replicas of a VPNServer with
+	// the kernel implementation run wireguard-go, as their node has no
+	// WireGuard kernel module
+	ConditionDataPlaneFallback = "DataPlaneFallback"
+
+	// ConditionRoutesExported is True when the client network of a
+	// VPNServer is published to the CNI of spec.routeExport
+	ConditionRoutesExported = "RoutesExported"
+
+	// ConditionDegraded is True when the latency or loss sampled by a
+	// VPNConnectivityCheck breached its thresholds for several samples in a
+	// row, and False once as many samples in a row were healthy again
+	ConditionDegraded = "Degraded"
+
+	// ConditionScalingLimited is True when the targets of spec.autoscaling
+	// of a VPNServer call for more replicas than maxReplicas
+	ConditionScalingLimited = "ScalingLimited"
+
+	// ConditionShardsAvailable is True when every replica of a sharded
+	// VPNServer serving peers is ready and reachable on its endpoint
+	ConditionShardsAvailable = "ShardsAvailable"
+
+	// ConditionActiveReplicaElected is True when a replica of a VPNServer in
+	// the high availability mode holds the lease and terminates its tunnels
+	ConditionActiveReplicaElected = "ActiveReplicaElected"
+)
+
+// SetCondition adds the condition to conditions or updates the existing
+// condition of the same type. LastTransitionTime is only changed when the
+// status of the condition changes.
+func SetCondition(conditions *[]Condition, condition Condition) {
+	if existing := FindCondition(*conditions, condition.Type); existing != nil {
+		if existing.Status != condition.Status {
+			existing.Status = condition.Status
+			existing.LastTransitionTime = metav1.Now()
+		}
+		existing.Reason = condition.Reason
+		existing.Message = condition.Message
+		existing.ObservedGeneration = condition.ObservedGeneration
+		return
+	}
+
+	if condition.LastTransitionTime.IsZero() {
+		condition.LastTransitionTime = metav1.Now()
+	}
+	*conditions = append(*conditions, condition)
+}
+
+// RemoveCondition removes the condition of the given type, if set.
+func RemoveCondition(conditions *[]Condition, conditionType string) {
+	kept := (*conditions)[:0]
+	for _, condition := range *conditions {
+		if condition.Type != conditionType {
+			kept = append(kept, condition)
+		}
+	}
+	*conditions = kept
+}
+
+// FindCondition returns the condition of the given type, or nil if it is
+// not set.
+func FindCondition(conditions []Condition, conditionType string) *Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsConditionTrue returns whether the condition of the given type is set
+// and true.
+func IsConditionTrue(conditions []Condition, conditionType string) bool {
+	condition := FindCondition(conditions, conditionType)
+	return condition != nil && condition.Status == ConditionTrue
+}
+
+// IsConditionCurrent returns whether the condition of the given type is set,
+// true and was set for the given generation of the resource. Unlike
+// IsConditionTrue it does not report a resource as Ready for a spec that has
+// not been reconciled yet.
+func IsConditionCurrent(conditions []Condition, conditionType string, generation int64) bool {
+	condition := FindCondition(conditions, conditionType)
+	return condition != nil && condition.Status == ConditionTrue && condition.ObservedGeneration == generation
+}
@@ -0,0 +1,50 @@
+package v1alpha1
+
+// Settings of the configuration hierarchy are merged field by field, in
+// this order, each level overriding the ones before it:
+//
+//  1. the fleet defaults of the operator (--fleet-defaults)
+//  2. the VPNServerClass of the server (spec.className)
+//  3. the VPNServer, then the client routing profile the peer selects
+//  4. the VPNPeerGroup of the peer (spec.group)
+//  5. the VPNPeer
+//
+// A level leaves a setting to the levels before it by leaving it unset. The
+// merged settings of a peer, with the level each comes from, are reported in
+// status.effectiveConfig.
+
+// ConfigLayer holds the settings one level of the configuration hierarchy
+// can define
+type ConfigLayer struct {
+	// DNS is the DNS server pushed to clients
+	DNS string `json:"dns,omitempty"`
+
+	// PersistentKeepalive is the keepalive interval in seconds
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
+	PersistentKeepalive *int32 `json:"persistentKeepalive,omitempty"`
+
+	// MTU is the MTU of the tunnel interface of clients
+	// +kubebuilder:validation:Minimum=1280
+	// +kubebuilder:validation:Maximum=9000
+	MTU *int32 `json:"mtu,omitempty"`
+}
+
+// EffectiveConfig is the configuration of a peer after merging the levels of
+// the configuration hierarchy
+type EffectiveConfig struct {
+	// DNS is the DNS server pushed to the client
+	DNS string `json:"dns,omitempty"`
+
+	// PersistentKeepalive is the keepalive interval in seconds, 0 if
+	// disabled
+	PersistentKeepalive int32 `json:"persistentKeepalive,omitempty"`
+
+	// MTU is the MTU of the tunnel interface of the client, 0 for the
+	// client's default
+	MTU int32 `json:"mtu,omitempty"`
+
+	// Sources maps each setting in effect to the level it comes from, e.g.
+	// VPNServerClass/standard
+	Sources map[string]string `json:"sources,omitempty"`
+}
@@ -0,0 +1,11 @@
+package v1alpha1
+
+// v1alpha1 is the hub of the conversions of the kinds promoted to v1beta1,
+// and their storage version: the operator reads and writes v1alpha1, and
+// v1beta1 objects are converted by the conversion webhook.
+
+// Hub marks VPNServer as the conversion hub.
+func (*VPNServer) Hub() {}
+
+// Hub marks VPNPeer as the conversion hub.
+func (*VPNPeer) Hub() {}
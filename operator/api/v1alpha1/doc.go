@@ -0,0 +1,4 @@
+// +groupName=vpn.vpn-devops.com
+// +groupGoName=Vpn
+
+package v1alpha1
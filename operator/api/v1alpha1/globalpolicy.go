@@ -0,0 +1,99 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ServerViolations returns how a server in a namespace with the labels
+// namespaceLabels violates the policy.
+func (p *VPNGlobalPolicy) ServerViolations(server *VPNServer, namespaceLabels map[string]string) []string {
+	var violations []string
+	violations = append(violations, p.allowedIPsViolations("spec.allowedIPs", splitList(server.Spec.AllowedIPs))...)
+	for _, profile := range server.Spec.ClientRoutingProfiles {
+		violations = append(violations, p.allowedIPsViolations(
+			fmt.Sprintf("spec.clientRoutingProfiles[%s].allowedIPs", profile.Name), profile.AllowedIPs)...)
+	}
+
+	if max := p.Spec.MaxKeyRotationInterval; max != nil {
+		if rotation := server.Spec.KeyRotation; rotation == nil {
+			violations = append(violations, fmt.Sprintf("spec.keyRotation is required, with an interval of at most %s", max.Duration))
+		} else if rotation.Interval.Duration > max.Duration {
+			violations = append(violations, fmt.Sprintf("spec.keyRotation.interval %s exceeds %s", rotation.Interval.Duration, max.Duration))
+		}
+	}
+
+	serviceType := corev1.ServiceTypeLoadBalancer
+	if server.Spec.Service != nil && server.Spec.Service.Type != "" {
+		serviceType = server.Spec.Service.Type
+	}
+	for _, rule := range p.Spec.Exposure {
+		selector, err := metav1.LabelSelectorAsSelector(&rule.NamespaceSelector)
+		if err != nil || !selector.Matches(labels.Set(namespaceLabels)) {
+			continue
+		}
+		allowed := false
+		for _, t := range rule.AllowedTypes {
+			allowed = allowed || t == serviceType
+		}
+		if !allowed {
+			violations = append(violations, fmt.Sprintf("spec.service.type %s is not allowed in namespace %s, allowed are %v",
+				serviceType, server.Namespace, rule.AllowedTypes))
+		}
+	}
+	return p.prefixed(violations)
+}
+
+// PeerViolations returns how a peer violates the policy.
+func (p *VPNGlobalPolicy) PeerViolations(peer *VPNPeer) []string {
+	violations := p.allowedIPsViolations("spec.allowedIPs", peer.Spec.AllowedIPs)
+	if p.Spec.RequirePresharedKeys && peer.Spec.PresharedKeySecretRef == nil && peer.Spec.PresharedKey == nil {
+		violations = append(violations, "a preshared key is required, set spec.presharedKey or spec.presharedKeySecretRef")
+	}
+	return p.prefixed(violations)
+}
+
+// PeerGroupViolations returns how a peer group violates the policy.
+func (p *VPNGlobalPolicy) PeerGroupViolations(group *VPNPeerGroup) []string {
+	if group.Spec.Tunnel == nil {
+		return nil
+	}
+	return p.prefixed(p.allowedIPsViolations("spec.tunnel", group.Spec.Tunnel.ClientAllowedIPs()))
+}
+
+// allowedIPsViolations returns the networks of allowedIPs at field that
+// cover a forbidden network.
+func (p *VPNGlobalPolicy) allowedIPsViolations(field string, allowedIPs []string) []string {
+	var violations []string
+	for _, cidr := range allowedIPs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		for _, forbidden := range p.Spec.ForbiddenAllowedIPs {
+			if _, forbiddenNetwork, err := net.ParseCIDR(forbidden); err == nil && coversNetwork(network, forbiddenNetwork) {
+				violations = append(violations, fmt.Sprintf("%s %s covers the forbidden network %s", field, cidr, forbidden))
+			}
+		}
+	}
+	return violations
+}
+
+// prefixed prefixes violations with the name of the policy.
+func (p *VPNGlobalPolicy) prefixed(violations []string) []string {
+	for i := range violations {
+		violations[i] = fmt.Sprintf("VPNGlobalPolicy %s: %s", p.Name, violations[i])
+	}
+	return violations
+}
+
+// coversNetwork returns whether network is other or a superset of it.
+func coversNetwork(network, other *net.IPNet) bool {
+	ones, bits := network.Mask.Size()
+	otherOnes, otherBits := other.Mask.Size()
+	return bits == otherBits && ones <= otherOnes && network.Contains(other.IP)
+}
@@ -0,0 +1,37 @@
+package v1alpha1
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FindDeniedKey returns the VPNKeyDenyList denying a public key with its
+// entry, or nil if no list denies it.
+func FindDeniedKey(ctx context.Context, c client.Reader, publicKey string) (*VPNKeyDenyList, *DeniedKey, error) {
+	publicKey = NormalizeKey(publicKey)
+	if publicKey == "" {
+		return nil, nil, nil
+	}
+	lists := &VPNKeyDenyListList{}
+	if err := c.List(ctx, lists); err != nil {
+		return nil, nil, err
+	}
+	for i := range lists.Items {
+		list := &lists.Items[i]
+		for j := range list.Spec.Keys {
+			if NormalizeKey(list.Spec.Keys[j].PublicKey) == publicKey {
+				return list, &list.Spec.Keys[j], nil
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// DenialMessage describes the denial of a key, with its reason if any.
+func (k *DeniedKey) DenialMessage() string {
+	if k.Reason == "" {
+		return "public key " + k.PublicKey + " is denied"
+	}
+	return "public key " + k.PublicKey + " is denied: " + k.Reason
+}
@@ -0,0 +1,98 @@
+package v1alpha1
+
+import (
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// DefaultPresharedKeyKey is the key of the preshared key in the Secret
+// referenced by presharedKeySecretRef when the reference does not say
+const DefaultPresharedKeyKey = "preshared-key"
+
+// curve25519P is the prime of the field of Curve25519, 2^255 - 19
+var curve25519P = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// curve25519A is the coefficient A of Curve25519, v^2 = u^3 + A*u^2 + u
+var curve25519A = big.NewInt(486662)
+
+// curve25519LowOrder are the canonical little endian encodings of the
+// u-coordinates of the points of small order on Curve25519: 0, 1, the two
+// points of order 8 and p - 1. They yield predictable shared secrets.
+var curve25519LowOrder = [][32]byte{
+	{},
+	{1},
+	{
+		0xe0, 0xeb, 0x7a, 0x7c, 0x3b, 0x41, 0xb8, 0xae, 0x16, 0x56, 0xe3, 0xfa, 0xf1, 0x9f, 0xc4, 0x6a,
+		0xda, 0x09, 0x8d, 0xeb, 0x9c, 0x32, 0xb1, 0xfd, 0x86, 0x62, 0x05, 0x16, 0x5f, 0x49, 0xb8, 0x00,
+	},
+	{
+		0x5f, 0x9c, 0x95, 0xbc, 0xa3, 0x50, 0x8c, 0x24, 0xb1, 0xd0, 0xb1, 0x55, 0x9c, 0x83, 0xef, 0x5b,
+		0x04, 0x44, 0x5c, 0xc4, 0x58, 0x1c, 0x8e, 0x86, 0xd8, 0x22, 0x4e, 0xdd, 0xd0, 0x9f, 0x11, 0x57,
+	},
+	{
+		0xec, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f,
+	},
+}
+
+// NormalizeKey removes the whitespace users paste along with keys, e.g. the
+// trailing newline of wg genkey or line breaks from wrapped terminals.
+func NormalizeKey(key string) string {
+	return strings.Join(strings.Fields(key), "")
+}
+
+// ParseWireGuardKey decodes a base64 encoded WireGuard key, a PSK or either
+// half of a key pair. Like wg, it rejects encodings whose padding bits are
+// set, which decode to the key of another encoding.
+func ParseWireGuardKey(key string) ([]byte, error) {
+	raw, err := base64.StdEncoding.Strict().DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		return nil, errors.New("not a base64 encoded 32 byte WireGuard key")
+	}
+	return raw, nil
+}
+
+// ValidatePublicKey checks that key is a WireGuard public key. Public keys
+// are canonical encodings of points on Curve25519 other than the low order
+// ones, which half of the private keys pasted instead fail. It returns a
+// warning for keys that are clamped like private keys, which a sixteenth
+// of public keys are by chance.
+func ValidatePublicKey(key string) (warning string, err error) {
+	raw, err := ParseWireGuardKey(key)
+	if err != nil {
+		return "", err
+	}
+	// Encodings are little endian
+	reversed := make([]byte, len(raw))
+	for i, b := range raw {
+		reversed[len(raw)-1-i] = b
+	}
+	u := new(big.Int).SetBytes(reversed)
+	if u.Cmp(curve25519P) >= 0 {
+		return "", errors.New("not a canonical Curve25519 point, is it a private key?")
+	}
+	for _, point := range curve25519LowOrder {
+		if [32]byte(raw) == point {
+			return "", errors.New("a low order Curve25519 point, which is no usable public key")
+		}
+	}
+
+	// u is on the curve, rather than its twist, if u^3 + A*u^2 + u is a
+	// square modulo p
+	rhs := new(big.Int).Mul(u, u)
+	rhs.Add(rhs, new(big.Int).Mul(curve25519A, u))
+	rhs.Add(rhs, big.NewInt(1))
+	rhs.Mul(rhs, u)
+	rhs.Mod(rhs, curve25519P)
+	exponent := new(big.Int).Rsh(new(big.Int).Sub(curve25519P, big.NewInt(1)), 1)
+	if rhs.Sign() != 0 && new(big.Int).Exp(rhs, exponent, curve25519P).Cmp(big.NewInt(1)) != 0 {
+		return "", errors.New("not a point on Curve25519, is it a private key?")
+	}
+
+	if raw[0]&7 == 0 && raw[31]&192 == 64 {
+		return "the key is clamped like a WireGuard private key, make sure it is the output of wg pubkey", nil
+	}
+	return "", nil
+}
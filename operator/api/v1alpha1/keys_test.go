@@ -0,0 +1,52 @@
+package v1alpha1
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePublicKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		err     string
+		warning bool
+	}{
+		// Key pairs as wg genkey and wg pubkey generate them
+		{name: "public key", key: "1nwRYAUSrbUHH814PAF+nbGBBnr2mxNuqupmKqT6VSI="},
+		{name: "another public key", key: "BEtWg2VTvyAXKz5rfqkfP2jtud4T/sBTnirlH2Yyo1o="},
+		{name: "public key clamped by chance", key: "wHOYzS2AlNteA2udy4GxSXR4A/1ZjPx4rzXhkYLKhGk=", warning: true},
+
+		// The private keys of the public keys above
+		{name: "private key on the curve", key: "gPP6J/ulLE1jsrux9X8vmGu63fHESyyn76uOpdfwh1Q=", warning: true},
+		{name: "private key on the twist", key: "WM1FEMN66+6/3P85Na5UsrpbWu/J9ZEUXCHzCqskdFw=", err: "not a point on Curve25519"},
+
+		{name: "zero", key: "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", err: "low order"},
+		{name: "one", key: "AQAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", err: "low order"},
+		{name: "point of order 8", key: "4Ot6fDtBuK4WVuP68Z/EatoJjeucMrH9hmIFFl9JuAA=", err: "low order"},
+		{name: "other point of order 8", key: "X5yVvKNQjCSx0LFVnIPvWwREXMRYHI6G2CJO3dCfEVc=", err: "low order"},
+		{name: "p - 1", key: "7P///////////////////////////////////////38=", err: "low order"},
+
+		{name: "p", key: "7f///////////////////////////////////////38=", err: "not a canonical"},
+		{name: "p + 1", key: "7v///////////////////////////////////////38=", err: "not a canonical"},
+		{name: "high bit set", key: "1nwRYAUSrbUHH814PAF+nbGBBnr2mxNuqupmKqT6VaI=", err: "not a canonical"},
+
+		{name: "not base64", key: "not a key", err: "not a base64 encoded"},
+		{name: "padding bits set", key: "1nwRYAUSrbUHH814PAF+nbGBBnr2mxNuqupmKqT6VSJ=", err: "not a base64 encoded"},
+		{name: "short", key: "1nwRYAUSrbUHH814PAF+nbGBBnr2mxNuqupmKqT6", err: "not a base64 encoded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warning, err := ValidatePublicKey(tt.key)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("error = %v, want none", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+			if (warning != "") != tt.warning {
+				t.Errorf("warning = %q, want one: %v", warning, tt.warning)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField is the set of values a field of a cron schedule matches
+type cronField struct {
+	values   map[int]bool
+	wildcard bool
+}
+
+// cronSchedule is a parsed five-field cron schedule
+type cronSchedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek cronField
+}
+
+// cronBounds are the bounds of the fields of a cron schedule, in order
+var cronBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// parseCron parses a cron schedule of five fields: minute, hour, day of
+// month, month and day of week. Fields are *, values, ranges and lists of
+// them, with an optional step, e.g. "0 2 * * 6" or "30 */4 1-7 * 1,3".
+// Sunday is 0 or 7. As with cron, a schedule restricting both the day of
+// month and the day of week matches days matching either.
+func parseCron(schedule string) (*cronSchedule, error) {
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+	var parsed [5]cronField
+	for i, field := range fields {
+		f, err := parseCronField(field, cronBounds[i][0], cronBounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("field %d %q: %w", i+1, field, err)
+		}
+		parsed[i] = f
+	}
+	if parsed[4].values[7] {
+		parsed[4].values[0] = true
+	}
+	return &cronSchedule{parsed[0], parsed[1], parsed[2], parsed[3], parsed[4]}, nil
+}
+
+// parseCronField parses a field of a cron schedule with bounds min and max.
+func parseCronField(field string, min, max int) (cronField, error) {
+	// As with cron, */n still leaves the day unrestricted
+	f := cronField{values: map[int]bool{}, wildcard: strings.HasPrefix(field, "*")}
+	for _, part := range strings.Split(field, ",") {
+		span, stepText, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+				return f, fmt.Errorf("invalid step %q", stepText)
+			}
+		}
+		low, high := min, max
+		if span != "*" {
+			lowText, highText, ranged := strings.Cut(span, "-")
+			var err error
+			if low, err = strconv.Atoi(lowText); err != nil {
+				return f, fmt.Errorf("invalid value %q", lowText)
+			}
+			high = low
+			if ranged {
+				if high, err = strconv.Atoi(highText); err != nil {
+					return f, fmt.Errorf("invalid value %q", highText)
+				}
+			} else if stepped {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return f, fmt.Errorf("%q is out of the range %d-%d", span, min, max)
+		}
+		for value := low; value <= high; value += step {
+			f.values[value] = true
+		}
+	}
+	return f, nil
+}
+
+// matchesDay returns whether the schedule runs on the day of t.
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	if !s.month.values[int(t.Month())] {
+		return false
+	}
+	dayOfMonth, dayOfWeek := s.dayOfMonth.values[t.Day()], s.dayOfWeek.values[int(t.Weekday())]
+	switch {
+	case s.dayOfMonth.wildcard:
+		return dayOfWeek
+	case s.dayOfWeek.wildcard:
+		return dayOfMonth
+	default:
+		return dayOfMonth || dayOfWeek
+	}
+}
+
+// next returns the first time the schedule runs after t, truncated to the
+// minute, or the zero time if it does not run within the next four years,
+// e.g. on February 30.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for i := 0; i < 4*366; i, day = i+1, day.AddDate(0, 0, 1) {
+		if !s.matchesDay(day) {
+			continue
+		}
+		for hour := 0; hour < 24; hour++ {
+			if !s.hour.values[hour] {
+				continue
+			}
+			for minute := 0; minute < 60; minute++ {
+				if !s.minute.values[minute] {
+					continue
+				}
+				run := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+				if !run.Before(t) {
+					return run
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// Validate checks the schedule, duration and time zone of the window.
+func (w MaintenanceWindow) Validate() error {
+	if _, err := parseCron(w.Schedule); err != nil {
+		return fmt.Errorf("schedule %q: %w", w.Schedule, err)
+	}
+	if w.Duration.Duration <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	if _, err := time.LoadLocation(w.TimeZone); err != nil {
+		return fmt.Errorf("timeZone %q: %w", w.TimeZone, err)
+	}
+	return nil
+}
+
+// Span returns the occurrence of the window open at now, or else the next
+// one, as its opening and closing times. It returns zero times if the
+// window is invalid or never opens.
+func (w MaintenanceWindow) Span(now time.Time) (time.Time, time.Time) {
+	schedule, err := parseCron(w.Schedule)
+	if err != nil {
+		return time.Time{}, time.Time{}
+	}
+	location, err := time.LoadLocation(w.TimeZone)
+	if err != nil {
+		return time.Time{}, time.Time{}
+	}
+	// The occurrence open at now started at most a duration ago
+	start := schedule.next(now.In(location).Add(-w.Duration.Duration - time.Minute))
+	for !start.IsZero() && !start.After(now) {
+		if end := start.Add(w.Duration.Duration); end.After(now) {
+			return start, end
+		}
+		start = schedule.next(start)
+	}
+	if start.IsZero() {
+		return time.Time{}, time.Time{}
+	}
+	return start, start.Add(w.Duration.Duration)
+}
+
+// MaintenanceAllowed returns whether disruptive operations on the server may
+// run at now: always without maintenance windows, within one of them
+// otherwise, or while the maintenance override annotation set by the CLI
+// has not expired. When they may not, it returns when the next window
+// opens, zero if none ever does.
+func (s *VPNServer) MaintenanceAllowed(now time.Time) (bool, time.Time) {
+	if len(s.Spec.MaintenanceWindows) == 0 {
+		return true, time.Time{}
+	}
+	if until, err := time.Parse(time.RFC3339, s.Annotations[MaintenanceOverrideAnnotation]); err == nil && now.Before(until) {
+		return true, time.Time{}
+	}
+	var next time.Time
+	for _, window := range s.Spec.MaintenanceWindows {
+		start, end := window.Span(now)
+		if start.IsZero() {
+			continue
+		}
+		if !now.Before(start) && now.Before(end) {
+			return true, time.Time{}
+		}
+		if next.IsZero() || start.Before(next) {
+			next = start
+		}
+	}
+	return false, next
+}
@@ -0,0 +1,77 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MemberClusterSpec defines the desired state of MemberCluster
+type MemberClusterSpec struct {
+	// KubeconfigSecretRef references a Secret holding a kubeconfig for the
+	// member cluster
+	KubeconfigSecretRef *SecretKeyReference `json:"kubeconfigSecretRef,omitempty"`
+
+	// ClusterRef references a Cluster API Cluster in the same namespace whose
+	// "<name>-kubeconfig" Secret is used to reach the member cluster
+	ClusterRef *LocalObjectReference `json:"clusterRef,omitempty"`
+
+	// Namespace is the namespace on the member cluster managed objects are
+	// created in. Defaults to the namespace of the MemberCluster.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// MemberClusterStatus defines the observed state of MemberCluster
+type MemberClusterStatus struct {
+	// ObservedGeneration is the generation of the spec the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	// KubernetesVersion is the version reported by the member API server
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+
+	// LastProbeTime is the last time the member cluster was contacted
+	LastProbeTime *metav1.Time `json:"lastProbeTime,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:categories=wireflow
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Version",type="string",JSONPath=".status.kubernetesVersion"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MemberCluster is the Schema for the memberclusters API. It registers a
+// cluster managed by an operator running in hub mode.
+type MemberCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MemberClusterSpec   `json:"spec,omitempty"`
+	Status MemberClusterStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MemberClusterList contains a list of MemberCluster
+type MemberClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MemberCluster `json:"items"`
+}
+
+// SecretKeyReference references a key of a Secret in the same namespace
+type SecretKeyReference struct {
+	Name string `json:"name"`
+	Key  string `json:"key,omitempty"`
+}
+
+// LocalObjectReference references an object in the same namespace
+type LocalObjectReference struct {
+	Name string `json:"name"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MemberCluster{}, &MemberClusterList{})
+}
@@ -0,0 +1,84 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultAccessRuleClaim is the identity claim matched by access rules that
+// do not name one
+const DefaultAccessRuleClaim = "groups"
+
+// VPNAccessPolicySpec defines the desired state of VPNAccessPolicy
+type VPNAccessPolicySpec struct {
+	// ServerRef references the VPNServer in the same namespace the policy
+	// applies to. The policy applies to every server of the namespace if
+	// empty.
+	ServerRef *LocalObjectReference `json:"serverRef,omitempty"`
+
+	// Rules map identity claims of peers to the networks they can reach
+	// +kubebuilder:validation:MinItems=1
+	Rules []AccessRule `json:"rules"`
+}
+
+// AccessRule grants networks to peers whose identity has a claim value
+type AccessRule struct {
+	// Claim is the identity claim matched, e.g. groups or department
+	// +kubebuilder:default=groups
+	// +optional
+	Claim string `json:"claim,omitempty"`
+
+	// Values are the claim values the rule matches. Any one matches.
+	// +kubebuilder:validation:MinItems=1
+	Values []string `json:"values"`
+
+	// AllowedIPs are the networks granted to matching peers
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:XValidation:rule="self.all(cidr, isCIDR(cidr))",message="allowedIPs must be networks, e.g. 10.20.0.0/16"
+	AllowedIPs []string `json:"allowedIPs"`
+}
+
+// VPNAccessPolicyStatus defines the observed state of VPNAccessPolicy
+type VPNAccessPolicyStatus struct {
+	// ObservedGeneration is the generation of the spec the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=vpnap,categories=wireflow
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.serverRef.name"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VPNAccessPolicy is the Schema for the vpnaccesspolicies API. It derives
+// the networks of peers enrolled with an identity from their claims, so
+// that access follows directory group changes when the identity is synced.
+type VPNAccessPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VPNAccessPolicySpec   `json:"spec,omitempty"`
+	Status VPNAccessPolicyStatus `json:"status,omitempty"`
+}
+
+// AppliesTo returns whether the policy applies to the server with the given
+// name.
+func (p *VPNAccessPolicy) AppliesTo(server string) bool {
+	return p.Spec.ServerRef == nil || p.Spec.ServerRef.Name == server
+}
+
+// +kubebuilder:object:root=true
+
+// VPNAccessPolicyList contains a list of VPNAccessPolicy
+type VPNAccessPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNAccessPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNAccessPolicy{}, &VPNAccessPolicyList{})
+}
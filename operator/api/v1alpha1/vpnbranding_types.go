@@ -0,0 +1,93 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VPNBrandingSpec defines the desired state of VPNBranding. Templates are Go
+// text/template templates rendered with the organization, the variables,
+// the peer and its server, and for invites the token, the enrollment URL
+// and the expiry.
+type VPNBrandingSpec struct {
+	// OrgName is the name of the organization shown to users
+	// +kubebuilder:validation:MinLength=1
+	OrgName string `json:"orgName"`
+
+	// SupportURL is where users get help, e.g. https://help.example.com or
+	// mailto:vpn@example.com
+	// +kubebuilder:validation:XValidation:rule="isURL(self)",message="supportURL must be a URL"
+	// +optional
+	SupportURL string `json:"supportURL,omitempty"`
+
+	// LogoURL is the logo shown on the enrollment pages
+	// +kubebuilder:validation:XValidation:rule="self.startsWith('https://') || self.startsWith('data:image/')",message="logoURL must be an https or data:image URL"
+	// +optional
+	LogoURL string `json:"logoURL,omitempty"`
+
+	// Variables are made available to the templates as .Variables
+	// +optional
+	Variables map[string]string `json:"variables,omitempty"`
+
+	// ConfigHeader is rendered as comments at the top of the client
+	// configs delivered to users
+	// +optional
+	ConfigHeader string `json:"configHeader,omitempty"`
+
+	// InviteEmail is the email sent to invitees of placeholder peers.
+	// Defaults to a plain invite naming the organization.
+	// +optional
+	InviteEmail *EmailTemplate `json:"inviteEmail,omitempty"`
+}
+
+// EmailTemplate defines the templates of an email
+type EmailTemplate struct {
+	// Subject is the template of the subject line
+	// +kubebuilder:validation:MinLength=1
+	Subject string `json:"subject"`
+
+	// Body is the template of the plain text body
+	// +kubebuilder:validation:MinLength=1
+	Body string `json:"body"`
+}
+
+// VPNBrandingStatus defines the observed state of VPNBranding
+type VPNBrandingStatus struct {
+	// ObservedGeneration is the generation of the spec the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations. Ready is
+	// False while a template does not parse.
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=vpnbrand,categories=wireflow
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Organization",type="string",JSONPath=".spec.orgName"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VPNBranding is the Schema for the vpnbrandings API. VPNServers reference
+// it to white-label the enrollment pages, invite emails and client configs
+// of their peers.
+type VPNBranding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VPNBrandingSpec   `json:"spec,omitempty"`
+	Status VPNBrandingStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VPNBrandingList contains a list of VPNBranding
+type VPNBrandingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNBranding `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNBranding{}, &VPNBrandingList{})
+}
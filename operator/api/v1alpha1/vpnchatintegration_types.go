@@ -0,0 +1,117 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ChatPlatformSlack handles Slack slash commands
+	ChatPlatformSlack = "slack"
+
+	// ChatPlatformTeams handles Microsoft Teams outgoing webhooks
+	ChatPlatformTeams = "teams"
+)
+
+// VPNChatIntegrationSpec defines the desired state of VPNChatIntegration
+// +kubebuilder:validation:XValidation:rule="self.platform != 'slack' || has(self.botTokenSecretRef)",message="botTokenSecretRef is required to send configs by direct message on Slack"
+type VPNChatIntegrationSpec struct {
+	// Platform is the chat platform the commands come from
+	// +kubebuilder:validation:Enum=slack;teams
+	Platform string `json:"platform"`
+
+	// SigningSecretRef references the secret requests are signed with: the
+	// signing secret of the Slack app, or the security token of the Teams
+	// outgoing webhook. The key defaults to signing-secret.
+	SigningSecretRef SecretKeyReference `json:"signingSecretRef"`
+
+	// BotTokenSecretRef references the Slack bot token configs are sent by
+	// direct message with. The key defaults to bot-token.
+	// +optional
+	BotTokenSecretRef *SecretKeyReference `json:"botTokenSecretRef,omitempty"`
+
+	// ServerRef references the VPNServer in the same namespace devices are
+	// created on
+	ServerRef LocalObjectReference `json:"serverRef"`
+
+	// Group is the peer group of the devices created
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Members are the chat users allowed to manage devices. Their claims
+	// are the identity of their devices, so that VPNAccessPolicies grant
+	// them networks.
+	// +kubebuilder:validation:MinItems=1
+	Members []ChatMember `json:"members"`
+
+	// MaxDevicesPerUser is the number of devices a member can own
+	// +kubebuilder:default=3
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxDevicesPerUser int32 `json:"maxDevicesPerUser,omitempty"`
+}
+
+// ChatMember maps a chat user to the identity of their devices
+type ChatMember struct {
+	// User is the chat user ID, e.g. U024BE7LH on Slack or the Azure AD
+	// object ID on Teams
+	User string `json:"user"`
+
+	// Claims are the identity claims of the member, e.g. groups
+	Claims map[string][]string `json:"claims,omitempty"`
+}
+
+// VPNChatIntegrationStatus defines the observed state of VPNChatIntegration
+type VPNChatIntegrationStatus struct {
+	// ObservedGeneration is the generation of the spec the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Path is the path of the operator's chat endpoint the commands must be
+	// sent to
+	Path string `json:"path,omitempty"`
+
+	// Conditions represent the latest available observations
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=vpnchat,categories=wireflow
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Platform",type="string",JSONPath=".spec.platform"
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.serverRef.name"
+// +kubebuilder:printcolumn:name="Path",type="string",JSONPath=".status.path"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VPNChatIntegration is the Schema for the vpnchatintegrations API. It lets
+// members manage their own devices with the /vpn new-device, /vpn revoke
+// and /vpn status chat commands.
+type VPNChatIntegration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VPNChatIntegrationSpec   `json:"spec,omitempty"`
+	Status VPNChatIntegrationStatus `json:"status,omitempty"`
+}
+
+// Member returns the member with the given chat user ID, or nil.
+func (c *VPNChatIntegration) Member(user string) *ChatMember {
+	for i := range c.Spec.Members {
+		if c.Spec.Members[i].User == user {
+			return &c.Spec.Members[i]
+		}
+	}
+	return nil
+}
+
+// +kubebuilder:object:root=true
+
+// VPNChatIntegrationList contains a list of VPNChatIntegration
+type VPNChatIntegrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNChatIntegration `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNChatIntegration{}, &VPNChatIntegrationList{})
+}
@@ -0,0 +1,173 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConnectivityCheckMode is how long a VPNConnectivityCheck probes its server
+// +kubebuilder:validation:Enum=Once;Soak
+type ConnectivityCheckMode string
+
+const (
+	// ConnectivityCheckOnce takes a single sample and removes the probe
+	ConnectivityCheckOnce ConnectivityCheckMode = "Once"
+
+	// ConnectivityCheckSoak keeps the probe connected and samples it on an
+	// interval until the check is deleted
+	ConnectivityCheckSoak ConnectivityCheckMode = "Soak"
+)
+
+// VPNConnectivityCheckSpec defines the desired state of VPNConnectivityCheck
+type VPNConnectivityCheckSpec struct {
+	// ServerRef references the VPNServer checked, in the namespace of the
+	// check
+	ServerRef LocalObjectReference `json:"serverRef"`
+
+	// Mode is Once to take a single sample, or Soak to keep the probe peer
+	// connected and sample it every soak.interval
+	// +kubebuilder:default=Once
+	// +optional
+	Mode ConnectivityCheckMode `json:"mode,omitempty"`
+
+	// Pings is the number of pings of a sample. Its loss is the share of
+	// them left unanswered.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:default=10
+	// +optional
+	Pings int32 `json:"pings,omitempty"`
+
+	// MaxLatency is the average round-trip time above which a sample is
+	// degraded
+	// +kubebuilder:default="200ms"
+	// +optional
+	MaxLatency *metav1.Duration `json:"maxLatency,omitempty"`
+
+	// MaxLossPercent is the loss above which a sample is degraded
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:default=5
+	// +optional
+	MaxLossPercent *int32 `json:"maxLossPercent,omitempty"`
+
+	// Soak configures the sampling of the Soak mode
+	// +optional
+	Soak *ConnectivitySoakSpec `json:"soak,omitempty"`
+}
+
+// ConnectivitySoakSpec configures the sampling of a soaking
+// VPNConnectivityCheck
+// +kubebuilder:validation:XValidation:rule="self.threshold <= self.window",message="the threshold cannot exceed the window"
+type ConnectivitySoakSpec struct {
+	// Interval is the time between two samples
+	// +kubebuilder:default="30s"
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// Window is the number of most recent samples kept in the status
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=500
+	// +kubebuilder:default=60
+	// +optional
+	Window int32 `json:"window,omitempty"`
+
+	// Threshold is the number of consecutive degraded samples after which
+	// the check is degraded, and of healthy samples after which it
+	// recovers, so that a single failed sample does not flip it
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=3
+	// +optional
+	Threshold int32 `json:"threshold,omitempty"`
+}
+
+// VPNConnectivityCheckStatus defines the observed state of
+// VPNConnectivityCheck
+type VPNConnectivityCheckStatus struct {
+	// ObservedGeneration is the generation of the spec the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ProbePeer is the name of the VPNPeer the probe connects as
+	// +optional
+	ProbePeer string `json:"probePeer,omitempty"`
+
+	// Samples is the rolling window of the most recent samples, oldest
+	// first
+	// +optional
+	Samples []ConnectivitySample `json:"samples,omitempty"`
+
+	// AverageLatency is the average latency of the samples of the window
+	// that got an answer
+	// +optional
+	AverageLatency *metav1.Duration `json:"averageLatency,omitempty"`
+
+	// AverageLossPercent is the average loss of the samples of the window
+	// +optional
+	AverageLossPercent int32 `json:"averageLossPercent,omitempty"`
+
+	// Streak is the number of consecutive most recent samples that are all
+	// degraded, or all healthy
+	// +optional
+	Streak int32 `json:"streak,omitempty"`
+
+	// CompletionTime is when a check of the Once mode took its sample
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Conditions represent the latest available observations
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// ConnectivitySample is a sample of the latency and loss between the probe
+// peer of a VPNConnectivityCheck and its server
+type ConnectivitySample struct {
+	// Time is when the sample was taken
+	Time metav1.Time `json:"time"`
+
+	// Latency is the average round-trip time of the answered pings, unset
+	// if none was answered
+	// +optional
+	Latency *metav1.Duration `json:"latency,omitempty"`
+
+	// LossPercent is the share of the pings left unanswered
+	LossPercent int32 `json:"lossPercent"`
+
+	// Degraded is whether the sample breaches the latency or loss
+	// thresholds
+	// +optional
+	Degraded bool `json:"degraded,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=vpncheck,categories=wireflow
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.serverRef.name"
+// +kubebuilder:printcolumn:name="Mode",type="string",JSONPath=".spec.mode"
+// +kubebuilder:printcolumn:name="Latency",type="string",JSONPath=".status.averageLatency"
+// +kubebuilder:printcolumn:name="Loss",type="integer",JSONPath=".status.averageLossPercent"
+// +kubebuilder:printcolumn:name="Degraded",type="string",JSONPath=".status.conditions[?(@.type==\"Degraded\")].status"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VPNConnectivityCheck is the Schema for the vpnconnectivitychecks API. It
+// connects a probe peer to a VPNServer and samples the latency and loss of
+// its tunnel, once or continuously.
+type VPNConnectivityCheck struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VPNConnectivityCheckSpec   `json:"spec,omitempty"`
+	Status VPNConnectivityCheckStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VPNConnectivityCheckList contains a list of VPNConnectivityCheck
+type VPNConnectivityCheckList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNConnectivityCheck `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNConnectivityCheck{}, &VPNConnectivityCheckList{})
+}
@@ -0,0 +1,111 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VPNGlobalPolicySpec defines the guardrails of VPNGlobalPolicy
+type VPNGlobalPolicySpec struct {
+	// ForbiddenAllowedIPs are networks no allowedIPs of servers, routing
+	// profiles or peers may cover, e.g. 10.0.0.0/8 forbids 10.0.0.0/8
+	// itself and its supersets such as 0.0.0.0/0, but not 10.1.0.0/16
+	// +kubebuilder:validation:XValidation:rule="self.all(cidr, isCIDR(cidr))",message="forbiddenAllowedIPs must be networks, e.g. 10.0.0.0/8"
+	// +listType=set
+	// +optional
+	ForbiddenAllowedIPs []string `json:"forbiddenAllowedIPs,omitempty"`
+
+	// MaxKeyRotationInterval requires servers to rotate their keys at
+	// least this often with spec.keyRotation
+	// +optional
+	MaxKeyRotationInterval *metav1.Duration `json:"maxKeyRotationInterval,omitempty"`
+
+	// RequirePresharedKeys requires peers to have a preshared key, either
+	// referenced or generated
+	// +optional
+	RequirePresharedKeys bool `json:"requirePresharedKeys,omitempty"`
+
+	// Exposure limits the Service types of the servers of the namespaces
+	// matched by each rule. A server must satisfy every rule matching its
+	// namespace.
+	// +optional
+	Exposure []ExposureRule `json:"exposure,omitempty"`
+}
+
+// ExposureRule limits the Service types of servers by namespace
+type ExposureRule struct {
+	// NamespaceSelector selects the namespaces of the rule. An empty
+	// selector selects every namespace.
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector"`
+
+	// AllowedTypes are the Service types the servers may use
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:items:Enum=LoadBalancer;NodePort;ClusterIP
+	// +listType=set
+	AllowedTypes []corev1.ServiceType `json:"allowedTypes"`
+}
+
+// VPNGlobalPolicyStatus defines the observed state of VPNGlobalPolicy
+type VPNGlobalPolicyStatus struct {
+	// ObservedGeneration is the generation of the spec the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ViolationCount is the number of resources violating the policy
+	ViolationCount int32 `json:"violationCount,omitempty"`
+
+	// Violations are the resources admitted before the policy, or before
+	// a change of it, that violate it. Only the first 100 are listed.
+	Violations []PolicyViolation `json:"violations,omitempty"`
+
+	// Conditions represent the latest available observations
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// PolicyViolation is a resource violating a VPNGlobalPolicy
+type PolicyViolation struct {
+	// Kind is the kind of the resource, VPNServer or VPNPeer
+	Kind string `json:"kind"`
+
+	// Namespace is the namespace of the resource
+	Namespace string `json:"namespace"`
+
+	// Name is the name of the resource
+	Name string `json:"name"`
+
+	// Message tells how the resource violates the policy
+	Message string `json:"message"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=vpngp,categories=wireflow
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Violations",type="integer",JSONPath=".status.violationCount"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VPNGlobalPolicy is the Schema for the vpnglobalpolicies API. It holds
+// organization-wide guardrails, owned by security, that the servers and
+// peers of every namespace must satisfy. The admission webhook rejects
+// violations, and the resources admitted before a policy or a change of it
+// are reported in its status, with an event on each resource.
+type VPNGlobalPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VPNGlobalPolicySpec   `json:"spec,omitempty"`
+	Status VPNGlobalPolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VPNGlobalPolicyList contains a list of VPNGlobalPolicy
+type VPNGlobalPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNGlobalPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNGlobalPolicy{}, &VPNGlobalPolicyList{})
+}
@@ -0,0 +1,120 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VPNIngressMapSpec defines the desired state of VPNIngressMap
+type VPNIngressMapSpec struct {
+	// ServerRef references the VPNServer in the same namespace whose agent
+	// forwards the traffic
+	ServerRef LocalObjectReference `json:"serverRef"`
+
+	// Direction selects which side is published to the other. FromPeer
+	// exposes a port of a peer as a cluster Service, ToPeers publishes a
+	// cluster Service on the tunnel address of the server.
+	// +kubebuilder:validation:Enum=FromPeer;ToPeers
+	// +kubebuilder:default=FromPeer
+	// +optional
+	Direction IngressMapDirection `json:"direction,omitempty"`
+
+	// Protocol is the protocol forwarded
+	// +kubebuilder:validation:Enum=TCP;UDP
+	// +kubebuilder:default=TCP
+	// +optional
+	Protocol IngressMapProtocol `json:"protocol,omitempty"`
+
+	// PeerRef references the VPNPeer exposed with FromPeer
+	PeerRef *LocalObjectReference `json:"peerRef,omitempty"`
+
+	// TunnelPort is the port on the tunnel side: the port of the peer with
+	// FromPeer, the port on the tunnel address of the server with ToPeers
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	TunnelPort int32 `json:"tunnelPort"`
+
+	// Service is the cluster side: the Service created for the peer with
+	// FromPeer, the Service published to peers with ToPeers
+	Service IngressMapService `json:"service"`
+}
+
+// IngressMapService is the cluster Service of an ingress map
+type IngressMapService struct {
+	// Name is the name of the Service in the namespace of the map
+	Name string `json:"name"`
+
+	// Port is the port of the Service
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+}
+
+// IngressMapDirection is which side of an ingress map is published
+type IngressMapDirection string
+
+const (
+	// IngressMapFromPeer exposes a port of a peer as a cluster Service
+	IngressMapFromPeer IngressMapDirection = "FromPeer"
+
+	// IngressMapToPeers publishes a cluster Service to the peers of a server
+	IngressMapToPeers IngressMapDirection = "ToPeers"
+)
+
+// IngressMapProtocol is the protocol forwarded by an ingress map
+type IngressMapProtocol string
+
+const (
+	// IngressMapTCP forwards TCP
+	IngressMapTCP IngressMapProtocol = "TCP"
+
+	// IngressMapUDP forwards UDP
+	IngressMapUDP IngressMapProtocol = "UDP"
+)
+
+// VPNIngressMapStatus defines the observed state of VPNIngressMap
+type VPNIngressMapStatus struct {
+	// ObservedGeneration is the generation of the spec the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Target is the address traffic is forwarded to: the tunnel address of
+	// the peer with FromPeer, the cluster IP of the Service with ToPeers
+	Target string `json:"target,omitempty"`
+
+	// Conditions represent the latest available observations
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=vpnim,categories=wireflow
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.serverRef.name"
+// +kubebuilder:printcolumn:name="Direction",type="string",JSONPath=".spec.direction"
+// +kubebuilder:printcolumn:name="Service",type="string",JSONPath=".spec.service.name"
+// +kubebuilder:printcolumn:name="Target",type="string",JSONPath=".status.target"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VPNIngressMap is the Schema for the vpningressmaps API. It maps a port of
+// a peer to a cluster Service or a cluster Service to the peers of a server,
+// with the agent of the server programming the DNAT, so that a device at a
+// remote site can be reached as a stable in-cluster Service.
+type VPNIngressMap struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VPNIngressMapSpec   `json:"spec,omitempty"`
+	Status VPNIngressMapStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VPNIngressMapList contains a list of VPNIngressMap
+type VPNIngressMapList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNIngressMap `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNIngressMap{}, &VPNIngressMapList{})
+}
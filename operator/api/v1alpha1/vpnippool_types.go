@@ -0,0 +1,105 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VPNIPPoolSpec defines the desired state of VPNIPPool
+type VPNIPPoolSpec struct {
+	// CIDR is the primary network addresses are allocated from
+	// +kubebuilder:validation:XValidation:rule="isCIDR(self)",message="cidr must be a network, e.g. 10.9.0.0/16"
+	CIDR string `json:"cidr"`
+
+	// SecondaryCIDRs are networks the pool expands to, in order, when it is
+	// nearly exhausted and Exhaustion.AutoExpand is set
+	// +kubebuilder:validation:XValidation:rule="self.all(cidr, isCIDR(cidr))",message="secondaryCIDRs must be networks"
+	SecondaryCIDRs []string `json:"secondaryCIDRs,omitempty"`
+
+	// Exhaustion configures exhaustion prediction
+	Exhaustion *ExhaustionSpec `json:"exhaustion,omitempty"`
+}
+
+// ExhaustionSpec configures the exhaustion prediction of a pool
+type ExhaustionSpec struct {
+	// ThresholdDays is the predicted number of days until exhaustion at or
+	// below which the pool is nearly exhausted
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=14
+	// +optional
+	ThresholdDays int32 `json:"thresholdDays,omitempty"`
+
+	// Window is how far back allocations are considered for the allocation
+	// rate. Defaults to 7 days.
+	Window *metav1.Duration `json:"window,omitempty"`
+
+	// AutoExpand adds the next secondary CIDR to the pool when it is nearly
+	// exhausted
+	AutoExpand bool `json:"autoExpand,omitempty"`
+}
+
+// VPNIPPoolStatus defines the observed state of VPNIPPool
+type VPNIPPoolStatus struct {
+	// ObservedGeneration is the generation of the spec the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// CIDRs are the networks addresses are allocated from: the primary CIDR
+	// followed by the secondary CIDRs the pool expanded to
+	CIDRs []string `json:"cidrs,omitempty"`
+
+	// Capacity is the number of allocatable addresses
+	Capacity int64 `json:"capacity,omitempty"`
+
+	// Allocated is the number of allocated addresses
+	Allocated int64 `json:"allocated,omitempty"`
+
+	// AllocationsPerDay is the allocation rate over the prediction window
+	AllocationsPerDay string `json:"allocationsPerDay,omitempty"`
+
+	// DaysUntilExhaustion is the predicted number of days until no address
+	// is left. It is unset while allocations do not grow.
+	DaysUntilExhaustion *int32 `json:"daysUntilExhaustion,omitempty"`
+
+	// History holds hourly allocation samples over the prediction window
+	History []AllocationSample `json:"history,omitempty"`
+
+	// Conditions represent the latest available observations
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// AllocationSample is the number of allocated addresses at a point in time
+type AllocationSample struct {
+	Time      metav1.Time `json:"time"`
+	Allocated int64       `json:"allocated"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=vpnip,categories=wireflow
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="CIDR",type="string",JSONPath=".spec.cidr"
+// +kubebuilder:printcolumn:name="Allocated",type="integer",JSONPath=".status.allocated"
+// +kubebuilder:printcolumn:name="Capacity",type="integer",JSONPath=".status.capacity"
+// +kubebuilder:printcolumn:name="Days Left",type="integer",JSONPath=".status.daysUntilExhaustion"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VPNIPPool is the Schema for the vpnippools API
+type VPNIPPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VPNIPPoolSpec   `json:"spec,omitempty"`
+	Status VPNIPPoolStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VPNIPPoolList contains a list of VPNIPPool
+type VPNIPPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNIPPool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNIPPool{}, &VPNIPPoolList{})
+}
@@ -0,0 +1,59 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VPNKeyDenyListSpec defines the public keys denied by VPNKeyDenyList
+type VPNKeyDenyListSpec struct {
+	// Keys are the denied public keys
+	// +listType=map
+	// +listMapKey=publicKey
+	// +kubebuilder:validation:MinItems=1
+	Keys []DeniedKey `json:"keys"`
+}
+
+// DeniedKey is a public key no peer may enroll
+type DeniedKey struct {
+	// PublicKey is the denied WireGuard public key
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9+/]{42}[AEIMQUYcgkosw048]=$`
+	PublicKey string `json:"publicKey"`
+
+	// Reason tells why the key is denied, e.g. the incident it leaked in.
+	// It is included in the rejections and audit events.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=vpnkdl,categories=wireflow
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VPNKeyDenyList is the Schema for the vpnkeydenylists API. It lists public
+// keys that were compromised or revoked, so that they are never enrolled
+// again on any server of the cluster: the admission webhook rejects peers
+// taking a denied key and the enrollment endpoint refuses invitees
+// submitting one. Each attempt is recorded as a KeyDenied event on the
+// list. Peers holding a key when it is denied are not changed, they are
+// revoked separately.
+type VPNKeyDenyList struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec VPNKeyDenyListSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VPNKeyDenyListList contains a list of VPNKeyDenyList
+type VPNKeyDenyListList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNKeyDenyList `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNKeyDenyList{}, &VPNKeyDenyListList{})
+}
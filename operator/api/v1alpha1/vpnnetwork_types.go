@@ -0,0 +1,203 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NetworkTopology is how the servers of a VPNNetwork are linked
+// +kubebuilder:validation:Enum=FullMesh;HubAndSpoke
+type NetworkTopology string
+
+const (
+	// NetworkTopologyFullMesh links every server to every other server
+	NetworkTopologyFullMesh NetworkTopology = "FullMesh"
+
+	// NetworkTopologyHubAndSpoke links every server to the hub only. The
+	// hub routes the traffic between spokes.
+	NetworkTopologyHubAndSpoke NetworkTopology = "HubAndSpoke"
+)
+
+// VPNNetworkSpec defines the desired state of VPNNetwork
+// +kubebuilder:validation:XValidation:rule="self.topology != 'HubAndSpoke' || has(self.hub)",message="hub is required with the HubAndSpoke topology"
+// +kubebuilder:validation:XValidation:rule="!has(self.hub) || self.members.exists(m, m.serverRef.name == self.hub)",message="hub must be one of the members"
+type VPNNetworkSpec struct {
+	// Topology is how the member servers are linked
+	// +kubebuilder:default=FullMesh
+	// +optional
+	Topology NetworkTopology `json:"topology,omitempty"`
+
+	// Hub is the name of the member server the others link to with the
+	// HubAndSpoke topology
+	// +optional
+	Hub string `json:"hub,omitempty"`
+
+	// Members are the servers of the network. Servers placed on a member
+	// cluster through spec.clusterName are linked across clusters.
+	// +kubebuilder:validation:MinItems=2
+	// +kubebuilder:validation:MaxItems=64
+	// +kubebuilder:validation:XValidation:rule="self.all(m, self.exists_one(o, o.serverRef.name == m.serverRef.name))",message="a server can only be a member once"
+	Members []VPNNetworkMember `json:"members"`
+
+	// PersistentKeepalive is the keepalive interval in seconds of the
+	// links, keeping them open through NAT
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=25
+	// +optional
+	PersistentKeepalive int32 `json:"persistentKeepalive,omitempty"`
+}
+
+// VPNNetworkMember is a server of a VPNNetwork
+type VPNNetworkMember struct {
+	// ServerRef references the VPNServer, in the namespace of the network
+	ServerRef LocalObjectReference `json:"serverRef"`
+
+	// Sites are the networks behind the server, e.g. the pod and service
+	// networks of its cluster, routed to it by the other members along
+	// with its tunnel network
+	// +kubebuilder:validation:XValidation:rule="self.all(cidr, isCIDR(cidr))",message="sites must be networks, e.g. 10.244.0.0/16"
+	// +optional
+	Sites []string `json:"sites,omitempty"`
+}
+
+// VPNNetworkStatus defines the observed state of VPNNetwork
+type VPNNetworkStatus struct {
+	// ObservedGeneration is the generation of the spec the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Members is the state of the member servers
+	// +optional
+	Members []VPNNetworkMemberStatus `json:"members,omitempty"`
+
+	// Links is the number of links between the members, each made of a
+	// VPNPeer on both of its servers
+	Links int32 `json:"links,omitempty"`
+
+	// EstablishedLinks is the number of links whose servers both
+	// handshook with each other recently
+	EstablishedLinks int32 `json:"establishedLinks"`
+
+	// LinkStatuses is the state of the links between the members
+	// +optional
+	LinkStatuses []VPNNetworkLinkStatus `json:"linkStatuses,omitempty"`
+
+	// Change is the change of the links being verified. It is committed
+	// once every changed link handshook in both directions, and rolled
+	// back as a whole otherwise.
+	// +optional
+	Change *VPNNetworkChange `json:"change,omitempty"`
+
+	// RolledBack is the last change rolled back. It is applied again once
+	// the network or its servers change, or after the retry interval.
+	// +optional
+	RolledBack *VPNNetworkChange `json:"rolledBack,omitempty"`
+
+	// Conditions represent the latest available observations
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// VPNNetworkLinkStatus is the state of a link of a VPNNetwork
+type VPNNetworkLinkStatus struct {
+	// Name is the pair of servers of the link, e.g. east/west
+	Name string `json:"name"`
+
+	// Established is whether both servers handshook with each other
+	// recently
+	Established bool `json:"established"`
+
+	// LatestHandshake is the older of the latest handshakes of both
+	// servers with each other
+	// +optional
+	LatestHandshake *metav1.Time `json:"latestHandshake,omitempty"`
+
+	// RoundTripTime is the round-trip time of the link, estimated by its
+	// servers and averaged over both of them
+	// +optional
+	RoundTripTime *metav1.Duration `json:"roundTripTime,omitempty"`
+}
+
+// VPNNetworkChange is a change of the link peers of a VPNNetwork, applied
+// to every member at once
+type VPNNetworkChange struct {
+	// Checksum identifies the link peers the change applies
+	Checksum string `json:"checksum"`
+
+	// Time is when the change was applied, or rolled back
+	Time metav1.Time `json:"time"`
+
+	// PendingLinks are the changed links that did not handshake since the
+	// change, as pairs of servers, e.g. east/west
+	// +optional
+	PendingLinks []string `json:"pendingLinks,omitempty"`
+
+	// Message tells why the change was rolled back
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// VPNNetworkMemberStatus is the state of a member server of a VPNNetwork
+type VPNNetworkMemberStatus struct {
+	// Server is the name of the VPNServer
+	Server string `json:"server"`
+
+	// ClusterName is the member cluster the server runs on, empty for the
+	// cluster of the network
+	// +optional
+	ClusterName string `json:"clusterName,omitempty"`
+
+	// PublicKey is the public key of the server, published to the other
+	// members
+	// +optional
+	PublicKey string `json:"publicKey,omitempty"`
+
+	// Endpoint is the endpoint the other members connect to
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Networks are the tunnel network and sites routed to the server
+	// +optional
+	Networks []string `json:"networks,omitempty"`
+
+	// Ready is whether the links of the server are in place
+	Ready bool `json:"ready"`
+
+	// Message tells why the server is not ready
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=vpnnet,categories=wireflow
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Topology",type="string",JSONPath=".spec.topology"
+// +kubebuilder:printcolumn:name="Hub",type="string",JSONPath=".spec.hub",priority=1
+// +kubebuilder:printcolumn:name="Links",type="integer",JSONPath=".status.links"
+// +kubebuilder:printcolumn:name="Established",type="integer",JSONPath=".status.establishedLinks"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VPNNetwork is the Schema for the vpnnetworks API. It links VPNServers,
+// possibly on different clusters, into a site-to-site network, exchanging
+// their public keys, endpoints and networks through a VPNPeer on each side
+// of every link.
+type VPNNetwork struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VPNNetworkSpec   `json:"spec,omitempty"`
+	Status VPNNetworkStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VPNNetworkList contains a list of VPNNetwork
+type VPNNetworkList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNNetwork `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNNetwork{}, &VPNNetworkList{})
+}
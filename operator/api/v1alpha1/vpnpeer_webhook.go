@@ -0,0 +1,294 @@
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var vpnpeerlog = logf.Log.WithName("vpnpeer-resource")
+
+// SetupWebhookWithManager registers the VPNPeer webhooks. When
+// allowMissingRefs is true, peers referencing objects that do not exist yet
+// are admitted with a warning and stay Pending until the references resolve.
+func (r *VPNPeer) SetupWebhookWithManager(mgr ctrl.Manager, allowMissingRefs bool) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithDefaulter(&vpnPeerDefaulter{}).
+		WithValidator(&vpnPeerValidator{
+			Client:           mgr.GetClient(),
+			Recorder:         mgr.GetEventRecorderFor("vpn-operator"),
+			allowMissingRefs: allowMissingRefs,
+		}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-vpn-vpn-devops-com-v1alpha1-vpnpeer,mutating=true,failurePolicy=fail,sideEffects=None,groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=create;update,versions=v1alpha1,name=mvpnpeer.kb.io,admissionReviewVersions=v1
+
+// vpnPeerDefaulter normalizes VPNPeers before their schema is validated
+type vpnPeerDefaulter struct{}
+
+var _ admission.CustomDefaulter = &vpnPeerDefaulter{}
+
+// Default implements admission.CustomDefaulter. It strips the whitespace
+// pasted along with the public key.
+func (d *vpnPeerDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	peer := obj.(*VPNPeer)
+	peer.Spec.PublicKey = NormalizeKey(peer.Spec.PublicKey)
+	return nil
+}
+
+//+kubebuilder:webhook:path=/validate-vpn-vpn-devops-com-v1alpha1-vpnpeer,mutating=false,failurePolicy=fail,sideEffects=None,groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=create;update;delete,versions=v1alpha1,name=vvpnpeer.kb.io,admissionReviewVersions=v1
+
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnkeydenylists,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// vpnPeerValidator validates VPNPeers
+type vpnPeerValidator struct {
+	client.Client
+
+	// Recorder records the attempts to enroll denied keys
+	Recorder record.EventRecorder
+
+	allowMissingRefs bool
+}
+
+var _ admission.CustomValidator = &vpnPeerValidator{}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *vpnPeerValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	peer := obj.(*VPNPeer)
+	vpnpeerlog.Info("validate create", "name", peer.Name)
+
+	if err := v.validateDelegation(ctx, peer); err != nil {
+		return nil, err
+	}
+	if err := v.validateGlobalPolicies(ctx, peer); err != nil {
+		return nil, err
+	}
+	if err := v.validateDeniedKey(ctx, peer); err != nil {
+		return nil, err
+	}
+	return v.validateKeysAndReferences(ctx, peer)
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *vpnPeerValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	peer := newObj.(*VPNPeer)
+	vpnpeerlog.Info("validate update", "name", peer.Name)
+
+	// Moving a peer requires the delegation of both servers
+	if err := v.validateDelegation(ctx, oldObj.(*VPNPeer)); err != nil {
+		return nil, err
+	}
+	if err := v.validateDelegation(ctx, peer); err != nil {
+		return nil, err
+	}
+	if !peer.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	if err := v.validateGlobalPolicies(ctx, peer); err != nil {
+		return nil, err
+	}
+	// Peers denied after they enrolled are still updated, e.g. revoked
+	if NormalizeKey(oldObj.(*VPNPeer).Spec.PublicKey) != NormalizeKey(peer.Spec.PublicKey) {
+		if err := v.validateDeniedKey(ctx, peer); err != nil {
+			return nil, err
+		}
+	}
+	return v.validateKeysAndReferences(ctx, peer)
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *vpnPeerValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validateDelegation(ctx, obj.(*VPNPeer))
+}
+
+// validateDelegation limits members of groups delegated the peers of a
+// server in the namespace to the peers of servers they may manage. RBAC
+// cannot tell peers apart by their server, so the Role generated for a
+// delegation grants all VPNPeers of the namespace and the manage-peers verb
+// on the server, which is checked here. Users outside delegated groups are
+// left to RBAC alone.
+func (v *vpnPeerValidator) validateDelegation(ctx context.Context, peer *VPNPeer) error {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil
+	}
+	user := req.UserInfo
+	servers := &VPNServerList{}
+	if err := v.List(ctx, servers, client.InNamespace(peer.Namespace)); err != nil {
+		return err
+	}
+	if !inDelegatedGroup(servers.Items, user.Groups) {
+		return nil
+	}
+
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for key, values := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(values)
+	}
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: peer.Namespace,
+				Verb:      ManagePeersVerb,
+				Group:     GroupVersion.Group,
+				Resource:  "vpnservers",
+				Name:      peer.Spec.ServerRef.Name,
+			},
+		},
+	}
+	if err := v.Create(ctx, review); err != nil {
+		return err
+	}
+	if !review.Status.Allowed {
+		return fmt.Errorf("%s may only manage peers of VPNServers delegated to its groups, not of %q", user.Username, peer.Spec.ServerRef.Name)
+	}
+	return nil
+}
+
+// inDelegatedGroup returns whether one of groups is delegated the peers of
+// one of servers.
+func inDelegatedGroup(servers []VPNServer, groups []string) bool {
+	for _, server := range servers {
+		if server.Spec.PeerDelegation == nil {
+			continue
+		}
+		for _, delegated := range server.Spec.PeerDelegation.Groups {
+			for _, group := range groups {
+				if group == delegated {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// validateGlobalPolicies rejects peers violating a VPNGlobalPolicy.
+func (v *vpnPeerValidator) validateGlobalPolicies(ctx context.Context, peer *VPNPeer) error {
+	policies := &VPNGlobalPolicyList{}
+	if err := v.List(ctx, policies); err != nil {
+		return err
+	}
+	var violations []string
+	for i := range policies.Items {
+		violations = append(violations, policies.Items[i].PeerViolations(peer)...)
+	}
+	if len(violations) > 0 {
+		return errors.New(strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// validateDeniedKey rejects peers taking a public key of a VPNKeyDenyList,
+// recording the attempt on the list.
+func (v *vpnPeerValidator) validateDeniedKey(ctx context.Context, peer *VPNPeer) error {
+	list, denied, err := FindDeniedKey(ctx, v, peer.Spec.PublicKey)
+	if err != nil || denied == nil {
+		return err
+	}
+	user := "unknown"
+	if req, err := admission.RequestFromContext(ctx); err == nil {
+		user = req.UserInfo.Username
+	}
+	v.Recorder.Eventf(list, corev1.EventTypeWarning, "KeyDenied", "Rejected the denied public key %s on VPNPeer %s/%s, submitted by %s",
+		denied.PublicKey, peer.Namespace, peer.Name, user)
+	return fmt.Errorf("spec.publicKey: %s", denied.DenialMessage())
+}
+
+// validateKeysAndReferences checks the keys of the peer and that the objects
+// it references exist.
+func (v *vpnPeerValidator) validateKeysAndReferences(ctx context.Context, peer *VPNPeer) (admission.Warnings, error) {
+	keyWarnings, err := v.validateKeys(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	warnings, err := v.validateReferences(ctx, peer)
+	return append(keyWarnings, warnings...), err
+}
+
+// validateKeys checks that the public key of the peer is one, and that its
+// preshared key is well-formed once its Secret exists.
+func (v *vpnPeerValidator) validateKeys(ctx context.Context, peer *VPNPeer) (admission.Warnings, error) {
+	var warnings admission.Warnings
+	if key := peer.Spec.PublicKey; key != "" {
+		warning, err := ValidatePublicKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("spec.publicKey is %w", err)
+		}
+		if warning != "" {
+			warnings = append(warnings, "spec.publicKey: "+warning)
+		}
+	}
+
+	ref := peer.Spec.PresharedKeySecretRef
+	if ref == nil {
+		return warnings, nil
+	}
+	secret := &corev1.Secret{}
+	if err := v.Get(ctx, types.NamespacedName{Namespace: peer.Namespace, Name: ref.Name}, secret); err != nil {
+		// A Secret created after the peer is checked by the controller
+		return warnings, client.IgnoreNotFound(err)
+	}
+	dataKey := ref.Key
+	if dataKey == "" {
+		dataKey = DefaultPresharedKeyKey
+	}
+	psk, ok := secret.Data[dataKey]
+	if !ok {
+		return nil, fmt.Errorf("Secret %q has no preshared key under %q", ref.Name, dataKey)
+	}
+	if _, err := ParseWireGuardKey(NormalizeKey(string(psk))); err != nil {
+		return nil, fmt.Errorf("the preshared key in Secret %q is %w", ref.Name, err)
+	}
+	return warnings, nil
+}
+
+// validateReferences checks that the objects referenced by the peer exist.
+func (v *vpnPeerValidator) validateReferences(ctx context.Context, peer *VPNPeer) (admission.Warnings, error) {
+	var missing []string
+	server := &VPNServer{}
+	if err := v.Get(ctx, types.NamespacedName{Namespace: peer.Namespace, Name: peer.Spec.ServerRef.Name}, server); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		missing = append(missing, fmt.Sprintf("VPNServer %q", peer.Spec.ServerRef.Name))
+	} else if profile := peer.Spec.RoutingProfile; profile != "" && server.Spec.RoutingProfile(profile) == nil {
+		missing = append(missing, fmt.Sprintf("routing profile %q of VPNServer %q", profile, server.Name))
+	} else if port := peer.Spec.EndpointPort; port != 0 && !server.Spec.ListensOn(port) {
+		missing = append(missing, fmt.Sprintf("listen port %d of VPNServer %q", port, server.Name))
+	}
+
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	if v.allowMissingRefs {
+		warnings := make(admission.Warnings, 0, len(missing))
+		for _, ref := range missing {
+			warnings = append(warnings, fmt.Sprintf("%s does not exist, the peer stays Pending until it is created", ref))
+		}
+		return warnings, nil
+	}
+	return nil, fmt.Errorf("referenced %s does not exist", missing[0])
+}
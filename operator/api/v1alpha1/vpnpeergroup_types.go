@@ -0,0 +1,88 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VPNPeerGroupSpec defines the desired state of VPNPeerGroup
+type VPNPeerGroupSpec struct {
+	// ConfigLayer holds the settings of the peers of the group unless they
+	// override them
+	ConfigLayer `json:",inline"`
+
+	// Tunnel sets the networks the clients of the group route through the
+	// tunnel: all their traffic in full tunnel mode, or the given networks
+	// in split tunnel mode. It applies to the peers that select no routing
+	// profile, which get the networks of their server if it is unset.
+	// Access policies governing a peer still take precedence.
+	// +optional
+	Tunnel *GroupTunnel `json:"tunnel,omitempty"`
+}
+
+// TunnelMode is what the clients of a group route through the tunnel
+type TunnelMode string
+
+const (
+	// TunnelModeFull routes all traffic of the clients through the tunnel
+	TunnelModeFull TunnelMode = "Full"
+
+	// TunnelModeSplit routes the traffic to the given networks only
+	TunnelModeSplit TunnelMode = "Split"
+)
+
+// GroupTunnel defines the networks the clients of a group route through
+// the tunnel
+// +kubebuilder:validation:XValidation:rule="self.mode == 'Split' ? has(self.allowedIPs) : !has(self.allowedIPs)",message="allowedIPs is required in split tunnel mode and not allowed in full tunnel mode"
+type GroupTunnel struct {
+	// Mode is Full or Split
+	// +kubebuilder:validation:Enum=Full;Split
+	Mode TunnelMode `json:"mode"`
+
+	// AllowedIPs are the networks the clients route through the tunnel in
+	// split tunnel mode, e.g. 10.0.0.0/8
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:XValidation:rule="self.all(cidr, isCIDR(cidr))",message="allowedIPs must be networks, e.g. 10.0.0.0/8"
+	// +optional
+	AllowedIPs []string `json:"allowedIPs,omitempty"`
+}
+
+// ClientAllowedIPs returns the networks the clients of the tunnel route
+// through it. A full tunnel covers IPv6 too, so that the traffic of
+// dual-stack clients does not leak past it.
+func (t *GroupTunnel) ClientAllowedIPs() []string {
+	if t.Mode == TunnelModeFull {
+		return []string{"0.0.0.0/0", "::/0"}
+	}
+	return t.AllowedIPs
+}
+
+// +genclient
+// +genclient:noStatus
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=vpnpg,categories=wireflow
+// +kubebuilder:printcolumn:name="DNS",type="string",JSONPath=".spec.dns"
+// +kubebuilder:printcolumn:name="MTU",type="integer",JSONPath=".spec.mtu"
+// +kubebuilder:printcolumn:name="Tunnel",type="string",JSONPath=".spec.tunnel.mode"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VPNPeerGroup is the Schema for the vpnpeergroups API. It holds settings
+// shared by the peers of the namespace whose spec.group is its name.
+type VPNPeerGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec VPNPeerGroupSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VPNPeerGroupList contains a list of VPNPeerGroup
+type VPNPeerGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNPeerGroup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNPeerGroup{}, &VPNPeerGroupList{})
+}
@@ -0,0 +1,109 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VPNPeeringSpec defines the desired state of VPNPeering
+// +kubebuilder:validation:XValidation:rule="has(self.bundle) != has(self.bundleSecretRef)",message="exactly one of bundle and bundleSecretRef is required"
+type VPNPeeringSpec struct {
+	// ServerRef references the VPNServer the tunnel is established from
+	ServerRef LocalObjectReference `json:"serverRef"`
+
+	// Bundle is the peering bundle exported by the other cluster, as found
+	// in the bundle key of the ConfigMap of its server
+	// +optional
+	Bundle string `json:"bundle,omitempty"`
+
+	// BundleSecretRef references the peering bundle in a Secret, for
+	// bundles delivered by a secret store. The key defaults to bundle.
+	// +optional
+	BundleSecretRef *SecretKeyReference `json:"bundleSecretRef,omitempty"`
+
+	// TrustedSigningKeys are the public keys of the operators whose bundles
+	// are trusted, as found in the signing-key key of the ConfigMap of the
+	// exported server. Listing two keys rolls one over.
+	// +kubebuilder:validation:MinItems=1
+	TrustedSigningKeys []string `json:"trustedSigningKeys"`
+
+	// PersistentKeepalive is the keepalive interval in seconds of the
+	// tunnel, keeping it open through NAT
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=25
+	// +optional
+	PersistentKeepalive int32 `json:"persistentKeepalive,omitempty"`
+}
+
+// VPNPeeringStatus defines the observed state of VPNPeering
+type VPNPeeringStatus struct {
+	// ObservedGeneration is the generation of the spec the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Cluster is the name of the exporting cluster
+	// +optional
+	Cluster string `json:"cluster,omitempty"`
+
+	// Server is the namespace/name of the exported server on its cluster
+	// +optional
+	Server string `json:"server,omitempty"`
+
+	// PublicKey is the public key of the exported server
+	// +optional
+	PublicKey string `json:"publicKey,omitempty"`
+
+	// Endpoint is the endpoint of the exported server
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Networks are the networks routed to the exported server
+	// +optional
+	Networks []string `json:"networks,omitempty"`
+
+	// ExpiresAt is when the bundle expires. The tunnel is removed then
+	// unless a renewed bundle was imported.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// Peer is the VPNPeer standing for the exported server on the local one
+	// +optional
+	Peer string `json:"peer,omitempty"`
+
+	// Conditions represent the latest available observations
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=vpnpeering,categories=wireflow
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.serverRef.name"
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".status.cluster"
+// +kubebuilder:printcolumn:name="Remote",type="string",JSONPath=".status.server"
+// +kubebuilder:printcolumn:name="Expires",type="date",JSONPath=".status.expiresAt",priority=1
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VPNPeering is the Schema for the vpnpeerings API. It imports the signed
+// peering bundle of a server of another cluster, establishing a tunnel to
+// it from a local server through a VPNPeer holding its identity.
+type VPNPeering struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VPNPeeringSpec   `json:"spec,omitempty"`
+	Status VPNPeeringStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VPNPeeringList contains a list of VPNPeering
+type VPNPeeringList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNPeering `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNPeering{}, &VPNPeeringList{})
+}
@@ -0,0 +1,165 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Pool migration phases
+const (
+	// PoolMigrationPhaseRunning means peers are being re-addressed
+	PoolMigrationPhaseRunning = "Running"
+
+	// PoolMigrationPhasePaused means no further batch is started
+	PoolMigrationPhasePaused = "Paused"
+
+	// PoolMigrationPhaseCompleted means every peer was re-addressed and
+	// its old address released
+	PoolMigrationPhaseCompleted = "Completed"
+)
+
+// Peer pool migration states
+const (
+	// PeerMigrationReaddressed means the peer has its new address and keeps
+	// its old one until its client reconnects
+	PeerMigrationReaddressed = "Readdressed"
+
+	// PeerMigrationReconnected means the client of the peer handshook after
+	// it was re-addressed. Its old address is released after the
+	// retention.
+	PeerMigrationReconnected = "Reconnected"
+
+	// PeerMigrationReleased means the old address of the peer was released
+	PeerMigrationReleased = "Released"
+
+	// PeerMigrationTimedOut means the client of the peer did not reconnect
+	// within the reconnect timeout. The peer keeps its old address until
+	// it does, but no longer holds back the next batches.
+	PeerMigrationTimedOut = "TimedOut"
+)
+
+// VPNPoolMigrationSpec defines the desired state of VPNPoolMigration
+type VPNPoolMigrationSpec struct {
+	// ServerRef references the VPNServer whose peers are migrated
+	ServerRef LocalObjectReference `json:"serverRef"`
+
+	// FromCIDR is the network the peers are migrated out of
+	// +kubebuilder:validation:XValidation:rule="isCIDR(self)",message="fromCIDR must be a network, e.g. 10.9.0.0/24"
+	FromCIDR string `json:"fromCIDR"`
+
+	// ToPoolRef references the VPNIPPool the peers are re-addressed from.
+	// The server must route its networks to the tunnel.
+	ToPoolRef LocalObjectReference `json:"toPoolRef"`
+
+	// BatchSize is the number of peers re-addressed at a time. The next
+	// batch starts once the clients of the previous one reconnected.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=10
+	// +optional
+	BatchSize int32 `json:"batchSize,omitempty"`
+
+	// ReconnectTimeout is how long the clients of a batch have to reconnect
+	// before the next batch starts without them
+	// +kubebuilder:default="24h"
+	// +optional
+	ReconnectTimeout *metav1.Duration `json:"reconnectTimeout,omitempty"`
+
+	// OldAddressRetention is how long the old address of a peer stays
+	// routed to it after its client reconnected, for devices that reconnect
+	// with a stale config before fetching the new one
+	// +kubebuilder:default="1h"
+	// +optional
+	OldAddressRetention *metav1.Duration `json:"oldAddressRetention,omitempty"`
+
+	// Paused stops starting new batches. Re-addressed peers still release
+	// their old addresses.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+}
+
+// VPNPoolMigrationStatus defines the observed state of VPNPoolMigration
+type VPNPoolMigrationStatus struct {
+	// ObservedGeneration is the generation of the spec the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Phase is the phase of the migration
+	Phase string `json:"phase,omitempty"`
+
+	// Total is the number of peers to migrate, including those done
+	Total int32 `json:"total,omitempty"`
+
+	// Readdressed is the number of peers with their new address
+	Readdressed int32 `json:"readdressed,omitempty"`
+
+	// Reconnected is the number of re-addressed peers whose client
+	// reconnected
+	Reconnected int32 `json:"reconnected,omitempty"`
+
+	// Peers are the peers re-addressed so far
+	// +listType=map
+	// +listMapKey=name
+	Peers []PeerPoolMigration `json:"peers,omitempty"`
+
+	// Conditions represent the latest available observations
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// PeerPoolMigration is the migration state of a peer
+type PeerPoolMigration struct {
+	// Name is the name of the VPNPeer
+	Name string `json:"name"`
+
+	// OldAddress is the address the peer was migrated from
+	OldAddress string `json:"oldAddress"`
+
+	// NewAddress is the address the peer was migrated to
+	NewAddress string `json:"newAddress"`
+
+	// State is the state of the migration of the peer
+	State string `json:"state"`
+
+	// ReaddressedTime is when the peer got its new address
+	ReaddressedTime metav1.Time `json:"readdressedTime"`
+
+	// ReconnectedTime is when the client of the peer was first seen
+	// handshaking after it was re-addressed
+	// +optional
+	ReconnectedTime *metav1.Time `json:"reconnectedTime,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=vpnpm,categories=wireflow
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.serverRef.name"
+// +kubebuilder:printcolumn:name="From",type="string",JSONPath=".spec.fromCIDR"
+// +kubebuilder:printcolumn:name="Pool",type="string",JSONPath=".spec.toPoolRef.name"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Readdressed",type="integer",JSONPath=".status.readdressed"
+// +kubebuilder:printcolumn:name="Total",type="integer",JSONPath=".status.total"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VPNPoolMigration is the Schema for the vpnpoolmigrations API. It
+// re-addresses the peers of a server from an old network to an IP pool in
+// batches. A re-addressed peer keeps its old address routed until its
+// client reconnects, so that clients keep working until they fetch their
+// regenerated config.
+type VPNPoolMigration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VPNPoolMigrationSpec   `json:"spec,omitempty"`
+	Status VPNPoolMigrationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VPNPoolMigrationList contains a list of VPNPoolMigration
+type VPNPoolMigrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNPoolMigration `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNPoolMigration{}, &VPNPoolMigrationList{})
+}
@@ -0,0 +1,93 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VPNSelfEnrollmentSpec defines the desired state of VPNSelfEnrollment
+type VPNSelfEnrollmentSpec struct {
+	// ServerRef references the VPNServer in the same namespace devices are
+	// enrolled on
+	ServerRef LocalObjectReference `json:"serverRef"`
+
+	// Group is the peer group of the devices enrolled
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Users are the users allowed to enroll devices, each authenticated by
+	// their own token. Their claims are the identity of their devices, so
+	// that VPNAccessPolicies grant them networks.
+	// +kubebuilder:validation:MinItems=1
+	// +listType=map
+	// +listMapKey=name
+	Users []EnrollmentUser `json:"users"`
+
+	// MaxDevicesPerUser is the number of devices a user can enroll
+	// +kubebuilder:default=3
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxDevicesPerUser int32 `json:"maxDevicesPerUser,omitempty"`
+}
+
+// EnrollmentUser maps an enrollment token to the identity of the devices
+// enrolled with it
+type EnrollmentUser struct {
+	// Name identifies the user, and prefixes the names of their peers
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]{0,30}[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// TokenSecretRef references the token the user authenticates with as
+	// a bearer token. The key defaults to token. Rotating the token does
+	// not affect the devices already enrolled.
+	TokenSecretRef SecretKeyReference `json:"tokenSecretRef"`
+
+	// Claims are the identity claims of the user, e.g. groups
+	Claims map[string][]string `json:"claims,omitempty"`
+}
+
+// VPNSelfEnrollmentStatus defines the observed state of VPNSelfEnrollment
+type VPNSelfEnrollmentStatus struct {
+	// ObservedGeneration is the generation of the spec the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Path is the path of the operator's enrollment endpoint devices are
+	// enrolled on
+	Path string `json:"path,omitempty"`
+
+	// Conditions represent the latest available observations
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=vpnse,categories=wireflow
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.serverRef.name"
+// +kubebuilder:printcolumn:name="Group",type="string",JSONPath=".spec.group"
+// +kubebuilder:printcolumn:name="Path",type="string",JSONPath=".status.path"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VPNSelfEnrollment is the Schema for the vpnselfenrollments API. It lets
+// users enroll, list and revoke their own devices on the REST API of the
+// operator's enrollment endpoint, authenticated by their token, without
+// access to the cluster.
+type VPNSelfEnrollment struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VPNSelfEnrollmentSpec   `json:"spec,omitempty"`
+	Status VPNSelfEnrollmentStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VPNSelfEnrollmentList contains a list of VPNSelfEnrollment
+type VPNSelfEnrollmentList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNSelfEnrollment `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNSelfEnrollment{}, &VPNSelfEnrollmentList{})
+}
@@ -0,0 +1,403 @@
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	nodev1 "k8s.io/api/node/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var vpnserverlog = logf.Log.WithName("vpnserver-resource")
+
+// SetupWebhookWithManager registers the VPNServer webhooks.
+func (r *VPNServer) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithDefaulter(&vpnServerDefaulter{}).
+		WithValidator(&vpnServerValidator{Client: mgr.GetClient()}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-vpn-vpn-devops-com-v1alpha1-vpnserver,mutating=true,failurePolicy=fail,sideEffects=None,groups=vpn.vpn-devops.com,resources=vpnservers,verbs=create;update,versions=v1alpha1,name=mvpnserver.kb.io,admissionReviewVersions=v1
+
+// vpnServerDefaulter normalizes VPNServers before their schema is validated
+type vpnServerDefaulter struct{}
+
+var _ admission.CustomDefaulter = &vpnServerDefaulter{}
+
+// Default implements admission.CustomDefaulter. It strips the whitespace
+// pasted along with the public key override.
+func (d *vpnServerDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	server := obj.(*VPNServer)
+	server.Spec.PublicKeyOverride = NormalizeKey(server.Spec.PublicKeyOverride)
+	return nil
+}
+
+//+kubebuilder:rbac:groups=node.k8s.io,resources=runtimeclasses,verbs=get;list;watch
+//+kubebuilder:webhook:path=/validate-vpn-vpn-devops-com-v1alpha1-vpnserver,mutating=false,failurePolicy=fail,sideEffects=None,groups=vpn.vpn-devops.com,resources=vpnservers,verbs=create;update;delete,versions=v1alpha1,name=vvpnserver.kb.io,admissionReviewVersions=v1
+
+// vpnServerValidator validates VPNServers
+type vpnServerValidator struct {
+	client.Client
+}
+
+var _ admission.CustomValidator = &vpnServerValidator{}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *vpnServerValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	server := obj.(*VPNServer)
+	if err := validateClientNetworks(server); err != nil {
+		return nil, err
+	}
+	if err := validateRoutingProfiles(server); err != nil {
+		return nil, err
+	}
+	if err := validateListenPorts(server); err != nil {
+		return nil, err
+	}
+	if err := validateHighAvailability(server); err != nil {
+		return nil, err
+	}
+	if err := validateMaintenanceWindows(server); err != nil {
+		return nil, err
+	}
+	warnings, err := validateOverrides(server)
+	if err != nil {
+		return nil, err
+	}
+	warnings = append(warnings, fwMarkWarnings(server)...)
+	if err := validatePropagatedMetadata(server); err != nil {
+		return nil, err
+	}
+	if err := v.validateRuntimeClass(ctx, server); err != nil {
+		return nil, err
+	}
+	if err := v.validateGlobalPolicies(ctx, server); err != nil {
+		return nil, err
+	}
+	return warnings, validateCompliance(server)
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *vpnServerValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	server := newObj.(*VPNServer)
+	if !server.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	if err := validateClientNetworks(server); err != nil {
+		return nil, err
+	}
+	if err := validateRoutingProfiles(server); err != nil {
+		return nil, err
+	}
+	if err := validateListenPorts(server); err != nil {
+		return nil, err
+	}
+	if err := validateHighAvailability(server); err != nil {
+		return nil, err
+	}
+	if err := validateMaintenanceWindows(server); err != nil {
+		return nil, err
+	}
+	warnings, err := validateOverrides(server)
+	if err != nil {
+		return nil, err
+	}
+	warnings = append(warnings, fwMarkWarnings(server)...)
+	if err := validatePropagatedMetadata(server); err != nil {
+		return nil, err
+	}
+	if err := v.validateInUse(ctx, server); err != nil {
+		return nil, err
+	}
+	if err := v.validateRuntimeClass(ctx, server); err != nil {
+		return nil, err
+	}
+	if err := v.validateGlobalPolicies(ctx, server); err != nil {
+		return nil, err
+	}
+	return warnings, validateCompliance(server)
+}
+
+// ValidateDelete implements admission.CustomValidator. It blocks deleting a
+// server that still has peers bound to it unless the cascade annotation is
+// set.
+func (v *vpnServerValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	server := obj.(*VPNServer)
+	vpnserverlog.Info("validate delete", "name", server.Name)
+
+	if server.Annotations[CascadeDeleteAnnotation] == "true" {
+		return nil, nil
+	}
+
+	peers := &VPNPeerList{}
+	if err := v.List(ctx, peers, client.InNamespace(server.Namespace), client.MatchingFields{VPNPeerServerRefField: server.Name}); err != nil {
+		return nil, err
+	}
+	if len(peers.Items) > 0 {
+		return nil, fmt.Errorf("VPNServer %q still has %d bound peers, delete them first or set the %s=true annotation to delete them along with the server",
+			server.Name, len(peers.Items), CascadeDeleteAnnotation)
+	}
+	return nil, nil
+}
+
+// validateCompliance requires a sink whenever session metadata is recorded.
+func validateCompliance(server *VPNServer) error {
+	compliance := server.Spec.Compliance
+	if compliance == nil || compliance.SessionMetadata == "" || compliance.SessionMetadata == SessionMetadataDisabled {
+		return nil
+	}
+	if compliance.Sink == nil || compliance.Sink.URL == "" {
+		return fmt.Errorf("spec.compliance.sink.url is required when spec.compliance.sessionMetadata is %s", compliance.SessionMetadata)
+	}
+	return nil
+}
+
+// validateClientNetworks checks the comma separated allowed IPs and DNS
+// servers of clients, which v1beta1 validates as lists in the CRD schema.
+func validateClientNetworks(server *VPNServer) error {
+	allowedIPs := splitList(server.Spec.AllowedIPs)
+	if len(allowedIPs) == 0 {
+		return fmt.Errorf("spec.allowedIPs must have at least one network")
+	}
+	for _, cidr := range allowedIPs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("spec.allowedIPs: invalid network %q", cidr)
+		}
+	}
+	dns := splitList(server.Spec.DNS)
+	if len(dns) > 3 {
+		return fmt.Errorf("spec.dns must have at most 3 servers")
+	}
+	for _, address := range dns {
+		if net.ParseIP(address) == nil {
+			return fmt.Errorf("spec.dns: invalid IP address %q", address)
+		}
+	}
+	return nil
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// validateRoutingProfiles checks that routing profiles hold valid networks.
+func validateRoutingProfiles(server *VPNServer) error {
+	for _, profile := range server.Spec.ClientRoutingProfiles {
+		for _, cidr := range profile.AllowedIPs {
+			if _, _, err := net.ParseCIDR(strings.TrimSpace(cidr)); err != nil {
+				return fmt.Errorf("routing profile %q: invalid network %q", profile.Name, cidr)
+			}
+		}
+	}
+	return nil
+}
+
+// validateOverrides checks that the endpoint and public key overrides are
+// usable in client configs.
+func validateOverrides(server *VPNServer) (admission.Warnings, error) {
+	if endpoint := server.Spec.EndpointOverride; endpoint != "" {
+		if _, port, err := net.SplitHostPort(endpoint); err != nil || port == "" {
+			return nil, fmt.Errorf("spec.endpointOverride %q is not a host:port", endpoint)
+		}
+	}
+	if key := server.Spec.PublicKeyOverride; key != "" {
+		warning, err := ValidatePublicKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("spec.publicKeyOverride is %w", err)
+		}
+		if warning != "" {
+			return admission.Warnings{"spec.publicKeyOverride: " + warning}, nil
+		}
+	}
+	return nil, nil
+}
+
+// validateMaintenanceWindows rejects maintenance windows with schedules or
+// time zones the operator cannot evaluate, which would never open.
+func validateMaintenanceWindows(server *VPNServer) error {
+	for i, window := range server.Spec.MaintenanceWindows {
+		if err := window.Validate(); err != nil {
+			return fmt.Errorf("spec.maintenanceWindows[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validatePropagatedMetadata checks that propagated labels are valid and
+// leave the labels the operator selects its resources by alone.
+func validatePropagatedMetadata(server *VPNServer) error {
+	propagated := server.Spec.PropagateLabels
+	if propagated == nil {
+		return nil
+	}
+	for key, value := range propagated.Labels {
+		if strings.HasPrefix(key, "app.kubernetes.io/") || strings.HasPrefix(key, "vpn.vpn-devops.com/") {
+			return fmt.Errorf("spec.propagateLabels.labels: %q is reserved for the operator", key)
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("spec.propagateLabels.labels: key %q: %s", key, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("spec.propagateLabels.labels: value of %q: %s", key, strings.Join(errs, "; "))
+		}
+	}
+	for key := range propagated.Annotations {
+		if strings.HasPrefix(key, "vpn.vpn-devops.com/") {
+			return fmt.Errorf("spec.propagateLabels.annotations: %q is reserved for the operator", key)
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("spec.propagateLabels.annotations: key %q: %s", key, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// validateListenPorts checks that additional listen ports differ from the
+// server's port and that the listen port range has a port for every
+// replica, apart from the other ports.
+func validateListenPorts(server *VPNServer) error {
+	for _, port := range server.Spec.AdditionalListenPorts {
+		if port == server.Spec.Port {
+			return fmt.Errorf("spec.additionalListenPorts: port %d is already the server's port", port)
+		}
+	}
+	portRange := server.Spec.ListenPortRange
+	if portRange == nil {
+		return nil
+	}
+	replicas := server.Spec.Replicas
+	if server.Spec.Autoscaling != nil {
+		replicas = server.Spec.Autoscaling.MaxReplicas
+	}
+	if portRange.Size() < replicas {
+		return fmt.Errorf("spec.listenPortRange: %d ports for up to %d replicas", portRange.Size(), replicas)
+	}
+	for _, port := range server.Spec.AdditionalListenPorts {
+		if port >= portRange.Start && port <= portRange.End {
+			return fmt.Errorf("spec.additionalListenPorts: port %d is in spec.listenPortRange", port)
+		}
+	}
+	return nil
+}
+
+// validateHighAvailability checks that a highly available server has a
+// standby replica, as the CRD schema does.
+func validateHighAvailability(server *VPNServer) error {
+	if server.Spec.HighAvailability != nil && server.Spec.Replicas < 2 {
+		return fmt.Errorf("spec.replicas: highAvailability requires at least 2 replicas, got %d", server.Spec.Replicas)
+	}
+	return nil
+}
+
+// reservedFwMarks are the firewall mark bits of kube-proxy, masquerade and
+// drop, which the marks of the replicas must not set on the host network
+const reservedFwMarks = 0x4000 | 0x8000
+
+// fwMarkWarnings warns about firewall marks sharing bits with those of
+// kube-proxy.
+func fwMarkWarnings(server *VPNServer) admission.Warnings {
+	if mark := server.Spec.FwMark; mark != nil && *mark&reservedFwMarks != 0 {
+		return admission.Warnings{fmt.Sprintf("spec.fwmark: %#x sets the masquerade or drop bits of kube-proxy (0x4000, 0x8000)", *mark)}
+	}
+	return nil
+}
+
+// validateInUse blocks removing routing profiles and listen ports that bound
+// peers still select.
+func (v *vpnServerValidator) validateInUse(ctx context.Context, server *VPNServer) error {
+	peers := &VPNPeerList{}
+	if err := v.List(ctx, peers, client.InNamespace(server.Namespace), client.MatchingFields{VPNPeerServerRefField: server.Name}); err != nil {
+		return err
+	}
+	for _, peer := range peers.Items {
+		if profile := peer.Spec.RoutingProfile; profile != "" && server.Spec.RoutingProfile(profile) == nil {
+			return fmt.Errorf("routing profile %q is still selected by VPNPeer %q", profile, peer.Name)
+		}
+		if port := peer.Spec.EndpointPort; port != 0 && !server.Spec.ListensOn(port) {
+			return fmt.Errorf("listen port %d is still selected by VPNPeer %q", port, peer.Name)
+		}
+	}
+	return nil
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnglobalpolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// validateGlobalPolicies rejects servers violating a VPNGlobalPolicy.
+func (v *vpnServerValidator) validateGlobalPolicies(ctx context.Context, server *VPNServer) error {
+	policies := &VPNGlobalPolicyList{}
+	if err := v.List(ctx, policies); err != nil {
+		return err
+	}
+	if len(policies.Items) == 0 {
+		return nil
+	}
+	namespace := &corev1.Namespace{}
+	if err := v.Get(ctx, client.ObjectKey{Name: server.Namespace}, namespace); err != nil {
+		return err
+	}
+	var violations []string
+	for i := range policies.Items {
+		violations = append(violations, policies.Items[i].ServerViolations(server, namespace.Labels)...)
+	}
+	if len(violations) > 0 {
+		return errors.New(strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// sandboxedRuntimeHandlers are the RuntimeClass handlers of runtimes that
+// give pods their own kernel without the WireGuard module or nftables.
+var sandboxedRuntimeHandlers = map[string]bool{
+	"runsc":  true,
+	"gvisor": true,
+}
+
+// validateRuntimeClass checks that the RuntimeClass of the server exists and
+// that the data plane is compatible with its runtime.
+func (v *vpnServerValidator) validateRuntimeClass(ctx context.Context, server *VPNServer) error {
+	if server.Spec.RuntimeClassName == nil {
+		return nil
+	}
+	name := *server.Spec.RuntimeClassName
+
+	runtimeClass := &nodev1.RuntimeClass{}
+	if err := v.Get(ctx, client.ObjectKey{Name: name}, runtimeClass); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("spec.runtimeClassName: RuntimeClass %q does not exist", name)
+		}
+		return err
+	}
+	if !sandboxedRuntimeHandlers[runtimeClass.Handler] {
+		return nil
+	}
+	if server.Spec.Implementation != WireGuardUserspace {
+		return fmt.Errorf("spec.implementation must be %s with RuntimeClass %q: handler %s has no WireGuard kernel module",
+			WireGuardUserspace, name, runtimeClass.Handler)
+	}
+	if server.Spec.FirewallBackend == FirewallBackendNFTables {
+		return fmt.Errorf("spec.firewallBackend %s is not supported with RuntimeClass %q: handler %s only implements iptables",
+			FirewallBackendNFTables, name, runtimeClass.Handler)
+	}
+	return nil
+}
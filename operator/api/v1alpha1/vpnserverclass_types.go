@@ -0,0 +1,44 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VPNServerClassSpec defines the desired state of VPNServerClass
+type VPNServerClassSpec struct {
+	// ConfigLayer holds the settings of the servers of the class, and of
+	// their peers unless overridden
+	ConfigLayer `json:",inline"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +genclient:noStatus
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=vpnsc,categories=wireflow
+// +kubebuilder:printcolumn:name="DNS",type="string",JSONPath=".spec.dns"
+// +kubebuilder:printcolumn:name="MTU",type="integer",JSONPath=".spec.mtu"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VPNServerClass is the Schema for the vpnserverclasses API. It holds
+// settings shared by a class of servers, e.g. per region or environment,
+// between the fleet defaults of the operator and the servers.
+type VPNServerClass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec VPNServerClassSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VPNServerClassList contains a list of VPNServerClass
+type VPNServerClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNServerClass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNServerClass{}, &VPNServerClassList{})
+}
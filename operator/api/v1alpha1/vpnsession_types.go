@@ -0,0 +1,64 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VPNSessionSpec records a session of a peer
+type VPNSessionSpec struct {
+	// ServerRef references the VPNServer the peer connected to
+	ServerRef LocalObjectReference `json:"serverRef"`
+
+	// PeerRef references the VPNPeer that connected. The session is kept
+	// after the peer is deleted.
+	PeerRef LocalObjectReference `json:"peerRef"`
+
+	// PublicKey is the public key the peer connected with
+	PublicKey string `json:"publicKey"`
+
+	// Endpoint is the address the peer connected from
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// StartTime is the first handshake of the session
+	StartTime metav1.Time `json:"startTime"`
+
+	// EndTime is when the last handshake of the session expired, unset
+	// while the peer is connected
+	// +optional
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=vpnsess,categories=wireflow
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.serverRef.name"
+// +kubebuilder:printcolumn:name="Peer",type="string",JSONPath=".spec.peerRef.name"
+// +kubebuilder:printcolumn:name="Endpoint",type="string",JSONPath=".spec.endpoint"
+// +kubebuilder:printcolumn:name="Start",type="date",JSONPath=".spec.startTime"
+// +kubebuilder:printcolumn:name="End",type="date",JSONPath=".spec.endTime"
+
+// VPNSession is the Schema for the vpnsessions API. It is written by the
+// operator for each session of a peer of a server recording sessions in
+// spec.compliance.connectionAudit, and answers who was connected when. It
+// is not owned by the server, so that it outlives it, and is deleted after
+// spec.compliance.sessionRetention.
+type VPNSession struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec VPNSessionSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VPNSessionList contains a list of VPNSession
+type VPNSessionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNSession `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNSession{}, &VPNSessionList{})
+}
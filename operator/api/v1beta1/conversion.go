@@ -0,0 +1,48 @@
+package v1beta1
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// The promoted kinds differ from v1alpha1 in a few fields only, so the
+// other fields are converted through their JSON form, which both versions
+// share, after clearing the fields that differ on a copy of the source.
+
+// convertJSON sets the fields of dst from their JSON form in src, without
+// the top-level fields in omit, for fields that are serialized when empty.
+func convertJSON(src, dst interface{}, omit ...string) error {
+	raw, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	if len(omit) > 0 {
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return err
+		}
+		for _, field := range omit {
+			delete(fields, field)
+		}
+		if raw, err = json.Marshal(fields); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(raw, dst)
+}
+
+// joinList returns the comma separated form of a v1alpha1 list field.
+func joinList(items []string) string {
+	return strings.Join(items, ",")
+}
+
+// splitList returns the items of a comma separated v1alpha1 list field.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,15 @@
+// +groupName=vpn.vpn-devops.com
+// +groupGoName=Vpn
+
+// Package v1beta1 is the v1beta1 version of the wireflow API. It promotes
+// VPNServer and VPNPeer with list fields in place of comma separated
+// strings: the allowedIPs and dns of servers and routing profiles, the dns
+// of peers, and the client DNS of their status. status.connectedClients of
+// servers is renamed connectedPeers. The other kinds are served as
+// v1alpha1 until they are promoted.
+//
+// Objects are stored as v1alpha1 and converted by the conversion webhook
+// of the operator, on /convert, so that v1alpha1 objects keep working
+// during the migration. Admission webhooks validate both versions as
+// v1alpha1.
+package v1beta1
@@ -0,0 +1,14 @@
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SchemeGroupVersion is GroupVersion under the name the generated clients
+// of the client module refer to it by
+var SchemeGroupVersion = GroupVersion
+
+// Resource returns the group qualified resource of an unqualified one
+func Resource(resource string) schema.GroupResource {
+	return SchemeGroupVersion.WithResource(resource).GroupResource()
+}
@@ -0,0 +1,57 @@
+package v1beta1
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// ConvertTo converts the peer to the v1alpha1 hub.
+func (src *VPNPeer) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.VPNPeer)
+	spoke := src.DeepCopy()
+	spoke.Spec.DNS = nil
+	spoke.Status.ClientDNS = nil
+	if spoke.Status.EffectiveConfig != nil {
+		spoke.Status.EffectiveConfig.DNS = nil
+	}
+
+	dst.ObjectMeta = spoke.ObjectMeta
+	if err := convertJSON(&spoke.Spec, &dst.Spec); err != nil {
+		return err
+	}
+	if err := convertJSON(&spoke.Status, &dst.Status); err != nil {
+		return err
+	}
+	dst.Spec.DNS = joinList(src.Spec.DNS)
+	dst.Status.ClientDNS = joinList(src.Status.ClientDNS)
+	if src.Status.EffectiveConfig != nil {
+		dst.Status.EffectiveConfig.DNS = joinList(src.Status.EffectiveConfig.DNS)
+	}
+	return nil
+}
+
+// ConvertFrom converts the peer from the v1alpha1 hub.
+func (dst *VPNPeer) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.VPNPeer)
+	hub := src.DeepCopy()
+	hub.Spec.DNS = ""
+	hub.Status.ClientDNS = ""
+	if hub.Status.EffectiveConfig != nil {
+		hub.Status.EffectiveConfig.DNS = ""
+	}
+
+	dst.ObjectMeta = hub.ObjectMeta
+	if err := convertJSON(&hub.Spec, &dst.Spec); err != nil {
+		return err
+	}
+	if err := convertJSON(&hub.Status, &dst.Status); err != nil {
+		return err
+	}
+	dst.Spec.DNS = splitList(src.Spec.DNS)
+	dst.Status.ClientDNS = splitList(src.Status.ClientDNS)
+	if src.Status.EffectiveConfig != nil {
+		dst.Status.EffectiveConfig.DNS = splitList(src.Status.EffectiveConfig.DNS)
+	}
+	return nil
+}
@@ -0,0 +1,491 @@
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VPNPeerSpec defines the desired state of VPNPeer
+// +kubebuilder:validation:XValidation:rule="!has(self.presharedKey) || !has(self.presharedKeySecretRef)",message="presharedKey and presharedKeySecretRef are mutually exclusive"
+type VPNPeerSpec struct {
+	// ServerRef references the VPNServer in the same namespace the peer
+	// connects to
+	ServerRef LocalObjectReference `json:"serverRef"`
+
+	// PublicKey is the WireGuard public key of the peer
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9+/]{42}[AEIMQUYcgkosw048]=$`
+	PublicKey string `json:"publicKey,omitempty"`
+
+	// PresharedKeySecretRef references the preshared key of the peer, which
+	// adds a symmetric key to the handshake. The key defaults to
+	// preshared-key.
+	PresharedKeySecretRef *SecretKeyReference `json:"presharedKeySecretRef,omitempty"`
+
+	// PresharedKey generates the preshared key of the peer, as an
+	// alternative to referencing one with presharedKeySecretRef
+	PresharedKey *PresharedKeyGeneration `json:"presharedKey,omitempty"`
+
+	// Address is the tunnel address of the peer, with or without a prefix
+	// length
+	// +kubebuilder:validation:XValidation:rule="isIP(self) || isCIDR(self)",message="address must be an IP address, optionally with a prefix length"
+	Address string `json:"address,omitempty"`
+
+	// KeepAddressOnMove keeps the address when the peer is moved to
+	// another server whose network contains it and where it is free. A
+	// free address of the network of the new server is assigned otherwise.
+	KeepAddressOnMove bool `json:"keepAddressOnMove,omitempty"`
+
+	// Revoked removes the peer from the live interfaces of its server
+	// right away, and keeps it out of the config of the server, while the
+	// peer itself is kept for audit. Deleting a peer removes it from the
+	// live interfaces the same way. A revoked peer cannot be reinstated.
+	// +kubebuilder:validation:XValidation:rule="self || !oldSelf",message="a revoked peer cannot be reinstated, create a new peer"
+	// +optional
+	Revoked bool `json:"revoked,omitempty"`
+
+	// AllowedIPs are the additional networks routed to the peer
+	// +kubebuilder:validation:XValidation:rule="self.all(cidr, isCIDR(cidr))",message="allowedIPs must be networks, e.g. 192.168.10.0/24"
+	AllowedIPs []string `json:"allowedIPs,omitempty"`
+
+	// Endpoint is the host:port the server connects to, for peers such as
+	// site routers that accept connections. The server waits for the peer
+	// to connect if empty.
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// EndpointPinning records the source of the peer's handshakes into
+	// Endpoint once it is stable, for site routers whose address changes,
+	// e.g. on a DHCP WAN link
+	EndpointPinning *EndpointPinning `json:"endpointPinning,omitempty"`
+
+	// PersistentKeepalive is the keepalive interval in seconds. Inherited
+	// from the group, server, class or fleet defaults if 0.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
+	PersistentKeepalive int32 `json:"persistentKeepalive,omitempty"`
+
+	// DNS are the DNS servers pushed to the client of the peer, overriding
+	// those of its group, routing profile and server
+	// +kubebuilder:validation:MaxItems=3
+	// +kubebuilder:validation:XValidation:rule="self.all(server, isIP(server))",message="dns must be IP addresses"
+	// +listType=atomic
+	DNS []string `json:"dns,omitempty"`
+
+	// PowerProfile tunes the peer for its power source. The mobile profile
+	// is meant for battery-powered phones and laptops: it lengthens the
+	// keepalive interval to 120 seconds unless the peer sets its own, so
+	// that the radio wakes up less often, and stale handshake alerts use
+	// the server's mobileStaleHandshakeThreshold for the peer. The tradeoff
+	// is that NAT mappings shorter than the interval expire while the peer
+	// is idle: traffic to the peer is dropped until it sends again, and its
+	// handshakes are too infrequent to tell whether it is online.
+	// +kubebuilder:validation:Enum=standard;mobile
+	// +optional
+	PowerProfile PowerProfile `json:"powerProfile,omitempty"`
+
+	// MTU is the MTU of the tunnel interface of the client of the peer.
+	// Inherited from the group, server, class or fleet defaults if unset.
+	// +kubebuilder:validation:Minimum=1280
+	// +kubebuilder:validation:Maximum=9000
+	MTU *int32 `json:"mtu,omitempty"`
+
+	// Group is the peer group the peer belongs to, e.g. contractors
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Group string `json:"group,omitempty"`
+
+	// RoutingProfile selects one of the client routing profiles of the
+	// server. The tunnel of the peer's group, or else the server's
+	// AllowedIPs, are pushed to the client if empty.
+	RoutingProfile string `json:"routingProfile,omitempty"`
+
+	// EndpointPort is the server port the client of the peer connects to,
+	// one of the server's additional listen ports. The server's port is
+	// used if unset.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	EndpointPort int32 `json:"endpointPort,omitempty"`
+
+	// RegionHints are the regions or zones the client of the peer is
+	// nearest to, by preference. When the server publishes zone endpoints,
+	// the client connects to the healthy zone matching the first hint and
+	// fails over to the others in order.
+	// +optional
+	RegionHints []string `json:"regionHints,omitempty"`
+
+	// PathMTUProbe schedules path MTU probes of the peer. Probes can also
+	// be requested with the probe-mtu annotation.
+	PathMTUProbe *PathMTUProbe `json:"pathMTUProbe,omitempty"`
+
+	// Identity is the identity the peer was enrolled with. It is refreshed
+	// from the identity provider on key rotation.
+	Identity *PeerIdentity `json:"identity,omitempty"`
+
+	// DeviceBinding binds the peer's config to the device it was enrolled
+	// on. The key is expected to be re-validated from that device only.
+	DeviceBinding *DeviceBinding `json:"deviceBinding,omitempty"`
+
+	// Invite creates the peer without a public key, as a placeholder that
+	// reserves an address until the invitee submits its key with the
+	// invite token through the enrollment API. The peer is deleted if the
+	// invite is not accepted in time.
+	Invite *PeerInvite `json:"invite,omitempty"`
+
+	// Bandwidth limits the traffic of the peer through the server, in each
+	// direction, so that a single client cannot saturate the gateway. The
+	// agents of the server pods shape it on the tunnel interface.
+	// +optional
+	Bandwidth *PeerBandwidth `json:"bandwidth,omitempty"`
+}
+
+// PeerBandwidth defines the bandwidth limit of a peer
+type PeerBandwidth struct {
+	// Rate is the rate in bits per second the traffic of the peer is
+	// limited to in each direction, e.g. 20M
+	Rate resource.Quantity `json:"rate"`
+
+	// Burst is the number of bytes the peer may send or receive at once
+	// above the rate, e.g. 64Ki. Defaults to 100ms of traffic at the rate.
+	// +optional
+	Burst *resource.Quantity `json:"burst,omitempty"`
+}
+
+// PresharedKeyGeneration generates the preshared key of a peer into a Secret
+// owned by the peer, named after it with a -psk suffix
+type PresharedKeyGeneration struct {
+	// RotationInterval replaces the key on an interval, e.g. 720h. A peer
+	// has a single preshared key, shared by both ends: its client cannot
+	// handshake after a rotation until it imports its updated config.
+	// +optional
+	RotationInterval *metav1.Duration `json:"rotationInterval,omitempty"`
+}
+
+// PeerInvite defines the invite of a placeholder peer
+type PeerInvite struct {
+	// TTL is how long the invite can be accepted
+	// +kubebuilder:default="72h"
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// Email is the address the invite is sent to, with the templates of
+	// the server's branding. Invites without one are handed out by hand.
+	// +kubebuilder:validation:Pattern=`^[^@\s]+@[^@\s]+$`
+	// +optional
+	Email string `json:"email,omitempty"`
+}
+
+// PowerProfile tunes a peer for its power source
+type PowerProfile string
+
+const (
+	// PowerProfileStandard keeps the keepalive and alerting of the peer's
+	// group and server
+	PowerProfileStandard PowerProfile = "standard"
+
+	// PowerProfileMobile saves the battery of mobile devices
+	PowerProfileMobile PowerProfile = "mobile"
+)
+
+// DeviceBinding binds a peer to the fingerprint of a device
+type DeviceBinding struct {
+	// Fingerprint is the hash of the machine identifiers of the device,
+	// supplied at enrollment
+	// +kubebuilder:validation:Pattern=`^[0-9a-f]{64}$`
+	Fingerprint string `json:"fingerprint"`
+
+	// RevalidationInterval is how often the device must re-validate its
+	// posture with the key. Defaults to 24h.
+	RevalidationInterval *metav1.Duration `json:"revalidationInterval,omitempty"`
+}
+
+// EndpointPinning defines when the handshake source of a peer is pinned
+type EndpointPinning struct {
+	// StableFor is how long the peer must keep handshaking from the same
+	// source before it is pinned, so that a briefly used address does not
+	// replace the endpoint. Defaults to 10m.
+	StableFor *metav1.Duration `json:"stableFor,omitempty"`
+}
+
+// PathMTUProbe defines when the path MTU of a peer is probed
+type PathMTUProbe struct {
+	// Interval is the time between probes
+	Interval metav1.Duration `json:"interval"`
+}
+
+// PeerIdentity is the identity of an enrolled peer
+type PeerIdentity struct {
+	// Issuer is the identity provider that authenticated the peer
+	Issuer string `json:"issuer,omitempty"`
+
+	// Subject identifies the peer's owner at the issuer
+	Subject string `json:"subject"`
+
+	// Claims are the identity claims of the owner, e.g. groups
+	Claims map[string][]string `json:"claims,omitempty"`
+
+	// SyncTime is the last time the claims were read from the issuer
+	SyncTime *metav1.Time `json:"syncTime,omitempty"`
+}
+
+// VPNPeerStatus defines the observed state of VPNPeer
+type VPNPeerStatus struct {
+	// ObservedGeneration is the generation of the spec the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Phase is the lifecycle phase of the peer: Pending, Active, Suspended,
+	// Invited or Revoked
+	Phase string `json:"phase,omitempty"`
+
+	// Conditions represent the latest available observations
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	// ObservedServer is the server the status was resolved against, from
+	// which a peer whose serverRef changes is moved
+	ObservedServer string `json:"observedServer,omitempty"`
+
+	// ObservedGroup is the group the status was resolved for
+	ObservedGroup string `json:"observedGroup,omitempty"`
+
+	// ClientAllowedIPs are the networks the client of the peer routes
+	// through the tunnel
+	ClientAllowedIPs []string `json:"clientAllowedIPs,omitempty"`
+
+	// ClientDNS are the DNS servers pushed to the client of the peer
+	ClientDNS []string `json:"clientDNS,omitempty"`
+
+	// ClientSearchDomains are the DNS search domains pushed to the client of
+	// the peer
+	ClientSearchDomains []string `json:"clientSearchDomains,omitempty"`
+
+	// EffectiveConfig is the configuration of the peer after merging the
+	// fleet defaults, its server's class, server, group and its own spec
+	EffectiveConfig *EffectiveConfig `json:"effectiveConfig,omitempty"`
+
+	// PresharedKey is the state of the preshared key of the peer, if it has
+	// one
+	PresharedKey *PresharedKeyStatus `json:"presharedKey,omitempty"`
+
+	// ClientEndpoint is the server endpoint the client of the peer connects
+	// to
+	ClientEndpoint string `json:"clientEndpoint,omitempty"`
+
+	// ClientEndpoints are the endpoints of the client of the peer in
+	// failover order, when the server publishes zone endpoints.
+	// ClientEndpoint is the first.
+	ClientEndpoints []string `json:"clientEndpoints,omitempty"`
+
+	// ClientConfigSecretName is the Secret holding the wg-quick config of
+	// the client under wg0.conf, without its private key, which the
+	// operator never sees
+	ClientConfigSecretName string `json:"clientConfigSecretName,omitempty"`
+
+	// ObservedEndpoint is the source of the peer's recent handshakes
+	ObservedEndpoint string `json:"observedEndpoint,omitempty"`
+
+	// ObservedEndpointSince is when the peer started handshaking from
+	// ObservedEndpoint
+	ObservedEndpointSince *metav1.Time `json:"observedEndpointSince,omitempty"`
+
+	// AccessPolicies are the VPNAccessPolicies that granted the client's
+	// networks
+	AccessPolicies []string `json:"accessPolicies,omitempty"`
+
+	// LastHandshake is the last handshake of the peer the operator
+	// recorded, at a granularity of an hour. It survives server restarts,
+	// which reset the handshakes reported by the server.
+	LastHandshake *metav1.Time `json:"lastHandshake,omitempty"`
+
+	// Suspension is set while the peer is suspended by the idle policy of
+	// its server
+	Suspension *PeerSuspension `json:"suspension,omitempty"`
+
+	// PathMTU is the result of the path MTU probes of the peer
+	PathMTU *PathMTUStatus `json:"pathMTU,omitempty"`
+
+	// Posture is the last posture re-validation of the peer's device
+	Posture *PostureStatus `json:"posture,omitempty"`
+
+	// Invite is the state of the invite of a placeholder peer
+	Invite *InviteStatus `json:"invite,omitempty"`
+
+	// Shard is the ordinal of the replica serving the peer, when its
+	// server is sharded
+	// +optional
+	Shard *int32 `json:"shard,omitempty"`
+
+	// Client is the implementation and version of the peer's client, as
+	// last reported by it
+	Client *ClientInfo `json:"client,omitempty"`
+
+	// Shaping is the state of the bandwidth limit of the peer on the
+	// replicas of its server
+	// +optional
+	Shaping *ShapingStatus `json:"shaping,omitempty"`
+}
+
+// EffectiveConfig is the configuration of a peer after merging the levels of
+// the configuration hierarchy
+type EffectiveConfig struct {
+	// DNS are the DNS servers pushed to the client
+	DNS []string `json:"dns,omitempty"`
+
+	// PersistentKeepalive is the keepalive interval in seconds, 0 if
+	// disabled
+	PersistentKeepalive int32 `json:"persistentKeepalive,omitempty"`
+
+	// MTU is the MTU of the tunnel interface of the client, 0 for the
+	// client's default
+	MTU int32 `json:"mtu,omitempty"`
+
+	// Sources maps each setting in effect to the level it comes from, e.g.
+	// VPNServerClass/standard
+	Sources map[string]string `json:"sources,omitempty"`
+}
+
+// PresharedKeyStatus is the state of the preshared key of a peer
+type PresharedKeyStatus struct {
+	// SecretName is the Secret holding the key
+	SecretName string `json:"secretName,omitempty"`
+
+	// Fingerprint identifies the current key without disclosing it
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// RotationTime is when the current key was generated or first seen
+	RotationTime *metav1.Time `json:"rotationTime,omitempty"`
+
+	// NextRotationTime is when a generated key is replaced
+	NextRotationTime *metav1.Time `json:"nextRotationTime,omitempty"`
+}
+
+// InviteStatus records the invite of a placeholder peer
+type InviteStatus struct {
+	// TokenSecretName is the Secret holding the invite token under the
+	// token key, deleted once the invite is accepted
+	TokenSecretName string `json:"tokenSecretName,omitempty"`
+
+	// ExpiresAt is when the invite expires and the peer is deleted
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// EmailSentAt is when the invite was sent to spec.invite.email
+	EmailSentAt *metav1.Time `json:"emailSentAt,omitempty"`
+
+	// AcceptedAt is when the invitee submitted its public key
+	AcceptedAt *metav1.Time `json:"acceptedAt,omitempty"`
+}
+
+// PostureStatus records the posture re-validations of a peer
+type PostureStatus struct {
+	// ValidatedAt is when the key was last re-validated
+	ValidatedAt *metav1.Time `json:"validatedAt,omitempty"`
+
+	// Fingerprint is the device fingerprint the key was last re-validated
+	// with
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// MismatchedAt is when the key was last re-validated from a device
+	// other than the bound one
+	MismatchedAt *metav1.Time `json:"mismatchedAt,omitempty"`
+}
+
+// ClientInfo is the implementation and version a client reported
+type ClientInfo struct {
+	// Implementation is the client implementation, e.g. wireflow
+	Implementation string `json:"implementation,omitempty"`
+
+	// Version is the version of the client
+	Version string `json:"version,omitempty"`
+
+	// Source is how the client reported them: Enrollment from the
+	// User-Agent or body of its enrollment, Agent from its posture
+	// re-validations
+	Source string `json:"source,omitempty"`
+
+	// ReportedAt is when the client last reported them
+	ReportedAt *metav1.Time `json:"reportedAt,omitempty"`
+}
+
+// ShapingStatus is the state of the bandwidth limit of a peer
+type ShapingStatus struct {
+	// State is Applied, Pending or Failed
+	State string `json:"state"`
+
+	// Rate is the rate in bits per second the replicas shape the peer at
+	Rate int64 `json:"rate,omitempty"`
+
+	// Burst is the burst in bytes the replicas shape the peer with
+	Burst int64 `json:"burst,omitempty"`
+
+	// Replicas is the number of ready replicas shaping the peer at its
+	// current limit
+	Replicas int32 `json:"replicas"`
+
+	// Message explains a pending or failed limit
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// PathMTUStatus records the path MTU probes of a peer
+type PathMTUStatus struct {
+	// Request is the value of the probe-mtu annotation last acted on
+	Request string `json:"request,omitempty"`
+
+	// RequestedAt is when the pending probe was requested, unset when no
+	// probe is pending
+	RequestedAt *metav1.Time `json:"requestedAt,omitempty"`
+
+	// ProbedAt is when the path was last probed
+	ProbedAt *metav1.Time `json:"probedAt,omitempty"`
+
+	// Endpoint is the peer endpoint the path was probed to
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// PathMTU is the MTU of the path from the server to the endpoint of
+	// the peer, 0 if the endpoint did not answer
+	PathMTU int32 `json:"pathMTU,omitempty"`
+
+	// SuggestedMTU is the largest tunnel MTU whose encapsulated packets
+	// fit the path
+	SuggestedMTU int32 `json:"suggestedMTU,omitempty"`
+}
+
+// PeerSuspension records the suspension of an idle peer
+type PeerSuspension struct {
+	// Since is when the peer was suspended
+	Since metav1.Time `json:"since"`
+
+	// Address is the address the peer had, reassigned when it resumes if
+	// it is still free
+	Address string `json:"address,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=vpnp,categories=wireflow
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.serverRef.name"
+// +kubebuilder:printcolumn:name="Address",type="string",JSONPath=".spec.address"
+// +kubebuilder:printcolumn:name="Group",type="string",JSONPath=".spec.group"
+// +kubebuilder:printcolumn:name="Profile",type="string",JSONPath=".spec.routingProfile"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Shard",type="integer",JSONPath=".status.shard",priority=1
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VPNPeer is the Schema for the vpnpeers API
+type VPNPeer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VPNPeerSpec   `json:"spec,omitempty"`
+	Status VPNPeerStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VPNPeerList contains a list of VPNPeer
+type VPNPeerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNPeer `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNPeer{}, &VPNPeerList{})
+}
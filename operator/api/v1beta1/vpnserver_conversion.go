@@ -0,0 +1,57 @@
+package v1beta1
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// ConvertTo converts the server to the v1alpha1 hub.
+func (src *VPNServer) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.VPNServer)
+	spoke := src.DeepCopy()
+	spoke.Spec.DNS = nil
+	for i := range spoke.Spec.ClientRoutingProfiles {
+		spoke.Spec.ClientRoutingProfiles[i].DNS = nil
+	}
+
+	dst.ObjectMeta = spoke.ObjectMeta
+	if err := convertJSON(&spoke.Spec, &dst.Spec, "allowedIPs"); err != nil {
+		return err
+	}
+	if err := convertJSON(&spoke.Status, &dst.Status); err != nil {
+		return err
+	}
+	dst.Spec.AllowedIPs = joinList(src.Spec.AllowedIPs)
+	dst.Spec.DNS = joinList(src.Spec.DNS)
+	for i := range src.Spec.ClientRoutingProfiles {
+		dst.Spec.ClientRoutingProfiles[i].DNS = joinList(src.Spec.ClientRoutingProfiles[i].DNS)
+	}
+	dst.Status.ConnectedClients = src.Status.ConnectedPeers
+	return nil
+}
+
+// ConvertFrom converts the server from the v1alpha1 hub.
+func (dst *VPNServer) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.VPNServer)
+	hub := src.DeepCopy()
+	hub.Spec.DNS = ""
+	for i := range hub.Spec.ClientRoutingProfiles {
+		hub.Spec.ClientRoutingProfiles[i].DNS = ""
+	}
+
+	dst.ObjectMeta = hub.ObjectMeta
+	if err := convertJSON(&hub.Spec, &dst.Spec, "allowedIPs"); err != nil {
+		return err
+	}
+	if err := convertJSON(&hub.Status, &dst.Status); err != nil {
+		return err
+	}
+	dst.Spec.AllowedIPs = splitList(src.Spec.AllowedIPs)
+	dst.Spec.DNS = splitList(src.Spec.DNS)
+	for i := range src.Spec.ClientRoutingProfiles {
+		dst.Spec.ClientRoutingProfiles[i].DNS = splitList(src.Spec.ClientRoutingProfiles[i].DNS)
+	}
+	dst.Status.ConnectedPeers = src.Status.ConnectedClients
+	return nil
+}
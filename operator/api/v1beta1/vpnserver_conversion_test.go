@@ -0,0 +1,48 @@
+package v1beta1
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+func TestVPNServerConversionRoundTripsLists(t *testing.T) {
+	hub := &v1alpha1.VPNServer{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "vpn", Name: "edge"},
+		Spec: v1alpha1.VPNServerSpec{
+			Address:    "10.8.0.1/24",
+			Port:       51820,
+			AllowedIPs: "10.0.0.0/8, 192.168.0.0/16",
+			DNS:        "1.1.1.1,8.8.8.8",
+		},
+		Status: v1alpha1.VPNServerStatus{ConnectedClients: 2},
+	}
+
+	server := &VPNServer{}
+	if err := server.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"10.0.0.0/8", "192.168.0.0/16"}; !reflect.DeepEqual(server.Spec.AllowedIPs, want) {
+		t.Errorf("allowedIPs = %v, want %v", server.Spec.AllowedIPs, want)
+	}
+	if want := []string{"1.1.1.1", "8.8.8.8"}; !reflect.DeepEqual(server.Spec.DNS, want) {
+		t.Errorf("dns = %v, want %v", server.Spec.DNS, want)
+	}
+	if server.Status.ConnectedPeers != 2 {
+		t.Errorf("connectedPeers = %d, want 2", server.Status.ConnectedPeers)
+	}
+
+	back := &v1alpha1.VPNServer{}
+	if err := server.ConvertTo(back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Spec.AllowedIPs != "10.0.0.0/8,192.168.0.0/16" || back.Spec.DNS != "1.1.1.1,8.8.8.8" {
+		t.Errorf("allowedIPs = %q, dns = %q, want the comma separated lists", back.Spec.AllowedIPs, back.Spec.DNS)
+	}
+	if back.Spec.Address != hub.Spec.Address || back.Status.ConnectedClients != 2 {
+		t.Errorf("spec = %+v, status = %+v, want the other fields kept", back.Spec, back.Status)
+	}
+}
@@ -0,0 +1,1675 @@
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VPNServerSpec defines the desired state of VPNServer. The CRD schema
+// carries the defaults and the validations that need no lookups, so that
+// clusters without the admission webhook still apply them. Network formats
+// are checked with the CEL network library of Kubernetes 1.31.
+// +kubebuilder:validation:XValidation:rule="!has(self.additionalListenPorts) || !(self.port in self.additionalListenPorts)",message="additionalListenPorts must not contain the server's port"
+// +kubebuilder:validation:XValidation:rule="!has(self.keyRotation) || has(self.privateKeySecretRef) || (has(self.keyStore) && self.keyStore.type == 'Vault')",message="keyRotation requires privateKeySecretRef or a Vault keyStore"
+// +kubebuilder:validation:XValidation:rule="!has(self.keyStore) || self.keyStore.type != 'Vault' || !has(self.keyStore.vault.engine) || self.keyStore.vault.engine != 'Transit' || has(self.privateKeySecretRef)",message="the Transit engine requires privateKeySecretRef, the Secret holding the encrypted keys"
+// +kubebuilder:validation:XValidation:rule="!has(self.deploymentMode) || self.deploymentMode != 'DaemonSet' || !has(self.autoscaling)",message="autoscaling does not apply to the DaemonSet deployment mode"
+// +kubebuilder:validation:XValidation:rule="!has(self.nodeListenPorts) || (has(self.deploymentMode) && self.deploymentMode == 'DaemonSet')",message="nodeListenPorts requires the DaemonSet deployment mode"
+// +kubebuilder:validation:XValidation:rule="!has(self.listenPortRange) || (has(self.deploymentMode) && self.deploymentMode == 'StatefulSet')",message="listenPortRange requires the StatefulSet deployment mode"
+// +kubebuilder:validation:XValidation:rule="!has(self.sharding) || (has(self.deploymentMode) && self.deploymentMode == 'StatefulSet')",message="sharding requires the StatefulSet deployment mode"
+// +kubebuilder:validation:XValidation:rule="!has(self.sharding) || !has(self.zoneEndpoints) || size(self.zoneEndpoints) == 0",message="sharding and zoneEndpoints are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="!has(self.highAvailability) || !has(self.sharding)",message="highAvailability and sharding are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="!has(self.highAvailability) || self.replicas >= 2",message="highAvailability requires at least 2 replicas"
+// +kubebuilder:validation:XValidation:rule="!has(self.endpointDNS) || !has(self.endpointOverride) || self.endpointOverride == ''",message="endpointDNS and endpointOverride are mutually exclusive"
+type VPNServerSpec struct {
+	// Replicas is the number of VPN server replicas
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10
+	// +kubebuilder:default=1
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// Autoscaling scales the replicas between bounds by the connected
+	// clients and the throughput of the server. Replicas is then managed
+	// by the operator.
+	// +optional
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+
+	// DeploymentMode is how the replicas run: Deployment runs Replicas
+	// replicas, StatefulSet runs them with stable names and DaemonSet runs
+	// one on every node, e.g. for host-network gateways. Replicas and
+	// Autoscaling do not apply to DaemonSets.
+	// +kubebuilder:default=Deployment
+	// +optional
+	DeploymentMode DeploymentMode `json:"deploymentMode,omitempty"`
+
+	// NodeListenPorts override Port on some nodes in the DaemonSet mode,
+	// for nodes where it is taken by another host-network service
+	// +listType=map
+	// +listMapKey=node
+	// +optional
+	NodeListenPorts []NodeListenPort `json:"nodeListenPorts,omitempty"`
+
+	// Image is the VPN server image
+	Image string `json:"image"`
+
+	// Port is the VPN server port
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=51820
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// AdditionalListenPorts are UDP ports redirected to Port, for clients on
+	// networks that only allow specific ports, e.g. 53, 123 or 4500
+	// +kubebuilder:validation:MaxItems=15
+	// +kubebuilder:validation:items:Minimum=1
+	// +kubebuilder:validation:items:Maximum=65535
+	// +listType=set
+	AdditionalListenPorts []int32 `json:"additionalListenPorts,omitempty"`
+
+	// ListenPortRange gives each replica of a StatefulSet its own listen
+	// port, the port of the range at its ordinal, for replicas sharing the
+	// host network of a node. Clients then reach the replicas on their own
+	// ports rather than on Port.
+	// +optional
+	ListenPortRange *PortRange `json:"listenPortRange,omitempty"`
+
+	// Sharding runs the replicas active-active: the peers are split across
+	// them and each replica serves its share on its own endpoint, so that
+	// the capacity of the server grows with its replicas
+	// +optional
+	Sharding *ShardingSpec `json:"sharding,omitempty"`
+
+	// HighAvailability runs the replicas active-passive: the replica holding
+	// the lease of the server terminates the tunnels and the others are hot
+	// standbys, one of which takes over when it fails. Requires at least 2
+	// replicas.
+	// +optional
+	HighAvailability *HighAvailabilitySpec `json:"highAvailability,omitempty"`
+
+	// Interface is the WireGuard interface name
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.=+-]{1,15}$`
+	// +kubebuilder:default=wg0
+	// +optional
+	Interface string `json:"interface,omitempty"`
+
+	// Address is the VPN server address with the prefix length of the
+	// tunnel network, e.g. 10.9.0.1/24
+	// +kubebuilder:validation:XValidation:rule="isCIDR(self)",message="address must be an address with a prefix length, e.g. 10.9.0.1/24"
+	Address string `json:"address"`
+
+	// ClientCIDR is the network the operator allocates addresses from for
+	// peers created without one. Defaults to the network of Address.
+	// +kubebuilder:validation:XValidation:rule="isCIDR(self)",message="clientCIDR must be a network, e.g. 10.9.0.0/24"
+	ClientCIDR string `json:"clientCIDR,omitempty"`
+
+	// Service configures the Service exposing the replicas to clients,
+	// which the endpoint is discovered from
+	// +optional
+	Service *ServiceSpec `json:"service,omitempty"`
+
+	// EndpointDNS publishes a DNS name for the Service with external-dns,
+	// which clients connect to instead of the address of the Service
+	// +optional
+	EndpointDNS *EndpointDNS `json:"endpointDNS,omitempty"`
+
+	// EndpointOverride is the endpoint published to clients instead of the
+	// discovered one, e.g. a DNS name in front of the Service. The operator
+	// never rewrites it.
+	EndpointOverride string `json:"endpointOverride,omitempty"`
+
+	// ZoneEndpoints are the endpoints of the replicas of each zone, for
+	// servers whose replicas span zones with distinct endpoints, e.g. a
+	// load balancer per zone. Clients connect to the endpoint of the
+	// healthy zone nearest to the regionHints of their peer, and fail over
+	// to the others in order.
+	// +listType=map
+	// +listMapKey=zone
+	// +optional
+	ZoneEndpoints []ZoneEndpoint `json:"zoneEndpoints,omitempty"`
+
+	// PublicKeyOverride is the server public key published to clients
+	// instead of the discovered one, for keys managed outside the operator.
+	// The operator never rewrites it.
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9+/]{42}[AEIMQUYcgkosw048]=$`
+	PublicKeyOverride string `json:"publicKeyOverride,omitempty"`
+
+	// PrivateKeySecretRef references the server private key, which is
+	// projected into the server pods. The operator generates the key pair
+	// into the Secret when it does not exist and publishes the public key
+	// in the status; existing keys are never rewritten. The key defaults to
+	// server_private. Servers without it generate their keys in the pod.
+	PrivateKeySecretRef *SecretKeyReference `json:"privateKeySecretRef,omitempty"`
+
+	// KeyRotation rotates the key pair in privateKeySecretRef, or in the
+	// key store, on a schedule
+	KeyRotation *KeyRotation `json:"keyRotation,omitempty"`
+
+	// MaintenanceWindows are when disruptive operations may run: the
+	// switch of a key rotation, the rollout of a new image and the batches
+	// of pool migrations. Outside of them the operations wait for the next
+	// window, as listed in status.pendingOperations. Operations run at any
+	// time without windows.
+	// +kubebuilder:validation:MaxItems=16
+	// +optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+
+	// KeyStore is where the key pair of the server is stored. Defaults to
+	// the Secret of privateKeySecretRef. With Vault, the server pods fetch
+	// their key from Vault and no plaintext private key is stored in the
+	// cluster.
+	// +optional
+	KeyStore *KeyStore `json:"keyStore,omitempty"`
+
+	// ClassName is the VPNServerClass the server inherits settings from
+	ClassName string `json:"className,omitempty"`
+
+	// BrandingRef references the VPNBranding in the same namespace that
+	// white-labels the enrollment pages, invite emails and client configs
+	// of the server's peers
+	// +optional
+	BrandingRef *LocalObjectReference `json:"brandingRef,omitempty"`
+
+	// DNS are the DNS servers for VPN clients. Inherited from the class or
+	// the fleet defaults if empty.
+	// +kubebuilder:validation:MaxItems=3
+	// +kubebuilder:validation:XValidation:rule="self.all(server, isIP(server))",message="dns must be IP addresses"
+	// +listType=atomic
+	DNS []string `json:"dns,omitempty"`
+
+	// PersistentKeepalive is the keepalive interval in seconds of the
+	// server's peers. Inherited from the class or the fleet defaults if
+	// unset.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
+	PersistentKeepalive *int32 `json:"persistentKeepalive,omitempty"`
+
+	// MTU is the MTU of the tunnel interface of the server's clients.
+	// Inherited from the class or the fleet defaults if unset.
+	// +kubebuilder:validation:Minimum=1280
+	// +kubebuilder:validation:Maximum=9000
+	MTU *int32 `json:"mtu,omitempty"`
+
+	// InterfaceMTU is the MTU of the tunnel interface of the replicas.
+	// Derived by the kernel from the route to the clients if unset.
+	// +kubebuilder:validation:Minimum=1280
+	// +kubebuilder:validation:Maximum=9000
+	// +optional
+	InterfaceMTU *int32 `json:"interfaceMTU,omitempty"`
+
+	// FwMark is the firewall mark of the packets the replicas send, for
+	// policy routing of the tunnel traffic on the host network
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=4294967295
+	// +optional
+	FwMark *int64 `json:"fwmark,omitempty"`
+
+	// AllowedIPs are the networks VPN clients route through the tunnel
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:XValidation:rule="self.all(cidr, isCIDR(cidr))",message="allowedIPs must be networks, e.g. 10.0.0.0/8"
+	// +listType=atomic
+	AllowedIPs []string `json:"allowedIPs"`
+
+	// ClientRoutingProfiles are named sets of routes pushed to clients, so
+	// that one server can serve different audiences. Peers select a profile
+	// with spec.routingProfile and receive AllowedIPs otherwise.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	ClientRoutingProfiles []ClientRoutingProfile `json:"clientRoutingProfiles,omitempty"`
+
+	// Resources defines the resource requirements
+	Resources ResourceRequirements `json:"resources,omitempty"`
+
+	// NodeSelector defines node selection constraints
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations defines pod tolerations
+	Tolerations []Toleration `json:"tolerations,omitempty"`
+
+	// Affinity defines pod affinity rules
+	Affinity *Affinity `json:"affinity,omitempty"`
+
+	// ClusterName is the MemberCluster the server is placed on when the
+	// operator runs in hub mode
+	ClusterName string `json:"clusterName,omitempty"`
+
+	// PeeringExport exports the identity of the server as a signed peering
+	// bundle, which other clusters import with a VPNPeering to establish a
+	// tunnel to it
+	// +optional
+	PeeringExport *PeeringExport `json:"peeringExport,omitempty"`
+
+	// Alerting defines health thresholds evaluated by the operator
+	Alerting *AlertingSpec `json:"alerting,omitempty"`
+
+	// Monitoring exports the traffic, handshakes and endpoint changes of
+	// the server's peers as Prometheus metrics
+	// +optional
+	Monitoring *MonitoringSpec `json:"monitoring,omitempty"`
+
+	// RouteExport publishes the client network to the CNI of the cluster,
+	// so pods reach clients without their traffic being masqueraded
+	// +optional
+	RouteExport *RouteExportSpec `json:"routeExport,omitempty"`
+
+	// FirewallBackend selects how the agent programs firewall rules. The
+	// default auto detects the backend used by the node.
+	// +kubebuilder:validation:Enum=auto;nftables;iptables-legacy
+	// +optional
+	FirewallBackend FirewallBackend `json:"firewallBackend,omitempty"`
+
+	// Compliance defines compliance controls applied by the agent
+	Compliance *ComplianceSpec `json:"compliance,omitempty"`
+
+	// ClientConfigEncryption encrypts the client configs the operator stores
+	// in Secrets with a key managed outside the cluster
+	ClientConfigEncryption *ClientConfigEncryption `json:"clientConfigEncryption,omitempty"`
+
+	// HostRoutes installs routes to the networks of the server's peers on
+	// every node, via a ready server pod, so that return traffic from pods
+	// reaches remote sites. Requires the node-routes DaemonSet.
+	// +optional
+	HostRoutes bool `json:"hostRoutes,omitempty"`
+
+	// Placement defines how server replicas are placed on nodes
+	Placement *PlacementSpec `json:"placement,omitempty"`
+
+	// Implementation selects the WireGuard implementation of the data
+	// plane. Sandboxed runtimes such as gVisor have no kernel module and
+	// require userspace.
+	// +kubebuilder:validation:Enum=kernel;userspace
+	// +kubebuilder:default=kernel
+	// +optional
+	Implementation WireGuardImplementation `json:"implementation,omitempty"`
+
+	// RuntimeClassName is the RuntimeClass server pods run with, e.g. a
+	// gVisor RuntimeClass for sandboxed data planes
+	RuntimeClassName *string `json:"runtimeClassName,omitempty"`
+
+	// DeviceLogging forwards the logs of the WireGuard device to the server
+	// container log, for kubectl wireflow logs. The kernel module logs
+	// through dynamic debug, which needs debugfs and /dev/kmsg in the pod
+	// and is enabled for every WireGuard interface of the node.
+	// +optional
+	DeviceLogging bool `json:"deviceLogging,omitempty"`
+
+	// Performance tunes the sockets and queues of the data plane for high
+	// throughput links. The agent applies it and reports the values in
+	// effect in status.performance.
+	// +optional
+	Performance *PerformanceTuning `json:"performance,omitempty"`
+
+	// ClientIsolation prevents peers from reaching each other through the
+	// server, e.g. for guest or contractor access. Peers can still reach
+	// the networks behind the server.
+	// +optional
+	ClientIsolation bool `json:"clientIsolation,omitempty"`
+
+	// IsolationExceptions are peer groups whose members can still reach
+	// each other when ClientIsolation is set
+	// +listType=set
+	IsolationExceptions []string `json:"isolationExceptions,omitempty"`
+
+	// Firewall constrains the traffic peers send through the server, e.g.
+	// to cluster Services, with rules by network, protocol and port. The
+	// agents of the server pods program them with nftables or
+	// iptables-legacy. Traffic between peers is subject to it too.
+	// +optional
+	Firewall *FirewallPolicy `json:"firewall,omitempty"`
+
+	// PropagateLabels are labels and annotations set on the resources the
+	// operator creates for the server, such as its Service, Secrets and
+	// Deployment, for cost allocation and ownership tooling
+	PropagateLabels *PropagatedMetadata `json:"propagateLabels,omitempty"`
+
+	// PeerDelegation grants groups the management of the peers bound to
+	// the server only, e.g. to team leads
+	PeerDelegation *PeerDelegation `json:"peerDelegation,omitempty"`
+
+	// IdlePolicy suspends peers that have not connected for a while and
+	// releases their address to the pool, for fleets of rarely connecting
+	// devices. Unlike deleting them, suspended peers resume when they
+	// connect again.
+	IdlePolicy *IdlePolicy `json:"idlePolicy,omitempty"`
+
+	// ClientVersionPolicy is the minimum client version of the peers of the
+	// server, for coordinating fleet-wide client upgrades
+	ClientVersionPolicy *ClientVersionPolicy `json:"clientVersionPolicy,omitempty"`
+
+	// DeletionPolicy is what happens to the resources of the server when it
+	// is deleted. With Delete they are destroyed with it. With Retain its
+	// Deployment, keys, Secrets and Service are left intact, e.g. for
+	// forensics or a migration, labelled as orphaned for later clean-up.
+	// +kubebuilder:validation:Enum=Retain;Delete
+	// +kubebuilder:default=Delete
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// KeyStore is where the key pair of a server is stored
+// +kubebuilder:validation:XValidation:rule="self.type != 'Vault' || has(self.vault)",message="vault is required for the Vault type"
+type KeyStore struct {
+	// Type is the kind of store: Secret stores the key pair in the Secret
+	// of privateKeySecretRef, Vault in HashiCorp Vault
+	// +kubebuilder:validation:Enum=Secret;Vault
+	Type KeyStoreType `json:"type"`
+
+	// Vault configures the Vault store
+	// +optional
+	Vault *VaultKeyStore `json:"vault,omitempty"`
+}
+
+// KeyStoreType is the kind of store of server keys
+type KeyStoreType string
+
+const (
+	// KeyStoreSecret stores the key pair in a Kubernetes Secret
+	KeyStoreSecret KeyStoreType = "Secret"
+
+	// KeyStoreVault stores the key pair in HashiCorp Vault
+	KeyStoreVault KeyStoreType = "Vault"
+)
+
+// VaultKeyStore stores the key pair of a server in HashiCorp Vault. The
+// operator and the server pods log in with the Kubernetes auth method, the
+// operator with its own role, the pods with Role.
+type VaultKeyStore struct {
+	// Address is the URL of Vault, e.g. https://vault.vault:8200
+	// +kubebuilder:validation:Pattern=`^https?://`
+	Address string `json:"address"`
+
+	// Engine is the secrets engine the keys are stored with. KV stores the
+	// key pair in a KV version 2 secret the pods read. Transit encrypts
+	// the private key with a transit key and stores the ciphertext in the
+	// Secret of privateKeySecretRef, which the pods decrypt with Vault.
+	// +kubebuilder:validation:Enum=KV;Transit
+	// +kubebuilder:default=KV
+	// +optional
+	Engine VaultEngine `json:"engine,omitempty"`
+
+	// Mount is the mount path of the engine. Defaults to secret for KV and
+	// transit for Transit.
+	// +optional
+	Mount string `json:"mount,omitempty"`
+
+	// Path is the path of the KV secret holding the key pair, or the name
+	// of the transit key. Defaults to wireflow/<namespace>/<name> for KV
+	// and wireflow for Transit.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// AuthMount is the mount path of the Kubernetes auth method
+	// +kubebuilder:default=kubernetes
+	// +optional
+	AuthMount string `json:"authMount,omitempty"`
+
+	// Role is the role of the Kubernetes auth method the server pods log
+	// in with. It only needs to read the key pair, or decrypt with the
+	// transit key.
+	Role string `json:"role"`
+}
+
+// VaultEngine is a Vault secrets engine
+type VaultEngine string
+
+const (
+	// VaultEngineKV is the KV version 2 secrets engine
+	VaultEngineKV VaultEngine = "KV"
+
+	// VaultEngineTransit is the transit secrets engine
+	VaultEngineTransit VaultEngine = "Transit"
+)
+
+// KeyRotation defines when the server key pair is rotated. A WireGuard
+// interface holds a single key, so the next key is published in the status
+// a grace period before the server switches to it: the old key stays
+// active meanwhile, and clients can fetch their updated config ahead of
+// the switch.
+type KeyRotation struct {
+	// Interval is the time between two key switches, e.g. 2160h
+	Interval metav1.Duration `json:"interval"`
+
+	// GracePeriod is how long the next key is published before the server
+	// switches to it. Defaults to a day, and is shortened to the interval.
+	// +optional
+	GracePeriod *metav1.Duration `json:"gracePeriod,omitempty"`
+}
+
+// MaintenanceWindow is a recurring window for disruptive operations
+type MaintenanceWindow struct {
+	// Schedule is when the window opens, as a cron schedule of five fields
+	// (minute, hour, day of month, month and day of week), e.g. "0 2 * * 6"
+	// for Saturdays at 2:00
+	// +kubebuilder:validation:MinLength=9
+	Schedule string `json:"schedule"`
+
+	// Duration is how long the window stays open, e.g. 4h
+	Duration metav1.Duration `json:"duration"`
+
+	// TimeZone is the IANA time zone of the schedule, e.g. Europe/Berlin.
+	// Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// PendingOperation is a disruptive operation waiting for a maintenance
+// window
+type PendingOperation struct {
+	// Type is the kind of operation
+	Type PendingOperationType `json:"type"`
+
+	// Description describes the operation, e.g. the image rolled out
+	Description string `json:"description"`
+
+	// Since is when the operation started waiting
+	Since metav1.Time `json:"since"`
+
+	// NotBefore is when the next maintenance window opens
+	// +optional
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+}
+
+// PendingOperationType is the kind of a pending operation
+// +kubebuilder:validation:Enum=KeyRotation;ImageRollout;PoolMigration
+type PendingOperationType string
+
+const (
+	// PendingKeyRotation is the switch of a server to its next key
+	PendingKeyRotation PendingOperationType = "KeyRotation"
+
+	// PendingImageRollout is the rollout of a new image to the replicas
+	PendingImageRollout PendingOperationType = "ImageRollout"
+
+	// PendingPoolMigration is the next batch of a VPNPoolMigration
+	PendingPoolMigration PendingOperationType = "PoolMigration"
+)
+
+// KeyRotationStatus is the state of the server key rotation
+type KeyRotationStatus struct {
+	// LastRotationTime is when the server last switched keys
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+
+	// NextPublicKey is the public key the server switches to at SwitchTime
+	NextPublicKey string `json:"nextPublicKey,omitempty"`
+
+	// SwitchTime is when the server switches to NextPublicKey
+	SwitchTime *metav1.Time `json:"switchTime,omitempty"`
+}
+
+// DeletionPolicy is what happens to the resources of a server when it is
+// deleted
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyRetain leaves the resources of the server intact
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+
+	// DeletionPolicyDelete deletes the resources of the server with it
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+)
+
+// IdlePolicy defines when peers are suspended. Peers routing networks or
+// with an endpoint the server connects to are never suspended.
+type IdlePolicy struct {
+	// IdleDays is the number of days without a handshake after which a
+	// peer is suspended
+	// +kubebuilder:validation:Minimum=1
+	IdleDays int32 `json:"idleDays"`
+}
+
+// ClientVersionPolicy defines the minimum version of the clients of a
+// server. Versions are compared as dotted numbers, and clients that report
+// no version, or one that is not dotted numbers, are not checked.
+type ClientVersionPolicy struct {
+	// MinimumVersion is the lowest accepted client version, e.g. 1.4.0
+	// +kubebuilder:validation:Pattern=`^v?[0-9]+(\.[0-9]+)*$`
+	MinimumVersion string `json:"minimumVersion"`
+
+	// Implementation is the client implementation the policy applies to,
+	// e.g. wireflow. Clients of other implementations are not checked.
+	// Empty applies to every implementation.
+	// +optional
+	Implementation string `json:"implementation,omitempty"`
+
+	// Action is what happens to clients below MinimumVersion. Warn flags
+	// their peers ClientOutdated, Refuse also turns their enrollments away.
+	// +kubebuilder:validation:Enum=Warn;Refuse
+	// +kubebuilder:default=Warn
+	// +optional
+	Action ClientVersionAction `json:"action,omitempty"`
+}
+
+// ClientVersionAction is what happens to clients below the minimum version
+type ClientVersionAction string
+
+const (
+	// ClientVersionActionWarn flags outdated clients
+	ClientVersionActionWarn ClientVersionAction = "Warn"
+
+	// ClientVersionActionRefuse also refuses the enrollment of outdated
+	// clients
+	ClientVersionActionRefuse ClientVersionAction = "Refuse"
+)
+
+// PeerDelegation defines the groups managing the peers of a server. The
+// operator generates a Role and RoleBinding granting them VPNPeers and the
+// manage-peers verb on the server; the admission webhook then limits their
+// members to peers bound to servers they were delegated.
+type PeerDelegation struct {
+	// Groups are the groups, as authenticated by the API server, granted
+	// the management of the server's peers
+	// +kubebuilder:validation:MinItems=1
+	// +listType=set
+	Groups []string `json:"groups"`
+}
+
+// PropagatedMetadata is metadata copied to the resources of a server
+type PropagatedMetadata struct {
+	// Labels are set on every resource, e.g. team or cost-center. Cloud
+	// load balancers inherit the labels of their Service on most providers.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are set on every resource
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// FirewallPolicy is an ordered list of rules over the traffic of peers
+type FirewallPolicy struct {
+	// DefaultAction is taken on the traffic no rule matches
+	// +kubebuilder:validation:Enum=Allow;Deny
+	// +kubebuilder:default=Allow
+	// +optional
+	DefaultAction FirewallAction `json:"defaultAction,omitempty"`
+
+	// Rules are matched in order, the first matching rule deciding.
+	// Replies to allowed connections are always allowed.
+	// +kubebuilder:validation:MaxItems=256
+	// +optional
+	Rules []FirewallRule `json:"rules,omitempty"`
+}
+
+// FirewallAction is the action of a firewall rule
+type FirewallAction string
+
+const (
+	// FirewallAllow forwards the matching traffic
+	FirewallAllow FirewallAction = "Allow"
+
+	// FirewallDeny drops the matching traffic
+	FirewallDeny FirewallAction = "Deny"
+)
+
+// FirewallRule matches traffic of peers by source, destination, protocol
+// and port. Unset fields match any traffic.
+// +kubebuilder:validation:XValidation:rule="!has(self.ports) || (has(self.protocol) && self.protocol in ['TCP', 'UDP'])",message="ports require the TCP or UDP protocol"
+type FirewallRule struct {
+	// Name describes the rule, and is shown in the rules programmed
+	// +kubebuilder:validation:Pattern=`^[-a-zA-Z0-9_.]{1,63}$`
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Action is taken on the matching traffic
+	// +kubebuilder:validation:Enum=Allow;Deny
+	Action FirewallAction `json:"action"`
+
+	// Sources are the peer networks the traffic comes from, e.g. the
+	// address of a peer with a prefix length. Any peer if empty.
+	// +kubebuilder:validation:MaxItems=64
+	// +kubebuilder:validation:XValidation:rule="self.all(cidr, isCIDR(cidr))",message="sources must be networks, e.g. 10.9.0.0/24"
+	// +optional
+	Sources []string `json:"sources,omitempty"`
+
+	// Destinations are the networks the traffic goes to, e.g. the Service
+	// network of the cluster. Any destination if empty.
+	// +kubebuilder:validation:MaxItems=64
+	// +kubebuilder:validation:XValidation:rule="self.all(cidr, isCIDR(cidr))",message="destinations must be networks, e.g. 10.96.0.0/12"
+	// +optional
+	Destinations []string `json:"destinations,omitempty"`
+
+	// Protocol is the protocol of the traffic. Any protocol if unset.
+	// +kubebuilder:validation:Enum=TCP;UDP;ICMP
+	// +optional
+	Protocol string `json:"protocol,omitempty"`
+
+	// Ports are the destination ports or port ranges of TCP or UDP
+	// traffic, e.g. 443 or 8000-8080. Any port if empty.
+	// +kubebuilder:validation:MaxItems=32
+	// +kubebuilder:validation:items:Pattern=`^[0-9]{1,5}(-[0-9]{1,5})?$`
+	// +optional
+	Ports []string `json:"ports,omitempty"`
+}
+
+// PerformanceTuning tunes the sockets and queues of the data plane. Unset
+// knobs keep the defaults of the node.
+type PerformanceTuning struct {
+	// ReceiveBufferBytes is the maximum and default size of UDP receive
+	// buffers, net.core.rmem_max and rmem_default. Kernels that do not
+	// namespace these sysctls only apply them to host network pods.
+	// +kubebuilder:validation:Minimum=212992
+	// +kubebuilder:validation:Maximum=268435456
+	// +optional
+	ReceiveBufferBytes *int32 `json:"receiveBufferBytes,omitempty"`
+
+	// SendBufferBytes is the maximum and default size of UDP send buffers,
+	// net.core.wmem_max and wmem_default
+	// +kubebuilder:validation:Minimum=212992
+	// +kubebuilder:validation:Maximum=268435456
+	// +optional
+	SendBufferBytes *int32 `json:"sendBufferBytes,omitempty"`
+
+	// TxQueueLen is the transmit queue length of the WireGuard interface
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100000
+	// +optional
+	TxQueueLen *int32 `json:"txQueueLen,omitempty"`
+
+	// RPSCPUs is the hex mask of the CPUs the receive processing of the
+	// uplink interface is steered to (RPS), e.g. ff or ffff,ffffffff
+	// +kubebuilder:validation:Pattern=`^[0-9a-fA-F]{1,8}(,[0-9a-fA-F]{8})*$`
+	// +kubebuilder:validation:MaxLength=64
+	// +optional
+	RPSCPUs string `json:"rpsCPUs,omitempty"`
+
+	// XPSCPUs is the hex mask of the CPUs transmitting on the queues of the
+	// uplink interface (XPS)
+	// +kubebuilder:validation:Pattern=`^[0-9a-fA-F]{1,8}(,[0-9a-fA-F]{8})*$`
+	// +kubebuilder:validation:MaxLength=64
+	// +optional
+	XPSCPUs string `json:"xpsCPUs,omitempty"`
+}
+
+// WireGuardImplementation is the WireGuard implementation of the data plane
+type WireGuardImplementation string
+
+const (
+	// WireGuardKernel uses the kernel module of the node
+	WireGuardKernel WireGuardImplementation = "kernel"
+
+	// WireGuardUserspace runs wireguard-go on a TUN device where the kernel
+	// module is unavailable
+	WireGuardUserspace WireGuardImplementation = "userspace"
+)
+
+// FirewallBackend is the firewall implementation used by the agent
+type FirewallBackend string
+
+const (
+	// FirewallBackendAuto follows the backend already used on the node
+	FirewallBackendAuto FirewallBackend = "auto"
+
+	// FirewallBackendNFTables programs rules with nft
+	FirewallBackendNFTables FirewallBackend = "nftables"
+
+	// FirewallBackendIPTablesLegacy programs rules with iptables-legacy
+	FirewallBackendIPTablesLegacy FirewallBackend = "iptables-legacy"
+)
+
+// ClientRoutingProfile is a named set of routes pushed to clients
+// +kubebuilder:validation:XValidation:rule="!has(self.dnsZones) || !has(self.dns)",message="dns must not be set with dnsZones, other names are resolved by the server's dns"
+type ClientRoutingProfile struct {
+	// Name identifies the profile, e.g. full-tunnel or corp-only
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// AllowedIPs are the networks clients route through the tunnel
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:XValidation:rule="self.all(cidr, isCIDR(cidr))",message="allowedIPs must be networks, e.g. 10.0.0.0/8"
+	AllowedIPs []string `json:"allowedIPs"`
+
+	// DNS are the DNS servers pushed to clients. Defaults to the server's
+	// DNS.
+	// +kubebuilder:validation:MaxItems=3
+	// +kubebuilder:validation:XValidation:rule="self.all(server, isIP(server))",message="dns must be IP addresses"
+	// +listType=atomic
+	DNS []string `json:"dns,omitempty"`
+
+	// SearchDomains are the DNS search domains pushed to clients
+	// +kubebuilder:validation:MaxItems=6
+	// +listType=set
+	SearchDomains []string `json:"searchDomains,omitempty"`
+
+	// DNSZones are domains resolved by their own DNS servers, e.g. internal
+	// zones only the corporate resolvers know. Clients of a profile with
+	// zones use the split-DNS forwarder on the server's tunnel address,
+	// which forwards the zones to their servers and other names to the
+	// server's DNS.
+	// +kubebuilder:validation:MaxItems=32
+	// +listType=map
+	// +listMapKey=domain
+	DNSZones []DNSZone `json:"dnsZones,omitempty"`
+}
+
+// DNSZone is a domain resolved by its own DNS servers
+type DNSZone struct {
+	// Domain is the domain of the zone, including its subdomains, e.g.
+	// corp.example.com
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?\.)*[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Domain string `json:"domain"`
+
+	// Servers are the addresses of the DNS servers of the zone
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=4
+	// +kubebuilder:validation:XValidation:rule="self.all(server, isIP(server))",message="servers must be IP addresses"
+	Servers []string `json:"servers"`
+}
+
+// PlacementSpec defines how server replicas are placed on nodes
+type PlacementSpec struct {
+	// AvoidSaturatedNodes steers new replicas away from nodes whose NIC
+	// utilization is above the operator's threshold. With Preferred the
+	// scheduler falls back to saturated nodes, with Required it never uses
+	// them.
+	// +kubebuilder:validation:Enum=Preferred;Required
+	// +optional
+	AvoidSaturatedNodes SaturationAvoidance `json:"avoidSaturatedNodes,omitempty"`
+}
+
+// SaturationAvoidance is how strictly saturated nodes are avoided
+type SaturationAvoidance string
+
+const (
+	// SaturationAvoidancePreferred prefers nodes that are not saturated
+	SaturationAvoidancePreferred SaturationAvoidance = "Preferred"
+
+	// SaturationAvoidanceRequired excludes saturated nodes
+	SaturationAvoidanceRequired SaturationAvoidance = "Required"
+)
+
+// ClientConfigEncryption defines the envelope encryption of client configs.
+// Secrets hold the configs encrypted under a data key, which is wrapped by
+// the key management service: reading a config takes access to both the
+// Secret and the key. The operator only needs the permission to encrypt
+// with the key; kubectl wireflow decrypt-config decrypts with the
+// credentials of its user.
+// +kubebuilder:validation:XValidation:rule="self.provider != 'age' || self.keyID.startsWith('age1')",message="age keys are recipients, e.g. age1..."
+// +kubebuilder:validation:XValidation:rule="self.provider != 'gcp-kms' || self.keyID.startsWith('projects/')",message="Cloud KMS keys are resource names, e.g. projects/p/locations/l/keyRings/r/cryptoKeys/k"
+type ClientConfigEncryption struct {
+	// Provider is the key management service
+	// +kubebuilder:validation:Enum=aws-kms;gcp-kms;age
+	Provider EncryptionProvider `json:"provider"`
+
+	// KeyID identifies the key: the ARN of an AWS KMS key, the resource
+	// name of a Cloud KMS crypto key or an age recipient. The operator
+	// reads AWS and Google credentials from its environment.
+	// +kubebuilder:validation:MinLength=1
+	KeyID string `json:"keyID"`
+}
+
+// EncryptionProvider is a key management service
+type EncryptionProvider string
+
+const (
+	// EncryptionProviderAWSKMS wraps data keys with AWS KMS
+	EncryptionProviderAWSKMS EncryptionProvider = "aws-kms"
+
+	// EncryptionProviderGCPKMS wraps data keys with Google Cloud KMS
+	EncryptionProviderGCPKMS EncryptionProvider = "gcp-kms"
+
+	// EncryptionProviderAge wraps data keys to an age recipient, whose
+	// identity is kept outside the cluster
+	EncryptionProviderAge EncryptionProvider = "age"
+)
+
+// ComplianceSpec defines compliance controls applied by the agent and the
+// operator
+// +kubebuilder:validation:XValidation:rule="!has(self.sessionMetadata) || self.sessionMetadata == 'disabled' || (has(self.sink) && self.sink.url != '')",message="sink.url is required when sessionMetadata is recorded"
+type ComplianceSpec struct {
+	// SessionMetadata controls recording of peer session metadata: start and
+	// stop times, transferred bytes and endpoints. Traffic contents are never
+	// recorded. With enforce, a peer cannot pass traffic until the start of
+	// its session has been shipped to the sink.
+	// +kubebuilder:validation:Enum=disabled;enabled;enforce
+	// +optional
+	SessionMetadata SessionMetadataMode `json:"sessionMetadata,omitempty"`
+
+	// Sink is where session metadata is shipped
+	Sink *SessionSink `json:"sink,omitempty"`
+
+	// FailurePolicy applies when SessionMetadata is enforce and the sink
+	// cannot be reached. fail-closed keeps new peers blocked, fail-open lets
+	// them through and spools their records until the sink recovers.
+	// +kubebuilder:validation:Enum=fail-closed;fail-open
+	// +optional
+	FailurePolicy SessionFailurePolicy `json:"failurePolicy,omitempty"`
+
+	// ConnectionAudit records peers connecting and disconnecting, as seen
+	// from their handshakes appearing and expiring, to the listed sinks:
+	// Event records Kubernetes events on the server, which the cluster
+	// keeps for an hour by default; Session writes a VPNSession per
+	// session; Log writes a structured line to the log of the operator.
+	// Unlike SessionMetadata, it is recorded by the operator and does not
+	// gate traffic.
+	// +kubebuilder:validation:items:Enum=Event;Session;Log
+	// +listType=set
+	// +optional
+	ConnectionAudit []ConnectionAuditSink `json:"connectionAudit,omitempty"`
+
+	// SessionRetention is how long the VPNSessions of ended sessions are
+	// kept
+	// +kubebuilder:default="2160h"
+	// +optional
+	SessionRetention *metav1.Duration `json:"sessionRetention,omitempty"`
+}
+
+// ConnectionAuditSink is where peer connections are recorded
+type ConnectionAuditSink string
+
+const (
+	// ConnectionAuditEvent records Kubernetes events
+	ConnectionAuditEvent ConnectionAuditSink = "Event"
+
+	// ConnectionAuditSession writes VPNSessions
+	ConnectionAuditSession ConnectionAuditSink = "Session"
+
+	// ConnectionAuditLog writes structured log lines
+	ConnectionAuditLog ConnectionAuditSink = "Log"
+)
+
+// SessionSink defines an HTTP endpoint session records are shipped to
+type SessionSink struct {
+	// URL is the endpoint session records are POSTed to as JSON
+	URL string `json:"url"`
+
+	// TokenSecretRef references a bearer token sent with the records
+	TokenSecretRef *SecretKeyReference `json:"tokenSecretRef,omitempty"`
+}
+
+// SessionMetadataMode is the session metadata recording mode
+type SessionMetadataMode string
+
+const (
+	// SessionMetadataDisabled does not record sessions
+	SessionMetadataDisabled SessionMetadataMode = "disabled"
+
+	// SessionMetadataEnabled records sessions without gating traffic
+	SessionMetadataEnabled SessionMetadataMode = "enabled"
+
+	// SessionMetadataEnforce blocks a peer's traffic until its session start
+	// has been shipped
+	SessionMetadataEnforce SessionMetadataMode = "enforce"
+)
+
+// SessionFailurePolicy is the behavior of enforced recording when the sink
+// is unavailable
+type SessionFailurePolicy string
+
+const (
+	// SessionFailClosed blocks peers whose session start was not shipped
+	SessionFailClosed SessionFailurePolicy = "fail-closed"
+
+	// SessionFailOpen admits peers and spools their records for later
+	SessionFailOpen SessionFailurePolicy = "fail-open"
+)
+
+// VPNServerStatus defines the observed state of VPNServer
+type VPNServerStatus struct {
+	// ObservedGeneration is the generation of the spec the status reflects:
+	// the last generation whose configuration every ready replica applied
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Replicas is the current number of replicas
+	Replicas int32 `json:"replicas"`
+
+	// ReadyReplicas is the number of ready replicas
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// AvailableReplicas is the number of available replicas
+	AvailableReplicas int32 `json:"availableReplicas"`
+
+	// Selector is the label selector of the server pods, used by the scale
+	// subresource
+	Selector string `json:"selector,omitempty"`
+
+	// Conditions represent the latest available observations
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	// PublicKey is the VPN server public key
+	PublicKey string `json:"publicKey,omitempty"`
+
+	// KeyRotation is the state of the key rotation, if enabled
+	KeyRotation *KeyRotationStatus `json:"keyRotation,omitempty"`
+
+	// IPAM is the state of the client address allocation
+	IPAM *IPAMStatus `json:"ipam,omitempty"`
+
+	// Endpoint is the VPN server endpoint
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// ZoneEndpoints are the zone endpoints with the number of ready
+	// replicas in their zone
+	ZoneEndpoints []ZoneEndpointStatus `json:"zoneEndpoints,omitempty"`
+
+	// NodeEndpoints are the endpoints of the replicas of each node in the
+	// DaemonSet mode
+	NodeEndpoints []NodeEndpoint `json:"nodeEndpoints,omitempty"`
+
+	// Autoscaling is the last scaling decision of spec.autoscaling
+	Autoscaling *AutoscalingStatus `json:"autoscaling,omitempty"`
+
+	// ConnectedPeers is the number of peers with a recent handshake
+	ConnectedPeers int32 `json:"connectedPeers,omitempty"`
+
+	// TotalTraffic is the total traffic in bytes
+	TotalTraffic int64 `json:"totalTraffic,omitempty"`
+
+	// ConfigChecksum is the checksum of the desired WireGuard configuration
+	ConfigChecksum string `json:"configChecksum,omitempty"`
+
+	// ConfigGeneration is the generation of the spec the configuration of
+	// ConfigChecksum was rendered from
+	ConfigGeneration int64 `json:"configGeneration,omitempty"`
+
+	// AppliedConfigChecksum is the checksum of the configuration loaded by
+	// every ready replica. It equals ConfigChecksum once a change has landed.
+	AppliedConfigChecksum string `json:"appliedConfigChecksum,omitempty"`
+
+	// Drift is the difference between the live devices of the replicas and
+	// the desired configuration
+	Drift *DriftStatus `json:"drift,omitempty"`
+
+	// Performance is the data plane tuning in effect on the replicas
+	Performance *PerformanceStatus `json:"performance,omitempty"`
+
+	// DataPlane is the WireGuard implementation the ready replicas run, as
+	// they report it: userspace as soon as one replica runs wireguard-go,
+	// e.g. after falling back from a missing kernel module
+	// +optional
+	DataPlane WireGuardImplementation `json:"dataPlane,omitempty"`
+
+	// Shards are the replicas of a sharded server and the peers they serve
+	// +optional
+	Shards []ShardStatus `json:"shards,omitempty"`
+
+	// ActiveReplica is the pod that terminates the tunnels of a server in
+	// the high availability mode, empty while no replica holds the lease
+	// +optional
+	ActiveReplica string `json:"activeReplica,omitempty"`
+
+	// RolloutImage is the image the replicas run. It follows spec.image
+	// within the maintenance windows of the server.
+	// +optional
+	RolloutImage string `json:"rolloutImage,omitempty"`
+
+	// PendingOperations are the disruptive operations waiting for the next
+	// maintenance window
+	// +optional
+	PendingOperations []PendingOperation `json:"pendingOperations,omitempty"`
+
+	// PeeringExport is the state of the peering bundle of the server
+	// +optional
+	PeeringExport *PeeringExportStatus `json:"peeringExport,omitempty"`
+
+	// Peers is the last observed state of the server's peers
+	Peers []PeerStatus `json:"peers,omitempty"`
+
+	// Sessions are the peers connected to the server, recorded when
+	// spec.compliance.connectionAudit is set
+	// +listType=map
+	// +listMapKey=publicKey
+	Sessions []PeerSession `json:"sessions,omitempty"`
+}
+
+// DeploymentMode is the kind of workload the replicas of a server run in
+// +kubebuilder:validation:Enum=Deployment;DaemonSet;StatefulSet
+type DeploymentMode string
+
+const (
+	// DeploymentModeDeployment runs the replicas in a Deployment
+	DeploymentModeDeployment DeploymentMode = "Deployment"
+
+	// DeploymentModeDaemonSet runs a replica on every node in a DaemonSet
+	DeploymentModeDaemonSet DeploymentMode = "DaemonSet"
+
+	// DeploymentModeStatefulSet runs the replicas in a StatefulSet, with
+	// stable names
+	DeploymentModeStatefulSet DeploymentMode = "StatefulSet"
+)
+
+// ServiceSpec configures the Service of a server
+type ServiceSpec struct {
+	// Type is the type of the Service. The endpoint is discovered from the
+	// load balancer of LoadBalancer Services and from the external
+	// addresses of the nodes of the replicas for NodePort Services.
+	// ClusterIP Services need spec.endpointOverride.
+	// +kubebuilder:validation:Enum=LoadBalancer;NodePort;ClusterIP
+	// +kubebuilder:default=LoadBalancer
+	// +optional
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// Annotations are set on the Service, e.g. to configure the load
+	// balancer of the cloud provider
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// ExternalTrafficPolicy is the external traffic policy of LoadBalancer
+	// and NodePort Services. Local preserves the addresses of the clients.
+	// +kubebuilder:validation:Enum=Cluster;Local
+	// +optional
+	ExternalTrafficPolicy corev1.ServiceExternalTrafficPolicyType `json:"externalTrafficPolicy,omitempty"`
+}
+
+// EndpointDNS defines the DNS name of the endpoint of a server, whose
+// records external-dns maintains
+type EndpointDNS struct {
+	// Hostname is the DNS name of the endpoint, e.g. vpn.example.com
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?\.)+[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Hostname string `json:"hostname"`
+
+	// TTL is the TTL of the records in seconds. Defaults to the TTL of
+	// external-dns.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TTL *int64 `json:"ttl,omitempty"`
+
+	// Mode is how the records are handed to external-dns. Annotation
+	// annotates the Service, DNSEndpoint writes a DNSEndpoint resource,
+	// for external-dns instances watching the crd source only.
+	// +kubebuilder:validation:Enum=Annotation;DNSEndpoint
+	// +kubebuilder:default=Annotation
+	// +optional
+	Mode EndpointDNSMode `json:"mode,omitempty"`
+}
+
+// EndpointDNSMode is how the records of an endpoint are handed to
+// external-dns
+type EndpointDNSMode string
+
+const (
+	// EndpointDNSModeAnnotation annotates the Service of the server
+	EndpointDNSModeAnnotation EndpointDNSMode = "Annotation"
+
+	// EndpointDNSModeDNSEndpoint writes a DNSEndpoint resource
+	EndpointDNSModeDNSEndpoint EndpointDNSMode = "DNSEndpoint"
+)
+
+// PortRange is an inclusive range of UDP ports
+// +kubebuilder:validation:XValidation:rule="self.start <= self.end",message="start must not exceed end"
+type PortRange struct {
+	// Start is the first port of the range
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Start int32 `json:"start"`
+
+	// End is the last port of the range
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	End int32 `json:"end"`
+}
+
+// ShardingSpec splits the peers of a server across its replicas. Each peer
+// is assigned to a replica by rendezvous hashing of its public key, so that
+// scaling only moves the peers of the added or removed replicas. Every
+// replica is exposed by its own Service, through which the clients of its
+// peers reach it on its listen port.
+type ShardingSpec struct {
+	// ServiceType is the type of the Services of the replicas. Their
+	// annotations and external traffic policy are those of spec.service.
+	// +kubebuilder:validation:Enum=LoadBalancer;NodePort
+	// +kubebuilder:default=LoadBalancer
+	// +optional
+	ServiceType corev1.ServiceType `json:"serviceType,omitempty"`
+}
+
+// HighAvailabilitySpec elects the active replica of a server through a
+// coordination Lease, which the agents renew every watchdog interval. The
+// Service of the server only selects the active replica, so the endpoint
+// of the server stays the same across failovers. Standbys keep the peers
+// configured, and re-apply the peer state when they take over.
+type HighAvailabilitySpec struct {
+	// LeaseDurationSeconds is how long the active replica holds the lease
+	// without renewing it before a standby takes over. It bounds the
+	// failover time when the active replica fails without releasing it.
+	// +kubebuilder:validation:Minimum=4
+	// +kubebuilder:validation:Maximum=300
+	// +kubebuilder:default=10
+	// +optional
+	LeaseDurationSeconds int32 `json:"leaseDurationSeconds,omitempty"`
+}
+
+// PeeringExport configures the peering bundle of a server
+type PeeringExport struct {
+	// Sites are the networks behind the server, e.g. the pod and service
+	// networks of its cluster, routed to it by the importing clusters along
+	// with its tunnel network
+	// +kubebuilder:validation:XValidation:rule="self.all(cidr, isCIDR(cidr))",message="sites must be networks, e.g. 10.244.0.0/16"
+	// +optional
+	Sites []string `json:"sites,omitempty"`
+
+	// Validity is how long a bundle is valid. Bundles are signed again
+	// once two thirds of it have passed, and must be carried over to the
+	// importing clusters again before they expire.
+	// +kubebuilder:default="720h"
+	// +optional
+	Validity *metav1.Duration `json:"validity,omitempty"`
+}
+
+// PeeringExportStatus is the state of the peering bundle of a server
+type PeeringExportStatus struct {
+	// ConfigMap is the ConfigMap holding the bundle, in its bundle key
+	ConfigMap string `json:"configMap"`
+
+	// SigningKey is the public key the bundle is signed with, trusted by
+	// the importing clusters
+	SigningKey string `json:"signingKey"`
+
+	// ExpiresAt is when the bundle expires
+	ExpiresAt metav1.Time `json:"expiresAt"`
+}
+
+// ShardStatus is the state of the share of the peers of a replica
+type ShardStatus struct {
+	// Ordinal is the ordinal of the replica in its StatefulSet
+	Ordinal int32 `json:"ordinal"`
+
+	// Pod is the pod of the replica
+	Pod string `json:"pod"`
+
+	// Port is the UDP port the replica listens on
+	Port int32 `json:"port"`
+
+	// Endpoint is the endpoint the clients of the peers of the shard
+	// connect to, once discovered from the Service of the replica
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Peers is the number of peers assigned to the replica
+	Peers int32 `json:"peers"`
+
+	// Ready is whether the replica is ready
+	Ready bool `json:"ready"`
+}
+
+// NodeListenPort is the listen port of the replica of a node
+type NodeListenPort struct {
+	// Node is the name of the node
+	Node string `json:"node"`
+
+	// Port is the UDP port the replica of the node listens on
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+}
+
+// NodeEndpoint is the endpoint of the replica of a node
+type NodeEndpoint struct {
+	// Node is the name of the node
+	Node string `json:"node"`
+
+	// Endpoint is the address of the node with the listen port of its
+	// replica
+	Endpoint string `json:"endpoint"`
+
+	// Ready is whether the replica of the node is ready
+	Ready bool `json:"ready"`
+}
+
+// AutoscalingSpec scales the replicas of a server by load, as a
+// HorizontalPodAutoscaler would with per-replica targets. The replicas are
+// the most the targets call for: scale-ups apply at once, scale-downs after
+// the stabilization window.
+// +kubebuilder:validation:XValidation:rule="has(self.targetClientsPerReplica) || has(self.targetThroughputPerReplica)",message="at least one of targetClientsPerReplica and targetThroughputPerReplica is required"
+// +kubebuilder:validation:XValidation:rule="!has(self.minReplicas) || self.minReplicas <= self.maxReplicas",message="minReplicas must not exceed maxReplicas"
+type AutoscalingSpec struct {
+	// MinReplicas is the lowest number of replicas
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10
+	// +kubebuilder:default=1
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+
+	// MaxReplicas is the highest number of replicas
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10
+	MaxReplicas int32 `json:"maxReplicas"`
+
+	// TargetClientsPerReplica is the number of connected clients each
+	// replica should serve
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TargetClientsPerReplica *int32 `json:"targetClientsPerReplica,omitempty"`
+
+	// TargetThroughputPerReplica is the traffic in bytes per second each
+	// replica should carry, e.g. 50Mi, averaged over two minutes
+	// +optional
+	TargetThroughputPerReplica *resource.Quantity `json:"targetThroughputPerReplica,omitempty"`
+
+	// ScaleDownStabilizationWindow is how long after scaling the replicas
+	// are not reduced, so that fluctuating load does not flap them
+	// +kubebuilder:default="5m"
+	// +optional
+	ScaleDownStabilizationWindow *metav1.Duration `json:"scaleDownStabilizationWindow,omitempty"`
+}
+
+// AutoscalingStatus is the last scaling decision of a server
+type AutoscalingStatus struct {
+	// DesiredReplicas is the number of replicas the targets call for,
+	// within the bounds
+	DesiredReplicas int32 `json:"desiredReplicas"`
+
+	// CurrentThroughput is the traffic of the server in bytes per second
+	// the decision was made with
+	// +optional
+	CurrentThroughput *resource.Quantity `json:"currentThroughput,omitempty"`
+
+	// LastScaleTime is when the replicas were last changed by the operator
+	// +optional
+	LastScaleTime *metav1.Time `json:"lastScaleTime,omitempty"`
+}
+
+// ZoneEndpoint is the endpoint of the replicas of a zone
+type ZoneEndpoint struct {
+	// Zone is the topology.kubernetes.io/zone of the nodes of the replicas
+	Zone string `json:"zone"`
+
+	// Region is the region of the zone, which region hints of peers match
+	// along with the zone
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Endpoint is the host:port clients connect to the replicas of the
+	// zone through
+	// +kubebuilder:validation:MinLength=1
+	Endpoint string `json:"endpoint"`
+}
+
+// ZoneEndpointStatus is the state of a zone endpoint
+type ZoneEndpointStatus struct {
+	ZoneEndpoint `json:",inline"`
+
+	// ReadyReplicas is the number of ready replicas in the zone. Clients
+	// are only pointed at zones with ready replicas.
+	ReadyReplicas int32 `json:"readyReplicas"`
+}
+
+// IPAMStatus is the state of the client address allocation of a server
+type IPAMStatus struct {
+	// ClientCIDR is the network client addresses are allocated from
+	ClientCIDR string `json:"clientCIDR,omitempty"`
+
+	// Capacity is the number of addresses that can be allocated
+	Capacity int64 `json:"capacity,omitempty"`
+
+	// Allocated is the number of peers addressed from ClientCIDR
+	Allocated int64 `json:"allocated,omitempty"`
+
+	// Conflicts are the peers whose address is taken by an older peer or
+	// the server
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// PerformanceStatus is the data plane tuning in effect on the replicas of a
+// server
+type PerformanceStatus struct {
+	// Replicas are the values in effect on each replica
+	Replicas []ReplicaPerformance `json:"replicas,omitempty"`
+}
+
+// ReplicaPerformance is the data plane tuning in effect on a replica, as
+// read back by its agent
+type ReplicaPerformance struct {
+	// Pod is the name of the pod of the replica
+	Pod string `json:"pod"`
+
+	// ReportedTime is when the agent read the values back
+	ReportedTime *metav1.Time `json:"reportedTime,omitempty"`
+
+	// ReceiveBufferBytes is net.core.rmem_max
+	ReceiveBufferBytes int64 `json:"receiveBufferBytes,omitempty"`
+
+	// SendBufferBytes is net.core.wmem_max
+	SendBufferBytes int64 `json:"sendBufferBytes,omitempty"`
+
+	// TxQueueLen is the transmit queue length of the WireGuard interface
+	TxQueueLen int32 `json:"txQueueLen,omitempty"`
+
+	// RPSCPUs is the RPS CPU mask of the uplink interface
+	RPSCPUs string `json:"rpsCPUs,omitempty"`
+
+	// XPSCPUs is the XPS CPU mask of the uplink interface
+	XPSCPUs string `json:"xpsCPUs,omitempty"`
+
+	// Errors are the knobs the agent failed to apply, with the reason
+	Errors []string `json:"errors,omitempty"`
+}
+
+// DriftStatus is the difference between the live devices of the replicas of
+// a server and its desired configuration
+type DriftStatus struct {
+	// CheckedTime is when the replica checked least recently compared its
+	// device with the desired configuration. The server had converged at
+	// that time if no replica drifts.
+	CheckedTime *metav1.Time `json:"checkedTime,omitempty"`
+
+	// Replicas are the replicas whose device differs from the desired
+	// configuration
+	Replicas []ReplicaDrift `json:"replicas,omitempty"`
+}
+
+// ReplicaDrift is the difference between the live device of a replica and
+// the desired configuration
+type ReplicaDrift struct {
+	// Pod is the name of the pod of the replica
+	Pod string `json:"pod"`
+
+	// CheckedTime is when the replica compared its device
+	CheckedTime *metav1.Time `json:"checkedTime,omitempty"`
+
+	// MissingPeers are the desired peers absent from the device, by name or
+	// by public key for keys of no VPNPeer
+	MissingPeers []string `json:"missingPeers,omitempty"`
+
+	// UnexpectedPeers are the peers on the device that are not desired
+	UnexpectedPeers []string `json:"unexpectedPeers,omitempty"`
+
+	// ChangedPeers are the peers whose settings on the device differ
+	ChangedPeers []PeerDrift `json:"changedPeers,omitempty"`
+
+	// Interface are the interface settings of the device that differ, e.g.
+	// listen-port
+	Interface []string `json:"interface,omitempty"`
+}
+
+// PeerDrift is the difference between a peer on the device and its desired
+// settings
+type PeerDrift struct {
+	// Name is the name of the peer, or its public key for keys of no VPNPeer
+	Name string `json:"name"`
+
+	// Fields are the settings that differ: allowed-ips, preshared-key or
+	// persistent-keepalive
+	Fields []string `json:"fields"`
+}
+
+// PeerStatus is the observed state of a peer on the WireGuard device
+type PeerStatus struct {
+	// Name is the name of the peer
+	Name string `json:"name,omitempty"`
+
+	// PublicKey is the public key of the peer
+	PublicKey string `json:"publicKey"`
+
+	// Endpoint is the last seen endpoint of the peer
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// LatestHandshake is the time of the last handshake with the peer
+	LatestHandshake *metav1.Time `json:"latestHandshake,omitempty"`
+
+	// ReceiveBytes is the number of bytes received from the peer
+	ReceiveBytes int64 `json:"receiveBytes,omitempty"`
+
+	// TransmitBytes is the number of bytes sent to the peer
+	TransmitBytes int64 `json:"transmitBytes,omitempty"`
+
+	// RoundTripTime is the round-trip time of the tunnel to the peer,
+	// estimated by the replica of its latest handshake for the peers
+	// keeping the tunnel alive, such as the links of networks
+	// +optional
+	RoundTripTime *metav1.Duration `json:"roundTripTime,omitempty"`
+}
+
+// PeerSession is a session of a peer in progress
+type PeerSession struct {
+	// Name is the name of the peer
+	Name string `json:"name"`
+
+	// PublicKey is the public key of the peer
+	PublicKey string `json:"publicKey"`
+
+	// Endpoint is the address the peer connected from
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// StartTime is the first handshake of the session
+	StartTime metav1.Time `json:"startTime"`
+}
+
+// MonitoringSpec exports per-peer Prometheus metrics on the metrics
+// endpoint of the operator
+type MonitoringSpec struct {
+	// Enabled exports the metrics of the server's peers and generates a
+	// ServiceMonitor scraping them, when the Prometheus Operator is
+	// installed
+	Enabled bool `json:"enabled"`
+
+	// Interval is the scrape interval of the ServiceMonitor
+	// +kubebuilder:default="30s"
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// Labels are added to the ServiceMonitor, e.g. the release label the
+	// Prometheus instance selects ServiceMonitors by
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// RouteExportProvider is a CNI the client network of a server is published
+// to
+// +kubebuilder:validation:Enum=Calico;Cilium
+type RouteExportProvider string
+
+const (
+	// RouteExportProviderCalico publishes the client network as a disabled
+	// Calico IPPool, which Calico neither allocates from nor masquerades
+	// traffic to
+	RouteExportProviderCalico RouteExportProvider = "Calico"
+
+	// RouteExportProviderCilium publishes the client network as a
+	// CiliumCIDRGroup, which network policies and egress rules can reference
+	RouteExportProviderCilium RouteExportProvider = "Cilium"
+)
+
+// RouteExportSpec publishes the client network of a server to the CNI
+type RouteExportSpec struct {
+	// Provider is the CNI the client network is published to
+	Provider RouteExportProvider `json:"provider"`
+}
+
+// AlertingSpec defines health thresholds evaluated by the operator. A
+// breached threshold sets the HealthAlert condition and notifies the
+// configured webhooks.
+type AlertingSpec struct {
+	// EvaluationInterval is how often the thresholds are evaluated
+	EvaluationInterval *metav1.Duration `json:"evaluationInterval,omitempty"`
+
+	// StaleHandshakeThreshold is the handshake age after which a peer is
+	// considered stale
+	StaleHandshakeThreshold *metav1.Duration `json:"staleHandshakeThreshold,omitempty"`
+
+	// MobileStaleHandshakeThreshold is the handshake age after which a peer
+	// with the mobile power profile is considered stale. Defaults to an hour.
+	MobileStaleHandshakeThreshold *metav1.Duration `json:"mobileStaleHandshakeThreshold,omitempty"`
+
+	// MaxStalePeers is the number of stale peers tolerated
+	// +kubebuilder:validation:Minimum=0
+	MaxStalePeers int32 `json:"maxStalePeers,omitempty"`
+
+	// TrafficFloor is the minimum throughput in bytes per second
+	// +kubebuilder:validation:Minimum=0
+	TrafficFloor *int64 `json:"trafficFloor,omitempty"`
+
+	// TrafficCeiling is the maximum throughput in bytes per second
+	// +kubebuilder:validation:Minimum=0
+	TrafficCeiling *int64 `json:"trafficCeiling,omitempty"`
+
+	// MaxPeerChurnPerHour is the maximum rate of peer connects and
+	// disconnects per hour
+	// +kubebuilder:validation:Minimum=0
+	MaxPeerChurnPerHour *int32 `json:"maxPeerChurnPerHour,omitempty"`
+
+	// UsageAnomaly alerts on peers whose usage departs from their baseline
+	UsageAnomaly *UsageAnomalySpec `json:"usageAnomaly,omitempty"`
+
+	// Webhooks are notified when the HealthAlert condition changes
+	Webhooks []AlertWebhook `json:"webhooks,omitempty"`
+}
+
+// UsageAnomalySpec defines how peer usage is compared to its baseline. The
+// baselines are kept in the <server>-usage-baseline ConfigMap so that they
+// survive operator restarts.
+type UsageAnomalySpec struct {
+	// TrafficFactor is how many times its baseline daily traffic a peer
+	// transfers in a day before its usage is anomalous
+	// +kubebuilder:validation:Minimum=2
+	// +kubebuilder:default=5
+	// +optional
+	TrafficFactor int32 `json:"trafficFactor,omitempty"`
+
+	// MinimumTraffic is the daily traffic in bytes below which usage is
+	// never anomalous. Defaults to 100MiB.
+	// +kubebuilder:validation:Minimum=0
+	MinimumTraffic *int64 `json:"minimumTraffic,omitempty"`
+
+	// LearningDays is the number of days of usage a baseline needs before
+	// it is compared against
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=7
+	// +optional
+	LearningDays int32 `json:"learningDays,omitempty"`
+
+	// NewNetworks alerts when a peer connects from an autonomous system it
+	// has not used before. Requires the operator to run with --asn-lookup.
+	NewNetworks bool `json:"newNetworks,omitempty"`
+}
+
+// AlertWebhook defines a notification webhook
+type AlertWebhook struct {
+	// URL is the endpoint alerts are POSTed to as JSON
+	URL string `json:"url"`
+
+	// TokenSecretRef references a bearer token sent with the notification
+	TokenSecretRef *SecretKeyReference `json:"tokenSecretRef,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=vpns,categories=wireflow
+// +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
+// +kubebuilder:printcolumn:name="Replicas",type="integer",JSONPath=".status.replicas"
+// +kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.readyReplicas"
+// +kubebuilder:printcolumn:name="Data Plane",type="string",JSONPath=".status.dataPlane",priority=1
+// +kubebuilder:printcolumn:name="Active",type="string",JSONPath=".status.activeReplica",priority=1
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// VPNServer is the Schema for the vpnservers API
+type VPNServer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VPNServerSpec   `json:"spec,omitempty"`
+	Status VPNServerStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VPNServerList contains a list of VPNServer
+type VPNServerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPNServer `json:"items"`
+}
+
+// ResourceRequirements defines resource requirements
+type ResourceRequirements struct {
+	Limits   ResourceList `json:"limits,omitempty"`
+	Requests ResourceList `json:"requests,omitempty"`
+}
+
+// ResourceList defines resource quantities
+type ResourceList struct {
+	CPU    string `json:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty"`
+}
+
+// Toleration defines pod toleration
+type Toleration struct {
+	Key      string `json:"key,omitempty"`
+	Operator string `json:"operator,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Effect   string `json:"effect,omitempty"`
+}
+
+// Affinity defines pod affinity rules
+type Affinity struct {
+	NodeAffinity    *NodeAffinity    `json:"nodeAffinity,omitempty"`
+	PodAffinity     *PodAffinity     `json:"podAffinity,omitempty"`
+	PodAntiAffinity *PodAntiAffinity `json:"podAntiAffinity,omitempty"`
+}
+
+// NodeAffinity defines node affinity rules
+type NodeAffinity struct {
+	RequiredDuringSchedulingIgnoredDuringExecution *NodeSelector `json:"requiredDuringSchedulingIgnoredDuringExecution,omitempty"`
+}
+
+// NodeSelector defines node selection constraints
+type NodeSelector struct {
+	NodeSelectorTerms []NodeSelectorTerm `json:"nodeSelectorTerms"`
+}
+
+// NodeSelectorTerm defines node selection term
+type NodeSelectorTerm struct {
+	MatchExpressions []NodeSelectorRequirement `json:"matchExpressions,omitempty"`
+}
+
+// NodeSelectorRequirement defines node selector requirement
+type NodeSelectorRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// PodAffinity defines pod affinity rules
+type PodAffinity struct {
+	RequiredDuringSchedulingIgnoredDuringExecution []PodAffinityTerm `json:"requiredDuringSchedulingIgnoredDuringExecution,omitempty"`
+}
+
+// PodAntiAffinity defines pod anti-affinity rules
+type PodAntiAffinity struct {
+	RequiredDuringSchedulingIgnoredDuringExecution []PodAffinityTerm `json:"requiredDuringSchedulingIgnoredDuringExecution,omitempty"`
+}
+
+// PodAffinityTerm defines pod affinity term
+type PodAffinityTerm struct {
+	LabelSelector *LabelSelector `json:"labelSelector,omitempty"`
+	Namespaces    []string       `json:"namespaces,omitempty"`
+	TopologyKey   string         `json:"topologyKey"`
+}
+
+// LabelSelector defines label selection
+type LabelSelector struct {
+	MatchLabels      map[string]string          `json:"matchLabels,omitempty"`
+	MatchExpressions []LabelSelectorRequirement `json:"matchExpressions,omitempty"`
+}
+
+// LabelSelectorRequirement defines label selector requirement
+type LabelSelectorRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// Condition defines a condition
+type Condition struct {
+	Type               string      `json:"type"`
+	Status             string      `json:"status"`
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+	Reason             string      `json:"reason,omitempty"`
+	Message            string      `json:"message,omitempty"`
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+}
+
+// SecretKeyReference references a key of a Secret in the same namespace
+type SecretKeyReference struct {
+	Name string `json:"name"`
+	Key  string `json:"key,omitempty"`
+}
+
+// LocalObjectReference references an object in the same namespace
+type LocalObjectReference struct {
+	Name string `json:"name"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPNServer{}, &VPNServerList{})
+}
@@ -0,0 +1,170 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	http "net/http"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	rest "k8s.io/client-go/rest"
+)
+
+type VpnV1alpha1Interface interface {
+	RESTClient() rest.Interface
+	MemberClustersGetter
+	VPNAccessPoliciesGetter
+	VPNBrandingsGetter
+	VPNChatIntegrationsGetter
+	VPNConnectivityChecksGetter
+	VPNGlobalPoliciesGetter
+	VPNIPPoolsGetter
+	VPNIngressMapsGetter
+	VPNKeyDenyListsGetter
+	VPNNetworksGetter
+	VPNPeersGetter
+	VPNPeerGroupsGetter
+	VPNPeeringsGetter
+	VPNPoolMigrationsGetter
+	VPNSelfEnrollmentsGetter
+	VPNServersGetter
+	VPNServerClassesGetter
+	VPNSessionsGetter
+}
+
+// VpnV1alpha1Client is used to interact with features provided by the vpn.vpn-devops.com group.
+type VpnV1alpha1Client struct {
+	restClient rest.Interface
+}
+
+func (c *VpnV1alpha1Client) MemberClusters(namespace string) MemberClusterInterface {
+	return newMemberClusters(c, namespace)
+}
+
+func (c *VpnV1alpha1Client) VPNAccessPolicies(namespace string) VPNAccessPolicyInterface {
+	return newVPNAccessPolicies(c, namespace)
+}
+
+func (c *VpnV1alpha1Client) VPNBrandings(namespace string) VPNBrandingInterface {
+	return newVPNBrandings(c, namespace)
+}
+
+func (c *VpnV1alpha1Client) VPNChatIntegrations(namespace string) VPNChatIntegrationInterface {
+	return newVPNChatIntegrations(c, namespace)
+}
+
+func (c *VpnV1alpha1Client) VPNConnectivityChecks(namespace string) VPNConnectivityCheckInterface {
+	return newVPNConnectivityChecks(c, namespace)
+}
+
+func (c *VpnV1alpha1Client) VPNGlobalPolicies() VPNGlobalPolicyInterface {
+	return newVPNGlobalPolicies(c)
+}
+
+func (c *VpnV1alpha1Client) VPNIPPools(namespace string) VPNIPPoolInterface {
+	return newVPNIPPools(c, namespace)
+}
+
+func (c *VpnV1alpha1Client) VPNIngressMaps(namespace string) VPNIngressMapInterface {
+	return newVPNIngressMaps(c, namespace)
+}
+
+func (c *VpnV1alpha1Client) VPNKeyDenyLists() VPNKeyDenyListInterface {
+	return newVPNKeyDenyLists(c)
+}
+
+func (c *VpnV1alpha1Client) VPNNetworks(namespace string) VPNNetworkInterface {
+	return newVPNNetworks(c, namespace)
+}
+
+func (c *VpnV1alpha1Client) VPNPeers(namespace string) VPNPeerInterface {
+	return newVPNPeers(c, namespace)
+}
+
+func (c *VpnV1alpha1Client) VPNPeerGroups(namespace string) VPNPeerGroupInterface {
+	return newVPNPeerGroups(c, namespace)
+}
+
+func (c *VpnV1alpha1Client) VPNPeerings(namespace string) VPNPeeringInterface {
+	return newVPNPeerings(c, namespace)
+}
+
+func (c *VpnV1alpha1Client) VPNPoolMigrations(namespace string) VPNPoolMigrationInterface {
+	return newVPNPoolMigrations(c, namespace)
+}
+
+func (c *VpnV1alpha1Client) VPNSelfEnrollments(namespace string) VPNSelfEnrollmentInterface {
+	return newVPNSelfEnrollments(c, namespace)
+}
+
+func (c *VpnV1alpha1Client) VPNServers(namespace string) VPNServerInterface {
+	return newVPNServers(c, namespace)
+}
+
+func (c *VpnV1alpha1Client) VPNServerClasses() VPNServerClassInterface {
+	return newVPNServerClasses(c)
+}
+
+func (c *VpnV1alpha1Client) VPNSessions(namespace string) VPNSessionInterface {
+	return newVPNSessions(c, namespace)
+}
+
+// NewForConfig creates a new VpnV1alpha1Client for the given config.
+// NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
+// where httpClient was generated with rest.HTTPClientFor(c).
+func NewForConfig(c *rest.Config) (*VpnV1alpha1Client, error) {
+	config := *c
+	setConfigDefaults(&config)
+	httpClient, err := rest.HTTPClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+	return NewForConfigAndClient(&config, httpClient)
+}
+
+// NewForConfigAndClient creates a new VpnV1alpha1Client for the given config and http client.
+// Note the http client provided takes precedence over the configured transport values.
+func NewForConfigAndClient(c *rest.Config, h *http.Client) (*VpnV1alpha1Client, error) {
+	config := *c
+	setConfigDefaults(&config)
+	client, err := rest.RESTClientForConfigAndClient(&config, h)
+	if err != nil {
+		return nil, err
+	}
+	return &VpnV1alpha1Client{client}, nil
+}
+
+// NewForConfigOrDie creates a new VpnV1alpha1Client for the given config and
+// panics if there is an error in the config.
+func NewForConfigOrDie(c *rest.Config) *VpnV1alpha1Client {
+	client, err := NewForConfig(c)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// New creates a new VpnV1alpha1Client for the given RESTClient.
+func New(c rest.Interface) *VpnV1alpha1Client {
+	return &VpnV1alpha1Client{c}
+}
+
+func setConfigDefaults(config *rest.Config) {
+	gv := apiv1alpha1.SchemeGroupVersion
+	config.GroupVersion = &gv
+	config.APIPath = "/apis"
+	config.NegotiatedSerializer = rest.CodecFactoryForGeneratedClient(scheme.Scheme, scheme.Codecs).WithoutConversion()
+
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+}
+
+// RESTClient returns a RESTClient that is used to communicate
+// with API server by this client implementation.
+func (c *VpnV1alpha1Client) RESTClient() rest.Interface {
+	if c == nil {
+		return nil
+	}
+	return c.restClient
+}
@@ -0,0 +1,92 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	rest "k8s.io/client-go/rest"
+	testing "k8s.io/client-go/testing"
+)
+
+type FakeVpnV1alpha1 struct {
+	*testing.Fake
+}
+
+func (c *FakeVpnV1alpha1) MemberClusters(namespace string) v1alpha1.MemberClusterInterface {
+	return newFakeMemberClusters(c, namespace)
+}
+
+func (c *FakeVpnV1alpha1) VPNAccessPolicies(namespace string) v1alpha1.VPNAccessPolicyInterface {
+	return newFakeVPNAccessPolicies(c, namespace)
+}
+
+func (c *FakeVpnV1alpha1) VPNBrandings(namespace string) v1alpha1.VPNBrandingInterface {
+	return newFakeVPNBrandings(c, namespace)
+}
+
+func (c *FakeVpnV1alpha1) VPNChatIntegrations(namespace string) v1alpha1.VPNChatIntegrationInterface {
+	return newFakeVPNChatIntegrations(c, namespace)
+}
+
+func (c *FakeVpnV1alpha1) VPNConnectivityChecks(namespace string) v1alpha1.VPNConnectivityCheckInterface {
+	return newFakeVPNConnectivityChecks(c, namespace)
+}
+
+func (c *FakeVpnV1alpha1) VPNGlobalPolicies() v1alpha1.VPNGlobalPolicyInterface {
+	return newFakeVPNGlobalPolicies(c)
+}
+
+func (c *FakeVpnV1alpha1) VPNIPPools(namespace string) v1alpha1.VPNIPPoolInterface {
+	return newFakeVPNIPPools(c, namespace)
+}
+
+func (c *FakeVpnV1alpha1) VPNIngressMaps(namespace string) v1alpha1.VPNIngressMapInterface {
+	return newFakeVPNIngressMaps(c, namespace)
+}
+
+func (c *FakeVpnV1alpha1) VPNKeyDenyLists() v1alpha1.VPNKeyDenyListInterface {
+	return newFakeVPNKeyDenyLists(c)
+}
+
+func (c *FakeVpnV1alpha1) VPNNetworks(namespace string) v1alpha1.VPNNetworkInterface {
+	return newFakeVPNNetworks(c, namespace)
+}
+
+func (c *FakeVpnV1alpha1) VPNPeers(namespace string) v1alpha1.VPNPeerInterface {
+	return newFakeVPNPeers(c, namespace)
+}
+
+func (c *FakeVpnV1alpha1) VPNPeerGroups(namespace string) v1alpha1.VPNPeerGroupInterface {
+	return newFakeVPNPeerGroups(c, namespace)
+}
+
+func (c *FakeVpnV1alpha1) VPNPeerings(namespace string) v1alpha1.VPNPeeringInterface {
+	return newFakeVPNPeerings(c, namespace)
+}
+
+func (c *FakeVpnV1alpha1) VPNPoolMigrations(namespace string) v1alpha1.VPNPoolMigrationInterface {
+	return newFakeVPNPoolMigrations(c, namespace)
+}
+
+func (c *FakeVpnV1alpha1) VPNSelfEnrollments(namespace string) v1alpha1.VPNSelfEnrollmentInterface {
+	return newFakeVPNSelfEnrollments(c, namespace)
+}
+
+func (c *FakeVpnV1alpha1) VPNServers(namespace string) v1alpha1.VPNServerInterface {
+	return newFakeVPNServers(c, namespace)
+}
+
+func (c *FakeVpnV1alpha1) VPNServerClasses() v1alpha1.VPNServerClassInterface {
+	return newFakeVPNServerClasses(c)
+}
+
+func (c *FakeVpnV1alpha1) VPNSessions(namespace string) v1alpha1.VPNSessionInterface {
+	return newFakeVPNSessions(c, namespace)
+}
+
+// RESTClient returns a RESTClient that is used to communicate
+// with API server by this client implementation.
+func (c *FakeVpnV1alpha1) RESTClient() rest.Interface {
+	var ret *rest.RESTClient
+	return ret
+}
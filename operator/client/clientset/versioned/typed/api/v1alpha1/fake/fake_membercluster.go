@@ -0,0 +1,36 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeMemberClusters implements MemberClusterInterface
+type fakeMemberClusters struct {
+	*gentype.FakeClientWithList[*v1alpha1.MemberCluster, *v1alpha1.MemberClusterList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeMemberClusters(fake *FakeVpnV1alpha1, namespace string) apiv1alpha1.MemberClusterInterface {
+	return &fakeMemberClusters{
+		gentype.NewFakeClientWithList[*v1alpha1.MemberCluster, *v1alpha1.MemberClusterList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("memberclusters"),
+			v1alpha1.SchemeGroupVersion.WithKind("MemberCluster"),
+			func() *v1alpha1.MemberCluster { return &v1alpha1.MemberCluster{} },
+			func() *v1alpha1.MemberClusterList { return &v1alpha1.MemberClusterList{} },
+			func(dst, src *v1alpha1.MemberClusterList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.MemberClusterList) []*v1alpha1.MemberCluster {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1alpha1.MemberClusterList, items []*v1alpha1.MemberCluster) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
@@ -0,0 +1,36 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNAccessPolicies implements VPNAccessPolicyInterface
+type fakeVPNAccessPolicies struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNAccessPolicy, *v1alpha1.VPNAccessPolicyList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNAccessPolicies(fake *FakeVpnV1alpha1, namespace string) apiv1alpha1.VPNAccessPolicyInterface {
+	return &fakeVPNAccessPolicies{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNAccessPolicy, *v1alpha1.VPNAccessPolicyList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("vpnaccesspolicies"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNAccessPolicy"),
+			func() *v1alpha1.VPNAccessPolicy { return &v1alpha1.VPNAccessPolicy{} },
+			func() *v1alpha1.VPNAccessPolicyList { return &v1alpha1.VPNAccessPolicyList{} },
+			func(dst, src *v1alpha1.VPNAccessPolicyList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNAccessPolicyList) []*v1alpha1.VPNAccessPolicy {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1alpha1.VPNAccessPolicyList, items []*v1alpha1.VPNAccessPolicy) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
@@ -0,0 +1,36 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNBrandings implements VPNBrandingInterface
+type fakeVPNBrandings struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNBranding, *v1alpha1.VPNBrandingList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNBrandings(fake *FakeVpnV1alpha1, namespace string) apiv1alpha1.VPNBrandingInterface {
+	return &fakeVPNBrandings{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNBranding, *v1alpha1.VPNBrandingList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("vpnbrandings"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNBranding"),
+			func() *v1alpha1.VPNBranding { return &v1alpha1.VPNBranding{} },
+			func() *v1alpha1.VPNBrandingList { return &v1alpha1.VPNBrandingList{} },
+			func(dst, src *v1alpha1.VPNBrandingList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNBrandingList) []*v1alpha1.VPNBranding {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1alpha1.VPNBrandingList, items []*v1alpha1.VPNBranding) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
@@ -0,0 +1,36 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNChatIntegrations implements VPNChatIntegrationInterface
+type fakeVPNChatIntegrations struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNChatIntegration, *v1alpha1.VPNChatIntegrationList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNChatIntegrations(fake *FakeVpnV1alpha1, namespace string) apiv1alpha1.VPNChatIntegrationInterface {
+	return &fakeVPNChatIntegrations{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNChatIntegration, *v1alpha1.VPNChatIntegrationList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("vpnchatintegrations"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNChatIntegration"),
+			func() *v1alpha1.VPNChatIntegration { return &v1alpha1.VPNChatIntegration{} },
+			func() *v1alpha1.VPNChatIntegrationList { return &v1alpha1.VPNChatIntegrationList{} },
+			func(dst, src *v1alpha1.VPNChatIntegrationList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNChatIntegrationList) []*v1alpha1.VPNChatIntegration {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1alpha1.VPNChatIntegrationList, items []*v1alpha1.VPNChatIntegration) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
@@ -0,0 +1,36 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNConnectivityChecks implements VPNConnectivityCheckInterface
+type fakeVPNConnectivityChecks struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNConnectivityCheck, *v1alpha1.VPNConnectivityCheckList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNConnectivityChecks(fake *FakeVpnV1alpha1, namespace string) apiv1alpha1.VPNConnectivityCheckInterface {
+	return &fakeVPNConnectivityChecks{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNConnectivityCheck, *v1alpha1.VPNConnectivityCheckList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("vpnconnectivitychecks"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNConnectivityCheck"),
+			func() *v1alpha1.VPNConnectivityCheck { return &v1alpha1.VPNConnectivityCheck{} },
+			func() *v1alpha1.VPNConnectivityCheckList { return &v1alpha1.VPNConnectivityCheckList{} },
+			func(dst, src *v1alpha1.VPNConnectivityCheckList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNConnectivityCheckList) []*v1alpha1.VPNConnectivityCheck {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1alpha1.VPNConnectivityCheckList, items []*v1alpha1.VPNConnectivityCheck) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
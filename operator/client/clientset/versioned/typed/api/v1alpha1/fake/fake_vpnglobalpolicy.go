@@ -0,0 +1,36 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNGlobalPolicies implements VPNGlobalPolicyInterface
+type fakeVPNGlobalPolicies struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNGlobalPolicy, *v1alpha1.VPNGlobalPolicyList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNGlobalPolicies(fake *FakeVpnV1alpha1) apiv1alpha1.VPNGlobalPolicyInterface {
+	return &fakeVPNGlobalPolicies{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNGlobalPolicy, *v1alpha1.VPNGlobalPolicyList](
+			fake.Fake,
+			"",
+			v1alpha1.SchemeGroupVersion.WithResource("vpnglobalpolicies"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNGlobalPolicy"),
+			func() *v1alpha1.VPNGlobalPolicy { return &v1alpha1.VPNGlobalPolicy{} },
+			func() *v1alpha1.VPNGlobalPolicyList { return &v1alpha1.VPNGlobalPolicyList{} },
+			func(dst, src *v1alpha1.VPNGlobalPolicyList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNGlobalPolicyList) []*v1alpha1.VPNGlobalPolicy {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1alpha1.VPNGlobalPolicyList, items []*v1alpha1.VPNGlobalPolicy) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
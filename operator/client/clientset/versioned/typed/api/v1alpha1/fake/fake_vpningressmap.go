@@ -0,0 +1,36 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNIngressMaps implements VPNIngressMapInterface
+type fakeVPNIngressMaps struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNIngressMap, *v1alpha1.VPNIngressMapList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNIngressMaps(fake *FakeVpnV1alpha1, namespace string) apiv1alpha1.VPNIngressMapInterface {
+	return &fakeVPNIngressMaps{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNIngressMap, *v1alpha1.VPNIngressMapList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("vpningressmaps"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNIngressMap"),
+			func() *v1alpha1.VPNIngressMap { return &v1alpha1.VPNIngressMap{} },
+			func() *v1alpha1.VPNIngressMapList { return &v1alpha1.VPNIngressMapList{} },
+			func(dst, src *v1alpha1.VPNIngressMapList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNIngressMapList) []*v1alpha1.VPNIngressMap {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1alpha1.VPNIngressMapList, items []*v1alpha1.VPNIngressMap) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
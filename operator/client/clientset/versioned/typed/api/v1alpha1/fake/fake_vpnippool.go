@@ -0,0 +1,34 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNIPPools implements VPNIPPoolInterface
+type fakeVPNIPPools struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNIPPool, *v1alpha1.VPNIPPoolList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNIPPools(fake *FakeVpnV1alpha1, namespace string) apiv1alpha1.VPNIPPoolInterface {
+	return &fakeVPNIPPools{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNIPPool, *v1alpha1.VPNIPPoolList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("vpnippools"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNIPPool"),
+			func() *v1alpha1.VPNIPPool { return &v1alpha1.VPNIPPool{} },
+			func() *v1alpha1.VPNIPPoolList { return &v1alpha1.VPNIPPoolList{} },
+			func(dst, src *v1alpha1.VPNIPPoolList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNIPPoolList) []*v1alpha1.VPNIPPool { return gentype.ToPointerSlice(list.Items) },
+			func(list *v1alpha1.VPNIPPoolList, items []*v1alpha1.VPNIPPool) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
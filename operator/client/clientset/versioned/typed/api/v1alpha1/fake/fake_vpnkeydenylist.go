@@ -0,0 +1,36 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNKeyDenyLists implements VPNKeyDenyListInterface
+type fakeVPNKeyDenyLists struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNKeyDenyList, *v1alpha1.VPNKeyDenyListList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNKeyDenyLists(fake *FakeVpnV1alpha1) apiv1alpha1.VPNKeyDenyListInterface {
+	return &fakeVPNKeyDenyLists{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNKeyDenyList, *v1alpha1.VPNKeyDenyListList](
+			fake.Fake,
+			"",
+			v1alpha1.SchemeGroupVersion.WithResource("vpnkeydenylists"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNKeyDenyList"),
+			func() *v1alpha1.VPNKeyDenyList { return &v1alpha1.VPNKeyDenyList{} },
+			func() *v1alpha1.VPNKeyDenyListList { return &v1alpha1.VPNKeyDenyListList{} },
+			func(dst, src *v1alpha1.VPNKeyDenyListList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNKeyDenyListList) []*v1alpha1.VPNKeyDenyList {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1alpha1.VPNKeyDenyListList, items []*v1alpha1.VPNKeyDenyList) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
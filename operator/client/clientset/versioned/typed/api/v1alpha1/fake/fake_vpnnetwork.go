@@ -0,0 +1,34 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNNetworks implements VPNNetworkInterface
+type fakeVPNNetworks struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNNetwork, *v1alpha1.VPNNetworkList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNNetworks(fake *FakeVpnV1alpha1, namespace string) apiv1alpha1.VPNNetworkInterface {
+	return &fakeVPNNetworks{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNNetwork, *v1alpha1.VPNNetworkList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("vpnnetworks"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNNetwork"),
+			func() *v1alpha1.VPNNetwork { return &v1alpha1.VPNNetwork{} },
+			func() *v1alpha1.VPNNetworkList { return &v1alpha1.VPNNetworkList{} },
+			func(dst, src *v1alpha1.VPNNetworkList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNNetworkList) []*v1alpha1.VPNNetwork { return gentype.ToPointerSlice(list.Items) },
+			func(list *v1alpha1.VPNNetworkList, items []*v1alpha1.VPNNetwork) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
@@ -0,0 +1,34 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNPeers implements VPNPeerInterface
+type fakeVPNPeers struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNPeer, *v1alpha1.VPNPeerList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNPeers(fake *FakeVpnV1alpha1, namespace string) apiv1alpha1.VPNPeerInterface {
+	return &fakeVPNPeers{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNPeer, *v1alpha1.VPNPeerList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("vpnpeers"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNPeer"),
+			func() *v1alpha1.VPNPeer { return &v1alpha1.VPNPeer{} },
+			func() *v1alpha1.VPNPeerList { return &v1alpha1.VPNPeerList{} },
+			func(dst, src *v1alpha1.VPNPeerList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNPeerList) []*v1alpha1.VPNPeer { return gentype.ToPointerSlice(list.Items) },
+			func(list *v1alpha1.VPNPeerList, items []*v1alpha1.VPNPeer) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
@@ -0,0 +1,36 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNPeerGroups implements VPNPeerGroupInterface
+type fakeVPNPeerGroups struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNPeerGroup, *v1alpha1.VPNPeerGroupList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNPeerGroups(fake *FakeVpnV1alpha1, namespace string) apiv1alpha1.VPNPeerGroupInterface {
+	return &fakeVPNPeerGroups{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNPeerGroup, *v1alpha1.VPNPeerGroupList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("vpnpeergroups"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNPeerGroup"),
+			func() *v1alpha1.VPNPeerGroup { return &v1alpha1.VPNPeerGroup{} },
+			func() *v1alpha1.VPNPeerGroupList { return &v1alpha1.VPNPeerGroupList{} },
+			func(dst, src *v1alpha1.VPNPeerGroupList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNPeerGroupList) []*v1alpha1.VPNPeerGroup {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1alpha1.VPNPeerGroupList, items []*v1alpha1.VPNPeerGroup) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
@@ -0,0 +1,34 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNPeerings implements VPNPeeringInterface
+type fakeVPNPeerings struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNPeering, *v1alpha1.VPNPeeringList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNPeerings(fake *FakeVpnV1alpha1, namespace string) apiv1alpha1.VPNPeeringInterface {
+	return &fakeVPNPeerings{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNPeering, *v1alpha1.VPNPeeringList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("vpnpeerings"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNPeering"),
+			func() *v1alpha1.VPNPeering { return &v1alpha1.VPNPeering{} },
+			func() *v1alpha1.VPNPeeringList { return &v1alpha1.VPNPeeringList{} },
+			func(dst, src *v1alpha1.VPNPeeringList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNPeeringList) []*v1alpha1.VPNPeering { return gentype.ToPointerSlice(list.Items) },
+			func(list *v1alpha1.VPNPeeringList, items []*v1alpha1.VPNPeering) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
@@ -0,0 +1,36 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNPoolMigrations implements VPNPoolMigrationInterface
+type fakeVPNPoolMigrations struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNPoolMigration, *v1alpha1.VPNPoolMigrationList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNPoolMigrations(fake *FakeVpnV1alpha1, namespace string) apiv1alpha1.VPNPoolMigrationInterface {
+	return &fakeVPNPoolMigrations{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNPoolMigration, *v1alpha1.VPNPoolMigrationList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("vpnpoolmigrations"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNPoolMigration"),
+			func() *v1alpha1.VPNPoolMigration { return &v1alpha1.VPNPoolMigration{} },
+			func() *v1alpha1.VPNPoolMigrationList { return &v1alpha1.VPNPoolMigrationList{} },
+			func(dst, src *v1alpha1.VPNPoolMigrationList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNPoolMigrationList) []*v1alpha1.VPNPoolMigration {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1alpha1.VPNPoolMigrationList, items []*v1alpha1.VPNPoolMigration) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
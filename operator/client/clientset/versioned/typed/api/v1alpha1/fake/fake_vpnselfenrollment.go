@@ -0,0 +1,36 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNSelfEnrollments implements VPNSelfEnrollmentInterface
+type fakeVPNSelfEnrollments struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNSelfEnrollment, *v1alpha1.VPNSelfEnrollmentList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNSelfEnrollments(fake *FakeVpnV1alpha1, namespace string) apiv1alpha1.VPNSelfEnrollmentInterface {
+	return &fakeVPNSelfEnrollments{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNSelfEnrollment, *v1alpha1.VPNSelfEnrollmentList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("vpnselfenrollments"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNSelfEnrollment"),
+			func() *v1alpha1.VPNSelfEnrollment { return &v1alpha1.VPNSelfEnrollment{} },
+			func() *v1alpha1.VPNSelfEnrollmentList { return &v1alpha1.VPNSelfEnrollmentList{} },
+			func(dst, src *v1alpha1.VPNSelfEnrollmentList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNSelfEnrollmentList) []*v1alpha1.VPNSelfEnrollment {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1alpha1.VPNSelfEnrollmentList, items []*v1alpha1.VPNSelfEnrollment) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
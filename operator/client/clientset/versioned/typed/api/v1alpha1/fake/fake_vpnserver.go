@@ -0,0 +1,34 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNServers implements VPNServerInterface
+type fakeVPNServers struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNServer, *v1alpha1.VPNServerList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNServers(fake *FakeVpnV1alpha1, namespace string) apiv1alpha1.VPNServerInterface {
+	return &fakeVPNServers{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNServer, *v1alpha1.VPNServerList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("vpnservers"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNServer"),
+			func() *v1alpha1.VPNServer { return &v1alpha1.VPNServer{} },
+			func() *v1alpha1.VPNServerList { return &v1alpha1.VPNServerList{} },
+			func(dst, src *v1alpha1.VPNServerList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNServerList) []*v1alpha1.VPNServer { return gentype.ToPointerSlice(list.Items) },
+			func(list *v1alpha1.VPNServerList, items []*v1alpha1.VPNServer) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
@@ -0,0 +1,36 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNServerClasses implements VPNServerClassInterface
+type fakeVPNServerClasses struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNServerClass, *v1alpha1.VPNServerClassList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNServerClasses(fake *FakeVpnV1alpha1) apiv1alpha1.VPNServerClassInterface {
+	return &fakeVPNServerClasses{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNServerClass, *v1alpha1.VPNServerClassList](
+			fake.Fake,
+			"",
+			v1alpha1.SchemeGroupVersion.WithResource("vpnserverclasses"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNServerClass"),
+			func() *v1alpha1.VPNServerClass { return &v1alpha1.VPNServerClass{} },
+			func() *v1alpha1.VPNServerClassList { return &v1alpha1.VPNServerClassList{} },
+			func(dst, src *v1alpha1.VPNServerClassList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNServerClassList) []*v1alpha1.VPNServerClass {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1alpha1.VPNServerClassList, items []*v1alpha1.VPNServerClass) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
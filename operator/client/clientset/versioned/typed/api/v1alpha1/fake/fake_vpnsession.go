@@ -0,0 +1,34 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1alpha1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNSessions implements VPNSessionInterface
+type fakeVPNSessions struct {
+	*gentype.FakeClientWithList[*v1alpha1.VPNSession, *v1alpha1.VPNSessionList]
+	Fake *FakeVpnV1alpha1
+}
+
+func newFakeVPNSessions(fake *FakeVpnV1alpha1, namespace string) apiv1alpha1.VPNSessionInterface {
+	return &fakeVPNSessions{
+		gentype.NewFakeClientWithList[*v1alpha1.VPNSession, *v1alpha1.VPNSessionList](
+			fake.Fake,
+			namespace,
+			v1alpha1.SchemeGroupVersion.WithResource("vpnsessions"),
+			v1alpha1.SchemeGroupVersion.WithKind("VPNSession"),
+			func() *v1alpha1.VPNSession { return &v1alpha1.VPNSession{} },
+			func() *v1alpha1.VPNSessionList { return &v1alpha1.VPNSessionList{} },
+			func(dst, src *v1alpha1.VPNSessionList) { dst.ListMeta = src.ListMeta },
+			func(list *v1alpha1.VPNSessionList) []*v1alpha1.VPNSession { return gentype.ToPointerSlice(list.Items) },
+			func(list *v1alpha1.VPNSessionList, items []*v1alpha1.VPNSession) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
@@ -0,0 +1,39 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+type MemberClusterExpansion interface{}
+
+type VPNAccessPolicyExpansion interface{}
+
+type VPNBrandingExpansion interface{}
+
+type VPNChatIntegrationExpansion interface{}
+
+type VPNConnectivityCheckExpansion interface{}
+
+type VPNGlobalPolicyExpansion interface{}
+
+type VPNIPPoolExpansion interface{}
+
+type VPNIngressMapExpansion interface{}
+
+type VPNKeyDenyListExpansion interface{}
+
+type VPNNetworkExpansion interface{}
+
+type VPNPeerExpansion interface{}
+
+type VPNPeerGroupExpansion interface{}
+
+type VPNPeeringExpansion interface{}
+
+type VPNPoolMigrationExpansion interface{}
+
+type VPNSelfEnrollmentExpansion interface{}
+
+type VPNServerExpansion interface{}
+
+type VPNServerClassExpansion interface{}
+
+type VPNSessionExpansion interface{}
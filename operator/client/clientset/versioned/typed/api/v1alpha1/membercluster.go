@@ -0,0 +1,54 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// MemberClustersGetter has a method to return a MemberClusterInterface.
+// A group's client should implement this interface.
+type MemberClustersGetter interface {
+	MemberClusters(namespace string) MemberClusterInterface
+}
+
+// MemberClusterInterface has methods to work with MemberCluster resources.
+type MemberClusterInterface interface {
+	Create(ctx context.Context, memberCluster *apiv1alpha1.MemberCluster, opts v1.CreateOptions) (*apiv1alpha1.MemberCluster, error)
+	Update(ctx context.Context, memberCluster *apiv1alpha1.MemberCluster, opts v1.UpdateOptions) (*apiv1alpha1.MemberCluster, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, memberCluster *apiv1alpha1.MemberCluster, opts v1.UpdateOptions) (*apiv1alpha1.MemberCluster, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.MemberCluster, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.MemberClusterList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.MemberCluster, err error)
+	MemberClusterExpansion
+}
+
+// memberClusters implements MemberClusterInterface
+type memberClusters struct {
+	*gentype.ClientWithList[*apiv1alpha1.MemberCluster, *apiv1alpha1.MemberClusterList]
+}
+
+// newMemberClusters returns a MemberClusters
+func newMemberClusters(c *VpnV1alpha1Client, namespace string) *memberClusters {
+	return &memberClusters{
+		gentype.NewClientWithList[*apiv1alpha1.MemberCluster, *apiv1alpha1.MemberClusterList](
+			"memberclusters",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1alpha1.MemberCluster { return &apiv1alpha1.MemberCluster{} },
+			func() *apiv1alpha1.MemberClusterList { return &apiv1alpha1.MemberClusterList{} },
+		),
+	}
+}
@@ -0,0 +1,54 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNAccessPoliciesGetter has a method to return a VPNAccessPolicyInterface.
+// A group's client should implement this interface.
+type VPNAccessPoliciesGetter interface {
+	VPNAccessPolicies(namespace string) VPNAccessPolicyInterface
+}
+
+// VPNAccessPolicyInterface has methods to work with VPNAccessPolicy resources.
+type VPNAccessPolicyInterface interface {
+	Create(ctx context.Context, vPNAccessPolicy *apiv1alpha1.VPNAccessPolicy, opts v1.CreateOptions) (*apiv1alpha1.VPNAccessPolicy, error)
+	Update(ctx context.Context, vPNAccessPolicy *apiv1alpha1.VPNAccessPolicy, opts v1.UpdateOptions) (*apiv1alpha1.VPNAccessPolicy, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, vPNAccessPolicy *apiv1alpha1.VPNAccessPolicy, opts v1.UpdateOptions) (*apiv1alpha1.VPNAccessPolicy, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.VPNAccessPolicy, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.VPNAccessPolicyList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.VPNAccessPolicy, err error)
+	VPNAccessPolicyExpansion
+}
+
+// vPNAccessPolicies implements VPNAccessPolicyInterface
+type vPNAccessPolicies struct {
+	*gentype.ClientWithList[*apiv1alpha1.VPNAccessPolicy, *apiv1alpha1.VPNAccessPolicyList]
+}
+
+// newVPNAccessPolicies returns a VPNAccessPolicies
+func newVPNAccessPolicies(c *VpnV1alpha1Client, namespace string) *vPNAccessPolicies {
+	return &vPNAccessPolicies{
+		gentype.NewClientWithList[*apiv1alpha1.VPNAccessPolicy, *apiv1alpha1.VPNAccessPolicyList](
+			"vpnaccesspolicies",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1alpha1.VPNAccessPolicy { return &apiv1alpha1.VPNAccessPolicy{} },
+			func() *apiv1alpha1.VPNAccessPolicyList { return &apiv1alpha1.VPNAccessPolicyList{} },
+		),
+	}
+}
@@ -0,0 +1,54 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNBrandingsGetter has a method to return a VPNBrandingInterface.
+// A group's client should implement this interface.
+type VPNBrandingsGetter interface {
+	VPNBrandings(namespace string) VPNBrandingInterface
+}
+
+// VPNBrandingInterface has methods to work with VPNBranding resources.
+type VPNBrandingInterface interface {
+	Create(ctx context.Context, vPNBranding *apiv1alpha1.VPNBranding, opts v1.CreateOptions) (*apiv1alpha1.VPNBranding, error)
+	Update(ctx context.Context, vPNBranding *apiv1alpha1.VPNBranding, opts v1.UpdateOptions) (*apiv1alpha1.VPNBranding, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, vPNBranding *apiv1alpha1.VPNBranding, opts v1.UpdateOptions) (*apiv1alpha1.VPNBranding, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.VPNBranding, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.VPNBrandingList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.VPNBranding, err error)
+	VPNBrandingExpansion
+}
+
+// vPNBrandings implements VPNBrandingInterface
+type vPNBrandings struct {
+	*gentype.ClientWithList[*apiv1alpha1.VPNBranding, *apiv1alpha1.VPNBrandingList]
+}
+
+// newVPNBrandings returns a VPNBrandings
+func newVPNBrandings(c *VpnV1alpha1Client, namespace string) *vPNBrandings {
+	return &vPNBrandings{
+		gentype.NewClientWithList[*apiv1alpha1.VPNBranding, *apiv1alpha1.VPNBrandingList](
+			"vpnbrandings",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1alpha1.VPNBranding { return &apiv1alpha1.VPNBranding{} },
+			func() *apiv1alpha1.VPNBrandingList { return &apiv1alpha1.VPNBrandingList{} },
+		),
+	}
+}
@@ -0,0 +1,54 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNChatIntegrationsGetter has a method to return a VPNChatIntegrationInterface.
+// A group's client should implement this interface.
+type VPNChatIntegrationsGetter interface {
+	VPNChatIntegrations(namespace string) VPNChatIntegrationInterface
+}
+
+// VPNChatIntegrationInterface has methods to work with VPNChatIntegration resources.
+type VPNChatIntegrationInterface interface {
+	Create(ctx context.Context, vPNChatIntegration *apiv1alpha1.VPNChatIntegration, opts v1.CreateOptions) (*apiv1alpha1.VPNChatIntegration, error)
+	Update(ctx context.Context, vPNChatIntegration *apiv1alpha1.VPNChatIntegration, opts v1.UpdateOptions) (*apiv1alpha1.VPNChatIntegration, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, vPNChatIntegration *apiv1alpha1.VPNChatIntegration, opts v1.UpdateOptions) (*apiv1alpha1.VPNChatIntegration, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.VPNChatIntegration, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.VPNChatIntegrationList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.VPNChatIntegration, err error)
+	VPNChatIntegrationExpansion
+}
+
+// vPNChatIntegrations implements VPNChatIntegrationInterface
+type vPNChatIntegrations struct {
+	*gentype.ClientWithList[*apiv1alpha1.VPNChatIntegration, *apiv1alpha1.VPNChatIntegrationList]
+}
+
+// newVPNChatIntegrations returns a VPNChatIntegrations
+func newVPNChatIntegrations(c *VpnV1alpha1Client, namespace string) *vPNChatIntegrations {
+	return &vPNChatIntegrations{
+		gentype.NewClientWithList[*apiv1alpha1.VPNChatIntegration, *apiv1alpha1.VPNChatIntegrationList](
+			"vpnchatintegrations",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1alpha1.VPNChatIntegration { return &apiv1alpha1.VPNChatIntegration{} },
+			func() *apiv1alpha1.VPNChatIntegrationList { return &apiv1alpha1.VPNChatIntegrationList{} },
+		),
+	}
+}
@@ -0,0 +1,54 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNConnectivityChecksGetter has a method to return a VPNConnectivityCheckInterface.
+// A group's client should implement this interface.
+type VPNConnectivityChecksGetter interface {
+	VPNConnectivityChecks(namespace string) VPNConnectivityCheckInterface
+}
+
+// VPNConnectivityCheckInterface has methods to work with VPNConnectivityCheck resources.
+type VPNConnectivityCheckInterface interface {
+	Create(ctx context.Context, vPNConnectivityCheck *apiv1alpha1.VPNConnectivityCheck, opts v1.CreateOptions) (*apiv1alpha1.VPNConnectivityCheck, error)
+	Update(ctx context.Context, vPNConnectivityCheck *apiv1alpha1.VPNConnectivityCheck, opts v1.UpdateOptions) (*apiv1alpha1.VPNConnectivityCheck, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, vPNConnectivityCheck *apiv1alpha1.VPNConnectivityCheck, opts v1.UpdateOptions) (*apiv1alpha1.VPNConnectivityCheck, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.VPNConnectivityCheck, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.VPNConnectivityCheckList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.VPNConnectivityCheck, err error)
+	VPNConnectivityCheckExpansion
+}
+
+// vPNConnectivityChecks implements VPNConnectivityCheckInterface
+type vPNConnectivityChecks struct {
+	*gentype.ClientWithList[*apiv1alpha1.VPNConnectivityCheck, *apiv1alpha1.VPNConnectivityCheckList]
+}
+
+// newVPNConnectivityChecks returns a VPNConnectivityChecks
+func newVPNConnectivityChecks(c *VpnV1alpha1Client, namespace string) *vPNConnectivityChecks {
+	return &vPNConnectivityChecks{
+		gentype.NewClientWithList[*apiv1alpha1.VPNConnectivityCheck, *apiv1alpha1.VPNConnectivityCheckList](
+			"vpnconnectivitychecks",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1alpha1.VPNConnectivityCheck { return &apiv1alpha1.VPNConnectivityCheck{} },
+			func() *apiv1alpha1.VPNConnectivityCheckList { return &apiv1alpha1.VPNConnectivityCheckList{} },
+		),
+	}
+}
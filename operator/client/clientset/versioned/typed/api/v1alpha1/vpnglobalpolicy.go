@@ -0,0 +1,54 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNGlobalPoliciesGetter has a method to return a VPNGlobalPolicyInterface.
+// A group's client should implement this interface.
+type VPNGlobalPoliciesGetter interface {
+	VPNGlobalPolicies() VPNGlobalPolicyInterface
+}
+
+// VPNGlobalPolicyInterface has methods to work with VPNGlobalPolicy resources.
+type VPNGlobalPolicyInterface interface {
+	Create(ctx context.Context, vPNGlobalPolicy *apiv1alpha1.VPNGlobalPolicy, opts v1.CreateOptions) (*apiv1alpha1.VPNGlobalPolicy, error)
+	Update(ctx context.Context, vPNGlobalPolicy *apiv1alpha1.VPNGlobalPolicy, opts v1.UpdateOptions) (*apiv1alpha1.VPNGlobalPolicy, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, vPNGlobalPolicy *apiv1alpha1.VPNGlobalPolicy, opts v1.UpdateOptions) (*apiv1alpha1.VPNGlobalPolicy, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.VPNGlobalPolicy, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.VPNGlobalPolicyList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.VPNGlobalPolicy, err error)
+	VPNGlobalPolicyExpansion
+}
+
+// vPNGlobalPolicies implements VPNGlobalPolicyInterface
+type vPNGlobalPolicies struct {
+	*gentype.ClientWithList[*apiv1alpha1.VPNGlobalPolicy, *apiv1alpha1.VPNGlobalPolicyList]
+}
+
+// newVPNGlobalPolicies returns a VPNGlobalPolicies
+func newVPNGlobalPolicies(c *VpnV1alpha1Client) *vPNGlobalPolicies {
+	return &vPNGlobalPolicies{
+		gentype.NewClientWithList[*apiv1alpha1.VPNGlobalPolicy, *apiv1alpha1.VPNGlobalPolicyList](
+			"vpnglobalpolicies",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			"",
+			func() *apiv1alpha1.VPNGlobalPolicy { return &apiv1alpha1.VPNGlobalPolicy{} },
+			func() *apiv1alpha1.VPNGlobalPolicyList { return &apiv1alpha1.VPNGlobalPolicyList{} },
+		),
+	}
+}
@@ -0,0 +1,54 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNIngressMapsGetter has a method to return a VPNIngressMapInterface.
+// A group's client should implement this interface.
+type VPNIngressMapsGetter interface {
+	VPNIngressMaps(namespace string) VPNIngressMapInterface
+}
+
+// VPNIngressMapInterface has methods to work with VPNIngressMap resources.
+type VPNIngressMapInterface interface {
+	Create(ctx context.Context, vPNIngressMap *apiv1alpha1.VPNIngressMap, opts v1.CreateOptions) (*apiv1alpha1.VPNIngressMap, error)
+	Update(ctx context.Context, vPNIngressMap *apiv1alpha1.VPNIngressMap, opts v1.UpdateOptions) (*apiv1alpha1.VPNIngressMap, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, vPNIngressMap *apiv1alpha1.VPNIngressMap, opts v1.UpdateOptions) (*apiv1alpha1.VPNIngressMap, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.VPNIngressMap, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.VPNIngressMapList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.VPNIngressMap, err error)
+	VPNIngressMapExpansion
+}
+
+// vPNIngressMaps implements VPNIngressMapInterface
+type vPNIngressMaps struct {
+	*gentype.ClientWithList[*apiv1alpha1.VPNIngressMap, *apiv1alpha1.VPNIngressMapList]
+}
+
+// newVPNIngressMaps returns a VPNIngressMaps
+func newVPNIngressMaps(c *VpnV1alpha1Client, namespace string) *vPNIngressMaps {
+	return &vPNIngressMaps{
+		gentype.NewClientWithList[*apiv1alpha1.VPNIngressMap, *apiv1alpha1.VPNIngressMapList](
+			"vpningressmaps",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1alpha1.VPNIngressMap { return &apiv1alpha1.VPNIngressMap{} },
+			func() *apiv1alpha1.VPNIngressMapList { return &apiv1alpha1.VPNIngressMapList{} },
+		),
+	}
+}
@@ -0,0 +1,54 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNIPPoolsGetter has a method to return a VPNIPPoolInterface.
+// A group's client should implement this interface.
+type VPNIPPoolsGetter interface {
+	VPNIPPools(namespace string) VPNIPPoolInterface
+}
+
+// VPNIPPoolInterface has methods to work with VPNIPPool resources.
+type VPNIPPoolInterface interface {
+	Create(ctx context.Context, vPNIPPool *apiv1alpha1.VPNIPPool, opts v1.CreateOptions) (*apiv1alpha1.VPNIPPool, error)
+	Update(ctx context.Context, vPNIPPool *apiv1alpha1.VPNIPPool, opts v1.UpdateOptions) (*apiv1alpha1.VPNIPPool, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, vPNIPPool *apiv1alpha1.VPNIPPool, opts v1.UpdateOptions) (*apiv1alpha1.VPNIPPool, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.VPNIPPool, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.VPNIPPoolList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.VPNIPPool, err error)
+	VPNIPPoolExpansion
+}
+
+// vPNIPPools implements VPNIPPoolInterface
+type vPNIPPools struct {
+	*gentype.ClientWithList[*apiv1alpha1.VPNIPPool, *apiv1alpha1.VPNIPPoolList]
+}
+
+// newVPNIPPools returns a VPNIPPools
+func newVPNIPPools(c *VpnV1alpha1Client, namespace string) *vPNIPPools {
+	return &vPNIPPools{
+		gentype.NewClientWithList[*apiv1alpha1.VPNIPPool, *apiv1alpha1.VPNIPPoolList](
+			"vpnippools",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1alpha1.VPNIPPool { return &apiv1alpha1.VPNIPPool{} },
+			func() *apiv1alpha1.VPNIPPoolList { return &apiv1alpha1.VPNIPPoolList{} },
+		),
+	}
+}
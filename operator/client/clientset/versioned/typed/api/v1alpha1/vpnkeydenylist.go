@@ -0,0 +1,52 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNKeyDenyListsGetter has a method to return a VPNKeyDenyListInterface.
+// A group's client should implement this interface.
+type VPNKeyDenyListsGetter interface {
+	VPNKeyDenyLists() VPNKeyDenyListInterface
+}
+
+// VPNKeyDenyListInterface has methods to work with VPNKeyDenyList resources.
+type VPNKeyDenyListInterface interface {
+	Create(ctx context.Context, vPNKeyDenyList *apiv1alpha1.VPNKeyDenyList, opts v1.CreateOptions) (*apiv1alpha1.VPNKeyDenyList, error)
+	Update(ctx context.Context, vPNKeyDenyList *apiv1alpha1.VPNKeyDenyList, opts v1.UpdateOptions) (*apiv1alpha1.VPNKeyDenyList, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.VPNKeyDenyList, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.VPNKeyDenyListList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.VPNKeyDenyList, err error)
+	VPNKeyDenyListExpansion
+}
+
+// vPNKeyDenyLists implements VPNKeyDenyListInterface
+type vPNKeyDenyLists struct {
+	*gentype.ClientWithList[*apiv1alpha1.VPNKeyDenyList, *apiv1alpha1.VPNKeyDenyListList]
+}
+
+// newVPNKeyDenyLists returns a VPNKeyDenyLists
+func newVPNKeyDenyLists(c *VpnV1alpha1Client) *vPNKeyDenyLists {
+	return &vPNKeyDenyLists{
+		gentype.NewClientWithList[*apiv1alpha1.VPNKeyDenyList, *apiv1alpha1.VPNKeyDenyListList](
+			"vpnkeydenylists",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			"",
+			func() *apiv1alpha1.VPNKeyDenyList { return &apiv1alpha1.VPNKeyDenyList{} },
+			func() *apiv1alpha1.VPNKeyDenyListList { return &apiv1alpha1.VPNKeyDenyListList{} },
+		),
+	}
+}
@@ -0,0 +1,54 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNNetworksGetter has a method to return a VPNNetworkInterface.
+// A group's client should implement this interface.
+type VPNNetworksGetter interface {
+	VPNNetworks(namespace string) VPNNetworkInterface
+}
+
+// VPNNetworkInterface has methods to work with VPNNetwork resources.
+type VPNNetworkInterface interface {
+	Create(ctx context.Context, vPNNetwork *apiv1alpha1.VPNNetwork, opts v1.CreateOptions) (*apiv1alpha1.VPNNetwork, error)
+	Update(ctx context.Context, vPNNetwork *apiv1alpha1.VPNNetwork, opts v1.UpdateOptions) (*apiv1alpha1.VPNNetwork, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, vPNNetwork *apiv1alpha1.VPNNetwork, opts v1.UpdateOptions) (*apiv1alpha1.VPNNetwork, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.VPNNetwork, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.VPNNetworkList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.VPNNetwork, err error)
+	VPNNetworkExpansion
+}
+
+// vPNNetworks implements VPNNetworkInterface
+type vPNNetworks struct {
+	*gentype.ClientWithList[*apiv1alpha1.VPNNetwork, *apiv1alpha1.VPNNetworkList]
+}
+
+// newVPNNetworks returns a VPNNetworks
+func newVPNNetworks(c *VpnV1alpha1Client, namespace string) *vPNNetworks {
+	return &vPNNetworks{
+		gentype.NewClientWithList[*apiv1alpha1.VPNNetwork, *apiv1alpha1.VPNNetworkList](
+			"vpnnetworks",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1alpha1.VPNNetwork { return &apiv1alpha1.VPNNetwork{} },
+			func() *apiv1alpha1.VPNNetworkList { return &apiv1alpha1.VPNNetworkList{} },
+		),
+	}
+}
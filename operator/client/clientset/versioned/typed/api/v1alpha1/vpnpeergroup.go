@@ -0,0 +1,52 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNPeerGroupsGetter has a method to return a VPNPeerGroupInterface.
+// A group's client should implement this interface.
+type VPNPeerGroupsGetter interface {
+	VPNPeerGroups(namespace string) VPNPeerGroupInterface
+}
+
+// VPNPeerGroupInterface has methods to work with VPNPeerGroup resources.
+type VPNPeerGroupInterface interface {
+	Create(ctx context.Context, vPNPeerGroup *apiv1alpha1.VPNPeerGroup, opts v1.CreateOptions) (*apiv1alpha1.VPNPeerGroup, error)
+	Update(ctx context.Context, vPNPeerGroup *apiv1alpha1.VPNPeerGroup, opts v1.UpdateOptions) (*apiv1alpha1.VPNPeerGroup, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.VPNPeerGroup, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.VPNPeerGroupList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.VPNPeerGroup, err error)
+	VPNPeerGroupExpansion
+}
+
+// vPNPeerGroups implements VPNPeerGroupInterface
+type vPNPeerGroups struct {
+	*gentype.ClientWithList[*apiv1alpha1.VPNPeerGroup, *apiv1alpha1.VPNPeerGroupList]
+}
+
+// newVPNPeerGroups returns a VPNPeerGroups
+func newVPNPeerGroups(c *VpnV1alpha1Client, namespace string) *vPNPeerGroups {
+	return &vPNPeerGroups{
+		gentype.NewClientWithList[*apiv1alpha1.VPNPeerGroup, *apiv1alpha1.VPNPeerGroupList](
+			"vpnpeergroups",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1alpha1.VPNPeerGroup { return &apiv1alpha1.VPNPeerGroup{} },
+			func() *apiv1alpha1.VPNPeerGroupList { return &apiv1alpha1.VPNPeerGroupList{} },
+		),
+	}
+}
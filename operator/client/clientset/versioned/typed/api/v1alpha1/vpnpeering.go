@@ -0,0 +1,54 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNPeeringsGetter has a method to return a VPNPeeringInterface.
+// A group's client should implement this interface.
+type VPNPeeringsGetter interface {
+	VPNPeerings(namespace string) VPNPeeringInterface
+}
+
+// VPNPeeringInterface has methods to work with VPNPeering resources.
+type VPNPeeringInterface interface {
+	Create(ctx context.Context, vPNPeering *apiv1alpha1.VPNPeering, opts v1.CreateOptions) (*apiv1alpha1.VPNPeering, error)
+	Update(ctx context.Context, vPNPeering *apiv1alpha1.VPNPeering, opts v1.UpdateOptions) (*apiv1alpha1.VPNPeering, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, vPNPeering *apiv1alpha1.VPNPeering, opts v1.UpdateOptions) (*apiv1alpha1.VPNPeering, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.VPNPeering, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.VPNPeeringList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.VPNPeering, err error)
+	VPNPeeringExpansion
+}
+
+// vPNPeerings implements VPNPeeringInterface
+type vPNPeerings struct {
+	*gentype.ClientWithList[*apiv1alpha1.VPNPeering, *apiv1alpha1.VPNPeeringList]
+}
+
+// newVPNPeerings returns a VPNPeerings
+func newVPNPeerings(c *VpnV1alpha1Client, namespace string) *vPNPeerings {
+	return &vPNPeerings{
+		gentype.NewClientWithList[*apiv1alpha1.VPNPeering, *apiv1alpha1.VPNPeeringList](
+			"vpnpeerings",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1alpha1.VPNPeering { return &apiv1alpha1.VPNPeering{} },
+			func() *apiv1alpha1.VPNPeeringList { return &apiv1alpha1.VPNPeeringList{} },
+		),
+	}
+}
@@ -0,0 +1,54 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNPoolMigrationsGetter has a method to return a VPNPoolMigrationInterface.
+// A group's client should implement this interface.
+type VPNPoolMigrationsGetter interface {
+	VPNPoolMigrations(namespace string) VPNPoolMigrationInterface
+}
+
+// VPNPoolMigrationInterface has methods to work with VPNPoolMigration resources.
+type VPNPoolMigrationInterface interface {
+	Create(ctx context.Context, vPNPoolMigration *apiv1alpha1.VPNPoolMigration, opts v1.CreateOptions) (*apiv1alpha1.VPNPoolMigration, error)
+	Update(ctx context.Context, vPNPoolMigration *apiv1alpha1.VPNPoolMigration, opts v1.UpdateOptions) (*apiv1alpha1.VPNPoolMigration, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, vPNPoolMigration *apiv1alpha1.VPNPoolMigration, opts v1.UpdateOptions) (*apiv1alpha1.VPNPoolMigration, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.VPNPoolMigration, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.VPNPoolMigrationList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.VPNPoolMigration, err error)
+	VPNPoolMigrationExpansion
+}
+
+// vPNPoolMigrations implements VPNPoolMigrationInterface
+type vPNPoolMigrations struct {
+	*gentype.ClientWithList[*apiv1alpha1.VPNPoolMigration, *apiv1alpha1.VPNPoolMigrationList]
+}
+
+// newVPNPoolMigrations returns a VPNPoolMigrations
+func newVPNPoolMigrations(c *VpnV1alpha1Client, namespace string) *vPNPoolMigrations {
+	return &vPNPoolMigrations{
+		gentype.NewClientWithList[*apiv1alpha1.VPNPoolMigration, *apiv1alpha1.VPNPoolMigrationList](
+			"vpnpoolmigrations",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1alpha1.VPNPoolMigration { return &apiv1alpha1.VPNPoolMigration{} },
+			func() *apiv1alpha1.VPNPoolMigrationList { return &apiv1alpha1.VPNPoolMigrationList{} },
+		),
+	}
+}
@@ -0,0 +1,54 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNSelfEnrollmentsGetter has a method to return a VPNSelfEnrollmentInterface.
+// A group's client should implement this interface.
+type VPNSelfEnrollmentsGetter interface {
+	VPNSelfEnrollments(namespace string) VPNSelfEnrollmentInterface
+}
+
+// VPNSelfEnrollmentInterface has methods to work with VPNSelfEnrollment resources.
+type VPNSelfEnrollmentInterface interface {
+	Create(ctx context.Context, vPNSelfEnrollment *apiv1alpha1.VPNSelfEnrollment, opts v1.CreateOptions) (*apiv1alpha1.VPNSelfEnrollment, error)
+	Update(ctx context.Context, vPNSelfEnrollment *apiv1alpha1.VPNSelfEnrollment, opts v1.UpdateOptions) (*apiv1alpha1.VPNSelfEnrollment, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, vPNSelfEnrollment *apiv1alpha1.VPNSelfEnrollment, opts v1.UpdateOptions) (*apiv1alpha1.VPNSelfEnrollment, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.VPNSelfEnrollment, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.VPNSelfEnrollmentList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.VPNSelfEnrollment, err error)
+	VPNSelfEnrollmentExpansion
+}
+
+// vPNSelfEnrollments implements VPNSelfEnrollmentInterface
+type vPNSelfEnrollments struct {
+	*gentype.ClientWithList[*apiv1alpha1.VPNSelfEnrollment, *apiv1alpha1.VPNSelfEnrollmentList]
+}
+
+// newVPNSelfEnrollments returns a VPNSelfEnrollments
+func newVPNSelfEnrollments(c *VpnV1alpha1Client, namespace string) *vPNSelfEnrollments {
+	return &vPNSelfEnrollments{
+		gentype.NewClientWithList[*apiv1alpha1.VPNSelfEnrollment, *apiv1alpha1.VPNSelfEnrollmentList](
+			"vpnselfenrollments",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1alpha1.VPNSelfEnrollment { return &apiv1alpha1.VPNSelfEnrollment{} },
+			func() *apiv1alpha1.VPNSelfEnrollmentList { return &apiv1alpha1.VPNSelfEnrollmentList{} },
+		),
+	}
+}
@@ -0,0 +1,54 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNServersGetter has a method to return a VPNServerInterface.
+// A group's client should implement this interface.
+type VPNServersGetter interface {
+	VPNServers(namespace string) VPNServerInterface
+}
+
+// VPNServerInterface has methods to work with VPNServer resources.
+type VPNServerInterface interface {
+	Create(ctx context.Context, vPNServer *apiv1alpha1.VPNServer, opts v1.CreateOptions) (*apiv1alpha1.VPNServer, error)
+	Update(ctx context.Context, vPNServer *apiv1alpha1.VPNServer, opts v1.UpdateOptions) (*apiv1alpha1.VPNServer, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, vPNServer *apiv1alpha1.VPNServer, opts v1.UpdateOptions) (*apiv1alpha1.VPNServer, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.VPNServer, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.VPNServerList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.VPNServer, err error)
+	VPNServerExpansion
+}
+
+// vPNServers implements VPNServerInterface
+type vPNServers struct {
+	*gentype.ClientWithList[*apiv1alpha1.VPNServer, *apiv1alpha1.VPNServerList]
+}
+
+// newVPNServers returns a VPNServers
+func newVPNServers(c *VpnV1alpha1Client, namespace string) *vPNServers {
+	return &vPNServers{
+		gentype.NewClientWithList[*apiv1alpha1.VPNServer, *apiv1alpha1.VPNServerList](
+			"vpnservers",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1alpha1.VPNServer { return &apiv1alpha1.VPNServer{} },
+			func() *apiv1alpha1.VPNServerList { return &apiv1alpha1.VPNServerList{} },
+		),
+	}
+}
@@ -0,0 +1,52 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNServerClassesGetter has a method to return a VPNServerClassInterface.
+// A group's client should implement this interface.
+type VPNServerClassesGetter interface {
+	VPNServerClasses() VPNServerClassInterface
+}
+
+// VPNServerClassInterface has methods to work with VPNServerClass resources.
+type VPNServerClassInterface interface {
+	Create(ctx context.Context, vPNServerClass *apiv1alpha1.VPNServerClass, opts v1.CreateOptions) (*apiv1alpha1.VPNServerClass, error)
+	Update(ctx context.Context, vPNServerClass *apiv1alpha1.VPNServerClass, opts v1.UpdateOptions) (*apiv1alpha1.VPNServerClass, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.VPNServerClass, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.VPNServerClassList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.VPNServerClass, err error)
+	VPNServerClassExpansion
+}
+
+// vPNServerClasses implements VPNServerClassInterface
+type vPNServerClasses struct {
+	*gentype.ClientWithList[*apiv1alpha1.VPNServerClass, *apiv1alpha1.VPNServerClassList]
+}
+
+// newVPNServerClasses returns a VPNServerClasses
+func newVPNServerClasses(c *VpnV1alpha1Client) *vPNServerClasses {
+	return &vPNServerClasses{
+		gentype.NewClientWithList[*apiv1alpha1.VPNServerClass, *apiv1alpha1.VPNServerClassList](
+			"vpnserverclasses",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			"",
+			func() *apiv1alpha1.VPNServerClass { return &apiv1alpha1.VPNServerClass{} },
+			func() *apiv1alpha1.VPNServerClassList { return &apiv1alpha1.VPNServerClassList{} },
+		),
+	}
+}
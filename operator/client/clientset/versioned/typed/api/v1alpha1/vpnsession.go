@@ -0,0 +1,52 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNSessionsGetter has a method to return a VPNSessionInterface.
+// A group's client should implement this interface.
+type VPNSessionsGetter interface {
+	VPNSessions(namespace string) VPNSessionInterface
+}
+
+// VPNSessionInterface has methods to work with VPNSession resources.
+type VPNSessionInterface interface {
+	Create(ctx context.Context, vPNSession *apiv1alpha1.VPNSession, opts v1.CreateOptions) (*apiv1alpha1.VPNSession, error)
+	Update(ctx context.Context, vPNSession *apiv1alpha1.VPNSession, opts v1.UpdateOptions) (*apiv1alpha1.VPNSession, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1alpha1.VPNSession, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1alpha1.VPNSessionList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1alpha1.VPNSession, err error)
+	VPNSessionExpansion
+}
+
+// vPNSessions implements VPNSessionInterface
+type vPNSessions struct {
+	*gentype.ClientWithList[*apiv1alpha1.VPNSession, *apiv1alpha1.VPNSessionList]
+}
+
+// newVPNSessions returns a VPNSessions
+func newVPNSessions(c *VpnV1alpha1Client, namespace string) *vPNSessions {
+	return &vPNSessions{
+		gentype.NewClientWithList[*apiv1alpha1.VPNSession, *apiv1alpha1.VPNSessionList](
+			"vpnsessions",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1alpha1.VPNSession { return &apiv1alpha1.VPNSession{} },
+			func() *apiv1alpha1.VPNSessionList { return &apiv1alpha1.VPNSessionList{} },
+		),
+	}
+}
@@ -0,0 +1,28 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1beta1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1beta1"
+	rest "k8s.io/client-go/rest"
+	testing "k8s.io/client-go/testing"
+)
+
+type FakeVpnV1beta1 struct {
+	*testing.Fake
+}
+
+func (c *FakeVpnV1beta1) VPNPeers(namespace string) v1beta1.VPNPeerInterface {
+	return newFakeVPNPeers(c, namespace)
+}
+
+func (c *FakeVpnV1beta1) VPNServers(namespace string) v1beta1.VPNServerInterface {
+	return newFakeVPNServers(c, namespace)
+}
+
+// RESTClient returns a RESTClient that is used to communicate
+// with API server by this client implementation.
+func (c *FakeVpnV1beta1) RESTClient() rest.Interface {
+	var ret *rest.RESTClient
+	return ret
+}
@@ -0,0 +1,34 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1beta1 "github.com/vpn-devops/vpn-operator/api/v1beta1"
+	apiv1beta1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1beta1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNPeers implements VPNPeerInterface
+type fakeVPNPeers struct {
+	*gentype.FakeClientWithList[*v1beta1.VPNPeer, *v1beta1.VPNPeerList]
+	Fake *FakeVpnV1beta1
+}
+
+func newFakeVPNPeers(fake *FakeVpnV1beta1, namespace string) apiv1beta1.VPNPeerInterface {
+	return &fakeVPNPeers{
+		gentype.NewFakeClientWithList[*v1beta1.VPNPeer, *v1beta1.VPNPeerList](
+			fake.Fake,
+			namespace,
+			v1beta1.SchemeGroupVersion.WithResource("vpnpeers"),
+			v1beta1.SchemeGroupVersion.WithKind("VPNPeer"),
+			func() *v1beta1.VPNPeer { return &v1beta1.VPNPeer{} },
+			func() *v1beta1.VPNPeerList { return &v1beta1.VPNPeerList{} },
+			func(dst, src *v1beta1.VPNPeerList) { dst.ListMeta = src.ListMeta },
+			func(list *v1beta1.VPNPeerList) []*v1beta1.VPNPeer { return gentype.ToPointerSlice(list.Items) },
+			func(list *v1beta1.VPNPeerList, items []*v1beta1.VPNPeer) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
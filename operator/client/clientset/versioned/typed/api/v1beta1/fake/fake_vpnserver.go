@@ -0,0 +1,34 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1beta1 "github.com/vpn-devops/vpn-operator/api/v1beta1"
+	apiv1beta1 "github.com/vpn-devops/vpn-operator/client/clientset/versioned/typed/api/v1beta1"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakeVPNServers implements VPNServerInterface
+type fakeVPNServers struct {
+	*gentype.FakeClientWithList[*v1beta1.VPNServer, *v1beta1.VPNServerList]
+	Fake *FakeVpnV1beta1
+}
+
+func newFakeVPNServers(fake *FakeVpnV1beta1, namespace string) apiv1beta1.VPNServerInterface {
+	return &fakeVPNServers{
+		gentype.NewFakeClientWithList[*v1beta1.VPNServer, *v1beta1.VPNServerList](
+			fake.Fake,
+			namespace,
+			v1beta1.SchemeGroupVersion.WithResource("vpnservers"),
+			v1beta1.SchemeGroupVersion.WithKind("VPNServer"),
+			func() *v1beta1.VPNServer { return &v1beta1.VPNServer{} },
+			func() *v1beta1.VPNServerList { return &v1beta1.VPNServerList{} },
+			func(dst, src *v1beta1.VPNServerList) { dst.ListMeta = src.ListMeta },
+			func(list *v1beta1.VPNServerList) []*v1beta1.VPNServer { return gentype.ToPointerSlice(list.Items) },
+			func(list *v1beta1.VPNServerList, items []*v1beta1.VPNServer) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
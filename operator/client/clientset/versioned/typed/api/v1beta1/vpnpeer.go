@@ -0,0 +1,54 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	context "context"
+
+	apiv1beta1 "github.com/vpn-devops/vpn-operator/api/v1beta1"
+	scheme "github.com/vpn-devops/vpn-operator/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// VPNPeersGetter has a method to return a VPNPeerInterface.
+// A group's client should implement this interface.
+type VPNPeersGetter interface {
+	VPNPeers(namespace string) VPNPeerInterface
+}
+
+// VPNPeerInterface has methods to work with VPNPeer resources.
+type VPNPeerInterface interface {
+	Create(ctx context.Context, vPNPeer *apiv1beta1.VPNPeer, opts v1.CreateOptions) (*apiv1beta1.VPNPeer, error)
+	Update(ctx context.Context, vPNPeer *apiv1beta1.VPNPeer, opts v1.UpdateOptions) (*apiv1beta1.VPNPeer, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, vPNPeer *apiv1beta1.VPNPeer, opts v1.UpdateOptions) (*apiv1beta1.VPNPeer, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1beta1.VPNPeer, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1beta1.VPNPeerList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1beta1.VPNPeer, err error)
+	VPNPeerExpansion
+}
+
+// vPNPeers implements VPNPeerInterface
+type vPNPeers struct {
+	*gentype.ClientWithList[*apiv1beta1.VPNPeer, *apiv1beta1.VPNPeerList]
+}
+
+// newVPNPeers returns a VPNPeers
+func newVPNPeers(c *VpnV1beta1Client, namespace string) *vPNPeers {
+	return &vPNPeers{
+		gentype.NewClientWithList[*apiv1beta1.VPNPeer, *apiv1beta1.VPNPeerList](
+			"vpnpeers",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *apiv1beta1.VPNPeer { return &apiv1beta1.VPNPeer{} },
+			func() *apiv1beta1.VPNPeerList { return &apiv1beta1.VPNPeerList{} },
+		),
+	}
+}
@@ -0,0 +1,11 @@
+// Package client holds the typed clientset, listers and informers of the
+// wireflow API, for programs that build on the API without
+// controller-runtime. The packages below are generated by
+// hack/update-codegen.sh and must not be edited.
+//
+// The module is versioned with the operator: it is tagged client/vX.Y.Z
+// alongside each vX.Y.Z release, and requires the API of that release.
+// Between releases it requires a pseudo-version of the operator module; to
+// build it against the API of a checkout, create a local workspace with
+// make go.work.
+package client
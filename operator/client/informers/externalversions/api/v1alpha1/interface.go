@@ -0,0 +1,148 @@
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	internalinterfaces "github.com/vpn-devops/vpn-operator/client/informers/externalversions/internalinterfaces"
+)
+
+// Interface provides access to all the informers in this group version.
+type Interface interface {
+	// MemberClusters returns a MemberClusterInformer.
+	MemberClusters() MemberClusterInformer
+	// VPNAccessPolicies returns a VPNAccessPolicyInformer.
+	VPNAccessPolicies() VPNAccessPolicyInformer
+	// VPNBrandings returns a VPNBrandingInformer.
+	VPNBrandings() VPNBrandingInformer
+	// VPNChatIntegrations returns a VPNChatIntegrationInformer.
+	VPNChatIntegrations() VPNChatIntegrationInformer
+	// VPNConnectivityChecks returns a VPNConnectivityCheckInformer.
+	VPNConnectivityChecks() VPNConnectivityCheckInformer
+	// VPNGlobalPolicies returns a VPNGlobalPolicyInformer.
+	VPNGlobalPolicies() VPNGlobalPolicyInformer
+	// VPNIPPools returns a VPNIPPoolInformer.
+	VPNIPPools() VPNIPPoolInformer
+	// VPNIngressMaps returns a VPNIngressMapInformer.
+	VPNIngressMaps() VPNIngressMapInformer
+	// VPNKeyDenyLists returns a VPNKeyDenyListInformer.
+	VPNKeyDenyLists() VPNKeyDenyListInformer
+	// VPNNetworks returns a VPNNetworkInformer.
+	VPNNetworks() VPNNetworkInformer
+	// VPNPeers returns a VPNPeerInformer.
+	VPNPeers() VPNPeerInformer
+	// VPNPeerGroups returns a VPNPeerGroupInformer.
+	VPNPeerGroups() VPNPeerGroupInformer
+	// VPNPeerings returns a VPNPeeringInformer.
+	VPNPeerings() VPNPeeringInformer
+	// VPNPoolMigrations returns a VPNPoolMigrationInformer.
+	VPNPoolMigrations() VPNPoolMigrationInformer
+	// VPNSelfEnrollments returns a VPNSelfEnrollmentInformer.
+	VPNSelfEnrollments() VPNSelfEnrollmentInformer
+	// VPNServers returns a VPNServerInformer.
+	VPNServers() VPNServerInformer
+	// VPNServerClasses returns a VPNServerClassInformer.
+	VPNServerClasses() VPNServerClassInformer
+	// VPNSessions returns a VPNSessionInformer.
+	VPNSessions() VPNSessionInformer
+}
+
+type version struct {
+	factory          internalinterfaces.SharedInformerFactory
+	namespace        string
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+}
+
+// New returns a new Interface.
+func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakListOptions internalinterfaces.TweakListOptionsFunc) Interface {
+	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
+}
+
+// MemberClusters returns a MemberClusterInformer.
+func (v *version) MemberClusters() MemberClusterInformer {
+	return &memberClusterInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNAccessPolicies returns a VPNAccessPolicyInformer.
+func (v *version) VPNAccessPolicies() VPNAccessPolicyInformer {
+	return &vPNAccessPolicyInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNBrandings returns a VPNBrandingInformer.
+func (v *version) VPNBrandings() VPNBrandingInformer {
+	return &vPNBrandingInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNChatIntegrations returns a VPNChatIntegrationInformer.
+func (v *version) VPNChatIntegrations() VPNChatIntegrationInformer {
+	return &vPNChatIntegrationInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNConnectivityChecks returns a VPNConnectivityCheckInformer.
+func (v *version) VPNConnectivityChecks() VPNConnectivityCheckInformer {
+	return &vPNConnectivityCheckInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNGlobalPolicies returns a VPNGlobalPolicyInformer.
+func (v *version) VPNGlobalPolicies() VPNGlobalPolicyInformer {
+	return &vPNGlobalPolicyInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNIPPools returns a VPNIPPoolInformer.
+func (v *version) VPNIPPools() VPNIPPoolInformer {
+	return &vPNIPPoolInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNIngressMaps returns a VPNIngressMapInformer.
+func (v *version) VPNIngressMaps() VPNIngressMapInformer {
+	return &vPNIngressMapInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNKeyDenyLists returns a VPNKeyDenyListInformer.
+func (v *version) VPNKeyDenyLists() VPNKeyDenyListInformer {
+	return &vPNKeyDenyListInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNNetworks returns a VPNNetworkInformer.
+func (v *version) VPNNetworks() VPNNetworkInformer {
+	return &vPNNetworkInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNPeers returns a VPNPeerInformer.
+func (v *version) VPNPeers() VPNPeerInformer {
+	return &vPNPeerInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNPeerGroups returns a VPNPeerGroupInformer.
+func (v *version) VPNPeerGroups() VPNPeerGroupInformer {
+	return &vPNPeerGroupInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNPeerings returns a VPNPeeringInformer.
+func (v *version) VPNPeerings() VPNPeeringInformer {
+	return &vPNPeeringInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNPoolMigrations returns a VPNPoolMigrationInformer.
+func (v *version) VPNPoolMigrations() VPNPoolMigrationInformer {
+	return &vPNPoolMigrationInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNSelfEnrollments returns a VPNSelfEnrollmentInformer.
+func (v *version) VPNSelfEnrollments() VPNSelfEnrollmentInformer {
+	return &vPNSelfEnrollmentInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNServers returns a VPNServerInformer.
+func (v *version) VPNServers() VPNServerInformer {
+	return &vPNServerInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNServerClasses returns a VPNServerClassInformer.
+func (v *version) VPNServerClasses() VPNServerClassInformer {
+	return &vPNServerClassInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
+// VPNSessions returns a VPNSessionInformer.
+func (v *version) VPNSessions() VPNSessionInformer {
+	return &vPNSessionInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
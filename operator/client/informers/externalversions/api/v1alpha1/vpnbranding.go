@@ -0,0 +1,86 @@
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+	time "time"
+
+	vpnoperatorapiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	versioned "github.com/vpn-devops/vpn-operator/client/clientset/versioned"
+	internalinterfaces "github.com/vpn-devops/vpn-operator/client/informers/externalversions/internalinterfaces"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/listers/api/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNBrandingInformer provides access to a shared informer and lister for
+// VPNBrandings.
+type VPNBrandingInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() apiv1alpha1.VPNBrandingLister
+}
+
+type vPNBrandingInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewVPNBrandingInformer constructs a new informer for VPNBranding type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewVPNBrandingInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredVPNBrandingInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredVPNBrandingInformer constructs a new informer for VPNBranding type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredVPNBrandingInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.VpnV1alpha1().VPNBrandings(namespace).List(context.Background(), options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.VpnV1alpha1().VPNBrandings(namespace).Watch(context.Background(), options)
+			},
+			ListWithContextFunc: func(ctx context.Context, options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.VpnV1alpha1().VPNBrandings(namespace).List(ctx, options)
+			},
+			WatchFuncWithContext: func(ctx context.Context, options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.VpnV1alpha1().VPNBrandings(namespace).Watch(ctx, options)
+			},
+		},
+		&vpnoperatorapiv1alpha1.VPNBranding{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *vPNBrandingInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredVPNBrandingInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *vPNBrandingInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&vpnoperatorapiv1alpha1.VPNBranding{}, f.defaultInformer)
+}
+
+func (f *vPNBrandingInformer) Lister() apiv1alpha1.VPNBrandingLister {
+	return apiv1alpha1.NewVPNBrandingLister(f.Informer().GetIndexer())
+}
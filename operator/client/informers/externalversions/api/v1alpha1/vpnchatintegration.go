@@ -0,0 +1,86 @@
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+	time "time"
+
+	vpnoperatorapiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	versioned "github.com/vpn-devops/vpn-operator/client/clientset/versioned"
+	internalinterfaces "github.com/vpn-devops/vpn-operator/client/informers/externalversions/internalinterfaces"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/listers/api/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNChatIntegrationInformer provides access to a shared informer and lister for
+// VPNChatIntegrations.
+type VPNChatIntegrationInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() apiv1alpha1.VPNChatIntegrationLister
+}
+
+type vPNChatIntegrationInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewVPNChatIntegrationInformer constructs a new informer for VPNChatIntegration type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewVPNChatIntegrationInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredVPNChatIntegrationInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredVPNChatIntegrationInformer constructs a new informer for VPNChatIntegration type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredVPNChatIntegrationInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.VpnV1alpha1().VPNChatIntegrations(namespace).List(context.Background(), options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.VpnV1alpha1().VPNChatIntegrations(namespace).Watch(context.Background(), options)
+			},
+			ListWithContextFunc: func(ctx context.Context, options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.VpnV1alpha1().VPNChatIntegrations(namespace).List(ctx, options)
+			},
+			WatchFuncWithContext: func(ctx context.Context, options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.VpnV1alpha1().VPNChatIntegrations(namespace).Watch(ctx, options)
+			},
+		},
+		&vpnoperatorapiv1alpha1.VPNChatIntegration{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *vPNChatIntegrationInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredVPNChatIntegrationInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *vPNChatIntegrationInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&vpnoperatorapiv1alpha1.VPNChatIntegration{}, f.defaultInformer)
+}
+
+func (f *vPNChatIntegrationInformer) Lister() apiv1alpha1.VPNChatIntegrationLister {
+	return apiv1alpha1.NewVPNChatIntegrationLister(f.Informer().GetIndexer())
+}
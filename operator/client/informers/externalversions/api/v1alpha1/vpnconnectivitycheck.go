@@ -0,0 +1,86 @@
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	context "context"
+	time "time"
+
+	vpnoperatorapiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	versioned "github.com/vpn-devops/vpn-operator/client/clientset/versioned"
+	internalinterfaces "github.com/vpn-devops/vpn-operator/client/informers/externalversions/internalinterfaces"
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/client/listers/api/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNConnectivityCheckInformer provides access to a shared informer and lister for
+// VPNConnectivityChecks.
+type VPNConnectivityCheckInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() apiv1alpha1.VPNConnectivityCheckLister
+}
+
+type vPNConnectivityCheckInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+	namespace        string
+}
+
+// NewVPNConnectivityCheckInformer constructs a new informer for VPNConnectivityCheck type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewVPNConnectivityCheckInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredVPNConnectivityCheckInformer(client, namespace, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredVPNConnectivityCheckInformer constructs a new informer for VPNConnectivityCheck type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredVPNConnectivityCheckInformer(client versioned.Interface, namespace string, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.VpnV1alpha1().VPNConnectivityChecks(namespace).List(context.Background(), options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.VpnV1alpha1().VPNConnectivityChecks(namespace).Watch(context.Background(), options)
+			},
+			ListWithContextFunc: func(ctx context.Context, options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.VpnV1alpha1().VPNConnectivityChecks(namespace).List(ctx, options)
+			},
+			WatchFuncWithContext: func(ctx context.Context, options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.VpnV1alpha1().VPNConnectivityChecks(namespace).Watch(ctx, options)
+			},
+		},
+		&vpnoperatorapiv1alpha1.VPNConnectivityCheck{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *vPNConnectivityCheckInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredVPNConnectivityCheckInformer(client, f.namespace, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *vPNConnectivityCheckInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&vpnoperatorapiv1alpha1.VPNConnectivityCheck{}, f.defaultInformer)
+}
+
+func (f *vPNConnectivityCheckInformer) Lister() apiv1alpha1.VPNConnectivityCheckLister {
+	return apiv1alpha1.NewVPNConnectivityCheckLister(f.Informer().GetIndexer())
+}
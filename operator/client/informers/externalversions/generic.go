@@ -0,0 +1,87 @@
+// Code generated by informer-gen. DO NOT EDIT.
+
+package externalversions
+
+import (
+	fmt "fmt"
+
+	v1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	v1beta1 "github.com/vpn-devops/vpn-operator/api/v1beta1"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// GenericInformer is type of SharedIndexInformer which will locate and delegate to other
+// sharedInformers based on type
+type GenericInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() cache.GenericLister
+}
+
+type genericInformer struct {
+	informer cache.SharedIndexInformer
+	resource schema.GroupResource
+}
+
+// Informer returns the SharedIndexInformer.
+func (f *genericInformer) Informer() cache.SharedIndexInformer {
+	return f.informer
+}
+
+// Lister returns the GenericLister.
+func (f *genericInformer) Lister() cache.GenericLister {
+	return cache.NewGenericLister(f.Informer().GetIndexer(), f.resource)
+}
+
+// ForResource gives generic access to a shared informer of the matching type
+// TODO extend this to unknown resources with a client pool
+func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource) (GenericInformer, error) {
+	switch resource {
+	// Group=vpn.vpn-devops.com, Version=v1alpha1
+	case v1alpha1.SchemeGroupVersion.WithResource("memberclusters"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().MemberClusters().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpnaccesspolicies"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNAccessPolicies().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpnbrandings"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNBrandings().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpnchatintegrations"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNChatIntegrations().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpnconnectivitychecks"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNConnectivityChecks().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpnglobalpolicies"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNGlobalPolicies().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpnippools"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNIPPools().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpningressmaps"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNIngressMaps().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpnkeydenylists"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNKeyDenyLists().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpnnetworks"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNNetworks().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpnpeers"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNPeers().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpnpeergroups"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNPeerGroups().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpnpeerings"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNPeerings().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpnpoolmigrations"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNPoolMigrations().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpnselfenrollments"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNSelfEnrollments().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpnservers"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNServers().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpnserverclasses"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNServerClasses().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("vpnsessions"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1alpha1().VPNSessions().Informer()}, nil
+
+		// Group=vpn.vpn-devops.com, Version=v1beta1
+	case v1beta1.SchemeGroupVersion.WithResource("vpnpeers"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1beta1().VPNPeers().Informer()}, nil
+	case v1beta1.SchemeGroupVersion.WithResource("vpnservers"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Vpn().V1beta1().VPNServers().Informer()}, nil
+
+	}
+
+	return nil, fmt.Errorf("no informer found for %v", resource)
+}
@@ -0,0 +1,135 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+// MemberClusterListerExpansion allows custom methods to be added to
+// MemberClusterLister.
+type MemberClusterListerExpansion interface{}
+
+// MemberClusterNamespaceListerExpansion allows custom methods to be added to
+// MemberClusterNamespaceLister.
+type MemberClusterNamespaceListerExpansion interface{}
+
+// VPNAccessPolicyListerExpansion allows custom methods to be added to
+// VPNAccessPolicyLister.
+type VPNAccessPolicyListerExpansion interface{}
+
+// VPNAccessPolicyNamespaceListerExpansion allows custom methods to be added to
+// VPNAccessPolicyNamespaceLister.
+type VPNAccessPolicyNamespaceListerExpansion interface{}
+
+// VPNBrandingListerExpansion allows custom methods to be added to
+// VPNBrandingLister.
+type VPNBrandingListerExpansion interface{}
+
+// VPNBrandingNamespaceListerExpansion allows custom methods to be added to
+// VPNBrandingNamespaceLister.
+type VPNBrandingNamespaceListerExpansion interface{}
+
+// VPNChatIntegrationListerExpansion allows custom methods to be added to
+// VPNChatIntegrationLister.
+type VPNChatIntegrationListerExpansion interface{}
+
+// VPNChatIntegrationNamespaceListerExpansion allows custom methods to be added to
+// VPNChatIntegrationNamespaceLister.
+type VPNChatIntegrationNamespaceListerExpansion interface{}
+
+// VPNConnectivityCheckListerExpansion allows custom methods to be added to
+// VPNConnectivityCheckLister.
+type VPNConnectivityCheckListerExpansion interface{}
+
+// VPNConnectivityCheckNamespaceListerExpansion allows custom methods to be added to
+// VPNConnectivityCheckNamespaceLister.
+type VPNConnectivityCheckNamespaceListerExpansion interface{}
+
+// VPNGlobalPolicyListerExpansion allows custom methods to be added to
+// VPNGlobalPolicyLister.
+type VPNGlobalPolicyListerExpansion interface{}
+
+// VPNIPPoolListerExpansion allows custom methods to be added to
+// VPNIPPoolLister.
+type VPNIPPoolListerExpansion interface{}
+
+// VPNIPPoolNamespaceListerExpansion allows custom methods to be added to
+// VPNIPPoolNamespaceLister.
+type VPNIPPoolNamespaceListerExpansion interface{}
+
+// VPNIngressMapListerExpansion allows custom methods to be added to
+// VPNIngressMapLister.
+type VPNIngressMapListerExpansion interface{}
+
+// VPNIngressMapNamespaceListerExpansion allows custom methods to be added to
+// VPNIngressMapNamespaceLister.
+type VPNIngressMapNamespaceListerExpansion interface{}
+
+// VPNKeyDenyListListerExpansion allows custom methods to be added to
+// VPNKeyDenyListLister.
+type VPNKeyDenyListListerExpansion interface{}
+
+// VPNNetworkListerExpansion allows custom methods to be added to
+// VPNNetworkLister.
+type VPNNetworkListerExpansion interface{}
+
+// VPNNetworkNamespaceListerExpansion allows custom methods to be added to
+// VPNNetworkNamespaceLister.
+type VPNNetworkNamespaceListerExpansion interface{}
+
+// VPNPeerListerExpansion allows custom methods to be added to
+// VPNPeerLister.
+type VPNPeerListerExpansion interface{}
+
+// VPNPeerNamespaceListerExpansion allows custom methods to be added to
+// VPNPeerNamespaceLister.
+type VPNPeerNamespaceListerExpansion interface{}
+
+// VPNPeerGroupListerExpansion allows custom methods to be added to
+// VPNPeerGroupLister.
+type VPNPeerGroupListerExpansion interface{}
+
+// VPNPeerGroupNamespaceListerExpansion allows custom methods to be added to
+// VPNPeerGroupNamespaceLister.
+type VPNPeerGroupNamespaceListerExpansion interface{}
+
+// VPNPeeringListerExpansion allows custom methods to be added to
+// VPNPeeringLister.
+type VPNPeeringListerExpansion interface{}
+
+// VPNPeeringNamespaceListerExpansion allows custom methods to be added to
+// VPNPeeringNamespaceLister.
+type VPNPeeringNamespaceListerExpansion interface{}
+
+// VPNPoolMigrationListerExpansion allows custom methods to be added to
+// VPNPoolMigrationLister.
+type VPNPoolMigrationListerExpansion interface{}
+
+// VPNPoolMigrationNamespaceListerExpansion allows custom methods to be added to
+// VPNPoolMigrationNamespaceLister.
+type VPNPoolMigrationNamespaceListerExpansion interface{}
+
+// VPNSelfEnrollmentListerExpansion allows custom methods to be added to
+// VPNSelfEnrollmentLister.
+type VPNSelfEnrollmentListerExpansion interface{}
+
+// VPNSelfEnrollmentNamespaceListerExpansion allows custom methods to be added to
+// VPNSelfEnrollmentNamespaceLister.
+type VPNSelfEnrollmentNamespaceListerExpansion interface{}
+
+// VPNServerListerExpansion allows custom methods to be added to
+// VPNServerLister.
+type VPNServerListerExpansion interface{}
+
+// VPNServerNamespaceListerExpansion allows custom methods to be added to
+// VPNServerNamespaceLister.
+type VPNServerNamespaceListerExpansion interface{}
+
+// VPNServerClassListerExpansion allows custom methods to be added to
+// VPNServerClassLister.
+type VPNServerClassListerExpansion interface{}
+
+// VPNSessionListerExpansion allows custom methods to be added to
+// VPNSessionLister.
+type VPNSessionListerExpansion interface{}
+
+// VPNSessionNamespaceListerExpansion allows custom methods to be added to
+// VPNSessionNamespaceLister.
+type VPNSessionNamespaceListerExpansion interface{}
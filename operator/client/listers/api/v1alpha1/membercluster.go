@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// MemberClusterLister helps list MemberClusters.
+// All objects returned here must be treated as read-only.
+type MemberClusterLister interface {
+	// List lists all MemberClusters in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.MemberCluster, err error)
+	// MemberClusters returns an object that can list and get MemberClusters.
+	MemberClusters(namespace string) MemberClusterNamespaceLister
+	MemberClusterListerExpansion
+}
+
+// memberClusterLister implements the MemberClusterLister interface.
+type memberClusterLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.MemberCluster]
+}
+
+// NewMemberClusterLister returns a new MemberClusterLister.
+func NewMemberClusterLister(indexer cache.Indexer) MemberClusterLister {
+	return &memberClusterLister{listers.New[*apiv1alpha1.MemberCluster](indexer, apiv1alpha1.Resource("membercluster"))}
+}
+
+// MemberClusters returns an object that can list and get MemberClusters.
+func (s *memberClusterLister) MemberClusters(namespace string) MemberClusterNamespaceLister {
+	return memberClusterNamespaceLister{listers.NewNamespaced[*apiv1alpha1.MemberCluster](s.ResourceIndexer, namespace)}
+}
+
+// MemberClusterNamespaceLister helps list and get MemberClusters.
+// All objects returned here must be treated as read-only.
+type MemberClusterNamespaceLister interface {
+	// List lists all MemberClusters in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.MemberCluster, err error)
+	// Get retrieves the MemberCluster from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.MemberCluster, error)
+	MemberClusterNamespaceListerExpansion
+}
+
+// memberClusterNamespaceLister implements the MemberClusterNamespaceLister
+// interface.
+type memberClusterNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.MemberCluster]
+}
@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNAccessPolicyLister helps list VPNAccessPolicies.
+// All objects returned here must be treated as read-only.
+type VPNAccessPolicyLister interface {
+	// List lists all VPNAccessPolicies in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNAccessPolicy, err error)
+	// VPNAccessPolicies returns an object that can list and get VPNAccessPolicies.
+	VPNAccessPolicies(namespace string) VPNAccessPolicyNamespaceLister
+	VPNAccessPolicyListerExpansion
+}
+
+// vPNAccessPolicyLister implements the VPNAccessPolicyLister interface.
+type vPNAccessPolicyLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNAccessPolicy]
+}
+
+// NewVPNAccessPolicyLister returns a new VPNAccessPolicyLister.
+func NewVPNAccessPolicyLister(indexer cache.Indexer) VPNAccessPolicyLister {
+	return &vPNAccessPolicyLister{listers.New[*apiv1alpha1.VPNAccessPolicy](indexer, apiv1alpha1.Resource("vpnaccesspolicy"))}
+}
+
+// VPNAccessPolicies returns an object that can list and get VPNAccessPolicies.
+func (s *vPNAccessPolicyLister) VPNAccessPolicies(namespace string) VPNAccessPolicyNamespaceLister {
+	return vPNAccessPolicyNamespaceLister{listers.NewNamespaced[*apiv1alpha1.VPNAccessPolicy](s.ResourceIndexer, namespace)}
+}
+
+// VPNAccessPolicyNamespaceLister helps list and get VPNAccessPolicies.
+// All objects returned here must be treated as read-only.
+type VPNAccessPolicyNamespaceLister interface {
+	// List lists all VPNAccessPolicies in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNAccessPolicy, err error)
+	// Get retrieves the VPNAccessPolicy from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.VPNAccessPolicy, error)
+	VPNAccessPolicyNamespaceListerExpansion
+}
+
+// vPNAccessPolicyNamespaceLister implements the VPNAccessPolicyNamespaceLister
+// interface.
+type vPNAccessPolicyNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNAccessPolicy]
+}
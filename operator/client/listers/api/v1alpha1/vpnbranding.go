@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNBrandingLister helps list VPNBrandings.
+// All objects returned here must be treated as read-only.
+type VPNBrandingLister interface {
+	// List lists all VPNBrandings in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNBranding, err error)
+	// VPNBrandings returns an object that can list and get VPNBrandings.
+	VPNBrandings(namespace string) VPNBrandingNamespaceLister
+	VPNBrandingListerExpansion
+}
+
+// vPNBrandingLister implements the VPNBrandingLister interface.
+type vPNBrandingLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNBranding]
+}
+
+// NewVPNBrandingLister returns a new VPNBrandingLister.
+func NewVPNBrandingLister(indexer cache.Indexer) VPNBrandingLister {
+	return &vPNBrandingLister{listers.New[*apiv1alpha1.VPNBranding](indexer, apiv1alpha1.Resource("vpnbranding"))}
+}
+
+// VPNBrandings returns an object that can list and get VPNBrandings.
+func (s *vPNBrandingLister) VPNBrandings(namespace string) VPNBrandingNamespaceLister {
+	return vPNBrandingNamespaceLister{listers.NewNamespaced[*apiv1alpha1.VPNBranding](s.ResourceIndexer, namespace)}
+}
+
+// VPNBrandingNamespaceLister helps list and get VPNBrandings.
+// All objects returned here must be treated as read-only.
+type VPNBrandingNamespaceLister interface {
+	// List lists all VPNBrandings in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNBranding, err error)
+	// Get retrieves the VPNBranding from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.VPNBranding, error)
+	VPNBrandingNamespaceListerExpansion
+}
+
+// vPNBrandingNamespaceLister implements the VPNBrandingNamespaceLister
+// interface.
+type vPNBrandingNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNBranding]
+}
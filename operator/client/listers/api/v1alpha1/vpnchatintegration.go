@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNChatIntegrationLister helps list VPNChatIntegrations.
+// All objects returned here must be treated as read-only.
+type VPNChatIntegrationLister interface {
+	// List lists all VPNChatIntegrations in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNChatIntegration, err error)
+	// VPNChatIntegrations returns an object that can list and get VPNChatIntegrations.
+	VPNChatIntegrations(namespace string) VPNChatIntegrationNamespaceLister
+	VPNChatIntegrationListerExpansion
+}
+
+// vPNChatIntegrationLister implements the VPNChatIntegrationLister interface.
+type vPNChatIntegrationLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNChatIntegration]
+}
+
+// NewVPNChatIntegrationLister returns a new VPNChatIntegrationLister.
+func NewVPNChatIntegrationLister(indexer cache.Indexer) VPNChatIntegrationLister {
+	return &vPNChatIntegrationLister{listers.New[*apiv1alpha1.VPNChatIntegration](indexer, apiv1alpha1.Resource("vpnchatintegration"))}
+}
+
+// VPNChatIntegrations returns an object that can list and get VPNChatIntegrations.
+func (s *vPNChatIntegrationLister) VPNChatIntegrations(namespace string) VPNChatIntegrationNamespaceLister {
+	return vPNChatIntegrationNamespaceLister{listers.NewNamespaced[*apiv1alpha1.VPNChatIntegration](s.ResourceIndexer, namespace)}
+}
+
+// VPNChatIntegrationNamespaceLister helps list and get VPNChatIntegrations.
+// All objects returned here must be treated as read-only.
+type VPNChatIntegrationNamespaceLister interface {
+	// List lists all VPNChatIntegrations in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNChatIntegration, err error)
+	// Get retrieves the VPNChatIntegration from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.VPNChatIntegration, error)
+	VPNChatIntegrationNamespaceListerExpansion
+}
+
+// vPNChatIntegrationNamespaceLister implements the VPNChatIntegrationNamespaceLister
+// interface.
+type vPNChatIntegrationNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNChatIntegration]
+}
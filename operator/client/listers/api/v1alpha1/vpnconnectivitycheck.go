@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNConnectivityCheckLister helps list VPNConnectivityChecks.
+// All objects returned here must be treated as read-only.
+type VPNConnectivityCheckLister interface {
+	// List lists all VPNConnectivityChecks in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNConnectivityCheck, err error)
+	// VPNConnectivityChecks returns an object that can list and get VPNConnectivityChecks.
+	VPNConnectivityChecks(namespace string) VPNConnectivityCheckNamespaceLister
+	VPNConnectivityCheckListerExpansion
+}
+
+// vPNConnectivityCheckLister implements the VPNConnectivityCheckLister interface.
+type vPNConnectivityCheckLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNConnectivityCheck]
+}
+
+// NewVPNConnectivityCheckLister returns a new VPNConnectivityCheckLister.
+func NewVPNConnectivityCheckLister(indexer cache.Indexer) VPNConnectivityCheckLister {
+	return &vPNConnectivityCheckLister{listers.New[*apiv1alpha1.VPNConnectivityCheck](indexer, apiv1alpha1.Resource("vpnconnectivitycheck"))}
+}
+
+// VPNConnectivityChecks returns an object that can list and get VPNConnectivityChecks.
+func (s *vPNConnectivityCheckLister) VPNConnectivityChecks(namespace string) VPNConnectivityCheckNamespaceLister {
+	return vPNConnectivityCheckNamespaceLister{listers.NewNamespaced[*apiv1alpha1.VPNConnectivityCheck](s.ResourceIndexer, namespace)}
+}
+
+// VPNConnectivityCheckNamespaceLister helps list and get VPNConnectivityChecks.
+// All objects returned here must be treated as read-only.
+type VPNConnectivityCheckNamespaceLister interface {
+	// List lists all VPNConnectivityChecks in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNConnectivityCheck, err error)
+	// Get retrieves the VPNConnectivityCheck from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.VPNConnectivityCheck, error)
+	VPNConnectivityCheckNamespaceListerExpansion
+}
+
+// vPNConnectivityCheckNamespaceLister implements the VPNConnectivityCheckNamespaceLister
+// interface.
+type vPNConnectivityCheckNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNConnectivityCheck]
+}
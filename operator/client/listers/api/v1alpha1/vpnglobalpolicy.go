@@ -0,0 +1,32 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNGlobalPolicyLister helps list VPNGlobalPolicies.
+// All objects returned here must be treated as read-only.
+type VPNGlobalPolicyLister interface {
+	// List lists all VPNGlobalPolicies in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNGlobalPolicy, err error)
+	// Get retrieves the VPNGlobalPolicy from the index for a given name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.VPNGlobalPolicy, error)
+	VPNGlobalPolicyListerExpansion
+}
+
+// vPNGlobalPolicyLister implements the VPNGlobalPolicyLister interface.
+type vPNGlobalPolicyLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNGlobalPolicy]
+}
+
+// NewVPNGlobalPolicyLister returns a new VPNGlobalPolicyLister.
+func NewVPNGlobalPolicyLister(indexer cache.Indexer) VPNGlobalPolicyLister {
+	return &vPNGlobalPolicyLister{listers.New[*apiv1alpha1.VPNGlobalPolicy](indexer, apiv1alpha1.Resource("vpnglobalpolicy"))}
+}
@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNIngressMapLister helps list VPNIngressMaps.
+// All objects returned here must be treated as read-only.
+type VPNIngressMapLister interface {
+	// List lists all VPNIngressMaps in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNIngressMap, err error)
+	// VPNIngressMaps returns an object that can list and get VPNIngressMaps.
+	VPNIngressMaps(namespace string) VPNIngressMapNamespaceLister
+	VPNIngressMapListerExpansion
+}
+
+// vPNIngressMapLister implements the VPNIngressMapLister interface.
+type vPNIngressMapLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNIngressMap]
+}
+
+// NewVPNIngressMapLister returns a new VPNIngressMapLister.
+func NewVPNIngressMapLister(indexer cache.Indexer) VPNIngressMapLister {
+	return &vPNIngressMapLister{listers.New[*apiv1alpha1.VPNIngressMap](indexer, apiv1alpha1.Resource("vpningressmap"))}
+}
+
+// VPNIngressMaps returns an object that can list and get VPNIngressMaps.
+func (s *vPNIngressMapLister) VPNIngressMaps(namespace string) VPNIngressMapNamespaceLister {
+	return vPNIngressMapNamespaceLister{listers.NewNamespaced[*apiv1alpha1.VPNIngressMap](s.ResourceIndexer, namespace)}
+}
+
+// VPNIngressMapNamespaceLister helps list and get VPNIngressMaps.
+// All objects returned here must be treated as read-only.
+type VPNIngressMapNamespaceLister interface {
+	// List lists all VPNIngressMaps in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNIngressMap, err error)
+	// Get retrieves the VPNIngressMap from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.VPNIngressMap, error)
+	VPNIngressMapNamespaceListerExpansion
+}
+
+// vPNIngressMapNamespaceLister implements the VPNIngressMapNamespaceLister
+// interface.
+type vPNIngressMapNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNIngressMap]
+}
@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNIPPoolLister helps list VPNIPPools.
+// All objects returned here must be treated as read-only.
+type VPNIPPoolLister interface {
+	// List lists all VPNIPPools in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNIPPool, err error)
+	// VPNIPPools returns an object that can list and get VPNIPPools.
+	VPNIPPools(namespace string) VPNIPPoolNamespaceLister
+	VPNIPPoolListerExpansion
+}
+
+// vPNIPPoolLister implements the VPNIPPoolLister interface.
+type vPNIPPoolLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNIPPool]
+}
+
+// NewVPNIPPoolLister returns a new VPNIPPoolLister.
+func NewVPNIPPoolLister(indexer cache.Indexer) VPNIPPoolLister {
+	return &vPNIPPoolLister{listers.New[*apiv1alpha1.VPNIPPool](indexer, apiv1alpha1.Resource("vpnippool"))}
+}
+
+// VPNIPPools returns an object that can list and get VPNIPPools.
+func (s *vPNIPPoolLister) VPNIPPools(namespace string) VPNIPPoolNamespaceLister {
+	return vPNIPPoolNamespaceLister{listers.NewNamespaced[*apiv1alpha1.VPNIPPool](s.ResourceIndexer, namespace)}
+}
+
+// VPNIPPoolNamespaceLister helps list and get VPNIPPools.
+// All objects returned here must be treated as read-only.
+type VPNIPPoolNamespaceLister interface {
+	// List lists all VPNIPPools in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNIPPool, err error)
+	// Get retrieves the VPNIPPool from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.VPNIPPool, error)
+	VPNIPPoolNamespaceListerExpansion
+}
+
+// vPNIPPoolNamespaceLister implements the VPNIPPoolNamespaceLister
+// interface.
+type vPNIPPoolNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNIPPool]
+}
@@ -0,0 +1,32 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNKeyDenyListLister helps list VPNKeyDenyLists.
+// All objects returned here must be treated as read-only.
+type VPNKeyDenyListLister interface {
+	// List lists all VPNKeyDenyLists in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNKeyDenyList, err error)
+	// Get retrieves the VPNKeyDenyList from the index for a given name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.VPNKeyDenyList, error)
+	VPNKeyDenyListListerExpansion
+}
+
+// vPNKeyDenyListLister implements the VPNKeyDenyListLister interface.
+type vPNKeyDenyListLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNKeyDenyList]
+}
+
+// NewVPNKeyDenyListLister returns a new VPNKeyDenyListLister.
+func NewVPNKeyDenyListLister(indexer cache.Indexer) VPNKeyDenyListLister {
+	return &vPNKeyDenyListLister{listers.New[*apiv1alpha1.VPNKeyDenyList](indexer, apiv1alpha1.Resource("vpnkeydenylist"))}
+}
@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNNetworkLister helps list VPNNetworks.
+// All objects returned here must be treated as read-only.
+type VPNNetworkLister interface {
+	// List lists all VPNNetworks in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNNetwork, err error)
+	// VPNNetworks returns an object that can list and get VPNNetworks.
+	VPNNetworks(namespace string) VPNNetworkNamespaceLister
+	VPNNetworkListerExpansion
+}
+
+// vPNNetworkLister implements the VPNNetworkLister interface.
+type vPNNetworkLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNNetwork]
+}
+
+// NewVPNNetworkLister returns a new VPNNetworkLister.
+func NewVPNNetworkLister(indexer cache.Indexer) VPNNetworkLister {
+	return &vPNNetworkLister{listers.New[*apiv1alpha1.VPNNetwork](indexer, apiv1alpha1.Resource("vpnnetwork"))}
+}
+
+// VPNNetworks returns an object that can list and get VPNNetworks.
+func (s *vPNNetworkLister) VPNNetworks(namespace string) VPNNetworkNamespaceLister {
+	return vPNNetworkNamespaceLister{listers.NewNamespaced[*apiv1alpha1.VPNNetwork](s.ResourceIndexer, namespace)}
+}
+
+// VPNNetworkNamespaceLister helps list and get VPNNetworks.
+// All objects returned here must be treated as read-only.
+type VPNNetworkNamespaceLister interface {
+	// List lists all VPNNetworks in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNNetwork, err error)
+	// Get retrieves the VPNNetwork from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.VPNNetwork, error)
+	VPNNetworkNamespaceListerExpansion
+}
+
+// vPNNetworkNamespaceLister implements the VPNNetworkNamespaceLister
+// interface.
+type vPNNetworkNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNNetwork]
+}
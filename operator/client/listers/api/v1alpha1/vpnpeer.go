@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNPeerLister helps list VPNPeers.
+// All objects returned here must be treated as read-only.
+type VPNPeerLister interface {
+	// List lists all VPNPeers in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNPeer, err error)
+	// VPNPeers returns an object that can list and get VPNPeers.
+	VPNPeers(namespace string) VPNPeerNamespaceLister
+	VPNPeerListerExpansion
+}
+
+// vPNPeerLister implements the VPNPeerLister interface.
+type vPNPeerLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNPeer]
+}
+
+// NewVPNPeerLister returns a new VPNPeerLister.
+func NewVPNPeerLister(indexer cache.Indexer) VPNPeerLister {
+	return &vPNPeerLister{listers.New[*apiv1alpha1.VPNPeer](indexer, apiv1alpha1.Resource("vpnpeer"))}
+}
+
+// VPNPeers returns an object that can list and get VPNPeers.
+func (s *vPNPeerLister) VPNPeers(namespace string) VPNPeerNamespaceLister {
+	return vPNPeerNamespaceLister{listers.NewNamespaced[*apiv1alpha1.VPNPeer](s.ResourceIndexer, namespace)}
+}
+
+// VPNPeerNamespaceLister helps list and get VPNPeers.
+// All objects returned here must be treated as read-only.
+type VPNPeerNamespaceLister interface {
+	// List lists all VPNPeers in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNPeer, err error)
+	// Get retrieves the VPNPeer from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.VPNPeer, error)
+	VPNPeerNamespaceListerExpansion
+}
+
+// vPNPeerNamespaceLister implements the VPNPeerNamespaceLister
+// interface.
+type vPNPeerNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNPeer]
+}
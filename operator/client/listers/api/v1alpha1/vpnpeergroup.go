@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNPeerGroupLister helps list VPNPeerGroups.
+// All objects returned here must be treated as read-only.
+type VPNPeerGroupLister interface {
+	// List lists all VPNPeerGroups in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNPeerGroup, err error)
+	// VPNPeerGroups returns an object that can list and get VPNPeerGroups.
+	VPNPeerGroups(namespace string) VPNPeerGroupNamespaceLister
+	VPNPeerGroupListerExpansion
+}
+
+// vPNPeerGroupLister implements the VPNPeerGroupLister interface.
+type vPNPeerGroupLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNPeerGroup]
+}
+
+// NewVPNPeerGroupLister returns a new VPNPeerGroupLister.
+func NewVPNPeerGroupLister(indexer cache.Indexer) VPNPeerGroupLister {
+	return &vPNPeerGroupLister{listers.New[*apiv1alpha1.VPNPeerGroup](indexer, apiv1alpha1.Resource("vpnpeergroup"))}
+}
+
+// VPNPeerGroups returns an object that can list and get VPNPeerGroups.
+func (s *vPNPeerGroupLister) VPNPeerGroups(namespace string) VPNPeerGroupNamespaceLister {
+	return vPNPeerGroupNamespaceLister{listers.NewNamespaced[*apiv1alpha1.VPNPeerGroup](s.ResourceIndexer, namespace)}
+}
+
+// VPNPeerGroupNamespaceLister helps list and get VPNPeerGroups.
+// All objects returned here must be treated as read-only.
+type VPNPeerGroupNamespaceLister interface {
+	// List lists all VPNPeerGroups in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNPeerGroup, err error)
+	// Get retrieves the VPNPeerGroup from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.VPNPeerGroup, error)
+	VPNPeerGroupNamespaceListerExpansion
+}
+
+// vPNPeerGroupNamespaceLister implements the VPNPeerGroupNamespaceLister
+// interface.
+type vPNPeerGroupNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNPeerGroup]
+}
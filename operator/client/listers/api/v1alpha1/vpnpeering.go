@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNPeeringLister helps list VPNPeerings.
+// All objects returned here must be treated as read-only.
+type VPNPeeringLister interface {
+	// List lists all VPNPeerings in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNPeering, err error)
+	// VPNPeerings returns an object that can list and get VPNPeerings.
+	VPNPeerings(namespace string) VPNPeeringNamespaceLister
+	VPNPeeringListerExpansion
+}
+
+// vPNPeeringLister implements the VPNPeeringLister interface.
+type vPNPeeringLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNPeering]
+}
+
+// NewVPNPeeringLister returns a new VPNPeeringLister.
+func NewVPNPeeringLister(indexer cache.Indexer) VPNPeeringLister {
+	return &vPNPeeringLister{listers.New[*apiv1alpha1.VPNPeering](indexer, apiv1alpha1.Resource("vpnpeering"))}
+}
+
+// VPNPeerings returns an object that can list and get VPNPeerings.
+func (s *vPNPeeringLister) VPNPeerings(namespace string) VPNPeeringNamespaceLister {
+	return vPNPeeringNamespaceLister{listers.NewNamespaced[*apiv1alpha1.VPNPeering](s.ResourceIndexer, namespace)}
+}
+
+// VPNPeeringNamespaceLister helps list and get VPNPeerings.
+// All objects returned here must be treated as read-only.
+type VPNPeeringNamespaceLister interface {
+	// List lists all VPNPeerings in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNPeering, err error)
+	// Get retrieves the VPNPeering from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.VPNPeering, error)
+	VPNPeeringNamespaceListerExpansion
+}
+
+// vPNPeeringNamespaceLister implements the VPNPeeringNamespaceLister
+// interface.
+type vPNPeeringNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNPeering]
+}
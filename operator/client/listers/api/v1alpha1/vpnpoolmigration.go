@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNPoolMigrationLister helps list VPNPoolMigrations.
+// All objects returned here must be treated as read-only.
+type VPNPoolMigrationLister interface {
+	// List lists all VPNPoolMigrations in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNPoolMigration, err error)
+	// VPNPoolMigrations returns an object that can list and get VPNPoolMigrations.
+	VPNPoolMigrations(namespace string) VPNPoolMigrationNamespaceLister
+	VPNPoolMigrationListerExpansion
+}
+
+// vPNPoolMigrationLister implements the VPNPoolMigrationLister interface.
+type vPNPoolMigrationLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNPoolMigration]
+}
+
+// NewVPNPoolMigrationLister returns a new VPNPoolMigrationLister.
+func NewVPNPoolMigrationLister(indexer cache.Indexer) VPNPoolMigrationLister {
+	return &vPNPoolMigrationLister{listers.New[*apiv1alpha1.VPNPoolMigration](indexer, apiv1alpha1.Resource("vpnpoolmigration"))}
+}
+
+// VPNPoolMigrations returns an object that can list and get VPNPoolMigrations.
+func (s *vPNPoolMigrationLister) VPNPoolMigrations(namespace string) VPNPoolMigrationNamespaceLister {
+	return vPNPoolMigrationNamespaceLister{listers.NewNamespaced[*apiv1alpha1.VPNPoolMigration](s.ResourceIndexer, namespace)}
+}
+
+// VPNPoolMigrationNamespaceLister helps list and get VPNPoolMigrations.
+// All objects returned here must be treated as read-only.
+type VPNPoolMigrationNamespaceLister interface {
+	// List lists all VPNPoolMigrations in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNPoolMigration, err error)
+	// Get retrieves the VPNPoolMigration from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.VPNPoolMigration, error)
+	VPNPoolMigrationNamespaceListerExpansion
+}
+
+// vPNPoolMigrationNamespaceLister implements the VPNPoolMigrationNamespaceLister
+// interface.
+type vPNPoolMigrationNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNPoolMigration]
+}
@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNSelfEnrollmentLister helps list VPNSelfEnrollments.
+// All objects returned here must be treated as read-only.
+type VPNSelfEnrollmentLister interface {
+	// List lists all VPNSelfEnrollments in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNSelfEnrollment, err error)
+	// VPNSelfEnrollments returns an object that can list and get VPNSelfEnrollments.
+	VPNSelfEnrollments(namespace string) VPNSelfEnrollmentNamespaceLister
+	VPNSelfEnrollmentListerExpansion
+}
+
+// vPNSelfEnrollmentLister implements the VPNSelfEnrollmentLister interface.
+type vPNSelfEnrollmentLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNSelfEnrollment]
+}
+
+// NewVPNSelfEnrollmentLister returns a new VPNSelfEnrollmentLister.
+func NewVPNSelfEnrollmentLister(indexer cache.Indexer) VPNSelfEnrollmentLister {
+	return &vPNSelfEnrollmentLister{listers.New[*apiv1alpha1.VPNSelfEnrollment](indexer, apiv1alpha1.Resource("vpnselfenrollment"))}
+}
+
+// VPNSelfEnrollments returns an object that can list and get VPNSelfEnrollments.
+func (s *vPNSelfEnrollmentLister) VPNSelfEnrollments(namespace string) VPNSelfEnrollmentNamespaceLister {
+	return vPNSelfEnrollmentNamespaceLister{listers.NewNamespaced[*apiv1alpha1.VPNSelfEnrollment](s.ResourceIndexer, namespace)}
+}
+
+// VPNSelfEnrollmentNamespaceLister helps list and get VPNSelfEnrollments.
+// All objects returned here must be treated as read-only.
+type VPNSelfEnrollmentNamespaceLister interface {
+	// List lists all VPNSelfEnrollments in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNSelfEnrollment, err error)
+	// Get retrieves the VPNSelfEnrollment from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.VPNSelfEnrollment, error)
+	VPNSelfEnrollmentNamespaceListerExpansion
+}
+
+// vPNSelfEnrollmentNamespaceLister implements the VPNSelfEnrollmentNamespaceLister
+// interface.
+type vPNSelfEnrollmentNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNSelfEnrollment]
+}
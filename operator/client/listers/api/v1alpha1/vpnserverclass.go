@@ -0,0 +1,32 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNServerClassLister helps list VPNServerClasses.
+// All objects returned here must be treated as read-only.
+type VPNServerClassLister interface {
+	// List lists all VPNServerClasses in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNServerClass, err error)
+	// Get retrieves the VPNServerClass from the index for a given name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.VPNServerClass, error)
+	VPNServerClassListerExpansion
+}
+
+// vPNServerClassLister implements the VPNServerClassLister interface.
+type vPNServerClassLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNServerClass]
+}
+
+// NewVPNServerClassLister returns a new VPNServerClassLister.
+func NewVPNServerClassLister(indexer cache.Indexer) VPNServerClassLister {
+	return &vPNServerClassLister{listers.New[*apiv1alpha1.VPNServerClass](indexer, apiv1alpha1.Resource("vpnserverclass"))}
+}
@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	apiv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNSessionLister helps list VPNSessions.
+// All objects returned here must be treated as read-only.
+type VPNSessionLister interface {
+	// List lists all VPNSessions in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNSession, err error)
+	// VPNSessions returns an object that can list and get VPNSessions.
+	VPNSessions(namespace string) VPNSessionNamespaceLister
+	VPNSessionListerExpansion
+}
+
+// vPNSessionLister implements the VPNSessionLister interface.
+type vPNSessionLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNSession]
+}
+
+// NewVPNSessionLister returns a new VPNSessionLister.
+func NewVPNSessionLister(indexer cache.Indexer) VPNSessionLister {
+	return &vPNSessionLister{listers.New[*apiv1alpha1.VPNSession](indexer, apiv1alpha1.Resource("vpnsession"))}
+}
+
+// VPNSessions returns an object that can list and get VPNSessions.
+func (s *vPNSessionLister) VPNSessions(namespace string) VPNSessionNamespaceLister {
+	return vPNSessionNamespaceLister{listers.NewNamespaced[*apiv1alpha1.VPNSession](s.ResourceIndexer, namespace)}
+}
+
+// VPNSessionNamespaceLister helps list and get VPNSessions.
+// All objects returned here must be treated as read-only.
+type VPNSessionNamespaceLister interface {
+	// List lists all VPNSessions in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1alpha1.VPNSession, err error)
+	// Get retrieves the VPNSession from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1alpha1.VPNSession, error)
+	VPNSessionNamespaceListerExpansion
+}
+
+// vPNSessionNamespaceLister implements the VPNSessionNamespaceLister
+// interface.
+type vPNSessionNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1alpha1.VPNSession]
+}
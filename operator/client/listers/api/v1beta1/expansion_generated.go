@@ -0,0 +1,19 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1beta1
+
+// VPNPeerListerExpansion allows custom methods to be added to
+// VPNPeerLister.
+type VPNPeerListerExpansion interface{}
+
+// VPNPeerNamespaceListerExpansion allows custom methods to be added to
+// VPNPeerNamespaceLister.
+type VPNPeerNamespaceListerExpansion interface{}
+
+// VPNServerListerExpansion allows custom methods to be added to
+// VPNServerLister.
+type VPNServerListerExpansion interface{}
+
+// VPNServerNamespaceListerExpansion allows custom methods to be added to
+// VPNServerNamespaceLister.
+type VPNServerNamespaceListerExpansion interface{}
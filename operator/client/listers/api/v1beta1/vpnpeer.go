@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	apiv1beta1 "github.com/vpn-devops/vpn-operator/api/v1beta1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNPeerLister helps list VPNPeers.
+// All objects returned here must be treated as read-only.
+type VPNPeerLister interface {
+	// List lists all VPNPeers in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1beta1.VPNPeer, err error)
+	// VPNPeers returns an object that can list and get VPNPeers.
+	VPNPeers(namespace string) VPNPeerNamespaceLister
+	VPNPeerListerExpansion
+}
+
+// vPNPeerLister implements the VPNPeerLister interface.
+type vPNPeerLister struct {
+	listers.ResourceIndexer[*apiv1beta1.VPNPeer]
+}
+
+// NewVPNPeerLister returns a new VPNPeerLister.
+func NewVPNPeerLister(indexer cache.Indexer) VPNPeerLister {
+	return &vPNPeerLister{listers.New[*apiv1beta1.VPNPeer](indexer, apiv1beta1.Resource("vpnpeer"))}
+}
+
+// VPNPeers returns an object that can list and get VPNPeers.
+func (s *vPNPeerLister) VPNPeers(namespace string) VPNPeerNamespaceLister {
+	return vPNPeerNamespaceLister{listers.NewNamespaced[*apiv1beta1.VPNPeer](s.ResourceIndexer, namespace)}
+}
+
+// VPNPeerNamespaceLister helps list and get VPNPeers.
+// All objects returned here must be treated as read-only.
+type VPNPeerNamespaceLister interface {
+	// List lists all VPNPeers in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1beta1.VPNPeer, err error)
+	// Get retrieves the VPNPeer from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1beta1.VPNPeer, error)
+	VPNPeerNamespaceListerExpansion
+}
+
+// vPNPeerNamespaceLister implements the VPNPeerNamespaceLister
+// interface.
+type vPNPeerNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1beta1.VPNPeer]
+}
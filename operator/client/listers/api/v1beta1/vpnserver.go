@@ -0,0 +1,54 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	apiv1beta1 "github.com/vpn-devops/vpn-operator/api/v1beta1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// VPNServerLister helps list VPNServers.
+// All objects returned here must be treated as read-only.
+type VPNServerLister interface {
+	// List lists all VPNServers in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1beta1.VPNServer, err error)
+	// VPNServers returns an object that can list and get VPNServers.
+	VPNServers(namespace string) VPNServerNamespaceLister
+	VPNServerListerExpansion
+}
+
+// vPNServerLister implements the VPNServerLister interface.
+type vPNServerLister struct {
+	listers.ResourceIndexer[*apiv1beta1.VPNServer]
+}
+
+// NewVPNServerLister returns a new VPNServerLister.
+func NewVPNServerLister(indexer cache.Indexer) VPNServerLister {
+	return &vPNServerLister{listers.New[*apiv1beta1.VPNServer](indexer, apiv1beta1.Resource("vpnserver"))}
+}
+
+// VPNServers returns an object that can list and get VPNServers.
+func (s *vPNServerLister) VPNServers(namespace string) VPNServerNamespaceLister {
+	return vPNServerNamespaceLister{listers.NewNamespaced[*apiv1beta1.VPNServer](s.ResourceIndexer, namespace)}
+}
+
+// VPNServerNamespaceLister helps list and get VPNServers.
+// All objects returned here must be treated as read-only.
+type VPNServerNamespaceLister interface {
+	// List lists all VPNServers in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1beta1.VPNServer, err error)
+	// Get retrieves the VPNServer from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1beta1.VPNServer, error)
+	VPNServerNamespaceListerExpansion
+}
+
+// vPNServerNamespaceLister implements the VPNServerNamespaceLister
+// interface.
+type vPNServerNamespaceLister struct {
+	listers.ResourceIndexer[*apiv1beta1.VPNServer]
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// bundleLabel is set on the resources expanded from a bundle to its name
+const bundleLabel = "vpn.vpn-devops.com/bundle"
+
+// bundle is a high-level document describing a server with its pool,
+// groups and initial peers, for POCs and demos. It is not a resource of
+// the cluster: apply expands it into the underlying resources.
+//
+//	apiVersion: vpn.vpn-devops.com/v1alpha1
+//	kind: VPNBundle
+//	metadata:
+//	  name: demo
+//	spec:
+//	  pool:
+//	    cidr: 10.9.0.0/24
+//	  server:
+//	    image: wireflow/wireguard:latest
+//	    allowedIPs: 10.0.0.0/8
+//	  groups:
+//	  - name: engineering
+//	    dns: 10.0.0.10
+//	  peers:
+//	  - name: alice
+//	    group: engineering
+//	    publicKey: ...
+type bundle struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec bundleSpec `json:"spec"`
+}
+
+type bundleSpec struct {
+	// Server is the spec of the server, named after the bundle. Its
+	// address defaults to the first address of the pool.
+	Server vpnv1alpha1.VPNServerSpec `json:"server"`
+
+	// Pool is the spec of the pool, named <bundle>-pool. Peers without an
+	// address are assigned the next free address of its CIDR.
+	Pool *vpnv1alpha1.VPNIPPoolSpec `json:"pool,omitempty"`
+
+	// Groups are the peer groups, named as given so that peers and access
+	// policies reference them by name
+	Groups []bundleGroup `json:"groups,omitempty"`
+
+	// Peers are the initial peers, named <bundle>-<name>
+	Peers []bundlePeer `json:"peers,omitempty"`
+}
+
+type bundleGroup struct {
+	Name                         string `json:"name"`
+	vpnv1alpha1.VPNPeerGroupSpec `json:",inline"`
+}
+
+type bundlePeer struct {
+	Name                    string `json:"name"`
+	vpnv1alpha1.VPNPeerSpec `json:",inline"`
+}
+
+// applyBundle expands the bundles of a multi-document YAML file into
+// VPNServers, VPNIPPools, VPNPeerGroups and VPNPeers and server-side applies
+// them, so that applying the file again updates them.
+func applyBundle(args []string) error {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	file := fs.String("f", "", "The bundle file, - for stdin. Required.")
+	namespace := fs.String("namespace", "", "The namespace of bundles without one. Defaults to the namespace of the current context.")
+	fs.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	dryRun := fs.Bool("dry-run", false, "Print the expanded resources instead of applying them.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow apply -f <bundle.yaml> [flags]")
+		fs.PrintDefaults()
+	}
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		fs.Usage()
+		return fmt.Errorf("-f is required")
+	}
+
+	raw, err := readFileOrStdin(*file)
+	if err != nil {
+		return err
+	}
+	bundles, err := decodeBundles(raw)
+	if err != nil {
+		return err
+	}
+
+	var c client.Client
+	if !*dryRun || *namespace == "" {
+		var defaultNamespace string
+		if c, defaultNamespace, err = newClient(); err != nil && !*dryRun {
+			return err
+		}
+		if *namespace == "" {
+			*namespace = defaultNamespace
+		}
+	}
+
+	var objects []client.Object
+	for i := range bundles {
+		if bundles[i].Namespace == "" {
+			bundles[i].Namespace = *namespace
+		}
+		expanded, err := expandBundle(&bundles[i])
+		if err != nil {
+			return fmt.Errorf("bundle %s: %w", bundles[i].Name, err)
+		}
+		objects = append(objects, expanded...)
+	}
+
+	return applyObjects(c, objects, *dryRun)
+}
+
+// applyObjects server-side applies objects, or prints them with dryRun.
+func applyObjects(c client.Client, objects []client.Object, dryRun bool) error {
+	ctx := context.Background()
+	for i, obj := range objects {
+		kind := obj.GetObjectKind().GroupVersionKind().Kind
+		if dryRun {
+			out, err := yaml.Marshal(obj)
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				fmt.Println("---")
+			}
+			fmt.Print(string(out))
+			continue
+		}
+		if err := c.Patch(ctx, obj, client.Apply, client.FieldOwner("wireflow"), client.ForceOwnership); err != nil {
+			return fmt.Errorf("applying %s %s/%s: %w", kind, obj.GetNamespace(), obj.GetName(), err)
+		}
+		fmt.Fprintf(os.Stderr, "%s %s/%s applied\n", strings.ToLower(kind), obj.GetNamespace(), obj.GetName())
+	}
+	return nil
+}
+
+// decodeBundles decodes the VPNBundle documents of a multi-document YAML
+// file.
+func decodeBundles(raw []byte) ([]bundle, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(raw), 4096)
+	var bundles []bundle
+	for {
+		var b bundle
+		if err := decoder.Decode(&b); err != nil {
+			if errors.Is(err, io.EOF) {
+				return bundles, nil
+			}
+			return nil, err
+		}
+		if b.Kind == "" && b.Name == "" {
+			continue
+		}
+		if b.Kind != "VPNBundle" || b.APIVersion != vpnv1alpha1.GroupVersion.String() {
+			return nil, fmt.Errorf("expected %s VPNBundle documents, got %s %s", vpnv1alpha1.GroupVersion, b.APIVersion, b.Kind)
+		}
+		if b.Name == "" {
+			return nil, fmt.Errorf("bundle without a name")
+		}
+		bundles = append(bundles, b)
+	}
+}
+
+// expandBundle returns the resources of a bundle, the ones referenced
+// first.
+func expandBundle(b *bundle) ([]client.Object, error) {
+	meta := func(kind, name string) (metav1.TypeMeta, metav1.ObjectMeta) {
+		labels := map[string]string{bundleLabel: b.Name}
+		for key, value := range b.Labels {
+			labels[key] = value
+		}
+		return metav1.TypeMeta{APIVersion: vpnv1alpha1.GroupVersion.String(), Kind: kind},
+			metav1.ObjectMeta{Name: name, Namespace: b.Namespace, Labels: labels}
+	}
+
+	var objects []client.Object
+	var network *net.IPNet
+	used := map[string]bool{}
+	if b.Spec.Pool != nil {
+		_, pool, err := net.ParseCIDR(b.Spec.Pool.CIDR)
+		if err != nil {
+			return nil, fmt.Errorf("pool: %w", err)
+		}
+		network = pool
+		typeMeta, objectMeta := meta("VPNIPPool", b.Name+"-pool")
+		objects = append(objects, &vpnv1alpha1.VPNIPPool{TypeMeta: typeMeta, ObjectMeta: objectMeta, Spec: *b.Spec.Pool})
+	}
+
+	for _, peer := range b.Spec.Peers {
+		if ip := net.ParseIP(strings.Split(peer.Address, "/")[0]); ip != nil {
+			used[ip.String()] = true
+		}
+	}
+
+	server := b.Spec.Server
+	if server.Address == "" {
+		if network == nil {
+			return nil, fmt.Errorf("server.address is required without a pool")
+		}
+		ip := nextAddress(network, used)
+		if ip == nil {
+			return nil, fmt.Errorf("pool %s has no free address for the server", network)
+		}
+		ones, _ := network.Mask.Size()
+		server.Address = fmt.Sprintf("%s/%d", ip, ones)
+	}
+	if ip, _, err := net.ParseCIDR(server.Address); err == nil {
+		used[ip.String()] = true
+	}
+	typeMeta, objectMeta := meta("VPNServer", b.Name)
+	objects = append(objects, &vpnv1alpha1.VPNServer{TypeMeta: typeMeta, ObjectMeta: objectMeta, Spec: server})
+
+	groups := map[string]bool{}
+	for _, group := range b.Spec.Groups {
+		groups[group.Name] = true
+		typeMeta, objectMeta := meta("VPNPeerGroup", group.Name)
+		objects = append(objects, &vpnv1alpha1.VPNPeerGroup{TypeMeta: typeMeta, ObjectMeta: objectMeta, Spec: group.VPNPeerGroupSpec})
+	}
+
+	for _, peer := range b.Spec.Peers {
+		if peer.Group != "" && !groups[peer.Group] {
+			return nil, fmt.Errorf("peer %s: group %q is not defined in the bundle", peer.Name, peer.Group)
+		}
+		spec := peer.VPNPeerSpec
+		spec.ServerRef = vpnv1alpha1.LocalObjectReference{Name: b.Name}
+		if spec.Address == "" && network != nil {
+			ip := nextAddress(network, used)
+			if ip == nil {
+				return nil, fmt.Errorf("peer %s: pool %s has no free address", peer.Name, network)
+			}
+			spec.Address = ip.String() + "/32"
+			if ip.To4() == nil {
+				spec.Address = ip.String() + "/128"
+			}
+		}
+		typeMeta, objectMeta := meta("VPNPeer", b.Name+"-"+peer.Name)
+		objects = append(objects, &vpnv1alpha1.VPNPeer{TypeMeta: typeMeta, ObjectMeta: objectMeta, Spec: spec})
+	}
+	return objects, nil
+}
+
+// nextAddress returns the first host address of network not in used and
+// marks it used, or nil if there is none.
+func nextAddress(network *net.IPNet, used map[string]bool) net.IP {
+	ones, bits := network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	base := new(big.Int).SetBytes(network.IP)
+	// The network address is never assigned, nor the broadcast address of
+	// IPv4 networks
+	last := new(big.Int).Sub(size, big.NewInt(1))
+	if bits == 32 && bits-ones >= 2 {
+		last.Sub(last, big.NewInt(1))
+	}
+	for offset := big.NewInt(1); offset.Cmp(last) <= 0; offset.Add(offset, big.NewInt(1)) {
+		raw := new(big.Int).Add(base, offset).Bytes()
+		ip := make(net.IP, len(network.IP))
+		copy(ip[len(ip)-len(raw):], raw)
+		if !used[ip.String()] {
+			used[ip.String()] = true
+			return ip
+		}
+	}
+	return nil
+}
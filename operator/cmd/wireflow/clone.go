@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// cloneServer copies a VPNServer under a new name with new addressing, for
+// standing up a server in a new region. Keys are not copied: the clone
+// generates its own. With --with-policies the access policies bound to the
+// server, which map peer groups to networks, are cloned along with it.
+func cloneServer(args []string) error {
+	fs := flag.NewFlagSet("clone", flag.ExitOnError)
+	namespace := fs.String("namespace", "", "The namespace of the server. Defaults to the namespace of the current context.")
+	fs.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	to := fs.String("to", "", "The name of the clone. Required.")
+	toNamespace := fs.String("to-namespace", "", "The namespace of the clone. Defaults to the namespace of the server.")
+	address := fs.String("address", "", "The tunnel address of the clone, e.g. 10.9.0.1/24. Required.")
+	clusterName := fs.String("cluster-name", "", "Place the clone on this member cluster instead of the server's.")
+	withPolicies := fs.Bool("with-policies", false, "Also clone the VPNAccessPolicies bound to the server.")
+	dryRun := fs.Bool("dry-run", false, "Print the cloned objects instead of creating them.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow clone server <name> --to <name> --address <cidr> [flags]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 || positional[0] != "server" {
+		fs.Usage()
+		return fmt.Errorf("expected server <name>")
+	}
+	from := positional[1]
+	if *to == "" || *address == "" {
+		fs.Usage()
+		return fmt.Errorf("--to and --address are required")
+	}
+	if _, _, err := net.ParseCIDR(*address); err != nil {
+		return fmt.Errorf("--address: %w", err)
+	}
+
+	c, defaultNamespace, err := newClient()
+	if err != nil {
+		return err
+	}
+	if *namespace == "" {
+		*namespace = defaultNamespace
+	}
+	if *toNamespace == "" {
+		*toNamespace = *namespace
+	}
+
+	ctx := context.Background()
+	source := &vpnv1alpha1.VPNServer{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: *namespace, Name: from}, source); err != nil {
+		return err
+	}
+
+	clone := &vpnv1alpha1.VPNServer{
+		TypeMeta:   metav1.TypeMeta{APIVersion: vpnv1alpha1.GroupVersion.String(), Kind: "VPNServer"},
+		ObjectMeta: metav1.ObjectMeta{Name: *to, Namespace: *toNamespace, Labels: source.Labels},
+		Spec:       *source.Spec.DeepCopy(),
+	}
+	clone.Spec.Address = *address
+	if *clusterName != "" {
+		clone.Spec.ClusterName = *clusterName
+	}
+	objects := []client.Object{clone}
+
+	if *withPolicies {
+		policies := &vpnv1alpha1.VPNAccessPolicyList{}
+		if err := c.List(ctx, policies, client.InNamespace(*namespace)); err != nil {
+			return err
+		}
+		for _, policy := range policies.Items {
+			// Policies without a server reference already apply to every
+			// server of their namespace
+			if policy.Spec.ServerRef == nil || policy.Spec.ServerRef.Name != from {
+				continue
+			}
+			cloned := &vpnv1alpha1.VPNAccessPolicy{
+				TypeMeta:   metav1.TypeMeta{APIVersion: vpnv1alpha1.GroupVersion.String(), Kind: "VPNAccessPolicy"},
+				ObjectMeta: metav1.ObjectMeta{Name: clonedName(policy.Name, from, *to), Namespace: *toNamespace, Labels: policy.Labels},
+				Spec:       *policy.Spec.DeepCopy(),
+			}
+			cloned.Spec.ServerRef = &vpnv1alpha1.LocalObjectReference{Name: *to}
+			objects = append(objects, cloned)
+		}
+	}
+
+	for i, obj := range objects {
+		if *dryRun {
+			out, err := yaml.Marshal(obj)
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				fmt.Println("---")
+			}
+			fmt.Print(string(out))
+			continue
+		}
+		if err := c.Create(ctx, obj); err != nil {
+			return fmt.Errorf("creating %s %s/%s: %w", obj.GetObjectKind().GroupVersionKind().Kind, obj.GetNamespace(), obj.GetName(), err)
+		}
+		fmt.Fprintf(os.Stderr, "%s %s/%s created\n", strings.ToLower(obj.GetObjectKind().GroupVersionKind().Kind), obj.GetNamespace(), obj.GetName())
+	}
+	return nil
+}
+
+// clonedName returns the name of the clone of an object bound to server
+// from: the server name in it is replaced, or the clone's server name is
+// appended.
+func clonedName(name, from, to string) string {
+	if strings.Contains(name, from) {
+		return strings.Replace(name, from, to, 1)
+	}
+	return name + "-" + to
+}
+
+// newClient returns a client for the VPN resources and Secrets of the
+// cluster of the current context, and the namespace of the context.
+func newClient() (client.Client, string, error) {
+	config, namespace, err := loadConfig()
+	if err != nil {
+		return nil, "", err
+	}
+
+	scheme := runtime.NewScheme()
+	if err := vpnv1alpha1.AddToScheme(scheme); err != nil {
+		return nil, "", err
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return nil, "", err
+	}
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, "", err
+	}
+	return c, namespace, nil
+}
+
+// loadConfig returns the client config of the current context, and the
+// namespace of the context.
+func loadConfig() (*rest.Config, string, error) {
+	loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{})
+	config, err := loader.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	namespace, _, err := loader.Namespace()
+	if err != nil {
+		return nil, "", err
+	}
+	return config, namespace, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/vpn-devops/vpn-operator/pkg/envelope"
+)
+
+// decryptConfig opens the client configs sealed in the envelopes of a
+// Secret, or of a file, with the credentials of the user: those of AWS or
+// Google in the environment, or an age identity file.
+func decryptConfig(args []string) error {
+	fs := flag.NewFlagSet("decrypt-config", flag.ExitOnError)
+	namespace := fs.String("n", "", "The namespace of the Secret. Defaults to the namespace of the kubeconfig context.")
+	identityFile := fs.String("identity", "", "File holding age identities, for configs sealed with age.")
+	fromFile := fs.String("from-file", "", "Read the envelope from this file instead of the cluster.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow decrypt-config [-n namespace] [--identity file] <secret> [key]")
+		fmt.Fprintln(fs.Output(), "       wireflow decrypt-config [--identity file] --from-file <envelope>")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var identities []*envelope.AgeIdentity
+	if *identityFile != "" {
+		raw, err := os.ReadFile(*identityFile)
+		if err != nil {
+			return err
+		}
+		if identities, err = envelope.ParseAgeIdentities(raw); err != nil {
+			return err
+		}
+	}
+
+	var sealed [][]byte
+	switch {
+	case *fromFile != "":
+		data, err := readFileOrStdin(*fromFile)
+		if err != nil {
+			return err
+		}
+		sealed = append(sealed, data)
+	case len(positional) == 1 || len(positional) == 2:
+		if sealed, err = fetchEnvelopes(*namespace, positional); err != nil {
+			return err
+		}
+	default:
+		fs.Usage()
+		return fmt.Errorf("expected <secret> [key] or --from-file")
+	}
+
+	ctx := context.Background()
+	for _, data := range sealed {
+		e, err := envelope.Parse(data)
+		if err != nil {
+			return err
+		}
+		unwrapper, err := envelope.NewUnwrapper(e.Provider, e.KeyID, identities)
+		if err != nil {
+			return err
+		}
+		plaintext, err := e.Open(ctx, unwrapper)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stdout.Write(plaintext); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchEnvelopes reads the envelopes of a Secret: that of the given key, or
+// all of them in key order.
+func fetchEnvelopes(namespace string, positional []string) ([][]byte, error) {
+	c, defaultNamespace, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	secret := &corev1.Secret{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: positional[0]}, secret); err != nil {
+		return nil, err
+	}
+
+	if len(positional) == 2 {
+		key := positional[1]
+		if !strings.HasSuffix(key, envelope.Suffix) {
+			key += envelope.Suffix
+		}
+		data, ok := secret.Data[key]
+		if !ok {
+			return nil, fmt.Errorf("%s/%s has no sealed config %q", namespace, secret.Name, key)
+		}
+		return [][]byte{data}, nil
+	}
+
+	var keys []string
+	for key := range secret.Data {
+		if strings.HasSuffix(key, envelope.Suffix) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s/%s holds no sealed config", namespace, secret.Name)
+	}
+	sort.Strings(keys)
+	sealed := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		sealed = append(sealed, secret.Data[key])
+	}
+	return sealed, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// errDrift is returned by drift with --exit-code when a server drifts, so
+// that scripts can assert convergence after incidents and upgrades.
+var errDrift = errors.New("configuration drift found")
+
+// driftReport lists the servers whose live devices differ from their desired
+// configuration, as reported by the agents, with the nature of the
+// differences of each replica.
+func driftReport(args []string) error {
+	fs := flag.NewFlagSet("drift", flag.ExitOnError)
+	namespace := fs.String("namespace", "", "The namespace of the servers. Defaults to the namespace of the current context.")
+	fs.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	allNamespaces := fs.Bool("all-namespaces", false, "Report the servers of every namespace.")
+	fs.BoolVar(allNamespaces, "A", false, "Shorthand for --all-namespaces.")
+	all := fs.Bool("all", false, "Also list the servers that converged or were not checked.")
+	exitCode := fs.Bool("exit-code", false, "Exit with status 1 if a server drifts.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow drift [<server>...] [flags]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	c, defaultNamespace, err := newClient()
+	if err != nil {
+		return err
+	}
+	var opts []client.ListOption
+	if !*allNamespaces {
+		if *namespace == "" {
+			*namespace = defaultNamespace
+		}
+		opts = append(opts, client.InNamespace(*namespace))
+	}
+	servers := &vpnv1alpha1.VPNServerList{}
+	if err := c.List(context.Background(), servers, opts...); err != nil {
+		return err
+	}
+	selected := map[string]bool{}
+	for _, name := range positional {
+		selected[name] = true
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tSERVER\tDRIFT\tCHECKED\tREPLICA\tDIFFERENCES")
+	drifted, converged, unchecked := 0, 0, 0
+	for _, server := range servers.Items {
+		if len(selected) > 0 && !selected[server.Name] {
+			continue
+		}
+		condition := vpnv1alpha1.FindCondition(server.Status.Conditions, vpnv1alpha1.ConditionConfigDrift)
+		state := "Unknown"
+		if condition != nil {
+			state = condition.Status
+		}
+		checked := "never"
+		if drift := server.Status.Drift; drift != nil && drift.CheckedTime != nil {
+			checked = drift.CheckedTime.UTC().Format(time.RFC3339)
+		}
+		switch state {
+		case vpnv1alpha1.ConditionTrue:
+			drifted++
+		case vpnv1alpha1.ConditionFalse:
+			converged++
+		default:
+			unchecked++
+		}
+		if state != vpnv1alpha1.ConditionTrue {
+			if *all {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\t\n", server.Namespace, server.Name, state, checked)
+			}
+			continue
+		}
+		for _, replica := range server.Status.Drift.Replicas {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", server.Namespace, server.Name, state, checked, replica.Pod, driftDifferences(replica))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d drifted, %d converged, %d not checked\n", drifted, converged, unchecked)
+	if *exitCode && drifted > 0 {
+		return errDrift
+	}
+	return nil
+}
+
+// driftDifferences describes the differences of a replica, e.g.
+// "missing: alice, bob; changed: carol (allowed-ips)".
+func driftDifferences(replica vpnv1alpha1.ReplicaDrift) string {
+	var parts []string
+	if len(replica.MissingPeers) > 0 {
+		parts = append(parts, "missing: "+strings.Join(replica.MissingPeers, ", "))
+	}
+	if len(replica.UnexpectedPeers) > 0 {
+		parts = append(parts, "unexpected: "+strings.Join(replica.UnexpectedPeers, ", "))
+	}
+	if len(replica.ChangedPeers) > 0 {
+		changed := make([]string, 0, len(replica.ChangedPeers))
+		for _, peer := range replica.ChangedPeers {
+			changed = append(changed, fmt.Sprintf("%s (%s)", peer.Name, strings.Join(peer.Fields, ", ")))
+		}
+		parts = append(parts, "changed: "+strings.Join(changed, ", "))
+	}
+	if len(replica.Interface) > 0 {
+		parts = append(parts, "interface: "+strings.Join(replica.Interface, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vpn-devops/vpn-operator/pkg/enrollment"
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+)
+
+// enrollDevice enrolls this device through a VPNSelfEnrollment: it submits
+// the public key of the local private key with the user's enrollment token
+// and a proof of possession, and prints the client config of the new peer
+// once the operator resolved it. The private key never leaves the machine.
+func enrollDevice(args []string) error {
+	fs := flag.NewFlagSet("enroll", flag.ExitOnError)
+	keyFile := fs.String("key-file", "", "File holding the WireGuard private key of the device, e.g. from wg genkey. Required.")
+	tokenFile := fs.String("token-file", "", "File holding the enrollment token, - for stdin. Required.")
+	url := fs.String("url", "", "The enrollment endpoint of the operator, e.g. https://vpn-operator:8085. Required.")
+	name := fs.String("name", "", "The device name, 1 to 20 letters, digits or dashes. Defaults to the short hostname.")
+	fetch := fs.Bool("fetch", false, "Fetch the config of the device enrolled before with --name instead of enrolling it.")
+	wait := fs.Duration("wait", 2*time.Minute, "How long to wait for the config of the new peer.")
+	output := fs.String("output", "", "Write the client config to this file instead of stdout.")
+	fs.StringVar(output, "o", "", "Shorthand for --output.")
+	asQR := fs.Bool("qr", false, "Write the client config as a QR code, to scan with the WireGuard mobile apps. A PNG image if --output ends in .png.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow enroll --key-file <file> --token-file <file> --url <url> [--name <device>] <namespace>/<self-enrollment>")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if *keyFile == "" || *tokenFile == "" || *url == "" || len(positional) != 1 || !strings.Contains(positional[0], "/") {
+		fs.Usage()
+		return fmt.Errorf("expected --key-file, --token-file, --url and <namespace>/<self-enrollment>")
+	}
+	if *name == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		*name = strings.ToLower(strings.SplitN(host, ".", 2)[0])
+	}
+
+	raw, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return err
+	}
+	key, err := escrow.ParseKey(string(raw))
+	if err != nil {
+		return fmt.Errorf("private key: %w", err)
+	}
+	token, err := readFileOrStdin(*tokenFile)
+	if err != nil {
+		return err
+	}
+
+	httpClient := &http.Client{Timeout: 60 * time.Second}
+	base := strings.TrimSuffix(*url, "/") + "/enroll/"
+	devices := base + "self/" + positional[0] + "/devices"
+	bearer := "Bearer " + strings.TrimSpace(string(token))
+	req, err := http.NewRequest(http.MethodGet, devices+"/"+*name, nil)
+	if err != nil {
+		return err
+	}
+	if !*fetch {
+		challenge, err := fetchChallenge(httpClient, base)
+		if err != nil {
+			return err
+		}
+		proof, err := enrollment.Prove(key, challenge)
+		if err != nil {
+			return err
+		}
+		body, err := json.Marshal(map[string]any{
+			"name":      *name,
+			"publicKey": key.PublicKey().String(),
+			"challenge": challenge,
+			"proof":     proof,
+			"client":    clientReport,
+		})
+		if err != nil {
+			return err
+		}
+		if req, err = http.NewRequest(http.MethodPost, devices, bytes.NewReader(body)); err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", bearer)
+
+	deadline := time.Now().Add(*wait)
+	for {
+		device, pending, err := enrollmentDevice(httpClient, req)
+		if err != nil {
+			return err
+		}
+		if !pending {
+			fmt.Fprintf(os.Stderr, "device %s is peer %s with public key %s\n", device.Name, device.Peer, key.PublicKey())
+			// The config is returned without the private key
+			return writeClientConfig(device.Config, key, *output, *asQR)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("device %s was enrolled as peer %s, but its config was not ready within %s, fetch it later with --fetch --name %s", device.Name, device.Peer, *wait, device.Name)
+		}
+		time.Sleep(2 * time.Second)
+		if req, err = http.NewRequest(http.MethodGet, devices+"/"+device.Name, nil); err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", bearer)
+	}
+}
+
+// enrolledDevice is a device as returned by the self enrollment API
+type enrolledDevice struct {
+	Name   string `json:"name"`
+	Peer   string `json:"peer"`
+	Config string `json:"config"`
+}
+
+// enrollmentDevice sends a request of the self enrollment API returning a
+// device, and whether its config is still pending.
+func enrollmentDevice(httpClient *http.Client, req *http.Request) (*enrolledDevice, bool, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusAccepted:
+	default:
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, false, fmt.Errorf("enrolling the device: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	device := &enrolledDevice{}
+	if err := json.NewDecoder(resp.Body).Decode(device); err != nil {
+		return nil, false, err
+	}
+	return device, resp.StatusCode == http.StatusAccepted, nil
+}
+
+// fetchChallenge fetches a proof of possession challenge from the
+// enrollment endpoint.
+func fetchChallenge(httpClient *http.Client, base string) (string, error) {
+	resp, err := httpClient.Get(base + "challenge")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	challenge, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching a challenge: %s", resp.Status)
+	}
+	return strings.TrimSpace(string(challenge)), nil
+}
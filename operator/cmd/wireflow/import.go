@@ -0,0 +1,454 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+)
+
+const (
+	// importedFromLabel is set on imported resources to the tool they were
+	// imported from
+	importedFromLabel = "vpn.vpn-devops.com/imported-from"
+
+	presharedKeyEntry = vpnv1alpha1.DefaultPresharedKeyKey
+
+	// importConflictsAnnotation records on imported peers what could not
+	// be reconstructed for them, for manual resolution
+	importConflictsAnnotation = "vpn.vpn-devops.com/import-conflicts"
+)
+
+const importUsage = `Usage: wireflow import <wg-easy|wg-portal|wg> -f <export> --server <name> --image <image> [flags]
+
+wg-easy exports are its wg0.json. wg-portal exports are a JSON object of the
+rows of its devices and peers tables, e.g.:
+  jq -n --argjson devices "$(sqlite3 -json wg_portal.db 'select * from devices')" \
+    --argjson peers "$(sqlite3 -json wg_portal.db 'select * from peers')" \
+    '{devices: $devices, peers: $peers}' > export.json
+
+Hand-managed servers are imported from the live device and its routes:
+  wg showconf wg0 > wg0.conf && ip route show dev wg0 > routes.txt
+  wireflow import wg -f wg0.conf --routes routes.txt ...
+The addresses of the peers and the networks routed to them are taken from
+their AllowedIPs. What cannot be reconstructed is reported as conflicts and
+recorded on the peers in the vpn.vpn-devops.com/import-conflicts annotation.
+Imports are idempotent, so they can be repeated once conflicts are resolved.
+
+`
+
+// invalidNameChars matches the characters not allowed in resource names
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// importedServer is the server of a WireGuard manager being migrated from
+type importedServer struct {
+	PrivateKey string
+	Address    string
+	Port       int32
+	DNS        []string
+	MTU        int32
+	AllowedIPs []string
+
+	// RoutingProfiles are the client routing profiles reconstructed from
+	// the networks routed to peers
+	RoutingProfiles []vpnv1alpha1.ClientRoutingProfile
+}
+
+// importedPeer is a peer of a WireGuard manager being migrated from
+type importedPeer struct {
+	Name         string
+	PublicKey    string
+	PresharedKey string
+	Addresses    []string
+	AllowedIPs   []string
+	Disabled     bool
+
+	// Conflicts are what could not be reconstructed for the peer
+	Conflicts []string
+}
+
+// importServer generates a VPNServer and its VPNPeers from the data of
+// wg-easy or wg-portal, keeping the server key, the addresses and the
+// preshared keys, so that existing client configs keep working once the
+// server endpoint points to the new server.
+func importServer(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	file := fs.String("f", "", "The export to import, - for stdin. Required.")
+	server := fs.String("server", "", "The name of the VPNServer. Required.")
+	namespace := fs.String("namespace", "", "The namespace of the resources. Defaults to the namespace of the current context.")
+	fs.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	image := fs.String("image", "", "The VPN server image. Required.")
+	device := fs.String("device", "", "The wg-portal device to import, if the export has several.")
+	prefix := fs.Int("prefix-length", 24, "The prefix length of the tunnel network, for wg-easy which stores the bare server address.")
+	port := fs.Int("port", 0, "The listen port. Defaults to the port of the export, or 51820.")
+	dns := fs.String("dns", "", "Comma separated DNS servers of clients. Defaults to those of the export.")
+	allowedIPs := fs.String("allowed-ips", "", "Comma separated allowed IPs of clients. Defaults to the ones of the export, or 0.0.0.0/0.")
+	includeDisabled := fs.Bool("include-disabled", false, "Also import disabled clients.")
+	routes := fs.String("routes", "", "The routes of the interface of a hand-managed server, as printed by ip route show dev wg0.")
+	address := fs.String("address", "", "The tunnel address of a hand-managed server with its prefix, if neither its config nor its routes tell it.")
+	dryRun := fs.Bool("dry-run", false, "Print the generated resources instead of applying them.")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), importUsage)
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected the tool to import from, wg-easy, wg-portal or wg")
+	}
+	if *file == "" || *server == "" || *image == "" {
+		fs.Usage()
+		return fmt.Errorf("-f, --server and --image are required")
+	}
+
+	raw, err := readFileOrStdin(*file)
+	if err != nil {
+		return err
+	}
+	var imported *importedServer
+	var peers []importedPeer
+	var conflicts []string
+	switch positional[0] {
+	case "wg-easy":
+		imported, peers, err = parseWGEasy(raw, *prefix)
+	case "wg-portal":
+		imported, peers, err = parseWGPortal(raw, *device)
+	case "wg":
+		var routeTable []byte
+		if *routes != "" {
+			if routeTable, err = readFileOrStdin(*routes); err != nil {
+				return err
+			}
+		}
+		imported, peers, conflicts, err = parseWGDevice(raw, routeTable, *address)
+	default:
+		return fmt.Errorf("unknown tool %q, expected wg-easy, wg-portal or wg", positional[0])
+	}
+	if err != nil {
+		return fmt.Errorf("%s export: %w", positional[0], err)
+	}
+	if *port != 0 {
+		imported.Port = int32(*port)
+	}
+	if *dns != "" {
+		imported.DNS = splitList(*dns)
+	}
+	if *allowedIPs != "" {
+		imported.AllowedIPs = splitList(*allowedIPs)
+	}
+
+	var c client.Client
+	if !*dryRun || *namespace == "" {
+		var defaultNamespace string
+		if c, defaultNamespace, err = newClient(); err != nil && !*dryRun {
+			return err
+		}
+		if *namespace == "" {
+			*namespace = defaultNamespace
+		}
+	}
+
+	objects, skipped, err := importedObjects(positional[0], *namespace, *server, *image, imported, peers, *includeDisabled)
+	if err != nil {
+		return err
+	}
+	for _, conflict := range conflicts {
+		fmt.Fprintln(os.Stderr, "conflict:", conflict)
+	}
+	if err := applyObjects(c, objects, *dryRun); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "imported %d peers from %s", len(peers)-skipped, positional[0])
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, ", skipped %d disabled or unaddressed peers", skipped)
+	}
+	fmt.Fprintf(os.Stderr, "; the server keys are in Secret %s/%s-keys\n", *namespace, *server)
+	if len(conflicts) > 0 {
+		fmt.Fprintf(os.Stderr, "%d conflicts need manual resolution, repeat the import once they are resolved\n", len(conflicts))
+	}
+	return nil
+}
+
+// importedObjects returns the resources of an imported server and its
+// peers, and the number of peers skipped, disabled or with conflicts
+// leaving them without an address.
+func importedObjects(tool, namespace, name, image string, imported *importedServer, peers []importedPeer, includeDisabled bool) ([]client.Object, int, error) {
+	key, err := escrow.ParseKey(imported.PrivateKey)
+	if err != nil {
+		return nil, 0, fmt.Errorf("server private key: %w", err)
+	}
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{importedFromLabel: tool}}
+	}
+	secret := func(name string, data map[string][]byte) *corev1.Secret {
+		return &corev1.Secret{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+			ObjectMeta: meta(name),
+			Type:       corev1.SecretTypeOpaque,
+			Data:       data,
+		}
+	}
+
+	// The entries are named after the key files of the server image
+	objects := []client.Object{secret(name+"-keys", map[string][]byte{
+		"server_private": []byte(key.String()),
+		"server_public":  []byte(key.PublicKey().String()),
+	})}
+
+	spec := vpnv1alpha1.VPNServerSpec{
+		Image:                 image,
+		Port:                  imported.Port,
+		Address:               imported.Address,
+		DNS:                   strings.Join(imported.DNS, ", "),
+		AllowedIPs:            strings.Join(imported.AllowedIPs, ", "),
+		ClientRoutingProfiles: imported.RoutingProfiles,
+	}
+	if spec.Port == 0 {
+		spec.Port = 51820
+	}
+	if spec.AllowedIPs == "" {
+		spec.AllowedIPs = "0.0.0.0/0"
+	}
+	if imported.MTU != 0 {
+		mtu := imported.MTU
+		spec.MTU = &mtu
+	}
+	objects = append(objects, &vpnv1alpha1.VPNServer{
+		TypeMeta:   metav1.TypeMeta{APIVersion: vpnv1alpha1.GroupVersion.String(), Kind: "VPNServer"},
+		ObjectMeta: meta(name),
+		Spec:       spec,
+	})
+
+	skipped := 0
+	names := map[string]bool{}
+	for i, peer := range peers {
+		if peer.Disabled && !includeDisabled {
+			skipped++
+			continue
+		}
+		if _, err := escrow.ParseKey(peer.PublicKey); err != nil {
+			return nil, 0, fmt.Errorf("peer %q public key: %w", peer.Name, err)
+		}
+		if len(peer.Addresses) == 0 {
+			if len(peer.Conflicts) > 0 {
+				// Reported as a conflict, imported once resolved
+				skipped++
+				continue
+			}
+			return nil, 0, fmt.Errorf("peer %q has no address", peer.Name)
+		}
+
+		peerName := name + "-" + strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(peer.Name), "-"), "-")
+		if peerName == name+"-" {
+			peerName = name + "-peer-" + strconv.Itoa(i+1)
+		}
+		for base, n := peerName, 2; names[peerName]; n++ {
+			peerName = fmt.Sprintf("%s-%d", base, n)
+		}
+		names[peerName] = true
+
+		// The first address is the address of the peer, the others of a
+		// dual-stack peer are routed to it
+		spec := vpnv1alpha1.VPNPeerSpec{
+			ServerRef:  vpnv1alpha1.LocalObjectReference{Name: name},
+			PublicKey:  peer.PublicKey,
+			Address:    hostAddress(peer.Addresses[0]),
+			AllowedIPs: peer.AllowedIPs,
+		}
+		for _, address := range peer.Addresses[1:] {
+			spec.AllowedIPs = append(spec.AllowedIPs, hostAddress(address))
+		}
+		if peer.PresharedKey != "" {
+			if _, err := escrow.ParseKey(peer.PresharedKey); err != nil {
+				return nil, 0, fmt.Errorf("peer %q preshared key: %w", peer.Name, err)
+			}
+			objects = append(objects, secret(peerName+"-psk", map[string][]byte{presharedKeyEntry: []byte(peer.PresharedKey)}))
+			spec.PresharedKeySecretRef = &vpnv1alpha1.SecretKeyReference{Name: peerName + "-psk", Key: presharedKeyEntry}
+		}
+		objectMeta := meta(peerName)
+		objectMeta.Annotations = map[string]string{"vpn.vpn-devops.com/imported-name": peer.Name}
+		if len(peer.Conflicts) > 0 {
+			objectMeta.Annotations[importConflictsAnnotation] = strings.Join(peer.Conflicts, "; ")
+		}
+		objects = append(objects, &vpnv1alpha1.VPNPeer{
+			TypeMeta:   metav1.TypeMeta{APIVersion: vpnv1alpha1.GroupVersion.String(), Kind: "VPNPeer"},
+			ObjectMeta: objectMeta,
+			Spec:       spec,
+		})
+	}
+	return objects, skipped, nil
+}
+
+// hostAddress returns an address with the prefix length of a single host,
+// /32 or /128, unless it has one.
+func hostAddress(address string) string {
+	address = strings.TrimSpace(address)
+	if strings.Contains(address, "/") {
+		return address
+	}
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		return address + "/128"
+	}
+	return address + "/32"
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseWGEasy parses the wg0.json of wg-easy. The tunnel network, port and
+// DNS server of wg-easy are environment settings, not part of the file.
+func parseWGEasy(raw []byte, prefix int) (*importedServer, []importedPeer, error) {
+	var data struct {
+		Server struct {
+			PrivateKey string `json:"privateKey"`
+			Address    string `json:"address"`
+		} `json:"server"`
+		Clients map[string]struct {
+			Name         string `json:"name"`
+			Address      string `json:"address"`
+			PublicKey    string `json:"publicKey"`
+			PreSharedKey string `json:"preSharedKey"`
+			Enabled      *bool  `json:"enabled"`
+		} `json:"clients"`
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, nil, err
+	}
+	if data.Server.PrivateKey == "" || net.ParseIP(data.Server.Address) == nil {
+		return nil, nil, fmt.Errorf("expected a server with a private key and an address")
+	}
+
+	server := &importedServer{
+		PrivateKey: data.Server.PrivateKey,
+		Address:    fmt.Sprintf("%s/%d", data.Server.Address, prefix),
+	}
+	var peers []importedPeer
+	for id, c := range data.Clients {
+		name := c.Name
+		if name == "" {
+			name = id
+		}
+		peers = append(peers, importedPeer{
+			Name:         name,
+			PublicKey:    c.PublicKey,
+			PresharedKey: c.PreSharedKey,
+			Addresses:    splitList(c.Address),
+			Disabled:     c.Enabled != nil && !*c.Enabled,
+		})
+	}
+	sortPeers(peers)
+	return server, peers, nil
+}
+
+// parseWGPortal parses the rows of the devices and peers tables of
+// wg-portal.
+func parseWGPortal(raw []byte, device string) (*importedServer, []importedPeer, error) {
+	var data struct {
+		Devices []struct {
+			DeviceName           string      `json:"device_name"`
+			PrivateKey           string      `json:"private_key"`
+			ListenPort           json.Number `json:"listen_port"`
+			IPsStr               string      `json:"ips_str"`
+			DNSStr               string      `json:"dns_str"`
+			MTU                  json.Number `json:"mtu"`
+			DefaultAllowedIPsStr string      `json:"default_allowed_ips_str"`
+		} `json:"devices"`
+		Peers []struct {
+			DeviceName       string  `json:"device_name"`
+			Identifier       string  `json:"identifier"`
+			Email            string  `json:"email"`
+			PublicKey        string  `json:"public_key"`
+			PresharedKey     string  `json:"preshared_key"`
+			IPsStr           string  `json:"ips_str"`
+			AllowedIPsSrvStr string  `json:"allowed_ips_srv_str"`
+			DeactivatedAt    *string `json:"deactivated_at"`
+		} `json:"peers"`
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, nil, err
+	}
+
+	index := -1
+	var names []string
+	for i, d := range data.Devices {
+		names = append(names, d.DeviceName)
+		if d.DeviceName == device || (device == "" && len(data.Devices) == 1) {
+			index = i
+		}
+	}
+	if index < 0 {
+		if device == "" {
+			return nil, nil, fmt.Errorf("select one of the devices %s with --device", strings.Join(names, ", "))
+		}
+		return nil, nil, fmt.Errorf("no device %q, the devices are %s", device, strings.Join(names, ", "))
+	}
+	d := data.Devices[index]
+	addresses := splitList(d.IPsStr)
+	if d.PrivateKey == "" || len(addresses) == 0 {
+		return nil, nil, fmt.Errorf("device %s has no private key or address", d.DeviceName)
+	}
+	port, _ := d.ListenPort.Int64()
+	mtu, _ := d.MTU.Int64()
+	server := &importedServer{
+		PrivateKey: d.PrivateKey,
+		Address:    addresses[0],
+		Port:       int32(port),
+		DNS:        splitList(d.DNSStr),
+		MTU:        int32(mtu),
+		AllowedIPs: splitList(d.DefaultAllowedIPsStr),
+	}
+
+	var peers []importedPeer
+	for _, p := range data.Peers {
+		if p.DeviceName != d.DeviceName {
+			continue
+		}
+		name := p.Identifier
+		if name == "" {
+			name = p.Email
+		}
+		peers = append(peers, importedPeer{
+			Name:         name,
+			PublicKey:    p.PublicKey,
+			PresharedKey: p.PresharedKey,
+			Addresses:    splitList(p.IPsStr),
+			AllowedIPs:   splitList(p.AllowedIPsSrvStr),
+			Disabled:     p.DeactivatedAt != nil && *p.DeactivatedAt != "",
+		})
+	}
+	sortPeers(peers)
+	return server, peers, nil
+}
+
+// sortPeers sorts peers by name, so that imports are reproducible.
+func sortPeers(peers []importedPeer) {
+	sort.Slice(peers, func(i, j int) bool {
+		if peers[i].Name != peers[j].Name {
+			return peers[i].Name < peers[j].Name
+		}
+		return peers[i].PublicKey < peers[j].PublicKey
+	})
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// siteRoutingProfile is the routing profile reconstructed from the networks
+// routed to peers of a hand-managed server
+const siteRoutingProfile = "sites"
+
+// route is a route of the WireGuard interface, as listed by ip route
+type route struct {
+	network *net.IPNet
+
+	// source is the preferred source of a route of the kernel, set for
+	// the route of the tunnel network
+	source net.IP
+}
+
+// parseWGDevice parses the configuration of a hand-managed device, as
+// printed by wg showconf or written for wg-quick, and the routes of its
+// interface, as printed by ip route show dev <interface>. The address of
+// each peer is its host route in the tunnel network; the other networks
+// of its AllowedIPs are routed to it and make up the sites routing
+// profile. What cannot be reconstructed unambiguously is returned as
+// conflicts, and recorded on the peers they concern.
+func parseWGDevice(raw, routes []byte, address string) (*importedServer, []importedPeer, []string, error) {
+	server := &importedServer{}
+	var peers []importedPeer
+	var peer *importedPeer
+	var comment string
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#"):
+			// Peers are commonly named by a comment above their section,
+			// as "# alice" or "# Name = alice"
+			comment = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+			if key, value, ok := strings.Cut(comment, "="); ok && strings.EqualFold(strings.TrimSpace(key), "name") {
+				comment = strings.TrimSpace(value)
+			}
+			continue
+		case strings.EqualFold(line, "[Interface]"):
+			peer = nil
+		case strings.EqualFold(line, "[Peer]"):
+			peers = append(peers, importedPeer{Name: comment})
+			peer = &peers[len(peers)-1]
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, nil, nil, fmt.Errorf("unexpected line %q", line)
+			}
+			key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+			if peer == nil {
+				switch key {
+				case "privatekey":
+					server.PrivateKey = value
+				case "listenport":
+					port, _ := strconv.Atoi(value)
+					server.Port = int32(port)
+				case "address":
+					if address == "" {
+						address = splitList(value)[0]
+					}
+				case "mtu":
+					mtu, _ := strconv.Atoi(value)
+					server.MTU = int32(mtu)
+				case "dns":
+					server.DNS = splitList(value)
+				}
+				continue
+			}
+			switch key {
+			case "publickey":
+				peer.PublicKey = value
+			case "presharedkey":
+				peer.PresharedKey = value
+			case "allowedips":
+				peer.AllowedIPs = append(peer.AllowedIPs, splitList(value)...)
+			}
+		}
+		comment = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+	if server.PrivateKey == "" {
+		return nil, nil, nil, fmt.Errorf("expected an [Interface] section with a private key, from wg showconf")
+	}
+
+	interfaceRoutes, err := parseRoutes(routes)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("routes: %w", err)
+	}
+	// The route of the tunnel network tells the address of the server
+	// when the config, as printed by wg showconf, lacks it
+	for _, r := range interfaceRoutes {
+		if address == "" && r.source != nil {
+			ones, _ := r.network.Mask.Size()
+			address = fmt.Sprintf("%s/%d", r.source, ones)
+		}
+	}
+	serverIP, tunnel, err := net.ParseCIDR(address)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("the address of the server is unknown, pass the routes of the interface or --address")
+	}
+	server.Address = address
+
+	var conflicts []string
+	sites := map[string]bool{}
+	owners := map[string]string{}
+	var routed []*net.IPNet
+	for i := range peers {
+		p := &peers[i]
+		if p.Name == "" {
+			// Named after their key, so that imports are idempotent
+			sum := sha256.Sum256([]byte(p.PublicKey))
+			p.Name = "peer-" + hex.EncodeToString(sum[:4])
+		}
+		var networks []string
+		for _, cidr := range p.AllowedIPs {
+			ip, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				p.Conflicts = append(p.Conflicts, fmt.Sprintf("allowed IP %s is not a network", cidr))
+				continue
+			}
+			ones, bits := network.Mask.Size()
+			switch {
+			case ones == bits && tunnel.Contains(ip) && ip.Equal(serverIP):
+				p.Conflicts = append(p.Conflicts, fmt.Sprintf("allowed IP %s is the address of the server", cidr))
+			case ones == bits && tunnel.Contains(ip):
+				p.Addresses = append(p.Addresses, network.String())
+			default:
+				networks = append(networks, network.String())
+			}
+			for _, other := range routed {
+				if owners[other.String()] != p.Name && (other.Contains(network.IP) || network.Contains(other.IP)) {
+					p.Conflicts = append(p.Conflicts, fmt.Sprintf("allowed IP %s overlaps %s of peer %s", network, other, owners[other.String()]))
+				}
+			}
+			routed = append(routed, network)
+			owners[network.String()] = p.Name
+		}
+		p.AllowedIPs = networks
+		for _, network := range networks {
+			sites[network] = true
+		}
+		if len(p.Addresses) == 0 {
+			p.Conflicts = append(p.Conflicts, fmt.Sprintf("no host route in the tunnel network %s to take the address from", tunnel))
+		}
+	}
+
+	// Routes of the interface must go to a peer, and the networks routed
+	// to peers need a route for the server to send traffic to them
+	if len(interfaceRoutes) > 0 {
+		routedTo := func(network *net.IPNet) bool {
+			for _, r := range interfaceRoutes {
+				if r.network.Contains(network.IP) {
+					return true
+				}
+			}
+			return false
+		}
+		for _, r := range interfaceRoutes {
+			covered := r.network.String() == tunnel.String()
+			for _, network := range routed {
+				covered = covered || network.Contains(r.network.IP)
+			}
+			if !covered {
+				conflicts = append(conflicts, fmt.Sprintf("route %s of the interface goes to no peer", r.network))
+			}
+		}
+		for _, network := range routed {
+			if !tunnel.Contains(network.IP) && !routedTo(network) {
+				conflicts = append(conflicts, fmt.Sprintf("allowed IP %s of peer %s has no route on the interface", network, owners[network.String()]))
+			}
+		}
+	}
+	for _, p := range peers {
+		for _, conflict := range p.Conflicts {
+			conflicts = append(conflicts, fmt.Sprintf("peer %s: %s", p.Name, conflict))
+		}
+	}
+
+	if len(sites) > 0 {
+		profile := vpnv1alpha1.ClientRoutingProfile{Name: siteRoutingProfile, AllowedIPs: []string{tunnel.String()}}
+		for network := range sites {
+			profile.AllowedIPs = append(profile.AllowedIPs, network)
+		}
+		sort.Strings(profile.AllowedIPs[1:])
+		server.RoutingProfiles = append(server.RoutingProfiles, profile)
+	}
+	sortPeers(peers)
+	return server, peers, conflicts, nil
+}
+
+// parseRoutes parses the output of ip route show dev <interface>, e.g.
+// "10.8.0.0/24 proto kernel scope link src 10.8.0.1".
+func parseRoutes(raw []byte) ([]route, error) {
+	var routes []route
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		// Local and broadcast routes of ip route show table all
+		if fields[0] == "local" || fields[0] == "broadcast" || fields[0] == "multicast" {
+			continue
+		}
+		destination := fields[0]
+		if destination == "default" {
+			destination = "0.0.0.0/0"
+		}
+		if !strings.Contains(destination, "/") {
+			destination = hostAddress(destination)
+		}
+		_, network, err := net.ParseCIDR(destination)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected route %q", scanner.Text())
+		}
+		r := route{network: network}
+		for i := 1; i+1 < len(fields); i++ {
+			if fields[i] == "src" {
+				r.source = net.ParseIP(fields[i+1])
+			}
+		}
+		routes = append(routes, r)
+	}
+	return routes, scanner.Err()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vpn-devops/vpn-operator/pkg/enrollment"
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+)
+
+// acceptInvite accepts the invite of a placeholder peer: it submits the
+// public key of the local private key with the invite token and a proof of
+// possession, and prints the client config of the activated peer. The
+// private key never leaves the machine.
+func acceptInvite(args []string) error {
+	fs := flag.NewFlagSet("accept-invite", flag.ExitOnError)
+	keyFile := fs.String("key-file", "", "File holding the WireGuard private key of the device, e.g. from wg genkey. Required.")
+	tokenFile := fs.String("token-file", "", "File holding the invite token, - for stdin. Required.")
+	url := fs.String("url", "", "The enrollment endpoint of the operator, e.g. https://vpn-operator:8085. Required.")
+	output := fs.String("output", "", "Write the client config to this file instead of stdout.")
+	fs.StringVar(output, "o", "", "Shorthand for --output.")
+	asQR := fs.Bool("qr", false, "Write the client config as a QR code, to scan with the WireGuard mobile apps. A PNG image if --output ends in .png.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow accept-invite --key-file <file> --token-file <file> --url <url> <namespace>/<peer>")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if *keyFile == "" || *tokenFile == "" || *url == "" || len(positional) != 1 || !strings.Contains(positional[0], "/") {
+		fs.Usage()
+		return fmt.Errorf("expected --key-file, --token-file, --url and <namespace>/<peer>")
+	}
+
+	raw, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return err
+	}
+	key, err := escrow.ParseKey(string(raw))
+	if err != nil {
+		return fmt.Errorf("private key: %w", err)
+	}
+	token, err := readFileOrStdin(*tokenFile)
+	if err != nil {
+		return err
+	}
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	base := strings.TrimSuffix(*url, "/") + "/enroll/"
+	challenge, err := fetchChallenge(httpClient, base)
+	if err != nil {
+		return err
+	}
+	proof, err := enrollment.Prove(key, challenge)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]any{
+		"token":     strings.TrimSpace(string(token)),
+		"publicKey": key.PublicKey().String(),
+		"challenge": challenge,
+		"proof":     proof,
+		"client":    clientReport,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(base+"invites/"+positional[0], "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("accepting the invite: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	var result struct {
+		Config string `json:"config"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "accepted the invite of %s with public key %s\n", positional[0], key.PublicKey())
+	// The config is returned without the private key
+	return writeClientConfig(result.Config, key, *output, *asQR)
+}
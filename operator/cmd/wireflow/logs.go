@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// serverContainer is the container of server pods running the agent and
+// the WireGuard device
+const serverContainer = "wireguard"
+
+// Log components of server pods
+const (
+	componentAgent     = "agent"
+	componentWireGuard = "wireguard"
+)
+
+// wireGuardLogPrefixes are the prefixes of the log lines of the WireGuard
+// device rather than the agent: commands run by wg-quick, wireguard-go
+// logs and kernel messages forwarded by the agent of servers with deviceLogging.
+var wireGuardLogPrefixes = []string{"[#] ", "DEBUG: (", "INFO: (", "ERROR: (", "wireguard: "}
+
+// serverLogs streams the logs of the replicas of a server, prefixed with the
+// pod of each line. Public keys, in full or abbreviated as wireguard-go
+// logs them, are replaced with the names of their peers.
+func serverLogs(args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	namespace := fs.String("namespace", "", "The namespace of the server. Defaults to the namespace of the current context.")
+	fs.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	component := fs.String("component", "", "Only show the logs of agent or wireguard. Both are shown if unset.")
+	follow := fs.Bool("follow", false, "Stream the logs as they are written.")
+	fs.BoolVar(follow, "f", false, "Shorthand for --follow.")
+	since := fs.Duration("since", 0, "Only show the logs newer than this duration, e.g. 1h.")
+	tail := fs.Int64("tail", -1, "The number of recent lines to show of each replica, all if negative.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow logs server <name> [flags]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 || positional[0] != "server" {
+		fs.Usage()
+		return fmt.Errorf("expected server <name>")
+	}
+	name := positional[1]
+	if *component != "" && *component != componentAgent && *component != componentWireGuard {
+		return fmt.Errorf("--component must be %s or %s", componentAgent, componentWireGuard)
+	}
+
+	c, defaultNamespace, err := newClient()
+	if err != nil {
+		return err
+	}
+	if *namespace == "" {
+		*namespace = defaultNamespace
+	}
+	config, _, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	server := &vpnv1alpha1.VPNServer{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: *namespace, Name: name}, server); err != nil {
+		return err
+	}
+	pods := &corev1.PodList{}
+	if err := c.List(ctx, pods, client.InNamespace(*namespace), client.MatchingLabels{
+		"app.kubernetes.io/name":     "vpn-server",
+		"app.kubernetes.io/instance": name,
+	}); err != nil {
+		return err
+	}
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("VPNServer %s/%s has no pods", *namespace, name)
+	}
+	enrich, err := peerNameReplacer(ctx, c, server)
+	if err != nil {
+		return err
+	}
+
+	options := &corev1.PodLogOptions{Container: serverContainer, Follow: *follow}
+	if *since > 0 {
+		seconds := int64(since.Seconds())
+		options.SinceSeconds = &seconds
+	}
+	if *tail >= 0 {
+		options.TailLines = tail
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(chan error, len(pods.Items))
+	for _, pod := range pods.Items {
+		wg.Add(1)
+		go func(pod string) {
+			defer wg.Done()
+			stream, err := clientset.CoreV1().Pods(*namespace).GetLogs(pod, options).Stream(ctx)
+			if err != nil {
+				errs <- fmt.Errorf("pod %s: %w", pod, err)
+				return
+			}
+			defer stream.Close()
+			scanner := bufio.NewScanner(stream)
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for scanner.Scan() {
+				line := scanner.Text()
+				if *component != "" && logComponent(line) != *component {
+					continue
+				}
+				mu.Lock()
+				fmt.Printf("[%s] %s\n", pod, enrich.Replace(line))
+				mu.Unlock()
+			}
+			if err := scanner.Err(); err != nil && ctx.Err() == nil {
+				errs <- fmt.Errorf("pod %s: %w", pod, err)
+			}
+		}(pod.Name)
+	}
+	wg.Wait()
+	close(errs)
+	var first error
+	for err := range errs {
+		if first != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			continue
+		}
+		first = err
+	}
+	return first
+}
+
+// logComponent returns the component that wrote a log line of a server
+// container.
+func logComponent(line string) string {
+	for _, prefix := range wireGuardLogPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return componentWireGuard
+		}
+	}
+	return componentAgent
+}
+
+// peerNameReplacer returns a replacer of the public keys of the peers of a
+// server with their names. wireguard-go abbreviates keys to their first
+// and last four significant characters.
+func peerNameReplacer(ctx context.Context, c client.Client, server *vpnv1alpha1.VPNServer) (*strings.Replacer, error) {
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := c.List(ctx, peers, client.InNamespace(server.Namespace)); err != nil {
+		return nil, err
+	}
+	var pairs []string
+	for _, peer := range peers.Items {
+		key := peer.Spec.PublicKey
+		if peer.Spec.ServerRef.Name != server.Name || len(key) != 44 {
+			continue
+		}
+		pairs = append(pairs, key, peer.Name, key[0:4]+"…"+key[39:43], peer.Name)
+	}
+	return strings.NewReplacer(pairs...), nil
+}
@@ -0,0 +1,236 @@
+// Command wireflow provides administrative workflows that run outside of
+// the operator.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+)
+
+// version is the version of wireflow, reported to the enrollment and
+// posture endpoints. It is set at build time with
+// -ldflags "-X main.version=1.4.2".
+var version = "dev"
+
+// clientReport is the implementation and version wireflow reports to the
+// operator, for its minimum client version policies
+var clientReport = map[string]string{"implementation": "wireflow", "version": version}
+
+const usage = `Usage: wireflow <command> [flags]
+
+Installed on the PATH as kubectl-wireflow, the commands are also available
+as kubectl wireflow <command>.
+
+Commands:
+  accept-invite  Accept the invite of a placeholder peer with a private key
+  apply          Expand VPNBundle documents into a server, pool, groups and peers
+  clone          Clone a VPNServer with new addressing
+  config export  Print the client config of a peer
+  decrypt-config Decrypt client configs sealed with a key management service
+  drift          Report the servers whose live devices differ from the desired config
+  enroll         Enroll this device through a VPNSelfEnrollment with an enrollment token
+  fingerprint    Print the fingerprint of this device, for device binding
+  import         Import a server and its peers from wg-easy, wg-portal or a hand-managed device
+  logs           Stream the logs of the replicas of a server with peer names
+  maintenance    List the operations waiting for a maintenance window, or force them now
+  peer add       Create a peer and print its client config
+  peer revoke    Delete or revoke peers, removing them from their server
+  peering        Export the signed peering bundle of a server, or import one from another cluster
+  posture        Re-validate the posture of this device for a bound peer
+  promote        Promote the read-only operator of a standby cluster
+  prove-key      Answer an enrollment challenge with a private key
+  recover-key    Recover an escrowed private key with the recovery key
+  status         List servers, or the peers of a server with their handshakes
+  watch peers    Stream the handshakes, connections and traffic of the peers of a server
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "accept-invite":
+		err = acceptInvite(os.Args[2:])
+	case "apply":
+		err = applyBundle(os.Args[2:])
+	case "clone":
+		err = cloneServer(os.Args[2:])
+	case "config":
+		err = configCommand(os.Args[2:])
+	case "decrypt-config":
+		err = decryptConfig(os.Args[2:])
+	case "drift":
+		err = driftReport(os.Args[2:])
+	case "enroll":
+		err = enrollDevice(os.Args[2:])
+	case "fingerprint":
+		err = deviceFingerprint(os.Args[2:])
+	case "import":
+		err = importServer(os.Args[2:])
+	case "logs":
+		err = serverLogs(os.Args[2:])
+	case "maintenance":
+		err = maintenanceCommand(os.Args[2:])
+	case "peer":
+		err = peerCommand(os.Args[2:])
+	case "peering":
+		err = peeringCommand(os.Args[2:])
+	case "posture":
+		err = revalidatePosture(os.Args[2:])
+	case "promote":
+		err = promoteOperator(os.Args[2:])
+	case "prove-key":
+		err = proveKey(os.Args[2:])
+	case "recover-key":
+		err = recoverKey(os.Args[2:])
+	case "status":
+		err = serverStatus(os.Args[2:])
+	case "watch":
+		err = watchPeers(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// recoverKey opens an escrowed private key with the recovery key. The key
+// is read from the escrow Secret of a Secret, or from a file holding the
+// sealed key. With --verify the key is only checked to be recoverable,
+// which allows proving recovery capability without exposing it.
+func recoverKey(args []string) error {
+	fs := flag.NewFlagSet("recover-key", flag.ExitOnError)
+	recoveryKeyFile := fs.String("escrow-key", "", "File holding the recovery private key. Required.")
+	namespace := fs.String("escrow-namespace", "wireflow-escrow", "The namespace escrowed keys are stored in.")
+	fromFile := fs.String("from-file", "", "Read the sealed key from this file instead of the cluster.")
+	verify := fs.Bool("verify", false, "Only verify the key can be recovered, do not print it.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow recover-key --escrow-key <file> <namespace>/<secret> <key>")
+		fmt.Fprintln(fs.Output(), "       wireflow recover-key --escrow-key <file> --from-file <sealed>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *recoveryKeyFile == "" {
+		fs.Usage()
+		return fmt.Errorf("--escrow-key is required")
+	}
+
+	raw, err := os.ReadFile(*recoveryKeyFile)
+	if err != nil {
+		return err
+	}
+	recovery, err := escrow.ParseKey(string(raw))
+	if err != nil {
+		return fmt.Errorf("recovery key: %w", err)
+	}
+
+	var sealed []byte
+	var expectedPublicKey string
+	switch {
+	case *fromFile != "":
+		if sealed, err = readFileOrStdin(*fromFile); err != nil {
+			return err
+		}
+	case fs.NArg() == 2:
+		if sealed, expectedPublicKey, err = fetchSealed(*namespace, fs.Arg(0), fs.Arg(1), recovery); err != nil {
+			return err
+		}
+	default:
+		fs.Usage()
+		return fmt.Errorf("expected <namespace>/<secret> <key> or --from-file")
+	}
+
+	plaintext, err := escrow.Open(recovery, sealed)
+	if err != nil {
+		return err
+	}
+	if expectedPublicKey != "" {
+		key, err := escrow.ParseKey(string(plaintext))
+		if err != nil {
+			return fmt.Errorf("recovered key: %w", err)
+		}
+		if key.PublicKey().String() != expectedPublicKey {
+			return fmt.Errorf("recovered key does not match the escrowed public key %s", expectedPublicKey)
+		}
+	}
+
+	if *verify {
+		fmt.Fprintln(os.Stderr, "key is recoverable")
+		return nil
+	}
+	_, err = os.Stdout.Write(plaintext)
+	return err
+}
+
+// parseInterspersed parses flags that may follow positional arguments, as
+// in kubectl, and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func readFileOrStdin(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// fetchSealed reads a sealed key and the public key it is verified against
+// from the escrow Secret of source.
+func fetchSealed(namespace, source, key string, recovery *escrow.Key) ([]byte, string, error) {
+	sourceNamespace, sourceName, ok := strings.Cut(source, "/")
+	if !ok {
+		return nil, "", fmt.Errorf("expected <namespace>/<secret>, got %q", source)
+	}
+
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.Background(), sourceNamespace+"."+sourceName, metav1.GetOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+
+	if fingerprint := secret.Annotations[escrow.RecipientAnnotation]; fingerprint != recovery.PublicKey().Fingerprint() {
+		return nil, "", fmt.Errorf("%s/%s is sealed to recovery key %s, not %s", namespace, secret.Name, fingerprint, recovery.PublicKey().Fingerprint())
+	}
+	sealed, ok := secret.Data[key]
+	if !ok {
+		return nil, "", fmt.Errorf("%s/%s has no escrowed key %q", namespace, secret.Name, key)
+	}
+	return sealed, string(secret.Data[key+escrow.PublicKeySuffix]), nil
+}
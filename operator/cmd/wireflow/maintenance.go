@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// maintenanceCommand lists the disruptive operations of a server waiting
+// for its next maintenance window, or with --force-now lets them run
+// outside of the windows for a while, for emergencies such as the rotation
+// of a leaked key.
+func maintenanceCommand(args []string) error {
+	fs := flag.NewFlagSet("maintenance", flag.ExitOnError)
+	namespace := fs.String("namespace", "", "The namespace of the server. Defaults to the namespace of the current context.")
+	fs.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	forceNow := fs.Bool("force-now", false, "Run the pending operations now, outside of the maintenance windows.")
+	duration := fs.Duration("for", time.Hour, "How long operations may run outside of the windows with --force-now.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow maintenance <server> [--force-now [--for <duration>]] [flags]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected a server")
+	}
+	if *duration <= 0 {
+		return fmt.Errorf("--for must be positive")
+	}
+
+	c, defaultNamespace, err := newClient()
+	if err != nil {
+		return err
+	}
+	if *namespace == "" {
+		*namespace = defaultNamespace
+	}
+	ctx := context.Background()
+	server := &vpnv1alpha1.VPNServer{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: *namespace, Name: positional[0]}, server); err != nil {
+		return err
+	}
+
+	if *forceNow {
+		until := time.Now().Add(*duration).UTC()
+		original := server.DeepCopy()
+		if server.Annotations == nil {
+			server.Annotations = map[string]string{}
+		}
+		server.Annotations[vpnv1alpha1.MaintenanceOverrideAnnotation] = until.Format(time.RFC3339)
+		if err := c.Patch(ctx, server, client.MergeFrom(original)); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "disruptive operations on %s may run until %s\n", server.Name, until.Format(time.RFC3339))
+		return nil
+	}
+
+	now := time.Now()
+	allowed, window := server.MaintenanceAllowed(now)
+	switch {
+	case len(server.Spec.MaintenanceWindows) == 0:
+		fmt.Fprintf(os.Stderr, "%s has no maintenance windows, disruptive operations run right away\n", server.Name)
+	case allowed:
+		fmt.Fprintf(os.Stderr, "%s is within a maintenance window\n", server.Name)
+	case !window.IsZero():
+		fmt.Fprintf(os.Stderr, "the next maintenance window of %s opens at %s\n", server.Name, window.UTC().Format(time.RFC3339))
+	default:
+		fmt.Fprintf(os.Stderr, "no maintenance window of %s ever opens\n", server.Name)
+	}
+	if len(server.Status.PendingOperations) == 0 {
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tSINCE\tNOT BEFORE\tDESCRIPTION")
+	for _, operation := range server.Status.PendingOperations {
+		notBefore := "-"
+		if operation.NotBefore != nil {
+			notBefore = operation.NotBefore.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", operation.Type, operation.Since.UTC().Format(time.RFC3339), notBefore, operation.Description)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/envelope"
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+	"github.com/vpn-devops/vpn-operator/pkg/qrcode"
+)
+
+// clientConfigKey is the key of the client config in the client config
+// Secrets of peers
+const clientConfigKey = "wg0.conf"
+
+// peerCommand runs the peer subcommands.
+func peerCommand(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add":
+			return addPeer(args[1:])
+		case "revoke":
+			return revokePeers(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Usage: wireflow peer add|revoke [flags]")
+	os.Exit(2)
+	return nil
+}
+
+// configCommand runs the config subcommands.
+func configCommand(args []string) error {
+	if len(args) > 0 && args[0] == "export" {
+		return exportConfig(args[1:])
+	}
+	fmt.Fprintln(os.Stderr, "Usage: wireflow config export [flags]")
+	os.Exit(2)
+	return nil
+}
+
+// addPeer creates a peer and prints its client config once the operator
+// rendered it. Without a key, a key pair is generated on this machine and
+// the private key is only written into the printed config.
+func addPeer(args []string) error {
+	fs := flag.NewFlagSet("peer add", flag.ExitOnError)
+	namespace := fs.String("namespace", "", "The namespace of the peer. Defaults to the namespace of the current context.")
+	fs.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	server := fs.String("server", "", "The VPNServer the peer connects to. Required.")
+	publicKey := fs.String("public-key", "", "The public key of the peer, whose private key stays on the device.")
+	keyFile := fs.String("key-file", "", "File holding the private key of the peer, e.g. from wg genkey.")
+	address := fs.String("address", "", "The tunnel address of the peer. Allocated by the operator if empty.")
+	group := fs.String("group", "", "The peer group of the peer.")
+	dns := fs.String("dns", "", "The DNS server pushed to the client of the peer.")
+	output := fs.String("output", "", "Write the client config to this file instead of stdout.")
+	fs.StringVar(output, "o", "", "Shorthand for --output.")
+	asQR := fs.Bool("qr", false, "Write the client config as a QR code, to scan with the WireGuard mobile apps. A PNG image if --output ends in .png.")
+	timeout := fs.Duration("timeout", 2*time.Minute, "How long to wait for the client config.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow peer add <name> --server <server> [--public-key <key> | --key-file <file>] [flags]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || *server == "" {
+		fs.Usage()
+		return fmt.Errorf("expected <name> and --server")
+	}
+	if *publicKey != "" && *keyFile != "" {
+		return fmt.Errorf("--public-key and --key-file are mutually exclusive")
+	}
+	if *asQR && *publicKey != "" {
+		return fmt.Errorf("--qr needs the private key of the peer, use --key-file instead of --public-key")
+	}
+
+	var privateKey *escrow.Key
+	switch {
+	case *keyFile != "":
+		raw, err := os.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		if privateKey, err = escrow.ParseKey(string(raw)); err != nil {
+			return fmt.Errorf("private key: %w", err)
+		}
+	case *publicKey == "":
+		if privateKey, err = escrow.GenerateKey(); err != nil {
+			return err
+		}
+	}
+	if privateKey != nil {
+		*publicKey = privateKey.PublicKey().String()
+	}
+
+	c, defaultNamespace, err := newClient()
+	if err != nil {
+		return err
+	}
+	if *namespace == "" {
+		*namespace = defaultNamespace
+	}
+	peer := &vpnv1alpha1.VPNPeer{
+		ObjectMeta: metav1.ObjectMeta{Name: positional[0], Namespace: *namespace},
+		Spec: vpnv1alpha1.VPNPeerSpec{
+			ServerRef: vpnv1alpha1.LocalObjectReference{Name: *server},
+			PublicKey: *publicKey,
+			Address:   *address,
+			Group:     *group,
+			DNS:       *dns,
+		},
+	}
+	ctx := context.Background()
+	if err := c.Create(ctx, peer); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "created peer %s/%s with public key %s\n", peer.Namespace, peer.Name, *publicKey)
+
+	config, err := waitForClientConfig(ctx, c, peer, *timeout)
+	if err != nil {
+		if privateKey != nil {
+			// The generated key is lost otherwise
+			fmt.Fprintf(os.Stderr, "the private key of the peer is %s\n", privateKey)
+		}
+		return err
+	}
+	return writeClientConfig(config, privateKey, *output, *asQR)
+}
+
+// waitForClientConfig waits for the operator to render the client config of
+// a peer into its client config Secret, and returns it.
+func waitForClientConfig(ctx context.Context, c client.Client, peer *vpnv1alpha1.VPNPeer, timeout time.Duration) (string, error) {
+	var config string
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		if err := c.Get(ctx, client.ObjectKeyFromObject(peer), peer); err != nil {
+			return false, err
+		}
+		if peer.Status.ClientConfigSecretName == "" {
+			return false, nil
+		}
+		var err error
+		config, err = fetchClientConfig(ctx, c, peer)
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if wait.Interrupted(err) {
+		return "", fmt.Errorf("the client config of %s/%s was not rendered within %s, the peer is %s", peer.Namespace, peer.Name, timeout, phaseOf(peer))
+	}
+	return config, err
+}
+
+// phaseOf returns the phase of a peer for messages.
+func phaseOf(peer *vpnv1alpha1.VPNPeer) string {
+	if peer.Status.Phase == "" {
+		return "not reconciled yet"
+	}
+	return peer.Status.Phase
+}
+
+// fetchClientConfig returns the client config of a peer from its client
+// config Secret.
+func fetchClientConfig(ctx context.Context, c client.Client, peer *vpnv1alpha1.VPNPeer) (string, error) {
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: peer.Namespace, Name: peer.Status.ClientConfigSecretName}
+	if err := c.Get(ctx, key, secret); err != nil {
+		return "", err
+	}
+	if config, ok := secret.Data[clientConfigKey]; ok {
+		return string(config), nil
+	}
+	if _, ok := secret.Data[clientConfigKey+envelope.Suffix]; ok {
+		return "", fmt.Errorf("the client config is sealed, open it with wireflow decrypt-config -n %s %s", secret.Namespace, secret.Name)
+	}
+	return "", fmt.Errorf("%s/%s has no client config", secret.Namespace, secret.Name)
+}
+
+// writeClientConfig writes a client config, with the private key filled in
+// if known, to path or stdout. Files are only readable by the user. With
+// asQR, the config is written as a QR code for the WireGuard mobile apps:
+// a PNG image if path ends in .png, text for terminals otherwise.
+func writeClientConfig(config string, privateKey *escrow.Key, path string, asQR bool) error {
+	if privateKey != nil {
+		config = strings.Replace(config, "PrivateKey = \n", "PrivateKey = "+privateKey.String()+"\n", 1)
+	}
+	out := []byte(config)
+	if asQR {
+		if privateKey == nil {
+			return fmt.Errorf("a QR code needs the private key of the peer, which the operator does not have; pass --key-file")
+		}
+		var err error
+		if strings.HasSuffix(path, ".png") {
+			out, err = qrcode.PNG(config)
+		} else {
+			var text string
+			text, err = qrcode.Text(config)
+			out = []byte(text)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if path == "" || path == "-" {
+		_, err := os.Stdout.Write(out)
+		return err
+	}
+	return os.WriteFile(path, out, 0o600)
+}
+
+// revokePeers deletes peers, which removes them from their server and
+// releases their addresses. With --keep the peers are marked revoked
+// instead, which removes them from their server all the same but keeps
+// them for audit.
+func revokePeers(args []string) error {
+	fs := flag.NewFlagSet("peer revoke", flag.ExitOnError)
+	namespace := fs.String("namespace", "", "The namespace of the peers. Defaults to the namespace of the current context.")
+	fs.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	keep := fs.Bool("keep", false, "Keep the peers for audit, marked revoked, instead of deleting them.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow peer revoke <name>... [flags]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		return fmt.Errorf("expected at least one peer")
+	}
+
+	c, defaultNamespace, err := newClient()
+	if err != nil {
+		return err
+	}
+	if *namespace == "" {
+		*namespace = defaultNamespace
+	}
+	ctx := context.Background()
+	for _, name := range positional {
+		peer := &vpnv1alpha1.VPNPeer{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: *namespace}}
+		if *keep {
+			if err := c.Get(ctx, client.ObjectKeyFromObject(peer), peer); err != nil {
+				return err
+			}
+			original := peer.DeepCopy()
+			peer.Spec.Revoked = true
+			if err := c.Patch(ctx, peer, client.MergeFrom(original)); err != nil {
+				return err
+			}
+		} else if err := c.Delete(ctx, peer); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "revoked peer %s/%s\n", *namespace, name)
+	}
+	return nil
+}
+
+// exportConfig prints the client config of a peer, with the private key
+// filled in from a key file if given.
+func exportConfig(args []string) error {
+	fs := flag.NewFlagSet("config export", flag.ExitOnError)
+	namespace := fs.String("namespace", "", "The namespace of the peer. Defaults to the namespace of the current context.")
+	fs.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	keyFile := fs.String("key-file", "", "File holding the private key of the peer, to fill into the config.")
+	output := fs.String("output", "", "Write the client config to this file instead of stdout.")
+	fs.StringVar(output, "o", "", "Shorthand for --output.")
+	asQR := fs.Bool("qr", false, "Write the client config as a QR code, to scan with the WireGuard mobile apps. A PNG image if --output ends in .png.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow config export <peer> [flags]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected <peer>")
+	}
+
+	var privateKey *escrow.Key
+	if *keyFile != "" {
+		raw, err := os.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		if privateKey, err = escrow.ParseKey(string(raw)); err != nil {
+			return fmt.Errorf("private key: %w", err)
+		}
+	}
+
+	c, defaultNamespace, err := newClient()
+	if err != nil {
+		return err
+	}
+	if *namespace == "" {
+		*namespace = defaultNamespace
+	}
+	ctx := context.Background()
+	peer := &vpnv1alpha1.VPNPeer{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: *namespace, Name: positional[0]}, peer); err != nil {
+		return err
+	}
+	if peer.Status.ClientConfigSecretName == "" {
+		return fmt.Errorf("%s/%s has no client config, the peer is %s", peer.Namespace, peer.Name, phaseOf(peer))
+	}
+	if privateKey != nil && privateKey.PublicKey().String() != peer.Spec.PublicKey {
+		return fmt.Errorf("the private key does not match the public key %s of %s/%s", peer.Spec.PublicKey, peer.Namespace, peer.Name)
+	}
+	config, err := fetchClientConfig(ctx, c, peer)
+	if err != nil {
+		return err
+	}
+	return writeClientConfig(config, privateKey, *output, *asQR)
+}
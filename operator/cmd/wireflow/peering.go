@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/peering"
+)
+
+// peeringCommand runs the peering subcommands.
+func peeringCommand(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "export":
+			return exportPeering(args[1:])
+		case "import":
+			return importPeering(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Usage: wireflow peering export|import [flags]")
+	os.Exit(2)
+	return nil
+}
+
+// exportPeering prints the peering bundle of a server with
+// spec.peeringExport, to carry over to the importing cluster along with
+// the signing key it reports.
+func exportPeering(args []string) error {
+	fs := flag.NewFlagSet("peering export", flag.ExitOnError)
+	namespace := fs.String("namespace", "", "The namespace of the server. Defaults to the namespace of the current context.")
+	fs.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow peering export <server> [flags]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected a server")
+	}
+
+	c, defaultNamespace, err := newClient()
+	if err != nil {
+		return err
+	}
+	if *namespace == "" {
+		*namespace = defaultNamespace
+	}
+	ctx := context.Background()
+	server := &vpnv1alpha1.VPNServer{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: *namespace, Name: positional[0]}, server); err != nil {
+		return err
+	}
+	status := server.Status.PeeringExport
+	if server.Spec.PeeringExport == nil {
+		return fmt.Errorf("VPNServer %s has no spec.peeringExport", server.Name)
+	}
+	if status == nil {
+		return fmt.Errorf("the peering bundle of VPNServer %s is not signed yet, the server has no public key", server.Name)
+	}
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: server.Namespace, Name: status.ConfigMap}, cm); err != nil {
+		return err
+	}
+	fmt.Println(cm.Data["bundle"])
+	fmt.Fprintf(os.Stderr, "signed with key %s, valid until %s\n", status.SigningKey, status.ExpiresAt.UTC().Format(time.RFC3339))
+	return nil
+}
+
+// importPeering creates or updates a VPNPeering importing a bundle read from
+// a file, or stdin with -, after checking it against the trusted keys.
+func importPeering(args []string) error {
+	fs := flag.NewFlagSet("peering import", flag.ExitOnError)
+	namespace := fs.String("namespace", "", "The namespace of the peering. Defaults to the namespace of the current context.")
+	fs.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	server := fs.String("server", "", "The local VPNServer the tunnel is established from. Required.")
+	name := fs.String("name", "", "The name of the VPNPeering. Defaults to the name of the exported server.")
+	trust := fs.String("trust", "", "The comma separated signing keys whose bundles are trusted. Required.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow peering import <bundle-file|-> --server <server> --trust <signing-key> [flags]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || *server == "" || *trust == "" {
+		fs.Usage()
+		return fmt.Errorf("expected a bundle file, --server and --trust")
+	}
+
+	var raw []byte
+	if positional[0] == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(positional[0])
+	}
+	if err != nil {
+		return err
+	}
+	encoded := strings.TrimSpace(string(raw))
+	var keys []string
+	var trusted []ed25519.PublicKey
+	for _, key := range strings.Split(*trust, ",") {
+		public, err := peering.ParsePublicKey(key)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, peering.EncodePublicKey(public))
+		trusted = append(trusted, public)
+	}
+	// Verified here too, so that a wrong bundle or key fails right away
+	bundle, _, err := peering.Verify(encoded, trusted, time.Now())
+	if err != nil {
+		return err
+	}
+	if *name == "" {
+		*name = bundle.Server
+	}
+
+	c, defaultNamespace, err := newClient()
+	if err != nil {
+		return err
+	}
+	if *namespace == "" {
+		*namespace = defaultNamespace
+	}
+	p := &vpnv1alpha1.VPNPeering{ObjectMeta: metav1.ObjectMeta{Namespace: *namespace, Name: *name}}
+	result, err := controllerutil.CreateOrUpdate(context.Background(), c, p, func() error {
+		p.Spec.ServerRef = vpnv1alpha1.LocalObjectReference{Name: *server}
+		p.Spec.Bundle = encoded
+		p.Spec.BundleSecretRef = nil
+		p.Spec.TrustedSigningKeys = keys
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "VPNPeering %s %s: VPNServer %s peers with %s/%s of cluster %q until %s\n",
+		p.Name, result, *server, bundle.Namespace, bundle.Server, bundle.Cluster, bundle.ExpiresAt.Format(time.RFC3339))
+	return nil
+}
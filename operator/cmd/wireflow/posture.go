@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vpn-devops/vpn-operator/pkg/enrollment"
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+)
+
+// deviceFingerprint prints the fingerprint of the local machine, to be
+// supplied at enrollment as the device binding of the peer.
+func deviceFingerprint(args []string) error {
+	fs := flag.NewFlagSet("fingerprint", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow fingerprint")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	identifiers, err := enrollment.MachineIdentifiers()
+	if err != nil {
+		return err
+	}
+	fmt.Println(enrollment.Fingerprint(identifiers...))
+	return nil
+}
+
+// revalidatePosture re-validates the posture of the local machine for a
+// peer: it proves possession of the peer's private key on this device to
+// the operator. The private key never leaves the machine.
+func revalidatePosture(args []string) error {
+	fs := flag.NewFlagSet("posture", flag.ExitOnError)
+	keyFile := fs.String("key-file", "", "File holding the WireGuard private key of the peer, - for stdin. Required.")
+	url := fs.String("url", "", "The posture endpoint of the operator, e.g. https://vpn-operator:8084. Required.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow posture --key-file <file> --url <url> <namespace>/<peer>")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if *keyFile == "" || *url == "" || len(positional) != 1 || !strings.Contains(positional[0], "/") {
+		fs.Usage()
+		return fmt.Errorf("expected --key-file, --url and <namespace>/<peer>")
+	}
+
+	raw, err := readFileOrStdin(*keyFile)
+	if err != nil {
+		return err
+	}
+	key, err := escrow.ParseKey(string(raw))
+	if err != nil {
+		return fmt.Errorf("private key: %w", err)
+	}
+	identifiers, err := enrollment.MachineIdentifiers()
+	if err != nil {
+		return err
+	}
+	fingerprint := enrollment.Fingerprint(identifiers...)
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	base := strings.TrimSuffix(*url, "/") + "/posture/"
+	resp, err := httpClient.Get(base + "challenge")
+	if err != nil {
+		return err
+	}
+	challenge, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching a challenge: %s", resp.Status)
+	}
+
+	proof, err := enrollment.ProveDevice(key, strings.TrimSpace(string(challenge)), fingerprint)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]any{
+		"challenge":   strings.TrimSpace(string(challenge)),
+		"fingerprint": fingerprint,
+		"proof":       proof,
+		"client":      clientReport,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err = httpClient.Post(base+positional[0], "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("re-validating: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	var result struct {
+		Bound bool `json:"bound"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if !result.Bound {
+		fmt.Fprintf(os.Stderr, "re-validated %s, but this device is not the one the peer is bound to\n", positional[0])
+		return fmt.Errorf("device fingerprint %s does not match the binding", fingerprint)
+	}
+	fmt.Fprintf(os.Stderr, "re-validated %s with device fingerprint %s\n", positional[0], fingerprint)
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// promoteOperator promotes the read-only operators of a standby cluster to
+// the active mode through their operator mode ConfigMap. They restart and
+// take over the devices and owned resources, so the primary must be down or
+// demoted first.
+func promoteOperator(args []string) error {
+	fs := flag.NewFlagSet("promote", flag.ExitOnError)
+	namespace := fs.String("operator-namespace", "", "The namespace the operator runs in. Required.")
+	configMap := fs.String("configmap", "wireflow-operator-mode", "The operator mode ConfigMap, as set with --mode-configmap.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow promote --operator-namespace <namespace> [flags]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 || *namespace == "" {
+		fs.Usage()
+		return fmt.Errorf("expected --operator-namespace")
+	}
+
+	c, _, err := newClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	cm := &corev1.ConfigMap{}
+	err = c.Get(ctx, client.ObjectKey{Namespace: *namespace, Name: *configMap}, cm)
+	switch {
+	case apierrors.IsNotFound(err):
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: *namespace, Name: *configMap},
+			Data:       map[string]string{"mode": "active"},
+		}
+		err = c.Create(ctx, cm)
+	case err == nil:
+		if cm.Data["mode"] == "active" {
+			fmt.Fprintf(os.Stderr, "the operator in %s is already promoted\n", *namespace)
+			return nil
+		}
+		original := cm.DeepCopy()
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data["mode"] = "active"
+		err = c.Patch(ctx, cm, client.MergeFrom(original))
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "promoted the operator in %s, its replicas restart in the active mode\n", *namespace)
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/vpn-devops/vpn-operator/pkg/enrollment"
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+)
+
+// proveKey answers an enrollment challenge, proving possession of the
+// private key whose public key is enrolled. The private key never leaves the
+// machine: only the proof is printed.
+func proveKey(args []string) error {
+	fs := flag.NewFlagSet("prove-key", flag.ExitOnError)
+	keyFile := fs.String("key-file", "", "File holding the WireGuard private key, - for stdin. Required.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow prove-key --key-file <file> <challenge>")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if *keyFile == "" || len(positional) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected --key-file and a challenge")
+	}
+
+	raw, err := readFileOrStdin(*keyFile)
+	if err != nil {
+		return err
+	}
+	key, err := escrow.ParseKey(string(raw))
+	if err != nil {
+		return fmt.Errorf("private key: %w", err)
+	}
+	proof, err := enrollment.Prove(key, positional[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "proof for public key %s\n", key.PublicKey())
+	fmt.Println(proof)
+	return nil
+}
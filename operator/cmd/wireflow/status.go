@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// serverStatus lists servers with their replicas, endpoint and peers. With
+// a single server, its peers are listed with their latest handshakes.
+func serverStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	namespace := fs.String("namespace", "", "The namespace of the servers. Defaults to the namespace of the current context.")
+	fs.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	allNamespaces := fs.Bool("all-namespaces", false, "List the servers of every namespace.")
+	fs.BoolVar(allNamespaces, "A", false, "Shorthand for --all-namespaces.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow status [<server>] [flags]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected at most one server")
+	}
+
+	c, defaultNamespace, err := newClient()
+	if err != nil {
+		return err
+	}
+	if *namespace == "" {
+		*namespace = defaultNamespace
+	}
+	ctx := context.Background()
+	if len(positional) == 1 {
+		return peerStatus(ctx, c, *namespace, positional[0])
+	}
+
+	var opts []client.ListOption
+	if !*allNamespaces {
+		opts = append(opts, client.InNamespace(*namespace))
+	}
+	servers := &vpnv1alpha1.VPNServerList{}
+	if err := c.List(ctx, servers, opts...); err != nil {
+		return err
+	}
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := c.List(ctx, peers, opts...); err != nil {
+		return err
+	}
+	type counts struct{ total, active int }
+	peerCounts := map[client.ObjectKey]*counts{}
+	for _, peer := range peers.Items {
+		key := client.ObjectKey{Namespace: peer.Namespace, Name: peer.Spec.ServerRef.Name}
+		if peerCounts[key] == nil {
+			peerCounts[key] = &counts{}
+		}
+		peerCounts[key].total++
+		if peer.Status.Phase == vpnv1alpha1.PeerPhaseActive {
+			peerCounts[key].active++
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tSERVER\tREADY\tENDPOINT\tPEERS\tACTIVE\tCONNECTED")
+	for _, server := range servers.Items {
+		n := peerCounts[client.ObjectKeyFromObject(&server)]
+		if n == nil {
+			n = &counts{}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d/%d\t%s\t%d\t%d\t%d\n", server.Namespace, server.Name,
+			server.Status.ReadyReplicas, server.Status.Replicas, server.Status.Endpoint, n.total, n.active, server.Status.ConnectedClients)
+	}
+	return w.Flush()
+}
+
+// peerStatus lists the peers of a server with their latest handshakes.
+func peerStatus(ctx context.Context, c client.Client, namespace, name string) error {
+	server := &vpnv1alpha1.VPNServer{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, server); err != nil {
+		return err
+	}
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := c.List(ctx, peers, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	observed := map[string]vpnv1alpha1.PeerStatus{}
+	for _, peer := range server.Status.Peers {
+		observed[peer.Name] = peer
+	}
+
+	fmt.Fprintf(os.Stderr, "%s/%s: %d/%d replicas ready, endpoint %s\n", namespace, name,
+		server.Status.ReadyReplicas, server.Status.Replicas, server.Status.Endpoint)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PEER\tADDRESS\tGROUP\tPHASE\tENDPOINT\tHANDSHAKE")
+	now := time.Now()
+	for _, peer := range peers.Items {
+		if peer.Spec.ServerRef.Name != name {
+			continue
+		}
+		handshake := "never"
+		status := observed[peer.Name]
+		if status.LatestHandshake != nil {
+			handshake = duration.HumanDuration(now.Sub(status.LatestHandshake.Time)) + " ago"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", peer.Name, peer.Spec.Address, peer.Spec.Group, peer.Status.Phase, status.Endpoint, handshake)
+	}
+	return w.Flush()
+}
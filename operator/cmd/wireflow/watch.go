@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// peerEvent is an event of the peer event stream of the operator
+type peerEvent struct {
+	Time          time.Time `json:"time"`
+	Type          string    `json:"type"`
+	Pod           string    `json:"pod"`
+	Peer          string    `json:"peer"`
+	PublicKey     string    `json:"publicKey"`
+	Endpoint      string    `json:"endpoint"`
+	ReceiveBytes  int64     `json:"receiveBytes"`
+	TransmitBytes int64     `json:"transmitBytes"`
+}
+
+// watchPeers streams the handshakes, connections, disconnections and
+// traffic of the peers of a server from the peer event stream of the
+// operator, starting with the peers connected now.
+func watchPeers(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	namespace := fs.String("namespace", "", "The namespace of the server. Defaults to the namespace of the current context.")
+	fs.StringVar(namespace, "n", "", "Shorthand for --namespace.")
+	server := fs.String("server", "", "The server to watch the peers of. Required.")
+	url := fs.String("url", "", "The peer event stream of the operator, e.g. https://vpn-operator:8086. Required.")
+	tokenFile := fs.String("token-file", "", "A file holding the bearer token to authenticate with. Defaults to the token of the current context.")
+	output := fs.String("output", "", "Print the events as json lines rather than a table.")
+	fs.StringVar(output, "o", "", "Shorthand for --output.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wireflow watch peers --server <name> --url <url> [flags]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || positional[0] != "peers" || *server == "" || *url == "" {
+		fs.Usage()
+		return fmt.Errorf("expected peers, --server and --url")
+	}
+	if *output != "" && *output != "json" {
+		return fmt.Errorf("--output must be json")
+	}
+
+	config, defaultNamespace, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if *namespace == "" {
+		*namespace = defaultNamespace
+	}
+	token := config.BearerToken
+	if *tokenFile == "" {
+		*tokenFile = config.BearerTokenFile
+	}
+	if *tokenFile != "" {
+		raw, err := os.ReadFile(*tokenFile)
+		if err != nil {
+			return err
+		}
+		token = strings.TrimSpace(string(raw))
+	}
+	if token == "" {
+		return fmt.Errorf("the current context has no bearer token, pass --token-file")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(*url, "/")+"/peer-events/"+*namespace+"/"+*server, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("peer event stream returned %s", resp.Status)
+	}
+
+	if *output == "" {
+		fmt.Printf("%-20s  %-10s  %-24s  %-24s  %-22s  %s\n", "TIME", "EVENT", "PEER", "POD", "ENDPOINT", "RX/TX")
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if *output == "json" {
+			fmt.Println(data)
+			continue
+		}
+		event := peerEvent{}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			continue
+		}
+		peer := event.Peer
+		if peer == "" {
+			peer = event.PublicKey
+		}
+		fmt.Printf("%-20s  %-10s  %-24s  %-24s  %-22s  %s/%s\n", event.Time.Local().Format(time.DateTime), event.Type,
+			peer, event.Pod, event.Endpoint, formatBytes(event.ReceiveBytes), formatBytes(event.TransmitBytes))
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("peer event stream closed")
+}
+
+// formatBytes formats a byte count with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"sort"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// policyAllowedIPs returns the networks the access policies grant to a peer
+// and the names of the policies that granted them. A peer is governed by
+// the policies when it has an identity and at least one policy applies to
+// its server; a governed peer matching no rule is granted no networks.
+// Peers that are not governed use their routing profile.
+func policyAllowedIPs(policies []vpnv1alpha1.VPNAccessPolicy, peer *vpnv1alpha1.VPNPeer) (allowedIPs, applied []string, governed bool) {
+	if peer.Spec.Identity == nil {
+		return nil, nil, false
+	}
+
+	networks := map[string]bool{}
+	for _, policy := range policies {
+		if !policy.AppliesTo(peer.Spec.ServerRef.Name) || !policy.DeletionTimestamp.IsZero() {
+			continue
+		}
+		governed = true
+
+		matched := false
+		for _, rule := range policy.Spec.Rules {
+			if !ruleMatches(rule, peer.Spec.Identity) {
+				continue
+			}
+			matched = true
+			for _, network := range rule.AllowedIPs {
+				networks[network] = true
+			}
+		}
+		if matched {
+			applied = append(applied, policy.Name)
+		}
+	}
+
+	for network := range networks {
+		allowedIPs = append(allowedIPs, network)
+	}
+	sort.Strings(allowedIPs)
+	sort.Strings(applied)
+	return allowedIPs, applied, governed
+}
+
+// ruleMatches returns whether the identity has one of the claim values of
+// the rule.
+func ruleMatches(rule vpnv1alpha1.AccessRule, identity *vpnv1alpha1.PeerIdentity) bool {
+	claim := rule.Claim
+	if claim == "" {
+		claim = vpnv1alpha1.DefaultAccessRuleClaim
+	}
+	for _, have := range identity.Claims[claim] {
+		for _, want := range rule.Values {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// Addresses are allocated by several controllers and servers: IPAM, the
+// invite and idle peer controllers, peer moves, pool migrations, chat
+// commands and self enrollments. Each works from its own, possibly stale
+// view of the peers, so an address is only handed out once it is claimed
+// in the address claims ConfigMap of the namespace. The ConfigMap is
+// written with optimistic locking: of two allocators claiming from the same
+// version, the second one fails, re-reads the claims and picks another
+// address.
+
+// addressClaimsName is the ConfigMap of each namespace holding the claims
+// of the addresses of its peers, keyed by address
+const addressClaimsName = "wireflow-address-claims"
+
+// addressClaimGracePeriod is how long the claim of an address is kept
+// before its peer holds it, for allocations that claim an address before
+// they create or update the peer
+const addressClaimGracePeriod = time.Minute
+
+// addressClaim is the claim of an address by a peer
+type addressClaim struct {
+	// Peer is the name of the peer the address is claimed for
+	Peer string `json:"peer"`
+
+	// Time is when the address was claimed
+	Time metav1.Time `json:"time"`
+}
+
+// addressClaimKey returns the key of the claim of an address. ConfigMap
+// keys cannot hold the colons of IPv6 addresses.
+func addressClaimKey(ip net.IP) string {
+	return strings.ReplaceAll(ip.String(), ":", "_")
+}
+
+// updateAddressClaims calls update with the claims of a namespace and
+// writes them back if it changed them, re-reading and calling it again when
+// another allocator wrote them in between.
+func updateAddressClaims(ctx context.Context, c client.Client, namespace string, update func(claims map[string]addressClaim) bool) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		cm := &corev1.ConfigMap{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: addressClaimsName}, cm)
+		if apierrors.IsNotFound(err) {
+			cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: addressClaimsName}}
+		} else if err != nil {
+			return err
+		}
+		claims := map[string]addressClaim{}
+		for key, value := range cm.Data {
+			var claim addressClaim
+			if json.Unmarshal([]byte(value), &claim) == nil && claim.Peer != "" {
+				claims[key] = claim
+			}
+		}
+		if !update(claims) {
+			return nil
+		}
+
+		cm.Data = make(map[string]string, len(claims))
+		for key, claim := range claims {
+			raw, err := json.Marshal(claim)
+			if err != nil {
+				return err
+			}
+			cm.Data[key] = string(raw)
+		}
+		if cm.ResourceVersion != "" {
+			return c.Update(ctx, cm)
+		}
+		err = c.Create(ctx, cm)
+		if apierrors.IsAlreadyExists(err) {
+			// Created by another allocator since it was read
+			return apierrors.NewConflict(corev1.Resource("configmaps"), addressClaimsName, err)
+		}
+		return err
+	})
+}
+
+// claimAddress claims an address for peer, and returns whether it holds
+// it: false if another peer claimed it first.
+func claimAddress(ctx context.Context, c client.Client, peer *vpnv1alpha1.VPNPeer, ip net.IP) (bool, error) {
+	claimed := false
+	err := updateAddressClaims(ctx, c, peer.Namespace, func(claims map[string]addressClaim) bool {
+		key := addressClaimKey(ip)
+		if claim, taken := claims[key]; taken {
+			claimed = claim.Peer == peer.Name
+			return false
+		}
+		claims[key] = addressClaim{Peer: peer.Name, Time: metav1.Now()}
+		claimed = true
+		return true
+	})
+	return claimed, err
+}
+
+// allocateFrom claims for peer the first address of the networks that is
+// neither used nor claimed by another peer, and returns it, or nil when the
+// networks are full. An address of the networks the peer already claimed is
+// returned again, so that retried allocations do not leak addresses.
+func allocateFrom(ctx context.Context, c client.Client, peer *vpnv1alpha1.VPNPeer, cidrs []string, used map[string]bool) (net.IP, error) {
+	var allocated net.IP
+	err := updateAddressClaims(ctx, c, peer.Namespace, func(claims map[string]addressClaim) bool {
+		allocated = nil
+		taken := make(map[string]bool, len(used)+len(claims))
+		for address := range used {
+			taken[address] = true
+		}
+		for key, claim := range claims {
+			ip := net.ParseIP(strings.ReplaceAll(key, "_", ":"))
+			if ip == nil {
+				continue
+			}
+			if claim.Peer == peer.Name && !used[ip.String()] && networksContain(cidrs, ip) {
+				allocated = ip
+				return false
+			}
+			taken[ip.String()] = true
+		}
+		for _, cidr := range cidrs {
+			if allocated = freeAddress(cidr, taken); allocated != nil {
+				claims[addressClaimKey(allocated)] = addressClaim{Peer: peer.Name, Time: metav1.Now()}
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allocated, nil
+}
+
+// releaseAddress releases the claim of peer on an address, for addresses a
+// peer gave up.
+func releaseAddress(ctx context.Context, c client.Client, peer *vpnv1alpha1.VPNPeer, address string) error {
+	ip, _ := splitAddress(address)
+	if ip == nil {
+		return nil
+	}
+	return updateAddressClaims(ctx, c, peer.Namespace, func(claims map[string]addressClaim) bool {
+		key := addressClaimKey(ip)
+		if claims[key].Peer != peer.Name {
+			return false
+		}
+		delete(claims, key)
+		return true
+	})
+}
+
+// pruneAddressClaims releases the claims of the peers of a namespace that
+// were deleted or no longer hold the address, once they are older than the
+// grace period. It returns when the next claim leaves its grace period, or
+// zero if none is within it.
+func pruneAddressClaims(ctx context.Context, c client.Client, namespace string, peers []vpnv1alpha1.VPNPeer) (time.Duration, error) {
+	held := heldAddresses(peers)
+	var next time.Duration
+	now := time.Now()
+	err := updateAddressClaims(ctx, c, namespace, func(claims map[string]addressClaim) bool {
+		next = 0
+		pruned := false
+		for key, claim := range claims {
+			if held[claim.Peer][key] {
+				continue
+			}
+			if age := now.Sub(claim.Time.Time); age < addressClaimGracePeriod {
+				if wait := addressClaimGracePeriod - age; next == 0 || wait < next {
+					next = wait
+				}
+				continue
+			}
+			delete(claims, key)
+			pruned = true
+		}
+		return pruned
+	})
+	return next, err
+}
+
+// heldAddresses returns the claim keys of the addresses each peer holds: its
+// address and the host routes it keeps, e.g. during pool migrations.
+func heldAddresses(peers []vpnv1alpha1.VPNPeer) map[string]map[string]bool {
+	held := map[string]map[string]bool{}
+	for i := range peers {
+		peer := &peers[i]
+		addresses := map[string]bool{}
+		if ip, _ := splitAddress(peer.Spec.Address); ip != nil {
+			addresses[addressClaimKey(ip)] = true
+		}
+		for _, ip := range hostRoutes(peer) {
+			addresses[addressClaimKey(ip)] = true
+		}
+		held[peer.Name] = addresses
+	}
+	return held
+}
@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// agentEnv returns the environment of the WireGuard container, which is
+// how the agent scripts in the server image are configured.
+func agentEnv(server *vpnv1alpha1.VPNServer) []corev1.EnvVar {
+	backend := server.Spec.FirewallBackend
+	if backend == "" {
+		backend = vpnv1alpha1.FirewallBackendAuto
+	}
+	implementation := server.Spec.Implementation
+	if implementation == "" {
+		implementation = vpnv1alpha1.WireGuardKernel
+	}
+
+	env := []corev1.EnvVar{
+		{Name: "WG_INTERFACE", Value: server.Spec.Interface},
+		{Name: "WG_PORT", Value: strconv.Itoa(int(server.Spec.Port))},
+		{Name: "WG_DEFAULT_ADDRESS", Value: server.Spec.Address},
+		{Name: "WG_DEFAULT_DNS", Value: server.Spec.DNS},
+		{Name: "WG_FIREWALL_BACKEND", Value: string(backend)},
+		{Name: "WG_IMPLEMENTATION", Value: string(implementation)},
+	}
+	if len(server.Spec.AdditionalListenPorts) > 0 {
+		ports := make([]string, 0, len(server.Spec.AdditionalListenPorts))
+		for _, port := range server.Spec.AdditionalListenPorts {
+			ports = append(ports, strconv.Itoa(int(port)))
+		}
+		env = append(env, corev1.EnvVar{Name: "WG_ADDITIONAL_PORTS", Value: strings.Join(ports, ",")})
+	}
+	if server.Spec.InterfaceMTU != nil {
+		env = append(env, corev1.EnvVar{Name: "WG_MTU", Value: strconv.Itoa(int(*server.Spec.InterfaceMTU))})
+	}
+	if server.Spec.FwMark != nil {
+		env = append(env, corev1.EnvVar{Name: "WG_FWMARK", Value: fmt.Sprintf("%#x", *server.Spec.FwMark)})
+	}
+	if portRange := server.Spec.ListenPortRange; portRange != nil && server.Spec.DeploymentMode == vpnv1alpha1.DeploymentModeStatefulSet {
+		// The agent listens on the port of the range at the ordinal of its
+		// pod instead of WG_PORT
+		env = append(env,
+			corev1.EnvVar{Name: "WG_PORT_RANGE", Value: fmt.Sprintf("%d-%d", portRange.Start, portRange.End)},
+			corev1.EnvVar{Name: "WG_POD_NAME", ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+			}},
+		)
+	}
+	if shardCount(server) > 0 {
+		// The agent only configures the peers assigned to the ordinal of
+		// its pod
+		env = append(env, corev1.EnvVar{Name: "WG_SHARDING", Value: "true"})
+		if server.Spec.ListenPortRange == nil {
+			env = append(env, corev1.EnvVar{Name: "WG_POD_NAME", ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+			}})
+		}
+	}
+	if server.Spec.DeploymentMode == vpnv1alpha1.DeploymentModeDaemonSet && len(server.Spec.NodeListenPorts) > 0 {
+		ports := make([]string, 0, len(server.Spec.NodeListenPorts))
+		for _, port := range server.Spec.NodeListenPorts {
+			ports = append(ports, port.Node+"="+strconv.Itoa(int(port.Port)))
+		}
+		// The agent listens on the port of its node instead of WG_PORT
+		env = append(env,
+			corev1.EnvVar{Name: "WG_NODE_PORTS", Value: strings.Join(ports, ",")},
+			corev1.EnvVar{Name: "WG_NODE_NAME", ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+			}},
+		)
+	}
+	if server.Spec.ClientIsolation {
+		env = append(env, corev1.EnvVar{Name: "WG_CLIENT_ISOLATION", Value: "true"})
+	}
+	if server.Spec.DeviceLogging {
+		env = append(env, corev1.EnvVar{Name: "WG_DEVICE_LOG", Value: "true"})
+	}
+	env = append(env, performanceEnv(server)...)
+	env = append(env, vaultEnv(server)...)
+	env = append(env, haEnv(server)...)
+	return append(env, sessionRecorderEnv(server)...)
+}
+
+// vaultEnv returns the environment of the key fetch from Vault. It is empty
+// unless the server has a Vault key store.
+func vaultEnv(server *vpnv1alpha1.VPNServer) []corev1.EnvVar {
+	if !usesVaultKeyStore(server) {
+		return nil
+	}
+	config := server.Spec.KeyStore.Vault
+	engine := config.Engine
+	if engine == "" {
+		engine = vpnv1alpha1.VaultEngineKV
+	}
+	authMount := config.AuthMount
+	if authMount == "" {
+		authMount = "kubernetes"
+	}
+	dataKey := defaultServerPrivateKeyKey
+	if ref := server.Spec.PrivateKeySecretRef; ref != nil && ref.Key != "" {
+		dataKey = ref.Key
+	}
+	env := []corev1.EnvVar{
+		{Name: "WG_VAULT_ADDR", Value: config.Address},
+		{Name: "WG_VAULT_AUTH_MOUNT", Value: authMount},
+		{Name: "WG_VAULT_ROLE", Value: config.Role},
+		{Name: "WG_VAULT_ENGINE", Value: string(engine)},
+		{Name: "WG_VAULT_MOUNT", Value: vaultMount(config)},
+		{Name: "WG_VAULT_PATH", Value: vaultPath(server)},
+		{Name: "WG_VAULT_KEY_FIELD", Value: dataKey},
+	}
+	if engine == vpnv1alpha1.VaultEngineTransit {
+		env = append(env, corev1.EnvVar{Name: "WG_VAULT_SEALED_KEY_FILE", Value: sealedKeysMountPath + "/" + dataKey})
+	}
+	return env
+}
+
+// usesVaultKeyStore returns whether the server pods fetch their key from
+// Vault.
+func usesVaultKeyStore(server *vpnv1alpha1.VPNServer) bool {
+	store := server.Spec.KeyStore
+	return store != nil && store.Type == vpnv1alpha1.KeyStoreVault && store.Vault != nil
+}
+
+// performanceEnv returns the environment of the data plane tuning. It is
+// empty unless the server has spec.performance.
+func performanceEnv(server *vpnv1alpha1.VPNServer) []corev1.EnvVar {
+	tuning := server.Spec.Performance
+	if tuning == nil {
+		return nil
+	}
+	var env []corev1.EnvVar
+	if tuning.ReceiveBufferBytes != nil {
+		env = append(env, corev1.EnvVar{Name: "WG_RMEM_MAX", Value: strconv.Itoa(int(*tuning.ReceiveBufferBytes))})
+	}
+	if tuning.SendBufferBytes != nil {
+		env = append(env, corev1.EnvVar{Name: "WG_WMEM_MAX", Value: strconv.Itoa(int(*tuning.SendBufferBytes))})
+	}
+	if tuning.TxQueueLen != nil {
+		env = append(env, corev1.EnvVar{Name: "WG_TXQUEUELEN", Value: strconv.Itoa(int(*tuning.TxQueueLen))})
+	}
+	if tuning.RPSCPUs != "" {
+		env = append(env, corev1.EnvVar{Name: "WG_RPS_CPUS", Value: tuning.RPSCPUs})
+	}
+	if tuning.XPSCPUs != "" {
+		env = append(env, corev1.EnvVar{Name: "WG_XPS_CPUS", Value: tuning.XPSCPUs})
+	}
+	return env
+}
+
+// sessionRecorderEnv returns the environment of the session recorder. It is
+// empty unless session metadata is recorded.
+func sessionRecorderEnv(server *vpnv1alpha1.VPNServer) []corev1.EnvVar {
+	compliance := server.Spec.Compliance
+	if compliance == nil || compliance.Sink == nil ||
+		compliance.SessionMetadata == "" || compliance.SessionMetadata == vpnv1alpha1.SessionMetadataDisabled {
+		return nil
+	}
+
+	policy := compliance.FailurePolicy
+	if policy == "" {
+		policy = vpnv1alpha1.SessionFailClosed
+	}
+
+	env := []corev1.EnvVar{
+		{Name: "WG_SESSION_METADATA", Value: string(compliance.SessionMetadata)},
+		{Name: "WG_SESSION_FAILURE_POLICY", Value: string(policy)},
+		{Name: "WG_SESSION_SINK_URL", Value: compliance.Sink.URL},
+		{Name: "WG_SESSION_SERVER", Value: server.Namespace + "/" + server.Name},
+	}
+	if ref := compliance.Sink.TokenSecretRef; ref != nil {
+		key := ref.Key
+		if key == "" {
+			key = "token"
+		}
+		env = append(env, corev1.EnvVar{
+			Name: "WG_SESSION_SINK_TOKEN",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
+					Key:                  key,
+				},
+			},
+		})
+	}
+	return env
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// autoscalingInterval is how often the load of autoscaled servers is
+// sampled, besides the updates of their status
+const autoscalingInterval = 30 * time.Second
+
+// AutoscalingReconciler scales the replicas of servers with
+// spec.autoscaling by their connected clients and throughput. The
+// throughput is derived from the total traffic published by the peer stats
+// aggregation, as for the custom metrics API.
+type AutoscalingReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	traffic trafficHistory
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile sets the replicas of a server to those its load calls for.
+func (r *AutoscalingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			r.traffic.forget(req.NamespacedName)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	autoscaling := server.Spec.Autoscaling
+	if autoscaling == nil {
+		r.traffic.forget(req.NamespacedName)
+		original := server.DeepCopy()
+		server.Status.Autoscaling = nil
+		vpnv1alpha1.RemoveCondition(&server.Status.Conditions, vpnv1alpha1.ConditionScalingLimited)
+		return ctrl.Result{}, r.patchStatus(ctx, server, original)
+	}
+
+	now := time.Now()
+	r.traffic.record(req.NamespacedName, now, server.Status.TotalTraffic)
+	throughput := r.traffic.rate(req.NamespacedName, now, throughputWindow)
+	desired, wanted := desiredReplicas(autoscaling, server.Status.ConnectedClients, throughput)
+
+	current := server.Spec.Replicas
+	if current == 0 {
+		current = 1
+	}
+	var lastScaleTime *metav1.Time
+	if server.Status.Autoscaling != nil {
+		lastScaleTime = server.Status.Autoscaling.LastScaleTime
+	}
+	window := 5 * time.Minute
+	if autoscaling.ScaleDownStabilizationWindow != nil {
+		window = autoscaling.ScaleDownStabilizationWindow.Duration
+	}
+	stable := lastScaleTime == nil || now.Sub(lastScaleTime.Time) >= window
+	if desired > current || (desired < current && stable) {
+		original := server.DeepCopy()
+		server.Spec.Replicas = desired
+		if err := r.Patch(ctx, server, client.MergeFrom(original)); err != nil {
+			return ctrl.Result{}, err
+		}
+		message := fmt.Sprintf("scaled from %d to %d replicas for %d connected clients and %s/s",
+			current, desired, server.Status.ConnectedClients, formatBytes(throughput))
+		logger.Info(message)
+		r.Recorder.Event(server, corev1.EventTypeNormal, "Scaled", message)
+		lastScaleTime = &metav1.Time{Time: now}
+	}
+
+	original := server.DeepCopy()
+	server.Status.Autoscaling = &vpnv1alpha1.AutoscalingStatus{
+		DesiredReplicas:   desired,
+		CurrentThroughput: resource.NewQuantity(int64(throughput), resource.BinarySI),
+		LastScaleTime:     lastScaleTime,
+	}
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionScalingLimited,
+		Status:             vpnv1alpha1.ConditionFalse,
+		Reason:             "DesiredWithinRange",
+		ObservedGeneration: server.Generation,
+	}
+	if wanted > autoscaling.MaxReplicas {
+		condition.Status = vpnv1alpha1.ConditionTrue
+		condition.Reason = "TooManyReplicas"
+		condition.Message = fmt.Sprintf("the targets call for %d replicas, more than maxReplicas %d", wanted, autoscaling.MaxReplicas)
+	}
+	vpnv1alpha1.SetCondition(&server.Status.Conditions, condition)
+	return ctrl.Result{RequeueAfter: autoscalingInterval}, r.patchStatus(ctx, server, original)
+}
+
+// desiredReplicas returns the replicas the targets of autoscaling call for
+// within its bounds, and without them.
+func desiredReplicas(autoscaling *vpnv1alpha1.AutoscalingSpec, clients int32, throughput float64) (int32, int32) {
+	minReplicas := int32(1)
+	if autoscaling.MinReplicas != nil {
+		minReplicas = *autoscaling.MinReplicas
+	}
+	wanted := minReplicas
+	if target := autoscaling.TargetClientsPerReplica; target != nil && *target > 0 {
+		if n := (clients + *target - 1) / *target; n > wanted {
+			wanted = n
+		}
+	}
+	if target := autoscaling.TargetThroughputPerReplica; target != nil && target.Value() > 0 {
+		if n := int32(math.Ceil(throughput / float64(target.Value()))); n > wanted {
+			wanted = n
+		}
+	}
+	if wanted > autoscaling.MaxReplicas {
+		return autoscaling.MaxReplicas, wanted
+	}
+	return wanted, wanted
+}
+
+// formatBytes formats a number of bytes with a binary unit, e.g. 1.5MiB.
+func formatBytes(bytes float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for bytes >= 1024 && i < len(units)-1 {
+		bytes /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f%s", bytes, units[i])
+}
+
+// patchStatus patches the status of a server if it changed.
+func (r *AutoscalingReconciler) patchStatus(ctx context.Context, server, original *vpnv1alpha1.VPNServer) error {
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return nil
+	}
+	return patchServerStatus(ctx, r.Client, server, original)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AutoscalingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("autoscaling").
+		For(&vpnv1alpha1.VPNServer{}).
+		Complete(instrument(mgr, "autoscaling", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// bandwidthConfigKey is the key of the rendered config ConfigMap holding
+// the bandwidth limits read by the agent
+const bandwidthConfigKey = "bandwidth"
+
+// minBandwidthBurst is the smallest burst of a limit, a full-sized packet
+const minBandwidthBurst = 1600
+
+// appliedBandwidthReport is the shaping report the agent pushes on its pod
+type appliedBandwidthReport struct {
+	ReportedAt int64                           `json:"reportedAt"`
+	Peers      map[string]appliedBandwidthPeer `json:"peers"`
+}
+
+// appliedBandwidthPeer is the limit a replica applied to a peer address, or
+// the error that prevented it
+type appliedBandwidthPeer struct {
+	Rate  int64  `json:"rate"`
+	Burst int64  `json:"burst"`
+	Error string `json:"error"`
+}
+
+// renderBandwidthLimits renders the bandwidth limits of the peers of a
+// server for the agent: one line per peer holding its tunnel address, its
+// rate in bits per second and its burst in bytes.
+func renderBandwidthLimits(server *vpnv1alpha1.VPNServer, peers []vpnv1alpha1.VPNPeer) string {
+	var lines []string
+	for i := range peers {
+		peer := &peers[i]
+		if peer.Spec.ServerRef.Name != server.Name || !peer.DeletionTimestamp.IsZero() {
+			continue
+		}
+		rate, burst, ok := bandwidthLimit(peer)
+		address := hostCIDR(peer.Spec.Address)
+		if !ok || address == "" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %d %d\n", address, rate, burst))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "")
+}
+
+// bandwidthLimit returns the rate in bits per second and the burst in bytes
+// of the bandwidth limit of a peer, and whether it has a valid one.
+func bandwidthLimit(peer *vpnv1alpha1.VPNPeer) (int64, int64, bool) {
+	limit := peer.Spec.Bandwidth
+	if limit == nil {
+		return 0, 0, false
+	}
+	rate := limit.Rate.Value()
+	if rate <= 0 {
+		return 0, 0, false
+	}
+	burst := rate / 80
+	if limit.Burst != nil {
+		burst = limit.Burst.Value()
+	}
+	if burst < minBandwidthBurst {
+		burst = minBandwidthBurst
+	}
+	return rate, burst, true
+}
+
+// BandwidthReconciler reports the state of the bandwidth limits of the
+// peers of a server in their status.shaping. The agents shape the traffic
+// of each limited peer with tc on the tunnel interface, and report the
+// limits in effect on their pod, since tc can fail, e.g. without the ifb
+// module that uploads are shaped through.
+type BandwidthReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers/status,verbs=get;update;patch
+
+// Reconcile sets the shaping status of the peers of a server from the
+// reports of its ready pods. A limit is Applied once every ready replica
+// shapes the peer at it, and Failed when any replica could not.
+func (r *BandwidthReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(server.Namespace), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: server.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(server.Namespace), client.MatchingLabels(serverLabels(server))); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	ready := 0
+	reports := map[string]appliedBandwidthReport{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !isPodReady(pod) || !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		ready++
+		raw, ok := pod.Annotations[vpnv1alpha1.AppliedBandwidthAnnotation]
+		if !ok {
+			continue
+		}
+		report := appliedBandwidthReport{}
+		if err := json.Unmarshal([]byte(raw), &report); err != nil {
+			logger.Info("ignoring malformed bandwidth report", "pod", pod.Name, "error", err.Error())
+			continue
+		}
+		reports[pod.Name] = report
+	}
+	names := make([]string, 0, len(reports))
+	for name := range reports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i := range peers.Items {
+		peer := &peers.Items[i]
+		if !peer.DeletionTimestamp.IsZero() {
+			continue
+		}
+		original := peer.DeepCopy()
+		peer.Status.Shaping = shapingStatus(peer, ready, names, reports)
+		if equality.Semantic.DeepEqual(original.Status, peer.Status) {
+			continue
+		}
+		if err := r.Status().Patch(ctx, peer, client.MergeFrom(original)); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// shapingStatus returns the shaping status of a peer given the reports of
+// the ready replicas of its server, by pod name, or nil if it has no limit.
+func shapingStatus(peer *vpnv1alpha1.VPNPeer, ready int, pods []string, reports map[string]appliedBandwidthReport) *vpnv1alpha1.ShapingStatus {
+	if peer.Spec.Bandwidth == nil {
+		return nil
+	}
+	rate, burst, ok := bandwidthLimit(peer)
+	status := &vpnv1alpha1.ShapingStatus{State: vpnv1alpha1.ShapingPending, Rate: rate, Burst: burst}
+	if !ok {
+		status.State = vpnv1alpha1.ShapingFailed
+		status.Message = "spec.bandwidth.rate must be positive"
+		return status
+	}
+	address := hostCIDR(peer.Spec.Address)
+	if address == "" {
+		status.Message = "the peer has no address to shape"
+		return status
+	}
+
+	var failed, pending []string
+	for _, pod := range pods {
+		applied, ok := reports[pod].Peers[address]
+		switch {
+		case ok && applied.Error != "":
+			failed = append(failed, pod+": "+applied.Error)
+		case ok && applied.Rate == rate && applied.Burst == burst:
+			status.Replicas++
+		default:
+			pending = append(pending, pod)
+		}
+	}
+	switch {
+	case len(failed) > 0:
+		status.State = vpnv1alpha1.ShapingFailed
+		status.Message = strings.Join(failed, "; ")
+	case ready == 0:
+		status.Message = "no replica of the server is ready"
+	case int(status.Replicas) < ready:
+		status.Message = fmt.Sprintf("%d of %d replicas shape the peer at its current limit", status.Replicas, ready)
+		if len(pending) > 0 {
+			status.Message += ", waiting for " + strings.Join(pending, ", ")
+		}
+	default:
+		status.State = vpnv1alpha1.ShapingApplied
+	}
+	return status
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BandwidthReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("bandwidth").
+		For(&vpnv1alpha1.VPNServer{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(serverForPod)).
+		Watches(&vpnv1alpha1.VPNPeer{}, handler.EnqueueRequestsFromMapFunc(serverForPeer)).
+		Complete(instrument(mgr, "bandwidth", &vpnv1alpha1.VPNServer{}, r))
+}
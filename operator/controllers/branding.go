@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/mail"
+)
+
+const (
+	defaultInviteSubject = `Your {{.OrgName}} VPN invite`
+
+	defaultInviteBody = `You are invited to the {{.OrgName}} VPN as {{.Peer.Name}}.
+{{if .PortalURL}}
+Open {{.PortalURL}} to get started.
+{{end}}
+Accept the invite from the device to connect, with its WireGuard private
+key in device.key and this token in invite.token:
+
+    {{.Token}}
+
+    wireflow accept-invite --key-file device.key --token-file invite.token{{if .EnrollmentURL}} --url {{.EnrollmentURL}}{{end}} {{.Peer.Namespace}}/{{.Peer.Name}}
+
+The invite expires on {{.ExpiresAt.UTC.Format "2006-01-02 15:04 MST"}}.
+{{if .SupportURL}}
+Need help? {{.SupportURL}}
+{{end}}`
+)
+
+// brandingData is what branding templates are rendered with
+type brandingData struct {
+	OrgName    string
+	SupportURL string
+	LogoURL    string
+	Variables  map[string]string
+
+	Peer   *vpnv1alpha1.VPNPeer
+	Server *vpnv1alpha1.VPNServer
+
+	// Token, EnrollmentURL, PortalURL and ExpiresAt are set for invites
+	Token         string
+	EnrollmentURL string
+	PortalURL     string
+	ExpiresAt     time.Time
+}
+
+// newBrandingData returns the template data of a peer, branded with
+// branding if not nil.
+func newBrandingData(branding *vpnv1alpha1.VPNBranding, peer *vpnv1alpha1.VPNPeer, server *vpnv1alpha1.VPNServer) *brandingData {
+	data := &brandingData{OrgName: "Wireflow", Peer: peer, Server: server}
+	if branding != nil {
+		data.OrgName = branding.Spec.OrgName
+		data.SupportURL = branding.Spec.SupportURL
+		data.LogoURL = branding.Spec.LogoURL
+		data.Variables = branding.Spec.Variables
+	}
+	return data
+}
+
+// serverBranding returns the branding referenced by a server, nil if it
+// references none or the branding does not exist: users are then served
+// the defaults rather than nothing.
+func serverBranding(ctx context.Context, c client.Reader, server *vpnv1alpha1.VPNServer) (*vpnv1alpha1.VPNBranding, error) {
+	ref := server.Spec.BrandingRef
+	if ref == nil {
+		return nil, nil
+	}
+	branding := &vpnv1alpha1.VPNBranding{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: server.Namespace, Name: ref.Name}, branding); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return branding, nil
+}
+
+// renderTemplate renders a branding template.
+func renderTemplate(name, text string, data *brandingData) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// brandedClientConfig renders the client config of a peer, headed with the
+// config header of the server's branding.
+func brandedClientConfig(ctx context.Context, c client.Reader, privateKey, presharedKey string, peer *vpnv1alpha1.VPNPeer, server *vpnv1alpha1.VPNServer) (string, error) {
+	config := renderClientConfig(privateKey, presharedKey, peer, server)
+	branding, err := serverBranding(ctx, c, server)
+	if err != nil || branding == nil || branding.Spec.ConfigHeader == "" {
+		return config, err
+	}
+	header, err := renderTemplate("configHeader", branding.Spec.ConfigHeader, newBrandingData(branding, peer, server))
+	if err != nil {
+		return "", fmt.Errorf("config header of VPNBranding %q: %w", branding.Name, err)
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(header, "\n"), "\n") {
+		b.WriteString(strings.TrimRight("# "+line, " "))
+		b.WriteString("\n")
+	}
+	b.WriteString(config)
+	return b.String(), nil
+}
+
+// renderInviteEmail renders the invite email of a peer.
+func renderInviteEmail(branding *vpnv1alpha1.VPNBranding, data *brandingData) (mail.Message, error) {
+	subject, body := defaultInviteSubject, defaultInviteBody
+	if branding != nil && branding.Spec.InviteEmail != nil {
+		subject, body = branding.Spec.InviteEmail.Subject, branding.Spec.InviteEmail.Body
+	}
+	msg := mail.Message{To: data.Peer.Spec.Invite.Email}
+	var err error
+	if msg.Subject, err = renderTemplate("inviteEmail.subject", subject, data); err != nil {
+		return msg, err
+	}
+	msg.Subject = strings.Join(strings.Fields(msg.Subject), " ")
+	msg.Body, err = renderTemplate("inviteEmail.body", body, data)
+	return msg, err
+}
+
+// VPNBrandingReconciler checks that the templates of brandings parse.
+type VPNBrandingReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnbrandings,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnbrandings/status,verbs=get;update;patch
+
+// Reconcile sets the Ready condition of a branding.
+func (r *VPNBrandingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	branding := &vpnv1alpha1.VPNBranding{}
+	if err := r.Get(ctx, req.NamespacedName, branding); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	branding.Status.ObservedGeneration = branding.Generation
+
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionReady,
+		Status:             vpnv1alpha1.ConditionTrue,
+		Reason:             "Configured",
+		ObservedGeneration: branding.Generation,
+	}
+	if err := checkBrandingTemplates(branding); err != nil {
+		condition.Status = vpnv1alpha1.ConditionFalse
+		condition.Reason = "InvalidTemplate"
+		condition.Message = err.Error()
+	}
+	vpnv1alpha1.SetCondition(&branding.Status.Conditions, condition)
+	return ctrl.Result{}, r.Status().Update(ctx, branding)
+}
+
+// checkBrandingTemplates parses the templates of a branding.
+func checkBrandingTemplates(branding *vpnv1alpha1.VPNBranding) error {
+	templates := map[string]string{"configHeader": branding.Spec.ConfigHeader}
+	if email := branding.Spec.InviteEmail; email != nil {
+		templates["inviteEmail.subject"] = email.Subject
+		templates["inviteEmail.body"] = email.Body
+	}
+	for _, name := range []string{"configHeader", "inviteEmail.subject", "inviteEmail.body"} {
+		if _, err := template.New(name).Parse(templates[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VPNBrandingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vpnv1alpha1.VPNBranding{}).
+		Complete(instrument(mgr, "vpnbranding", &vpnv1alpha1.VPNBranding{}, r))
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/catalog"
+)
+
+// CatalogReconciler publishes the connected peers of each server into a
+// Consul or etcd service catalog, with their tunnel IP and tags, so that
+// service discovery driven tooling can reach them. A peer is connected
+// while its latest handshake is fresh; disconnected and deleted peers are
+// removed from the catalog.
+type CatalogReconciler struct {
+	client.Client
+	Scheme  *runtime.Scheme
+	Catalog catalog.Catalog
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+
+// Reconcile registers the connected peers of a server and deregisters the
+// entries of the peers no longer connected.
+func (r *CatalogReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	scope := catalog.Scope{Name: req.Namespace + "-" + req.Name}
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		// The server is gone, so are its peers
+		return ctrl.Result{}, r.prune(ctx, scope, nil)
+	}
+	if ip, _, ok := strings.Cut(server.Spec.Address, "/"); ok {
+		scope.Address = ip
+	}
+
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(server.Namespace), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: server.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+	connected := map[string]bool{}
+	for _, status := range server.Status.Peers {
+		if status.LatestHandshake != nil && now.Sub(status.LatestHandshake.Time) <= handshakeFreshness {
+			connected[status.PublicKey] = true
+		}
+	}
+
+	registered := map[string]bool{}
+	for i := range peers.Items {
+		peer := &peers.Items[i]
+		if !connected[peer.Spec.PublicKey] || peer.Spec.PublicKey == "" || peer.Spec.Address == "" || !peer.DeletionTimestamp.IsZero() {
+			continue
+		}
+		address, _, _ := strings.Cut(peer.Spec.Address, "/")
+		tags := []string{"server:" + server.Name}
+		if peer.Spec.Group != "" {
+			tags = append(tags, "group:"+peer.Spec.Group)
+		}
+		entry := catalog.Entry{
+			ID:      peer.Name,
+			Name:    peer.Name,
+			Address: address,
+			Tags:    tags,
+			Meta: map[string]string{
+				"namespace": peer.Namespace,
+				"publicKey": peer.Spec.PublicKey,
+			},
+		}
+		if err := r.Catalog.Register(ctx, scope, entry); err != nil {
+			return ctrl.Result{}, err
+		}
+		registered[entry.ID] = true
+	}
+	if err := r.prune(ctx, scope, registered); err != nil {
+		return ctrl.Result{}, err
+	}
+	logger.V(1).Info("published connected peers", "scope", scope.Name, "peers", len(registered))
+
+	// Handshakes age without any event, peers that stop handshaking are
+	// removed on the next pass
+	return ctrl.Result{RequeueAfter: handshakeFreshness}, nil
+}
+
+// prune deregisters the entries of a scope not in keep.
+func (r *CatalogReconciler) prune(ctx context.Context, scope catalog.Scope, keep map[string]bool) error {
+	ids, err := r.Catalog.List(ctx, scope)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if keep[id] {
+			continue
+		}
+		if err := r.Catalog.Deregister(ctx, scope, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CatalogReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("catalog").
+		For(&vpnv1alpha1.VPNServer{}).
+		Watches(&vpnv1alpha1.VPNPeer{}, serverForPeerCoalesced()).
+		Complete(instrument(mgr, "catalog", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,412 @@
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/chat"
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+	"github.com/vpn-devops/vpn-operator/pkg/qrcode"
+)
+
+const (
+	// maxCommandSize bounds the body of chat commands
+	maxCommandSize = 64 << 10
+
+	// defaultDeviceTimeout is how long a new device may take to be resolved
+	// before its creation is abandoned
+	defaultDeviceTimeout = time.Minute
+
+	defaultMaxDevicesPerUser = 3
+
+	chatUsage = "Usage: /vpn new-device [name] | /vpn revoke <name> | /vpn status"
+)
+
+// deviceNameInvalid matches the characters not allowed in device names
+var deviceNameInvalid = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// ChatCommandServer serves the /vpn chat commands of VPNChatIntegrations
+// on /chat/<namespace>/<name>. Members create devices, whose private key is
+// generated, sent by direct message with a QR code and never stored, revoke
+// them and list them with their last handshake.
+type ChatCommandServer struct {
+	client.Client
+
+	// Address is the address commands are served on
+	Address string
+
+	// GetCertificate returns the serving certificate of the operator
+	GetCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+	// DeviceTimeout is how long a new device may take to be resolved
+	DeviceTimeout time.Duration
+
+	// HTTPClient is the client chat APIs are called with
+	HTTPClient *http.Client
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnchatintegrations,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Every
+// replica serves commands, so that they are answered during leader
+// changes.
+func (s *ChatCommandServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable.
+func (s *ChatCommandServer) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle("/chat/", s)
+	server := &http.Server{
+		Addr:              s.Address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+		TLSConfig: &tls.Config{
+			GetCertificate: s.GetCertificate,
+			MinVersion:     tls.VersionTLS12,
+		},
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	ctrl.Log.WithName("chat").Info("serving chat commands", "address", s.Address)
+	if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP verifies a command with the signing secret of its integration
+// and answers it.
+func (s *ChatCommandServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	logger := ctrl.Log.WithName("chat")
+	ctx := req.Context()
+
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/chat/"), "/")
+	if req.Method != http.MethodPost || len(parts) != 2 {
+		http.NotFound(w, req)
+		return
+	}
+	integration := &vpnv1alpha1.VPNChatIntegration{}
+	if err := s.Get(ctx, types.NamespacedName{Namespace: parts[0], Name: parts[1]}, integration); err != nil {
+		if !apierrors.IsNotFound(err) {
+			logger.Error(err, "unable to get chat integration", "namespace", parts[0], "name", parts[1])
+		}
+		http.NotFound(w, req)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxCommandSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	secret, err := secretKey(ctx, s.Client, integration.Namespace, integration.Spec.SigningSecretRef, defaultSigningSecretKey)
+	if err != nil {
+		logger.Error(err, "unable to read signing secret", "integration", integration.Name)
+		http.Error(w, "integration is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var command *chat.Command
+	if integration.Spec.Platform == vpnv1alpha1.ChatPlatformTeams {
+		command, err = chat.ParseTeams(req.Header, body, secret)
+	} else {
+		command, err = chat.ParseSlack(req.Header, body, secret, time.Now())
+	}
+	if errors.Is(err, chat.ErrInvalidSignature) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reply, err := s.handle(ctx, integration, command)
+	if err != nil {
+		logger.Error(err, "chat command failed", "integration", integration.Name, "user", command.User, "command", command.Verb)
+		reply = "The command failed, please try again later."
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(chat.Reply(command.Platform, reply))
+}
+
+// handle runs a command and returns the reply to its sender.
+func (s *ChatCommandServer) handle(ctx context.Context, integration *vpnv1alpha1.VPNChatIntegration, command *chat.Command) (string, error) {
+	member := integration.Member(command.User)
+	if member == nil {
+		return fmt.Sprintf("You cannot manage VPN devices here, ask an administrator to add user %s to the integration.", command.User), nil
+	}
+	switch command.Verb {
+	case "new-device":
+		name := "device"
+		if len(command.Args) > 0 {
+			name = command.Args[0]
+		}
+		return s.newDevice(ctx, integration, member, command, name)
+	case "revoke":
+		if len(command.Args) == 0 {
+			return chatUsage, nil
+		}
+		return s.revoke(ctx, integration, command, command.Args[0])
+	case "status":
+		return s.status(ctx, integration, command)
+	default:
+		return chatUsage, nil
+	}
+}
+
+// devices returns the peers of a member.
+func (s *ChatCommandServer) devices(ctx context.Context, integration *vpnv1alpha1.VPNChatIntegration, user string) ([]vpnv1alpha1.VPNPeer, error) {
+	peers := &vpnv1alpha1.VPNPeerList{}
+	err := s.List(ctx, peers, client.InNamespace(integration.Namespace), client.MatchingLabels{
+		vpnv1alpha1.ChatIntegrationLabel: integration.Name,
+		vpnv1alpha1.ChatUserLabel:        strings.ToLower(user),
+	})
+	return peers.Items, err
+}
+
+// deviceName returns the device name of a peer created by a member.
+func deviceName(integration *vpnv1alpha1.VPNChatIntegration, user, peer string) string {
+	return strings.TrimPrefix(peer, integration.Name+"-"+strings.ToLower(user)+"-")
+}
+
+// newDevice creates a peer for a member and sends its config by direct
+// message once it is resolved.
+func (s *ChatCommandServer) newDevice(ctx context.Context, integration *vpnv1alpha1.VPNChatIntegration, member *vpnv1alpha1.ChatMember, command *chat.Command, name string) (string, error) {
+	// Teams outgoing webhooks can only reply in the channel, where the
+	// private key would be visible to everyone
+	if integration.Spec.Platform != vpnv1alpha1.ChatPlatformSlack || integration.Spec.BotTokenSecretRef == nil {
+		return "New devices can only be created where their config can be sent by direct message, ask an administrator for a config.", nil
+	}
+	name = strings.Trim(deviceNameInvalid.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if name == "" || len(name) > maxDeviceNameLength {
+		return fmt.Sprintf("Device names are 1 to %d letters, digits or dashes.", maxDeviceNameLength), nil
+	}
+
+	devices, err := s.devices(ctx, integration, command.User)
+	if err != nil {
+		return "", err
+	}
+	max := integration.Spec.MaxDevicesPerUser
+	if max == 0 {
+		max = defaultMaxDevicesPerUser
+	}
+	if int32(len(devices)) >= max {
+		return fmt.Sprintf("You already have %d devices, revoke one first with /vpn revoke <name>.", len(devices)), nil
+	}
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := s.Get(ctx, types.NamespacedName{Namespace: integration.Namespace, Name: integration.Spec.ServerRef.Name}, server); err != nil {
+		return "", err
+	}
+	key, err := escrow.GenerateKey()
+	if err != nil {
+		return "", err
+	}
+
+	now := metav1.Now()
+	peer := &vpnv1alpha1.VPNPeer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      integration.Name + "-" + strings.ToLower(command.User) + "-" + name,
+			Namespace: integration.Namespace,
+			Labels: map[string]string{
+				vpnv1alpha1.ChatIntegrationLabel: integration.Name,
+				vpnv1alpha1.ChatUserLabel:        strings.ToLower(command.User),
+			},
+		},
+		Spec: vpnv1alpha1.VPNPeerSpec{
+			ServerRef: vpnv1alpha1.LocalObjectReference{Name: server.Name},
+			PublicKey: key.PublicKey().String(),
+			Group:     integration.Spec.Group,
+			Identity: &vpnv1alpha1.PeerIdentity{
+				Issuer:   command.Platform + ":" + command.Team,
+				Subject:  command.User,
+				Claims:   member.Claims,
+				SyncTime: &now,
+			},
+		},
+	}
+	address, err := allocateAddress(ctx, s.Client, peer, server)
+	if err != nil {
+		return "", err
+	}
+	if address == "" {
+		return "The VPN has no free address left, ask an administrator.", nil
+	}
+	peer.Spec.Address = address
+	if err := s.Create(ctx, peer); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			// The claimed address may be the one of the existing peer
+			return fmt.Sprintf("You already have a device named %s.", name), nil
+		}
+		return "", errors.Join(err, releaseAddress(ctx, s.Client, peer, address))
+	}
+
+	// Slack expects a reply within seconds, the config follows once the
+	// peer is resolved
+	go s.deliver(integration.DeepCopy(), command, peer, key.String(), name)
+	return fmt.Sprintf("Creating device %s, its config will be sent to you by direct message.", name), nil
+}
+
+// deliver waits for a new peer to be resolved and sends its config by
+// direct message. The peer is deleted if the config cannot be delivered, as
+// its private key is not kept.
+func (s *ChatCommandServer) deliver(integration *vpnv1alpha1.VPNChatIntegration, command *chat.Command, peer *vpnv1alpha1.VPNPeer, privateKey, name string) {
+	logger := ctrl.Log.WithName("chat").WithValues("integration", integration.Name, "peer", peer.Name)
+	timeout := s.DeviceTimeout
+	if timeout == 0 {
+		timeout = defaultDeviceTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+30*time.Second)
+	defer cancel()
+
+	err := s.sendConfig(ctx, integration, command, peer, privateKey, name, timeout)
+	if err == nil {
+		logger.Info("sent device config")
+		return
+	}
+	logger.Error(err, "unable to deliver device config")
+	if err := s.Delete(ctx, peer); client.IgnoreNotFound(err) != nil {
+		logger.Error(err, "unable to delete undelivered device")
+	}
+	if command.ResponseURL != "" {
+		text := fmt.Sprintf("Device %s could not be delivered and was removed, please try again later.", name)
+		if err := chat.Respond(ctx, s.HTTPClient, command.ResponseURL, text); err != nil {
+			logger.Error(err, "unable to reply to command")
+		}
+	}
+}
+
+// sendConfig waits up to timeout for a peer to be resolved and sends its
+// config and QR code to the sender of the command.
+func (s *ChatCommandServer) sendConfig(ctx context.Context, integration *vpnv1alpha1.VPNChatIntegration, command *chat.Command, peer *vpnv1alpha1.VPNPeer, privateKey, name string, timeout time.Duration) error {
+	server := &vpnv1alpha1.VPNServer{}
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := s.Get(ctx, client.ObjectKeyFromObject(peer), peer); err != nil {
+			return err
+		}
+		if err := s.Get(ctx, types.NamespacedName{Namespace: peer.Namespace, Name: peer.Spec.ServerRef.Name}, server); err != nil {
+			return err
+		}
+		if peer.Status.Phase == vpnv1alpha1.PeerPhaseActive && peer.Status.ClientEndpoint != "" && server.Status.PublicKey != "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("peer was not resolved within %s, phase %q", timeout, peer.Status.Phase)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+
+	psk, err := peerPresharedKey(ctx, s.Client, peer)
+	if err != nil {
+		return err
+	}
+	config, err := brandedClientConfig(ctx, s.Client, privateKey, psk, peer, server)
+	if err != nil {
+		return err
+	}
+	png, err := qrcode.PNG(config)
+	if err != nil {
+		return err
+	}
+	token, err := secretKey(ctx, s.Client, integration.Namespace, *integration.Spec.BotTokenSecretRef, defaultBotTokenKey)
+	if err != nil {
+		return err
+	}
+	slack := &chat.SlackClient{Token: strings.TrimSpace(string(token)), HTTPClient: s.HTTPClient}
+	branding, err := serverBranding(ctx, s.Client, server)
+	if err != nil {
+		return err
+	}
+	org := ""
+	if branding != nil {
+		org = branding.Spec.OrgName + " "
+	}
+	comment := fmt.Sprintf("Your %sVPN device %s: import %s.conf or scan the QR code with the WireGuard app. "+
+		"Its private key is not kept, revoke the device with /vpn revoke %s if the config is lost.", org, name, name, name)
+	if branding != nil && branding.Spec.SupportURL != "" {
+		comment += " Need help? " + branding.Spec.SupportURL
+	}
+	return slack.SendFiles(ctx, command.User, comment, []chat.File{
+		{Name: name + ".conf", Title: name + ".conf", Content: []byte(config)},
+		{Name: name + ".png", Title: name + " QR code", Content: png},
+	})
+}
+
+// revoke deletes a peer of the sender of a command.
+func (s *ChatCommandServer) revoke(ctx context.Context, integration *vpnv1alpha1.VPNChatIntegration, command *chat.Command, name string) (string, error) {
+	devices, err := s.devices(ctx, integration, command.User)
+	if err != nil {
+		return "", err
+	}
+	for i := range devices {
+		if deviceName(integration, command.User, devices[i].Name) != strings.ToLower(name) {
+			continue
+		}
+		if err := s.Delete(ctx, &devices[i]); client.IgnoreNotFound(err) != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Device %s was revoked.", name), nil
+	}
+	return fmt.Sprintf("You have no device named %s, list them with /vpn status.", name), nil
+}
+
+// status lists the peers of the sender of a command with their last
+// handshake.
+func (s *ChatCommandServer) status(ctx context.Context, integration *vpnv1alpha1.VPNChatIntegration, command *chat.Command) (string, error) {
+	devices, err := s.devices(ctx, integration, command.User)
+	if err != nil {
+		return "", err
+	}
+	if len(devices) == 0 {
+		return "You have no devices, create one with /vpn new-device [name].", nil
+	}
+	server := &vpnv1alpha1.VPNServer{}
+	if err := s.Get(ctx, types.NamespacedName{Namespace: integration.Namespace, Name: integration.Spec.ServerRef.Name}, server); client.IgnoreNotFound(err) != nil {
+		return "", err
+	}
+	handshakes := map[string]time.Time{}
+	for _, status := range server.Status.Peers {
+		if status.LatestHandshake != nil {
+			handshakes[status.PublicKey] = status.LatestHandshake.Time
+		}
+	}
+
+	lines := []string{"Your devices:"}
+	for _, peer := range devices {
+		handshake := "never connected"
+		if at, ok := handshakes[peer.Spec.PublicKey]; ok {
+			handshake = "last handshake " + time.Since(at).Round(time.Second).String() + " ago"
+		}
+		lines = append(lines, fmt.Sprintf("• %s: %s, %s, %s", deviceName(integration, command.User, peer.Name), peer.Status.Phase, peer.Spec.Address, handshake))
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	defaultSigningSecretKey = "signing-secret"
+	defaultBotTokenKey      = "bot-token"
+)
+
+// VPNChatIntegrationReconciler checks the references of chat integrations
+// and reports the path their commands are served on by the
+// ChatCommandServer.
+type VPNChatIntegrationReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnchatintegrations,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnchatintegrations/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// Reconcile sets the Ready condition of a chat integration.
+func (r *VPNChatIntegrationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	integration := &vpnv1alpha1.VPNChatIntegration{}
+	if err := r.Get(ctx, req.NamespacedName, integration); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	integration.Status.ObservedGeneration = integration.Generation
+	integration.Status.Path = chatPath(integration)
+
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionReady,
+		Status:             vpnv1alpha1.ConditionTrue,
+		Reason:             "Configured",
+		ObservedGeneration: integration.Generation,
+	}
+	reason, err := r.check(ctx, integration)
+	if err != nil {
+		condition.Status = vpnv1alpha1.ConditionFalse
+		condition.Reason = reason
+		condition.Message = err.Error()
+	}
+	vpnv1alpha1.SetCondition(&integration.Status.Conditions, condition)
+	if err := r.Status().Update(ctx, integration); err != nil {
+		return ctrl.Result{}, err
+	}
+	// Secrets and servers are not watched, missing ones are checked again
+	if condition.Status == vpnv1alpha1.ConditionFalse {
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// check returns the reason and error of the first reference of the
+// integration that cannot be resolved.
+func (r *VPNChatIntegrationReconciler) check(ctx context.Context, integration *vpnv1alpha1.VPNChatIntegration) (string, error) {
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: integration.Namespace, Name: integration.Spec.ServerRef.Name}, server); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "MissingRef", fmt.Errorf("VPNServer %q does not exist", integration.Spec.ServerRef.Name)
+		}
+		return "MissingRef", err
+	}
+	if _, err := secretKey(ctx, r.Client, integration.Namespace, integration.Spec.SigningSecretRef, defaultSigningSecretKey); err != nil {
+		return "MissingSecret", err
+	}
+	if ref := integration.Spec.BotTokenSecretRef; ref != nil {
+		if _, err := secretKey(ctx, r.Client, integration.Namespace, *ref, defaultBotTokenKey); err != nil {
+			return "MissingSecret", err
+		}
+	}
+	return "", nil
+}
+
+// chatPath returns the path the commands of an integration are served on.
+func chatPath(integration *vpnv1alpha1.VPNChatIntegration) string {
+	return fmt.Sprintf("/chat/%s/%s", integration.Namespace, integration.Name)
+}
+
+// secretKey returns the value of a key of a Secret, defaultKey if the
+// reference does not name one.
+func secretKey(ctx context.Context, c client.Reader, namespace string, ref vpnv1alpha1.SecretKeyReference, defaultKey string) ([]byte, error) {
+	key := ref.Key
+	if key == "" {
+		key = defaultKey
+	}
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get secret %q: %w", ref.Name, err)
+	}
+	value, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("secret %q has no key %q", ref.Name, key)
+	}
+	return value, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VPNChatIntegrationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vpnv1alpha1.VPNChatIntegration{}).
+		Complete(instrument(mgr, "vpnchatintegration", &vpnv1alpha1.VPNChatIntegration{}, r))
+}
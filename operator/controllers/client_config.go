@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/envelope"
+)
+
+// renderClientConfig returns the wg-quick configuration of the client of a
+// resolved peer with the given private key and preshared key, if any.
+func renderClientConfig(privateKey, presharedKey string, peer *vpnv1alpha1.VPNPeer, server *vpnv1alpha1.VPNServer) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Interface]\nPrivateKey = %s\nAddress = %s\n", privateKey, peer.Spec.Address)
+	// wg-quick takes search domains on the DNS line, after the servers
+	if dns := clientDNSEntries(peer); len(dns) > 0 {
+		fmt.Fprintf(&b, "DNS = %s\n", strings.Join(dns, ", "))
+	}
+	effective := peer.Status.EffectiveConfig
+	if effective != nil && effective.MTU != 0 {
+		fmt.Fprintf(&b, "MTU = %d\n", effective.MTU)
+	}
+
+	fmt.Fprintf(&b, "\n[Peer]\nPublicKey = %s\n", server.Status.PublicKey)
+	if presharedKey != "" {
+		fmt.Fprintf(&b, "PresharedKey = %s\n", presharedKey)
+	}
+	fmt.Fprintf(&b, "Endpoint = %s\n", peer.Status.ClientEndpoint)
+	// wg-quick takes a single endpoint: the others are listed in failover
+	// order for clients and scripts that switch endpoints
+	if fallbacks := peer.Status.ClientEndpoints; len(fallbacks) > 1 {
+		fmt.Fprintf(&b, "# FallbackEndpoints = %s\n", strings.Join(fallbacks[1:], ", "))
+	}
+	if len(peer.Status.ClientAllowedIPs) > 0 {
+		fmt.Fprintf(&b, "AllowedIPs = %s\n", strings.Join(peer.Status.ClientAllowedIPs, ", "))
+	}
+	if effective != nil && effective.PersistentKeepalive != 0 {
+		fmt.Fprintf(&b, "PersistentKeepalive = %d\n", effective.PersistentKeepalive)
+	}
+	return b.String()
+}
+
+// clientDNSEntries returns the DNS servers and search domains of the client
+// of a peer. Search domains are only pushed along with a DNS server.
+func clientDNSEntries(peer *vpnv1alpha1.VPNPeer) []string {
+	if peer.Status.ClientDNS == "" {
+		return nil
+	}
+	return append(splitList(peer.Status.ClientDNS), peer.Status.ClientSearchDomains...)
+}
+
+// clientConfigSecretData returns the Secret data holding a client config
+// under name. The config is sealed in an envelope, under name with the
+// envelope suffix, when the server has clientConfigEncryption.
+func clientConfigSecretData(ctx context.Context, server *vpnv1alpha1.VPNServer, name, config string) (map[string][]byte, error) {
+	encryption := server.Spec.ClientConfigEncryption
+	if encryption == nil {
+		return map[string][]byte{name: []byte(config)}, nil
+	}
+	provider := string(encryption.Provider)
+	wrapper, err := envelope.NewWrapper(provider, encryption.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := envelope.Seal(ctx, provider, encryption.KeyID, wrapper, []byte(config))
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{name + envelope.Suffix: sealed}, nil
+}
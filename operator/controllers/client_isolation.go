@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// isolationConfigKey is the key of the rendered config ConfigMap holding
+// the isolation exceptions read by the agent
+const isolationConfigKey = "isolation"
+
+// renderIsolationExceptions renders the isolation exceptions of a server
+// for the agent: one line per exception group, holding the group name and
+// the tunnel addresses of its peers. Groups without peers are left out.
+func renderIsolationExceptions(server *vpnv1alpha1.VPNServer, peers []vpnv1alpha1.VPNPeer) string {
+	if !server.Spec.ClientIsolation {
+		return ""
+	}
+
+	members := map[string][]string{}
+	for _, peer := range peers {
+		if peer.Spec.ServerRef.Name != server.Name || peer.Spec.Group == "" || !peer.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if address := hostCIDR(peer.Spec.Address); address != "" {
+			members[peer.Spec.Group] = append(members[peer.Spec.Group], address)
+		}
+	}
+
+	groups := append([]string(nil), server.Spec.IsolationExceptions...)
+	sort.Strings(groups)
+	var b strings.Builder
+	for _, group := range groups {
+		addresses := members[group]
+		if len(addresses) == 0 {
+			continue
+		}
+		sort.Strings(addresses)
+		fmt.Fprintf(&b, "%s %s\n", group, strings.Join(addresses, " "))
+	}
+	return b.String()
+}
+
+// hostCIDR returns the host route of a peer address given with or without
+// a prefix length, or an empty string if it is not an address.
+func hostCIDR(address string) string {
+	ip := net.ParseIP(strings.SplitN(strings.TrimSpace(address), "/", 2)[0])
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return ip.String() + "/32"
+	default:
+		return ip.String() + "/128"
+	}
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// Sources of the client info of peers
+const (
+	clientInfoSourceEnrollment = "Enrollment"
+	clientInfoSourceAgent      = "Agent"
+)
+
+// clientVersionPattern matches the versions compareVersions compares
+var clientVersionPattern = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+)*([-+ ].*)?$`)
+
+// clientReport is the implementation and version a client reports with its
+// enrollment or posture re-validations
+type clientReport struct {
+	// Implementation is the client implementation, e.g. wireflow
+	Implementation string `json:"implementation"`
+
+	// Version is the version of the client
+	Version string `json:"version"`
+}
+
+// clientInfo returns the client info of a report, or, without one, of the
+// first product of a User-Agent such as wireflow/1.4.2. Browsers are not
+// clients, and are ignored.
+func clientInfo(report *clientReport, userAgent, source string) *vpnv1alpha1.ClientInfo {
+	var implementation, version string
+	if report != nil {
+		implementation, version = report.Implementation, report.Version
+	} else if fields := strings.Fields(userAgent); len(fields) > 0 {
+		implementation, version, _ = strings.Cut(fields[0], "/")
+		if strings.EqualFold(implementation, "Mozilla") {
+			return nil
+		}
+	}
+	if implementation == "" && version == "" {
+		return nil
+	}
+	now := metav1.Now()
+	return &vpnv1alpha1.ClientInfo{
+		Implementation: truncate(implementation, 64),
+		Version:        truncate(version, 64),
+		Source:         source,
+		ReportedAt:     &now,
+	}
+}
+
+// truncate returns the first n bytes of s.
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// compareVersions compares two dotted versions such as v1.4.2 numerically,
+// ignoring pre-release and build suffixes. Missing components are zero.
+func compareVersions(a, b string) int {
+	parse := func(version string) []int {
+		version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+		if i := strings.IndexAny(version, "-+ "); i >= 0 {
+			version = version[:i]
+		}
+		var parts []int
+		for _, part := range strings.Split(version, ".") {
+			n, _ := strconv.Atoi(part)
+			parts = append(parts, n)
+		}
+		return parts
+	}
+	x, y := parse(a), parse(b)
+	for i := 0; i < len(x) || i < len(y); i++ {
+		var m, n int
+		if i < len(x) {
+			m = x[i]
+		}
+		if i < len(y) {
+			n = y[i]
+		}
+		if m != n {
+			if m < n {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// clientOutdated returns whether a client is below the minimum version of
+// a policy. Clients of other implementations, or that report no version or
+// one that is not dotted numbers such as dev builds, are not outdated.
+func clientOutdated(policy *vpnv1alpha1.ClientVersionPolicy, info *vpnv1alpha1.ClientInfo) bool {
+	if policy == nil || info == nil || !clientVersionPattern.MatchString(info.Version) {
+		return false
+	}
+	if policy.Implementation != "" && !strings.EqualFold(policy.Implementation, info.Implementation) {
+		return false
+	}
+	return compareVersions(info.Version, policy.MinimumVersion) < 0
+}
+
+// ClientVersionReconciler flags peers whose clients reported a version
+// below the minimum client version of their server. The versions are
+// recorded by the EnrollmentServer and the PostureServer.
+type ClientVersionReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile sets the ClientOutdated condition of a peer.
+func (r *ClientVersionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	peer := &vpnv1alpha1.VPNPeer{}
+	if err := r.Get(ctx, req.NamespacedName, peer); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !peer.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: peer.Namespace, Name: peer.Spec.ServerRef.Name}, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	original := peer.DeepCopy()
+	policy := server.Spec.ClientVersionPolicy
+	info := peer.Status.Client
+	if policy == nil || info == nil {
+		vpnv1alpha1.RemoveCondition(&peer.Status.Conditions, vpnv1alpha1.ConditionClientOutdated)
+	} else {
+		condition := vpnv1alpha1.Condition{
+			Type:               vpnv1alpha1.ConditionClientOutdated,
+			Status:             vpnv1alpha1.ConditionFalse,
+			Reason:             "VersionSupported",
+			ObservedGeneration: peer.Generation,
+		}
+		if clientOutdated(policy, info) {
+			condition.Status = vpnv1alpha1.ConditionTrue
+			condition.Reason = "BelowMinimumVersion"
+			condition.Message = fmt.Sprintf("The client %s %s is below the minimum version %s of server %s",
+				info.Implementation, info.Version, policy.MinimumVersion, server.Name)
+		}
+		vpnv1alpha1.SetCondition(&peer.Status.Conditions, condition)
+	}
+	if equality.Semantic.DeepEqual(original.Status, peer.Status) {
+		return ctrl.Result{}, nil
+	}
+	if err := r.Status().Patch(ctx, peer, client.MergeFrom(original)); err != nil {
+		return ctrl.Result{}, err
+	}
+	if vpnv1alpha1.IsConditionTrue(peer.Status.Conditions, vpnv1alpha1.ConditionClientOutdated) &&
+		!vpnv1alpha1.IsConditionTrue(original.Status.Conditions, vpnv1alpha1.ConditionClientOutdated) {
+		condition := vpnv1alpha1.FindCondition(peer.Status.Conditions, vpnv1alpha1.ConditionClientOutdated)
+		r.Recorder.Event(peer, corev1.EventTypeWarning, "ClientOutdated", condition.Message)
+	}
+	return ctrl.Result{}, nil
+}
+
+// peersForServer maps a VPNServer to the peers referencing it.
+func (r *ClientVersionReconciler) peersForServer(ctx context.Context, obj client.Object) []reconcile.Request {
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(obj.GetNamespace()), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: obj.GetName()}); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(peers.Items))
+	for _, peer := range peers.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&peer)})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClientVersionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("clientversion").
+		For(&vpnv1alpha1.VPNPeer{}).
+		Watches(&vpnv1alpha1.VPNServer{}, handler.EnqueueRequestsFromMapFunc(r.peersForServer)).
+		Complete(instrument(mgr, "clientversion", &vpnv1alpha1.VPNPeer{}, r))
+}
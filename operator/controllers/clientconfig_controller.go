@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// clientConfigKey is the key of the wg-quick config in client config
+// Secrets, so that mounting the Secret on /etc/wireguard brings up wg0
+const clientConfigKey = "wg0.conf"
+
+// ClientConfigReconciler renders the wg-quick config of the client of every
+// active peer into a Secret owned by the peer, so that platform teams can
+// mount or distribute it. The operator never sees the private keys of
+// clients, so the PrivateKey line is left for the client to fill in.
+type ClientConfigReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnbrandings,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile renders the client config Secret of a peer, or deletes it
+// while the peer is not active.
+func (r *ClientConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	peer := &vpnv1alpha1.VPNPeer{}
+	if err := r.Get(ctx, req.NamespacedName, peer); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !peer.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	original := peer.DeepCopy()
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: peer.Namespace, Name: peer.Name + "-client-config"}}
+
+	server := &vpnv1alpha1.VPNServer{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: peer.Namespace, Name: peer.Spec.ServerRef.Name}, server)
+	if client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+	if err != nil || peer.Status.Phase != vpnv1alpha1.PeerPhaseActive ||
+		peer.Status.ClientEndpoint == "" || server.Status.PublicKey == "" {
+		// Suspended peers lose their address, so their config would not
+		// connect anymore
+		if err := r.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+		peer.Status.ClientConfigSecretName = ""
+		return ctrl.Result{}, r.patchStatus(ctx, peer, original)
+	}
+
+	psk, err := peerPresharedKey(ctx, r.Client, peer)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// The preshared key is generated, or its Secret created, next
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	rendered := observePhase(ctx, reconcilePhaseRender)
+	config, err := brandedClientConfig(ctx, r.Client, "", psk, peer, server)
+	rendered()
+	if err != nil {
+		r.Recorder.Event(peer, corev1.EventTypeWarning, "ClientConfigFailed", err.Error())
+		return ctrl.Result{}, err
+	}
+	sum := sha256.Sum256([]byte(config))
+	checksum := hex.EncodeToString(sum[:])
+
+	written := observePhase(ctx, reconcilePhaseSecretWrite)
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		// Sealed configs differ on every seal, so they are only sealed
+		// again when the config changed
+		if secret.Annotations[vpnv1alpha1.ClientConfigChecksumAnnotation] != checksum {
+			data, err := clientConfigSecretData(ctx, server, clientConfigKey, config)
+			if err != nil {
+				return err
+			}
+			secret.Data = data
+			if secret.Annotations == nil {
+				secret.Annotations = map[string]string{}
+			}
+			secret.Annotations[vpnv1alpha1.ClientConfigChecksumAnnotation] = checksum
+		}
+		if secret.Labels == nil {
+			secret.Labels = map[string]string{}
+		}
+		for key, value := range serverLabels(server) {
+			secret.Labels[key] = value
+		}
+		secret.Type = corev1.SecretTypeOpaque
+		return controllerutil.SetControllerReference(peer, secret, r.Scheme)
+	})
+	written()
+	if err != nil {
+		r.Recorder.Event(peer, corev1.EventTypeWarning, "ClientConfigFailed", err.Error())
+		return ctrl.Result{}, err
+	}
+	if result != controllerutil.OperationResultNone {
+		logger.Info("rendered client config", "secret", secret.Name, "operation", result)
+	}
+	peer.Status.ClientConfigSecretName = secret.Name
+	return ctrl.Result{}, r.patchStatus(ctx, peer, original)
+}
+
+// patchStatus patches the status of a peer if it changed.
+func (r *ClientConfigReconciler) patchStatus(ctx context.Context, peer, original *vpnv1alpha1.VPNPeer) error {
+	if equality.Semantic.DeepEqual(original.Status, peer.Status) {
+		return nil
+	}
+	return r.Status().Patch(ctx, peer, client.MergeFrom(original))
+}
+
+// peersForServer maps a VPNServer to the peers referencing it.
+func (r *ClientConfigReconciler) peersForServer(ctx context.Context, obj client.Object) []reconcile.Request {
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(obj.GetNamespace()), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: obj.GetName()}); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list peers of server", "server", obj.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(peers.Items))
+	for _, peer := range peers.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&peer)})
+	}
+	return requests
+}
+
+// peersForSecret maps a Secret to the peers whose preshared key it holds.
+func (r *ClientConfigReconciler) peersForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, peer := range peers.Items {
+		if ref := presharedKeyRef(&peer); ref != nil && ref.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&peer)})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClientConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("clientconfig").
+		For(&vpnv1alpha1.VPNPeer{}).
+		Owns(&corev1.Secret{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.peersForSecret)).
+		Watches(&vpnv1alpha1.VPNServer{}, handler.EnqueueRequestsFromMapFunc(r.peersForServer)).
+		Complete(instrument(mgr, "clientconfig", &vpnv1alpha1.VPNPeer{}, r))
+}
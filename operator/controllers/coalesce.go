@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// peerDeletionWindow is the window over which deletions of the peers of a
+// server are coalesced into a single reconcile
+const peerDeletionWindow = 2 * time.Second
+
+// peerDeletionCoalescer maps VPNPeer events to the server of the peer, like
+// serverForPeer, but coalesces bursts of deletions, as when a namespace with
+// many peers is deleted. The first deletion of a burst is reconciled right
+// away; later ones are deferred to the end of the window, where the delaying
+// queue collapses them into one reconcile, so that the server is
+// reconfigured and its status written once per window instead of once per
+// peer.
+type peerDeletionCoalescer struct {
+	handler.EventHandler
+
+	mu sync.Mutex
+	// bursts holds when the current burst of deletions of each server
+	// started
+	bursts map[types.NamespacedName]time.Time
+}
+
+// serverForPeerCoalesced returns a handler mapping VPNPeer events to the
+// server of the peer, coalescing bursts of deletions. Each controller needs
+// its own handler.
+func serverForPeerCoalesced() handler.EventHandler {
+	return &peerDeletionCoalescer{
+		EventHandler: handler.EnqueueRequestsFromMapFunc(serverForPeer),
+		bursts:       map[types.NamespacedName]time.Time{},
+	}
+}
+
+// Delete implements handler.EventHandler.
+func (c *peerDeletionCoalescer) Delete(ctx context.Context, evt event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	if evt.Object == nil {
+		return
+	}
+	now := time.Now()
+	for _, req := range serverForPeer(ctx, evt.Object) {
+		if delay := c.delay(req.NamespacedName, now); delay > 0 {
+			q.AddAfter(req, delay)
+		} else {
+			q.Add(req)
+		}
+	}
+}
+
+// delay returns how long a deletion of a peer of server is deferred: not at
+// all when it starts a burst, else until the end of the burst's window.
+func (c *peerDeletionCoalescer) delay(server types.NamespacedName, now time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, started := range c.bursts {
+		if now.Sub(started) >= peerDeletionWindow {
+			delete(c.bursts, key)
+		}
+	}
+	started, ok := c.bursts[server]
+	if !ok {
+		c.bursts[server] = now
+		return 0
+	}
+	return started.Add(peerDeletionWindow).Sub(now)
+}
+
+var _ handler.EventHandler = &peerDeletionCoalescer{}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// configChecksum returns the checksum of a configuration, the hex encoded
+// SHA-256 of its contents.
+func configChecksum(config []byte) string {
+	sum := sha256.Sum256(config)
+	return hex.EncodeToString(sum[:])
+}
+
+// renderedChecksum returns the checksum of the files rendered for a server,
+// the keys of its config Secret and ConfigMap projected into one directory.
+// The files are listed with their checksums in the order of their names, as
+// sha256sum prints them, and the listing is hashed, which lets the agent
+// verify all of them before loading any.
+func renderedChecksum(files map[string][]byte) string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var listing strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&listing, "%s  %s\n", configChecksum(files[name]), name)
+	}
+	return configChecksum([]byte(listing.String()))
+}
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;patch
+
+// propagateConfigChecksum annotates the running server pods with the desired
+// config checksum. The annotation is set on the pods rather than on the pod
+// template so the agent reloads the device in place instead of the
+// Deployment rolling the pods.
+func propagateConfigChecksum(ctx context.Context, c client.Client, pods []corev1.Pod, checksum string) error {
+	defer observePhase(ctx, reconcilePhaseDeviceApply)()
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Annotations[vpnv1alpha1.ConfigChecksumAnnotation] == checksum {
+			continue
+		}
+
+		patch := client.MergeFrom(pod.DeepCopy())
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[vpnv1alpha1.ConfigChecksumAnnotation] = checksum
+		if err := c.Patch(ctx, pod, patch); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appliedConfigChecksum returns the checksum reported by the agents of all
+// ready pods, or an empty string when they have not converged on the same
+// configuration yet.
+func appliedConfigChecksum(pods []corev1.Pod) string {
+	applied := ""
+	for _, pod := range pods {
+		if !isPodReady(&pod) {
+			continue
+		}
+		checksum := pod.Annotations[vpnv1alpha1.AppliedConfigChecksumAnnotation]
+		if checksum == "" || (applied != "" && applied != checksum) {
+			return ""
+		}
+		applied = checksum
+	}
+	return applied
+}
+
+// isPodReady returns whether the pod has a true Ready condition.
+func isPodReady(pod *corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// configLevel is a level of the configuration hierarchy and the settings it
+// defines
+type configLevel struct {
+	source string
+	layer  vpnv1alpha1.ConfigLayer
+}
+
+// fleetConfigSource is the source of settings from the fleet defaults
+const fleetConfigSource = "fleet"
+
+// mobileKeepalive is the keepalive interval of peers with the mobile power
+// profile, long enough for the radio of the device to sleep in between
+const mobileKeepalive int32 = 120
+
+// mergeConfig merges the levels of the configuration hierarchy, given from
+// the least to the most specific, field by field.
+func mergeConfig(levels []configLevel) *vpnv1alpha1.EffectiveConfig {
+	effective := &vpnv1alpha1.EffectiveConfig{Sources: map[string]string{}}
+	for _, level := range levels {
+		if level.layer.DNS != "" {
+			effective.DNS = level.layer.DNS
+			effective.Sources["dns"] = level.source
+		}
+		if level.layer.PersistentKeepalive != nil {
+			effective.PersistentKeepalive = *level.layer.PersistentKeepalive
+			effective.Sources["persistentKeepalive"] = level.source
+		}
+		if level.layer.MTU != nil {
+			effective.MTU = *level.layer.MTU
+			effective.Sources["mtu"] = level.source
+		}
+	}
+	if len(effective.Sources) == 0 {
+		effective.Sources = nil
+	}
+	return effective
+}
+
+// configLevels returns the levels of the configuration hierarchy of a peer,
+// in the merge order documented on ConfigLayer. A class or group that does
+// not exist contributes no settings.
+func (r *VPNPeerReconciler) configLevels(ctx context.Context, server *vpnv1alpha1.VPNServer, peer *vpnv1alpha1.VPNPeer) ([]configLevel, error) {
+	levels := []configLevel{{source: fleetConfigSource, layer: r.FleetDefaults}}
+
+	if name := server.Spec.ClassName; name != "" {
+		class := &vpnv1alpha1.VPNServerClass{}
+		err := r.Get(ctx, types.NamespacedName{Name: name}, class)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		if err == nil {
+			levels = append(levels, configLevel{source: "VPNServerClass/" + name, layer: class.Spec.ConfigLayer})
+		}
+	}
+
+	levels = append(levels, configLevel{
+		source: "VPNServer/" + server.Name,
+		layer: vpnv1alpha1.ConfigLayer{
+			DNS:                 server.Spec.DNS,
+			PersistentKeepalive: server.Spec.PersistentKeepalive,
+			MTU:                 server.Spec.MTU,
+		},
+	})
+	if profile := server.Spec.RoutingProfile(peer.Spec.RoutingProfile); profile != nil {
+		levels = append(levels, configLevel{
+			source: "ClientRoutingProfile/" + profile.Name,
+			layer:  vpnv1alpha1.ConfigLayer{DNS: profileDNS(server, profile)},
+		})
+	}
+
+	if name := peer.Spec.Group; name != "" {
+		group := &vpnv1alpha1.VPNPeerGroup{}
+		err := r.Get(ctx, types.NamespacedName{Namespace: peer.Namespace, Name: name}, group)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		if err == nil {
+			levels = append(levels, configLevel{source: "VPNPeerGroup/" + name, layer: group.Spec.ConfigLayer})
+		}
+	}
+
+	if peer.Spec.PowerProfile == vpnv1alpha1.PowerProfileMobile {
+		keepalive := mobileKeepalive
+		levels = append(levels, configLevel{
+			source: "PowerProfile/" + string(peer.Spec.PowerProfile),
+			layer:  vpnv1alpha1.ConfigLayer{PersistentKeepalive: &keepalive},
+		})
+	}
+
+	own := vpnv1alpha1.ConfigLayer{DNS: peer.Spec.DNS, MTU: peer.Spec.MTU}
+	if peer.Spec.PersistentKeepalive != 0 {
+		keepalive := peer.Spec.PersistentKeepalive
+		own.PersistentKeepalive = &keepalive
+	}
+	return append(levels, configLevel{source: "VPNPeer/" + peer.Name, layer: own}), nil
+}
+
+// groupTunnel returns the tunnel of the group of a peer, or nil if it has
+// no group, the group does not exist or sets no tunnel.
+func (r *VPNPeerReconciler) groupTunnel(ctx context.Context, peer *vpnv1alpha1.VPNPeer) (*vpnv1alpha1.GroupTunnel, error) {
+	if peer.Spec.Group == "" {
+		return nil, nil
+	}
+	group := &vpnv1alpha1.VPNPeerGroup{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: peer.Namespace, Name: peer.Spec.Group}, group); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	return group.Spec.Tunnel, nil
+}
+
+// peersForGroup maps a VPNPeerGroup to the peers of the group.
+func (r *VPNPeerReconciler) peersForGroup(ctx context.Context, obj client.Object) []reconcile.Request {
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list peers of group", "group", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, peer := range peers.Items {
+		if peer.Spec.Group == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&peer)})
+		}
+	}
+	return requests
+}
+
+// peersForClass maps a VPNServerClass to the peers of the servers of the
+// class.
+func (r *VPNPeerReconciler) peersForClass(ctx context.Context, obj client.Object) []reconcile.Request {
+	servers := &vpnv1alpha1.VPNServerList{}
+	if err := r.List(ctx, servers); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list servers of class", "class", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range servers.Items {
+		if servers.Items[i].Spec.ClassName == obj.GetName() {
+			requests = append(requests, r.peersForServer(ctx, &servers.Items[i])...)
+		}
+	}
+	return requests
+}
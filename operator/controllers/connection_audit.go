@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	// defaultSessionRetention is how long the VPNSessions of ended sessions
+	// are kept when the retention is not set
+	defaultSessionRetention = 90 * 24 * time.Hour
+
+	// sessionPruneInterval is how often ended VPNSessions past their
+	// retention are deleted
+	sessionPruneInterval = time.Hour
+)
+
+// ConnectionAuditReconciler records peers connecting to and disconnecting
+// from servers with spec.compliance.connectionAudit. A session starts with
+// the first fresh handshake of a peer and ends when its latest handshake
+// expires. The sessions in progress are kept in the status of the server,
+// so that operator restarts neither repeat nor lose them.
+type ConnectionAuditReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnsessions,verbs=get;list;watch;create;update;patch;delete;deletecollection
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile compares the fresh handshakes in the status of a server with
+// its sessions in progress, and records the sessions that started or
+// ended.
+func (r *ConnectionAuditReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	var sinks []vpnv1alpha1.ConnectionAuditSink
+	if compliance := server.Spec.Compliance; compliance != nil {
+		sinks = compliance.ConnectionAudit
+	}
+	original := server.DeepCopy()
+	if len(sinks) == 0 || !server.DeletionTimestamp.IsZero() {
+		server.Status.Sessions = nil
+		return ctrl.Result{}, r.patchStatus(ctx, server, original)
+	}
+
+	now := time.Now()
+	observed := map[string]vpnv1alpha1.PeerStatus{}
+	for _, status := range server.Status.Peers {
+		observed[status.PublicKey] = status
+	}
+	var sessions []vpnv1alpha1.PeerSession
+	var requeue time.Duration
+	expiring := func(handshake time.Time) {
+		if expires := handshake.Add(handshakeFreshness).Sub(now); requeue == 0 || expires < requeue {
+			requeue = expires
+		}
+	}
+
+	open := map[string]bool{}
+	for _, session := range server.Status.Sessions {
+		open[session.PublicKey] = true
+		status, ok := observed[session.PublicKey]
+		if ok && status.LatestHandshake != nil && now.Sub(status.LatestHandshake.Time) <= handshakeFreshness {
+			sessions = append(sessions, session)
+			expiring(status.LatestHandshake.Time)
+			continue
+		}
+		// Peers that are gone, e.g. deleted, end their session now
+		end := now
+		if ok && status.LatestHandshake != nil && !status.LatestHandshake.Before(&session.StartTime) {
+			end = status.LatestHandshake.Add(handshakeFreshness)
+		}
+		if err := r.recordEnd(ctx, server, sinks, session, end); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	for _, status := range server.Status.Peers {
+		if open[status.PublicKey] || status.LatestHandshake == nil || now.Sub(status.LatestHandshake.Time) > handshakeFreshness {
+			continue
+		}
+		session := vpnv1alpha1.PeerSession{
+			Name:      status.Name,
+			PublicKey: status.PublicKey,
+			Endpoint:  status.Endpoint,
+			StartTime: *status.LatestHandshake,
+		}
+		if err := r.recordStart(ctx, server, sinks, session); err != nil {
+			return ctrl.Result{}, err
+		}
+		sessions = append(sessions, session)
+		expiring(status.LatestHandshake.Time)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Name < sessions[j].Name })
+	server.Status.Sessions = sessions
+	if err := r.patchStatus(ctx, server, original); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if hasSink(sinks, vpnv1alpha1.ConnectionAuditSession) {
+		if err := r.pruneSessions(ctx, server, now); err != nil {
+			return ctrl.Result{}, err
+		}
+		if requeue == 0 || requeue > sessionPruneInterval {
+			requeue = sessionPruneInterval
+		}
+	}
+	if requeue < 0 {
+		requeue = time.Second
+	}
+	return ctrl.Result{RequeueAfter: requeue}, nil
+}
+
+// recordStart records the start of a session to the sinks.
+func (r *ConnectionAuditReconciler) recordStart(ctx context.Context, server *vpnv1alpha1.VPNServer, sinks []vpnv1alpha1.ConnectionAuditSink, session vpnv1alpha1.PeerSession) error {
+	if hasSink(sinks, vpnv1alpha1.ConnectionAuditLog) {
+		log.FromContext(ctx).WithName("connection-audit").Info("peer connected", "peer", session.Name,
+			"publicKey", session.PublicKey, "endpoint", session.Endpoint, "time", session.StartTime.UTC().Format(time.RFC3339))
+	}
+	if hasSink(sinks, vpnv1alpha1.ConnectionAuditEvent) {
+		r.Recorder.Eventf(server, corev1.EventTypeNormal, "PeerConnected", "Peer %s connected from %s at %s",
+			session.Name, session.Endpoint, session.StartTime.UTC().Format(time.RFC3339))
+	}
+	if !hasSink(sinks, vpnv1alpha1.ConnectionAuditSession) {
+		return nil
+	}
+	vpnSession := &vpnv1alpha1.VPNSession{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: server.Namespace,
+			Name:      sessionName(server, session),
+			Labels:    serverLabels(server),
+		},
+		Spec: vpnv1alpha1.VPNSessionSpec{
+			ServerRef: vpnv1alpha1.LocalObjectReference{Name: server.Name},
+			PeerRef:   vpnv1alpha1.LocalObjectReference{Name: session.Name},
+			PublicKey: session.PublicKey,
+			Endpoint:  session.Endpoint,
+			StartTime: session.StartTime,
+		},
+	}
+	// A session already written before a failed status update is kept
+	if err := r.Create(ctx, vpnSession); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// recordEnd records the end of a session to the sinks.
+func (r *ConnectionAuditReconciler) recordEnd(ctx context.Context, server *vpnv1alpha1.VPNServer, sinks []vpnv1alpha1.ConnectionAuditSink, session vpnv1alpha1.PeerSession, end time.Time) error {
+	duration := end.Sub(session.StartTime.Time).Round(time.Second)
+	if hasSink(sinks, vpnv1alpha1.ConnectionAuditLog) {
+		log.FromContext(ctx).WithName("connection-audit").Info("peer disconnected", "peer", session.Name,
+			"publicKey", session.PublicKey, "endpoint", session.Endpoint, "time", end.UTC().Format(time.RFC3339),
+			"duration", duration.String())
+	}
+	if hasSink(sinks, vpnv1alpha1.ConnectionAuditEvent) {
+		r.Recorder.Eventf(server, corev1.EventTypeNormal, "PeerDisconnected", "Peer %s disconnected at %s after %s",
+			session.Name, end.UTC().Format(time.RFC3339), duration)
+	}
+	if !hasSink(sinks, vpnv1alpha1.ConnectionAuditSession) {
+		return nil
+	}
+	vpnSession := &vpnv1alpha1.VPNSession{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: server.Namespace, Name: sessionName(server, session)}, vpnSession)
+	if apierrors.IsNotFound(err) {
+		// Sessions started before the sink was enabled have no record
+		return nil
+	}
+	if err != nil || vpnSession.Spec.EndTime != nil {
+		return err
+	}
+	patch := client.MergeFrom(vpnSession.DeepCopy())
+	endTime := metav1.NewTime(end)
+	vpnSession.Spec.EndTime = &endTime
+	return r.Patch(ctx, vpnSession, patch)
+}
+
+// pruneSessions deletes the VPNSessions of a server that ended longer than
+// the retention ago.
+func (r *ConnectionAuditReconciler) pruneSessions(ctx context.Context, server *vpnv1alpha1.VPNServer, now time.Time) error {
+	retention := defaultSessionRetention
+	if server.Spec.Compliance.SessionRetention != nil {
+		retention = server.Spec.Compliance.SessionRetention.Duration
+	}
+	sessions := &vpnv1alpha1.VPNSessionList{}
+	if err := r.List(ctx, sessions, client.InNamespace(server.Namespace), client.MatchingLabels(serverLabels(server))); err != nil {
+		return err
+	}
+	for i := range sessions.Items {
+		session := &sessions.Items[i]
+		if session.Spec.EndTime == nil || now.Sub(session.Spec.EndTime.Time) < retention {
+			continue
+		}
+		if err := r.Delete(ctx, session); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchStatus patches the status of a server if it changed.
+func (r *ConnectionAuditReconciler) patchStatus(ctx context.Context, server, original *vpnv1alpha1.VPNServer) error {
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return nil
+	}
+	return patchServerStatus(ctx, r.Client, server, original)
+}
+
+// sessionName returns the name of the VPNSession of a session, unique per
+// peer and start.
+func sessionName(server *vpnv1alpha1.VPNServer, session vpnv1alpha1.PeerSession) string {
+	return fmt.Sprintf("%s-%s-%d", server.Name, session.Name, session.StartTime.Unix())
+}
+
+// hasSink returns whether sinks lists sink.
+func hasSink(sinks []vpnv1alpha1.ConnectionAuditSink, sink vpnv1alpha1.ConnectionAuditSink) bool {
+	for _, s := range sinks {
+		if s == sink {
+			return true
+		}
+	}
+	return false
+}
+
+// SetupWithManager sets up the controller with the Manager. Servers are
+// reconciled on status changes too, as the handshakes of their peers are
+// in their status.
+func (r *ConnectionAuditReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("connectionaudit").
+		For(&vpnv1alpha1.VPNServer{}).
+		Complete(instrument(mgr, "connectionaudit", &vpnv1alpha1.VPNServer{}, r))
+}
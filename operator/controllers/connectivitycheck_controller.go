@@ -0,0 +1,524 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	// Defaults of the checks that do not set their thresholds or sampling
+	defaultProbePings      = 10
+	defaultProbeMaxLatency = 200 * time.Millisecond
+	defaultProbeMaxLoss    = 5
+	defaultSoakInterval    = 30 * time.Second
+	defaultSoakWindow      = 60
+	defaultSoakThreshold   = 3
+
+	// probeStaleIntervals is after how many soak intervals without a sample
+	// the probe of a soaking check is no longer ready
+	probeStaleIntervals = 3
+)
+
+// probeSample is a sample reported by the probe of a check
+type probeSample struct {
+	Time    int64    `json:"time"`
+	Latency *float64 `json:"latency"`
+	Loss    int32    `json:"loss"`
+}
+
+// ConnectivityCheckReconciler runs VPNConnectivityChecks. The probe of a
+// check is a VPNPeer of the checked server, addressed by IPAM, and a
+// Deployment whose pod connects as that peer with a key it generates and
+// publishes on its pod. The pod pings the tunnel address of the server and
+// pushes each sample on its pod, from where it is recorded in the status.
+//
+// A check of the Once mode takes a single sample and removes its probe. A
+// soaking check keeps it connected and records a rolling window of samples.
+// Its Degraded condition only flips once soak.threshold samples in a row
+// breach the thresholds, or are healthy again, so that a single lost sample
+// does not flap it.
+type ConnectivityCheckReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnconnectivitychecks,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnconnectivitychecks/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile applies the probe of a check and records the samples it
+// reported.
+func (r *ConnectivityCheckReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	check := &vpnv1alpha1.VPNConnectivityCheck{}
+	if err := r.Get(ctx, req.NamespacedName, check); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !check.DeletionTimestamp.IsZero() {
+		// The probe is owned by the check
+		return ctrl.Result{}, nil
+	}
+	original := check.DeepCopy()
+	check.Status.ObservedGeneration = check.Generation
+	soak := check.Spec.Mode == vpnv1alpha1.ConnectivityCheckSoak
+	if soak {
+		check.Status.CompletionTime = nil
+	}
+
+	result, err := r.reconcileProbe(ctx, check)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	r.setDegradedCondition(check)
+
+	if !soak && check.Status.CompletionTime == nil && len(check.Status.Samples) > 0 {
+		now := metav1.Now()
+		check.Status.CompletionTime = &now
+	}
+	if !soak && check.Status.CompletionTime != nil {
+		if err := r.removeProbe(ctx, check); err != nil {
+			return ctrl.Result{}, err
+		}
+		check.Status.ProbePeer = ""
+		setCheckReady(check, vpnv1alpha1.ConditionTrue, "Completed", "The sample was taken and the probe removed")
+		result = ctrl.Result{}
+	}
+
+	if !equality.Semantic.DeepEqual(original.Status, check.Status) {
+		if err := r.Status().Patch(ctx, check, client.MergeFrom(original)); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return result, nil
+}
+
+// reconcileProbe applies the probe peer and Deployment of a check and
+// records the samples of its pods.
+func (r *ConnectivityCheckReconciler) reconcileProbe(ctx context.Context, check *vpnv1alpha1.VPNConnectivityCheck) (ctrl.Result, error) {
+	if check.Status.CompletionTime != nil {
+		return ctrl.Result{}, nil
+	}
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: check.Namespace, Name: check.Spec.ServerRef.Name}, server); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		setCheckReady(check, vpnv1alpha1.ConditionFalse, "ServerNotFound",
+			fmt.Sprintf("VPNServer %s does not exist", check.Spec.ServerRef.Name))
+		return ctrl.Result{}, nil
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(check.Namespace), client.MatchingLabels(probeLabels(check))); err != nil {
+		return ctrl.Result{}, err
+	}
+	publicKey, samples := probeReports(ctx, pods.Items)
+
+	peer, err := r.applyProbePeer(ctx, check, server, publicKey)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	check.Status.ProbePeer = peer.Name
+	if peer.Spec.Address == "" {
+		setCheckReady(check, vpnv1alpha1.ConditionFalse, "WaitingForAddress", "Waiting for the probe peer to be allocated an address")
+		return ctrl.Result{}, nil
+	}
+	if server.Status.PublicKey == "" || server.Status.Endpoint == "" {
+		setCheckReady(check, vpnv1alpha1.ConditionFalse, "WaitingForServer", "Waiting for the server to publish its public key and endpoint")
+		return ctrl.Result{}, nil
+	}
+	if err := r.applyProbe(ctx, check, server, peer); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	recordSamples(check, samples)
+	n := len(check.Status.Samples)
+	if n == 0 {
+		setCheckReady(check, vpnv1alpha1.ConditionFalse, "WaitingForProbe", "Waiting for the probe to connect and report a sample")
+		return ctrl.Result{}, nil
+	}
+	interval := soakInterval(check)
+	if age := time.Since(check.Status.Samples[n-1].Time.Time); age > probeStaleIntervals*interval {
+		setCheckReady(check, vpnv1alpha1.ConditionFalse, "ProbeStale",
+			fmt.Sprintf("The probe reported no sample for %s", age.Round(time.Second)))
+		return ctrl.Result{RequeueAfter: interval}, nil
+	}
+	setCheckReady(check, vpnv1alpha1.ConditionTrue, "Probing", fmt.Sprintf("%d samples in the window", n))
+	return ctrl.Result{RequeueAfter: probeStaleIntervals * interval}, nil
+}
+
+// applyProbePeer creates or updates the VPNPeer the probe of a check
+// connects as. It has no key until the probe published one.
+func (r *ConnectivityCheckReconciler) applyProbePeer(ctx context.Context, check *vpnv1alpha1.VPNConnectivityCheck, server *vpnv1alpha1.VPNServer, publicKey string) (*vpnv1alpha1.VPNPeer, error) {
+	peer := &vpnv1alpha1.VPNPeer{ObjectMeta: metav1.ObjectMeta{Namespace: check.Namespace, Name: probeName(check)}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, peer, func() error {
+		if owner, ok := peer.Labels[vpnv1alpha1.ConnectivityCheckLabel]; peer.ResourceVersion != "" && (!ok || owner != check.Name) {
+			return fmt.Errorf("VPNPeer %s/%s exists and is not the probe of connectivity check %s", peer.Namespace, peer.Name, check.Name)
+		}
+		if peer.Labels == nil {
+			peer.Labels = map[string]string{}
+		}
+		peer.Labels[vpnv1alpha1.ConnectivityCheckLabel] = check.Name
+		peer.Spec.ServerRef = vpnv1alpha1.LocalObjectReference{Name: server.Name}
+		if publicKey != "" {
+			peer.Spec.PublicKey = publicKey
+		}
+		return controllerutil.SetControllerReference(check, peer, r.Scheme)
+	})
+	return peer, err
+}
+
+// applyProbe creates or updates the service account of the probe of a
+// check, with a Role granting it to annotate its pod, and its Deployment.
+func (r *ConnectivityCheckReconciler) applyProbe(ctx context.Context, check *vpnv1alpha1.VPNConnectivityCheck, server *vpnv1alpha1.VPNServer, peer *vpnv1alpha1.VPNPeer) error {
+	meta := metav1.ObjectMeta{Namespace: check.Namespace, Name: probeName(check)}
+	account := &corev1.ServiceAccount{ObjectMeta: meta}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, account, func() error {
+		account.Labels = probeLabels(check)
+		return controllerutil.SetControllerReference(check, account, r.Scheme)
+	}); err != nil {
+		return err
+	}
+	role := &rbacv1.Role{ObjectMeta: meta}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, role, func() error {
+		role.Labels = probeLabels(check)
+		role.Rules = []rbacv1.PolicyRule{{
+			APIGroups: []string{corev1.GroupName},
+			Resources: []string{"pods"},
+			Verbs:     []string{"get", "patch"},
+		}}
+		return controllerutil.SetControllerReference(check, role, r.Scheme)
+	}); err != nil {
+		return err
+	}
+	binding := &rbacv1.RoleBinding{ObjectMeta: meta}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, binding, func() error {
+		binding.Labels = probeLabels(check)
+		binding.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: role.Name}
+		binding.Subjects = []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: account.Name, Namespace: account.Namespace}}
+		return controllerutil.SetControllerReference(check, binding, r.Scheme)
+	}); err != nil {
+		return err
+	}
+
+	deployment := &appsv1.Deployment{ObjectMeta: meta}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, deployment, func() error {
+		deployment.Labels = probeLabels(check)
+		replicas := int32(1)
+		deployment.Spec.Replicas = &replicas
+		deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: probeLabels(check)}
+		// Each probe pod generates its own key, only one may run at once
+		deployment.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+		deployment.Spec.Template = probePodTemplate(check, server, peer)
+		return controllerutil.SetControllerReference(check, deployment, r.Scheme)
+	})
+	return err
+}
+
+// probePodTemplate returns the pod template of the probe of a check: the
+// server image running the probe script as the probe peer.
+func probePodTemplate(check *vpnv1alpha1.VPNConnectivityCheck, server *vpnv1alpha1.VPNServer, peer *vpnv1alpha1.VPNPeer) corev1.PodTemplateSpec {
+	target, _ := splitAddress(server.Spec.Address)
+	var interval int64
+	if check.Spec.Mode == vpnv1alpha1.ConnectivityCheckSoak {
+		interval = int64(soakInterval(check) / time.Second)
+	}
+	pings := check.Spec.Pings
+	if pings == 0 {
+		pings = defaultProbePings
+	}
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: probeLabels(check)},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: probeName(check),
+			Containers: []corev1.Container{{
+				Name:    "probe",
+				Image:   server.Spec.Image,
+				Command: []string{"/scripts/probe.sh"},
+				Env: []corev1.EnvVar{
+					{Name: "WG_PROBE_ADDRESS", Value: hostCIDR(peer.Spec.Address)},
+					{Name: "WG_PROBE_TARGET", Value: target.String()},
+					{Name: "WG_PROBE_PINGS", Value: strconv.Itoa(int(pings))},
+					{Name: "WG_PROBE_INTERVAL", Value: strconv.FormatInt(interval, 10)},
+					{Name: "WG_SERVER_PUBLIC_KEY", Value: server.Status.PublicKey},
+					{Name: "WG_SERVER_ENDPOINT", Value: server.Status.Endpoint},
+				},
+				SecurityContext: &corev1.SecurityContext{Capabilities: &corev1.Capabilities{
+					Add: []corev1.Capability{"NET_ADMIN"},
+				}},
+			}},
+		},
+	}
+}
+
+// removeProbe deletes the probe peer and Deployment of a check that
+// completed.
+func (r *ConnectivityCheckReconciler) removeProbe(ctx context.Context, check *vpnv1alpha1.VPNConnectivityCheck) error {
+	meta := metav1.ObjectMeta{Namespace: check.Namespace, Name: probeName(check)}
+	for _, obj := range []client.Object{&appsv1.Deployment{ObjectMeta: meta}, &vpnv1alpha1.VPNPeer{ObjectMeta: meta}} {
+		if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// probeReports returns the public key published by the newest probe pod
+// of a check and the samples of its pods.
+func probeReports(ctx context.Context, pods []corev1.Pod) (string, []probeSample) {
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].CreationTimestamp.Before(&pods[j].CreationTimestamp)
+	})
+	var publicKey string
+	var samples []probeSample
+	for _, pod := range pods {
+		if !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if key := pod.Annotations[vpnv1alpha1.ProbePublicKeyAnnotation]; key != "" {
+			publicKey = key
+		}
+		raw := pod.Annotations[vpnv1alpha1.ProbeSampleAnnotation]
+		if raw == "" {
+			continue
+		}
+		var sample probeSample
+		if err := json.Unmarshal([]byte(raw), &sample); err != nil {
+			log.FromContext(ctx).Info("ignoring invalid probe sample", "pod", pod.Name, "error", err.Error())
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	return publicKey, samples
+}
+
+// recordSamples appends the samples newer than the latest one recorded to
+// the window of a check, drops those that fell out of it, and summarizes
+// it.
+func recordSamples(check *vpnv1alpha1.VPNConnectivityCheck, reported []probeSample) {
+	sort.Slice(reported, func(i, j int) bool { return reported[i].Time < reported[j].Time })
+	status := &check.Status
+	for _, sample := range reported {
+		at := time.Unix(sample.Time, 0)
+		if n := len(status.Samples); n > 0 && !at.After(status.Samples[n-1].Time.Time) {
+			continue
+		}
+		recorded := vpnv1alpha1.ConnectivitySample{Time: metav1.NewTime(at), LossPercent: sample.Loss}
+		if sample.Latency != nil {
+			recorded.Latency = &metav1.Duration{Duration: time.Duration(*sample.Latency * float64(time.Millisecond)).Round(time.Microsecond)}
+		}
+		status.Samples = append(status.Samples, recorded)
+	}
+	if window := soakWindow(check); len(status.Samples) > window {
+		status.Samples = status.Samples[len(status.Samples)-window:]
+	}
+
+	// The thresholds may have changed since the samples were taken
+	maxLatency, maxLoss := probeThresholds(check)
+	var latency time.Duration
+	var answered int
+	var loss int32
+	for i := range status.Samples {
+		sample := &status.Samples[i]
+		sample.Degraded = sample.Latency == nil || sample.Latency.Duration > maxLatency || sample.LossPercent > maxLoss
+		if sample.Latency != nil {
+			latency += sample.Latency.Duration
+			answered++
+		}
+		loss += sample.LossPercent
+	}
+	status.AverageLatency, status.AverageLossPercent, status.Streak = nil, 0, 0
+	if n := len(status.Samples); n > 0 {
+		if answered > 0 {
+			status.AverageLatency = &metav1.Duration{Duration: (latency / time.Duration(answered)).Round(time.Microsecond)}
+		}
+		status.AverageLossPercent = loss / int32(n)
+		latest := status.Samples[n-1].Degraded
+		for i := n - 1; i >= 0 && status.Samples[i].Degraded == latest; i-- {
+			status.Streak++
+		}
+	}
+}
+
+// setDegradedCondition flips the Degraded condition of a check once the
+// latest samples breached its thresholds, or were healthy, for as many
+// samples in a row as its threshold, and keeps it otherwise.
+func (r *ConnectivityCheckReconciler) setDegradedCondition(check *vpnv1alpha1.VPNConnectivityCheck) {
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionDegraded,
+		Status:             vpnv1alpha1.ConditionUnknown,
+		Reason:             "Sampling",
+		Message:            "Not enough samples were taken yet",
+		ObservedGeneration: check.Generation,
+	}
+	current := vpnv1alpha1.FindCondition(check.Status.Conditions, condition.Type)
+	if current != nil {
+		condition.Status, condition.Reason, condition.Message = current.Status, current.Reason, current.Message
+	}
+	threshold := int32(1)
+	if check.Spec.Mode == vpnv1alpha1.ConnectivityCheckSoak {
+		threshold = soakThreshold(check)
+	}
+	if n := len(check.Status.Samples); n > 0 && check.Status.Streak >= threshold {
+		latest := check.Status.Samples[n-1]
+		latency := "no answer"
+		if latest.Latency != nil {
+			latency = latest.Latency.Duration.String()
+		}
+		if latest.Degraded {
+			condition.Status = vpnv1alpha1.ConditionTrue
+			condition.Reason = "ThresholdsBreached"
+			condition.Message = fmt.Sprintf("%d samples in a row breached the thresholds, latest latency %s and loss %d%%",
+				check.Status.Streak, latency, latest.LossPercent)
+		} else {
+			condition.Status = vpnv1alpha1.ConditionFalse
+			condition.Reason = "WithinThresholds"
+			condition.Message = fmt.Sprintf("%d samples in a row were within the thresholds, latest latency %s and loss %d%%",
+				check.Status.Streak, latency, latest.LossPercent)
+		}
+	}
+
+	if r.Recorder != nil && current != nil && current.Status != condition.Status {
+		switch condition.Status {
+		case vpnv1alpha1.ConditionTrue:
+			r.Recorder.Event(check, corev1.EventTypeWarning, "ConnectivityDegraded", condition.Message)
+		case vpnv1alpha1.ConditionFalse:
+			if current.Status == vpnv1alpha1.ConditionTrue {
+				r.Recorder.Event(check, corev1.EventTypeNormal, "ConnectivityRecovered", condition.Message)
+			}
+		}
+	}
+	vpnv1alpha1.SetCondition(&check.Status.Conditions, condition)
+}
+
+// setCheckReady sets the Ready condition of a check.
+func setCheckReady(check *vpnv1alpha1.VPNConnectivityCheck, status, reason, message string) {
+	vpnv1alpha1.SetCondition(&check.Status.Conditions, vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: check.Generation,
+	})
+}
+
+// probeThresholds returns the latency and loss above which a sample of a
+// check is degraded.
+func probeThresholds(check *vpnv1alpha1.VPNConnectivityCheck) (time.Duration, int32) {
+	maxLatency, maxLoss := defaultProbeMaxLatency, int32(defaultProbeMaxLoss)
+	if check.Spec.MaxLatency != nil {
+		maxLatency = check.Spec.MaxLatency.Duration
+	}
+	if check.Spec.MaxLossPercent != nil {
+		maxLoss = *check.Spec.MaxLossPercent
+	}
+	return maxLatency, maxLoss
+}
+
+// soakInterval returns the time between two samples of a soaking check.
+func soakInterval(check *vpnv1alpha1.VPNConnectivityCheck) time.Duration {
+	if soak := check.Spec.Soak; soak != nil && soak.Interval != nil && soak.Interval.Duration >= time.Second {
+		return soak.Interval.Duration
+	}
+	return defaultSoakInterval
+}
+
+// soakWindow returns the number of samples kept in the status of a check.
+func soakWindow(check *vpnv1alpha1.VPNConnectivityCheck) int {
+	if check.Spec.Mode != vpnv1alpha1.ConnectivityCheckSoak {
+		return 1
+	}
+	if soak := check.Spec.Soak; soak != nil && soak.Window > 0 {
+		return int(soak.Window)
+	}
+	return defaultSoakWindow
+}
+
+// soakThreshold returns the number of samples in a row that flip the
+// Degraded condition of a soaking check.
+func soakThreshold(check *vpnv1alpha1.VPNConnectivityCheck) int32 {
+	if soak := check.Spec.Soak; soak != nil && soak.Threshold > 0 {
+		return soak.Threshold
+	}
+	return defaultSoakThreshold
+}
+
+// probeName returns the name of the probe VPNPeer of a check, and of its
+// Deployment and service account.
+func probeName(check *vpnv1alpha1.VPNConnectivityCheck) string {
+	return check.Name + "-probe"
+}
+
+// probeLabels returns the labels identifying the probe pods of a check.
+func probeLabels(check *vpnv1alpha1.VPNConnectivityCheck) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":           "vpn-connectivity-probe",
+		"app.kubernetes.io/instance":       check.Name,
+		"app.kubernetes.io/managed-by":     "vpn-operator",
+		vpnv1alpha1.ConnectivityCheckLabel: check.Name,
+	}
+}
+
+// checkForProbePod maps a probe pod to its check.
+func checkForProbePod(_ context.Context, obj client.Object) []reconcile.Request {
+	name, ok := obj.GetLabels()[vpnv1alpha1.ConnectivityCheckLabel]
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}}}
+}
+
+// checksForServer maps a server to the checks probing it.
+func (r *ConnectivityCheckReconciler) checksForServer(ctx context.Context, obj client.Object) []reconcile.Request {
+	checks := &vpnv1alpha1.VPNConnectivityCheckList{}
+	if err := r.List(ctx, checks, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, check := range checks.Items {
+		if check.Spec.ServerRef.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&check)})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ConnectivityCheckReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("connectivitycheck").
+		For(&vpnv1alpha1.VPNConnectivityCheck{}).
+		Owns(&vpnv1alpha1.VPNPeer{}).
+		Owns(&appsv1.Deployment{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(checkForProbePod)).
+		Watches(&vpnv1alpha1.VPNServer{}, handler.EnqueueRequestsFromMapFunc(r.checksForServer)).
+		Complete(instrument(mgr, "connectivitycheck", &vpnv1alpha1.VPNConnectivityCheck{}, r))
+}
@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// testCheck returns a check of the edge server, and the server.
+func testCheck(mode vpnv1alpha1.ConnectivityCheckMode) (*vpnv1alpha1.VPNConnectivityCheck, *vpnv1alpha1.VPNServer) {
+	server := testServer("edge")
+	server.Status.PublicKey = testKeyA
+	server.Status.Endpoint = "203.0.113.1:51820"
+	check := &vpnv1alpha1.VPNConnectivityCheck{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "edge-check", UID: "edge-check-uid", Generation: 1},
+		Spec: vpnv1alpha1.VPNConnectivityCheckSpec{
+			ServerRef: vpnv1alpha1.LocalObjectReference{Name: "edge"},
+			Mode:      mode,
+			Soak:      &vpnv1alpha1.ConnectivitySoakSpec{Window: 4, Threshold: 2},
+		},
+	}
+	return check, server
+}
+
+// reconcileCheck reconciles a check and returns it as stored.
+func reconcileCheck(t *testing.T, r *ConnectivityCheckReconciler, check *vpnv1alpha1.VPNConnectivityCheck) *vpnv1alpha1.VPNConnectivityCheck {
+	t.Helper()
+	key := client.ObjectKeyFromObject(check)
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	updated := &vpnv1alpha1.VPNConnectivityCheck{}
+	if err := r.Get(context.Background(), key, updated); err != nil {
+		t.Fatal(err)
+	}
+	return updated
+}
+
+// startProbe allocates the address of the probe peer of a check, as IPAM
+// does, and returns its probe pod after reconciling the check.
+func startProbe(t *testing.T, r *ConnectivityCheckReconciler, check *vpnv1alpha1.VPNConnectivityCheck) *corev1.Pod {
+	t.Helper()
+	ctx := context.Background()
+	checked := reconcileCheck(t, r, check)
+	if ready := vpnv1alpha1.FindCondition(checked.Status.Conditions, vpnv1alpha1.ConditionReady); ready == nil || ready.Reason != "WaitingForAddress" {
+		t.Fatalf("Ready = %+v, want WaitingForAddress", ready)
+	}
+	peer := &vpnv1alpha1.VPNPeer{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: testNamespace, Name: "edge-check-probe"}, peer); err != nil {
+		t.Fatalf("probe peer: %v", err)
+	}
+	peer.Spec.Address = "10.8.0.9"
+	if err := r.Update(ctx, peer); err != nil {
+		t.Fatal(err)
+	}
+	reconcileCheck(t, r, check)
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: testNamespace, Name: "edge-check-probe"}, deployment); err != nil {
+		t.Fatalf("probe deployment: %v", err)
+	}
+	env := map[string]string{}
+	for _, e := range deployment.Spec.Template.Spec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	if env["WG_PROBE_ADDRESS"] != "10.8.0.9/32" || env["WG_PROBE_TARGET"] != "10.8.0.1" || env["WG_SERVER_PUBLIC_KEY"] != testKeyA {
+		t.Errorf("probe env = %v", env)
+	}
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   testNamespace,
+		Name:        "edge-check-probe-1",
+		Labels:      deployment.Spec.Template.Labels,
+		Annotations: map[string]string{vpnv1alpha1.ProbePublicKeyAnnotation: testKeyB},
+	}}
+	if err := r.Create(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+	return pod
+}
+
+// reportSample sets the latest sample of a probe pod, taken at a time.
+func reportSample(t *testing.T, c client.Client, pod *corev1.Pod, at time.Time, latency string, loss int) {
+	t.Helper()
+	pod.Annotations[vpnv1alpha1.ProbeSampleAnnotation] = fmt.Sprintf(`{"time":%d,"latency":%s,"loss":%d}`, at.Unix(), latency, loss)
+	if err := c.Update(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConnectivityCheckSoakFlipsOnSustainedDegradation(t *testing.T) {
+	check, server := testCheck(vpnv1alpha1.ConnectivityCheckSoak)
+	c, scheme := newTestClient(t, check, server)
+	recorder := record.NewFakeRecorder(10)
+	r := &ConnectivityCheckReconciler{Client: c, Scheme: scheme, Recorder: recorder}
+	pod := startProbe(t, r, check)
+
+	checked := reconcileCheck(t, r, check)
+	if ready := vpnv1alpha1.FindCondition(checked.Status.Conditions, vpnv1alpha1.ConditionReady); ready.Reason != "WaitingForProbe" {
+		t.Errorf("Ready reason = %s, want WaitingForProbe", ready.Reason)
+	}
+	peer := &vpnv1alpha1.VPNPeer{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: checked.Status.ProbePeer}, peer); err != nil {
+		t.Fatal(err)
+	}
+	if peer.Spec.PublicKey != testKeyB {
+		t.Errorf("probe peer key = %q, want the key published by the probe", peer.Spec.PublicKey)
+	}
+
+	start := time.Now().Add(-time.Minute)
+	degraded := func() string {
+		condition := vpnv1alpha1.FindCondition(checked.Status.Conditions, vpnv1alpha1.ConditionDegraded)
+		if condition == nil {
+			return ""
+		}
+		return condition.Status
+	}
+	steps := []struct {
+		latency string
+		loss    int
+		want    string
+	}{
+		{"12.5", 0, vpnv1alpha1.ConditionUnknown},
+		{"12.5", 0, vpnv1alpha1.ConditionFalse},
+		// A single failed sample does not flip the check
+		{"null", 100, vpnv1alpha1.ConditionFalse},
+		{"15", 0, vpnv1alpha1.ConditionFalse},
+		{"450", 0, vpnv1alpha1.ConditionFalse},
+		{"14", 20, vpnv1alpha1.ConditionTrue},
+		{"14", 0, vpnv1alpha1.ConditionTrue},
+		{"14", 0, vpnv1alpha1.ConditionFalse},
+	}
+	for i, step := range steps {
+		reportSample(t, c, pod, start.Add(time.Duration(i)*time.Second), step.latency, step.loss)
+		checked = reconcileCheck(t, r, check)
+		if got := degraded(); got != step.want {
+			t.Errorf("sample %d: Degraded = %s, want %s", i, got, step.want)
+		}
+	}
+
+	// The same sample is only recorded once
+	checked = reconcileCheck(t, r, check)
+	if n := len(checked.Status.Samples); n != 4 {
+		t.Fatalf("window holds %d samples, want 4", n)
+	}
+	if checked.Status.Samples[0].LossPercent != 0 || checked.Status.Samples[1].LossPercent != 20 {
+		t.Errorf("window = %+v, want the 4 latest samples", checked.Status.Samples)
+	}
+	if checked.Status.Streak != 2 || checked.Status.AverageLossPercent != 5 {
+		t.Errorf("streak = %d, average loss = %d, want 2 and 5", checked.Status.Streak, checked.Status.AverageLossPercent)
+	}
+	if got := checked.Status.AverageLatency.Duration; got != 123*time.Millisecond {
+		t.Errorf("average latency = %s, want 123ms", got)
+	}
+	if len(recorder.Events) != 2 {
+		t.Errorf("recorded %d events, want ConnectivityDegraded and ConnectivityRecovered", len(recorder.Events))
+	}
+}
+
+func TestConnectivityCheckOnceRemovesProbe(t *testing.T) {
+	check, server := testCheck(vpnv1alpha1.ConnectivityCheckOnce)
+	c, scheme := newTestClient(t, check, server)
+	r := &ConnectivityCheckReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(10)}
+	pod := startProbe(t, r, check)
+
+	reportSample(t, c, pod, time.Now(), "null", 100)
+	checked := reconcileCheck(t, r, check)
+	if checked.Status.CompletionTime == nil {
+		t.Fatal("check did not complete after its sample")
+	}
+	if !vpnv1alpha1.IsConditionTrue(checked.Status.Conditions, vpnv1alpha1.ConditionDegraded) {
+		t.Errorf("a single failed sample of the Once mode did not degrade the check")
+	}
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: testNamespace, Name: "edge-check-probe"}
+	if err := c.Get(ctx, key, &appsv1.Deployment{}); !apierrors.IsNotFound(err) {
+		t.Errorf("probe deployment: err = %v, want NotFound", err)
+	}
+	if err := c.Get(ctx, key, &vpnv1alpha1.VPNPeer{}); !apierrors.IsNotFound(err) {
+		t.Errorf("probe peer: err = %v, want NotFound", err)
+	}
+}
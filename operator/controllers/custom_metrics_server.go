@@ -0,0 +1,597 @@
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/certs"
+)
+
+const (
+	// ConnectedPeersMetric is the number of peers with a recent handshake
+	ConnectedPeersMetric = "wireflow_connected_peers"
+
+	// ServerThroughputMetric is the traffic of a server in bytes per second
+	ServerThroughputMetric = "wireflow_server_throughput"
+
+	customMetricsGroupVersion   = "custom.metrics.k8s.io/v1beta2"
+	externalMetricsGroupVersion = "external.metrics.k8s.io/v1beta1"
+
+	// serverMetricsResource is the resource VPNServer metrics are described
+	// with in the custom metrics API
+	serverMetricsResource = "vpnservers.vpn.vpn-devops.com"
+
+	// throughputWindow is the window throughput is averaged over
+	throughputWindow = 2 * time.Minute
+
+	// trafficHistoryRetention is how long traffic samples are kept
+	trafficHistoryRetention = 10 * time.Minute
+
+	// authenticationConfigRefresh is how often the front proxy settings are
+	// reloaded
+	authenticationConfigRefresh = 10 * time.Minute
+)
+
+// CustomMetricsServer serves the connected peers and the throughput of
+// VPNServers through the custom metrics API, for HPA object metrics, and
+// through the external metrics API, so that HPAs and KEDA scalers consume
+// them without a Prometheus adapter. It is registered with APIService
+// objects for v1beta2.custom.metrics.k8s.io and
+// v1beta1.external.metrics.k8s.io pointing at its Service.
+//
+// Requests are proxied by the API aggregator: its front proxy client
+// certificate is verified against the CA of the
+// kube-system/extension-apiserver-authentication ConfigMap, and the user it
+// asserts is authorized with a SubjectAccessReview.
+type CustomMetricsServer struct {
+	client.Client
+
+	// APIReader reads the front proxy settings without caching ConfigMaps
+	APIReader client.Reader
+
+	// Informers provides the VPNServer informer traffic is sampled from
+	Informers cache.Informers
+
+	// Address is the address the metrics APIs are served on
+	Address string
+
+	// GetCertificate returns the serving certificate. A self-signed
+	// certificate is used if nil, for APIServices with
+	// insecureSkipTLSVerify.
+	GetCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+	traffic trafficHistory
+
+	mu         sync.Mutex
+	frontProxy *frontProxyConfig
+}
+
+// frontProxyConfig is the request header authentication of the API
+// aggregator
+type frontProxyConfig struct {
+	loaded        time.Time
+	roots         *x509.CertPool
+	allowedNames  []string
+	usernameHeads []string
+	groupHeads    []string
+	extraPrefixes []string
+}
+
+// metricValue is an item of a custom metrics MetricValueList
+type metricValue struct {
+	DescribedObject corev1.ObjectReference `json:"describedObject"`
+	Metric          metricIdentifier       `json:"metric"`
+	Timestamp       metav1.Time            `json:"timestamp"`
+	WindowSeconds   *int64                 `json:"windowSeconds,omitempty"`
+	Value           resource.Quantity      `json:"value"`
+}
+
+type metricIdentifier struct {
+	Name     string                `json:"name"`
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// externalMetricValue is an item of an ExternalMetricValueList
+type externalMetricValue struct {
+	MetricName    string            `json:"metricName"`
+	MetricLabels  map[string]string `json:"metricLabels"`
+	Timestamp     metav1.Time       `json:"timestamp"`
+	WindowSeconds *int64            `json:"window,omitempty"`
+	Value         resource.Quantity `json:"value"`
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+//+kubebuilder:rbac:groups="",namespace=kube-system,resources=configmaps,resourceNames=extension-apiserver-authentication,verbs=get
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. The API
+// aggregator balances requests over the replicas of the Service.
+func (s *CustomMetricsServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable.
+func (s *CustomMetricsServer) Start(ctx context.Context) error {
+	logger := ctrl.Log.WithName("custom-metrics")
+
+	informer, err := s.Informers.GetInformer(ctx, &vpnv1alpha1.VPNServer{})
+	if err != nil {
+		return err
+	}
+	if _, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { s.sample(obj) },
+		UpdateFunc: func(_, obj interface{}) { s.sample(obj) },
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if server, ok := obj.(*vpnv1alpha1.VPNServer); ok {
+				s.traffic.forget(client.ObjectKeyFromObject(server))
+			}
+		},
+	}); err != nil {
+		return err
+	}
+
+	getCertificate := s.GetCertificate
+	if getCertificate == nil {
+		cert, err := certs.SelfSigned([]string{"vpn-operator-metrics"}, 365*24*time.Hour)
+		if err != nil {
+			return err
+		}
+		getCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return cert, nil }
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/apis/", s)
+	server := &http.Server{
+		Addr:              s.Address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+		TLSConfig: &tls.Config{
+			GetCertificate: getCertificate,
+			// Front proxy certificates are verified per request, against
+			// a CA that may be rotated
+			ClientAuth: tls.RequestClientCert,
+			MinVersion: tls.VersionTLS12,
+		},
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	logger.Info("serving custom and external metrics", "address", s.Address)
+	if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// sample records the total traffic of a server.
+func (s *CustomMetricsServer) sample(obj interface{}) {
+	server, ok := obj.(*vpnv1alpha1.VPNServer)
+	if !ok {
+		return
+	}
+	s.traffic.record(client.ObjectKeyFromObject(server), time.Now(), server.Status.TotalTraffic)
+}
+
+// ServeHTTP serves API discovery and metric values.
+func (s *CustomMetricsServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	logger := ctrl.Log.WithName("custom-metrics")
+	ctx := req.Context()
+	if req.Method != http.MethodGet {
+		writeStatus(w, apierrors.NewMethodNotSupported(schema.GroupResource{}, req.Method))
+		return
+	}
+	user, err := s.authenticate(ctx, req)
+	if err != nil {
+		logger.V(1).Info("rejecting unauthenticated request", "path", req.URL.Path, "reason", err.Error())
+		writeStatus(w, apierrors.NewUnauthorized(err.Error()))
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(req.URL.Path, "/apis/"), "/")
+	switch {
+	case path == customMetricsGroupVersion:
+		writeJSON(w, metricsDiscovery(customMetricsGroupVersion, "MetricValueList", serverMetricsResource+"/"))
+	case path == externalMetricsGroupVersion:
+		writeJSON(w, metricsDiscovery(externalMetricsGroupVersion, "ExternalMetricValueList", ""))
+	case strings.HasPrefix(path, customMetricsGroupVersion+"/"):
+		s.serveCustomMetric(w, req, user, strings.Split(strings.TrimPrefix(path, customMetricsGroupVersion+"/"), "/"))
+	case strings.HasPrefix(path, externalMetricsGroupVersion+"/"):
+		s.serveExternalMetric(w, req, user, strings.Split(strings.TrimPrefix(path, externalMetricsGroupVersion+"/"), "/"))
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// serveCustomMetric serves
+// namespaces/<namespace>/vpnservers.vpn.vpn-devops.com/<name>/<metric>, with
+// a name of * for the servers matching the labelSelector parameter.
+func (s *CustomMetricsServer) serveCustomMetric(w http.ResponseWriter, req *http.Request, user *authorizationv1.SubjectAccessReviewSpec, parts []string) {
+	if len(parts) != 5 || parts[0] != "namespaces" || parts[2] != serverMetricsResource || !isServerMetric(parts[4]) {
+		writeStatus(w, apierrors.NewNotFound(schema.GroupResource{Group: "custom.metrics.k8s.io", Resource: serverMetricsResource}, strings.Join(parts, "/")))
+		return
+	}
+	namespace, name, metric := parts[1], parts[3], parts[4]
+	ctx := req.Context()
+	if err := s.authorize(ctx, user, &authorizationv1.ResourceAttributes{
+		Namespace:   namespace,
+		Verb:        "get",
+		Group:       "custom.metrics.k8s.io",
+		Resource:    serverMetricsResource,
+		Subresource: metric,
+		Name:        name,
+	}); err != nil {
+		writeStatus(w, err)
+		return
+	}
+
+	servers, err := s.servers(ctx, namespace, name, req.URL.Query().Get("labelSelector"), nil)
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+	now := time.Now()
+	items := make([]metricValue, 0, len(servers))
+	for i := range servers {
+		server := &servers[i]
+		value, window := s.metricValue(server, metric, now)
+		items = append(items, metricValue{
+			DescribedObject: corev1.ObjectReference{
+				Kind:       "VPNServer",
+				APIVersion: vpnv1alpha1.GroupVersion.String(),
+				Namespace:  server.Namespace,
+				Name:       server.Name,
+			},
+			Metric:        metricIdentifier{Name: metric},
+			Timestamp:     metav1.NewTime(now),
+			WindowSeconds: window,
+			Value:         value,
+		})
+	}
+	writeJSON(w, map[string]interface{}{
+		"kind":       "MetricValueList",
+		"apiVersion": customMetricsGroupVersion,
+		"metadata":   metav1.ListMeta{},
+		"items":      items,
+	})
+}
+
+// serveExternalMetric serves namespaces/<namespace>/<metric>, with a value
+// per server of the namespace whose labels, along with a server label
+// holding its name, match the labelSelector parameter.
+func (s *CustomMetricsServer) serveExternalMetric(w http.ResponseWriter, req *http.Request, user *authorizationv1.SubjectAccessReviewSpec, parts []string) {
+	if len(parts) != 3 || parts[0] != "namespaces" || !isServerMetric(parts[2]) {
+		writeStatus(w, apierrors.NewNotFound(schema.GroupResource{Group: "external.metrics.k8s.io"}, strings.Join(parts, "/")))
+		return
+	}
+	namespace, metric := parts[1], parts[2]
+	ctx := req.Context()
+	if err := s.authorize(ctx, user, &authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      "get",
+		Group:     "external.metrics.k8s.io",
+		Resource:  metric,
+	}); err != nil {
+		writeStatus(w, err)
+		return
+	}
+
+	servers, err := s.servers(ctx, namespace, "*", req.URL.Query().Get("labelSelector"), map[string]string{"server": ""})
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+	now := time.Now()
+	items := make([]externalMetricValue, 0, len(servers))
+	for i := range servers {
+		server := &servers[i]
+		value, window := s.metricValue(server, metric, now)
+		items = append(items, externalMetricValue{
+			MetricName:    metric,
+			MetricLabels:  map[string]string{"server": server.Name},
+			Timestamp:     metav1.NewTime(now),
+			WindowSeconds: window,
+			Value:         value,
+		})
+	}
+	writeJSON(w, map[string]interface{}{
+		"kind":       "ExternalMetricValueList",
+		"apiVersion": externalMetricsGroupVersion,
+		"metadata":   metav1.ListMeta{},
+		"items":      items,
+	})
+}
+
+// servers returns the server with the given name, or the servers of the
+// namespace matching selector for a name of *. Keys of virtual are matched
+// as labels set to the name of the server.
+func (s *CustomMetricsServer) servers(ctx context.Context, namespace, name, selector string, virtual map[string]string) ([]vpnv1alpha1.VPNServer, error) {
+	if name != "*" {
+		server := vpnv1alpha1.VPNServer{}
+		if err := s.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &server); err != nil {
+			return nil, err
+		}
+		return []vpnv1alpha1.VPNServer{server}, nil
+	}
+
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid label selector: %v", err))
+	}
+	list := &vpnv1alpha1.VPNServerList{}
+	if err := s.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	var servers []vpnv1alpha1.VPNServer
+	for _, server := range list.Items {
+		set := labels.Set{}
+		for key, value := range server.Labels {
+			set[key] = value
+		}
+		for key := range virtual {
+			set[key] = server.Name
+		}
+		if parsed.Matches(set) {
+			servers = append(servers, server)
+		}
+	}
+	return servers, nil
+}
+
+// metricValue returns the value of a metric of a server and the window it
+// is averaged over, if any.
+func (s *CustomMetricsServer) metricValue(server *vpnv1alpha1.VPNServer, metric string, now time.Time) (resource.Quantity, *int64) {
+	if metric == ConnectedPeersMetric {
+		return *resource.NewQuantity(int64(server.Status.ConnectedClients), resource.DecimalSI), nil
+	}
+	window := int64(throughputWindow / time.Second)
+	rate := s.traffic.rate(client.ObjectKeyFromObject(server), now, throughputWindow)
+	return *resource.NewMilliQuantity(int64(rate*1000), resource.DecimalSI), &window
+}
+
+// authenticate verifies the front proxy client certificate of a request
+// and returns the user it asserts.
+func (s *CustomMetricsServer) authenticate(ctx context.Context, req *http.Request) (*authorizationv1.SubjectAccessReviewSpec, error) {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return nil, errors.New("no client certificate")
+	}
+	config, err := s.frontProxyConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	leaf := req.TLS.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range req.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         config.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return nil, fmt.Errorf("invalid front proxy certificate: %w", err)
+	}
+	if len(config.allowedNames) > 0 && !containsString(config.allowedNames, leaf.Subject.CommonName) {
+		return nil, fmt.Errorf("front proxy certificate for %q is not allowed", leaf.Subject.CommonName)
+	}
+
+	user := &authorizationv1.SubjectAccessReviewSpec{Extra: map[string]authorizationv1.ExtraValue{}}
+	for _, header := range config.usernameHeads {
+		if user.User = req.Header.Get(header); user.User != "" {
+			break
+		}
+	}
+	if user.User == "" {
+		return nil, errors.New("no user asserted by the front proxy")
+	}
+	for _, header := range config.groupHeads {
+		user.Groups = append(user.Groups, req.Header.Values(header)...)
+	}
+	for name, values := range req.Header {
+		for _, prefix := range config.extraPrefixes {
+			if strings.HasPrefix(strings.ToLower(name), strings.ToLower(prefix)) {
+				key := strings.ToLower(name[len(prefix):])
+				user.Extra[key] = append(user.Extra[key], values...)
+			}
+		}
+	}
+	return user, nil
+}
+
+// authorize checks that user may access the resource.
+func (s *CustomMetricsServer) authorize(ctx context.Context, user *authorizationv1.SubjectAccessReviewSpec, attributes *authorizationv1.ResourceAttributes) error {
+	review := &authorizationv1.SubjectAccessReview{Spec: *user}
+	review.Spec.ResourceAttributes = attributes
+	if err := s.Create(ctx, review); err != nil {
+		return err
+	}
+	if !review.Status.Allowed {
+		return apierrors.NewForbidden(schema.GroupResource{Group: attributes.Group, Resource: attributes.Resource}, attributes.Name,
+			fmt.Errorf("user %q cannot %s %s", user.User, attributes.Verb, attributes.Resource))
+	}
+	return nil
+}
+
+// frontProxyConfig returns the request header authentication of the API
+// aggregator, reloaded periodically to follow CA rotations.
+func (s *CustomMetricsServer) frontProxyConfig(ctx context.Context) (*frontProxyConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.frontProxy != nil && time.Since(s.frontProxy.loaded) < authenticationConfigRefresh {
+		return s.frontProxy, nil
+	}
+
+	cm := &corev1.ConfigMap{}
+	if err := s.APIReader.Get(ctx, types.NamespacedName{Namespace: "kube-system", Name: "extension-apiserver-authentication"}, cm); err != nil {
+		if s.frontProxy != nil {
+			return s.frontProxy, nil
+		}
+		return nil, fmt.Errorf("loading front proxy settings: %w", err)
+	}
+	config := &frontProxyConfig{loaded: time.Now(), roots: x509.NewCertPool()}
+	if !config.roots.AppendCertsFromPEM([]byte(cm.Data["requestheader-client-ca-file"])) {
+		return nil, errors.New("the cluster has no front proxy CA")
+	}
+	for key, target := range map[string]*[]string{
+		"requestheader-allowed-names":        &config.allowedNames,
+		"requestheader-username-headers":     &config.usernameHeads,
+		"requestheader-group-headers":        &config.groupHeads,
+		"requestheader-extra-headers-prefix": &config.extraPrefixes,
+	} {
+		if raw := cm.Data[key]; raw != "" {
+			if err := json.Unmarshal([]byte(raw), target); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", key, err)
+			}
+		}
+	}
+	s.frontProxy = config
+	return config, nil
+}
+
+// metricsDiscovery returns the resources of a metrics API, the metrics
+// prefixed with prefix.
+func metricsDiscovery(groupVersion, kind, prefix string) *metav1.APIResourceList {
+	list := &metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: groupVersion,
+	}
+	for _, metric := range []string{ConnectedPeersMetric, ServerThroughputMetric} {
+		list.APIResources = append(list.APIResources, metav1.APIResource{
+			Name:       prefix + metric,
+			Namespaced: true,
+			Kind:       kind,
+			Verbs:      metav1.Verbs{"get"},
+		})
+	}
+	return list
+}
+
+func isServerMetric(metric string) bool {
+	return metric == ConnectedPeersMetric || metric == ServerThroughputMetric
+}
+
+// writeStatus writes an error as a Status, as the API aggregator relays
+// it to clients.
+func writeStatus(w http.ResponseWriter, err error) {
+	status := apierrors.APIStatus(nil)
+	if !errors.As(err, &status) {
+		status = apierrors.NewInternalError(err)
+	}
+	result := status.Status()
+	result.TypeMeta = metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(int(result.Code))
+	_ = json.NewEncoder(w).Encode(&result)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// trafficSample is the total traffic of a server at a time
+type trafficSample struct {
+	time  time.Time
+	total int64
+}
+
+// trafficHistory keeps the recent total traffic of servers, as published
+// by the peer stats aggregation, to derive their throughput.
+type trafficHistory struct {
+	mu      sync.Mutex
+	samples map[types.NamespacedName][]trafficSample
+}
+
+// record adds a sample of a server. Samples equal to the latest are
+// skipped, and a decreasing total, as after replicas restart, starts a new
+// history.
+func (h *trafficHistory) record(key types.NamespacedName, now time.Time, total int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.samples == nil {
+		h.samples = map[types.NamespacedName][]trafficSample{}
+	}
+	samples := h.samples[key]
+	if n := len(samples); n > 0 {
+		if samples[n-1].total == total {
+			return
+		}
+		if samples[n-1].total > total {
+			samples = nil
+		}
+	}
+	samples = append(samples, trafficSample{time: now, total: total})
+	// Keep one sample older than the retention as the baseline of rates
+	for len(samples) > 2 && now.Sub(samples[1].time) > trafficHistoryRetention {
+		samples = samples[1:]
+	}
+	h.samples[key] = samples
+}
+
+func (h *trafficHistory) forget(key types.NamespacedName) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.samples, key)
+}
+
+// rate returns the throughput of a server in bytes per second over the
+// window ending at now: the traffic since the latest sample at or before
+// the start of the window, or since the oldest sample if the history is
+// shorter than the window.
+func (h *trafficHistory) rate(key types.NamespacedName, now time.Time, window time.Duration) float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	samples := h.samples[key]
+	if len(samples) < 2 {
+		return 0
+	}
+	baseline := samples[0]
+	for _, sample := range samples[1:] {
+		if sample.time.After(now.Add(-window)) {
+			break
+		}
+		baseline = sample
+	}
+	elapsed := now.Sub(baseline.time).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(samples[len(samples)-1].total-baseline.total) / elapsed
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	// wireguardContainerName is the container of server pods running the
+	// agent and the WireGuard interface
+	wireguardContainerName = "wireguard"
+
+	// tunVolumeName is the volume of the TUN device of userspace data planes
+	tunVolumeName = "dev-net-tun"
+
+	// modulesVolumeName is the volume of the kernel modules of the node,
+	// for the kernel data plane to load the WireGuard module
+	modulesVolumeName = "lib-modules"
+)
+
+// applyDataPlane adjusts the WireGuard container of a server pod to its
+// data plane. The kernel implementation loads the WireGuard module of the
+// node, which takes SYS_MODULE and the modules of the node. wireguard-go
+// only creates a TUN device, so userspace pods get the TUN device instead
+// and no SYS_MODULE, which sandboxed runtimes and restricted nodes refuse.
+// The agent starts wireguard-go from WG_IMPLEMENTATION.
+func applyDataPlane(server *vpnv1alpha1.VPNServer, spec *corev1.PodSpec) {
+	var container *corev1.Container
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == wireguardContainerName {
+			container = &spec.Containers[i]
+		}
+	}
+	if container == nil {
+		return
+	}
+	if container.SecurityContext == nil {
+		container.SecurityContext = &corev1.SecurityContext{}
+	}
+	if container.SecurityContext.Capabilities == nil {
+		container.SecurityContext.Capabilities = &corev1.Capabilities{}
+	}
+	capabilities := []corev1.Capability{"NET_ADMIN"}
+	volume := corev1.Volume{Name: modulesVolumeName}
+	mount := corev1.VolumeMount{Name: modulesVolumeName, MountPath: "/lib/modules", ReadOnly: true}
+	if server.Spec.Implementation == vpnv1alpha1.WireGuardUserspace {
+		charDevice := corev1.HostPathCharDev
+		volume = corev1.Volume{
+			Name:         tunVolumeName,
+			VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/dev/net/tun", Type: &charDevice}},
+		}
+		mount = corev1.VolumeMount{Name: tunVolumeName, MountPath: "/dev/net/tun"}
+	} else {
+		directory := corev1.HostPathDirectory
+		volume.VolumeSource = corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/lib/modules", Type: &directory}}
+		capabilities = append(capabilities, "SYS_MODULE")
+	}
+	container.SecurityContext.Capabilities.Add = capabilities
+	container.VolumeMounts = append(container.VolumeMounts, mount)
+	spec.Volumes = append(spec.Volumes, volume)
+}
+
+// DataPlaneReconciler reports the WireGuard implementation the replicas of
+// servers run. wg-quick falls back to wireguard-go when the kernel module
+// is missing, so replicas of servers with the kernel implementation may
+// silently run in userspace, at a fraction of the throughput.
+type DataPlaneReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile sets the data plane and the DataPlaneFallback condition of a
+// server from the reports of its ready pods.
+func (r *DataPlaneReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(server.Namespace), client.MatchingLabels(serverLabels(server))); err != nil {
+		return ctrl.Result{}, err
+	}
+	var dataPlane vpnv1alpha1.WireGuardImplementation
+	var fallbacks []string
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !isPodReady(pod) || !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		switch vpnv1alpha1.WireGuardImplementation(pod.Annotations[vpnv1alpha1.DataPlaneAnnotation]) {
+		case vpnv1alpha1.WireGuardUserspace:
+			dataPlane = vpnv1alpha1.WireGuardUserspace
+			if server.Spec.Implementation != vpnv1alpha1.WireGuardUserspace {
+				fallbacks = append(fallbacks, pod.Name)
+			}
+		case vpnv1alpha1.WireGuardKernel:
+			if dataPlane == "" {
+				dataPlane = vpnv1alpha1.WireGuardKernel
+			}
+		}
+	}
+	sort.Strings(fallbacks)
+
+	original := server.DeepCopy()
+	server.Status.DataPlane = dataPlane
+	if len(fallbacks) == 0 {
+		vpnv1alpha1.RemoveCondition(&server.Status.Conditions, vpnv1alpha1.ConditionDataPlaneFallback)
+	} else {
+		condition := vpnv1alpha1.Condition{
+			Type:   vpnv1alpha1.ConditionDataPlaneFallback,
+			Status: vpnv1alpha1.ConditionTrue,
+			Reason: "KernelModuleMissing",
+			Message: fmt.Sprintf("%s run wireguard-go as their nodes have no WireGuard kernel module; "+
+				"load the module or set spec.implementation to userspace", strings.Join(fallbacks, ", ")),
+			ObservedGeneration: server.Generation,
+		}
+		previous := vpnv1alpha1.FindCondition(original.Status.Conditions, condition.Type)
+		if previous == nil || previous.Message != condition.Message {
+			r.Recorder.Event(server, corev1.EventTypeWarning, "DataPlaneFallback", condition.Message)
+		}
+		vpnv1alpha1.SetCondition(&server.Status.Conditions, condition)
+	}
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{}, patchServerStatus(ctx, r.Client, server, original)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DataPlaneReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("dataplane").
+		For(&vpnv1alpha1.VPNServer{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(serverForPod)).
+		Complete(instrument(mgr, "dataplane", &vpnv1alpha1.VPNServer{}, r))
+}
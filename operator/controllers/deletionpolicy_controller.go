@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// retainFinalizer holds servers with the Retain deletion policy until their
+// resources are released from the garbage collector
+const retainFinalizer = "vpn.vpn-devops.com/retain"
+
+// retainedKinds are the kinds of the resources a server keeps with the
+// Retain deletion policy. Its peers are kept with it, since they hold the
+// client side of the keys.
+var retainedKinds = []func() client.ObjectList{
+	func() client.ObjectList { return &appsv1.DeploymentList{} },
+	func() client.ObjectList { return &appsv1.DaemonSetList{} },
+	func() client.ObjectList { return &appsv1.StatefulSetList{} },
+	func() client.ObjectList { return &corev1.ServiceList{} },
+	func() client.ObjectList { return &corev1.SecretList{} },
+	func() client.ObjectList { return &corev1.ConfigMapList{} },
+	func() client.ObjectList { return &vpnv1alpha1.VPNPeerList{} },
+}
+
+// DeletionPolicyReconciler applies the deletion policy of servers. Servers
+// with the Delete policy are left to the garbage collector; those with the
+// Retain policy get a finalizer that releases their resources from it when
+// they are deleted, so that the WireGuard interface, keys and Secrets
+// survive the server. Foreground deletions bypass the policy: the garbage
+// collector deletes the resources before the finalizer runs.
+type DeletionPolicyReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/finalizers,verbs=update
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=apps,resources=deployments;daemonsets;statefulsets,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups="",resources=services;secrets;configmaps,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile adds or removes the retain finalizer of a server, and orphans
+// its resources when it is deleted with the Retain policy.
+func (r *DeletionPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	retain := server.Spec.DeletionPolicy == vpnv1alpha1.DeletionPolicyRetain
+
+	if server.DeletionTimestamp.IsZero() {
+		var changed bool
+		if retain {
+			changed = controllerutil.AddFinalizer(server, retainFinalizer)
+		} else {
+			changed = controllerutil.RemoveFinalizer(server, retainFinalizer)
+		}
+		if changed {
+			return ctrl.Result{}, r.Update(ctx, server)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(server, retainFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	// The policy may have been switched to Delete after the deletion
+	if retain {
+		orphaned, err := r.orphan(ctx, server)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		logger.Info("retained the resources of the deleted server", "resources", orphaned)
+		r.Recorder.Event(server, corev1.EventTypeNormal, "Retained",
+			fmt.Sprintf("Retained %d resources, labelled %s=true", orphaned, vpnv1alpha1.OrphanedLabel))
+	}
+	controllerutil.RemoveFinalizer(server, retainFinalizer)
+	return ctrl.Result{}, r.Update(ctx, server)
+}
+
+// orphan releases the resources owned by a server from the garbage
+// collector and labels them as orphaned. It returns how many it released.
+func (r *DeletionPolicyReconciler) orphan(ctx context.Context, server *vpnv1alpha1.VPNServer) (int, error) {
+	orphaned := 0
+	for _, newList := range retainedKinds {
+		list := newList()
+		if err := r.List(ctx, list, client.InNamespace(server.Namespace)); err != nil {
+			return orphaned, err
+		}
+		objects, err := meta.ExtractList(list)
+		if err != nil {
+			return orphaned, err
+		}
+		for _, item := range objects {
+			obj := item.(client.Object)
+			if !isOwnedBy(obj, server) {
+				continue
+			}
+			patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+			var refs []metav1.OwnerReference
+			for _, ref := range obj.GetOwnerReferences() {
+				if ref.UID != server.UID {
+					refs = append(refs, ref)
+				}
+			}
+			obj.SetOwnerReferences(refs)
+			labels := obj.GetLabels()
+			if labels == nil {
+				labels = map[string]string{}
+			}
+			labels[vpnv1alpha1.OrphanedLabel] = "true"
+			obj.SetLabels(labels)
+			annotations := obj.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[vpnv1alpha1.OrphanedFromAnnotation] = server.Name
+			obj.SetAnnotations(annotations)
+			if err := r.Patch(ctx, obj, patch); err != nil {
+				return orphaned, err
+			}
+			orphaned++
+		}
+	}
+	return orphaned, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DeletionPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("deletionpolicy").
+		For(&vpnv1alpha1.VPNServer{}).
+		Complete(instrument(mgr, "deletionpolicy", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const defaultRevalidationInterval = 24 * time.Hour
+
+// DeviceBindingReconciler flags peers bound to a device whose key was
+// re-validated from another device, or whose re-validation is overdue. The
+// re-validations are recorded by the PostureServer.
+type DeviceBindingReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile sets the DeviceMismatch condition of a peer from its last
+// posture re-validation.
+func (r *DeviceBindingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	peer := &vpnv1alpha1.VPNPeer{}
+	if err := r.Get(ctx, req.NamespacedName, peer); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	binding := peer.Spec.DeviceBinding
+	if binding == nil || !peer.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	interval := defaultRevalidationInterval
+	if binding.RevalidationInterval != nil {
+		interval = binding.RevalidationInterval.Duration
+	}
+
+	now := time.Now()
+	posture := peer.Status.Posture
+	if posture == nil {
+		posture = &vpnv1alpha1.PostureStatus{}
+	}
+	due := peer.CreationTimestamp.Add(interval)
+	if posture.ValidatedAt != nil {
+		due = posture.ValidatedAt.Add(interval)
+	}
+
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionDeviceMismatch,
+		Status:             vpnv1alpha1.ConditionFalse,
+		Reason:             "FingerprintMatches",
+		ObservedGeneration: peer.Generation,
+	}
+	requeue := due.Sub(now)
+	// A key seen on another device stays flagged for an interval, even if
+	// the bound device re-validates in between
+	if posture.MismatchedAt != nil && now.Sub(posture.MismatchedAt.Time) < interval {
+		condition.Status = vpnv1alpha1.ConditionTrue
+		condition.Reason = "FingerprintMismatch"
+		condition.Message = fmt.Sprintf("The key was re-validated at %s from a device other than the bound one",
+			posture.MismatchedAt.UTC().Format(time.RFC3339))
+		if cleared := posture.MismatchedAt.Add(interval).Sub(now); cleared < requeue {
+			requeue = cleared
+		}
+	} else if !now.Before(due) {
+		condition.Status = vpnv1alpha1.ConditionUnknown
+		condition.Reason = "RevalidationOverdue"
+		condition.Message = fmt.Sprintf("The device has not re-validated its posture since %s", due.Add(-interval).UTC().Format(time.RFC3339))
+		requeue = 0
+	} else if posture.ValidatedAt == nil {
+		condition.Reason = "AwaitingRevalidation"
+	}
+
+	original := peer.DeepCopy()
+	wasMismatched := vpnv1alpha1.IsConditionTrue(peer.Status.Conditions, condition.Type)
+	vpnv1alpha1.SetCondition(&peer.Status.Conditions, condition)
+	if !equality.Semantic.DeepEqual(original.Status, peer.Status) {
+		if err := r.Status().Patch(ctx, peer, client.MergeFrom(original)); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if condition.Status == vpnv1alpha1.ConditionTrue && !wasMismatched {
+		r.Recorder.Event(peer, corev1.EventTypeWarning, "DeviceMismatch", condition.Message)
+	}
+	// Overdue peers are reconciled again when the device re-validates
+	return ctrl.Result{RequeueAfter: requeue}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DeviceBindingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("devicebinding").
+		For(&vpnv1alpha1.VPNPeer{}).
+		Complete(instrument(mgr, "devicebinding", &vpnv1alpha1.VPNPeer{}, r))
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	// driftCheckInterval is how often the drift of a server is evaluated
+	// again, so that replicas whose agent stopped reporting are noticed
+	driftCheckInterval = 5 * time.Minute
+
+	// driftReportMaxAge is the age after which the drift report of a
+	// replica is no longer trusted. Agents refresh their report every five
+	// minutes by default.
+	driftReportMaxAge = 15 * time.Minute
+)
+
+// configDriftReport is the drift report the agent pushes on its pod. Changed
+// peers map to the settings that differ.
+type configDriftReport struct {
+	CheckedAt  int64               `json:"checkedAt"`
+	Checksum   string              `json:"checksum"`
+	Missing    []string            `json:"missing"`
+	Unexpected []string            `json:"unexpected"`
+	Changed    map[string][]string `json:"changed"`
+	Interface  []string            `json:"interface"`
+}
+
+// DriftReconciler reports the servers whose live devices differ from their
+// desired configuration. The agents compare their device with the rendered
+// configuration and push the differences on their pods, which catches peers
+// changed out of band and reloads that failed after incidents or upgrades.
+type DriftReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile sets the drift status and the ConfigDrift condition of a server
+// from the reports of its ready pods. Reports against another configuration
+// than the desired one, or older than driftReportMaxAge, are ignored.
+func (r *DriftReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() || server.Status.ConfigChecksum == "" {
+		return ctrl.Result{}, nil
+	}
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(server.Namespace), client.MatchingLabels(serverLabels(server))); err != nil {
+		return ctrl.Result{}, err
+	}
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(server.Namespace), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: server.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	names := map[string]string{}
+	for _, peer := range peers.Items {
+		if peer.Spec.PublicKey != "" {
+			names[peer.Spec.PublicKey] = peer.Name
+		}
+	}
+	name := func(publicKey string) string {
+		if name, ok := names[publicKey]; ok {
+			return name
+		}
+		return publicKey
+	}
+
+	now := time.Now()
+	drift := &vpnv1alpha1.DriftStatus{}
+	ready, checked := 0, 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !isPodReady(pod) || !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		ready++
+		raw, ok := pod.Annotations[vpnv1alpha1.ConfigDriftAnnotation]
+		if !ok {
+			continue
+		}
+		report := configDriftReport{}
+		if err := json.Unmarshal([]byte(raw), &report); err != nil {
+			logger.Info("ignoring malformed drift report", "pod", pod.Name, "error", err.Error())
+			continue
+		}
+		checkedTime := metav1.Unix(report.CheckedAt, 0)
+		if report.Checksum != server.Status.ConfigChecksum || now.Sub(checkedTime.Time) > driftReportMaxAge {
+			continue
+		}
+		checked++
+		if drift.CheckedTime == nil || checkedTime.Before(drift.CheckedTime) {
+			drift.CheckedTime = &checkedTime
+		}
+
+		replica := vpnv1alpha1.ReplicaDrift{Pod: pod.Name, CheckedTime: &checkedTime, Interface: report.Interface}
+		for _, publicKey := range report.Missing {
+			replica.MissingPeers = append(replica.MissingPeers, name(publicKey))
+		}
+		for _, publicKey := range report.Unexpected {
+			replica.UnexpectedPeers = append(replica.UnexpectedPeers, name(publicKey))
+		}
+		for publicKey, fields := range report.Changed {
+			replica.ChangedPeers = append(replica.ChangedPeers, vpnv1alpha1.PeerDrift{Name: name(publicKey), Fields: fields})
+		}
+		if len(replica.MissingPeers)+len(replica.UnexpectedPeers)+len(replica.ChangedPeers)+len(replica.Interface) == 0 {
+			continue
+		}
+		sort.Strings(replica.MissingPeers)
+		sort.Strings(replica.UnexpectedPeers)
+		sort.Slice(replica.ChangedPeers, func(i, j int) bool { return replica.ChangedPeers[i].Name < replica.ChangedPeers[j].Name })
+		drift.Replicas = append(drift.Replicas, replica)
+	}
+	sort.Slice(drift.Replicas, func(i, j int) bool { return drift.Replicas[i].Pod < drift.Replicas[j].Pod })
+
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionConfigDrift,
+		Status:             vpnv1alpha1.ConditionFalse,
+		Reason:             "Converged",
+		ObservedGeneration: server.Generation,
+	}
+	switch {
+	case len(drift.Replicas) > 0:
+		summaries := make([]string, 0, len(drift.Replicas))
+		for _, replica := range drift.Replicas {
+			summaries = append(summaries, replica.Pod+": "+describeDrift(replica))
+		}
+		condition.Status = vpnv1alpha1.ConditionTrue
+		condition.Reason = "DeviceDrift"
+		condition.Message = fmt.Sprintf("%d of %d replicas differ from configuration %s; %s",
+			len(drift.Replicas), ready, server.Status.ConfigChecksum, strings.Join(summaries, "; "))
+	case checked < ready || ready == 0:
+		condition.Status = vpnv1alpha1.ConditionUnknown
+		condition.Reason = "NotChecked"
+		condition.Message = fmt.Sprintf("%d of %d replicas compared their device with configuration %s recently",
+			checked, ready, server.Status.ConfigChecksum)
+	default:
+		condition.Message = fmt.Sprintf("every replica matched configuration %s as of %s",
+			server.Status.ConfigChecksum, drift.CheckedTime.UTC().Format(time.RFC3339))
+	}
+
+	original := server.DeepCopy()
+	previous := vpnv1alpha1.FindCondition(original.Status.Conditions, condition.Type)
+	if condition.Status == vpnv1alpha1.ConditionTrue && (previous == nil || previous.Message != condition.Message) {
+		r.Recorder.Event(server, corev1.EventTypeWarning, "ConfigDrift", condition.Message)
+	} else if condition.Status == vpnv1alpha1.ConditionFalse && previous != nil && previous.Status == vpnv1alpha1.ConditionTrue {
+		r.Recorder.Event(server, corev1.EventTypeNormal, "ConfigConverged", condition.Message)
+	}
+	vpnv1alpha1.SetCondition(&server.Status.Conditions, condition)
+	server.Status.Drift = drift
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return ctrl.Result{RequeueAfter: driftCheckInterval}, nil
+	}
+	if err := patchServerStatus(ctx, r.Client, server, original); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: driftCheckInterval}, nil
+}
+
+// describeDrift summarizes the drift of a replica, e.g. "2 missing peers,
+// listen-port".
+func describeDrift(replica vpnv1alpha1.ReplicaDrift) string {
+	var parts []string
+	count := func(n int, what string) {
+		switch {
+		case n == 1:
+			parts = append(parts, "1 "+what+" peer")
+		case n > 1:
+			parts = append(parts, fmt.Sprintf("%d %s peers", n, what))
+		}
+	}
+	count(len(replica.MissingPeers), "missing")
+	count(len(replica.UnexpectedPeers), "unexpected")
+	count(len(replica.ChangedPeers), "changed")
+	parts = append(parts, replica.Interface...)
+	return strings.Join(parts, ", ")
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DriftReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("drift").
+		For(&vpnv1alpha1.VPNServer{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(serverForPod)).
+		Watches(&vpnv1alpha1.VPNPeer{}, serverForPeerCoalesced()).
+		Complete(instrument(mgr, "drift", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// defaultResolutionTTL is how long resolved names are cached when the
+	// TTL of their records is unknown
+	defaultResolutionTTL = 5 * time.Minute
+
+	// resolutionBackoff and maxResolutionBackoff bound the wait before a
+	// name that failed to resolve is looked up again
+	resolutionBackoff    = 5 * time.Second
+	maxResolutionBackoff = 5 * time.Minute
+)
+
+var endpointResolutionFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "wireflow_endpoint_resolution_failures_total",
+	Help: "Failed lookups of endpoint host names, by reason: not_found, timeout or error.",
+}, []string{"reason"})
+
+func init() {
+	metrics.Registry.MustRegister(endpointResolutionFailures)
+}
+
+// EndpointResolver resolves the host names of endpoints. Addresses are
+// cached for the TTL of the records when it is known, and names failing to
+// resolve are retried with an exponential backoff per name rather than on
+// every reconcile.
+type EndpointResolver struct {
+	// DNS is the resolver names are looked up with, net.DefaultResolver if
+	// nil
+	DNS *net.Resolver
+
+	mu      sync.Mutex
+	entries map[string]*resolution
+}
+
+type resolution struct {
+	addrs    []string
+	expires  time.Time
+	failures int
+	retryAt  time.Time
+	err      error
+}
+
+// Resolve returns the addresses of host, from the cache if they have not
+// expired. ttl is the TTL of the records of host, or zero if unknown. IP
+// addresses are returned as is.
+func (r *EndpointResolver) Resolve(ctx context.Context, host string, ttl time.Duration) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	now := time.Now()
+	r.mu.Lock()
+	if r.entries == nil {
+		r.entries = map[string]*resolution{}
+	}
+	entry, ok := r.entries[host]
+	if !ok {
+		entry = &resolution{}
+		r.entries[host] = entry
+	}
+	switch {
+	case entry.err == nil && now.Before(entry.expires):
+		addrs := entry.addrs
+		r.mu.Unlock()
+		return addrs, nil
+	case entry.err != nil && now.Before(entry.retryAt):
+		err := entry.err
+		r.mu.Unlock()
+		return nil, err
+	}
+	r.mu.Unlock()
+
+	dns := r.DNS
+	if dns == nil {
+		dns = net.DefaultResolver
+	}
+	addrs, err := dns.LookupHost(ctx, host)
+	if err == nil && len(addrs) == 0 {
+		err = &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		endpointResolutionFailures.WithLabelValues(resolutionFailureReason(err)).Inc()
+		backoff := resolutionBackoff << entry.failures
+		if backoff > maxResolutionBackoff || backoff <= 0 {
+			backoff = maxResolutionBackoff
+		}
+		entry.failures++
+		entry.retryAt = now.Add(backoff)
+		entry.err = err
+		return nil, err
+	}
+	// Sorted so that round-robin answers do not read as changes
+	sort.Strings(addrs)
+	if ttl <= 0 {
+		ttl = defaultResolutionTTL
+	}
+	*entry = resolution{addrs: addrs, expires: now.Add(ttl)}
+	return addrs, nil
+}
+
+// RetryAfter returns how long until a name that failed to resolve is
+// looked up again, or zero if it resolved.
+func (r *EndpointResolver) RetryAfter(host string) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.entries[host]
+	if !ok || entry.err == nil {
+		return 0
+	}
+	if wait := time.Until(entry.retryAt); wait > 0 {
+		return wait
+	}
+	return time.Second
+}
+
+// resolutionFailureReason classifies a failed lookup for the metric.
+func resolutionFailureReason(err error) string {
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		return "not_found"
+	case errors.As(err, &dnsErr) && dnsErr.IsTimeout, errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	return "error"
+}
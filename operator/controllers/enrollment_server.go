@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/enrollment"
+)
+
+// inviteAcceptance accepts the invite of a placeholder peer
+type inviteAcceptance struct {
+	// Token is the invite token of the peer
+	Token string `json:"token"`
+
+	// PublicKey is the WireGuard public key of the invitee
+	PublicKey string `json:"publicKey"`
+
+	// Challenge is the challenge issued on /enroll/challenge
+	Challenge string `json:"challenge"`
+
+	// Proof answers the challenge with the private key of PublicKey
+	Proof string `json:"proof"`
+
+	// Client is the implementation and version of the invitee's client.
+	// Without it, they are taken from the User-Agent.
+	Client *clientReport `json:"client,omitempty"`
+}
+
+// EnrollmentServer serves the enrollment API. Invitees of placeholder peers
+// fetch a challenge from /enroll/challenge and post their public key with
+// the invite token and a proof of possession of the private key to
+// /enroll/invites/<namespace>/<peer>, which activates the peer and returns
+// its client config without the private key. Challenges are answered on the
+// replica that issued them. A GET of the invite path with the token serves
+// the invite page, branded with the VPNBranding of the peer's server. The
+// client version is recorded on the peer, and clients below the minimum
+// version of a server refusing them are turned away. Users of
+// VPNSelfEnrollments enroll and revoke their own devices the same way on
+// /enroll/self/<namespace>/<name>/devices, authenticated by their token.
+type EnrollmentServer struct {
+	client.Client
+
+	// Address is the address the enrollment API is served on
+	Address string
+
+	// GetCertificate returns the serving certificate of the operator
+	GetCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+	// Verifier issues the challenges and verifies the proofs
+	Verifier *enrollment.Verifier
+
+	// Recorder records accepted invites and denied keys
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnbrandings,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnkeydenylists,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnselfenrollments,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Every
+// replica serves enrollments, so that invitees are not turned away during
+// leader changes.
+func (s *EnrollmentServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable.
+func (s *EnrollmentServer) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle("/enroll/", s)
+	server := &http.Server{
+		Addr:              s.Address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+		TLSConfig: &tls.Config{
+			GetCertificate: s.GetCertificate,
+			MinVersion:     tls.VersionTLS12,
+		},
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	ctrl.Log.WithName("enrollment").Info("serving the enrollment API", "address", s.Address)
+	if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP issues challenges, accepts invites and serves self enrollments.
+func (s *EnrollmentServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := strings.TrimPrefix(req.URL.Path, "/enroll/")
+	if path == "challenge" && req.Method == http.MethodGet {
+		challenge, err := s.Verifier.Challenge()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = io.WriteString(w, challenge)
+		return
+	}
+	parts := strings.Split(path, "/")
+	if parts[0] == "self" {
+		s.serveSelfEnrollment(w, req, parts[1:])
+		return
+	}
+	if len(parts) != 3 || parts[0] != "invites" {
+		http.NotFound(w, req)
+		return
+	}
+	key := types.NamespacedName{Namespace: parts[1], Name: parts[2]}
+	switch req.Method {
+	case http.MethodGet:
+		s.invitePage(w, req, key)
+	case http.MethodPost:
+		s.acceptInvite(w, req, key)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// pendingInvite returns the peer with a pending invite and its token.
+// Failures are written to w, in which case the peer is nil.
+func (s *EnrollmentServer) pendingInvite(w http.ResponseWriter, req *http.Request, key types.NamespacedName) (*vpnv1alpha1.VPNPeer, []byte) {
+	ctx := req.Context()
+	peer := &vpnv1alpha1.VPNPeer{}
+	if err := s.Get(ctx, key, peer); err != nil {
+		if apierrors.IsNotFound(err) {
+			http.NotFound(w, req)
+			return nil, nil
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, nil
+	}
+	invite := peer.Status.Invite
+	if peer.Spec.Invite == nil || invite == nil || invite.TokenSecretName == "" || peer.Spec.PublicKey != "" {
+		http.Error(w, "the peer has no pending invite", http.StatusConflict)
+		return nil, nil
+	}
+	if invite.ExpiresAt != nil && !time.Now().Before(invite.ExpiresAt.Time) {
+		http.Error(w, "the invite expired", http.StatusGone)
+		return nil, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := s.Get(ctx, types.NamespacedName{Namespace: peer.Namespace, Name: invite.TokenSecretName}, secret); err != nil {
+		http.Error(w, "the peer has no pending invite", http.StatusConflict)
+		return nil, nil
+	}
+	token := secret.Data[inviteTokenKey]
+	if len(token) == 0 {
+		http.Error(w, "the peer has no pending invite", http.StatusConflict)
+		return nil, nil
+	}
+	return peer, token
+}
+
+// invitePage serves the invite page of a placeholder peer to the holder of
+// its token.
+func (s *EnrollmentServer) invitePage(w http.ResponseWriter, req *http.Request, key types.NamespacedName) {
+	peer, token := s.pendingInvite(w, req, key)
+	if peer == nil {
+		return
+	}
+	given := req.URL.Query().Get("token")
+	if subtle.ConstantTimeCompare(token, []byte(given)) != 1 {
+		http.Error(w, "invalid invite token", http.StatusForbidden)
+		return
+	}
+
+	ctx := req.Context()
+	server := &vpnv1alpha1.VPNServer{}
+	if err := s.Get(ctx, types.NamespacedName{Namespace: peer.Namespace, Name: peer.Spec.ServerRef.Name}, server); err != nil {
+		http.Error(w, "the server of the invite is unavailable", http.StatusInternalServerError)
+		return
+	}
+	branding, err := serverBranding(ctx, s, server)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := newBrandingData(branding, peer, server)
+	data.Token = given
+	if expiresAt := peer.Status.Invite.ExpiresAt; expiresAt != nil {
+		data.ExpiresAt = expiresAt.Time
+	}
+	data.EnrollmentURL = "https://" + req.Host
+	if req.TLS == nil {
+		data.EnrollmentURL = "http://" + req.Host
+	}
+	// The page carries the token, it must not be kept or framed elsewhere
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src https: data:; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := invitePageTemplate.Execute(w, data); err != nil {
+		ctrl.Log.WithName("enrollment").Error(err, "unable to render the invite page", "namespace", peer.Namespace, "peer", peer.Name)
+	}
+}
+
+// acceptInvite sets the public key of a placeholder peer once the invitee
+// proved both the invite token and the possession of the private key.
+func (s *EnrollmentServer) acceptInvite(w http.ResponseWriter, req *http.Request, key types.NamespacedName) {
+	logger := ctrl.Log.WithName("enrollment")
+	acceptance := inviteAcceptance{}
+	if err := json.NewDecoder(io.LimitReader(req.Body, 4096)).Decode(&acceptance); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := req.Context()
+	peer, token := s.pendingInvite(w, req, key)
+	if peer == nil {
+		return
+	}
+	if subtle.ConstantTimeCompare(token, []byte(acceptance.Token)) != 1 {
+		http.Error(w, "invalid invite token", http.StatusForbidden)
+		return
+	}
+	// Failures are not told apart, to leak nothing about keys
+	if err := s.Verifier.Verify(acceptance.Challenge, acceptance.PublicKey, acceptance.Proof); err != nil {
+		http.Error(w, "invalid proof", http.StatusForbidden)
+		return
+	}
+	list, denied, err := vpnv1alpha1.FindDeniedKey(ctx, s, acceptance.PublicKey)
+	if err != nil {
+		logger.Error(err, "unable to check the key deny lists", "namespace", peer.Namespace, "peer", peer.Name)
+		http.Error(w, "unable to accept the invite", http.StatusInternalServerError)
+		return
+	}
+	if denied != nil {
+		s.Recorder.Eventf(list, corev1.EventTypeWarning, "KeyDenied", "Refused the denied public key %s submitted for the invite of VPNPeer %s/%s from %s",
+			denied.PublicKey, peer.Namespace, peer.Name, req.RemoteAddr)
+		http.Error(w, "the public key is denied, generate a new key pair", http.StatusForbidden)
+		return
+	}
+	server := &vpnv1alpha1.VPNServer{}
+	if err := s.Get(ctx, types.NamespacedName{Namespace: peer.Namespace, Name: peer.Spec.ServerRef.Name}, server); err != nil {
+		http.Error(w, "the server of the invite is unavailable", http.StatusInternalServerError)
+		return
+	}
+	info := clientInfo(acceptance.Client, req.UserAgent(), clientInfoSourceEnrollment)
+	if policy := server.Spec.ClientVersionPolicy; clientOutdated(policy, info) {
+		if policy.Action == vpnv1alpha1.ClientVersionActionRefuse {
+			s.Recorder.Eventf(peer, corev1.EventTypeWarning, "ClientRefused", "Refused the enrollment of client %s %s, below the minimum version %s",
+				info.Implementation, info.Version, policy.MinimumVersion)
+			http.Error(w, "the client must be upgraded to version "+policy.MinimumVersion+" or later", http.StatusUpgradeRequired)
+			return
+		}
+	}
+
+	// The lock makes concurrent acceptances of the same invite fail
+	original := peer.DeepCopy()
+	peer.Spec.PublicKey = acceptance.PublicKey
+	if err := s.Patch(ctx, peer, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})); err != nil {
+		if apierrors.IsConflict(err) {
+			http.Error(w, "the invite was accepted concurrently", http.StatusConflict)
+			return
+		}
+		if apierrors.IsInvalid(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Error(err, "unable to accept invite", "namespace", peer.Namespace, "peer", peer.Name)
+		http.Error(w, "unable to accept the invite", http.StatusInternalServerError)
+		return
+	}
+	logger.Info("accepted invite", "namespace", peer.Namespace, "peer", peer.Name)
+	s.Recorder.Eventf(peer, corev1.EventTypeNormal, "InviteAccepted", "The invitee enrolled the public key %s", peer.Spec.PublicKey)
+	if info != nil {
+		original := peer.DeepCopy()
+		peer.Status.Client = info
+		if err := s.Status().Patch(ctx, peer, client.MergeFrom(original)); err != nil {
+			logger.Error(err, "unable to record the client version", "namespace", peer.Namespace, "peer", peer.Name)
+		}
+	}
+
+	config, err := s.clientConfig(ctx, peer, server)
+	if err != nil {
+		http.Error(w, "the invite was accepted, but its preshared key is unavailable", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"config": config})
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	// federationFinalizer makes sure propagated servers are removed from
+	// their member cluster before the hub object goes away
+	federationFinalizer = "vpn.vpn-devops.com/federation"
+
+	// hubServerAnnotation records the hub object a propagated server
+	// originates from
+	hubServerAnnotation = "vpn.vpn-devops.com/hub-server"
+
+	// federationSyncInterval is how often member status is pulled back into
+	// the hub, since member clusters are not watched
+	federationSyncInterval = 30 * time.Second
+)
+
+// FederatedVPNServerReconciler propagates VPNServers placed on a member
+// cluster through spec.clusterName and mirrors their status back into the
+// hub.
+type FederatedVPNServerReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Clusters *ClusterSet
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/finalizers,verbs=update
+
+// Reconcile creates or updates the member copy of the server and copies its
+// status back.
+func (r *FederatedVPNServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if server.Spec.ClusterName == "" {
+		return ctrl.Result{}, nil
+	}
+
+	memberKey := types.NamespacedName{Namespace: server.Namespace, Name: server.Spec.ClusterName}
+	member, connected := r.Clusters.get(memberKey)
+
+	if !server.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(server, federationFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		if !connected {
+			logger.Info("waiting for member cluster to remove propagated server", "cluster", server.Spec.ClusterName)
+			return ctrl.Result{RequeueAfter: federationSyncInterval}, nil
+		}
+		remote := &vpnv1alpha1.VPNServer{ObjectMeta: metav1.ObjectMeta{Name: server.Name, Namespace: member.namespace}}
+		if err := member.client.Delete(ctx, remote); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+		controllerutil.RemoveFinalizer(server, federationFinalizer)
+		return ctrl.Result{}, r.Update(ctx, server)
+	}
+
+	if controllerutil.AddFinalizer(server, federationFinalizer) {
+		if err := r.Update(ctx, server); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if !connected {
+		vpnv1alpha1.SetCondition(&server.Status.Conditions, vpnv1alpha1.Condition{
+			Type:               vpnv1alpha1.ConditionFederated,
+			Status:             vpnv1alpha1.ConditionFalse,
+			Reason:             "MemberClusterUnavailable",
+			Message:            fmt.Sprintf("member cluster %q is not connected", server.Spec.ClusterName),
+			ObservedGeneration: server.Generation,
+		})
+		return ctrl.Result{RequeueAfter: federationSyncInterval}, r.Status().Update(ctx, server)
+	}
+
+	remote := &vpnv1alpha1.VPNServer{ObjectMeta: metav1.ObjectMeta{Name: server.Name, Namespace: member.namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, member.client, remote, func() error {
+		if origin, ok := remote.Annotations[hubServerAnnotation]; ok && origin != req.String() {
+			return fmt.Errorf("server %s/%s on the member cluster is managed by %s", remote.Namespace, remote.Name, origin)
+		}
+		if remote.CreationTimestamp.IsZero() {
+			remote.Labels = server.Labels
+		}
+		if remote.Annotations == nil {
+			remote.Annotations = map[string]string{}
+		}
+		remote.Annotations[hubServerAnnotation] = req.String()
+		remote.Spec = *server.Spec.DeepCopy()
+		remote.Spec.ClusterName = ""
+		return nil
+	}); err != nil {
+		if apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err) {
+			logger.Error(err, "member cluster rejected the propagated server", "cluster", server.Spec.ClusterName)
+		}
+		vpnv1alpha1.SetCondition(&server.Status.Conditions, vpnv1alpha1.Condition{
+			Type:               vpnv1alpha1.ConditionFederated,
+			Status:             vpnv1alpha1.ConditionFalse,
+			Reason:             "PropagationFailed",
+			Message:            err.Error(),
+			ObservedGeneration: server.Generation,
+		})
+		return ctrl.Result{RequeueAfter: federationSyncInterval}, r.Status().Update(ctx, server)
+	}
+
+	// The member status describes generations of the member copy. It only
+	// reflects the current hub spec once the member operator has observed
+	// the copy written above; until then it keeps the previous generation.
+	observed := server.Status.ObservedGeneration
+	if remote.Status.ObservedGeneration == remote.Generation {
+		observed = server.Generation
+	}
+	conditions := server.Status.Conditions
+	server.Status = *remote.Status.DeepCopy()
+	server.Status.ObservedGeneration = observed
+	for i := range server.Status.Conditions {
+		condition := &server.Status.Conditions[i]
+		if condition.ObservedGeneration == remote.Generation {
+			condition.ObservedGeneration = server.Generation
+		} else {
+			condition.ObservedGeneration = observed
+		}
+	}
+	if federated := vpnv1alpha1.FindCondition(conditions, vpnv1alpha1.ConditionFederated); federated != nil {
+		server.Status.Conditions = append(server.Status.Conditions, *federated)
+	}
+	vpnv1alpha1.SetCondition(&server.Status.Conditions, vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionFederated,
+		Status:             vpnv1alpha1.ConditionTrue,
+		Reason:             "Propagated",
+		ObservedGeneration: server.Generation,
+	})
+	if err := r.Status().Update(ctx, server); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: federationSyncInterval}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *FederatedVPNServerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("federatedvpnserver").
+		For(&vpnv1alpha1.VPNServer{}).
+		Complete(instrument(mgr, "federatedvpnserver", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// firewallConfigKey is the key of the rendered config ConfigMap holding
+// the firewall rules read by the agent
+const firewallConfigKey = "firewall"
+
+// renderFirewallRules renders the firewall of a server for the agent: one
+// line per rule in order, holding its action, protocol, comma separated
+// sources, destinations and ports, and name, with - for any, followed by
+// the default action.
+func renderFirewallRules(server *vpnv1alpha1.VPNServer) string {
+	policy := server.Spec.Firewall
+	if policy == nil {
+		return ""
+	}
+	var b strings.Builder
+	for i, rule := range policy.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("rule-%d", i)
+		}
+		fmt.Fprintf(&b, "%s %s %s %s %s %s\n", strings.ToLower(string(rule.Action)), anyField(strings.ToLower(rule.Protocol)),
+			anyField(strings.Join(rule.Sources, ",")), anyField(strings.Join(rule.Destinations, ",")),
+			anyField(strings.Join(rule.Ports, ",")), name)
+	}
+	action := vpnv1alpha1.FirewallAllow
+	if policy.DefaultAction != "" {
+		action = policy.DefaultAction
+	}
+	fmt.Fprintf(&b, "default %s\n", strings.ToLower(string(action)))
+	return b.String()
+}
+
+// anyField returns a field of a rendered firewall rule, - if it is empty.
+func anyField(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
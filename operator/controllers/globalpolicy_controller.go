@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	// globalPolicyResync is how often policies are evaluated without
+	// changes, as a safety net for missed events
+	globalPolicyResync = 10 * time.Minute
+
+	// maxListedViolations is the number of violations listed in the
+	// status of a policy
+	maxListedViolations = 100
+)
+
+// GlobalPolicyReconciler reports the servers, peers and peer groups violating each
+// VPNGlobalPolicy, such as those admitted before the policy or a change of
+// it, which the admission webhook could not reject. Resources that start
+// violating a policy get a warning event.
+type GlobalPolicyReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnglobalpolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnglobalpolicies/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeergroups,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile evaluates a policy against every server, peer and peer group.
+func (r *GlobalPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	policy := &vpnv1alpha1.VPNGlobalPolicy{}
+	if err := r.Get(ctx, req.NamespacedName, policy); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !policy.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	namespaces := &corev1.NamespaceList{}
+	if err := r.List(ctx, namespaces); err != nil {
+		return ctrl.Result{}, err
+	}
+	namespaceLabels := make(map[string]map[string]string, len(namespaces.Items))
+	for _, namespace := range namespaces.Items {
+		namespaceLabels[namespace.Name] = namespace.Labels
+	}
+	servers := &vpnv1alpha1.VPNServerList{}
+	if err := r.List(ctx, servers); err != nil {
+		return ctrl.Result{}, err
+	}
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers); err != nil {
+		return ctrl.Result{}, err
+	}
+	groups := &vpnv1alpha1.VPNPeerGroupList{}
+	if err := r.List(ctx, groups); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var violations []vpnv1alpha1.PolicyViolation
+	var violating []client.Object
+	for i := range servers.Items {
+		server := &servers.Items[i]
+		if messages := policy.ServerViolations(server, namespaceLabels[server.Namespace]); len(messages) > 0 {
+			violations = append(violations, policyViolation("VPNServer", server, messages))
+			violating = append(violating, server)
+		}
+	}
+	for i := range peers.Items {
+		peer := &peers.Items[i]
+		if messages := policy.PeerViolations(peer); len(messages) > 0 {
+			violations = append(violations, policyViolation("VPNPeer", peer, messages))
+			violating = append(violating, peer)
+		}
+	}
+	for i := range groups.Items {
+		group := &groups.Items[i]
+		if messages := policy.PeerGroupViolations(group); len(messages) > 0 {
+			violations = append(violations, policyViolation("VPNPeerGroup", group, messages))
+			violating = append(violating, group)
+		}
+	}
+
+	original := policy.DeepCopy()
+	reported := map[string]bool{}
+	for _, violation := range original.Status.Violations {
+		reported[violation.Kind+"/"+violation.Namespace+"/"+violation.Name] = true
+	}
+	policy.Status.ObservedGeneration = policy.Generation
+	policy.Status.ViolationCount = int32(len(violations))
+	policy.Status.Violations = violations
+	if len(violations) > maxListedViolations {
+		policy.Status.Violations = violations[:maxListedViolations]
+	}
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionReady,
+		Status:             vpnv1alpha1.ConditionTrue,
+		Reason:             "Compliant",
+		Message:            "no server, peer or peer group violates the policy",
+		ObservedGeneration: policy.Generation,
+	}
+	if len(violations) > 0 {
+		condition.Status = vpnv1alpha1.ConditionFalse
+		condition.Reason = "Violated"
+		condition.Message = fmt.Sprintf("%d servers, peers and peer groups violate the policy", len(violations))
+	}
+	vpnv1alpha1.SetCondition(&policy.Status.Conditions, condition)
+	if !equality.Semantic.DeepEqual(original.Status, policy.Status) {
+		if err := r.Status().Patch(ctx, policy, client.MergeFrom(original)); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Only the listed violations are remembered, so resources beyond them
+	// get an event on every change
+	for i, obj := range violating {
+		violation := violations[i]
+		if !reported[violation.Kind+"/"+violation.Namespace+"/"+violation.Name] {
+			r.Recorder.Event(obj, corev1.EventTypeWarning, "PolicyViolation", violation.Message)
+		}
+	}
+	return ctrl.Result{RequeueAfter: globalPolicyResync}, nil
+}
+
+// policyViolation returns the violation of a policy by obj.
+func policyViolation(kind string, obj client.Object, messages []string) vpnv1alpha1.PolicyViolation {
+	return vpnv1alpha1.PolicyViolation{
+		Kind:      kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Message:   strings.Join(messages, "; "),
+	}
+}
+
+// allPolicies maps a server, peer, peer group or namespace to every policy.
+func (r *GlobalPolicyReconciler) allPolicies(ctx context.Context, _ client.Object) []reconcile.Request {
+	policies := &vpnv1alpha1.VPNGlobalPolicyList{}
+	if err := r.List(ctx, policies); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(policies.Items))
+	for _, policy := range policies.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&policy)})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GlobalPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vpnv1alpha1.VPNGlobalPolicy{}).
+		Watches(&vpnv1alpha1.VPNServer{}, handler.EnqueueRequestsFromMapFunc(r.allPolicies),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&vpnv1alpha1.VPNPeer{}, handler.EnqueueRequestsFromMapFunc(r.allPolicies),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&vpnv1alpha1.VPNPeerGroup{}, handler.EnqueueRequestsFromMapFunc(r.allPolicies),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.allPolicies),
+			builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Complete(instrument(mgr, "vpnglobalpolicy", &vpnv1alpha1.VPNGlobalPolicy{}, r))
+}
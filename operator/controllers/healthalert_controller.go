@@ -0,0 +1,305 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/events"
+)
+
+const (
+	defaultAlertEvaluationInterval = time.Minute
+	defaultStaleHandshakeThreshold = 5 * time.Minute
+
+	// defaultMobileStaleHandshakeThreshold tolerates mobile peers that only
+	// handshake when they send
+	defaultMobileStaleHandshakeThreshold = time.Hour
+)
+
+// alertSample is an observation of a server used to derive rates between
+// two evaluations
+type alertSample struct {
+	time         time.Time
+	totalTraffic int64
+	connected    map[string]bool
+	rateBreaches []alertBreach
+}
+
+// alertBreach is a breached alerting threshold
+type alertBreach struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// alertNotification is the payload POSTed to alerting webhooks
+type alertNotification struct {
+	Server string        `json:"server"`
+	State  string        `json:"state"`
+	Time   time.Time     `json:"time"`
+	Alerts []alertBreach `json:"alerts,omitempty"`
+}
+
+// HealthAlertReconciler evaluates the alerting thresholds of VPNServers,
+// maintains their HealthAlert condition and notifies webhooks when it
+// changes. It is meant for clusters without Prometheus and Alertmanager.
+type HealthAlertReconciler struct {
+	client.Client
+	Scheme     *runtime.Scheme
+	Recorder   record.EventRecorder
+	HTTPClient *http.Client
+
+	// ASNResolver resolves the networks peers connect from. Usage anomaly
+	// detection ignores new networks if nil.
+	ASNResolver ASNResolver
+
+	mu        sync.Mutex
+	samples   map[types.NamespacedName]alertSample
+	baselines map[types.NamespacedName]*usageBaselines
+}
+
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile evaluates the alerting thresholds of a server.
+func (r *HealthAlertReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		r.forget(req.NamespacedName)
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	alerting := server.Spec.Alerting
+	if alerting == nil || !server.DeletionTimestamp.IsZero() {
+		r.forget(req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
+	interval := defaultAlertEvaluationInterval
+	if alerting.EvaluationInterval != nil {
+		interval = alerting.EvaluationInterval.Duration
+	}
+
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(server.Namespace), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: server.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	mobile := map[string]bool{}
+	for _, peer := range peers.Items {
+		if peer.Spec.PowerProfile == vpnv1alpha1.PowerProfileMobile && peer.Spec.PublicKey != "" {
+			mobile[peer.Spec.PublicKey] = true
+		}
+	}
+
+	now := time.Now()
+	breaches := r.evaluate(req.NamespacedName, server, mobile, interval, now)
+	if alerting.UsageAnomaly != nil {
+		anomalies, err := r.detectAnomalies(ctx, server, now)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		breaches = append(breaches, anomalies...)
+	}
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionHealthAlert,
+		Status:             vpnv1alpha1.ConditionFalse,
+		Reason:             "WithinThresholds",
+		ObservedGeneration: server.Generation,
+	}
+	if len(breaches) > 0 {
+		rules := make([]string, 0, len(breaches))
+		messages := make([]string, 0, len(breaches))
+		for _, breach := range breaches {
+			rules = append(rules, breach.Rule)
+			messages = append(messages, breach.Message)
+		}
+		condition.Status = vpnv1alpha1.ConditionTrue
+		condition.Reason = strings.Join(rules, ",")
+		condition.Message = strings.Join(messages, "; ")
+	}
+
+	previous := vpnv1alpha1.FindCondition(server.Status.Conditions, vpnv1alpha1.ConditionHealthAlert)
+	changed := previous == nil && condition.Status == vpnv1alpha1.ConditionTrue ||
+		previous != nil && previous.Status != condition.Status
+
+	original := server.DeepCopy()
+	vpnv1alpha1.SetCondition(&server.Status.Conditions, condition)
+	if err := patchServerStatus(ctx, r.Client, server, original); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if changed {
+		state := "resolved"
+		if condition.Status == vpnv1alpha1.ConditionTrue {
+			state = "firing"
+		}
+		notification := alertNotification{Server: req.String(), State: state, Time: time.Now(), Alerts: breaches}
+		if r.Recorder != nil {
+			if condition.Status == vpnv1alpha1.ConditionTrue {
+				r.Recorder.Event(server, corev1.EventTypeWarning, "HealthAlertFiring", condition.Message)
+			} else {
+				r.Recorder.Event(server, corev1.EventTypeNormal, "HealthAlertResolved", "All alerting thresholds are met again")
+			}
+		}
+		for _, webhook := range alerting.Webhooks {
+			if err := r.notify(ctx, server.Namespace, webhook, notification); err != nil {
+				logger.Error(err, "unable to notify alerting webhook", "url", webhook.URL)
+			}
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// evaluate returns the thresholds breached by the server. Peers whose public
+// key is in mobile are held to the mobile stale handshake threshold. Rates
+// are derived from the previous sample of the same server, which is only
+// replaced once at least half an interval has passed so that spec changes
+// do not produce rates over very short periods.
+func (r *HealthAlertReconciler) evaluate(key types.NamespacedName, server *vpnv1alpha1.VPNServer, mobile map[string]bool, interval time.Duration, now time.Time) []alertBreach {
+	alerting := server.Spec.Alerting
+	var breaches []alertBreach
+
+	staleThreshold := defaultStaleHandshakeThreshold
+	if alerting.StaleHandshakeThreshold != nil {
+		staleThreshold = alerting.StaleHandshakeThreshold.Duration
+	}
+	mobileStaleThreshold := defaultMobileStaleHandshakeThreshold
+	if alerting.MobileStaleHandshakeThreshold != nil {
+		mobileStaleThreshold = alerting.MobileStaleHandshakeThreshold.Duration
+	}
+	connected := map[string]bool{}
+	stale := 0
+	for _, peer := range server.Status.Peers {
+		threshold := staleThreshold
+		if mobile[peer.PublicKey] {
+			threshold = mobileStaleThreshold
+		}
+		if peer.LatestHandshake != nil && now.Sub(peer.LatestHandshake.Time) <= threshold {
+			connected[peer.PublicKey] = true
+			continue
+		}
+		stale++
+	}
+	if alerting.StaleHandshakeThreshold != nil && int32(stale) > alerting.MaxStalePeers {
+		message := fmt.Sprintf("%d peers have not completed a handshake in %s", stale, staleThreshold)
+		if len(mobile) > 0 {
+			message += fmt.Sprintf(", %s for mobile peers", mobileStaleThreshold)
+		}
+		breaches = append(breaches, alertBreach{Rule: "StaleHandshakes", Message: message})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.samples == nil {
+		r.samples = map[types.NamespacedName]alertSample{}
+	}
+	previous, ok := r.samples[key]
+	if ok && now.Sub(previous.time) < interval/2 {
+		return append(breaches, previous.rateBreaches...)
+	}
+
+	sample := alertSample{time: now, totalTraffic: server.Status.TotalTraffic, connected: connected}
+	if ok {
+		sample.rateBreaches = evaluateRates(alerting, previous, sample)
+	}
+	r.samples[key] = sample
+	return append(breaches, sample.rateBreaches...)
+}
+
+// evaluateRates returns the rate thresholds breached between two samples.
+func evaluateRates(alerting *vpnv1alpha1.AlertingSpec, previous, current alertSample) []alertBreach {
+	var breaches []alertBreach
+	elapsed := current.time.Sub(previous.time).Seconds()
+	if elapsed <= 0 {
+		return nil
+	}
+
+	// A traffic counter going backwards means the device was recreated
+	if delta := current.totalTraffic - previous.totalTraffic; delta >= 0 {
+		throughput := int64(float64(delta) / elapsed)
+		if alerting.TrafficFloor != nil && throughput < *alerting.TrafficFloor {
+			breaches = append(breaches, alertBreach{
+				Rule:    "TrafficBelowFloor",
+				Message: fmt.Sprintf("throughput %d B/s is below %d B/s", throughput, *alerting.TrafficFloor),
+			})
+		}
+		if alerting.TrafficCeiling != nil && throughput > *alerting.TrafficCeiling {
+			breaches = append(breaches, alertBreach{
+				Rule:    "TrafficAboveCeiling",
+				Message: fmt.Sprintf("throughput %d B/s is above %d B/s", throughput, *alerting.TrafficCeiling),
+			})
+		}
+	}
+
+	if alerting.MaxPeerChurnPerHour != nil {
+		churn := 0
+		for peer := range current.connected {
+			if !previous.connected[peer] {
+				churn++
+			}
+		}
+		for peer := range previous.connected {
+			if !current.connected[peer] {
+				churn++
+			}
+		}
+		perHour := float64(churn) * time.Hour.Seconds() / elapsed
+		if perHour > float64(*alerting.MaxPeerChurnPerHour) {
+			breaches = append(breaches, alertBreach{
+				Rule:    "PeerChurn",
+				Message: fmt.Sprintf("peer churn of %.0f/h is above %d/h", perHour, *alerting.MaxPeerChurnPerHour),
+			})
+		}
+	}
+
+	return breaches
+}
+
+// notify POSTs the notification to the webhook.
+func (r *HealthAlertReconciler) notify(ctx context.Context, namespace string, webhook vpnv1alpha1.AlertWebhook, notification alertNotification) error {
+	token := ""
+	if ref := webhook.TokenSecretRef; ref != nil {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, secret); err != nil {
+			return err
+		}
+		key := ref.Key
+		if key == "" {
+			key = "token"
+		}
+		token = strings.TrimSpace(string(secret.Data[key]))
+	}
+	return events.PostJSON(ctx, r.HTTPClient, webhook.URL, token, notification)
+}
+
+func (r *HealthAlertReconciler) forget(key types.NamespacedName) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.samples, key)
+	delete(r.baselines, key)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HealthAlertReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("healthalert").
+		For(&vpnv1alpha1.VPNServer{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(instrument(mgr, "healthalert", &vpnv1alpha1.VPNServer{}, r))
+}
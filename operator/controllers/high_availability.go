@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// defaultLeaseDurationSeconds is the lease duration of servers in the high
+// availability mode that do not set one
+const defaultLeaseDurationSeconds = 10
+
+// HighAvailabilityReconciler maintains the Lease the replicas of servers in
+// the high availability mode elect their active replica with, and follows
+// its holder: the replicas are labeled with their role, which the Service
+// of the server selects on, and the holder is reported in
+// status.activeReplica. The agents acquire and renew the lease; the
+// operator only labels the replicas after them, also demoting a replica
+// that lost the lease without noticing.
+type HighAvailabilityReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile applies the lease of a server and labels its replicas after
+// the lease holder.
+func (r *HighAvailabilityReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	original := server.DeepCopy()
+
+	lease := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: server.Namespace, Name: haLeaseName(server)}}
+	if server.Spec.HighAvailability == nil {
+		if err := r.Delete(ctx, lease); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+		server.Status.ActiveReplica = ""
+		vpnv1alpha1.RemoveCondition(&server.Status.Conditions, vpnv1alpha1.ConditionActiveReplicaElected)
+		if equality.Semantic.DeepEqual(original.Status, server.Status) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, patchServerStatus(ctx, r.Client, server, original)
+	}
+
+	// The holder is left to the agents, only the duration is ours
+	duration := haLeaseDuration(server)
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, lease, func() error {
+		lease.Labels = serverLabels(server)
+		lease.Spec.LeaseDurationSeconds = &duration
+		return controllerutil.SetControllerReference(server, lease, r.Scheme)
+	}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+	active, expiry := leaseHolder(lease, now)
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(server.Namespace), client.MatchingLabels(serverLabels(server))); err != nil {
+		return ctrl.Result{}, err
+	}
+	found := false
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		role := vpnv1alpha1.HARoleStandby
+		if pod.Name == active && pod.DeletionTimestamp.IsZero() {
+			role = vpnv1alpha1.HARoleActive
+			found = true
+		}
+		if pod.Labels[vpnv1alpha1.HARoleLabel] == role {
+			continue
+		}
+		patch := client.MergeFrom(pod.DeepCopy())
+		if pod.Labels == nil {
+			pod.Labels = map[string]string{}
+		}
+		pod.Labels[vpnv1alpha1.HARoleLabel] = role
+		if err := r.Patch(ctx, pod, patch); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if !found {
+		active = ""
+	}
+
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionActiveReplicaElected,
+		Status:             vpnv1alpha1.ConditionTrue,
+		Reason:             "LeaseHeld",
+		Message:            fmt.Sprintf("replica %s holds lease %s", active, lease.Name),
+		ObservedGeneration: server.Generation,
+	}
+	if active == "" {
+		condition.Status = vpnv1alpha1.ConditionFalse
+		condition.Reason = "NoActiveReplica"
+		condition.Message = fmt.Sprintf("no replica holds lease %s, the server terminates no tunnels", lease.Name)
+	}
+	server.Status.ActiveReplica = active
+	vpnv1alpha1.SetCondition(&server.Status.Conditions, condition)
+	if previous := original.Status.ActiveReplica; previous != active {
+		switch {
+		case previous != "" && active != "":
+			r.Recorder.Eventf(server, corev1.EventTypeWarning, "FailedOver", "Replica %s took over from %s", active, previous)
+		case active != "":
+			r.Recorder.Eventf(server, corev1.EventTypeNormal, "ActiveReplicaElected", "Replica %s terminates the tunnels", active)
+		default:
+			r.Recorder.Eventf(server, corev1.EventTypeWarning, "ActiveReplicaLost", "Replica %s lost lease %s and no standby took over", previous, lease.Name)
+		}
+	}
+
+	// A holder failing without releasing the lease is only noticed once it
+	// expires, when no standby takes over
+	var result ctrl.Result
+	if active != "" {
+		result.RequeueAfter = expiry.Sub(now) + time.Second
+	}
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return result, nil
+	}
+	return result, patchServerStatus(ctx, r.Client, server, original)
+}
+
+// leaseHolder returns the holder of a lease and when it expires, or an
+// empty holder if it is not held or expired.
+func leaseHolder(lease *coordinationv1.Lease, now time.Time) (string, time.Time) {
+	spec := lease.Spec
+	if spec.HolderIdentity == nil || *spec.HolderIdentity == "" || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+		return "", time.Time{}
+	}
+	expiry := spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second)
+	if !now.Before(expiry) {
+		return "", time.Time{}
+	}
+	return *spec.HolderIdentity, expiry
+}
+
+// haLeaseName returns the name of the Lease of a server in the high
+// availability mode.
+func haLeaseName(server *vpnv1alpha1.VPNServer) string {
+	return server.Name + "-active"
+}
+
+// haLeaseDuration returns the lease duration of a server in the high
+// availability mode.
+func haLeaseDuration(server *vpnv1alpha1.VPNServer) int32 {
+	if ha := server.Spec.HighAvailability; ha != nil && ha.LeaseDurationSeconds > 0 {
+		return ha.LeaseDurationSeconds
+	}
+	return defaultLeaseDurationSeconds
+}
+
+// haEnv returns the environment of the leader election of the agent. It is
+// empty unless the server is in the high availability mode.
+func haEnv(server *vpnv1alpha1.VPNServer) []corev1.EnvVar {
+	if server.Spec.HighAvailability == nil {
+		return nil
+	}
+	return []corev1.EnvVar{
+		{Name: "WG_HA_LEASE", Value: haLeaseName(server)},
+		{Name: "WG_HA_LEASE_DURATION", Value: strconv.Itoa(int(haLeaseDuration(server)))},
+	}
+}
+
+// holderChanged passes the lease updates changing the holder, leaving out
+// the renewals of the agents every watchdog interval.
+var holderChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		previous, ok := e.ObjectOld.(*coordinationv1.Lease)
+		if !ok {
+			return true
+		}
+		current := e.ObjectNew.(*coordinationv1.Lease)
+		return !equality.Semantic.DeepEqual(previous.Spec.HolderIdentity, current.Spec.HolderIdentity)
+	},
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HighAvailabilityReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("highavailability").
+		For(&vpnv1alpha1.VPNServer{}).
+		Owns(&coordinationv1.Lease{}, builder.WithPredicates(holderChanged)).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(serverForPod)).
+		Complete(instrument(mgr, "highavailability", &vpnv1alpha1.VPNServer{}, r))
+}
+
+// isHAStandby returns whether a pod is a standby replica of a server in the
+// high availability mode, which does not terminate tunnels.
+func isHAStandby(server *vpnv1alpha1.VPNServer, pod *corev1.Pod) bool {
+	return server.Spec.HighAvailability != nil && pod.Labels[vpnv1alpha1.HARoleLabel] != vpnv1alpha1.HARoleActive
+}
@@ -0,0 +1,358 @@
+package controllers
+
+import (
+	"context"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	// lastHandshakeGranularity bounds how often the last handshake of a
+	// peer is written to its status
+	lastHandshakeGranularity = time.Hour
+
+	// idleCheckInterval is how often servers with an idle policy are
+	// checked for idle peers
+	idleCheckInterval = time.Hour
+)
+
+// IdlePeerReconciler records the last handshake of the peers of servers
+// with an idle policy, suspends the peers idle for longer than the policy
+// allows and resumes them when they connect again. A suspended peer keeps
+// its key on the server without an address, so its handshakes still
+// complete and are reported, while its address is released to the pool.
+type IdlePeerReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnippools,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile applies the idle policy of a server to its peers.
+func (r *IdlePeerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	policy := server.Spec.IdlePolicy
+	if policy == nil || !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(server.Namespace), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: server.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	handshakes := map[string]time.Time{}
+	for _, status := range server.Status.Peers {
+		if status.LatestHandshake != nil {
+			handshakes[status.PublicKey] = status.LatestHandshake.Time
+		}
+	}
+	now := time.Now()
+	idleFor := time.Duration(policy.IdleDays) * 24 * time.Hour
+
+	for i := range peers.Items {
+		peer := &peers.Items[i]
+		if !peer.DeletionTimestamp.IsZero() || peer.Spec.PublicKey == "" || peer.Spec.Revoked {
+			continue
+		}
+		handshake, connected := handshakes[peer.Spec.PublicKey]
+		if connected && (peer.Status.LastHandshake == nil || handshake.Sub(peer.Status.LastHandshake.Time) >= lastHandshakeGranularity) {
+			last := metav1.NewTime(handshake)
+			peer.Status.LastHandshake = &last
+			if err := r.Status().Update(ctx, peer); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		switch suspension := peer.Status.Suspension; {
+		case suspension != nil && connected && handshake.After(suspension.Since.Time):
+			if err := r.resume(ctx, peer); err != nil {
+				return ctrl.Result{}, err
+			}
+		case suspension != nil && peer.Spec.Address != "":
+			if err := r.settle(ctx, peer); err != nil {
+				return ctrl.Result{}, err
+			}
+		case suspension == nil && suspendable(peer) && now.Sub(lastSeen(peer)) >= idleFor:
+			if err := r.suspend(ctx, peer, now); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+	return ctrl.Result{RequeueAfter: idleCheckInterval}, nil
+}
+
+// suspendable returns whether the idle policy applies to a peer. Peers
+// routing networks or reached by the server, such as site routers, are
+// infrastructure rather than rarely connecting devices.
+func suspendable(peer *vpnv1alpha1.VPNPeer) bool {
+	return peer.Spec.Address != "" && len(peer.Spec.AllowedIPs) == 0 && peer.Spec.Endpoint == "" && peer.Spec.EndpointPinning == nil
+}
+
+// lastSeen returns the last handshake of a peer, or when it was created if
+// it never connected.
+func lastSeen(peer *vpnv1alpha1.VPNPeer) time.Time {
+	if peer.Status.LastHandshake != nil && peer.Status.LastHandshake.After(peer.CreationTimestamp.Time) {
+		return peer.Status.LastHandshake.Time
+	}
+	return peer.CreationTimestamp.Time
+}
+
+// suspend releases the address of an idle peer.
+func (r *IdlePeerReconciler) suspend(ctx context.Context, peer *vpnv1alpha1.VPNPeer, now time.Time) error {
+	address := peer.Spec.Address
+	// The suspension is recorded first so that the address is not lost if
+	// releasing it fails
+	peer.Status.Suspension = &vpnv1alpha1.PeerSuspension{Since: metav1.NewTime(now), Address: address}
+	peer.Status.Phase = vpnv1alpha1.PeerPhaseSuspended
+	if err := r.Status().Update(ctx, peer); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(peer.DeepCopy())
+	peer.Spec.Address = ""
+	if err := r.Patch(ctx, peer, patch); err != nil {
+		return err
+	}
+	if err := releaseAddress(ctx, r.Client, peer, address); err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("suspended idle peer", "peer", peer.Name, "address", address, "lastSeen", lastSeen(peer))
+	r.Recorder.Eventf(peer, corev1.EventTypeNormal, "PeerSuspended", "Suspended after no handshake since %s, released address %s",
+		lastSeen(peer).UTC().Format(time.RFC3339), address)
+	return nil
+}
+
+// settle completes the suspension of a peer whose address is set: it is
+// released if the suspension was interrupted before, and a different
+// address set by a user resumes the peer.
+func (r *IdlePeerReconciler) settle(ctx context.Context, peer *vpnv1alpha1.VPNPeer) error {
+	if peer.Spec.Address != peer.Status.Suspension.Address {
+		peer.Status.Suspension = nil
+		peer.Status.Phase = vpnv1alpha1.PeerPhaseActive
+		return r.Status().Update(ctx, peer)
+	}
+	patch := client.MergeFrom(peer.DeepCopy())
+	peer.Spec.Address = ""
+	if err := r.Patch(ctx, peer, patch); err != nil {
+		return err
+	}
+	return releaseAddress(ctx, r.Client, peer, peer.Status.Suspension.Address)
+}
+
+// resume assigns an address to a suspended peer that connected again: its
+// previous address if it is still free, a free address of the pool it was
+// allocated from otherwise.
+func (r *IdlePeerReconciler) resume(ctx context.Context, peer *vpnv1alpha1.VPNPeer) error {
+	previous := peer.Status.Suspension.Address
+	address, err := r.reassign(ctx, peer, previous)
+	if err != nil {
+		return err
+	}
+	if address == "" {
+		r.Recorder.Eventf(peer, corev1.EventTypeWarning, "AddressUnavailable", "Unable to resume, address %s was reused and no pool has a free address", previous)
+		return nil
+	}
+
+	if peer.Spec.Address != address {
+		patch := client.MergeFrom(peer.DeepCopy())
+		peer.Spec.Address = address
+		if err := r.Patch(ctx, peer, patch); err != nil {
+			return err
+		}
+	}
+	peer.Status.Suspension = nil
+	peer.Status.Phase = vpnv1alpha1.PeerPhaseActive
+	if err := r.Status().Update(ctx, peer); err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("resumed peer", "peer", peer.Name, "address", address)
+	if address == previous {
+		r.Recorder.Eventf(peer, corev1.EventTypeNormal, "PeerResumed", "Resumed with address %s", address)
+	} else {
+		r.Recorder.Eventf(peer, corev1.EventTypeNormal, "PeerResumed", "Resumed with address %s, %s was reused; the client needs its new config", address, previous)
+	}
+	return nil
+}
+
+// reassign returns the address of a resuming peer, or an empty string if
+// there is none free.
+func (r *IdlePeerReconciler) reassign(ctx context.Context, peer *vpnv1alpha1.VPNPeer, previous string) (string, error) {
+	used, err := usedAddresses(ctx, r.Client, peer)
+	if err != nil {
+		return "", err
+	}
+	ip, prefix := splitAddress(previous)
+	if ip == nil {
+		return "", nil
+	}
+	if !used[ip.String()] {
+		claimed, err := claimAddress(ctx, r.Client, peer, ip)
+		if err != nil || claimed {
+			return previous, err
+		}
+		used[ip.String()] = true
+	}
+
+	pools := &vpnv1alpha1.VPNIPPoolList{}
+	if err := r.List(ctx, pools, client.InNamespace(peer.Namespace)); err != nil {
+		return "", err
+	}
+	for i := range pools.Items {
+		cidrs := activeCIDRs(&pools.Items[i])
+		if !networksContain(cidrs, ip) {
+			continue
+		}
+		free, err := allocateFrom(ctx, r.Client, peer, cidrs, used)
+		if err != nil || free == nil {
+			return "", err
+		}
+		return free.String() + prefix, nil
+	}
+	return "", nil
+}
+
+// usedAddresses returns the addresses of the peers and servers of the
+// namespace of peer, other than its own, including the host routes of
+// peers such as the old addresses kept during pool migrations.
+func usedAddresses(ctx context.Context, c client.Reader, peer *vpnv1alpha1.VPNPeer) (map[string]bool, error) {
+	used := map[string]bool{}
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := c.List(ctx, peers, client.InNamespace(peer.Namespace)); err != nil {
+		return nil, err
+	}
+	for _, other := range peers.Items {
+		if other.Name == peer.Name {
+			continue
+		}
+		if ip, _ := splitAddress(other.Spec.Address); ip != nil {
+			used[ip.String()] = true
+		}
+		for _, ip := range hostRoutes(&other) {
+			used[ip.String()] = true
+		}
+	}
+	servers := &vpnv1alpha1.VPNServerList{}
+	if err := c.List(ctx, servers, client.InNamespace(peer.Namespace)); err != nil {
+		return nil, err
+	}
+	for _, server := range servers.Items {
+		if ip, _ := splitAddress(server.Spec.Address); ip != nil {
+			used[ip.String()] = true
+		}
+	}
+	return used, nil
+}
+
+// splitAddress splits an address given with or without a prefix length
+// into its IP and the prefix suffix, e.g. "/32".
+func splitAddress(address string) (net.IP, string) {
+	host, prefix, found := strings.Cut(strings.TrimSpace(address), "/")
+	ip := net.ParseIP(host)
+	if found {
+		prefix = "/" + prefix
+	}
+	return ip, prefix
+}
+
+// hostRoutes returns the single addresses among the allowed IPs of a peer.
+func hostRoutes(peer *vpnv1alpha1.VPNPeer) []net.IP {
+	var ips []net.IP
+	for _, cidr := range peer.Spec.AllowedIPs {
+		ip, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if ones, bits := network.Mask.Size(); ones == bits {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// networksContain returns whether one of the networks contains ip.
+func networksContain(cidrs []string, ip net.IP) bool {
+	for _, cidr := range cidrs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// freeAddress returns the first host address of a network that is not
+// used, skipping the network and broadcast addresses of IPv4 networks.
+func freeAddress(cidr string, used map[string]bool) net.IP {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil
+	}
+	ones, bits := network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	first, last := big.NewInt(0), new(big.Int).Sub(size, big.NewInt(1))
+	if bits == 32 && bits-ones >= 2 {
+		first.SetInt64(1)
+		last.Sub(last, big.NewInt(1))
+	}
+	base := new(big.Int).SetBytes(network.IP)
+	// Every used address rules out at most one candidate
+	limit := new(big.Int).Add(first, big.NewInt(int64(len(used))))
+	if limit.Cmp(last) > 0 {
+		limit = last
+	}
+	for offset := new(big.Int).Set(first); offset.Cmp(limit) <= 0; offset.Add(offset, big.NewInt(1)) {
+		raw := new(big.Int).Add(base, offset).Bytes()
+		ip := make(net.IP, len(network.IP))
+		copy(ip[len(ip)-len(raw):], raw)
+		if !used[ip.String()] {
+			return ip
+		}
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *IdlePeerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("idlepeer").
+		For(&vpnv1alpha1.VPNServer{}).
+		Watches(&vpnv1alpha1.VPNPeer{}, serverForPeerCoalesced()).
+		Complete(instrument(mgr, "idlepeer", &vpnv1alpha1.VPNServer{}, r))
+}
+
+// allocateAddress claims a free host address of the client network of a
+// server for peer, and returns it with a host prefix length, or "" when the
+// network is full.
+func allocateAddress(ctx context.Context, c client.Client, peer *vpnv1alpha1.VPNPeer, server *vpnv1alpha1.VPNServer) (string, error) {
+	network, err := clientNetwork(server)
+	if err != nil {
+		return "", err
+	}
+	used, err := usedAddresses(ctx, c, peer)
+	if err != nil {
+		return "", err
+	}
+	ip, err := allocateFrom(ctx, c, peer, []string{network.String()}, used)
+	if err != nil || ip == nil {
+		return "", err
+	}
+	return hostAddress(ip), nil
+}
@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// SetupIndexes registers the field indexes shared by the controllers and
+// webhooks.
+func SetupIndexes(ctx context.Context, mgr ctrl.Manager) error {
+	return mgr.GetFieldIndexer().IndexField(ctx, &vpnv1alpha1.VPNPeer{}, vpnv1alpha1.VPNPeerServerRefField, func(obj client.Object) []string {
+		return []string{obj.(*vpnv1alpha1.VPNPeer).Spec.ServerRef.Name}
+	})
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	// keysVolumeName is the volume holding the server keys
+	keysVolumeName = "wireguard-keys"
+
+	// keysMountPath is where the server keys are mounted
+	keysMountPath = "/etc/wireguard/keys"
+
+	// sealedKeysVolumeName is the volume holding the server keys encrypted
+	// with a Vault transit key
+	sealedKeysVolumeName = "wireguard-sealed-keys"
+
+	// sealedKeysMountPath is where the encrypted server keys are mounted
+	sealedKeysMountPath = "/etc/wireguard/sealed-keys"
+)
+
+// keysVolume returns the volume projecting the server key Secret. The
+// Secret is optional so the pod can be scheduled before it exists; the
+// kubelet populates the volume once the Secret is created and the
+// wait-for-key init container holds the pod until then. A private key
+// stored under another key than server_private is projected as
+// server_private.
+func keysVolume(secretName, key string) corev1.Volume {
+	optional := true
+	mode := int32(0400)
+	source := &corev1.SecretVolumeSource{
+		SecretName:  secretName,
+		Optional:    &optional,
+		DefaultMode: &mode,
+	}
+	if key != "" && key != defaultServerPrivateKeyKey {
+		source.Items = []corev1.KeyToPath{{Key: key, Path: defaultServerPrivateKeyKey}}
+	}
+	return corev1.Volume{
+		Name:         keysVolumeName,
+		VolumeSource: corev1.VolumeSource{Secret: source},
+	}
+}
+
+// serverKeysVolumes returns the volumes holding the server key. With a
+// Vault key store, the keys volume is held in memory and the agent fetches
+// the key into it, from Vault or by decrypting the sealed keys volume, so
+// that the plaintext key is never stored in the cluster.
+func serverKeysVolumes(server *vpnv1alpha1.VPNServer) []corev1.Volume {
+	ref := server.Spec.PrivateKeySecretRef
+	if !usesVaultKeyStore(server) {
+		if ref == nil {
+			return nil
+		}
+		return []corev1.Volume{keysVolume(ref.Name, ref.Key)}
+	}
+	volumes := []corev1.Volume{{
+		Name: keysVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory},
+		},
+	}}
+	if server.Spec.KeyStore.Vault.Engine == vpnv1alpha1.VaultEngineTransit && ref != nil {
+		sealed := keysVolume(ref.Name, "")
+		sealed.Name = sealedKeysVolumeName
+		volumes = append(volumes, sealed)
+	}
+	return volumes
+}
+
+// serverKeysMounts returns the mounts of the volumes of serverKeysVolumes.
+// The keys volume is writable for the agent to fetch the key into.
+func serverKeysMounts(server *vpnv1alpha1.VPNServer) []corev1.VolumeMount {
+	mounts := []corev1.VolumeMount{{Name: keysVolumeName, MountPath: keysMountPath, ReadOnly: !usesVaultKeyStore(server)}}
+	if usesVaultKeyStore(server) && server.Spec.KeyStore.Vault.Engine == vpnv1alpha1.VaultEngineTransit {
+		mounts = append(mounts, corev1.VolumeMount{Name: sealedKeysVolumeName, MountPath: sealedKeysMountPath, ReadOnly: true})
+	}
+	return mounts
+}
+
+// waitForKeyInitContainer returns the init container that waits for the
+// server key to be available and valid before the WireGuard container
+// starts, fetching it from Vault with a Vault key store.
+func waitForKeyInitContainer(server *vpnv1alpha1.VPNServer) corev1.Container {
+	return corev1.Container{
+		Name:    "wait-for-key",
+		Image:   rolloutImage(server),
+		Command: []string{"/scripts/wait-for-key.sh"},
+		Env: append([]corev1.EnvVar{
+			{Name: "WG_KEY_FILE", Value: keysMountPath + "/server_private"},
+		}, vaultEnv(server)...),
+		VolumeMounts: serverKeysMounts(server),
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("10m"),
+				corev1.ResourceMemory: resource.MustParse("16Mi"),
+			},
+		},
+	}
+}
@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/mail"
+)
+
+const (
+	// defaultInviteTTL is how long invites can be accepted when the peer
+	// does not say
+	defaultInviteTTL = 72 * time.Hour
+
+	// inviteTokenKey is the key of the token in invite Secrets
+	inviteTokenKey = "token"
+)
+
+// InviteReconciler provisions placeholder peers created with an invite: it
+// reserves their address, issues the invite token, emails it to invitees
+// with an address and deletes the peer when the invite expires. Invites are
+// accepted through the EnrollmentServer.
+type InviteReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// Mailer sends the invite emails. Invites are not emailed if nil.
+	Mailer mail.Sender
+
+	// EnrollmentURL is the URL invitees reach the EnrollmentServer on,
+	// linked from the invite emails
+	EnrollmentURL string
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch;update;patch;delete
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnbrandings,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile provisions the invite of a placeholder peer, or cleans it up
+// once accepted.
+func (r *InviteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	peer := &vpnv1alpha1.VPNPeer{}
+	if err := r.Get(ctx, req.NamespacedName, peer); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if peer.Spec.Invite == nil || !peer.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	original := peer.DeepCopy()
+	secretName := peer.Name + "-invite"
+
+	if peer.Spec.PublicKey != "" {
+		// Accepted: the token must not be usable again
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: peer.Namespace, Name: secretName}}
+		if err := r.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+		if peer.Status.Invite == nil || peer.Status.Invite.AcceptedAt != nil {
+			return ctrl.Result{}, nil
+		}
+		now := metav1.Now()
+		peer.Status.Invite.AcceptedAt = &now
+		peer.Status.Invite.TokenSecretName = ""
+		return ctrl.Result{}, r.Status().Patch(ctx, peer, client.MergeFrom(original))
+	}
+
+	ttl := defaultInviteTTL
+	if peer.Spec.Invite.TTL != nil {
+		ttl = peer.Spec.Invite.TTL.Duration
+	}
+	expiresAt := metav1.NewTime(peer.CreationTimestamp.Add(ttl))
+	if remaining := time.Until(expiresAt.Time); remaining <= 0 {
+		logger.Info("invite expired, releasing the placeholder peer")
+		r.Recorder.Event(peer, corev1.EventTypeNormal, "InviteExpired",
+			fmt.Sprintf("The invite was not accepted within %s, releasing address %s", ttl, peer.Spec.Address))
+		return ctrl.Result{}, client.IgnoreNotFound(r.Delete(ctx, peer))
+	}
+
+	if peer.Spec.Address == "" {
+		server := &vpnv1alpha1.VPNServer{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: peer.Namespace, Name: peer.Spec.ServerRef.Name}, server); err != nil {
+			// The peer controller reports the missing server
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+		address, err := allocateAddress(ctx, r.Client, peer, server)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if address == "" {
+			r.Recorder.Event(peer, corev1.EventTypeWarning, "AddressExhausted",
+				fmt.Sprintf("VPNServer %s has no free address left for the invite", server.Name))
+			return ctrl.Result{RequeueAfter: time.Minute}, nil
+		}
+		peer.Spec.Address = address
+		if err := r.Update(ctx, peer); err != nil {
+			return ctrl.Result{}, err
+		}
+		original = peer.DeepCopy()
+	}
+
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: peer.Namespace, Name: secretName}, secret)
+	if apierrors.IsNotFound(err) {
+		token, err := inviteToken()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: peer.Namespace, Name: secretName},
+			Data:       map[string][]byte{inviteTokenKey: []byte(token)},
+		}
+		if err := controllerutil.SetControllerReference(peer, secret, r.Scheme); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.Create(ctx, secret); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Event(peer, corev1.EventTypeNormal, "InviteIssued",
+			fmt.Sprintf("Issued the invite token in Secret %s, valid until %s", secretName, expiresAt.UTC().Format(time.RFC3339)))
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var emailSentAt *metav1.Time
+	if peer.Status.Invite != nil {
+		emailSentAt = peer.Status.Invite.EmailSentAt
+	}
+	if emailSentAt == nil && peer.Spec.Invite.Email != "" && r.Mailer != nil {
+		if err := r.sendInvite(ctx, peer, string(secret.Data[inviteTokenKey]), expiresAt.Time); err != nil {
+			r.Recorder.Event(peer, corev1.EventTypeWarning, "InviteEmailFailed",
+				fmt.Sprintf("Unable to email the invite to %s: %s", peer.Spec.Invite.Email, err))
+			return ctrl.Result{}, err
+		}
+		now := metav1.Now()
+		emailSentAt = &now
+		r.Recorder.Event(peer, corev1.EventTypeNormal, "InviteEmailed",
+			fmt.Sprintf("Emailed the invite to %s", peer.Spec.Invite.Email))
+	}
+
+	peer.Status.Invite = &vpnv1alpha1.InviteStatus{TokenSecretName: secretName, ExpiresAt: &expiresAt, EmailSentAt: emailSentAt}
+	if !equality.Semantic.DeepEqual(original.Status, peer.Status) {
+		if err := r.Status().Patch(ctx, peer, client.MergeFrom(original)); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: time.Until(expiresAt.Time)}, nil
+}
+
+// sendInvite emails the invite of a peer with the templates of the branding
+// of its server.
+func (r *InviteReconciler) sendInvite(ctx context.Context, peer *vpnv1alpha1.VPNPeer, token string, expiresAt time.Time) error {
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: peer.Namespace, Name: peer.Spec.ServerRef.Name}, server); err != nil {
+		return err
+	}
+	branding, err := serverBranding(ctx, r.Client, server)
+	if err != nil {
+		return err
+	}
+	data := newBrandingData(branding, peer, server)
+	data.Token = token
+	data.ExpiresAt = expiresAt
+	if r.EnrollmentURL != "" {
+		data.EnrollmentURL = strings.TrimSuffix(r.EnrollmentURL, "/")
+		data.PortalURL = fmt.Sprintf("%s/enroll/invites/%s/%s?token=%s",
+			data.EnrollmentURL, peer.Namespace, peer.Name, url.QueryEscape(token))
+	}
+	msg, err := renderInviteEmail(branding, data)
+	if err != nil {
+		return err
+	}
+	return r.Mailer.Send(ctx, msg)
+}
+
+// inviteToken returns a new random invite token.
+func inviteToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *InviteReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("invite").
+		For(&vpnv1alpha1.VPNPeer{}).
+		Owns(&corev1.Secret{}).
+		Complete(instrument(mgr, "invite", &vpnv1alpha1.VPNPeer{}, r))
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"html/template"
+	"strings"
+)
+
+// invitePageTemplate is the invite page of the EnrollmentServer, rendered
+// with the brandingData of the invite
+var invitePageTemplate = template.Must(template.New("invite").Funcs(template.FuncMap{"logo": logoURL}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.OrgName}} VPN invite</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 3rem auto; padding: 0 1rem; color: #1f2328; }
+header { display: flex; align-items: center; gap: 1rem; }
+header img { max-height: 3rem; }
+pre { background: #f6f8fa; padding: 1rem; overflow-x: auto; }
+footer { margin-top: 2rem; color: #59636e; }
+</style>
+</head>
+<body>
+<header>
+{{if .LogoURL}}<img src="{{logo .LogoURL}}" alt="{{.OrgName}}">{{end}}
+<h1>{{.OrgName}} VPN</h1>
+</header>
+<p>You are invited to connect the device <strong>{{.Peer.Name}}</strong>.</p>
+<p>Generate the WireGuard key of the device and accept the invite from it. The private key never leaves the device.</p>
+<pre>wg genkey &gt; device.key
+echo '{{.Token}}' &gt; invite.token
+wireflow accept-invite --key-file device.key --token-file invite.token --url {{.EnrollmentURL}} {{.Peer.Namespace}}/{{.Peer.Name}} &gt; {{.Peer.Name}}.conf</pre>
+<p>Then import {{.Peer.Name}}.conf in the WireGuard app.</p>
+{{if not .ExpiresAt.IsZero}}<p>The invite expires on {{.ExpiresAt.UTC.Format "2006-01-02 15:04 MST"}}.</p>{{end}}
+{{if .SupportURL}}<footer>Need help? <a href="{{.SupportURL}}">Contact {{.OrgName}} support</a></footer>{{end}}
+</body>
+</html>
+`))
+
+// logoURL marks the logo URLs allowed by VPNBranding as safe: html/template
+// filters data URLs, which embedded logos are.
+func logoURL(url string) template.URL {
+	if strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "data:image/") {
+		return template.URL(url)
+	}
+	return ""
+}
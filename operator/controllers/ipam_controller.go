@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// IPAMReconciler allocates addresses from the client CIDR of servers to the
+// peers created without one, and reallocates peers whose address is taken.
+// Allocations are claimed in the address claims ConfigMap of the namespace,
+// shared with the other allocators; the claims of deleted peers are
+// released after a grace period. Invite placeholders are addressed by the
+// InviteReconciler, suspended peers when they resume.
+type IPAMReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile allocates the addresses of the peers of a server and updates
+// its allocation status. Addresses are unique per namespace: of the peers
+// sharing one, the peer holding its claim keeps it and the others are
+// reallocated. Peers addressed before claims existed claim their address
+// in creation order, so the oldest keeps it. Peering peers mirror the
+// address of a remote peer and are only reported.
+func (r *IPAMReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	network, err := clientNetwork(server)
+	if err != nil {
+		// Rejected at admission, or reported by the server controller
+		return ctrl.Result{}, nil
+	}
+
+	serverList := &vpnv1alpha1.VPNServerList{}
+	if err := r.List(ctx, serverList, client.InNamespace(server.Namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(server.Namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+	sort.Slice(peers.Items, func(i, j int) bool {
+		a, b := peers.Items[i], peers.Items[j]
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+		return a.Name < b.Name
+	})
+
+	used := map[string]bool{}
+	servers := map[string]string{}
+	var reserved int64
+	for _, other := range serverList.Items {
+		if ip, _ := splitAddress(other.Spec.Address); ip != nil {
+			servers[ip.String()] = other.Name
+			used[ip.String()] = true
+			if network.Contains(ip) {
+				reserved++
+			}
+		}
+	}
+	for i := range peers.Items {
+		peer := &peers.Items[i]
+		if ip, _ := splitAddress(peer.Spec.Address); ip != nil {
+			used[ip.String()] = true
+		}
+		for _, ip := range hostRoutes(peer) {
+			used[ip.String()] = true
+		}
+	}
+	conflicts, err := r.claimAddresses(ctx, server.Namespace, peers.Items, servers)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var allocated int64
+	exhausted := false
+	for i := range peers.Items {
+		peer := &peers.Items[i]
+		if peer.Spec.ServerRef.Name != server.Name || !peer.DeletionTimestamp.IsZero() {
+			continue
+		}
+		previous, _ := splitAddress(peer.Spec.Address)
+		if previous != nil && (conflicts[peer.Name] == "" || peer.Labels[vpnv1alpha1.PeeringLabel] != "") {
+			if network.Contains(previous) && conflicts[peer.Name] == "" {
+				allocated++
+			}
+			continue
+		}
+		if previous == nil && (peer.Status.Suspension != nil || peer.Spec.Invite != nil && peer.Spec.PublicKey == "") || exhausted {
+			continue
+		}
+		ip, err := allocateFrom(ctx, r.Client, peer, []string{network.String()}, used)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if ip == nil {
+			exhausted = true
+			r.Recorder.Eventf(server, corev1.EventTypeWarning, "AddressExhausted",
+				"No free address left in client CIDR %s for peer %s", network, peer.Name)
+			continue
+		}
+		peer.Spec.Address = hostAddress(ip)
+		if err := r.Update(ctx, peer); err != nil {
+			return ctrl.Result{}, err
+		}
+		used[ip.String()] = true
+		allocated++
+		if previous != nil {
+			logger.Info("reallocated address", "peer", peer.Name, "address", peer.Spec.Address, "conflict", conflicts[peer.Name])
+			r.Recorder.Eventf(peer, corev1.EventTypeWarning, "AddressReallocated",
+				"Reallocated address %s from client CIDR %s of VPNServer %s, %s; the client needs its new config", peer.Spec.Address, network, server.Name, conflicts[peer.Name])
+			delete(conflicts, peer.Name)
+			continue
+		}
+		logger.Info("allocated address", "peer", peer.Name, "address", peer.Spec.Address)
+		r.Recorder.Eventf(peer, corev1.EventTypeNormal, "AddressAllocated",
+			"Allocated address %s from client CIDR %s of VPNServer %s", peer.Spec.Address, network, server.Name)
+	}
+
+	for i := range peers.Items {
+		peer := &peers.Items[i]
+		if peer.Spec.ServerRef.Name != server.Name || !peer.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if err := r.reportConflict(ctx, peer, conflicts[peer.Name]); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	requeue, err := pruneAddressClaims(ctx, r.Client, server.Namespace, peers.Items)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	original := server.DeepCopy()
+	server.Status.IPAM = &vpnv1alpha1.IPAMStatus{
+		ClientCIDR: network.String(),
+		Capacity:   poolCapacity([]string{network.String()}) - reserved,
+		Allocated:  allocated,
+	}
+	for i := range peers.Items {
+		if name := peers.Items[i].Name; conflicts[name] != "" && peers.Items[i].Spec.ServerRef.Name == server.Name {
+			server.Status.IPAM.Conflicts = append(server.Status.IPAM.Conflicts, name)
+		}
+	}
+	sort.Strings(server.Status.IPAM.Conflicts)
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return ctrl.Result{RequeueAfter: requeue}, nil
+	}
+	return ctrl.Result{RequeueAfter: requeue}, patchServerStatus(ctx, r.Client, server, original)
+}
+
+// claimAddresses claims the addresses of the peers of a namespace, in their
+// order, and returns the conflicts of the peers whose address is a server
+// address or claimed by another peer. servers maps the server addresses to
+// their server. The link peers of a VPNNetwork hold the address of the
+// server they stand for, and the claims of peers that no longer hold their
+// address are taken over once past their grace period.
+func (r *IPAMReconciler) claimAddresses(ctx context.Context, namespace string, peers []vpnv1alpha1.VPNPeer, servers map[string]string) (map[string]string, error) {
+	held := heldAddresses(peers)
+	var conflicts map[string]string
+	err := updateAddressClaims(ctx, r.Client, namespace, func(claims map[string]addressClaim) bool {
+		conflicts = map[string]string{}
+		changed := false
+		for i := range peers {
+			peer := &peers[i]
+			ip, _ := splitAddress(peer.Spec.Address)
+			if ip == nil {
+				continue
+			}
+			if owner, taken := servers[ip.String()]; taken {
+				if owner != peer.Labels[vpnv1alpha1.NetworkServerLabel] {
+					conflicts[peer.Name] = fmt.Sprintf("address %s is taken by VPNServer %s", ip, owner)
+				}
+				continue
+			}
+			key := addressClaimKey(ip)
+			claim, claimed := claims[key]
+			switch {
+			case claimed && claim.Peer == peer.Name:
+			case claimed && (held[claim.Peer][key] || time.Since(claim.Time.Time) < addressClaimGracePeriod):
+				conflicts[peer.Name] = fmt.Sprintf("address %s is taken by VPNPeer %s", ip, claim.Peer)
+			default:
+				claims[key] = addressClaim{Peer: peer.Name, Time: metav1.Now()}
+				changed = true
+			}
+		}
+		return changed
+	})
+	return conflicts, err
+}
+
+// reportConflict sets the AddressConflict condition of a peer, or resolves
+// it once the peer no longer conflicts.
+func (r *IPAMReconciler) reportConflict(ctx context.Context, peer *vpnv1alpha1.VPNPeer, conflict string) error {
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionAddressConflict,
+		Status:             vpnv1alpha1.ConditionFalse,
+		Reason:             "Unique",
+		Message:            "the address of the peer is not used by another peer or server",
+		ObservedGeneration: peer.Generation,
+	}
+	if conflict != "" {
+		condition.Status = vpnv1alpha1.ConditionTrue
+		condition.Reason = "AddressTaken"
+		condition.Message = conflict
+	} else if vpnv1alpha1.FindCondition(peer.Status.Conditions, condition.Type) == nil {
+		return nil
+	}
+
+	original := peer.DeepCopy()
+	if conflict != "" && !vpnv1alpha1.IsConditionTrue(peer.Status.Conditions, condition.Type) {
+		r.Recorder.Event(peer, corev1.EventTypeWarning, "AddressConflict", conflict)
+	}
+	vpnv1alpha1.SetCondition(&peer.Status.Conditions, condition)
+	if equality.Semantic.DeepEqual(original.Status, peer.Status) {
+		return nil
+	}
+	return r.Status().Patch(ctx, peer, client.MergeFrom(original))
+}
+
+// clientNetwork returns the network the addresses of the peers of a server
+// are allocated from.
+func clientNetwork(server *vpnv1alpha1.VPNServer) (*net.IPNet, error) {
+	cidr := server.Spec.ClientCIDR
+	if cidr == "" {
+		cidr = server.Spec.Address
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("VPNServer %s has no client network: %w", server.Name, err)
+	}
+	return network, nil
+}
+
+// hostAddress returns the host route of an allocated address.
+func hostAddress(ip net.IP) string {
+	if ip.To4() == nil {
+		return ip.String() + "/128"
+	}
+	return ip.String() + "/32"
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *IPAMReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("ipam").
+		For(&vpnv1alpha1.VPNServer{}).
+		Watches(&vpnv1alpha1.VPNPeer{}, serverForPeerCoalesced()).
+		Complete(instrument(mgr, "ipam", &vpnv1alpha1.VPNServer{}, r))
+}
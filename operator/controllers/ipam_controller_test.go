@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// storedAddress returns the address of a peer as stored.
+func storedAddress(t *testing.T, c client.Client, name string) string {
+	t.Helper()
+	peer := &vpnv1alpha1.VPNPeer{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: testNamespace, Name: name}, peer); err != nil {
+		t.Fatal(err)
+	}
+	return peer.Spec.Address
+}
+
+func TestAllocateFromStaleViews(t *testing.T) {
+	c, _ := newTestClient(t)
+	laptop := testPeer("laptop", "edge", testKeyA, "")
+	phone := testPeer("phone", "edge", testKeyB, "")
+
+	// Both allocators see the same peers, neither sees the other allocation
+	used := map[string]bool{"10.8.0.1": true}
+	first, err := allocateFrom(context.Background(), c, laptop, []string{"10.8.0.0/24"}, used)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := allocateFrom(context.Background(), c, phone, []string{"10.8.0.0/24"}, used)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != "10.8.0.2" || second.String() != "10.8.0.3" {
+		t.Errorf("allocated %s and %s, want 10.8.0.2 and 10.8.0.3", first, second)
+	}
+
+	// A retried allocation returns the claimed address
+	again, err := allocateFrom(context.Background(), c, laptop, []string{"10.8.0.0/24"}, used)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Equal(first) {
+		t.Errorf("retried allocation = %s, want %s", again, first)
+	}
+
+	if err := releaseAddress(context.Background(), c, laptop, "10.8.0.2/32"); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := claimAddress(context.Background(), c, phone, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !claimed {
+		t.Error("released address not claimable")
+	}
+}
+
+func TestIPAMReconcilerReallocatesConflicts(t *testing.T) {
+	server := testServer("edge")
+	created := metav1.NewTime(time.Now().Add(-time.Hour))
+	older := testPeer("laptop", "edge", testKeyA, "10.8.0.2/32")
+	older.CreationTimestamp = created
+	newer := testPeer("phone", "edge", testKeyB, "10.8.0.2/32")
+	newer.CreationTimestamp = metav1.NewTime(created.Add(time.Minute))
+	serverAddress := testPeer("tablet", "edge", testKeyC, "10.8.0.1/32")
+	serverAddress.CreationTimestamp = metav1.NewTime(created.Add(2 * time.Minute))
+	c, scheme := newTestClient(t, server, older, newer, serverAddress)
+	r := &IPAMReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(10)}
+
+	updated := reconcileServer(t, r, c, server)
+	if address := storedAddress(t, c, "laptop"); address != "10.8.0.2/32" {
+		t.Errorf("oldest peer address = %s, want it kept", address)
+	}
+	if address := storedAddress(t, c, "phone"); address != "10.8.0.3/32" {
+		t.Errorf("conflicting peer address = %s, want 10.8.0.3/32", address)
+	}
+	if address := storedAddress(t, c, "tablet"); address != "10.8.0.4/32" {
+		t.Errorf("peer holding the server address = %s, want 10.8.0.4/32", address)
+	}
+	if ipam := updated.Status.IPAM; ipam == nil || ipam.Allocated != 3 || len(ipam.Conflicts) != 0 {
+		t.Errorf("ipam status = %+v, want 3 allocated and no conflicts", ipam)
+	}
+}
+
+func TestIPAMReconcilerKeepsClaimHolder(t *testing.T) {
+	server := testServer("edge")
+	created := metav1.NewTime(time.Now().Add(-time.Hour))
+	older := testPeer("laptop", "edge", testKeyA, "10.8.0.2/32")
+	older.CreationTimestamp = created
+	holder := testPeer("phone", "edge", testKeyB, "10.8.0.2/32")
+	holder.CreationTimestamp = metav1.NewTime(created.Add(time.Minute))
+	raw, err := json.Marshal(addressClaim{Peer: "phone", Time: created})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: addressClaimsName},
+		Data:       map[string]string{"10.8.0.2": string(raw)},
+	}
+	c, scheme := newTestClient(t, server, older, holder, claims)
+	r := &IPAMReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(10)}
+
+	reconcileServer(t, r, c, server)
+	if address := storedAddress(t, c, "phone"); address != "10.8.0.2/32" {
+		t.Errorf("claim holder address = %s, want it kept", address)
+	}
+	if address := storedAddress(t, c, "laptop"); address != "10.8.0.3/32" {
+		t.Errorf("conflicting peer address = %s, want 10.8.0.3/32", address)
+	}
+}
@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// kernelVersion is the major and minor version of a Linux kernel
+type kernelVersion struct {
+	major, minor int
+}
+
+func (v kernelVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.major, v.minor)
+}
+
+func (v kernelVersion) less(other kernelVersion) bool {
+	return v.major < other.major || v.major == other.major && v.minor < other.minor
+}
+
+// parseKernelVersion parses the version of a kernel release as reported by
+// the kubelet, e.g. 5.15.0-1051-aws.
+func parseKernelVersion(release string) (kernelVersion, bool) {
+	fields := strings.SplitN(release, ".", 3)
+	if len(fields) < 2 {
+		return kernelVersion{}, false
+	}
+	major, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return kernelVersion{}, false
+	}
+	// The minor version may carry a suffix, as in 4.19-rc1
+	minor := fields[1]
+	if i := strings.IndexFunc(minor, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		minor = minor[:i]
+	}
+	m, err := strconv.Atoi(minor)
+	if err != nil {
+		return kernelVersion{}, false
+	}
+	return kernelVersion{major, m}, true
+}
+
+// kernelRequirement is an entry of the kernel compatibility matrix: a
+// feature used by servers and the oldest kernel providing it
+type kernelRequirement struct {
+	feature string
+	minimum kernelVersion
+	hint    string
+	usedBy  func(server *vpnv1alpha1.VPNServer) bool
+}
+
+// kernelMatrix lists the kernel features the agent relies on that are not
+// available on every kernel still run by nodes.
+var kernelMatrix = []kernelRequirement{
+	{
+		// Mainline since 5.6, the module backport builds from 3.10 on
+		feature: "in-kernel WireGuard",
+		minimum: kernelVersion{3, 10},
+		hint:    "set spec.implementation to userspace",
+		usedBy: func(server *vpnv1alpha1.VPNServer) bool {
+			return server.Spec.Implementation != vpnv1alpha1.WireGuardUserspace
+		},
+	},
+	{
+		// The agent programs NAT and redirects in an inet table
+		feature: "nftables NAT in the inet family",
+		minimum: kernelVersion{5, 2},
+		hint:    "set spec.firewallBackend to iptables-legacy",
+		usedBy: func(server *vpnv1alpha1.VPNServer) bool {
+			return server.Spec.FirewallBackend == vpnv1alpha1.FirewallBackendNFTables
+		},
+	},
+}
+
+// unsupportedFeatures returns the features used by a server that a kernel
+// release lacks, described for the condition message. Unparseable releases
+// are assumed to support everything.
+func unsupportedFeatures(server *vpnv1alpha1.VPNServer, release string) []string {
+	version, ok := parseKernelVersion(release)
+	if !ok {
+		return nil
+	}
+	var unsupported []string
+	for _, requirement := range kernelMatrix {
+		if requirement.usedBy(server) && version.less(requirement.minimum) {
+			unsupported = append(unsupported, fmt.Sprintf("%s requires kernel %s (%s)", requirement.feature, requirement.minimum, requirement.hint))
+		}
+	}
+	return unsupported
+}
+
+// KernelCompatReconciler checks the features a server uses against the
+// kernels of the nodes it can be scheduled on, and reports unsupported ones
+// in the FeatureUnsupportedOnNode condition instead of leaving them to fail
+// in the agent.
+type KernelCompatReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+
+// Reconcile evaluates the kernel compatibility matrix for a server.
+func (r *KernelCompatReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// Servers placed on member clusters are checked by their operator
+	if !server.DeletionTimestamp.IsZero() || server.Spec.ClusterName != "" {
+		return ctrl.Result{}, nil
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes, client.MatchingLabelsSelector{Selector: labels.SelectorFromSet(server.Spec.NodeSelector)}); err != nil {
+		return ctrl.Result{}, err
+	}
+	unsupported := map[string][]string{}
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		for _, feature := range unsupportedFeatures(server, node.Status.NodeInfo.KernelVersion) {
+			unsupported[feature] = append(unsupported[feature], node.Name)
+		}
+	}
+
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionFeatureUnsupportedOnNode,
+		Status:             vpnv1alpha1.ConditionFalse,
+		Reason:             "Supported",
+		ObservedGeneration: server.Generation,
+	}
+	if len(unsupported) > 0 {
+		messages := make([]string, 0, len(unsupported))
+		for feature, names := range unsupported {
+			sort.Strings(names)
+			messages = append(messages, fmt.Sprintf("%s, on nodes %s", feature, strings.Join(names, ", ")))
+		}
+		sort.Strings(messages)
+		condition.Status = vpnv1alpha1.ConditionTrue
+		condition.Reason = "KernelTooOld"
+		condition.Message = strings.Join(messages, "; ")
+	}
+
+	current := vpnv1alpha1.FindCondition(server.Status.Conditions, condition.Type)
+	if current != nil && current.Status == condition.Status && current.Message == condition.Message && current.ObservedGeneration == condition.ObservedGeneration {
+		return ctrl.Result{}, nil
+	}
+	if condition.Status == vpnv1alpha1.ConditionTrue {
+		log.FromContext(ctx).Info("server uses features unsupported on nodes", "features", condition.Message)
+	}
+	original := server.DeepCopy()
+	vpnv1alpha1.SetCondition(&server.Status.Conditions, condition)
+	return ctrl.Result{}, patchServerStatus(ctx, r.Client, server, original)
+}
+
+// serversForNode maps a node to every server, as any can be scheduled on it.
+func (r *KernelCompatReconciler) serversForNode(ctx context.Context, obj client.Object) []reconcile.Request {
+	servers := &vpnv1alpha1.VPNServerList{}
+	if err := r.List(ctx, servers); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list servers")
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(servers.Items))
+	for i := range servers.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&servers.Items[i])})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KernelCompatReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Node status is updated continuously, only kernel upgrades, label and
+	// schedulability changes affect the matrix
+	nodeChanged := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			previous, node := e.ObjectOld.(*corev1.Node), e.ObjectNew.(*corev1.Node)
+			return previous.Status.NodeInfo.KernelVersion != node.Status.NodeInfo.KernelVersion ||
+				previous.Spec.Unschedulable != node.Spec.Unschedulable ||
+				!labels.Equals(previous.Labels, node.Labels)
+		},
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("kernelcompat").
+		For(&vpnv1alpha1.VPNServer{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(r.serversForNode), builder.WithPredicates(nodeChanged)).
+		Complete(instrument(mgr, "kernelcompat", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+)
+
+const (
+	// defaultKeyRotationGracePeriod is how long the next server key is
+	// published before the switch when the rotation does not say
+	defaultKeyRotationGracePeriod = 24 * time.Hour
+
+	// nextKeySuffix is appended to the key of the private key in the Secret
+	// to hold the next key during the grace period
+	nextKeySuffix = ".next"
+)
+
+// rotateKey advances the key rotation of a server whose active private key
+// is key, and whose keys were first stored at created. The next key is
+// generated into the store a grace period before the switch and moved over
+// the active key at the switch, which the agents apply to the interface
+// once the kubelet refreshes the keys volume, or once they fetch it from
+// Vault. It returns the active key and when to check the rotation again.
+func (r *ServerKeyReconciler) rotateKey(ctx context.Context, server *vpnv1alpha1.VPNServer, store serverKeyStore, keys map[string][]byte, created time.Time, dataKey string, key *escrow.Key, now time.Time) (*escrow.Key, time.Duration, error) {
+	rotation := server.Spec.KeyRotation
+	grace := defaultKeyRotationGracePeriod
+	if rotation.GracePeriod != nil {
+		grace = rotation.GracePeriod.Duration
+	}
+	if grace > rotation.Interval.Duration {
+		grace = rotation.Interval.Duration
+	}
+	if server.Status.KeyRotation == nil {
+		server.Status.KeyRotation = &vpnv1alpha1.KeyRotationStatus{}
+	}
+	status := server.Status.KeyRotation
+	last := created
+	if status.LastRotationTime != nil {
+		last = status.LastRotationTime.Time
+	}
+	nextKey := dataKey + nextKeySuffix
+
+	if status.SwitchTime == nil {
+		start := last.Add(rotation.Interval.Duration - grace)
+		if now.Before(start) {
+			setKeyRotationCondition(server, vpnv1alpha1.ConditionFalse, "Scheduled",
+				fmt.Sprintf("the next key is published at %s", start.UTC().Format(time.RFC3339)))
+			return key, start.Sub(now), nil
+		}
+		next, err := ensureNextKey(ctx, store, keys, nextKey)
+		if err != nil {
+			return nil, 0, err
+		}
+		switchTime := metav1.NewTime(now.Add(grace))
+		status.NextPublicKey = next.PublicKey().String()
+		status.SwitchTime = &switchTime
+		r.Recorder.Event(server, corev1.EventTypeNormal, "KeyRotationStarted",
+			fmt.Sprintf("Published the next key %s, the server switches to it at %s", status.NextPublicKey, switchTime.UTC().Format(time.RFC3339)))
+	}
+
+	if now.Before(status.SwitchTime.Time) {
+		// The next key is regenerated if it was removed from the store
+		next, err := ensureNextKey(ctx, store, keys, nextKey)
+		if err != nil {
+			return nil, 0, err
+		}
+		status.NextPublicKey = next.PublicKey().String()
+		setKeyRotationCondition(server, vpnv1alpha1.ConditionTrue, "GracePeriod",
+			fmt.Sprintf("the server switches to key %s at %s", status.NextPublicKey, status.SwitchTime.UTC().Format(time.RFC3339)))
+		return key, status.SwitchTime.Sub(now), nil
+	}
+
+	// Clients may drop their tunnel at the switch, it waits for a window
+	if allowed, window := server.MaintenanceAllowed(now); !allowed {
+		message := fmt.Sprintf("the switch to key %s waits for a maintenance window", status.NextPublicKey)
+		retry := time.Hour
+		if !window.IsZero() {
+			message += " opening at " + window.UTC().Format(time.RFC3339)
+			retry = window.Sub(now)
+		}
+		setKeyRotationCondition(server, vpnv1alpha1.ConditionTrue, "AwaitingMaintenanceWindow", message)
+		return key, retry, nil
+	}
+
+	next, err := escrow.ParseKey(string(keys[nextKey]))
+	if err != nil {
+		// Restart the grace period rather than switch to a key no client knows
+		status.SwitchTime = nil
+		status.NextPublicKey = ""
+		return key, time.Second, nil
+	}
+	keys[dataKey] = []byte(next.String())
+	if _, ok := keys[serverPublicKeyKey]; ok || dataKey == defaultServerPrivateKeyKey {
+		keys[serverPublicKeyKey] = []byte(next.PublicKey().String())
+	}
+	delete(keys, nextKey)
+	if err := store.save(ctx, keys); err != nil {
+		return nil, 0, err
+	}
+	rotated := metav1.NewTime(now)
+	status.LastRotationTime = &rotated
+	status.NextPublicKey = ""
+	status.SwitchTime = nil
+	setKeyRotationCondition(server, vpnv1alpha1.ConditionFalse, "Rotated",
+		fmt.Sprintf("switched from key %s to %s at %s", key.PublicKey(), next.PublicKey(), now.UTC().Format(time.RFC3339)))
+	r.Recorder.Event(server, corev1.EventTypeNormal, "KeyRotated",
+		fmt.Sprintf("Switched the server to key %s", next.PublicKey()))
+	return next, rotation.Interval.Duration - grace, nil
+}
+
+// ensureNextKey returns the next key held in the store, generating it if
+// the store has none.
+func ensureNextKey(ctx context.Context, store serverKeyStore, keys map[string][]byte, nextKey string) (*escrow.Key, error) {
+	if next, err := escrow.ParseKey(string(keys[nextKey])); err == nil {
+		return next, nil
+	}
+	next, err := escrow.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	keys[nextKey] = []byte(next.String())
+	if err := store.save(ctx, keys); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// setKeyRotationCondition sets the KeyRotationPending condition of a server.
+func setKeyRotationCondition(server *vpnv1alpha1.VPNServer, status, reason, message string) {
+	vpnv1alpha1.SetCondition(&server.Status.Conditions, vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionKeyRotationPending,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: server.Generation,
+	})
+}
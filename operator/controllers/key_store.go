@@ -0,0 +1,260 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/vault"
+)
+
+// serverKeyStore holds the keys of a server by data key: the private key,
+// the next private key during a rotation and the public key.
+type serverKeyStore interface {
+	// load returns the stored keys and when they were first stored, or nil
+	// keys if none are stored yet
+	load(ctx context.Context) (map[string][]byte, time.Time, error)
+
+	// save stores the keys
+	save(ctx context.Context, keys map[string][]byte) error
+
+	// String describes the store in events
+	String() string
+}
+
+// keyStoreFor returns the key store of a server, or nil if the operator
+// does not manage its keys.
+func (r *ServerKeyReconciler) keyStoreFor(server *vpnv1alpha1.VPNServer) serverKeyStore {
+	ref := server.Spec.PrivateKeySecretRef
+	var secretStore *secretKeyStore
+	if ref != nil {
+		secretStore = &secretKeyStore{Client: r.Client, scheme: r.Scheme, server: server, name: ref.Name}
+	}
+	store := server.Spec.KeyStore
+	if store == nil || store.Type != vpnv1alpha1.KeyStoreVault || store.Vault == nil {
+		if secretStore == nil {
+			return nil
+		}
+		return secretStore
+	}
+
+	config := store.Vault
+	client := r.vaultClient(config)
+	if config.Engine == vpnv1alpha1.VaultEngineTransit {
+		if secretStore == nil {
+			return nil
+		}
+		return &transitKeyStore{secret: secretStore, vault: client, mount: vaultMount(config), key: vaultPath(server)}
+	}
+	return &kvKeyStore{vault: client, mount: vaultMount(config), path: vaultPath(server)}
+}
+
+// vaultMount returns the mount of the engine of a Vault key store.
+func vaultMount(config *vpnv1alpha1.VaultKeyStore) string {
+	switch {
+	case config.Mount != "":
+		return config.Mount
+	case config.Engine == vpnv1alpha1.VaultEngineTransit:
+		return "transit"
+	}
+	return "secret"
+}
+
+// vaultPath returns the KV path or the transit key of the Vault key store
+// of a server.
+func vaultPath(server *vpnv1alpha1.VPNServer) string {
+	config := server.Spec.KeyStore.Vault
+	switch {
+	case config.Path != "":
+		return config.Path
+	case config.Engine == vpnv1alpha1.VaultEngineTransit:
+		return "wireflow"
+	}
+	return "wireflow/" + server.Namespace + "/" + server.Name
+}
+
+// vaultClients are the Vault clients of the operator by address and auth
+// mount, so that their tokens are reused and renewed across reconciles
+type vaultClients struct {
+	mu      sync.Mutex
+	clients map[string]*vault.Client
+}
+
+// vaultClient returns the client of the operator for the Vault of a key
+// store.
+func (r *ServerKeyReconciler) vaultClient(config *vpnv1alpha1.VaultKeyStore) *vault.Client {
+	r.vault.mu.Lock()
+	defer r.vault.mu.Unlock()
+	if r.vault.clients == nil {
+		r.vault.clients = map[string]*vault.Client{}
+	}
+	id := config.Address + "|" + config.AuthMount
+	if c, ok := r.vault.clients[id]; ok {
+		return c
+	}
+	c := &vault.Client{Address: config.Address, AuthMount: config.AuthMount, Role: r.VaultRole}
+	r.vault.clients[id] = c
+	return c
+}
+
+// secretKeyStore stores the keys in a Secret owned by the server, so it
+// follows its deletion policy
+type secretKeyStore struct {
+	client.Client
+	scheme *runtime.Scheme
+	server *vpnv1alpha1.VPNServer
+	name   string
+
+	secret *corev1.Secret
+}
+
+func (s *secretKeyStore) load(ctx context.Context) (map[string][]byte, time.Time, error) {
+	secret := &corev1.Secret{}
+	err := s.Get(ctx, types.NamespacedName{Namespace: s.server.Namespace, Name: s.name}, secret)
+	if apierrors.IsNotFound(err) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	s.secret = secret
+	// A Secret without keys is the user's, it is not generated into
+	keys := secret.Data
+	if keys == nil {
+		keys = map[string][]byte{}
+	}
+	return keys, secret.CreationTimestamp.Time, nil
+}
+
+func (s *secretKeyStore) save(ctx context.Context, keys map[string][]byte) error {
+	if s.secret != nil {
+		s.secret.Data = keys
+		return s.Update(ctx, s.secret)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: s.server.Namespace,
+			Name:      s.name,
+			Labels:    serverLabels(s.server),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: keys,
+	}
+	if err := controllerutil.SetControllerReference(s.server, secret, s.scheme); err != nil {
+		return err
+	}
+	if err := s.Create(ctx, secret); err != nil {
+		return err
+	}
+	s.secret = secret
+	return nil
+}
+
+func (s *secretKeyStore) String() string {
+	return "Secret " + s.name
+}
+
+// kvKeyStore stores the keys in a KV version 2 secret of Vault. Each save
+// writes a new version, so the previous keys stay recoverable with the
+// versioning of the engine.
+type kvKeyStore struct {
+	vault *vault.Client
+	mount string
+	path  string
+}
+
+func (s *kvKeyStore) load(ctx context.Context) (map[string][]byte, time.Time, error) {
+	kv, err := s.vault.ReadKV(ctx, s.mount, s.path)
+	if errors.Is(err, vault.ErrNotFound) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	keys := make(map[string][]byte, len(kv.Data))
+	for key, value := range kv.Data {
+		keys[key] = []byte(value)
+	}
+	return keys, kv.CreatedTime, nil
+}
+
+func (s *kvKeyStore) save(ctx context.Context, keys map[string][]byte) error {
+	data := make(map[string]string, len(keys))
+	for key, value := range keys {
+		data[key] = string(value)
+	}
+	return s.vault.WriteKV(ctx, s.mount, s.path, data)
+}
+
+func (s *kvKeyStore) String() string {
+	return fmt.Sprintf("Vault secret %s/%s", s.mount, s.path)
+}
+
+// transitKeyStore stores the keys in a Secret, the private keys encrypted
+// with a transit key of Vault. The public key is stored in the clear.
+type transitKeyStore struct {
+	secret *secretKeyStore
+	vault  *vault.Client
+	mount  string
+	key    string
+}
+
+func (s *transitKeyStore) load(ctx context.Context) (map[string][]byte, time.Time, error) {
+	stored, created, err := s.secret.load(ctx)
+	if err != nil || stored == nil {
+		return stored, created, err
+	}
+	keys := make(map[string][]byte, len(stored))
+	plaintext := false
+	for key, value := range stored {
+		if !vault.IsCiphertext(string(value)) {
+			keys[key] = value
+			plaintext = plaintext || key != serverPublicKeyKey
+			continue
+		}
+		decrypted, err := s.vault.Decrypt(ctx, s.mount, s.key, string(value))
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("unable to decrypt %s of Secret %s: %w", key, s.secret.name, err)
+		}
+		keys[key] = decrypted
+	}
+	// Private keys stored in the clear, e.g. before the store switched to
+	// Transit, are encrypted in place
+	if plaintext {
+		if err := s.save(ctx, keys); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+	return keys, created, nil
+}
+
+func (s *transitKeyStore) save(ctx context.Context, keys map[string][]byte) error {
+	stored := make(map[string][]byte, len(keys))
+	for key, value := range keys {
+		if key == serverPublicKeyKey {
+			stored[key] = value
+			continue
+		}
+		ciphertext, err := s.vault.Encrypt(ctx, s.mount, s.key, value)
+		if err != nil {
+			return fmt.Errorf("unable to encrypt %s with transit key %s: %w", key, s.key, err)
+		}
+		stored[key] = []byte(ciphertext)
+	}
+	return s.secret.save(ctx, stored)
+}
+
+func (s *transitKeyStore) String() string {
+	return fmt.Sprintf("Secret %s encrypted with Vault transit key %s/%s", s.secret.name, s.mount, s.key)
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+)
+
+// KeyEscrowReconciler escrows the private keys of labelled Secrets. Each key
+// is sealed to the organisation recovery key and stored in a Secret of the
+// escrow namespace, which is kept when the original Secret is deleted.
+type KeyEscrowReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Recipient is the recovery public key keys are sealed to
+	Recipient *escrow.Key
+
+	// Namespace is the namespace escrowed keys are stored in
+	Namespace string
+}
+
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+
+// Reconcile escrows the private keys of a Secret.
+func (r *KeyEscrowReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, req.NamespacedName, secret); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if secret.Labels[vpnv1alpha1.KeyEscrowLabel] != "true" || !secret.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	escrowed := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: r.Namespace,
+			Name:      escrowSecretName(secret),
+		},
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(escrowed), escrowed); client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+	// Sealing is randomised, only reseal when the keys or recipient changed
+	if escrowed.Annotations[escrow.SourceVersionAnnotation] == secret.ResourceVersion &&
+		escrowed.Annotations[escrow.RecipientAnnotation] == r.Recipient.Fingerprint() {
+		return ctrl.Result{}, nil
+	}
+
+	data := map[string][]byte{}
+	for name, value := range secret.Data {
+		if !escrow.IsPrivateKeyEntry(name) {
+			continue
+		}
+		sealed, err := escrow.Seal(r.Recipient, value)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		data[name] = sealed
+		if key, err := escrow.ParseKey(string(value)); err == nil {
+			data[name+escrow.PublicKeySuffix] = []byte(key.PublicKey().String())
+		}
+	}
+	if len(data) == 0 {
+		logger.Info("secret labelled for escrow holds no private keys")
+		return ctrl.Result{}, nil
+	}
+
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, escrowed, func() error {
+		if escrowed.Annotations == nil {
+			escrowed.Annotations = map[string]string{}
+		}
+		escrowed.Annotations[escrow.SourceAnnotation] = req.String()
+		escrowed.Annotations[escrow.SourceVersionAnnotation] = secret.ResourceVersion
+		escrowed.Annotations[escrow.RecipientAnnotation] = r.Recipient.Fingerprint()
+		escrowed.Type = escrow.SecretType
+		escrowed.Data = data
+		return nil
+	})
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to store escrowed keys: %w", err)
+	}
+	if op != controllerutil.OperationResultNone {
+		logger.Info("escrowed private keys", "escrow", client.ObjectKeyFromObject(escrowed), "keys", len(data))
+	}
+	return ctrl.Result{}, nil
+}
+
+// escrowSecretName returns the name of the escrow Secret of a Secret.
+// Namespace names cannot contain dots, so the name is unique.
+func escrowSecretName(secret *corev1.Secret) string {
+	return secret.Namespace + "." + secret.Name
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KeyEscrowReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	labelled := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetLabels()[vpnv1alpha1.KeyEscrowLabel] == "true"
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("keyescrow").
+		For(&corev1.Secret{}, builder.WithPredicates(labelled)).
+		Complete(instrument(mgr, "keyescrow", nil, r))
+}
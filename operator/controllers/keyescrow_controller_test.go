@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+)
+
+func TestKeyEscrowRecoversSealedKeys(t *testing.T) {
+	ctx := context.Background()
+	recovery, err := escrow.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverKey, err := escrow.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testNamespace,
+			Name:      "edge-keys",
+			Labels:    map[string]string{vpnv1alpha1.KeyEscrowLabel: "true"},
+		},
+		Data: map[string][]byte{
+			"privateKey": []byte(serverKey.String()),
+			"publicKey":  []byte(serverKey.PublicKey().String()),
+		},
+	}
+	c, scheme := newTestClient(t, secret)
+	r := &KeyEscrowReconciler{Client: c, Scheme: scheme, Recipient: recovery.PublicKey(), Namespace: "vpn-escrow"}
+
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(secret)}); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	escrowed := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "vpn-escrow", Name: testNamespace + ".edge-keys"}, escrowed); err != nil {
+		t.Fatalf("escrow secret: %v", err)
+	}
+	if _, ok := escrowed.Data["publicKey"]; ok {
+		t.Error("escrowed the public key entry")
+	}
+	if got := string(escrowed.Data["privateKey"+escrow.PublicKeySuffix]); got != serverKey.PublicKey().String() {
+		t.Errorf("recorded public key %q, want %q", got, serverKey.PublicKey().String())
+	}
+
+	sealed := escrowed.Data["privateKey"]
+	if bytes.Contains(sealed, []byte(serverKey.String())) {
+		t.Fatal("escrowed key holds the plaintext")
+	}
+	recovered, err := escrow.Open(recovery, sealed)
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if string(recovered) != serverKey.String() {
+		t.Errorf("recovered %q, want %q", recovered, serverKey.String())
+	}
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 0x01
+	if _, err := escrow.Open(recovery, tampered); err == nil {
+		t.Error("recovered a tampered escrowed key")
+	}
+	other, err := escrow.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := escrow.Open(other, sealed); err == nil {
+		t.Error("recovered an escrowed key with another recovery key")
+	}
+}
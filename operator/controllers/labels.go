@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// serverLabels returns the labels identifying the pods of a server.
+func serverLabels(server *vpnv1alpha1.VPNServer) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       "vpn-server",
+		"app.kubernetes.io/instance":   server.Name,
+		"app.kubernetes.io/managed-by": "vpn-operator",
+	}
+}
+
+// isServerPod returns whether labels are those of a server pod.
+func isServerPod(labels map[string]string) bool {
+	return labels["app.kubernetes.io/managed-by"] == "vpn-operator" && labels["app.kubernetes.io/name"] == "vpn-server"
+}
+
+// serverSelector returns the label selector of the pods of a server in
+// string form, as published in status.selector for the scale subresource.
+func serverSelector(server *vpnv1alpha1.VPNServer) string {
+	return labels.SelectorFromSet(serverLabels(server)).String()
+}
+
+// propagateMetadata sets the labels and annotations the server propagates
+// on a resource created for it. Reserved keys are skipped: the webhook
+// rejects them, but servers admitted before may have them.
+func propagateMetadata(server *vpnv1alpha1.VPNServer, obj metav1.Object) {
+	propagated := server.Spec.PropagateLabels
+	if propagated == nil {
+		return
+	}
+	obj.SetLabels(mergeMetadata(obj.GetLabels(), propagated.Labels))
+	obj.SetAnnotations(mergeMetadata(obj.GetAnnotations(), propagated.Annotations))
+}
+
+// mergeMetadata sets the entries of propagated on current, other than those
+// with prefixes the operator owns.
+func mergeMetadata(current, propagated map[string]string) map[string]string {
+	for key, value := range propagated {
+		if strings.HasPrefix(key, "app.kubernetes.io/") || strings.HasPrefix(key, "vpn.vpn-devops.com/") {
+			continue
+		}
+		if current == nil {
+			current = make(map[string]string, len(propagated))
+		}
+		current[key] = value
+	}
+	return current
+}
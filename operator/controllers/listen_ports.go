@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// servicePorts returns the UDP ports of the server Service: the listen port
+// and the additional listen ports, which the agent redirects to it inside
+// the pod.
+func servicePorts(server *vpnv1alpha1.VPNServer) []corev1.ServicePort {
+	ports := []corev1.ServicePort{{
+		Name:       wireguardPortName,
+		Protocol:   corev1.ProtocolUDP,
+		Port:       server.Spec.Port,
+		TargetPort: intstr.FromInt(int(server.Spec.Port)),
+	}}
+	for _, port := range server.Spec.AdditionalListenPorts {
+		ports = append(ports, corev1.ServicePort{
+			Name:       fmt.Sprintf("%s-%d", wireguardPortName, port),
+			Protocol:   corev1.ProtocolUDP,
+			Port:       port,
+			TargetPort: intstr.FromInt(int(port)),
+		})
+	}
+	return ports
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// MaintenanceReconciler rolls the image of servers out within their
+// maintenance windows, through status.rolloutImage which the replicas are
+// rendered with, and reports the disruptive operations held until the next
+// window in status.pendingOperations: the key switch, which the key
+// rotation holds, the image rollout and the batches of pool migrations.
+type MaintenanceReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpoolmigrations,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile rolls out the image of a server if maintenance is allowed and
+// lists its pending operations otherwise.
+func (r *MaintenanceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	original := server.DeepCopy()
+	now := time.Now()
+	allowed, window := server.MaintenanceAllowed(now)
+
+	// A new server has no replicas to disrupt yet
+	status := &server.Status
+	if status.RolloutImage == "" || (allowed && status.RolloutImage != server.Spec.Image) {
+		if status.RolloutImage != "" {
+			r.Recorder.Eventf(server, corev1.EventTypeNormal, "ImageRolledOut", "Rolling out image %s over %s", server.Spec.Image, status.RolloutImage)
+		}
+		status.RolloutImage = server.Spec.Image
+	}
+
+	var pending []vpnv1alpha1.PendingOperation
+	if !allowed {
+		if rotation := status.KeyRotation; rotation != nil && rotation.SwitchTime != nil && !now.Before(rotation.SwitchTime.Time) {
+			pending = append(pending, vpnv1alpha1.PendingOperation{
+				Type:        vpnv1alpha1.PendingKeyRotation,
+				Description: fmt.Sprintf("switch to key %s", rotation.NextPublicKey),
+			})
+		}
+		if status.RolloutImage != server.Spec.Image {
+			pending = append(pending, vpnv1alpha1.PendingOperation{
+				Type:        vpnv1alpha1.PendingImageRollout,
+				Description: fmt.Sprintf("rollout of image %s over %s", server.Spec.Image, status.RolloutImage),
+			})
+		}
+		migrations := &vpnv1alpha1.VPNPoolMigrationList{}
+		if err := r.List(ctx, migrations, client.InNamespace(server.Namespace)); err != nil {
+			return ctrl.Result{}, err
+		}
+		sort.Slice(migrations.Items, func(i, j int) bool { return migrations.Items[i].Name < migrations.Items[j].Name })
+		for _, migration := range migrations.Items {
+			if migration.Spec.ServerRef.Name != server.Name || migration.Spec.Paused ||
+				migration.Status.Phase != vpnv1alpha1.PoolMigrationPhaseRunning || migration.Status.Readdressed >= migration.Status.Total {
+				continue
+			}
+			pending = append(pending, vpnv1alpha1.PendingOperation{
+				Type: vpnv1alpha1.PendingPoolMigration,
+				Description: fmt.Sprintf("VPNPoolMigration %s, %d peers left to re-address",
+					migration.Name, migration.Status.Total-migration.Status.Readdressed),
+			})
+		}
+	}
+	for i := range pending {
+		operation := &pending[i]
+		operation.Since = metav1.NewTime(now)
+		for _, previous := range original.Status.PendingOperations {
+			if previous.Type == operation.Type && previous.Description == operation.Description {
+				operation.Since = previous.Since
+			}
+		}
+		if !window.IsZero() {
+			notBefore := metav1.NewTime(window)
+			operation.NotBefore = &notBefore
+		}
+	}
+	status.PendingOperations = pending
+
+	var result ctrl.Result
+	if len(pending) > 0 && !window.IsZero() {
+		result.RequeueAfter = window.Sub(now)
+	}
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return result, nil
+	}
+	return result, patchServerStatus(ctx, r.Client, server, original)
+}
+
+// rolloutImage returns the image the replicas of a server are rendered
+// with, which only follows spec.image within its maintenance windows.
+func rolloutImage(server *vpnv1alpha1.VPNServer) string {
+	if server.Status.RolloutImage != "" {
+		return server.Status.RolloutImage
+	}
+	return server.Spec.Image
+}
+
+// serverForPoolMigration maps a VPNPoolMigration to the VPNServer whose
+// peers it migrates.
+func serverForPoolMigration(_ context.Context, obj client.Object) []reconcile.Request {
+	migration, ok := obj.(*vpnv1alpha1.VPNPoolMigration)
+	if !ok || migration.Spec.ServerRef.Name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: migration.Namespace, Name: migration.Spec.ServerRef.Name}}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MaintenanceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("maintenance").
+		For(&vpnv1alpha1.VPNServer{}).
+		Watches(&vpnv1alpha1.VPNPoolMigration{}, handler.EnqueueRequestsFromMapFunc(serverForPoolMigration)).
+		Complete(instrument(mgr, "maintenance", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	// memberClusterProbeInterval is how often member clusters are contacted
+	// to refresh their health
+	memberClusterProbeInterval = time.Minute
+
+	// defaultKubeconfigKey is the Secret key holding the kubeconfig, matching
+	// the Cluster API convention
+	defaultKubeconfigKey = "value"
+)
+
+// memberCluster is a connected member cluster
+type memberCluster struct {
+	client    client.Client
+	namespace string
+}
+
+// ClusterSet holds the clients of the member clusters that are currently
+// reachable, keyed by their MemberCluster.
+type ClusterSet struct {
+	mu       sync.RWMutex
+	clusters map[types.NamespacedName]memberCluster
+}
+
+// NewClusterSet returns an empty ClusterSet.
+func NewClusterSet() *ClusterSet {
+	return &ClusterSet{clusters: map[types.NamespacedName]memberCluster{}}
+}
+
+func (s *ClusterSet) get(key types.NamespacedName) (memberCluster, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	member, ok := s.clusters[key]
+	return member, ok
+}
+
+func (s *ClusterSet) set(key types.NamespacedName, member memberCluster) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clusters[key] = member
+}
+
+func (s *ClusterSet) remove(key types.NamespacedName) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clusters, key)
+}
+
+// MemberClusterReconciler reconciles a MemberCluster object
+type MemberClusterReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Clusters *ClusterSet
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=memberclusters,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=memberclusters/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// Reconcile connects to the member cluster and records its health.
+func (r *MemberClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	member := &vpnv1alpha1.MemberCluster{}
+	if err := r.Get(ctx, req.NamespacedName, member); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.Clusters.remove(req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	version, err := r.connect(ctx, member)
+	now := metav1.Now()
+	member.Status.LastProbeTime = &now
+	member.Status.ObservedGeneration = member.Generation
+	if err != nil {
+		logger.Info("member cluster unreachable", "error", err.Error())
+		r.Clusters.remove(req.NamespacedName)
+		vpnv1alpha1.SetCondition(&member.Status.Conditions, vpnv1alpha1.Condition{
+			Type:               vpnv1alpha1.ConditionReady,
+			Status:             vpnv1alpha1.ConditionFalse,
+			Reason:             "Unreachable",
+			Message:            err.Error(),
+			ObservedGeneration: member.Generation,
+		})
+	} else {
+		member.Status.KubernetesVersion = version
+		vpnv1alpha1.SetCondition(&member.Status.Conditions, vpnv1alpha1.Condition{
+			Type:               vpnv1alpha1.ConditionReady,
+			Status:             vpnv1alpha1.ConditionTrue,
+			Reason:             "Connected",
+			ObservedGeneration: member.Generation,
+		})
+	}
+
+	if err := r.Status().Update(ctx, member); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: memberClusterProbeInterval}, nil
+}
+
+// connect builds a client for the member cluster, probes its API server and
+// registers it in the cluster set.
+func (r *MemberClusterReconciler) connect(ctx context.Context, member *vpnv1alpha1.MemberCluster) (string, error) {
+	kubeconfig, err := r.kubeconfig(ctx, member)
+	if err != nil {
+		return "", err
+	}
+
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return "", fmt.Errorf("invalid kubeconfig: %w", err)
+	}
+	config.Timeout = 10 * time.Second
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return "", err
+	}
+	version, err := discoveryClient.ServerVersion()
+	if err != nil {
+		return "", err
+	}
+
+	memberClient, err := client.New(config, client.Options{Scheme: r.Scheme})
+	if err != nil {
+		return "", err
+	}
+
+	namespace := member.Spec.Namespace
+	if namespace == "" {
+		namespace = member.Namespace
+	}
+	r.Clusters.set(client.ObjectKeyFromObject(member), memberCluster{client: memberClient, namespace: namespace})
+	return version.GitVersion, nil
+}
+
+// kubeconfig returns the kubeconfig of the member cluster from the
+// referenced Secret or the Cluster API kubeconfig Secret.
+func (r *MemberClusterReconciler) kubeconfig(ctx context.Context, member *vpnv1alpha1.MemberCluster) ([]byte, error) {
+	var ref vpnv1alpha1.SecretKeyReference
+	switch {
+	case member.Spec.KubeconfigSecretRef != nil:
+		ref = *member.Spec.KubeconfigSecretRef
+	case member.Spec.ClusterRef != nil:
+		ref = vpnv1alpha1.SecretKeyReference{Name: member.Spec.ClusterRef.Name + "-kubeconfig"}
+	default:
+		return nil, fmt.Errorf("one of kubeconfigSecretRef or clusterRef must be set")
+	}
+	if ref.Key == "" {
+		ref.Key = defaultKubeconfigKey
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: member.Namespace, Name: ref.Name}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig secret %q: %w", ref.Name, err)
+	}
+	kubeconfig, ok := secret.Data[ref.Key]
+	if !ok {
+		return nil, fmt.Errorf("kubeconfig secret %q has no key %q", ref.Name, ref.Key)
+	}
+	return kubeconfig, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MemberClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vpnv1alpha1.MemberCluster{}).
+		Complete(instrument(mgr, "membercluster", &vpnv1alpha1.MemberCluster{}, r))
+}
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/nodemetrics"
+)
+
+// NICSaturationMonitor periodically reads the NIC utilization of nodes and
+// labels the saturated ones, so that replicas of servers avoiding them are
+// scheduled elsewhere.
+type NICSaturationMonitor struct {
+	client.Client
+	Metrics *nodemetrics.Client
+
+	// Query returns the NIC utilization of each node as a fraction of its
+	// link speed
+	Query string
+
+	// Threshold is the utilization above which a node is saturated
+	Threshold float64
+
+	// Interval is how often the utilization is read
+	Interval time.Duration
+
+	mu          sync.RWMutex
+	utilization map[string]float64
+}
+
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;patch
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+func (m *NICSaturationMonitor) NeedLeaderElection() bool {
+	return true
+}
+
+// Start implements manager.Runnable.
+func (m *NICSaturationMonitor) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("nic-saturation")
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		if err := m.update(ctx); err != nil {
+			logger.Error(err, "unable to update NIC saturation of nodes")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// update reads the utilization of the nodes and updates their saturation
+// label. Nodes without a reading keep their label.
+func (m *NICSaturationMonitor) update(ctx context.Context) error {
+	nodes := &corev1.NodeList{}
+	if err := m.List(ctx, nodes); err != nil {
+		return err
+	}
+	hosts := map[string]string{}
+	for _, node := range nodes.Items {
+		hosts[node.Name] = node.Name
+		for _, address := range node.Status.Addresses {
+			hosts[address.Address] = node.Name
+		}
+	}
+
+	utilization, err := m.Metrics.Utilization(ctx, m.Query, func(host string) string { return hosts[host] })
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.utilization = utilization
+	m.mu.Unlock()
+
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		value, ok := utilization[node.Name]
+		if !ok {
+			continue
+		}
+		saturated := value >= m.Threshold
+		if _, labelled := node.Labels[vpnv1alpha1.NICSaturatedLabel]; labelled == saturated {
+			continue
+		}
+
+		patch := client.MergeFrom(node.DeepCopy())
+		if saturated {
+			if node.Labels == nil {
+				node.Labels = map[string]string{}
+			}
+			node.Labels[vpnv1alpha1.NICSaturatedLabel] = "true"
+		} else {
+			delete(node.Labels, vpnv1alpha1.NICSaturatedLabel)
+		}
+		if err := m.Patch(ctx, node, patch); err != nil {
+			return err
+		}
+		log.FromContext(ctx).Info("updated NIC saturation of node", "node", node.Name, "utilization", value, "saturated", saturated)
+	}
+	return nil
+}
+
+// Utilization returns the last read NIC utilization of a node.
+func (m *NICSaturationMonitor) Utilization(node string) (float64, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	value, ok := m.utilization[node]
+	return value, ok
+}
+
+// Saturated returns the nodes whose last read utilization is above the
+// threshold.
+func (m *NICSaturationMonitor) Saturated() map[string]float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	saturated := map[string]float64{}
+	for node, value := range m.utilization {
+		if value >= m.Threshold {
+			saturated[node] = value
+		}
+	}
+	return saturated
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// NodeEndpointReconciler publishes the endpoint of the replica of each node
+// of servers in the DaemonSet deployment mode, which clients reach on the
+// address of the node and the listen port of the replica.
+type NodeEndpointReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+
+// Reconcile sets the node endpoints of the status of a server.
+func (r *NodeEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	original := server.DeepCopy()
+
+	server.Status.NodeEndpoints = nil
+	if server.Spec.DeploymentMode == vpnv1alpha1.DeploymentModeDaemonSet {
+		pods := &corev1.PodList{}
+		if err := r.List(ctx, pods, client.InNamespace(server.Namespace), client.MatchingLabels(serverLabels(server))); err != nil {
+			return ctrl.Result{}, err
+		}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if !pod.DeletionTimestamp.IsZero() || pod.Spec.NodeName == "" {
+				continue
+			}
+			node := &corev1.Node{}
+			if err := r.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, node); err != nil {
+				if client.IgnoreNotFound(err) != nil {
+					return ctrl.Result{}, err
+				}
+				continue
+			}
+			address := nodeEndpointAddress(node)
+			if address == "" {
+				continue
+			}
+			server.Status.NodeEndpoints = append(server.Status.NodeEndpoints, vpnv1alpha1.NodeEndpoint{
+				Node:     node.Name,
+				Endpoint: net.JoinHostPort(address, strconv.Itoa(int(nodeListenPort(server, node.Name)))),
+				Ready:    isPodReady(pod),
+			})
+		}
+		sort.Slice(server.Status.NodeEndpoints, func(i, j int) bool {
+			return server.Status.NodeEndpoints[i].Node < server.Status.NodeEndpoints[j].Node
+		})
+	}
+
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{}, patchServerStatus(ctx, r.Client, server, original)
+}
+
+// nodeListenPort returns the port the replica of a server on a node
+// listens on.
+func nodeListenPort(server *vpnv1alpha1.VPNServer, node string) int32 {
+	for _, port := range server.Spec.NodeListenPorts {
+		if port.Node == node {
+			return port.Port
+		}
+	}
+	return server.Spec.Port
+}
+
+// nodeEndpointAddress returns the address clients reach a node on: the
+// endpoint address annotation, else its external address, else its
+// internal address.
+func nodeEndpointAddress(node *corev1.Node) string {
+	if address := node.Annotations[vpnv1alpha1.NodeEndpointAddressAnnotation]; address != "" {
+		return address
+	}
+	for _, addressType := range []corev1.NodeAddressType{corev1.NodeExternalIP, corev1.NodeInternalIP} {
+		for _, address := range node.Status.Addresses {
+			if address.Type == addressType {
+				return address.Address
+			}
+		}
+	}
+	return ""
+}
+
+// daemonSetServers maps a node to the servers in the DaemonSet deployment
+// mode, whose endpoints depend on the addresses of the nodes.
+func (r *NodeEndpointReconciler) daemonSetServers(ctx context.Context, _ client.Object) []reconcile.Request {
+	servers := &vpnv1alpha1.VPNServerList{}
+	if err := r.List(ctx, servers); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, server := range servers.Items {
+		if server.Spec.DeploymentMode == vpnv1alpha1.DeploymentModeDaemonSet {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&server)})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NodeEndpointReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("nodeendpoints").
+		For(&vpnv1alpha1.VPNServer{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(serverForPod)).
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(r.daemonSetServers)).
+		Complete(instrument(mgr, "nodeendpoints", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// NodeRoutesReconciler publishes the routes to the peer networks of
+// VPNServers with host routes enabled. The node-routes DaemonSet reads them
+// from a ConfigMap and installs them on every node.
+type NodeRoutesReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Namespace and Name identify the ConfigMap the routes are published in
+	Namespace string
+	Name      string
+}
+
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+
+// Reconcile publishes the routes of a server. Each ConfigMap entry holds the
+// routes of one server as "<cidr> <gateway>" lines, where the gateway is the
+// IP of the oldest ready server pod.
+func (r *NodeRoutesReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		server = nil
+	}
+
+	routes := ""
+	if server != nil && server.Spec.HostRoutes && server.DeletionTimestamp.IsZero() {
+		var err error
+		if routes, err = r.routes(ctx, server); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, r.publish(ctx, req.Namespace+"."+req.Name, routes)
+}
+
+// routes returns the routes of a server, or nothing when no server pod is
+// ready to forward traffic.
+func (r *NodeRoutesReconciler) routes(ctx context.Context, server *vpnv1alpha1.VPNServer) (string, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(server.Namespace), client.MatchingLabels(serverLabels(server))); err != nil {
+		return "", err
+	}
+	var gateway *corev1.Pod
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !isPodReady(pod) || pod.Status.PodIP == "" || !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		// The oldest pod keeps the routes stable while replicas come and go
+		if gateway == nil || pod.CreationTimestamp.Before(&gateway.CreationTimestamp) {
+			gateway = pod
+		}
+	}
+	if gateway == nil {
+		return "", nil
+	}
+
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(server.Namespace), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: server.Name}); err != nil {
+		return "", err
+	}
+	cidrs := map[string]bool{}
+	for _, peer := range peers.Items {
+		if !peer.DeletionTimestamp.IsZero() {
+			continue
+		}
+		for _, allowed := range peer.Spec.AllowedIPs {
+			_, network, err := net.ParseCIDR(strings.TrimSpace(allowed))
+			if err != nil {
+				log.FromContext(ctx).Info("ignoring invalid peer network", "peer", peer.Name, "cidr", allowed)
+				continue
+			}
+			// A default route would take over the node's own egress
+			if ones, _ := network.Mask.Size(); ones == 0 {
+				continue
+			}
+			cidrs[network.String()] = true
+		}
+	}
+
+	lines := make([]string, 0, len(cidrs))
+	for cidr := range cidrs {
+		lines = append(lines, fmt.Sprintf("%s %s", cidr, gateway.Status.PodIP))
+	}
+	sort.Strings(lines)
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// publish sets the routes of a server in the ConfigMap, removing its entry
+// when there are none.
+func (r *NodeRoutesReconciler) publish(ctx context.Context, key, routes string) error {
+	cm := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: r.Name}, cm)
+	if apierrors.IsNotFound(err) {
+		if routes == "" {
+			return nil
+		}
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: r.Namespace, Name: r.Name},
+			Data:       map[string]string{key: routes},
+		}
+		return r.Create(ctx, cm)
+	}
+	if err != nil {
+		return err
+	}
+
+	if current, ok := cm.Data[key]; ok == (routes != "") && current == routes {
+		return nil
+	}
+	if routes == "" {
+		delete(cm.Data, key)
+	} else {
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[key] = routes
+	}
+	return r.Update(ctx, cm)
+}
+
+// serverForPeer maps a VPNPeer to the server it references.
+func serverForPeer(ctx context.Context, obj client.Object) []reconcile.Request {
+	peer := obj.(*vpnv1alpha1.VPNPeer)
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: peer.Namespace, Name: peer.Spec.ServerRef.Name}}}
+}
+
+// serverForPod maps a server pod to its server.
+func serverForPod(ctx context.Context, obj client.Object) []reconcile.Request {
+	labels := obj.GetLabels()
+	if !isServerPod(labels) {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: labels["app.kubernetes.io/instance"]}}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NodeRoutesReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("noderoutes").
+		For(&vpnv1alpha1.VPNServer{}).
+		Watches(&vpnv1alpha1.VPNPeer{}, serverForPeerCoalesced()).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(serverForPod)).
+		Complete(instrument(mgr, "noderoutes", &vpnv1alpha1.VPNServer{}, r))
+}
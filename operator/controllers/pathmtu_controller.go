@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	// defaultTunnelMTU is the MTU of WireGuard interfaces on a path with
+	// an MTU of 1500 when none is configured
+	defaultTunnelMTU = 1420
+
+	// WireGuard overhead: outer IP header, UDP header and the WireGuard
+	// header and authentication tag
+	wireGuardOverheadIPv4 = 20 + 8 + 32
+	wireGuardOverheadIPv6 = 40 + 8 + 32
+)
+
+// mtuProbeResult is the result of a path MTU probe reported by the agent
+type mtuProbeResult struct {
+	Request string `json:"request"`
+	PathMTU int32  `json:"pathMTU"`
+}
+
+// PathMTUReconciler probes the path MTU of peers, on request through the
+// probe-mtu annotation or on their schedule, and suggests a tunnel MTU when
+// the one they use does not fit the path. The probes are run by the agent
+// of the server pods, which ping the endpoint of the peer with
+// fragmentation prohibited.
+type PathMTUReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile requests due probes of the peers of a server from its pods and
+// records the results they report.
+func (r *PathMTUReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(server.Namespace), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: server.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(server.Namespace), client.MatchingLabels(serverLabels(server))); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Any replica may report the result of a probe
+	results := map[string]mtuProbeResult{}
+	for _, pod := range pods.Items {
+		raw := pod.Annotations[vpnv1alpha1.MTUProbeResultsAnnotation]
+		if raw == "" {
+			continue
+		}
+		reported := map[string]mtuProbeResult{}
+		if err := json.Unmarshal([]byte(raw), &reported); err != nil {
+			logger.Info("ignoring invalid path MTU probe results", "pod", pod.Name, "error", err.Error())
+			continue
+		}
+		for peer, result := range reported {
+			results[peer] = result
+		}
+	}
+
+	now := time.Now()
+	var probes []string
+	var requeue time.Duration
+	for i := range peers.Items {
+		peer := &peers.Items[i]
+		if !peer.DeletionTimestamp.IsZero() {
+			continue
+		}
+		original := peer.DeepCopy()
+		status := peer.Status.PathMTU
+		if status == nil {
+			status = &vpnv1alpha1.PathMTUStatus{}
+		}
+
+		if status.RequestedAt == nil {
+			request := peer.Annotations[vpnv1alpha1.ProbeMTUAnnotation]
+			due := request != "" && request != status.Request
+			if schedule := peer.Spec.PathMTUProbe; schedule != nil {
+				next := schedule.Interval.Duration
+				if status.ProbedAt != nil {
+					next -= now.Sub(status.ProbedAt.Time)
+				}
+				if next <= 0 {
+					due = true
+				} else if requeue == 0 || next < requeue {
+					requeue = next
+				}
+			}
+			if due {
+				requestedAt := metav1.NewTime(now)
+				status.RequestedAt = &requestedAt
+				status.Request = request
+			}
+		}
+
+		if status.RequestedAt != nil {
+			token := strconv.FormatInt(status.RequestedAt.Unix(), 10)
+			endpoint := probeEndpoint(peer)
+			result, reported := results[peer.Name]
+			switch {
+			case endpoint == "":
+				recordProbe(status, now, "", 0)
+			case reported && result.Request == token:
+				recordProbe(status, now, endpoint, result.PathMTU)
+			default:
+				probes = append(probes, strings.Join([]string{peer.Name, token, endpoint}, "|"))
+			}
+		}
+
+		if status.RequestedAt == nil && status.ProbedAt != nil {
+			r.setMTUCondition(peer, status)
+		}
+		if status.Request != "" || status.RequestedAt != nil || status.ProbedAt != nil {
+			peer.Status.PathMTU = status
+		}
+		if equality.Semantic.DeepEqual(original.Status, peer.Status) {
+			continue
+		}
+		if err := r.Status().Patch(ctx, peer, client.MergeFrom(original)); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	sort.Strings(probes)
+	desired := strings.Join(probes, " ")
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !pod.DeletionTimestamp.IsZero() || pod.Annotations[vpnv1alpha1.MTUProbesAnnotation] == desired {
+			continue
+		}
+		patch := client.MergeFrom(pod.DeepCopy())
+		if desired == "" {
+			delete(pod.Annotations, vpnv1alpha1.MTUProbesAnnotation)
+		} else {
+			if pod.Annotations == nil {
+				pod.Annotations = map[string]string{}
+			}
+			pod.Annotations[vpnv1alpha1.MTUProbesAnnotation] = desired
+		}
+		if err := r.Patch(ctx, pod, patch); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+	return ctrl.Result{RequeueAfter: requeue}, nil
+}
+
+// probeEndpoint returns the IP the path of a peer is probed to: the source
+// of its recent handshakes, or its configured endpoint. Endpoints configured
+// by host name are resolved by the server, not probed.
+func probeEndpoint(peer *vpnv1alpha1.VPNPeer) string {
+	for _, endpoint := range []string{peer.Status.ObservedEndpoint, peer.Spec.Endpoint} {
+		host, _, err := net.SplitHostPort(endpoint)
+		if err == nil && net.ParseIP(host) != nil {
+			return host
+		}
+	}
+	return ""
+}
+
+// recordProbe records the result of a probe and completes the pending
+// request.
+func recordProbe(status *vpnv1alpha1.PathMTUStatus, now time.Time, endpoint string, pathMTU int32) {
+	probedAt := metav1.NewTime(now)
+	status.ProbedAt = &probedAt
+	status.RequestedAt = nil
+	status.Endpoint = endpoint
+	status.PathMTU = pathMTU
+	status.SuggestedMTU = 0
+	if pathMTU > 0 {
+		overhead := int32(wireGuardOverheadIPv4)
+		if ip := net.ParseIP(endpoint); ip != nil && ip.To4() == nil {
+			overhead = wireGuardOverheadIPv6
+		}
+		status.SuggestedMTU = pathMTU - overhead
+	}
+}
+
+// setMTUCondition compares the effective tunnel MTU of a peer with the one
+// suggested by its last probe.
+func (r *PathMTUReconciler) setMTUCondition(peer *vpnv1alpha1.VPNPeer, status *vpnv1alpha1.PathMTUStatus) {
+	mtu := int32(defaultTunnelMTU)
+	if effective := peer.Status.EffectiveConfig; effective != nil && effective.MTU != 0 {
+		mtu = effective.MTU
+	}
+
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionMTUMismatch,
+		Status:             vpnv1alpha1.ConditionFalse,
+		Reason:             "MTUFits",
+		Message:            fmt.Sprintf("Tunnel MTU %d fits the path MTU %d to %s", mtu, status.PathMTU, status.Endpoint),
+		ObservedGeneration: peer.Generation,
+	}
+	switch {
+	case status.Endpoint == "":
+		condition.Status = vpnv1alpha1.ConditionUnknown
+		condition.Reason = "NoEndpoint"
+		condition.Message = "The peer has no endpoint to probe"
+	case status.PathMTU == 0:
+		condition.Status = vpnv1alpha1.ConditionUnknown
+		condition.Reason = "EndpointUnreachable"
+		condition.Message = fmt.Sprintf("%s did not answer the probe", status.Endpoint)
+	case mtu > status.SuggestedMTU:
+		condition.Status = vpnv1alpha1.ConditionTrue
+		condition.Reason = "MTUTooLarge"
+		condition.Message = fmt.Sprintf("Tunnel MTU %d exceeds the path MTU %d to %s, set spec.mtu to %d",
+			mtu, status.PathMTU, status.Endpoint, status.SuggestedMTU)
+	}
+
+	if condition.Status == vpnv1alpha1.ConditionTrue && !vpnv1alpha1.IsConditionTrue(peer.Status.Conditions, condition.Type) {
+		r.Recorder.Event(peer, corev1.EventTypeWarning, "MTUAdjustmentSuggested", condition.Message)
+	}
+	vpnv1alpha1.SetCondition(&peer.Status.Conditions, condition)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PathMTUReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("pathmtu").
+		For(&vpnv1alpha1.VPNServer{}).
+		Watches(&vpnv1alpha1.VPNPeer{}, serverForPeerCoalesced()).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(serverForPod)).
+		Complete(instrument(mgr, "pathmtu", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,548 @@
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// Types of peer events
+const (
+	// PeerEventHandshake is a new handshake of a connected peer
+	PeerEventHandshake = "handshake"
+
+	// PeerEventConnect is the first fresh handshake of a peer
+	PeerEventConnect = "connect"
+
+	// PeerEventDisconnect is the expiry of the latest handshake of a peer,
+	// or the removal of the peer or the replica
+	PeerEventDisconnect = "disconnect"
+
+	// PeerEventTraffic is a peer transferring another traffic threshold
+	PeerEventTraffic = "traffic"
+)
+
+const (
+	// defaultPeerEventTrafficThreshold is the traffic between traffic
+	// events of a peer when the threshold is not set
+	defaultPeerEventTrafficThreshold = 64 << 20
+
+	// peerEventHeartbeat is how often idle streams are written to, so that
+	// proxies do not close them
+	peerEventHeartbeat = 15 * time.Second
+
+	// peerEventBuffer is the number of events a subscriber may lag behind
+	// before it is disconnected
+	peerEventBuffer = 256
+)
+
+// PeerEvent is a change of the connection state of a peer, as observed by
+// a replica of its server
+type PeerEvent struct {
+	// Time is when the replica sampled the change
+	Time time.Time `json:"time"`
+
+	// Type is handshake, connect, disconnect or traffic
+	Type string `json:"type"`
+
+	// Namespace is the namespace of the server and the peer
+	Namespace string `json:"namespace"`
+
+	// Server is the name of the server
+	Server string `json:"server"`
+
+	// Pod is the replica of the server the peer is connected to
+	Pod string `json:"pod"`
+
+	// Peer is the name of the peer, empty for keys without a VPNPeer
+	Peer string `json:"peer,omitempty"`
+
+	// PublicKey is the public key of the peer
+	PublicKey string `json:"publicKey"`
+
+	// Endpoint is the address the peer connects from
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// LatestHandshake is the latest handshake of the peer, if any
+	LatestHandshake *time.Time `json:"latestHandshake,omitempty"`
+
+	// ReceiveBytes and TransmitBytes are the traffic of the peer on the
+	// replica
+	ReceiveBytes  int64 `json:"receiveBytes"`
+	TransmitBytes int64 `json:"transmitBytes"`
+}
+
+// peerSample is the entry of a peer in a peer stats summary
+type peerSample struct {
+	endpoint  string
+	handshake int64
+	received  int64
+	sent      int64
+}
+
+// podSamples are the peers of the latest summary of a replica
+type podSamples struct {
+	server    types.NamespacedName
+	sampledAt int64
+	peers     map[string]peerSample
+
+	// reported is the traffic of each peer at its latest traffic event
+	reported map[string]int64
+}
+
+// PeerEventStream streams the handshakes, connections, disconnections and
+// traffic of the peers of servers as they happen, so that dashboards show
+// live connection state without polling the API server. The events are
+// derived from the peer stats summaries the agents push on their pods.
+//
+// Clients stream the events of a server from
+// /peer-events/<namespace>/<server> as server-sent events, or as JSON
+// messages over a WebSocket. They authenticate with a Kubernetes bearer
+// token and must be allowed to get the server. The peers connected when a
+// client subscribes are sent first as connect events.
+type PeerEventStream struct {
+	client.Client
+
+	// Informers provides the Pod informer the summaries are read from
+	Informers cache.Informers
+
+	// Address is the address events are streamed on
+	Address string
+
+	// GetCertificate returns the serving certificate of the operator
+	GetCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+	// TrafficThreshold is the traffic between the traffic events of a
+	// peer, 64Mi if zero
+	TrafficThreshold int64
+
+	mu          sync.Mutex
+	pods        map[types.NamespacedName]*podSamples
+	subscribers map[*peerEventSubscriber]struct{}
+}
+
+// peerEventSubscriber is a client streaming the events of a server
+type peerEventSubscriber struct {
+	server types.NamespacedName
+	events chan PeerEvent
+}
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Every
+// replica watches the pods and streams events.
+func (s *PeerEventStream) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable.
+func (s *PeerEventStream) Start(ctx context.Context) error {
+	s.mu.Lock()
+	s.pods = map[types.NamespacedName]*podSamples{}
+	s.subscribers = map[*peerEventSubscriber]struct{}{}
+	s.mu.Unlock()
+
+	informer, err := s.Informers.GetInformer(ctx, &corev1.Pod{})
+	if err != nil {
+		return err
+	}
+	if _, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { s.observe(ctx, obj, false) },
+		UpdateFunc: func(_, obj interface{}) { s.observe(ctx, obj, true) },
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			s.forget(ctx, obj)
+		},
+	}); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/peer-events/", s)
+	server := &http.Server{
+		Addr:              s.Address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+		TLSConfig: &tls.Config{
+			GetCertificate: s.GetCertificate,
+			MinVersion:     tls.VersionTLS12,
+		},
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	ctrl.Log.WithName("peer-events").Info("streaming peer events", "address", s.Address)
+	if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// observe compares the summary of a server pod with its previous summary
+// and publishes the changes. Summaries of pods seen for the first time are
+// recorded without events, as their peers were not observed connecting.
+func (s *PeerEventStream) observe(ctx context.Context, obj interface{}, publish bool) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || !isServerPod(pod.Labels) {
+		return
+	}
+	raw, ok := pod.Annotations[vpnv1alpha1.PeerStatsAnnotation]
+	if !ok {
+		return
+	}
+	summary := peerStatsSummary{}
+	if err := json.Unmarshal([]byte(raw), &summary); err != nil {
+		return
+	}
+	server := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Labels["app.kubernetes.io/instance"]}
+	current := &podSamples{server: server, sampledAt: summary.SampledAt, peers: map[string]peerSample{}, reported: map[string]int64{}}
+	for publicKey, entry := range summary.Peers {
+		var sample peerSample
+		if json.Unmarshal(entry[0], &sample.endpoint) != nil || json.Unmarshal(entry[1], &sample.handshake) != nil ||
+			json.Unmarshal(entry[2], &sample.received) != nil || json.Unmarshal(entry[3], &sample.sent) != nil {
+			continue
+		}
+		current.peers[publicKey] = sample
+	}
+
+	key := client.ObjectKeyFromObject(pod)
+	s.mu.Lock()
+	previous := s.pods[key]
+	if previous != nil && previous.sampledAt == current.sampledAt {
+		s.mu.Unlock()
+		return
+	}
+	s.pods[key] = current
+	if previous == nil || !publish {
+		for publicKey, sample := range current.peers {
+			current.reported[publicKey] = sample.received + sample.sent
+		}
+		s.mu.Unlock()
+		return
+	}
+	threshold := s.TrafficThreshold
+	if threshold <= 0 {
+		threshold = defaultPeerEventTrafficThreshold
+	}
+	at := time.Unix(current.sampledAt, 0)
+	var events []PeerEvent
+	for publicKey, sample := range current.peers {
+		before, known := previous.peers[publicKey]
+		fresh := sample.fresh(at)
+		wasFresh := known && before.fresh(time.Unix(previous.sampledAt, 0))
+		switch {
+		case fresh && !wasFresh:
+			events = append(events, peerEvent(PeerEventConnect, server, pod.Name, publicKey, sample, at))
+		case !fresh && wasFresh:
+			events = append(events, peerEvent(PeerEventDisconnect, server, pod.Name, publicKey, sample, at))
+		case fresh && sample.handshake > before.handshake:
+			events = append(events, peerEvent(PeerEventHandshake, server, pod.Name, publicKey, sample, at))
+		}
+		// Counters restart with the interface
+		reported, ok := previous.reported[publicKey]
+		if total := sample.received + sample.sent; !ok || total < reported {
+			current.reported[publicKey] = total
+		} else if total-reported >= threshold {
+			events = append(events, peerEvent(PeerEventTraffic, server, pod.Name, publicKey, sample, at))
+			current.reported[publicKey] = total
+		} else {
+			current.reported[publicKey] = reported
+		}
+	}
+	for publicKey, before := range previous.peers {
+		if _, ok := current.peers[publicKey]; !ok && before.fresh(time.Unix(previous.sampledAt, 0)) {
+			events = append(events, peerEvent(PeerEventDisconnect, server, pod.Name, publicKey, before, at))
+		}
+	}
+	s.mu.Unlock()
+	s.publish(ctx, server, events)
+}
+
+// forget publishes the disconnection of the peers connected to a deleted
+// server pod.
+func (s *PeerEventStream) forget(ctx context.Context, obj interface{}) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || !isServerPod(pod.Labels) {
+		return
+	}
+	key := client.ObjectKeyFromObject(pod)
+	s.mu.Lock()
+	previous := s.pods[key]
+	delete(s.pods, key)
+	s.mu.Unlock()
+	if previous == nil {
+		return
+	}
+	now := time.Now()
+	var events []PeerEvent
+	for publicKey, sample := range previous.peers {
+		if sample.fresh(time.Unix(previous.sampledAt, 0)) {
+			events = append(events, peerEvent(PeerEventDisconnect, previous.server, pod.Name, publicKey, sample, now))
+		}
+	}
+	s.publish(ctx, previous.server, events)
+}
+
+// publish names the peers of events and delivers them to the subscribers
+// of their server. Subscribers too slow to keep up are disconnected rather
+// than blocking the informer.
+func (s *PeerEventStream) publish(ctx context.Context, server types.NamespacedName, events []PeerEvent) {
+	if len(events) == 0 {
+		return
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].PublicKey < events[j].PublicKey })
+	names := s.peerNames(ctx, server)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for subscriber := range s.subscribers {
+		if subscriber.server != server {
+			continue
+		}
+		for _, event := range events {
+			event.Peer = names[event.PublicKey]
+			select {
+			case subscriber.events <- event:
+			default:
+				delete(s.subscribers, subscriber)
+				close(subscriber.events)
+			}
+			if _, ok := s.subscribers[subscriber]; !ok {
+				break
+			}
+		}
+	}
+}
+
+// peerNames returns the names of the peers of a server by public key.
+func (s *PeerEventStream) peerNames(ctx context.Context, server types.NamespacedName) map[string]string {
+	names := map[string]string{}
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := s.List(ctx, peers, client.InNamespace(server.Namespace), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: server.Name}); err != nil {
+		return names
+	}
+	for _, peer := range peers.Items {
+		if peer.Spec.PublicKey != "" {
+			names[peer.Spec.PublicKey] = peer.Name
+		}
+	}
+	return names
+}
+
+// subscribe registers a subscriber to the events of a server, with the
+// peers currently connected to it queued as connect events.
+func (s *PeerEventStream) subscribe(ctx context.Context, server types.NamespacedName) *peerEventSubscriber {
+	names := s.peerNames(ctx, server)
+	subscriber := &peerEventSubscriber{server: server, events: make(chan PeerEvent, peerEventBuffer)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var connected []PeerEvent
+	for key, samples := range s.pods {
+		if samples.server != server {
+			continue
+		}
+		at := time.Unix(samples.sampledAt, 0)
+		for publicKey, sample := range samples.peers {
+			if sample.fresh(at) {
+				event := peerEvent(PeerEventConnect, server, key.Name, publicKey, sample, at)
+				event.Peer = names[publicKey]
+				connected = append(connected, event)
+			}
+		}
+	}
+	sort.Slice(connected, func(i, j int) bool { return connected[i].Time.Before(connected[j].Time) })
+	for _, event := range connected {
+		select {
+		case subscriber.events <- event:
+		default:
+		}
+	}
+	s.subscribers[subscriber] = struct{}{}
+	return subscriber
+}
+
+// unsubscribe removes a subscriber, unless it was disconnected already.
+func (s *PeerEventStream) unsubscribe(subscriber *peerEventSubscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscribers[subscriber]; ok {
+		delete(s.subscribers, subscriber)
+		close(subscriber.events)
+	}
+}
+
+// ServeHTTP streams the events of a server, over a WebSocket if the request
+// upgrades to one and as server-sent events otherwise.
+func (s *PeerEventStream) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	logger := ctrl.Log.WithName("peer-events")
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, "/peer-events/"), "/"), "/")
+	if req.Method != http.MethodGet || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.NotFound(w, req)
+		return
+	}
+	server := types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+	ctx := req.Context()
+	if err := s.authorize(ctx, req, server); err != nil {
+		logger.V(1).Info("rejecting peer event stream", "namespace", server.Namespace, "server", server.Name, "reason", err.Error())
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		// Clients authenticate with a token rather than cookies, so the
+		// origin is not checked
+		websocket.Server{Handler: func(conn *websocket.Conn) {
+			s.stream(conn.Request().Context(), server, func(event *PeerEvent) error {
+				if event == nil {
+					conn.PayloadType = websocket.PingFrame
+					_, err := conn.Write(nil)
+					conn.PayloadType = websocket.TextFrame
+					return err
+				}
+				return websocket.JSON.Send(conn, event)
+			})
+		}}.ServeHTTP(w, req)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	s.stream(ctx, server, func(event *PeerEvent) error {
+		if event == nil {
+			_, err := fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+			return err
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		flusher.Flush()
+		return err
+	})
+}
+
+// stream writes the events of a server until the client goes away, writing
+// nil events as heartbeats.
+func (s *PeerEventStream) stream(ctx context.Context, server types.NamespacedName, write func(*PeerEvent) error) {
+	subscriber := s.subscribe(ctx, server)
+	defer s.unsubscribe(subscriber)
+	heartbeat := time.NewTicker(peerEventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if write(nil) != nil {
+				return
+			}
+		case event, ok := <-subscriber.events:
+			if !ok || write(&event) != nil {
+				return
+			}
+		}
+	}
+}
+
+// authorize authenticates the bearer token of a request and checks that
+// its user may get the server.
+func (s *PeerEventStream) authorize(ctx context.Context, req *http.Request, server types.NamespacedName) error {
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == req.Header.Get("Authorization") {
+		return errors.New("a bearer token is required")
+	}
+	review := &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}}
+	if err := s.Create(ctx, review); err != nil {
+		return err
+	}
+	if !review.Status.Authenticated {
+		return errors.New("invalid token")
+	}
+	user := review.Status.User
+	access := &authorizationv1.SubjectAccessReview{Spec: authorizationv1.SubjectAccessReviewSpec{
+		User:   user.Username,
+		UID:    user.UID,
+		Groups: user.Groups,
+		Extra:  map[string]authorizationv1.ExtraValue{},
+		ResourceAttributes: &authorizationv1.ResourceAttributes{
+			Namespace: server.Namespace,
+			Verb:      "get",
+			Group:     vpnv1alpha1.GroupVersion.Group,
+			Resource:  "vpnservers",
+			Name:      server.Name,
+		},
+	}}
+	for key, values := range user.Extra {
+		access.Spec.Extra[key] = authorizationv1.ExtraValue(values)
+	}
+	if err := s.Create(ctx, access); err != nil {
+		return err
+	}
+	if !access.Status.Allowed {
+		return fmt.Errorf("user %q cannot get vpnservers %s", user.Username, server.Name)
+	}
+	return nil
+}
+
+// fresh returns whether the peer had a handshake recently at a sample.
+func (p peerSample) fresh(at time.Time) bool {
+	return p.handshake > 0 && at.Sub(time.Unix(p.handshake, 0)) <= handshakeFreshness
+}
+
+// peerEvent returns an event of a peer on a replica of a server.
+func peerEvent(eventType string, server types.NamespacedName, pod, publicKey string, sample peerSample, at time.Time) PeerEvent {
+	event := PeerEvent{
+		Time:          at,
+		Type:          eventType,
+		Namespace:     server.Namespace,
+		Server:        server.Name,
+		Pod:           pod,
+		PublicKey:     publicKey,
+		Endpoint:      sample.endpoint,
+		ReceiveBytes:  sample.received,
+		TransmitBytes: sample.sent,
+	}
+	if sample.handshake > 0 {
+		handshake := time.Unix(sample.handshake, 0)
+		event.LatestHandshake = &handshake
+	}
+	return event
+}
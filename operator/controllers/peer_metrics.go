@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// peerMetricLabels are the labels of the per-peer metrics
+var peerMetricLabels = []string{"namespace", "server", "peer"}
+
+var (
+	peerReceiveBytesDesc = prometheus.NewDesc("wireflow_peer_receive_bytes_total",
+		"Bytes received from the peer, summed over the replicas of its server.", peerMetricLabels, nil)
+	peerTransmitBytesDesc = prometheus.NewDesc("wireflow_peer_transmit_bytes_total",
+		"Bytes sent to the peer, summed over the replicas of its server.", peerMetricLabels, nil)
+	peerHandshakeAgeDesc = prometheus.NewDesc("wireflow_peer_last_handshake_age_seconds",
+		"Seconds since the latest handshake with the peer.", peerMetricLabels, nil)
+
+	// peerEndpointChanges counts the endpoint changes of peers observed by
+	// the PeerStatsReconciler
+	peerEndpointChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wireflow_peer_endpoint_changes_total",
+		Help: "Changes of the endpoint of the peer, e.g. when it roams between networks.",
+	}, peerMetricLabels)
+)
+
+func init() {
+	metrics.Registry.MustRegister(peerEndpointChanges)
+}
+
+// monitoringEnabled returns whether the metrics of the peers of a server
+// are exported.
+func monitoringEnabled(server *vpnv1alpha1.VPNServer) bool {
+	return server.Spec.Monitoring != nil && server.Spec.Monitoring.Enabled
+}
+
+// PeerMetricsCollector exports the traffic and handshakes of the peers of
+// servers with monitoring enabled, from the peer stats the
+// PeerStatsReconciler aggregates into their status. It is registered with
+// the metrics registry of the manager, so every replica serves the metrics
+// from its cache.
+type PeerMetricsCollector struct {
+	client.Reader
+}
+
+var _ prometheus.Collector = &PeerMetricsCollector{}
+
+// Describe implements prometheus.Collector.
+func (c *PeerMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- peerReceiveBytesDesc
+	ch <- peerTransmitBytesDesc
+	ch <- peerHandshakeAgeDesc
+}
+
+// Collect implements prometheus.Collector. Nothing is collected until the
+// cache has started.
+func (c *PeerMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	servers := &vpnv1alpha1.VPNServerList{}
+	if err := c.List(ctx, servers); err != nil {
+		return
+	}
+	now := time.Now()
+	for i := range servers.Items {
+		server := &servers.Items[i]
+		if !monitoringEnabled(server) {
+			continue
+		}
+		for _, peer := range server.Status.Peers {
+			labels := []string{server.Namespace, server.Name, peer.Name}
+			ch <- prometheus.MustNewConstMetric(peerReceiveBytesDesc, prometheus.CounterValue, float64(peer.ReceiveBytes), labels...)
+			ch <- prometheus.MustNewConstMetric(peerTransmitBytesDesc, prometheus.CounterValue, float64(peer.TransmitBytes), labels...)
+			if peer.LatestHandshake != nil {
+				ch <- prometheus.MustNewConstMetric(peerHandshakeAgeDesc, prometheus.GaugeValue,
+					now.Sub(peer.LatestHandshake.Time).Seconds(), labels...)
+			}
+		}
+	}
+}
+
+// recordEndpointChanges counts the peers of a server whose endpoint changed
+// between two observations, and forgets the peers no longer observed.
+func recordEndpointChanges(server *vpnv1alpha1.VPNServer, previous, current []vpnv1alpha1.PeerStatus) {
+	if !monitoringEnabled(server) {
+		peerEndpointChanges.DeletePartialMatch(prometheus.Labels{"namespace": server.Namespace, "server": server.Name})
+		return
+	}
+	endpoints := make(map[string]string, len(previous))
+	for _, peer := range previous {
+		endpoints[peer.Name] = peer.Endpoint
+	}
+	observed := make(map[string]bool, len(current))
+	for _, peer := range current {
+		observed[peer.Name] = true
+		counter := peerEndpointChanges.WithLabelValues(server.Namespace, server.Name, peer.Name)
+		if endpoint := endpoints[peer.Name]; endpoint != "" && peer.Endpoint != "" && endpoint != peer.Endpoint {
+			counter.Inc()
+		}
+	}
+	for name := range endpoints {
+		if !observed[name] {
+			peerEndpointChanges.DeleteLabelValues(server.Namespace, server.Name, name)
+		}
+	}
+}
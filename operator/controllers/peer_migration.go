@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// A peer is moved by changing its serverRef or group. Its key is kept: the
+// server configs are rendered from the peers referencing each server, so the
+// peer leaves the device of its previous server and joins the new one with
+// the same key, and only the parts of the client config that differ between
+// the two need to reach the client.
+
+// releasePreviousServers removes the owner references of servers other
+// than server from a peer moved to it, so that a cascading deletion of its
+// previous server does not take the peer along.
+func releasePreviousServers(peer *vpnv1alpha1.VPNPeer, server *vpnv1alpha1.VPNServer) {
+	var refs []metav1.OwnerReference
+	for _, ref := range peer.OwnerReferences {
+		if ref.Kind == "VPNServer" && strings.HasPrefix(ref.APIVersion, vpnv1alpha1.GroupVersion.Group+"/") && ref.UID != server.UID {
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	peer.OwnerReferences = refs
+}
+
+// moveAddress sets the address of a peer moved to server: its address if
+// the peer keeps it on moves and it is free in the client network of the
+// server, the first free address of the network otherwise. The address the
+// peer leaves is released by IPAM once the move is stored. Suspended peers
+// without an address are assigned one when they resume.
+func (r *VPNPeerReconciler) moveAddress(ctx context.Context, peer *vpnv1alpha1.VPNPeer, server *vpnv1alpha1.VPNServer) error {
+	ip, prefix := splitAddress(peer.Spec.Address)
+	if ip == nil {
+		return nil
+	}
+	network, err := clientNetwork(server)
+	if err != nil {
+		return nil
+	}
+	used, err := usedAddresses(ctx, r.Client, peer)
+	if err != nil {
+		return err
+	}
+	if peer.Spec.KeepAddressOnMove && network.Contains(ip) && !used[ip.String()] {
+		claimed, err := claimAddress(ctx, r.Client, peer, ip)
+		if err != nil || claimed {
+			return err
+		}
+	}
+
+	free, err := allocateFrom(ctx, r.Client, peer, []string{network.String()}, used)
+	if err != nil {
+		return err
+	}
+	if free == nil {
+		r.Recorder.Eventf(peer, corev1.EventTypeWarning, "AddressUnavailable",
+			"No free address in network %s of VPNServer %s, keeping %s", network, server.Name, peer.Spec.Address)
+		return nil
+	}
+	peer.Spec.Address = free.String() + prefix
+	return nil
+}
+
+// recordMigration records the move of a peer to another server or group,
+// with the parts of the client config that changed.
+func (r *VPNPeerReconciler) recordMigration(previous, peer *vpnv1alpha1.VPNPeer) {
+	var moves []string
+	if from, to := previous.Status.ObservedServer, peer.Spec.ServerRef.Name; from != to {
+		moves = append(moves, fmt.Sprintf("from VPNServer %s to %s", from, to))
+	}
+	if from, to := previous.Status.ObservedGroup, peer.Spec.Group; from != to {
+		moves = append(moves, fmt.Sprintf("from group %q to %q", from, to))
+	}
+	if len(moves) == 0 {
+		return
+	}
+
+	message := "Moved " + strings.Join(moves, " and ") + " with its key"
+	if changes := clientConfigChanges(previous, peer); len(changes) > 0 {
+		message += "; the client config changed in: " + strings.Join(changes, ", ")
+	} else {
+		message += "; the client config is unchanged"
+	}
+	r.Recorder.Event(peer, corev1.EventTypeNormal, "PeerMigrated", message)
+}
+
+// clientConfigChanges returns the settings of the client config of a peer
+// that differ from the previous resolution.
+func clientConfigChanges(previous, peer *vpnv1alpha1.VPNPeer) []string {
+	var changes []string
+	if previous.Spec.Address != peer.Spec.Address {
+		changes = append(changes, "address")
+	}
+	if previous.Status.ObservedServer != peer.Spec.ServerRef.Name {
+		changes = append(changes, "server public key")
+	}
+	if previous.Status.ClientEndpoint != peer.Status.ClientEndpoint {
+		changes = append(changes, "endpoint")
+	}
+	if !equality.Semantic.DeepEqual(previous.Status.ClientAllowedIPs, peer.Status.ClientAllowedIPs) {
+		changes = append(changes, "allowed IPs")
+	}
+
+	var before, after vpnv1alpha1.EffectiveConfig
+	if previous.Status.EffectiveConfig != nil {
+		before = *previous.Status.EffectiveConfig
+	}
+	if peer.Status.EffectiveConfig != nil {
+		after = *peer.Status.EffectiveConfig
+	}
+	if before.DNS != after.DNS {
+		changes = append(changes, "DNS")
+	}
+	if before.MTU != after.MTU {
+		changes = append(changes, "MTU")
+	}
+	if before.PersistentKeepalive != after.PersistentKeepalive {
+		changes = append(changes, "persistent keepalive")
+	}
+	return changes
+}
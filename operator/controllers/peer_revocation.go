@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	// revocationFinalizer holds deleted peers until their key is handed to
+	// the agents of their server for removal from the live interface
+	revocationFinalizer = "vpn.vpn-devops.com/revocation"
+
+	// revocationRetention is how long the keys of deleted peers stay in the
+	// revoked peers annotation, so that pods restarting from an older
+	// config still drop them
+	revocationRetention = 10 * time.Minute
+)
+
+// PeerRevocationReconciler hands the public keys of the revoked and deleted
+// peers of a server to its agents through the RevokedPeersAnnotation of the
+// server pods. The agents remove them from the live interface on their next
+// tick rather than on the next config reload, so a revoked key stops
+// working within seconds.
+type PeerRevocationReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile annotates the pods of a server with the keys of its revoked
+// and deleted peers, and releases the deleted peers once the pods are
+// annotated.
+func (r *PeerRevocationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(req.Namespace), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: req.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		// Without a server there is no interface to remove the keys from
+		return ctrl.Result{}, r.release(ctx, peers.Items)
+	}
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(server.Namespace), client.MatchingLabels(serverLabels(server))); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	announced := map[string]time.Time{}
+	for _, pod := range pods.Items {
+		for key, at := range parseRevokedPeers(pod.Annotations[vpnv1alpha1.RevokedPeersAnnotation]) {
+			announced[key] = at
+		}
+	}
+
+	now := time.Now()
+	revoked := map[string]time.Time{}
+	active := map[string]bool{}
+	var deleted []vpnv1alpha1.VPNPeer
+	for i := range peers.Items {
+		peer := &peers.Items[i]
+		key := peer.Spec.PublicKey
+		switch {
+		case key == "":
+			continue
+		case !peer.DeletionTimestamp.IsZero():
+			revoked[key] = peer.DeletionTimestamp.Time
+			deleted = append(deleted, *peer)
+		case peer.Spec.Revoked:
+			revoked[key] = now
+			if at, ok := announced[key]; ok {
+				revoked[key] = at
+			}
+			if condition := vpnv1alpha1.FindCondition(peer.Status.Conditions, vpnv1alpha1.ConditionRevoked); condition != nil {
+				revoked[key] = condition.LastTransitionTime.Time
+			}
+		default:
+			active[key] = true
+			if controllerutil.AddFinalizer(peer, revocationFinalizer) {
+				if err := r.Update(ctx, peer); err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+		}
+	}
+
+	// The keys of peers deleted for good are only known from the
+	// annotation, and kept there for the retention
+	for key, at := range announced {
+		if _, ok := revoked[key]; !ok && !active[key] && now.Sub(at) < revocationRetention {
+			revoked[key] = at
+		}
+	}
+
+	annotation := formatRevokedPeers(revoked)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Annotations[vpnv1alpha1.RevokedPeersAnnotation] == annotation {
+			continue
+		}
+		patch := client.MergeFrom(pod.DeepCopy())
+		if annotation == "" {
+			delete(pod.Annotations, vpnv1alpha1.RevokedPeersAnnotation)
+		} else {
+			if pod.Annotations == nil {
+				pod.Annotations = map[string]string{}
+			}
+			pod.Annotations[vpnv1alpha1.RevokedPeersAnnotation] = annotation
+		}
+		if err := r.Patch(ctx, pod, patch); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	for i := range peers.Items {
+		peer := &peers.Items[i]
+		_, handed := revoked[peer.Spec.PublicKey]
+		_, known := announced[peer.Spec.PublicKey]
+		if handed && !known && peer.Spec.PublicKey != "" && len(pods.Items) > 0 {
+			logger.Info("revoked peer", "peer", peer.Name)
+			r.Recorder.Eventf(peer, corev1.EventTypeNormal, "PeerRevoked", "Public key %q handed to %d pods of server %s for removal from the live interface",
+				peer.Spec.PublicKey, len(pods.Items), server.Name)
+		}
+	}
+	if err := r.release(ctx, deleted); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Requeue to drop the keys of deleted peers once their retention ends
+	var requeue time.Duration
+	for key, at := range revoked {
+		if expires := at.Add(revocationRetention).Sub(now); expires > 0 && (requeue == 0 || expires < requeue) && !stillRevoked(peers.Items, key) {
+			requeue = expires
+		}
+	}
+	return ctrl.Result{RequeueAfter: requeue}, nil
+}
+
+// release removes the revocation finalizer of deleted peers.
+func (r *PeerRevocationReconciler) release(ctx context.Context, peers []vpnv1alpha1.VPNPeer) error {
+	for i := range peers {
+		peer := &peers[i]
+		if peer.DeletionTimestamp.IsZero() || !controllerutil.RemoveFinalizer(peer, revocationFinalizer) {
+			continue
+		}
+		if err := r.Update(ctx, peer); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stillRevoked returns whether a key belongs to a peer that exists and is
+// revoked, which keeps it in the annotation regardless of the retention.
+func stillRevoked(peers []vpnv1alpha1.VPNPeer, key string) bool {
+	for _, peer := range peers {
+		if peer.Spec.PublicKey == key && peer.Spec.Revoked && peer.DeletionTimestamp.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRevokedPeers parses a RevokedPeersAnnotation into the revocation
+// time of each key. Malformed entries are ignored.
+func parseRevokedPeers(annotation string) map[string]time.Time {
+	revoked := map[string]time.Time{}
+	for _, entry := range strings.Split(annotation, ",") {
+		key, at, ok := strings.Cut(strings.TrimSpace(entry), "@")
+		if !ok || key == "" {
+			continue
+		}
+		seconds, err := strconv.ParseInt(at, 10, 64)
+		if err != nil {
+			continue
+		}
+		revoked[key] = time.Unix(seconds, 0)
+	}
+	return revoked
+}
+
+// formatRevokedPeers formats the revocation time of each key as a
+// RevokedPeersAnnotation, sorted by key so that it is stable.
+func formatRevokedPeers(revoked map[string]time.Time) string {
+	keys := make([]string, 0, len(revoked))
+	for key := range revoked {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	entries := make([]string, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, fmt.Sprintf("%s@%d", key, revoked[key].Unix()))
+	}
+	return strings.Join(entries, ",")
+}
+
+// SetupWithManager sets up the controller with the Manager. Peer changes
+// are not coalesced, so that revocations are handed out right away.
+func (r *PeerRevocationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("peerrevocation").
+		For(&vpnv1alpha1.VPNServer{}).
+		Watches(&vpnv1alpha1.VPNPeer{}, handler.EnqueueRequestsFromMapFunc(serverForPeer)).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(serverForPod)).
+		Complete(instrument(mgr, "peerrevocation", &vpnv1alpha1.VPNServer{}, r))
+}
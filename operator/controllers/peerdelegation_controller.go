@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// PeerDelegationReconciler generates the Role and RoleBinding delegating the
+// management of the peers of a server to groups. The admission webhook
+// limits members of the groups to the peers of the server.
+type PeerDelegationReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// The API server only lets the operator grant permissions it holds itself
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch;manage-peers
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers/status,verbs=get
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile creates, updates or deletes the delegation of a server.
+func (r *PeerDelegationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	meta := metav1.ObjectMeta{Namespace: server.Namespace, Name: peerDelegationName(server)}
+	role := &rbacv1.Role{ObjectMeta: meta}
+	binding := &rbacv1.RoleBinding{ObjectMeta: meta}
+	if server.Spec.PeerDelegation == nil || len(server.Spec.PeerDelegation.Groups) == 0 {
+		for _, obj := range []client.Object{binding, role} {
+			if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return ctrl.Result{}, err
+			}
+			if !isOwnedBy(obj, server) {
+				continue
+			}
+			if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, role, func() error {
+		role.Labels = serverLabels(server)
+		propagateMetadata(server, role)
+		role.Rules = []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{vpnv1alpha1.GroupVersion.Group},
+				Resources: []string{"vpnpeers"},
+				Verbs:     []string{"get", "list", "watch", "create", "update", "patch", "delete"},
+			},
+			{
+				APIGroups: []string{vpnv1alpha1.GroupVersion.Group},
+				Resources: []string{"vpnpeers/status"},
+				Verbs:     []string{"get"},
+			},
+			{
+				APIGroups:     []string{vpnv1alpha1.GroupVersion.Group},
+				Resources:     []string{"vpnservers"},
+				ResourceNames: []string{server.Name},
+				Verbs:         []string{"get", vpnv1alpha1.ManagePeersVerb},
+			},
+		}
+		return controllerutil.SetControllerReference(server, role, r.Scheme)
+	}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	subjects := make([]rbacv1.Subject, 0, len(server.Spec.PeerDelegation.Groups))
+	for _, group := range server.Spec.PeerDelegation.Groups {
+		subjects = append(subjects, rbacv1.Subject{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: group})
+	}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, binding, func() error {
+		binding.Labels = serverLabels(server)
+		propagateMetadata(server, binding)
+		// The role reference of a binding is immutable, it is only set once
+		binding.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: role.Name}
+		binding.Subjects = subjects
+		return controllerutil.SetControllerReference(server, binding, r.Scheme)
+	})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if result != controllerutil.OperationResultNone {
+		log.FromContext(ctx).Info("delegated peer management", "groups", server.Spec.PeerDelegation.Groups, "operation", result)
+	}
+	return ctrl.Result{}, nil
+}
+
+// peerDelegationName returns the name of the Role and RoleBinding of the
+// delegation of a server.
+func peerDelegationName(server *vpnv1alpha1.VPNServer) string {
+	return server.Name + "-peer-managers"
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PeerDelegationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("peerdelegation").
+		For(&vpnv1alpha1.VPNServer{}).
+		Owns(&rbacv1.Role{}).
+		Owns(&rbacv1.RoleBinding{}).
+		Complete(instrument(mgr, "peerdelegation", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	defaultEndpointStableFor = 10 * time.Minute
+
+	// handshakeFreshness is the handshake age after which the endpoint
+	// reported for a peer is no longer considered its current source.
+	// WireGuard renews sessions every two minutes while traffic flows.
+	handshakeFreshness = 3 * time.Minute
+)
+
+// PeerEndpointReconciler records the source of the handshakes of the peers
+// of a server and pins it into the endpoint of peers with endpoint pinning,
+// so that the server reconnects to roaming site routers after a restart
+// without waiting for them to initiate.
+type PeerEndpointReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile updates the observed endpoints of the peers of a server from
+// the peer status the server reports.
+func (r *PeerEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(server.Namespace), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: server.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+	current := map[string]string{}
+	for _, status := range server.Status.Peers {
+		if status.Endpoint != "" && status.LatestHandshake != nil && now.Sub(status.LatestHandshake.Time) <= handshakeFreshness {
+			current[status.PublicKey] = status.Endpoint
+		}
+	}
+
+	var requeue time.Duration
+	for i := range peers.Items {
+		peer := &peers.Items[i]
+		endpoint, ok := current[peer.Spec.PublicKey]
+		if !ok || peer.Spec.PublicKey == "" || !peer.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if endpoint != peer.Status.ObservedEndpoint {
+			since := metav1.NewTime(now)
+			peer.Status.ObservedEndpoint = endpoint
+			peer.Status.ObservedEndpointSince = &since
+			if err := r.Status().Update(ctx, peer); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		pinning := peer.Spec.EndpointPinning
+		if pinning == nil || peer.Spec.Endpoint == endpoint || peer.Status.ObservedEndpointSince == nil {
+			continue
+		}
+		stableFor := defaultEndpointStableFor
+		if pinning.StableFor != nil {
+			stableFor = pinning.StableFor.Duration
+		}
+		// Hysteresis: only an endpoint the peer kept using is pinned
+		if remaining := stableFor - now.Sub(peer.Status.ObservedEndpointSince.Time); remaining > 0 {
+			if requeue == 0 || remaining < requeue {
+				requeue = remaining
+			}
+			continue
+		}
+
+		previous := peer.Spec.Endpoint
+		patch := client.MergeFrom(peer.DeepCopy())
+		peer.Spec.Endpoint = endpoint
+		if err := r.Patch(ctx, peer, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+		logger.Info("pinned peer endpoint", "peer", peer.Name, "endpoint", endpoint, "previous", previous)
+		if previous == "" {
+			r.Recorder.Eventf(peer, corev1.EventTypeNormal, "EndpointPinned", "Pinned endpoint %s", endpoint)
+		} else {
+			r.Recorder.Eventf(peer, corev1.EventTypeNormal, "EndpointPinned", "Pinned endpoint %s, replacing %s", endpoint, previous)
+		}
+	}
+	return ctrl.Result{RequeueAfter: requeue}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PeerEndpointReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("peerendpoint").
+		For(&vpnv1alpha1.VPNServer{}).
+		Watches(&vpnv1alpha1.VPNPeer{}, serverForPeerCoalesced()).
+		Complete(instrument(mgr, "peerendpoint", &vpnv1alpha1.VPNServer{}, r))
+}
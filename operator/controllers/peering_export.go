@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"net"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/peering"
+)
+
+const (
+	// peeringBundleKey is the key of the peering bundle in the ConfigMap of
+	// an exported server, and the default key of bundleSecretRef
+	peeringBundleKey = "bundle"
+
+	// peeringSigningKeyKey is the key of the public signing key in the
+	// ConfigMap of an exported server, and of the private one in the
+	// signing key Secret
+	peeringSigningKeyKey = "signing-key"
+
+	// defaultPeeringValidity is how long bundles are valid when the export
+	// does not say
+	defaultPeeringValidity = 30 * 24 * time.Hour
+)
+
+// PeeringExportReconciler exports the identity of servers with
+// spec.peeringExport as a peering bundle signed with the signing key of
+// the operator, in the ConfigMap <server>-peering-bundle. Bundles are
+// signed again when the identity changes and before they expire.
+type PeeringExportReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// ClusterName is the name of this cluster recorded in the bundles
+	ClusterName string
+
+	// SigningKeySecret is the Secret holding the signing key, generated if
+	// it does not exist
+	SigningKeySecret types.NamespacedName
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile applies the peering bundle of a server.
+func (r *PeeringExportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	original := server.DeepCopy()
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: server.Namespace, Name: peeringBundleName(server)}}
+
+	export := server.Spec.PeeringExport
+	if export == nil || server.Status.PublicKey == "" {
+		if err := r.Delete(ctx, cm); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+		server.Status.PeeringExport = nil
+		if equality.Semantic.DeepEqual(original.Status, server.Status) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, patchServerStatus(ctx, r.Client, server, original)
+	}
+
+	key, err := r.signingKey(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	public := key.Public().(ed25519.PublicKey)
+	validity := defaultPeeringValidity
+	if export.Validity != nil && export.Validity.Duration > 0 {
+		validity = export.Validity.Duration
+	}
+	now := time.Now()
+	bundle := &peering.Bundle{
+		Cluster:   r.ClusterName,
+		Namespace: server.Namespace,
+		Server:    server.Name,
+		PublicKey: server.Status.PublicKey,
+		Endpoint:  server.Status.Endpoint,
+		Address:   server.Spec.Address,
+	}
+	if _, tunnel, err := net.ParseCIDR(server.Spec.Address); err == nil {
+		bundle.Networks = append(bundle.Networks, tunnel.String())
+	}
+	bundle.Networks = append(bundle.Networks, export.Sites...)
+
+	if err := r.Get(ctx, client.ObjectKeyFromObject(cm), cm); client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+	// The current bundle is kept while it carries the same identity and
+	// has more than a third of its validity left
+	encoded := cm.Data[peeringBundleKey]
+	current, _, err := peering.Verify(encoded, []ed25519.PublicKey{public}, now)
+	if err != nil || !sameIdentity(current, bundle) || now.After(current.ExpiresAt.Add(-validity/3)) {
+		bundle.IssuedAt = now.UTC().Truncate(time.Second)
+		bundle.ExpiresAt = bundle.IssuedAt.Add(validity)
+		if encoded, err = peering.Sign(bundle, key); err != nil {
+			return ctrl.Result{}, err
+		}
+		current = bundle
+		r.Recorder.Eventf(server, corev1.EventTypeNormal, "PeeringBundleSigned",
+			"Signed the peering bundle in ConfigMap %s, valid until %s", cm.Name, bundle.ExpiresAt.Format(time.RFC3339))
+	}
+
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, cm, func() error {
+		cm.Labels = serverLabels(server)
+		cm.Data = map[string]string{
+			peeringBundleKey:     encoded,
+			peeringSigningKeyKey: peering.EncodePublicKey(public),
+		}
+		return controllerutil.SetControllerReference(server, cm, r.Scheme)
+	}); err != nil {
+		return ctrl.Result{}, err
+	}
+	server.Status.PeeringExport = &vpnv1alpha1.PeeringExportStatus{
+		ConfigMap:  cm.Name,
+		SigningKey: peering.EncodePublicKey(public),
+		ExpiresAt:  metav1.NewTime(current.ExpiresAt),
+	}
+
+	result := ctrl.Result{RequeueAfter: current.ExpiresAt.Add(-validity / 3).Sub(now)}
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return result, nil
+	}
+	return result, patchServerStatus(ctx, r.Client, server, original)
+}
+
+// signingKey returns the signing key of the operator, generating it into
+// its Secret on first use.
+func (r *PeeringExportReconciler) signingKey(ctx context.Context) (ed25519.PrivateKey, error) {
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, r.SigningKeySecret, secret)
+	if err == nil {
+		raw := secret.Data[peeringSigningKeyKey]
+		if len(raw) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("secret %s has no %s key of %d bytes", r.SigningKeySecret, peeringSigningKeyKey, ed25519.PrivateKeySize)
+		}
+		return ed25519.PrivateKey(raw), nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	key, err := peering.GenerateSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: r.SigningKeySecret.Namespace, Name: r.SigningKeySecret.Name},
+		Data:       map[string][]byte{peeringSigningKeyKey: key},
+	}
+	if err := r.Create(ctx, secret); err != nil {
+		// The retry reads the key of a replica that created it first
+		return nil, err
+	}
+	return key, nil
+}
+
+// sameIdentity returns whether two bundles carry the same identity,
+// regardless of their validity.
+func sameIdentity(a, b *peering.Bundle) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	x, y := *a, *b
+	x.IssuedAt, x.ExpiresAt, y.IssuedAt, y.ExpiresAt = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+	return equality.Semantic.DeepEqual(x, y)
+}
+
+// peeringBundleName returns the name of the ConfigMap of the peering
+// bundle of a server.
+func peeringBundleName(server *vpnv1alpha1.VPNServer) string {
+	return server.Name + "-peering-bundle"
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PeeringExportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("peeringexport").
+		For(&vpnv1alpha1.VPNServer{}).
+		Owns(&corev1.ConfigMap{}).
+		Complete(instrument(mgr, "peeringexport", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// peerStatsSummary is the peer stats summary the agent pushes on its pod.
+// Entries are [endpoint, latest handshake, received bytes, sent bytes,
+// round-trip time], the handshake in unix seconds and the round-trip time
+// in milliseconds, null or left out by older agents if unknown.
+type peerStatsSummary struct {
+	SampledAt int64                         `json:"sampledAt"`
+	Peers     map[string][5]json.RawMessage `json:"peers"`
+}
+
+// PeerStatsReconciler aggregates the peer stats the agents of a server push
+// on their pods into the status of the server. The agents sample their
+// device and push on significant changes only, so the operator neither
+// execs into server pods nor polls them.
+type PeerStatsReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+
+// Reconcile sets the peers, connected clients and total traffic of a server
+// from the summaries of its pods. The counters of a peer are summed over
+// the replicas; its endpoint and handshake are those of its latest
+// handshake.
+func (r *PeerStatsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		if apierrors.IsNotFound(err) {
+			peerEndpointChanges.DeletePartialMatch(prometheus.Labels{"namespace": req.Namespace, "server": req.Name})
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(server.Namespace), client.MatchingLabels(serverLabels(server))); err != nil {
+		return ctrl.Result{}, err
+	}
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(server.Namespace), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: server.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	names := map[string]string{}
+	for _, peer := range peers.Items {
+		if peer.Spec.PublicKey != "" {
+			names[peer.Spec.PublicKey] = peer.Name
+		}
+	}
+
+	observed := map[string]*vpnv1alpha1.PeerStatus{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		raw, ok := pod.Annotations[vpnv1alpha1.PeerStatsAnnotation]
+		if !ok || !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		summary := peerStatsSummary{}
+		if err := json.Unmarshal([]byte(raw), &summary); err != nil {
+			logger.Info("ignoring malformed peer stats", "pod", pod.Name, "error", err.Error())
+			continue
+		}
+		for publicKey, entry := range summary.Peers {
+			name, known := names[publicKey]
+			if !known {
+				continue
+			}
+			var endpoint string
+			var handshake, received, sent int64
+			var roundTrip *float64
+			if json.Unmarshal(entry[0], &endpoint) != nil || json.Unmarshal(entry[1], &handshake) != nil ||
+				json.Unmarshal(entry[2], &received) != nil || json.Unmarshal(entry[3], &sent) != nil ||
+				len(entry[4]) > 0 && json.Unmarshal(entry[4], &roundTrip) != nil {
+				continue
+			}
+			status, ok := observed[publicKey]
+			if !ok {
+				status = &vpnv1alpha1.PeerStatus{Name: name, PublicKey: publicKey}
+				observed[publicKey] = status
+			}
+			status.ReceiveBytes += received
+			status.TransmitBytes += sent
+			if status.LatestHandshake == nil || handshake > status.LatestHandshake.Unix() {
+				latest := metav1.Unix(handshake, 0)
+				status.LatestHandshake = &latest
+				status.Endpoint = endpoint
+				status.RoundTripTime = nil
+				if roundTrip != nil {
+					status.RoundTripTime = &metav1.Duration{Duration: time.Duration(*roundTrip * float64(time.Millisecond))}
+				}
+			}
+		}
+	}
+
+	original := server.DeepCopy()
+	now := time.Now()
+	server.Status.Peers = make([]vpnv1alpha1.PeerStatus, 0, len(observed))
+	server.Status.ConnectedClients = 0
+	server.Status.TotalTraffic = 0
+	for _, status := range observed {
+		server.Status.Peers = append(server.Status.Peers, *status)
+		if now.Sub(status.LatestHandshake.Time) <= handshakeFreshness {
+			server.Status.ConnectedClients++
+		}
+		server.Status.TotalTraffic += status.ReceiveBytes + status.TransmitBytes
+	}
+	sort.Slice(server.Status.Peers, func(i, j int) bool { return server.Status.Peers[i].Name < server.Status.Peers[j].Name })
+	if len(server.Status.Peers) == 0 {
+		server.Status.Peers = nil
+	}
+	if !equality.Semantic.DeepEqual(original.Status, server.Status) {
+		if err := patchServerStatus(ctx, r.Client, server, original); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	recordEndpointChanges(server, original.Status.Peers, server.Status.Peers)
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PeerStatsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("peerstats").
+		For(&vpnv1alpha1.VPNServer{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(serverForPod)).
+		Watches(&vpnv1alpha1.VPNPeer{}, serverForPeerCoalesced()).
+		Complete(instrument(mgr, "peerstats", &vpnv1alpha1.VPNServer{}, r))
+}
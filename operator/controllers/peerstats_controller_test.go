@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+func TestPeerStatsReconcilerReportsRoundTripTime(t *testing.T) {
+	server := testServer("edge")
+	link := testPeer("mesh-west", "edge", testKeyA, "10.9.0.1")
+	laptop := testPeer("laptop", "edge", testKeyB, "10.8.0.2")
+	now := time.Now().Unix()
+	pod := testPod(server, "edge-0", true, map[string]string{
+		vpnv1alpha1.PeerStatsAnnotation: fmt.Sprintf(`{"sampledAt": %d, "peers": {"%s": ["203.0.113.2:51820", %d, 10, 20, 12.5], "%s": ["198.51.100.7:40000", %d, 30, 40]}}`,
+			now, testKeyA, now, testKeyB, now),
+	})
+	c, scheme := newTestClient(t, server, link, laptop, pod)
+	r := &PeerStatsReconciler{Client: c, Scheme: scheme}
+
+	updated := reconcileServer(t, r, c, server)
+	if len(updated.Status.Peers) != 2 {
+		t.Fatalf("peers = %+v, want laptop and mesh-west", updated.Status.Peers)
+	}
+	byName := map[string]vpnv1alpha1.PeerStatus{}
+	for _, peer := range updated.Status.Peers {
+		byName[peer.Name] = peer
+	}
+	if rtt := byName["mesh-west"].RoundTripTime; rtt == nil || rtt.Duration != 12500*time.Microsecond {
+		t.Errorf("round-trip time of mesh-west = %v, want 12.5ms", rtt)
+	}
+	if rtt := byName["laptop"].RoundTripTime; rtt != nil {
+		t.Errorf("round-trip time of laptop = %v, want none from an entry without one", rtt)
+	}
+	if byName["laptop"].ReceiveBytes != 30 {
+		t.Errorf("laptop = %+v, want the entry without a round-trip time parsed", byName["laptop"])
+	}
+}
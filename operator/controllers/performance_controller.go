@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// appliedPerformanceReport is the tuning report the agent pushes on its pod
+type appliedPerformanceReport struct {
+	ReportedAt int64    `json:"reportedAt"`
+	RmemMax    int64    `json:"rmemMax"`
+	WmemMax    int64    `json:"wmemMax"`
+	TxQueueLen int32    `json:"txQueueLen"`
+	RPSCPUs    string   `json:"rpsCPUs"`
+	XPSCPUs    string   `json:"xpsCPUs"`
+	Errors     []string `json:"errors"`
+}
+
+// PerformanceReconciler reports the data plane tuning in effect on the
+// replicas of servers with spec.performance. The agents apply the tuning
+// and read the values back, since sysctls the kernel does not namespace
+// and queues the uplink lacks can silently keep their defaults.
+type PerformanceReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile sets the performance status and the PerformanceTuned condition
+// of a server from the reports of its ready pods.
+func (r *PerformanceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	original := server.DeepCopy()
+	tuning := server.Spec.Performance
+	if tuning == nil {
+		server.Status.Performance = nil
+		vpnv1alpha1.RemoveCondition(&server.Status.Conditions, vpnv1alpha1.ConditionPerformanceTuned)
+		if equality.Semantic.DeepEqual(original.Status, server.Status) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, patchServerStatus(ctx, r.Client, server, original)
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(server.Namespace), client.MatchingLabels(serverLabels(server))); err != nil {
+		return ctrl.Result{}, err
+	}
+	performance := &vpnv1alpha1.PerformanceStatus{}
+	var differing []string
+	ready := 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !isPodReady(pod) || !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		ready++
+		raw, ok := pod.Annotations[vpnv1alpha1.AppliedPerformanceAnnotation]
+		if !ok {
+			continue
+		}
+		report := appliedPerformanceReport{}
+		if err := json.Unmarshal([]byte(raw), &report); err != nil {
+			logger.Info("ignoring malformed performance report", "pod", pod.Name, "error", err.Error())
+			continue
+		}
+		reportedTime := metav1.Unix(report.ReportedAt, 0)
+		replica := vpnv1alpha1.ReplicaPerformance{
+			Pod:                pod.Name,
+			ReportedTime:       &reportedTime,
+			ReceiveBufferBytes: report.RmemMax,
+			SendBufferBytes:    report.WmemMax,
+			TxQueueLen:         report.TxQueueLen,
+			RPSCPUs:            report.RPSCPUs,
+			XPSCPUs:            report.XPSCPUs,
+			Errors:             report.Errors,
+		}
+		performance.Replicas = append(performance.Replicas, replica)
+		if knobs := untunedKnobs(tuning, replica); len(knobs) > 0 {
+			differing = append(differing, pod.Name+": "+strings.Join(knobs, ", "))
+		}
+	}
+	sort.Slice(performance.Replicas, func(i, j int) bool { return performance.Replicas[i].Pod < performance.Replicas[j].Pod })
+	sort.Strings(differing)
+
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionPerformanceTuned,
+		Status:             vpnv1alpha1.ConditionTrue,
+		Reason:             "Applied",
+		Message:            fmt.Sprintf("%d replicas run with spec.performance", ready),
+		ObservedGeneration: server.Generation,
+	}
+	switch {
+	case len(differing) > 0:
+		condition.Status = vpnv1alpha1.ConditionFalse
+		condition.Reason = "NotApplied"
+		condition.Message = fmt.Sprintf("%d of %d replicas differ from spec.performance; %s",
+			len(differing), ready, strings.Join(differing, "; "))
+	case len(performance.Replicas) < ready || ready == 0:
+		condition.Status = vpnv1alpha1.ConditionUnknown
+		condition.Reason = "NotReported"
+		condition.Message = fmt.Sprintf("%d of %d replicas reported their tuning", len(performance.Replicas), ready)
+	}
+
+	previous := vpnv1alpha1.FindCondition(original.Status.Conditions, condition.Type)
+	if condition.Status == vpnv1alpha1.ConditionFalse && (previous == nil || previous.Message != condition.Message) {
+		r.Recorder.Event(server, corev1.EventTypeWarning, "PerformanceNotApplied", condition.Message)
+	}
+	vpnv1alpha1.SetCondition(&server.Status.Conditions, condition)
+	server.Status.Performance = performance
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{}, patchServerStatus(ctx, r.Client, server, original)
+}
+
+// untunedKnobs returns the knobs of tuning a replica does not have in
+// effect, e.g. "txQueueLen 1000".
+func untunedKnobs(tuning *vpnv1alpha1.PerformanceTuning, replica vpnv1alpha1.ReplicaPerformance) []string {
+	var knobs []string
+	if tuning.ReceiveBufferBytes != nil && replica.ReceiveBufferBytes != int64(*tuning.ReceiveBufferBytes) {
+		knobs = append(knobs, "receiveBufferBytes "+strconv.FormatInt(replica.ReceiveBufferBytes, 10))
+	}
+	if tuning.SendBufferBytes != nil && replica.SendBufferBytes != int64(*tuning.SendBufferBytes) {
+		knobs = append(knobs, "sendBufferBytes "+strconv.FormatInt(replica.SendBufferBytes, 10))
+	}
+	if tuning.TxQueueLen != nil && replica.TxQueueLen != *tuning.TxQueueLen {
+		knobs = append(knobs, "txQueueLen "+strconv.Itoa(int(replica.TxQueueLen)))
+	}
+	if tuning.RPSCPUs != "" && normalizeCPUMask(replica.RPSCPUs) != normalizeCPUMask(tuning.RPSCPUs) {
+		knobs = append(knobs, "rpsCPUs "+replica.RPSCPUs)
+	}
+	if tuning.XPSCPUs != "" && normalizeCPUMask(replica.XPSCPUs) != normalizeCPUMask(tuning.XPSCPUs) {
+		knobs = append(knobs, "xpsCPUs "+replica.XPSCPUs)
+	}
+	return append(knobs, replica.Errors...)
+}
+
+// normalizeCPUMask returns a hex CPU mask without separators and leading
+// zeros, as sysfs pads masks to the number of CPUs of the node.
+func normalizeCPUMask(mask string) string {
+	mask = strings.TrimLeft(strings.ToLower(strings.ReplaceAll(mask, ",", "")), "0")
+	if mask == "" {
+		return "0"
+	}
+	return mask
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PerformanceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("performance").
+		For(&vpnv1alpha1.VPNServer{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(serverForPod)).
+		Complete(instrument(mgr, "performance", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// PlacementReconciler records why server pods avoiding saturated nodes were
+// placed where they were, as an annotation on the pod and an event on the
+// server.
+type PlacementReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Monitor  *NICSaturationMonitor
+}
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile records the placement rationale of a scheduled server pod.
+func (r *PlacementReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	pod := &corev1.Pod{}
+	if err := r.Get(ctx, req.NamespacedName, pod); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if pod.Spec.NodeName == "" || pod.Annotations[vpnv1alpha1.PlacementAnnotation] != "" {
+		return ctrl.Result{}, nil
+	}
+
+	server := &vpnv1alpha1.VPNServer{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Labels["app.kubernetes.io/instance"]}, server)
+	if apierrors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if server.Spec.Placement == nil || server.Spec.Placement.AvoidSaturatedNodes == "" {
+		return ctrl.Result{}, nil
+	}
+
+	rationale := r.rationale(pod.Spec.NodeName)
+	patch := client.MergeFrom(pod.DeepCopy())
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[vpnv1alpha1.PlacementAnnotation] = rationale
+	if err := r.Patch(ctx, pod, patch); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.Recorder.Eventf(server, corev1.EventTypeNormal, "Placed", "Replica %s %s", pod.Name, rationale)
+	return ctrl.Result{}, nil
+}
+
+// rationale describes the placement of a pod on node.
+func (r *PlacementReconciler) rationale(node string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "scheduled on node %s", node)
+	if value, ok := r.Monitor.Utilization(node); ok {
+		fmt.Fprintf(&b, " (NIC utilization %.0f%%)", value*100)
+	} else {
+		b.WriteString(" (NIC utilization unknown)")
+	}
+
+	saturated := r.Monitor.Saturated()
+	if len(saturated) == 0 {
+		b.WriteString(", no node was saturated")
+		return b.String()
+	}
+	names := make([]string, 0, len(saturated))
+	for name := range saturated {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	avoided := make([]string, 0, len(names))
+	for _, name := range names {
+		avoided = append(avoided, fmt.Sprintf("%s (%.0f%%)", name, saturated[name]*100))
+	}
+	fmt.Fprintf(&b, ", avoiding saturated nodes %s", strings.Join(avoided, ", "))
+	return b.String()
+}
+
+// placementAffinity adds the node affinity keeping the pods of a server off
+// saturated nodes to affinity, following spec.placement.
+func placementAffinity(server *vpnv1alpha1.VPNServer, affinity *corev1.Affinity) *corev1.Affinity {
+	if server.Spec.Placement == nil || server.Spec.Placement.AvoidSaturatedNodes == "" {
+		return affinity
+	}
+	if affinity == nil {
+		affinity = &corev1.Affinity{}
+	}
+	if affinity.NodeAffinity == nil {
+		affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	notSaturated := corev1.NodeSelectorRequirement{
+		Key:      vpnv1alpha1.NICSaturatedLabel,
+		Operator: corev1.NodeSelectorOpDoesNotExist,
+	}
+
+	nodeAffinity := affinity.NodeAffinity
+	switch server.Spec.Placement.AvoidSaturatedNodes {
+	case vpnv1alpha1.SaturationAvoidanceRequired:
+		required := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+		if required == nil || len(required.NodeSelectorTerms) == 0 {
+			nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{notSaturated}}},
+			}
+			break
+		}
+		// Terms are ORed, so every term must exclude saturated nodes
+		for i := range required.NodeSelectorTerms {
+			term := &required.NodeSelectorTerms[i]
+			term.MatchExpressions = append(term.MatchExpressions, notSaturated)
+		}
+	case vpnv1alpha1.SaturationAvoidancePreferred:
+		nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+			corev1.PreferredSchedulingTerm{
+				Weight:     100,
+				Preference: corev1.NodeSelectorTerm{MatchExpressions: []corev1.NodeSelectorRequirement{notSaturated}},
+			})
+	}
+	return affinity
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PlacementReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	serverPods := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return isServerPod(obj.GetLabels())
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("placement").
+		For(&corev1.Pod{}, builder.WithPredicates(serverPods)).
+		Complete(instrument(mgr, "placement", nil, r))
+}
@@ -0,0 +1,323 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// poolMigrationInterval is how often running migrations check for
+// reconnected clients, besides the updates of the peer stats of the server
+const poolMigrationInterval = time.Minute
+
+// VPNPoolMigrationReconciler re-addresses the peers of a server from an old
+// network to an IP pool in batches. A re-addressed peer keeps its old
+// address as an additional allowed IP, so the server routes both while its
+// client moves to the regenerated config. A client is considered to have
+// reconnected once it handshakes after its peer was re-addressed, and the
+// old address is released after the retention.
+type VPNPoolMigrationReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpoolmigrations,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpoolmigrations/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnippools,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile advances a migration: it releases the old addresses of the
+// peers whose clients reconnected and re-addresses the next batch.
+func (r *VPNPoolMigrationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	migration := &vpnv1alpha1.VPNPoolMigration{}
+	if err := r.Get(ctx, req.NamespacedName, migration); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !migration.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	original := migration.DeepCopy()
+	spec := migration.Spec
+
+	server := &vpnv1alpha1.VPNServer{}
+	pool := &vpnv1alpha1.VPNIPPool{}
+	missing := ""
+	if err := r.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: spec.ServerRef.Name}, server); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		missing = fmt.Sprintf("VPNServer %q does not exist", spec.ServerRef.Name)
+	} else if err := r.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: spec.ToPoolRef.Name}, pool); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		missing = fmt.Sprintf("VPNIPPool %q does not exist", spec.ToPoolRef.Name)
+	}
+	_, from, err := net.ParseCIDR(spec.FromCIDR)
+	if missing == "" && err != nil {
+		missing = fmt.Sprintf("fromCIDR: %v", err)
+	}
+	if missing != "" {
+		vpnv1alpha1.SetCondition(&migration.Status.Conditions, vpnv1alpha1.Condition{
+			Type:               vpnv1alpha1.ConditionReady,
+			Status:             vpnv1alpha1.ConditionFalse,
+			Reason:             "InvalidReference",
+			Message:            missing,
+			ObservedGeneration: migration.Generation,
+		})
+		return ctrl.Result{}, r.patchStatus(ctx, migration, original)
+	}
+
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(req.Namespace), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: server.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	byName := map[string]*vpnv1alpha1.VPNPeer{}
+	for i := range peers.Items {
+		byName[peers.Items[i].Name] = &peers.Items[i]
+	}
+	handshakes := map[string]*metav1.Time{}
+	for _, status := range server.Status.Peers {
+		handshakes[status.Name] = status.LatestHandshake
+	}
+	reconnectTimeout := 24 * time.Hour
+	if spec.ReconnectTimeout != nil {
+		reconnectTimeout = spec.ReconnectTimeout.Duration
+	}
+	retention := time.Hour
+	if spec.OldAddressRetention != nil {
+		retention = spec.OldAddressRetention.Duration
+	}
+	now := time.Now()
+
+	// Progress the peers re-addressed so far
+	migrated := map[string]bool{}
+	inFlight := int32(0)
+	for i := range migration.Status.Peers {
+		entry := &migration.Status.Peers[i]
+		migrated[entry.Name] = true
+		peer := byName[entry.Name]
+		if peer == nil || !peer.DeletionTimestamp.IsZero() {
+			// Deleting the peer released both addresses
+			entry.State = vpnv1alpha1.PeerMigrationReleased
+			continue
+		}
+		if entry.State == vpnv1alpha1.PeerMigrationReaddressed || entry.State == vpnv1alpha1.PeerMigrationTimedOut {
+			if handshake := handshakes[entry.Name]; handshake != nil && handshake.After(entry.ReaddressedTime.Time) {
+				entry.State = vpnv1alpha1.PeerMigrationReconnected
+				entry.ReconnectedTime = handshake.DeepCopy()
+			} else if entry.State == vpnv1alpha1.PeerMigrationReaddressed && now.Sub(entry.ReaddressedTime.Time) >= reconnectTimeout {
+				entry.State = vpnv1alpha1.PeerMigrationTimedOut
+				r.Recorder.Eventf(migration, corev1.EventTypeWarning, "ReconnectTimedOut",
+					"The client of peer %s did not reconnect within %s, it keeps %s until it does", entry.Name, reconnectTimeout, entry.OldAddress)
+			}
+		}
+		if entry.State == vpnv1alpha1.PeerMigrationReconnected && now.Sub(entry.ReconnectedTime.Time) >= retention {
+			if err := r.releaseOldAddress(ctx, peer, entry.OldAddress); err != nil {
+				return ctrl.Result{}, err
+			}
+			entry.State = vpnv1alpha1.PeerMigrationReleased
+			logger.Info("released old address", "peer", peer.Name, "address", entry.OldAddress)
+		}
+		if entry.State == vpnv1alpha1.PeerMigrationReaddressed {
+			inFlight++
+		}
+	}
+
+	// Peers still addressed from the old network, in name order
+	var remaining []*vpnv1alpha1.VPNPeer
+	for i := range peers.Items {
+		peer := &peers.Items[i]
+		ip, _ := splitAddress(peer.Spec.Address)
+		if ip != nil && from.Contains(ip) && !migrated[peer.Name] && peer.DeletionTimestamp.IsZero() {
+			remaining = append(remaining, peer)
+		}
+	}
+	sort.Slice(remaining, func(i, j int) bool { return remaining[i].Name < remaining[j].Name })
+
+	batchSize := spec.BatchSize
+	if batchSize <= 0 {
+		batchSize = 10
+	}
+	exhausted := false
+	// Re-addressing makes clients reconnect, new batches wait for the
+	// maintenance windows of the server
+	allowed, window := server.MaintenanceAllowed(now)
+	if !spec.Paused && allowed && inFlight < batchSize && len(remaining) > 0 {
+		used, err := usedAddresses(ctx, r.Client, &vpnv1alpha1.VPNPeer{ObjectMeta: metav1.ObjectMeta{Namespace: req.Namespace}})
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		for len(remaining) > 0 && inFlight < batchSize {
+			peer := remaining[0]
+			ip, err := allocateFrom(ctx, r.Client, peer, activeCIDRs(pool), used)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if ip == nil {
+				exhausted = true
+				break
+			}
+			entry, err := r.readdress(ctx, peer, ip)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			used[ip.String()] = true
+			migration.Status.Peers = append(migration.Status.Peers, entry)
+			remaining = remaining[1:]
+			inFlight++
+			r.Recorder.Eventf(peer, corev1.EventTypeNormal, "AddressMigrated",
+				"Re-addressed from %s to %s by VPNPoolMigration %s", entry.OldAddress, entry.NewAddress, migration.Name)
+		}
+	}
+
+	status := &migration.Status
+	status.Total = int32(len(status.Peers) + len(remaining))
+	status.Readdressed = int32(len(status.Peers))
+	status.Reconnected = 0
+	released := 0
+	for _, entry := range status.Peers {
+		switch entry.State {
+		case vpnv1alpha1.PeerMigrationReconnected:
+			status.Reconnected++
+		case vpnv1alpha1.PeerMigrationReleased:
+			status.Reconnected++
+			released++
+		}
+	}
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionReady,
+		Status:             vpnv1alpha1.ConditionTrue,
+		Reason:             "Migrating",
+		Message:            fmt.Sprintf("%d of %d peers re-addressed, %d reconnected", status.Readdressed, status.Total, status.Reconnected),
+		ObservedGeneration: migration.Generation,
+	}
+	switch {
+	case len(remaining) == 0 && released == len(status.Peers):
+		status.Phase = vpnv1alpha1.PoolMigrationPhaseCompleted
+		condition.Reason = "Completed"
+	case spec.Paused:
+		status.Phase = vpnv1alpha1.PoolMigrationPhasePaused
+		condition.Reason = "Paused"
+	default:
+		status.Phase = vpnv1alpha1.PoolMigrationPhaseRunning
+	}
+	if !allowed && !spec.Paused && len(remaining) > 0 && status.Phase == vpnv1alpha1.PoolMigrationPhaseRunning {
+		condition.Reason = "AwaitingMaintenanceWindow"
+		condition.Message += ", the next batch waits for a maintenance window"
+		if !window.IsZero() {
+			condition.Message += " opening at " + window.UTC().Format(time.RFC3339)
+		}
+	}
+	if exhausted {
+		condition.Status = vpnv1alpha1.ConditionFalse
+		condition.Reason = "PoolExhausted"
+		condition.Message = fmt.Sprintf("VPNIPPool %s has no free address left for %d peers", pool.Name, len(remaining))
+		if !equality.Semantic.DeepEqual(vpnv1alpha1.FindCondition(original.Status.Conditions, condition.Type), &condition) {
+			r.Recorder.Event(migration, corev1.EventTypeWarning, "PoolExhausted", condition.Message)
+		}
+	}
+	if status.Phase == vpnv1alpha1.PoolMigrationPhaseCompleted && original.Status.Phase != status.Phase {
+		r.Recorder.Eventf(migration, corev1.EventTypeNormal, "Completed", "Migrated %d peers to VPNIPPool %s", status.Total, pool.Name)
+	}
+	vpnv1alpha1.SetCondition(&status.Conditions, condition)
+	status.ObservedGeneration = migration.Generation
+	if err := r.patchStatus(ctx, migration, original); err != nil {
+		return ctrl.Result{}, err
+	}
+	if status.Phase == vpnv1alpha1.PoolMigrationPhaseCompleted {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: poolMigrationInterval}, nil
+}
+
+// readdress moves a peer to a new address, keeping its old address as an
+// additional allowed IP so that its client keeps working until it
+// reconnects with its new config.
+func (r *VPNPoolMigrationReconciler) readdress(ctx context.Context, peer *vpnv1alpha1.VPNPeer, ip net.IP) (vpnv1alpha1.PeerPoolMigration, error) {
+	oldIP, _ := splitAddress(peer.Spec.Address)
+	entry := vpnv1alpha1.PeerPoolMigration{
+		Name:            peer.Name,
+		OldAddress:      hostAddress(oldIP),
+		NewAddress:      hostAddress(ip),
+		State:           vpnv1alpha1.PeerMigrationReaddressed,
+		ReaddressedTime: metav1.Now(),
+	}
+	peer.Spec.Address = entry.NewAddress
+	if !containsString(peer.Spec.AllowedIPs, entry.OldAddress) {
+		peer.Spec.AllowedIPs = append(peer.Spec.AllowedIPs, entry.OldAddress)
+	}
+	return entry, r.Update(ctx, peer)
+}
+
+// releaseOldAddress removes the old address of a migrated peer from its
+// allowed IPs.
+func (r *VPNPoolMigrationReconciler) releaseOldAddress(ctx context.Context, peer *vpnv1alpha1.VPNPeer, address string) error {
+	if !containsString(peer.Spec.AllowedIPs, address) {
+		return nil
+	}
+	var allowed []string
+	for _, cidr := range peer.Spec.AllowedIPs {
+		if cidr != address {
+			allowed = append(allowed, cidr)
+		}
+	}
+	peer.Spec.AllowedIPs = allowed
+	if err := r.Update(ctx, peer); err != nil {
+		return err
+	}
+	return releaseAddress(ctx, r.Client, peer, address)
+}
+
+// patchStatus patches the status of a migration if it changed.
+func (r *VPNPoolMigrationReconciler) patchStatus(ctx context.Context, migration, original *vpnv1alpha1.VPNPoolMigration) error {
+	if equality.Semantic.DeepEqual(original.Status, migration.Status) {
+		return nil
+	}
+	return r.Status().Patch(ctx, migration, client.MergeFrom(original))
+}
+
+// migrationsForServer maps a VPNServer to the migrations of its peers.
+func (r *VPNPoolMigrationReconciler) migrationsForServer(ctx context.Context, obj client.Object) []reconcile.Request {
+	migrations := &vpnv1alpha1.VPNPoolMigrationList{}
+	if err := r.List(ctx, migrations, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, migration := range migrations.Items {
+		if migration.Spec.ServerRef.Name == obj.GetName() && migration.Status.Phase != vpnv1alpha1.PoolMigrationPhaseCompleted {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&migration)})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VPNPoolMigrationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vpnv1alpha1.VPNPoolMigration{}).
+		Watches(&vpnv1alpha1.VPNServer{}, handler.EnqueueRequestsFromMapFunc(r.migrationsForServer)).
+		Complete(instrument(mgr, "vpnpoolmigration", &vpnv1alpha1.VPNPoolMigration{}, r))
+}
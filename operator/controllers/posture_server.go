@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/enrollment"
+)
+
+// postureReport is a posture re-validation of a peer's device
+type postureReport struct {
+	// Challenge is the challenge issued on /posture/challenge
+	Challenge string `json:"challenge"`
+
+	// Fingerprint is the fingerprint of the device
+	Fingerprint string `json:"fingerprint"`
+
+	// Proof answers the challenge with the peer's key, bound to the
+	// fingerprint
+	Proof string `json:"proof"`
+
+	// Client is the implementation and version of the agent, recorded on
+	// the peer when set
+	Client *clientReport `json:"client,omitempty"`
+}
+
+// PostureServer records the posture re-validations of peer devices. A
+// device fetches a challenge from /posture/challenge and posts its
+// fingerprint with a proof of the peer's key to /posture/<namespace>/<peer>.
+// Challenges are answered on the replica that issued them.
+type PostureServer struct {
+	client.Client
+
+	// Address is the address re-validations are served on
+	Address string
+
+	// GetCertificate returns the serving certificate of the operator
+	GetCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+	// Verifier issues the challenges and verifies the proofs
+	Verifier *enrollment.Verifier
+
+	// Recorder records re-validations from devices the peer is not bound to
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Every
+// replica serves re-validations, so that devices are not turned away
+// during leader changes.
+func (s *PostureServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable.
+func (s *PostureServer) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle("/posture/", s)
+	server := &http.Server{
+		Addr:              s.Address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+		TLSConfig: &tls.Config{
+			GetCertificate: s.GetCertificate,
+			MinVersion:     tls.VersionTLS12,
+		},
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	ctrl.Log.WithName("posture").Info("serving posture re-validations", "address", s.Address)
+	if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP issues challenges and records re-validations.
+func (s *PostureServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	logger := ctrl.Log.WithName("posture")
+	path := strings.TrimPrefix(req.URL.Path, "/posture/")
+	if path == "challenge" && req.Method == http.MethodGet {
+		challenge, err := s.Verifier.Challenge()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = io.WriteString(w, challenge)
+		return
+	}
+	parts := strings.Split(path, "/")
+	if req.Method != http.MethodPost || len(parts) != 2 {
+		http.NotFound(w, req)
+		return
+	}
+
+	report := postureReport{}
+	if err := json.NewDecoder(io.LimitReader(req.Body, 4096)).Decode(&report); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := req.Context()
+	peer := &vpnv1alpha1.VPNPeer{}
+	if err := s.Get(ctx, types.NamespacedName{Namespace: parts[0], Name: parts[1]}, peer); err != nil {
+		if apierrors.IsNotFound(err) {
+			http.NotFound(w, req)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Failures are not told apart, to leak nothing about keys
+	err := s.Verifier.VerifyDevice(report.Challenge, peer.Spec.PublicKey, report.Fingerprint, report.Proof)
+	if err != nil {
+		http.Error(w, "invalid proof", http.StatusForbidden)
+		return
+	}
+
+	now := metav1.Now()
+	original := peer.DeepCopy()
+	if peer.Status.Posture == nil {
+		peer.Status.Posture = &vpnv1alpha1.PostureStatus{}
+	}
+	peer.Status.Posture.ValidatedAt = &now
+	peer.Status.Posture.Fingerprint = report.Fingerprint
+	bound := peer.Spec.DeviceBinding == nil || peer.Spec.DeviceBinding.Fingerprint == report.Fingerprint
+	if !bound {
+		peer.Status.Posture.MismatchedAt = &now
+	}
+	if info := clientInfo(report.Client, "", clientInfoSourceAgent); info != nil {
+		peer.Status.Client = info
+	}
+	if err := s.Status().Patch(ctx, peer, client.MergeFrom(original)); err != nil {
+		logger.Error(err, "unable to record posture re-validation", "namespace", peer.Namespace, "peer", peer.Name)
+		http.Error(w, "unable to record the re-validation", http.StatusInternalServerError)
+		return
+	}
+	logger.Info("recorded posture re-validation", "namespace", peer.Namespace, "peer", peer.Name, "bound", bound)
+	if !bound {
+		s.Recorder.Eventf(peer, corev1.EventTypeWarning, "PostureMismatch",
+			"Re-validated from device %s, which the peer is not bound to", report.Fingerprint)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]bool{"bound": bound})
+}
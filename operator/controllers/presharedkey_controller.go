@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// PresharedKeyReconciler manages the preshared keys of peers: it generates
+// the keys of peers with a presharedKey into a Secret owned by the peer and
+// rotates them on schedule, and records in the status of every peer with a
+// preshared key when its key last changed, whether rotated by the operator
+// or replaced in a referenced Secret.
+type PresharedKeyReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile generates or rotates the preshared key of a peer and updates
+// its status.
+func (r *PresharedKeyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	peer := &vpnv1alpha1.VPNPeer{}
+	if err := r.Get(ctx, req.NamespacedName, peer); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !peer.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	original := peer.DeepCopy()
+	ref := presharedKeyRef(peer)
+	if ref == nil {
+		peer.Status.PresharedKey = nil
+		if equality.Semantic.DeepEqual(original.Status, peer.Status) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, r.Status().Patch(ctx, peer, client.MergeFrom(original))
+	}
+
+	now := time.Now()
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: peer.Namespace, Name: ref.Name}, secret)
+	switch {
+	case apierrors.IsNotFound(err) && peer.Spec.PresharedKey != nil:
+		psk, err := generatePresharedKey()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: peer.Namespace, Name: ref.Name},
+			Type:       corev1.SecretTypeOpaque,
+			Data:       map[string][]byte{ref.Key: []byte(psk)},
+		}
+		if err := controllerutil.SetControllerReference(peer, secret, r.Scheme); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.Create(ctx, secret); err != nil {
+			return ctrl.Result{}, err
+		}
+		logger.Info("generated preshared key", "secret", ref.Name)
+		r.Recorder.Event(peer, corev1.EventTypeNormal, "PresharedKeyGenerated",
+			fmt.Sprintf("Generated the preshared key into Secret %s", ref.Name))
+	case apierrors.IsNotFound(err):
+		// Reconciled again through the Secret watch once it is created
+		r.Recorder.Event(peer, corev1.EventTypeWarning, "PresharedKeyMissing",
+			fmt.Sprintf("Secret %s holding the preshared key does not exist", ref.Name))
+		return ctrl.Result{}, nil
+	case err != nil:
+		return ctrl.Result{}, err
+	}
+
+	dataKey := ref.Key
+	if dataKey == "" {
+		dataKey = vpnv1alpha1.DefaultPresharedKeyKey
+	}
+	if generation := peer.Spec.PresharedKey; generation != nil && generation.RotationInterval != nil && peer.Status.PresharedKey != nil &&
+		peer.Status.PresharedKey.NextRotationTime != nil && !now.Before(peer.Status.PresharedKey.NextRotationTime.Time) && metav1.IsControlledBy(secret, peer) {
+		psk, err := generatePresharedKey()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		secret.Data[dataKey] = []byte(psk)
+		if err := r.Update(ctx, secret); err != nil {
+			return ctrl.Result{}, err
+		}
+		logger.Info("rotated preshared key", "secret", ref.Name)
+	}
+
+	psk := vpnv1alpha1.NormalizeKey(string(secret.Data[dataKey]))
+	if _, err := vpnv1alpha1.ParseWireGuardKey(psk); err != nil {
+		r.Recorder.Event(peer, corev1.EventTypeWarning, "InvalidPresharedKey",
+			fmt.Sprintf("Secret %s has no valid preshared key under %s: %v", ref.Name, dataKey, err))
+		return ctrl.Result{}, nil
+	}
+	sum := sha256.Sum256([]byte(psk))
+	fingerprint := hex.EncodeToString(sum[:8])
+
+	status := peer.Status.PresharedKey
+	if status == nil {
+		status = &vpnv1alpha1.PresharedKeyStatus{}
+		peer.Status.PresharedKey = status
+	}
+	if status.Fingerprint != fingerprint {
+		if status.Fingerprint != "" {
+			r.Recorder.Event(peer, corev1.EventTypeNormal, "PresharedKeyRotated",
+				fmt.Sprintf("The preshared key in Secret %s changed to %s, the client of the peer needs its updated config", ref.Name, fingerprint))
+		}
+		rotated := metav1.NewTime(now)
+		status.Fingerprint = fingerprint
+		status.RotationTime = &rotated
+	}
+	status.SecretName = ref.Name
+	status.NextRotationTime = nil
+	var result ctrl.Result
+	if generation := peer.Spec.PresharedKey; generation != nil && generation.RotationInterval != nil {
+		next := metav1.NewTime(status.RotationTime.Add(generation.RotationInterval.Duration))
+		status.NextRotationTime = &next
+		result.RequeueAfter = time.Until(next.Time)
+	}
+	if equality.Semantic.DeepEqual(original.Status, peer.Status) {
+		return result, nil
+	}
+	return result, r.Status().Patch(ctx, peer, client.MergeFrom(original))
+}
+
+// presharedKeyRef returns the reference to the preshared key of a peer,
+// generated or referenced, or nil if it has none.
+func presharedKeyRef(peer *vpnv1alpha1.VPNPeer) *vpnv1alpha1.SecretKeyReference {
+	if peer.Spec.PresharedKey != nil {
+		return &vpnv1alpha1.SecretKeyReference{Name: peer.Name + "-psk", Key: vpnv1alpha1.DefaultPresharedKeyKey}
+	}
+	return peer.Spec.PresharedKeySecretRef
+}
+
+// peerPresharedKey returns the preshared key of a peer, or an empty string
+// if it has none.
+func peerPresharedKey(ctx context.Context, c client.Reader, peer *vpnv1alpha1.VPNPeer) (string, error) {
+	ref := presharedKeyRef(peer)
+	if ref == nil {
+		return "", nil
+	}
+	psk, err := secretKey(ctx, c, peer.Namespace, *ref, vpnv1alpha1.DefaultPresharedKeyKey)
+	if err != nil {
+		return "", err
+	}
+	return vpnv1alpha1.NormalizeKey(string(psk)), nil
+}
+
+// generatePresharedKey returns a new random preshared key, as wg genpsk.
+func generatePresharedKey() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// peersForSecret maps a Secret to the peers whose preshared key it holds.
+func (r *PresharedKeyReconciler) peersForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, peer := range peers.Items {
+		if ref := presharedKeyRef(&peer); ref != nil && ref.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&peer)})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PresharedKeyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("presharedkey").
+		For(&vpnv1alpha1.VPNPeer{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.peersForSecret)).
+		Complete(instrument(mgr, "presharedkey", &vpnv1alpha1.VPNPeer{}, r))
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Operator modes
+const (
+	// OperatorModeActive reconciles the devices and owned resources
+	OperatorModeActive = "active"
+
+	// OperatorModeReadOnly only reconciles status, for standby clusters
+	// restored from a backup of the primary
+	OperatorModeReadOnly = "read-only"
+)
+
+// operatorModeKey is the key of the mode in the operator mode ConfigMap
+const operatorModeKey = "mode"
+
+// ReadOnlyClient is the client of operators in the read-only mode. It drops
+// every write but those of status, so that reconcilers keep the status and
+// metrics of a standby cluster current without fighting the primary over
+// the owned resources and, through them, the devices.
+type ReadOnlyClient struct {
+	client.Client
+}
+
+// NewReadOnlyClient is a client.NewClientFunc returning a ReadOnlyClient.
+func NewReadOnlyClient(config *rest.Config, options client.Options) (client.Client, error) {
+	c, err := client.New(config, options)
+	if err != nil {
+		return nil, err
+	}
+	return ReadOnlyClient{Client: c}, nil
+}
+
+// Create implements client.Writer.
+func (c ReadOnlyClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	switch obj.(type) {
+	case *authenticationv1.TokenReview, *authorizationv1.SubjectAccessReview:
+		// Reviews are not persisted, the servers authorizing users need them
+		return c.Client.Create(ctx, obj, opts...)
+	}
+	skipWrite(ctx, "create", obj)
+	return nil
+}
+
+// Update implements client.Writer.
+func (c ReadOnlyClient) Update(ctx context.Context, obj client.Object, _ ...client.UpdateOption) error {
+	skipWrite(ctx, "update", obj)
+	return nil
+}
+
+// Patch implements client.Writer.
+func (c ReadOnlyClient) Patch(ctx context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+	skipWrite(ctx, "patch", obj)
+	return nil
+}
+
+// Delete implements client.Writer.
+func (c ReadOnlyClient) Delete(ctx context.Context, obj client.Object, _ ...client.DeleteOption) error {
+	skipWrite(ctx, "delete", obj)
+	return nil
+}
+
+// DeleteAllOf implements client.Writer.
+func (c ReadOnlyClient) DeleteAllOf(ctx context.Context, obj client.Object, _ ...client.DeleteAllOfOption) error {
+	skipWrite(ctx, "delete all of", obj)
+	return nil
+}
+
+// skipWrite logs a write dropped in the read-only mode.
+func skipWrite(ctx context.Context, verb string, obj client.Object) {
+	log.FromContext(ctx).V(1).Info("skipped write in read-only mode", "verb", verb,
+		"kind", obj.GetObjectKind().GroupVersionKind().Kind, "namespace", obj.GetNamespace(), "name", obj.GetName())
+}
+
+// OperatorPromoted returns whether the operator mode ConfigMap promotes
+// the operator to the active mode, which wireflow promote sets.
+func OperatorPromoted(ctx context.Context, reader client.Reader, key client.ObjectKey) (bool, error) {
+	cm := &corev1.ConfigMap{}
+	if err := reader.Get(ctx, key, cm); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return cm.Data[operatorModeKey] == OperatorModeActive, nil
+}
+
+// PromotionWatcher waits for a read-only operator to be promoted. The
+// operator then restarts, and reconciles everything in the active mode.
+type PromotionWatcher struct {
+	// Reader reads the operator mode ConfigMap, uncached
+	Reader client.Reader
+
+	// Key is the operator mode ConfigMap
+	Key client.ObjectKey
+
+	// Interval is how often the ConfigMap is read
+	Interval time.Duration
+
+	// Promoted is called once the operator is promoted
+	Promoted func()
+}
+
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Every
+// replica restarts on promotion.
+func (w *PromotionWatcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable.
+func (w *PromotionWatcher) Start(ctx context.Context) error {
+	logger := ctrl.Log.WithName("promotion")
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			promoted, err := OperatorPromoted(ctx, w.Reader, w.Key)
+			if err != nil {
+				logger.Error(err, "unable to read the operator mode", "configmap", w.Key)
+				continue
+			}
+			if promoted {
+				logger.Info("promoted to the active mode", "configmap", w.Key)
+				w.Promoted()
+				return nil
+			}
+		}
+	}
+}
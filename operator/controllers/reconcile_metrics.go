@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Reconcile phases timed with observePhase
+const (
+	// reconcilePhaseRender renders a configuration
+	reconcilePhaseRender = "render"
+
+	// reconcilePhaseSecretWrite writes a rendered configuration to its
+	// Secret
+	reconcilePhaseSecretWrite = "secret_write"
+
+	// reconcilePhaseDeviceApply hands a configuration to the agents, which
+	// apply it to their devices
+	reconcilePhaseDeviceApply = "device_apply"
+)
+
+var (
+	// reconcileOutcomes counts the reconciles of each controller by
+	// outcome, and by reason for errors
+	reconcileOutcomes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wireflow_reconcile_total",
+		Help: "Reconciles by controller and outcome: success, requeue or error. Errors carry the reason of the API error, or Other.",
+	}, []string{"controller", "outcome", "reason"})
+
+	// reconcileRequeues counts the requeues of each controller by trigger
+	reconcileRequeues = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wireflow_reconcile_requeues_total",
+		Help: "Requeued reconciles by controller and trigger: error, requeue or requeue_after.",
+	}, []string{"controller", "trigger"})
+
+	// reconcilePhaseDuration times the phases of reconciles
+	reconcilePhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wireflow_reconcile_phase_duration_seconds",
+		Help:    "Duration of the phases of reconciles by controller and phase: render, secret_write or device_apply.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"controller", "phase"})
+
+	// reconcileLastSuccess is when each resource was last reconciled
+	// successfully
+	reconcileLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "wireflow_reconcile_last_success_timestamp_seconds",
+		Help: "Unix time the resource was last reconciled successfully by the controller, or its creation time until then.",
+	}, []string{"controller", "namespace", "name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileOutcomes, reconcileRequeues, reconcilePhaseDuration, reconcileLastSuccess)
+}
+
+// controllerNameKey is the context key of the name of the reconciling
+// controller
+type controllerNameKey struct{}
+
+// observePhase starts timing a phase of the reconcile of ctx, and returns
+// the function ending it:
+//
+//	defer observePhase(ctx, reconcilePhaseRender)()
+func observePhase(ctx context.Context, phase string) func() {
+	name, _ := ctx.Value(controllerNameKey{}).(string)
+	if name == "" {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		reconcilePhaseDuration.WithLabelValues(name, phase).Observe(time.Since(start).Seconds())
+	}
+}
+
+// reconcileErrorReason returns the reason label of a reconcile error.
+func reconcileErrorReason(err error) string {
+	if reason := apierrors.ReasonForError(err); reason != metav1.StatusReasonUnknown {
+		return string(reason)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return string(metav1.StatusReasonTimeout)
+	}
+	return "Other"
+}
+
+// instrumentedReconciler records the reconcile metrics of a controller. The
+// last success of a resource is exported from its first reconcile, with its
+// creation time, so that resources that never reconciled are alerted on
+// too, and removed once the resource is gone. Controllers of pods and
+// Secrets only record their outcomes, as they have too many resources.
+type instrumentedReconciler struct {
+	name       string
+	object     client.Object
+	reader     client.Reader
+	reconciler reconcile.Reconciler
+
+	mu   sync.Mutex
+	seen map[types.NamespacedName]bool
+}
+
+// instrument returns a reconciler recording the reconcile metrics of the
+// controller name, whose resources are of the type of object. A nil object
+// skips the last success of resources.
+func instrument(mgr ctrl.Manager, name string, object client.Object, r reconcile.Reconciler) reconcile.Reconciler {
+	return &instrumentedReconciler{
+		name:       name,
+		object:     object,
+		reader:     mgr.GetClient(),
+		reconciler: r,
+		seen:       map[types.NamespacedName]bool{},
+	}
+}
+
+// Reconcile implements reconcile.Reconciler.
+func (r *instrumentedReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	result, err := r.reconciler.Reconcile(context.WithValue(ctx, controllerNameKey{}, r.name), req)
+	switch {
+	case err != nil:
+		reconcileOutcomes.WithLabelValues(r.name, "error", reconcileErrorReason(err)).Inc()
+		reconcileRequeues.WithLabelValues(r.name, "error").Inc()
+	case result.RequeueAfter > 0:
+		reconcileOutcomes.WithLabelValues(r.name, "success", "").Inc()
+		reconcileRequeues.WithLabelValues(r.name, "requeue_after").Inc()
+	case result.Requeue:
+		reconcileOutcomes.WithLabelValues(r.name, "requeue", "").Inc()
+		reconcileRequeues.WithLabelValues(r.name, "requeue").Inc()
+	default:
+		reconcileOutcomes.WithLabelValues(r.name, "success", "").Inc()
+	}
+	if r.object != nil {
+		r.recordLastSuccess(ctx, req.NamespacedName, err == nil)
+	}
+	return result, err
+}
+
+// recordLastSuccess updates the last success of a resource. The resource
+// is read from the cache on successes, which include those of its deletion,
+// and on its first reconcile.
+func (r *instrumentedReconciler) recordLastSuccess(ctx context.Context, key types.NamespacedName, succeeded bool) {
+	r.mu.Lock()
+	seen := r.seen[key]
+	r.mu.Unlock()
+	if seen && !succeeded {
+		return
+	}
+
+	obj := r.object.DeepCopyObject().(client.Object)
+	if err := r.reader.Get(ctx, key, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.mu.Lock()
+			delete(r.seen, key)
+			r.mu.Unlock()
+			reconcileLastSuccess.DeleteLabelValues(r.name, key.Namespace, key.Name)
+		}
+		return
+	}
+	r.mu.Lock()
+	r.seen[key] = true
+	r.mu.Unlock()
+	gauge := reconcileLastSuccess.WithLabelValues(r.name, key.Namespace, key.Name)
+	if succeeded {
+		gauge.SetToCurrentTime()
+	} else {
+		gauge.Set(float64(obj.GetCreationTimestamp().Unix()))
+	}
+}
@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// routeExportFinalizer makes sure the cluster-scoped CNI resources of a
+// server are deleted along with it, as they cannot be owned by it
+const routeExportFinalizer = "vpn.vpn-devops.com/route-export"
+
+// routeExportKinds are the resources the client networks of servers are
+// published as, by provider
+var routeExportKinds = map[vpnv1alpha1.RouteExportProvider]schema.GroupVersionKind{
+	vpnv1alpha1.RouteExportProviderCalico: {Group: "projectcalico.org", Version: "v3", Kind: "IPPool"},
+	vpnv1alpha1.RouteExportProviderCilium: {Group: "cilium.io", Version: "v2alpha1", Kind: "CiliumCIDRGroup"},
+}
+
+// RouteExportReconciler publishes the client networks of servers with
+// spec.routeExport to the CNI of the cluster. Pod traffic to clients is
+// then neither masqueraded by the CNI nor hairpinned through the Service
+// of the server.
+type RouteExportReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/finalizers,verbs=update
+//+kubebuilder:rbac:groups=projectcalico.org,resources=ippools,verbs=get;list;watch;create;update;patch;delete;deletecollection
+//+kubebuilder:rbac:groups=cilium.io,resources=ciliumcidrgroups,verbs=get;list;watch;create;update;patch;delete;deletecollection
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile applies the CNI resource of a server and deletes those of other
+// providers, or all of them once the server is deleted or stops exporting
+// its client network.
+func (r *RouteExportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	export := server.Spec.RouteExport
+	if export == nil || !server.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(server, routeExportFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		if err := r.deleteExported(ctx, server, ""); err != nil {
+			return ctrl.Result{}, err
+		}
+		controllerutil.RemoveFinalizer(server, routeExportFinalizer)
+		if err := r.Update(ctx, server); err != nil || !server.DeletionTimestamp.IsZero() {
+			return ctrl.Result{}, err
+		}
+		original := server.DeepCopy()
+		vpnv1alpha1.RemoveCondition(&server.Status.Conditions, vpnv1alpha1.ConditionRoutesExported)
+		return ctrl.Result{}, r.patchStatus(ctx, server, original)
+	}
+
+	if controllerutil.AddFinalizer(server, routeExportFinalizer) {
+		if err := r.Update(ctx, server); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	original := server.DeepCopy()
+	network, err := clientNetwork(server)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.deleteExported(ctx, server, export.Provider); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionRoutesExported,
+		Status:             vpnv1alpha1.ConditionTrue,
+		Reason:             "Exported",
+		Message:            fmt.Sprintf("%s is published to %s", network, export.Provider),
+		ObservedGeneration: server.Generation,
+	}
+	err = r.Patch(ctx, exportedNetwork(server, export.Provider, network.String()), client.Apply,
+		client.FieldOwner("vpn-operator"), client.ForceOwnership)
+	switch {
+	case meta.IsNoMatchError(err):
+		logger.Info("the CNI API is not installed, not exporting the client network", "provider", export.Provider)
+		condition.Status = vpnv1alpha1.ConditionFalse
+		condition.Reason = "CNIUnavailable"
+		condition.Message = fmt.Sprintf("the %s API of %s is not installed", routeExportKinds[export.Provider].Kind, export.Provider)
+		if previous := vpnv1alpha1.FindCondition(original.Status.Conditions, condition.Type); previous == nil || previous.Reason != condition.Reason {
+			r.Recorder.Event(server, corev1.EventTypeWarning, "RouteExportUnavailable", condition.Message)
+		}
+	case err != nil:
+		return ctrl.Result{}, err
+	}
+	vpnv1alpha1.SetCondition(&server.Status.Conditions, condition)
+	return ctrl.Result{}, r.patchStatus(ctx, server, original)
+}
+
+// exportedNetwork returns the CNI resource publishing the client network of
+// a server. It is named after the namespace and name of the server, as it
+// is cluster-scoped.
+func exportedNetwork(server *vpnv1alpha1.VPNServer, provider vpnv1alpha1.RouteExportProvider, cidr string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(routeExportKinds[provider])
+	obj.SetName("vpn-" + server.Namespace + "-" + server.Name)
+	obj.SetLabels(routeExportLabels(server))
+	switch provider {
+	case vpnv1alpha1.RouteExportProviderCalico:
+		// A disabled pool selecting no node is never allocated from, but
+		// marks the network as part of the cluster, so pools with
+		// natOutgoing do not masquerade traffic to it
+		obj.Object["spec"] = map[string]interface{}{
+			"cidr":         cidr,
+			"disabled":     true,
+			"natOutgoing":  false,
+			"ipipMode":     "Never",
+			"vxlanMode":    "Never",
+			"nodeSelector": "!all()",
+		}
+	case vpnv1alpha1.RouteExportProviderCilium:
+		obj.Object["spec"] = map[string]interface{}{
+			"externalCIDRs": []interface{}{cidr},
+		}
+	}
+	return obj
+}
+
+// routeExportLabels returns the labels of the CNI resources of a server.
+func routeExportLabels(server *vpnv1alpha1.VPNServer) map[string]string {
+	labels := serverLabels(server)
+	labels[vpnv1alpha1.ServerNamespaceLabel] = server.Namespace
+	return labels
+}
+
+// deleteExported deletes the CNI resources of a server, except those of
+// keep. Providers whose API is not installed have none.
+func (r *RouteExportReconciler) deleteExported(ctx context.Context, server *vpnv1alpha1.VPNServer, keep vpnv1alpha1.RouteExportProvider) error {
+	for provider, gvk := range routeExportKinds {
+		if provider == keep {
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		err := r.DeleteAllOf(ctx, obj, client.MatchingLabels(routeExportLabels(server)))
+		if err != nil && !meta.IsNoMatchError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchStatus patches the status of a server if it changed.
+func (r *RouteExportReconciler) patchStatus(ctx context.Context, server, original *vpnv1alpha1.VPNServer) error {
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return nil
+	}
+	return patchServerStatus(ctx, r.Client, server, original)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RouteExportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("routeexport").
+		For(&vpnv1alpha1.VPNServer{}).
+		Complete(instrument(mgr, "routeexport", &vpnv1alpha1.VPNServer{}, r))
+}
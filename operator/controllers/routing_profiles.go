@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// clientRoutes returns the networks and DNS server pushed to the client of
+// a peer, taken from its routing profile or the server defaults.
+func clientRoutes(server *vpnv1alpha1.VPNServer, profile string) ([]string, string, error) {
+	if profile == "" {
+		return splitList(server.Spec.AllowedIPs), server.Spec.DNS, nil
+	}
+
+	p := server.Spec.RoutingProfile(profile)
+	if p == nil {
+		return nil, "", fmt.Errorf("VPNServer %q has no routing profile %q", server.Name, profile)
+	}
+	dns := profileDNS(server, p)
+	if dns == "" {
+		dns = server.Spec.DNS
+	}
+	return p.AllowedIPs, dns, nil
+}
+
+// profileDNS returns the DNS server a routing profile pushes to clients:
+// the split-DNS forwarder of the server if the profile has DNS zones, its
+// own DNS otherwise.
+func profileDNS(server *vpnv1alpha1.VPNServer, p *vpnv1alpha1.ClientRoutingProfile) string {
+	if len(p.DNSZones) > 0 {
+		return splitDNSAddress(server)
+	}
+	return p.DNS
+}
+
+// splitDNSAddress returns the address the split-DNS forwarder of a server
+// listens on, its tunnel address.
+func splitDNSAddress(server *vpnv1alpha1.VPNServer) string {
+	ip, _, err := net.ParseCIDR(server.Spec.Address)
+	if err != nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// clientEndpoint returns the endpoint the client of a peer connects to: the
+// server endpoint, on the listen port the peer selected.
+func clientEndpoint(server *vpnv1alpha1.VPNServer, port int32) string {
+	return withPort(server.Status.Endpoint, port)
+}
+
+// withPort returns an endpoint on port, or as is if port is 0.
+func withPort(endpoint string, port int32) string {
+	if endpoint == "" || port == 0 {
+		return endpoint
+	}
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		host = endpoint
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,13 @@
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// applyRuntimeClass runs the server pods with the RuntimeClass of the
+// server. The webhook has checked that the data plane works under it.
+func applyRuntimeClass(server *vpnv1alpha1.VPNServer, spec *corev1.PodSpec) {
+	spec.RuntimeClassName = server.Spec.RuntimeClassName
+}
@@ -0,0 +1,442 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	// minEnrollmentTokenLength is the length of the shortest enrollment
+	// token accepted, shorter ones could be guessed
+	minEnrollmentTokenLength = 32
+
+	// selfEnrollmentWait is how long an enrollment waits for the new peer
+	// to be resolved before answering that its config is pending
+	selfEnrollmentWait = 20 * time.Second
+
+	// maxDeviceNameLength is the length of the longest device name
+	maxDeviceNameLength = 20
+)
+
+// selfEnrollmentRequest enrolls a device of a user
+type selfEnrollmentRequest struct {
+	// Name is the device name, unique among the devices of the user
+	Name string `json:"name"`
+
+	// PublicKey is the WireGuard public key of the device
+	PublicKey string `json:"publicKey"`
+
+	// Challenge is the challenge issued on /enroll/challenge
+	Challenge string `json:"challenge"`
+
+	// Proof answers the challenge with the private key of PublicKey
+	Proof string `json:"proof"`
+
+	// Client is the implementation and version of the device's client.
+	// Without it, they are taken from the User-Agent.
+	Client *clientReport `json:"client,omitempty"`
+}
+
+// selfEnrolledDevice is a device of a user, as listed and enrolled
+type selfEnrolledDevice struct {
+	Name            string       `json:"name"`
+	Peer            string       `json:"peer"`
+	PublicKey       string       `json:"publicKey"`
+	Address         string       `json:"address,omitempty"`
+	Phase           string       `json:"phase,omitempty"`
+	LatestHandshake *metav1.Time `json:"latestHandshake,omitempty"`
+	Config          string       `json:"config,omitempty"`
+}
+
+// serveSelfEnrollment serves the devices of the users of a
+// VPNSelfEnrollment, on /enroll/self/<namespace>/<name>/devices:
+//
+//	GET    devices           lists the devices of the user
+//	POST   devices           enrolls a device, returning its config
+//	GET    devices/<device>  returns the config of a device
+//	DELETE devices/<device>  revokes a device
+//
+// Users authenticate with their token as a bearer token, and only see their
+// own devices. Configs are returned without the private key, which never
+// leaves the device; enrollments prove its possession like invitees do.
+func (s *EnrollmentServer) serveSelfEnrollment(w http.ResponseWriter, req *http.Request, parts []string) {
+	if len(parts) < 3 || len(parts) > 4 || parts[2] != "devices" {
+		http.NotFound(w, req)
+		return
+	}
+	ctx := req.Context()
+	enrollment := &vpnv1alpha1.VPNSelfEnrollment{}
+	if err := s.Get(ctx, types.NamespacedName{Namespace: parts[0], Name: parts[1]}, enrollment); err != nil {
+		if !apierrors.IsNotFound(err) {
+			ctrl.Log.WithName("enrollment").Error(err, "unable to get self enrollment", "namespace", parts[0], "name", parts[1])
+		}
+		http.NotFound(w, req)
+		return
+	}
+	user := s.authenticate(ctx, req, enrollment)
+	if user == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="wireflow"`)
+		http.Error(w, "invalid enrollment token", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch {
+	case len(parts) == 3 && req.Method == http.MethodGet:
+		s.listDevices(w, req, enrollment, user)
+	case len(parts) == 3 && req.Method == http.MethodPost:
+		s.enrollDevice(w, req, enrollment, user)
+	case len(parts) == 4 && req.Method == http.MethodGet:
+		if peer := s.device(w, req, enrollment, user, parts[3]); peer != nil {
+			s.deviceConfig(w, req, peer, http.StatusOK)
+		}
+	case len(parts) == 4 && req.Method == http.MethodDelete:
+		s.revokeDevice(w, req, enrollment, user, parts[3])
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// authenticate returns the user whose token the request bears, or nil.
+// Every token is compared, so that the time taken tells nothing about which
+// user matched.
+func (s *EnrollmentServer) authenticate(ctx context.Context, req *http.Request, enrollment *vpnv1alpha1.VPNSelfEnrollment) *vpnv1alpha1.EnrollmentUser {
+	given, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || given == "" {
+		return nil
+	}
+	var matched *vpnv1alpha1.EnrollmentUser
+	for i, user := range enrollment.Spec.Users {
+		token, err := secretKey(ctx, s.Client, enrollment.Namespace, user.TokenSecretRef, defaultEnrollmentTokenKey)
+		if err != nil {
+			continue
+		}
+		token = []byte(strings.TrimSpace(string(token)))
+		if len(token) >= minEnrollmentTokenLength && subtle.ConstantTimeCompare(token, []byte(given)) == 1 {
+			matched = &enrollment.Spec.Users[i]
+		}
+	}
+	return matched
+}
+
+// devices returns the peers of a user.
+func (s *EnrollmentServer) devices(ctx context.Context, enrollment *vpnv1alpha1.VPNSelfEnrollment, user *vpnv1alpha1.EnrollmentUser) ([]vpnv1alpha1.VPNPeer, error) {
+	peers := &vpnv1alpha1.VPNPeerList{}
+	err := s.List(ctx, peers, client.InNamespace(enrollment.Namespace), client.MatchingLabels{
+		vpnv1alpha1.SelfEnrollmentLabel:     enrollment.Name,
+		vpnv1alpha1.SelfEnrollmentUserLabel: user.Name,
+	})
+	return peers.Items, err
+}
+
+// selfEnrolledPeerName returns the name of the peer of a device of a user.
+// User and device names may both hold dashes, so the pair is hashed rather
+// than joined, for the devices of different users not to share a name. The
+// device name is submitted by the user: it must be a DNS-1123 label of at
+// most maxDeviceNameLength characters, and the peer name a valid name.
+func selfEnrolledPeerName(enrollment *vpnv1alpha1.VPNSelfEnrollment, user *vpnv1alpha1.EnrollmentUser, device string) (string, error) {
+	if len(device) > maxDeviceNameLength || len(validation.IsDNS1123Label(device)) > 0 {
+		return "", fmt.Errorf("device names are 1 to %d lowercase letters, digits or dashes, starting and ending with a letter or digit", maxDeviceNameLength)
+	}
+	sum := sha256.Sum256([]byte(user.Name + "\x00" + device))
+	name := enrollment.Name + "-" + device + "-" + hex.EncodeToString(sum[:5])
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", fmt.Errorf("invalid peer name %s: %s", name, strings.Join(errs, ", "))
+	}
+	return name, nil
+}
+
+// selfEnrolledDeviceName returns the device name of a peer of a user.
+// Peers enrolled before the device label was set are named
+// <enrollment>-<user>-<device>.
+func selfEnrolledDeviceName(peer *vpnv1alpha1.VPNPeer) string {
+	if device, ok := peer.Labels[vpnv1alpha1.SelfEnrollmentDeviceLabel]; ok {
+		return device
+	}
+	return strings.TrimPrefix(peer.Name, peer.Labels[vpnv1alpha1.SelfEnrollmentLabel]+"-"+peer.Labels[vpnv1alpha1.SelfEnrollmentUserLabel]+"-")
+}
+
+// device returns the peer of a device of a user. Failures are written to
+// w, in which case the peer is nil.
+func (s *EnrollmentServer) device(w http.ResponseWriter, req *http.Request, enrollment *vpnv1alpha1.VPNSelfEnrollment, user *vpnv1alpha1.EnrollmentUser, device string) *vpnv1alpha1.VPNPeer {
+	peers, err := s.devices(req.Context(), enrollment, user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+	// Only the peers labeled with the user are looked at, a peer of
+	// another user never matches
+	for i := range peers {
+		if selfEnrolledDeviceName(&peers[i]) == device {
+			return &peers[i]
+		}
+	}
+	http.Error(w, "no device named "+device, http.StatusNotFound)
+	return nil
+}
+
+// listDevices lists the devices of a user with their last handshake.
+func (s *EnrollmentServer) listDevices(w http.ResponseWriter, req *http.Request, enrollment *vpnv1alpha1.VPNSelfEnrollment, user *vpnv1alpha1.EnrollmentUser) {
+	ctx := req.Context()
+	peers, err := s.devices(ctx, enrollment, user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	server := &vpnv1alpha1.VPNServer{}
+	if err := s.Get(ctx, types.NamespacedName{Namespace: enrollment.Namespace, Name: enrollment.Spec.ServerRef.Name}, server); client.IgnoreNotFound(err) != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	handshakes := map[string]*metav1.Time{}
+	for _, status := range server.Status.Peers {
+		handshakes[status.PublicKey] = status.LatestHandshake
+	}
+
+	devices := []selfEnrolledDevice{}
+	for _, peer := range peers {
+		devices = append(devices, selfEnrolledDevice{
+			Name:            selfEnrolledDeviceName(&peer),
+			Peer:            peer.Name,
+			PublicKey:       peer.Spec.PublicKey,
+			Address:         peer.Spec.Address,
+			Phase:           peer.Status.Phase,
+			LatestHandshake: handshakes[peer.Spec.PublicKey],
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(devices)
+}
+
+// enrollDevice creates a peer for the public key of a device once the user
+// proved its possession, and returns its config once it is resolved.
+func (s *EnrollmentServer) enrollDevice(w http.ResponseWriter, req *http.Request, enrollment *vpnv1alpha1.VPNSelfEnrollment, user *vpnv1alpha1.EnrollmentUser) {
+	logger := ctrl.Log.WithName("enrollment")
+	ctx := req.Context()
+	request := selfEnrollmentRequest{}
+	if err := json.NewDecoder(io.LimitReader(req.Body, 4096)).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := strings.Trim(deviceNameInvalid.ReplaceAllString(strings.ToLower(request.Name), "-"), "-")
+	peerName, err := selfEnrolledPeerName(enrollment, user, name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Failures are not told apart, to leak nothing about keys
+	if err := s.Verifier.Verify(request.Challenge, request.PublicKey, request.Proof); err != nil {
+		http.Error(w, "invalid proof", http.StatusForbidden)
+		return
+	}
+	list, denied, err := vpnv1alpha1.FindDeniedKey(ctx, s, request.PublicKey)
+	if err != nil {
+		logger.Error(err, "unable to check the key deny lists", "namespace", enrollment.Namespace, "enrollment", enrollment.Name)
+		http.Error(w, "unable to enroll the device", http.StatusInternalServerError)
+		return
+	}
+	if denied != nil {
+		s.Recorder.Eventf(list, corev1.EventTypeWarning, "KeyDenied", "Refused the denied public key %s submitted by user %s of VPNSelfEnrollment %s/%s from %s",
+			denied.PublicKey, user.Name, enrollment.Namespace, enrollment.Name, req.RemoteAddr)
+		http.Error(w, "the public key is denied, generate a new key pair", http.StatusForbidden)
+		return
+	}
+
+	devices, err := s.devices(ctx, enrollment, user)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	max := enrollment.Spec.MaxDevicesPerUser
+	if max == 0 {
+		max = defaultMaxDevicesPerUser
+	}
+	if int32(len(devices)) >= max {
+		http.Error(w, fmt.Sprintf("you already have %d devices, revoke one first", len(devices)), http.StatusConflict)
+		return
+	}
+	for i := range devices {
+		if selfEnrolledDeviceName(&devices[i]) == name {
+			http.Error(w, "you already have a device named "+name, http.StatusConflict)
+			return
+		}
+	}
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := s.Get(ctx, types.NamespacedName{Namespace: enrollment.Namespace, Name: enrollment.Spec.ServerRef.Name}, server); err != nil {
+		http.Error(w, "the server of the enrollment is unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	info := clientInfo(request.Client, req.UserAgent(), clientInfoSourceEnrollment)
+	if policy := server.Spec.ClientVersionPolicy; clientOutdated(policy, info) && policy.Action == vpnv1alpha1.ClientVersionActionRefuse {
+		s.Recorder.Eventf(enrollment, corev1.EventTypeWarning, "ClientRefused", "Refused the enrollment of client %s %s by user %s, below the minimum version %s",
+			info.Implementation, info.Version, user.Name, policy.MinimumVersion)
+		http.Error(w, "the client must be upgraded to version "+policy.MinimumVersion+" or later", http.StatusUpgradeRequired)
+		return
+	}
+
+	now := metav1.Now()
+	peer := &vpnv1alpha1.VPNPeer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      peerName,
+			Namespace: enrollment.Namespace,
+			Labels: map[string]string{
+				vpnv1alpha1.SelfEnrollmentLabel:       enrollment.Name,
+				vpnv1alpha1.SelfEnrollmentUserLabel:   user.Name,
+				vpnv1alpha1.SelfEnrollmentDeviceLabel: name,
+			},
+		},
+		Spec: vpnv1alpha1.VPNPeerSpec{
+			ServerRef: vpnv1alpha1.LocalObjectReference{Name: server.Name},
+			PublicKey: request.PublicKey,
+			Group:     enrollment.Spec.Group,
+			Identity: &vpnv1alpha1.PeerIdentity{
+				Issuer:   "self-enrollment:" + enrollment.Namespace + "/" + enrollment.Name,
+				Subject:  user.Name,
+				Claims:   user.Claims,
+				SyncTime: &now,
+			},
+		},
+	}
+	address, err := allocateAddress(ctx, s.Client, peer, server)
+	if err != nil {
+		logger.Error(err, "unable to allocate an address", "namespace", enrollment.Namespace, "enrollment", enrollment.Name)
+		http.Error(w, "unable to enroll the device", http.StatusInternalServerError)
+		return
+	}
+	if address == "" {
+		http.Error(w, "the VPN has no free address left, ask an administrator", http.StatusServiceUnavailable)
+		return
+	}
+	peer.Spec.Address = address
+	if err := s.Create(ctx, peer); err != nil {
+		// The devices of the user were checked above, a peer of the same
+		// name belongs to someone else and is left alone
+		if apierrors.IsAlreadyExists(err) {
+			http.Error(w, "the device name "+name+" is taken, choose another", http.StatusConflict)
+			return
+		}
+		if err := releaseAddress(ctx, s.Client, peer, address); err != nil {
+			logger.Error(err, "unable to release the address of the peer", "address", address)
+		}
+		if apierrors.IsInvalid(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Error(err, "unable to create peer", "namespace", enrollment.Namespace, "enrollment", enrollment.Name, "user", user.Name)
+		http.Error(w, "unable to enroll the device", http.StatusInternalServerError)
+		return
+	}
+	logger.Info("enrolled device", "namespace", peer.Namespace, "peer", peer.Name, "user", user.Name)
+	s.Recorder.Eventf(peer, corev1.EventTypeNormal, "DeviceEnrolled", "User %s enrolled the public key %s through VPNSelfEnrollment %s",
+		user.Name, peer.Spec.PublicKey, enrollment.Name)
+	if info != nil {
+		original := peer.DeepCopy()
+		peer.Status.Client = info
+		if err := s.Status().Patch(ctx, peer, client.MergeFrom(original)); err != nil {
+			logger.Error(err, "unable to record the client version", "namespace", peer.Namespace, "peer", peer.Name)
+		}
+	}
+
+	// The config is usually ready within seconds, clients fetch it later
+	// from the device path otherwise
+	deadline := time.Now().Add(selfEnrollmentWait)
+	for resolvedServer(ctx, s, peer) == nil && time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+	s.deviceConfig(w, req, peer, http.StatusCreated)
+}
+
+// resolvedServer returns the server of a peer once both are resolved far
+// enough to render its client config, or nil. The peer is refreshed.
+func resolvedServer(ctx context.Context, c client.Reader, peer *vpnv1alpha1.VPNPeer) *vpnv1alpha1.VPNServer {
+	if err := c.Get(ctx, client.ObjectKeyFromObject(peer), peer); err != nil {
+		return nil
+	}
+	server := &vpnv1alpha1.VPNServer{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: peer.Namespace, Name: peer.Spec.ServerRef.Name}, server); err != nil {
+		return nil
+	}
+	if peer.Status.Phase != vpnv1alpha1.PeerPhaseActive || peer.Status.ClientEndpoint == "" || server.Status.PublicKey == "" {
+		return nil
+	}
+	return server
+}
+
+// deviceConfig writes a device with its client config, without the private
+// key, or with 202 Accepted and no config while the peer is not resolved.
+func (s *EnrollmentServer) deviceConfig(w http.ResponseWriter, req *http.Request, peer *vpnv1alpha1.VPNPeer, status int) {
+	ctx := req.Context()
+	device := selfEnrolledDevice{
+		Name:      selfEnrolledDeviceName(peer),
+		Peer:      peer.Name,
+		PublicKey: peer.Spec.PublicKey,
+		Address:   peer.Spec.Address,
+	}
+	server := resolvedServer(ctx, s, peer)
+	device.Phase = peer.Status.Phase
+	if server == nil {
+		status = http.StatusAccepted
+	} else {
+		config, err := s.clientConfig(ctx, peer, server)
+		if err != nil {
+			http.Error(w, "the preshared key of the device is unavailable", http.StatusInternalServerError)
+			return
+		}
+		device.Config = config
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(device)
+}
+
+// revokeDevice deletes the peer of a device of a user.
+func (s *EnrollmentServer) revokeDevice(w http.ResponseWriter, req *http.Request, enrollment *vpnv1alpha1.VPNSelfEnrollment, user *vpnv1alpha1.EnrollmentUser, device string) {
+	peer := s.device(w, req, enrollment, user, device)
+	if peer == nil {
+		return
+	}
+	if err := s.Delete(req.Context(), peer); client.IgnoreNotFound(err) != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ctrl.Log.WithName("enrollment").Info("revoked device", "namespace", peer.Namespace, "peer", peer.Name, "user", user.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clientConfig returns the client config of a peer without the private key,
+// branded with the VPNBranding of its server if it can be.
+func (s *EnrollmentServer) clientConfig(ctx context.Context, peer *vpnv1alpha1.VPNPeer, server *vpnv1alpha1.VPNServer) (string, error) {
+	psk, err := peerPresharedKey(ctx, s, peer)
+	if err != nil {
+		return "", err
+	}
+	config, err := brandedClientConfig(ctx, s, "", psk, peer, server)
+	if err != nil {
+		ctrl.Log.WithName("enrollment").Error(err, "unable to brand the client config", "namespace", peer.Namespace, "peer", peer.Name)
+		config = renderClientConfig("", psk, peer, server)
+	}
+	return config, nil
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+func TestSelfEnrolledPeerNameKeepsUsersApart(t *testing.T) {
+	enrollment := &vpnv1alpha1.VPNSelfEnrollment{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "staff"}}
+	first, err := selfEnrolledPeerName(enrollment, &vpnv1alpha1.EnrollmentUser{Name: "a"}, "b-c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := selfEnrolledPeerName(enrollment, &vpnv1alpha1.EnrollmentUser{Name: "a-b"}, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("the devices of different users share the peer name %s", first)
+	}
+}
+
+func TestSelfEnrolledPeerNameValidatesDeviceName(t *testing.T) {
+	user := &vpnv1alpha1.EnrollmentUser{Name: "alice"}
+	for _, tc := range []struct {
+		name       string
+		enrollment string
+		device     string
+		valid      bool
+	}{
+		{name: "label", enrollment: "staff", device: "laptop-2", valid: true},
+		{name: "longest", enrollment: "staff", device: strings.Repeat("a", maxDeviceNameLength), valid: true},
+		{name: "empty", enrollment: "staff", device: ""},
+		{name: "too long", enrollment: "staff", device: strings.Repeat("a", maxDeviceNameLength+1)},
+		{name: "uppercase", enrollment: "staff", device: "Laptop"},
+		{name: "leading dash", enrollment: "staff", device: "-laptop"},
+		{name: "trailing dash", enrollment: "staff", device: "laptop-"},
+		{name: "dot", enrollment: "staff", device: "my.laptop"},
+		{name: "slash", enrollment: "staff", device: "../laptop"},
+		{name: "peer name too long", enrollment: strings.Repeat("s", 240), device: "laptop"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			enrollment := &vpnv1alpha1.VPNSelfEnrollment{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: tc.enrollment}}
+			name, err := selfEnrolledPeerName(enrollment, user, tc.device)
+			if tc.valid && err != nil {
+				t.Fatalf("device %q rejected: %v", tc.device, err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatalf("device %q accepted as peer %s", tc.device, name)
+			}
+		})
+	}
+}
+
+func TestSelfEnrollmentRejectsInvalidDeviceName(t *testing.T) {
+	enrollment := &vpnv1alpha1.VPNSelfEnrollment{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "staff"}}
+	c, _ := newTestClient(t)
+	s := &EnrollmentServer{Client: c}
+
+	for _, device := range []string{"", "!!!", "a-device-name-longer-than-allowed"} {
+		body := strings.NewReader(`{"name": "` + device + `", "publicKey": "` + testKeyA + `"}`)
+		req := httptest.NewRequest(http.MethodPost, "/enroll/self/"+testNamespace+"/staff/devices", body)
+		w := httptest.NewRecorder()
+		s.enrollDevice(w, req, enrollment, &vpnv1alpha1.EnrollmentUser{Name: "alice"})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("device %q: expected 400, got %d %s", device, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestSelfEnrollmentDeviceIgnoresOtherUsers(t *testing.T) {
+	enrollment := &vpnv1alpha1.VPNSelfEnrollment{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "staff"}}
+	// Enrolled under the former naming by user a-b as device c
+	legacy := testPeer("staff-a-b-c", "edge", testKeyA, "10.8.0.2")
+	legacy.Labels = map[string]string{
+		vpnv1alpha1.SelfEnrollmentLabel:     "staff",
+		vpnv1alpha1.SelfEnrollmentUserLabel: "a-b",
+	}
+	c, _ := newTestClient(t, legacy)
+	s := &EnrollmentServer{Client: c}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	if peer := s.device(w, req, enrollment, &vpnv1alpha1.EnrollmentUser{Name: "a"}, "b-c"); peer != nil {
+		t.Fatalf("user a got the device %s of user a-b", peer.Name)
+	}
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for the device of another user, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	peer := s.device(w, req, enrollment, &vpnv1alpha1.EnrollmentUser{Name: "a-b"}, "c")
+	if peer == nil || peer.Name != legacy.Name {
+		t.Fatalf("user a-b did not get their device: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const defaultEnrollmentTokenKey = "token"
+
+// VPNSelfEnrollmentReconciler checks the references of self enrollments and
+// reports the path their devices are enrolled on by the EnrollmentServer.
+type VPNSelfEnrollmentReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnselfenrollments,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnselfenrollments/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// Reconcile sets the Ready condition of a self enrollment.
+func (r *VPNSelfEnrollmentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	enrollment := &vpnv1alpha1.VPNSelfEnrollment{}
+	if err := r.Get(ctx, req.NamespacedName, enrollment); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	enrollment.Status.ObservedGeneration = enrollment.Generation
+	enrollment.Status.Path = selfEnrollmentPath(enrollment)
+
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionReady,
+		Status:             vpnv1alpha1.ConditionTrue,
+		Reason:             "Configured",
+		ObservedGeneration: enrollment.Generation,
+	}
+	reason, err := r.check(ctx, enrollment)
+	if err != nil {
+		condition.Status = vpnv1alpha1.ConditionFalse
+		condition.Reason = reason
+		condition.Message = err.Error()
+	}
+	vpnv1alpha1.SetCondition(&enrollment.Status.Conditions, condition)
+	if err := r.Status().Update(ctx, enrollment); err != nil {
+		return ctrl.Result{}, err
+	}
+	// Secrets and servers are not watched, missing ones are checked again
+	if condition.Status == vpnv1alpha1.ConditionFalse {
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// check returns the reason and error of the first reference of the self
+// enrollment that cannot be resolved.
+func (r *VPNSelfEnrollmentReconciler) check(ctx context.Context, enrollment *vpnv1alpha1.VPNSelfEnrollment) (string, error) {
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: enrollment.Namespace, Name: enrollment.Spec.ServerRef.Name}, server); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "MissingRef", fmt.Errorf("VPNServer %q does not exist", enrollment.Spec.ServerRef.Name)
+		}
+		return "MissingRef", err
+	}
+	for _, user := range enrollment.Spec.Users {
+		token, err := secretKey(ctx, r.Client, enrollment.Namespace, user.TokenSecretRef, defaultEnrollmentTokenKey)
+		if err != nil {
+			return "MissingSecret", fmt.Errorf("token of user %s: %w", user.Name, err)
+		}
+		if len(token) < minEnrollmentTokenLength {
+			return "WeakToken", fmt.Errorf("the token of user %s is shorter than %d bytes", user.Name, minEnrollmentTokenLength)
+		}
+	}
+	return "", nil
+}
+
+// selfEnrollmentPath returns the path the devices of a self enrollment are
+// enrolled on.
+func selfEnrollmentPath(enrollment *vpnv1alpha1.VPNSelfEnrollment) string {
+	return fmt.Sprintf("/enroll/self/%s/%s/devices", enrollment.Namespace, enrollment.Name)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VPNSelfEnrollmentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vpnv1alpha1.VPNSelfEnrollment{}).
+		Complete(instrument(mgr, "vpnselfenrollment", &vpnv1alpha1.VPNSelfEnrollment{}, r))
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// patchServerStatus writes the changes a controller made to the status of
+// server since original. Many controllers write parts of the status of a
+// server, and merge patches replace lists such as the conditions whole, so
+// the patch is optimistically locked: a patch computed from a stale server
+// fails instead of dropping what other controllers wrote since. The changes
+// are then re-applied to the current status, condition by condition, and
+// written again.
+func patchServerStatus(ctx context.Context, c client.Client, server, original *vpnv1alpha1.VPNServer) error {
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return nil
+	}
+	apply, err := serverStatusChanges(&original.Status, &server.Status)
+	if err != nil {
+		return err
+	}
+
+	current, base := server, original
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if current != server {
+			current = &vpnv1alpha1.VPNServer{}
+			if err := c.Get(ctx, client.ObjectKeyFromObject(server), current); err != nil {
+				return err
+			}
+			base = current.DeepCopy()
+			if err := apply(&current.Status); err != nil {
+				return err
+			}
+			if equality.Semantic.DeepEqual(base.Status, current.Status) {
+				current.DeepCopyInto(server)
+				return nil
+			}
+		}
+		err := c.Status().Patch(ctx, current, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{}))
+		switch {
+		case apierrors.IsConflict(err) && current == server:
+			// Re-read on the next attempt
+			current = server.DeepCopy()
+		case err == nil && current != server:
+			current.DeepCopyInto(server)
+		}
+		return err
+	})
+}
+
+// serverStatusChanges returns a function applying the changes from original
+// to status to another status: the conditions set or removed, and a merge
+// patch of the other fields.
+func serverStatusChanges(original, status *vpnv1alpha1.VPNServerStatus) (func(*vpnv1alpha1.VPNServerStatus) error, error) {
+	var set []vpnv1alpha1.Condition
+	for _, condition := range status.Conditions {
+		if previous := vpnv1alpha1.FindCondition(original.Conditions, condition.Type); previous == nil || !equality.Semantic.DeepEqual(*previous, condition) {
+			set = append(set, condition)
+		}
+	}
+	var removed []string
+	for _, condition := range original.Conditions {
+		if vpnv1alpha1.FindCondition(status.Conditions, condition.Type) == nil {
+			removed = append(removed, condition.Type)
+		}
+	}
+	from, err := statusWithoutConditions(original)
+	if err != nil {
+		return nil, err
+	}
+	to, err := statusWithoutConditions(status)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := jsonpatch.CreateMergePatch(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(current *vpnv1alpha1.VPNServerStatus) error {
+		raw, err := statusWithoutConditions(current)
+		if err != nil {
+			return err
+		}
+		if raw, err = jsonpatch.MergePatch(raw, fields); err != nil {
+			return err
+		}
+		conditions := current.Conditions
+		*current = vpnv1alpha1.VPNServerStatus{}
+		if err := json.Unmarshal(raw, current); err != nil {
+			return err
+		}
+		current.Conditions = conditions
+		for _, condition := range set {
+			if existing := vpnv1alpha1.FindCondition(current.Conditions, condition.Type); existing != nil {
+				*existing = condition
+			} else {
+				current.Conditions = append(current.Conditions, condition)
+			}
+		}
+		for _, conditionType := range removed {
+			vpnv1alpha1.RemoveCondition(&current.Conditions, conditionType)
+		}
+		return nil
+	}, nil
+}
+
+// statusWithoutConditions returns the JSON of a status without its
+// conditions.
+func statusWithoutConditions(status *vpnv1alpha1.VPNServerStatus) ([]byte, error) {
+	withoutConditions := *status
+	withoutConditions.Conditions = nil
+	return json.Marshal(withoutConditions)
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+)
+
+const (
+	// defaultServerPrivateKeyKey is the key of the server private key in the
+	// Secret referenced by spec.privateKeySecretRef, the file the agent
+	// reads from the keys volume
+	defaultServerPrivateKeyKey = "server_private"
+
+	// serverPublicKeyKey is the key of the public key in generated Secrets
+	serverPublicKeyKey = "server_public"
+)
+
+// ServerKeyReconciler manages the key pair of servers with a
+// privateKeySecretRef or a Vault keyStore: it generates the key pair into
+// the store when it holds none, and publishes the public key in the status
+// of the server, rotating it if the server has a keyRotation. Server pods
+// get the key through the keys volume, or fetch it from Vault.
+// Generated Secrets are owned by the server, so they follow its deletion
+// policy.
+type ServerKeyReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// VaultRole is the role of the Kubernetes auth method of Vault the
+	// operator logs in with to manage the keys of Vault key stores
+	VaultRole string
+
+	vault vaultClients
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile generates the private key of a server if needed, rotates it on
+// schedule and publishes its public key.
+func (r *ServerKeyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	store := r.keyStoreFor(server)
+	if store == nil || !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	dataKey := defaultServerPrivateKeyKey
+	if ref := server.Spec.PrivateKeySecretRef; ref != nil && ref.Key != "" {
+		dataKey = ref.Key
+	}
+
+	keys, created, err := store.load(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if keys == nil {
+		key, err := escrow.GenerateKey()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		keys = map[string][]byte{
+			dataKey:            []byte(key.String()),
+			serverPublicKeyKey: []byte(key.PublicKey().String()),
+		}
+		if err := store.save(ctx, keys); err != nil {
+			return ctrl.Result{}, err
+		}
+		created = time.Now()
+		logger.Info("generated server key pair", "store", store.String())
+		r.Recorder.Event(server, corev1.EventTypeNormal, "KeyGenerated",
+			fmt.Sprintf("Generated the server key pair into %s", store))
+	}
+
+	key, err := escrow.ParseKey(string(keys[dataKey]))
+	if err != nil {
+		// A key stored by the user is theirs to fix
+		r.Recorder.Event(server, corev1.EventTypeWarning, "InvalidPrivateKey",
+			fmt.Sprintf("%s has no valid private key under %s: %v", store, dataKey, err))
+		return ctrl.Result{}, nil
+	}
+
+	original := server.DeepCopy()
+	var result ctrl.Result
+	if server.Spec.KeyRotation != nil {
+		key, result.RequeueAfter, err = r.rotateKey(ctx, server, store, keys, created, dataKey, key, time.Now())
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	} else {
+		server.Status.KeyRotation = nil
+		vpnv1alpha1.RemoveCondition(&server.Status.Conditions, vpnv1alpha1.ConditionKeyRotationPending)
+	}
+	server.Status.PublicKey = resolveOverride(server, vpnv1alpha1.ConditionPublicKeyOverridden,
+		"spec.publicKeyOverride", server.Spec.PublicKeyOverride, key.PublicKey().String())
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return result, nil
+	}
+	return result, patchServerStatus(ctx, r.Client, server, original)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ServerKeyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("serverkey").
+		For(&vpnv1alpha1.VPNServer{}).
+		Owns(&corev1.Secret{}).
+		Complete(instrument(mgr, "serverkey", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,344 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// wireguardPortName is the name of the port of the server in its Service
+const wireguardPortName = "wireguard"
+
+// Annotations of Services read by external-dns
+const (
+	externalDNSHostnameAnnotation = "external-dns.alpha.kubernetes.io/hostname"
+	externalDNSTTLAnnotation      = "external-dns.alpha.kubernetes.io/ttl"
+)
+
+// ServerServiceReconciler exposes the replicas of servers with a Service of
+// the type of spec.service, and discovers the endpoint of the server from
+// it: the address of its load balancer, or the external address of a node
+// of a ready replica with the node port. With spec.endpointDNS, external-dns
+// publishes the address under a stable name, which becomes the endpoint.
+type ServerServiceReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// Resolver checks that endpoints published as host names resolve. They
+	// are not checked if nil.
+	Resolver *EndpointResolver
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups=externaldns.k8s.io,resources=dnsendpoints,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile applies the Service of a server and sets its endpoint.
+func (r *ServerServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	spec := vpnv1alpha1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer}
+	if server.Spec.Service != nil {
+		spec = *server.Spec.Service
+		if spec.Type == "" {
+			spec.Type = corev1.ServiceTypeLoadBalancer
+		}
+	}
+
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: server.Namespace, Name: server.Name}}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, svc, func() error {
+		if svc.Labels == nil {
+			svc.Labels = map[string]string{}
+		}
+		for key, value := range serverLabels(server) {
+			svc.Labels[key] = value
+		}
+		delete(svc.Annotations, externalDNSHostnameAnnotation)
+		delete(svc.Annotations, externalDNSTTLAnnotation)
+		propagateMetadata(server, svc)
+		svc.Annotations = mergeMetadata(svc.Annotations, spec.Annotations)
+		if dns := server.Spec.EndpointDNS; dns != nil && dns.Mode != vpnv1alpha1.EndpointDNSModeDNSEndpoint {
+			annotations := map[string]string{externalDNSHostnameAnnotation: dns.Hostname}
+			if dns.TTL != nil {
+				annotations[externalDNSTTLAnnotation] = strconv.FormatInt(*dns.TTL, 10)
+			}
+			svc.Annotations = mergeMetadata(svc.Annotations, annotations)
+		}
+		svc.Spec.Type = spec.Type
+		svc.Spec.Selector = serverLabels(server)
+		if server.Spec.HighAvailability != nil {
+			// Clients reach the active replica on the same endpoint after
+			// a failover
+			svc.Spec.Selector[vpnv1alpha1.HARoleLabel] = vpnv1alpha1.HARoleActive
+		}
+		svc.Spec.Ports = serverServicePorts(server, svc.Spec.Ports)
+		svc.Spec.ExternalTrafficPolicy = ""
+		if spec.Type != corev1.ServiceTypeClusterIP {
+			svc.Spec.ExternalTrafficPolicy = spec.ExternalTrafficPolicy
+			if svc.Spec.ExternalTrafficPolicy == "" {
+				svc.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+			}
+		}
+		return controllerutil.SetControllerReference(server, svc, r.Scheme)
+	})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if result != controllerutil.OperationResultNone {
+		logger.Info("applied service", "type", spec.Type, "operation", result)
+	}
+
+	endpoint, condition, err := r.discoverEndpoint(ctx, server, svc)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.applyDNSEndpoint(ctx, server, endpoint); err != nil {
+		return ctrl.Result{}, err
+	}
+	if dns := server.Spec.EndpointDNS; dns != nil && endpoint != "" {
+		_, port, _ := net.SplitHostPort(endpoint)
+		endpoint = net.JoinHostPort(dns.Hostname, port)
+		condition.Message += ", published as " + dns.Hostname
+	}
+	condition.Type = vpnv1alpha1.ConditionEndpointDiscovered
+	condition.ObservedGeneration = server.Generation
+
+	original := server.DeepCopy()
+	vpnv1alpha1.SetCondition(&server.Status.Conditions, condition)
+	server.Status.Endpoint = resolveOverride(server, vpnv1alpha1.ConditionEndpointOverridden,
+		"spec.endpointOverride", server.Spec.EndpointOverride, endpoint)
+	retry := r.checkResolution(ctx, server)
+	if vpnv1alpha1.IsConditionTrue(server.Status.Conditions, vpnv1alpha1.ConditionEndpointResolutionDegraded) &&
+		!vpnv1alpha1.IsConditionTrue(original.Status.Conditions, vpnv1alpha1.ConditionEndpointResolutionDegraded) {
+		r.Recorder.Event(server, corev1.EventTypeWarning, "EndpointResolutionFailed",
+			vpnv1alpha1.FindCondition(server.Status.Conditions, vpnv1alpha1.ConditionEndpointResolutionDegraded).Message)
+	}
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return ctrl.Result{RequeueAfter: retry}, nil
+	}
+	if endpoint != "" && server.Spec.EndpointOverride == "" && original.Status.Endpoint != endpoint {
+		r.Recorder.Eventf(server, corev1.EventTypeNormal, "EndpointDiscovered", "Clients connect to %s", endpoint)
+	}
+	return ctrl.Result{RequeueAfter: retry}, patchServerStatus(ctx, r.Client, server, original)
+}
+
+// checkResolution sets the EndpointResolutionDegraded condition of a server
+// whose endpoint is a host name from whether it resolves, and returns when
+// to look it up again if it does not. Clients fail to connect to names that
+// do not resolve, e.g. before external-dns published them.
+func (r *ServerServiceReconciler) checkResolution(ctx context.Context, server *vpnv1alpha1.VPNServer) time.Duration {
+	host, _, err := net.SplitHostPort(server.Status.Endpoint)
+	if err != nil {
+		host = server.Status.Endpoint
+	}
+	if r.Resolver == nil || host == "" || net.ParseIP(host) != nil {
+		vpnv1alpha1.RemoveCondition(&server.Status.Conditions, vpnv1alpha1.ConditionEndpointResolutionDegraded)
+		return 0
+	}
+	var ttl time.Duration
+	if dns := server.Spec.EndpointDNS; dns != nil && dns.Hostname == host && dns.TTL != nil {
+		ttl = time.Duration(*dns.TTL) * time.Second
+	}
+
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionEndpointResolutionDegraded,
+		Status:             vpnv1alpha1.ConditionFalse,
+		Reason:             "Resolved",
+		ObservedGeneration: server.Generation,
+	}
+	addrs, err := r.Resolver.Resolve(ctx, host, ttl)
+	if err != nil {
+		condition.Status = vpnv1alpha1.ConditionTrue
+		condition.Reason = "ResolutionFailed"
+		condition.Message = fmt.Sprintf("%s does not resolve: %v", host, err)
+	} else {
+		condition.Message = fmt.Sprintf("%s resolves to %s", host, strings.Join(addrs, ", "))
+	}
+	vpnv1alpha1.SetCondition(&server.Status.Conditions, condition)
+	return r.Resolver.RetryAfter(host)
+}
+
+// applyDNSEndpoint writes the DNSEndpoint of a server publishing the host
+// of its discovered endpoint, or deletes it when spec.endpointDNS does not
+// ask for one. Nothing is written before an endpoint is discovered, so
+// that external-dns keeps the last records meanwhile.
+func (r *ServerServiceReconciler) applyDNSEndpoint(ctx context.Context, server *vpnv1alpha1.VPNServer, endpoint string) error {
+	record := &unstructured.Unstructured{}
+	record.SetAPIVersion("externaldns.k8s.io/v1alpha1")
+	record.SetKind("DNSEndpoint")
+	record.SetNamespace(server.Namespace)
+	record.SetName(server.Name)
+
+	dns := server.Spec.EndpointDNS
+	if dns == nil || dns.Mode != vpnv1alpha1.EndpointDNSModeDNSEndpoint {
+		err := r.Delete(ctx, record)
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		return client.IgnoreNotFound(err)
+	}
+	if endpoint == "" {
+		return nil
+	}
+
+	host, _, _ := net.SplitHostPort(endpoint)
+	recordType := "CNAME"
+	if ip := net.ParseIP(host); ip != nil {
+		recordType = "A"
+		if ip.To4() == nil {
+			recordType = "AAAA"
+		}
+	}
+	record.SetLabels(serverLabels(server))
+	if err := ctrl.SetControllerReference(server, record, r.Scheme); err != nil {
+		return err
+	}
+	entry := map[string]interface{}{
+		"dnsName":    dns.Hostname,
+		"recordType": recordType,
+		"targets":    []interface{}{host},
+	}
+	if dns.TTL != nil {
+		entry["recordTTL"] = *dns.TTL
+	}
+	record.Object["spec"] = map[string]interface{}{
+		"endpoints": []interface{}{entry},
+	}
+
+	err := r.Patch(ctx, record, client.Apply, client.FieldOwner("vpn-operator"), client.ForceOwnership)
+	if meta.IsNoMatchError(err) {
+		r.Recorder.Event(server, corev1.EventTypeWarning, "DNSEndpointUnavailable",
+			"spec.endpointDNS.mode is DNSEndpoint but the DNSEndpoint API of external-dns is not installed")
+		return nil
+	}
+	return err
+}
+
+// serverServicePorts returns the ports of servicePorts with the node ports
+// of current, so that updates do not reallocate them. They are matched by
+// port number, which survives the renaming of the ports.
+func serverServicePorts(server *vpnv1alpha1.VPNServer, current []corev1.ServicePort) []corev1.ServicePort {
+	nodePorts := map[int32]int32{}
+	for _, port := range current {
+		nodePorts[port.Port] = port.NodePort
+	}
+	ports := servicePorts(server)
+	if server.Spec.Service != nil && server.Spec.Service.Type == corev1.ServiceTypeClusterIP {
+		return ports
+	}
+	for i := range ports {
+		ports[i].NodePort = nodePorts[ports[i].Port]
+	}
+	return ports
+}
+
+// discoverEndpoint returns the endpoint clients reach a server on through
+// its Service, and the condition telling how it was discovered.
+func (r *ServerServiceReconciler) discoverEndpoint(ctx context.Context, server *vpnv1alpha1.VPNServer, svc *corev1.Service) (string, vpnv1alpha1.Condition, error) {
+	switch svc.Spec.Type {
+	case corev1.ServiceTypeLoadBalancer:
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			host := ingress.IP
+			if host == "" {
+				host = ingress.Hostname
+			}
+			if host != "" {
+				return withPort(host, server.Spec.Port), vpnv1alpha1.Condition{
+					Status:  vpnv1alpha1.ConditionTrue,
+					Reason:  "LoadBalancer",
+					Message: "discovered from the load balancer of Service " + svc.Name,
+				}, nil
+			}
+		}
+		return "", vpnv1alpha1.Condition{
+			Status:  vpnv1alpha1.ConditionFalse,
+			Reason:  "LoadBalancerPending",
+			Message: fmt.Sprintf("Service %s has no load balancer address yet", svc.Name),
+		}, nil
+
+	case corev1.ServiceTypeNodePort:
+		var nodePort int32
+		for _, port := range svc.Spec.Ports {
+			if port.Name == wireguardPortName {
+				nodePort = port.NodePort
+			}
+		}
+		pods := &corev1.PodList{}
+		if err := r.List(ctx, pods, client.InNamespace(server.Namespace), client.MatchingLabels(serverLabels(server))); err != nil {
+			return "", vpnv1alpha1.Condition{}, err
+		}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if nodePort == 0 || !isPodReady(pod) || pod.Spec.NodeName == "" || isHAStandby(server, pod) {
+				continue
+			}
+			node := &corev1.Node{}
+			if err := r.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, node); err != nil {
+				if client.IgnoreNotFound(err) != nil {
+					return "", vpnv1alpha1.Condition{}, err
+				}
+				continue
+			}
+			if address := nodeEndpointAddress(node); address != "" {
+				return withPort(address, nodePort), vpnv1alpha1.Condition{
+					Status:  vpnv1alpha1.ConditionTrue,
+					Reason:  "NodePort",
+					Message: fmt.Sprintf("discovered from node %s of a ready replica and the node port of Service %s", node.Name, svc.Name),
+				}, nil
+			}
+		}
+		return "", vpnv1alpha1.Condition{
+			Status:  vpnv1alpha1.ConditionFalse,
+			Reason:  "NoReadyReplica",
+			Message: fmt.Sprintf("no ready replica on a node with an address to reach node port Service %s on", svc.Name),
+		}, nil
+
+	default:
+		return "", vpnv1alpha1.Condition{
+			Status:  vpnv1alpha1.ConditionFalse,
+			Reason:  "ClusterIP",
+			Message: fmt.Sprintf("Service %s is not reachable from outside the cluster, set spec.endpointOverride", svc.Name),
+		}, nil
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ServerServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("serverservice").
+		For(&vpnv1alpha1.VPNServer{}).
+		Owns(&corev1.Service{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(serverForPod)).
+		Complete(instrument(mgr, "serverservice", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+func TestServerServicePortsKeepNodePorts(t *testing.T) {
+	server := testServer("edge")
+	server.Spec.AdditionalListenPorts = []int32{443}
+	current := []corev1.ServicePort{
+		{Name: "wireguard", Port: 51820, NodePort: 31820},
+		{Name: "listen-443", Port: 443, NodePort: 30443},
+	}
+
+	ports := serverServicePorts(server, current)
+	want := servicePorts(server)
+	if len(ports) != len(want) {
+		t.Fatalf("ports = %+v, want those of servicePorts", ports)
+	}
+	for i, port := range ports {
+		if port.Name != want[i].Name || port.Port != want[i].Port || port.Protocol != corev1.ProtocolUDP {
+			t.Errorf("port %d = %+v, want %+v", i, port, want[i])
+		}
+	}
+	if ports[0].NodePort != 31820 || ports[1].NodePort != 30443 {
+		t.Errorf("node ports = %d, %d, want 31820, 30443 kept", ports[0].NodePort, ports[1].NodePort)
+	}
+
+	server.Spec.Service = &vpnv1alpha1.ServiceSpec{Type: corev1.ServiceTypeClusterIP}
+	for _, port := range serverServicePorts(server, current) {
+		if port.NodePort != 0 {
+			t.Errorf("port %s of a ClusterIP Service has node port %d", port.Name, port.NodePort)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// ServiceMonitorReconciler generates the ServiceMonitors of servers with
+// monitoring enabled. Per-peer metrics are served by the operator, so the
+// ServiceMonitor of a server scrapes the metrics Service of the operator
+// and keeps the series of the server only.
+type ServiceMonitorReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// Namespace is the namespace of the operator's metrics Service
+	Namespace string
+
+	// ServiceSelector selects the operator's metrics Service
+	ServiceSelector map[string]string
+
+	// PortName is the name of the metrics port of the Service
+	PortName string
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile applies the ServiceMonitor of a server, or deletes it once
+// monitoring is disabled.
+func (r *ServiceMonitorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	monitor := &unstructured.Unstructured{}
+	monitor.SetAPIVersion("monitoring.coreos.com/v1")
+	monitor.SetKind("ServiceMonitor")
+	monitor.SetNamespace(server.Namespace)
+	monitor.SetName(server.Name + "-peers")
+
+	if !monitoringEnabled(server) || !server.DeletionTimestamp.IsZero() {
+		// Deleted servers take their ServiceMonitor along through its
+		// owner reference
+		err := r.Delete(ctx, monitor)
+		if meta.IsNoMatchError(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	labels := map[string]string{}
+	for key, value := range server.Spec.Monitoring.Labels {
+		labels[key] = value
+	}
+	for key, value := range serverLabels(server) {
+		labels[key] = value
+	}
+	monitor.SetLabels(labels)
+	if err := ctrl.SetControllerReference(server, monitor, r.Scheme); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	selector := map[string]interface{}{}
+	for key, value := range r.ServiceSelector {
+		selector[key] = value
+	}
+	interval := "30s"
+	if server.Spec.Monitoring.Interval != nil {
+		interval = server.Spec.Monitoring.Interval.Duration.String()
+	}
+	monitor.Object["spec"] = map[string]interface{}{
+		"namespaceSelector": map[string]interface{}{
+			"matchNames": []interface{}{r.Namespace},
+		},
+		"selector": map[string]interface{}{
+			"matchLabels": selector,
+		},
+		"endpoints": []interface{}{
+			map[string]interface{}{
+				"port":     r.PortName,
+				"path":     "/metrics",
+				"interval": interval,
+				// The namespace label of the series is the server's,
+				// not the operator's
+				"honorLabels": true,
+				"metricRelabelings": []interface{}{
+					map[string]interface{}{
+						"sourceLabels": []interface{}{"__name__"},
+						"regex":        "wireflow_peer_.*",
+						"action":       "keep",
+					},
+					map[string]interface{}{
+						"sourceLabels": []interface{}{"namespace", "server"},
+						"regex":        regexp.QuoteMeta(server.Namespace) + ";" + regexp.QuoteMeta(server.Name),
+						"action":       "keep",
+					},
+				},
+			},
+		},
+	}
+
+	err := r.Patch(ctx, monitor, client.Apply, client.FieldOwner("vpn-operator"), client.ForceOwnership)
+	if meta.IsNoMatchError(err) {
+		logger.Info("the Prometheus Operator is not installed, not generating a ServiceMonitor")
+		r.Recorder.Event(server, corev1.EventTypeWarning, "ServiceMonitorUnavailable",
+			fmt.Sprintf("spec.monitoring is enabled but the ServiceMonitor API is not installed; the peer metrics are still served by the operator in namespace %s", r.Namespace))
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{}, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ServiceMonitorReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("servicemonitor").
+		For(&vpnv1alpha1.VPNServer{}).
+		Complete(instrument(mgr, "servicemonitor", &vpnv1alpha1.VPNServer{}, r))
+}
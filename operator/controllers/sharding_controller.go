@@ -0,0 +1,341 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// shardsConfigKey is the key of the rendered config ConfigMap holding the
+// shard assignments read by the agent
+const shardsConfigKey = "shards"
+
+// statefulSetPodNameLabel is set by the StatefulSet controller on its pods
+// to their name, which the Services of the replicas select
+const statefulSetPodNameLabel = "statefulset.kubernetes.io/pod-name"
+
+// ShardingReconciler exposes each replica of sharded servers with its own
+// Service and reports the shards in status.shards: the endpoint of each
+// replica, whether it is ready and the number of peers assigned to it. The
+// VPNPeerReconciler points the clients of peers at the endpoint of their
+// shard, and the agents only configure the peers of their own shard.
+type ShardingReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+
+// Reconcile applies the Services of the replicas of a sharded server and
+// sets its shards.
+func (r *ShardingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	original := server.DeepCopy()
+
+	replicas := shardCount(server)
+	if err := r.deleteShardServices(ctx, server, replicas); err != nil {
+		return ctrl.Result{}, err
+	}
+	if replicas == 0 {
+		server.Status.Shards = nil
+		vpnv1alpha1.RemoveCondition(&server.Status.Conditions, vpnv1alpha1.ConditionShardsAvailable)
+		if equality.Semantic.DeepEqual(original.Status, server.Status) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, patchServerStatus(ctx, r.Client, server, original)
+	}
+
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(server.Namespace), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: server.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	assigned := map[int32]int32{}
+	total := 0
+	for i := range peers.Items {
+		if shard := peerShard(server, &peers.Items[i]); shard != nil {
+			assigned[*shard]++
+			total++
+		}
+	}
+
+	var unavailable []string
+	server.Status.Shards = nil
+	for ordinal := int32(0); ordinal < replicas; ordinal++ {
+		shard := vpnv1alpha1.ShardStatus{
+			Ordinal: ordinal,
+			Pod:     shardPod(server, ordinal),
+			Port:    shardPort(server, ordinal),
+			Peers:   assigned[ordinal],
+		}
+		svc, result, err := r.applyShardService(ctx, server, shard)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if result != controllerutil.OperationResultNone {
+			logger.Info("applied shard service", "service", svc.Name, "operation", result)
+		}
+		pod := &corev1.Pod{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: server.Namespace, Name: shard.Pod}, pod); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		} else if err == nil {
+			shard.Ready = isPodReady(pod) && pod.DeletionTimestamp.IsZero()
+		}
+		if shard.Endpoint, err = r.shardEndpoint(ctx, svc, pod); err != nil {
+			return ctrl.Result{}, err
+		}
+		if shard.Peers > 0 && (!shard.Ready || shard.Endpoint == "") {
+			unavailable = append(unavailable, shard.Pod)
+		}
+		server.Status.Shards = append(server.Status.Shards, shard)
+	}
+
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionShardsAvailable,
+		Status:             vpnv1alpha1.ConditionTrue,
+		Reason:             "Available",
+		Message:            fmt.Sprintf("%d peers across %d replicas", total, replicas),
+		ObservedGeneration: server.Generation,
+	}
+	if len(unavailable) > 0 {
+		condition.Status = vpnv1alpha1.ConditionFalse
+		condition.Reason = "ShardUnavailable"
+		condition.Message = fmt.Sprintf("replicas %s serve peers but are not ready or have no endpoint", strings.Join(unavailable, ", "))
+	}
+	vpnv1alpha1.SetCondition(&server.Status.Conditions, condition)
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{}, patchServerStatus(ctx, r.Client, server, original)
+}
+
+// applyShardService applies the Service exposing the replica of a shard on
+// its listen port.
+func (r *ShardingReconciler) applyShardService(ctx context.Context, server *vpnv1alpha1.VPNServer, shard vpnv1alpha1.ShardStatus) (*corev1.Service, controllerutil.OperationResult, error) {
+	serviceType := server.Spec.Sharding.ServiceType
+	if serviceType == "" {
+		serviceType = corev1.ServiceTypeLoadBalancer
+	}
+	var annotations map[string]string
+	trafficPolicy := corev1.ServiceExternalTrafficPolicyTypeCluster
+	if spec := server.Spec.Service; spec != nil {
+		annotations = spec.Annotations
+		if spec.ExternalTrafficPolicy != "" {
+			trafficPolicy = spec.ExternalTrafficPolicy
+		}
+	}
+
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: server.Namespace, Name: shardServiceName(server, shard.Ordinal)}}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, svc, func() error {
+		if svc.Labels == nil {
+			svc.Labels = map[string]string{}
+		}
+		for key, value := range serverLabels(server) {
+			svc.Labels[key] = value
+		}
+		svc.Labels[vpnv1alpha1.ShardLabel] = strconv.Itoa(int(shard.Ordinal))
+		propagateMetadata(server, svc)
+		svc.Annotations = mergeMetadata(svc.Annotations, annotations)
+		selector := serverLabels(server)
+		selector[statefulSetPodNameLabel] = shard.Pod
+		svc.Spec.Selector = selector
+		var nodePort int32
+		if len(svc.Spec.Ports) == 1 && svc.Spec.Ports[0].Port == shard.Port {
+			nodePort = svc.Spec.Ports[0].NodePort
+		}
+		svc.Spec.Ports = []corev1.ServicePort{{
+			Name:       wireguardPortName,
+			Protocol:   corev1.ProtocolUDP,
+			Port:       shard.Port,
+			TargetPort: intstr.FromInt(int(shard.Port)),
+			NodePort:   nodePort,
+		}}
+		svc.Spec.Type = serviceType
+		svc.Spec.ExternalTrafficPolicy = trafficPolicy
+		return controllerutil.SetControllerReference(server, svc, r.Scheme)
+	})
+	return svc, result, err
+}
+
+// shardEndpoint returns the endpoint clients reach the replica of a shard
+// on: the address of the load balancer of its Service, or the address of
+// the node of its pod with the node port.
+func (r *ShardingReconciler) shardEndpoint(ctx context.Context, svc *corev1.Service, pod *corev1.Pod) (string, error) {
+	port := svc.Spec.Ports[0]
+	if svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			host := ingress.IP
+			if host == "" {
+				host = ingress.Hostname
+			}
+			if host != "" {
+				return withPort(host, port.Port), nil
+			}
+		}
+		return "", nil
+	}
+	if port.NodePort == 0 || pod.Spec.NodeName == "" {
+		return "", nil
+	}
+	node := &corev1.Node{}
+	if err := r.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, node); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+	if address := nodeEndpointAddress(node); address != "" {
+		return withPort(address, port.NodePort), nil
+	}
+	return "", nil
+}
+
+// deleteShardServices deletes the Services of the replicas of a server at
+// or beyond replicas, after it was scaled in or stopped sharding.
+func (r *ShardingReconciler) deleteShardServices(ctx context.Context, server *vpnv1alpha1.VPNServer, replicas int32) error {
+	services := &corev1.ServiceList{}
+	if err := r.List(ctx, services, client.InNamespace(server.Namespace), client.MatchingLabels(serverLabels(server)), client.HasLabels{vpnv1alpha1.ShardLabel}); err != nil {
+		return err
+	}
+	for i := range services.Items {
+		svc := &services.Items[i]
+		ordinal, err := strconv.Atoi(svc.Labels[vpnv1alpha1.ShardLabel])
+		if err == nil && int32(ordinal) < replicas {
+			continue
+		}
+		if err := r.Delete(ctx, svc); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		log.FromContext(ctx).Info("deleted shard service", "service", svc.Name)
+	}
+	return nil
+}
+
+// shardCount returns the number of shards of a server, its replicas, or 0
+// if it is not sharded.
+func shardCount(server *vpnv1alpha1.VPNServer) int32 {
+	if server.Spec.Sharding == nil || server.Spec.DeploymentMode != vpnv1alpha1.DeploymentModeStatefulSet {
+		return 0
+	}
+	if server.Spec.Replicas < 1 {
+		return 1
+	}
+	return server.Spec.Replicas
+}
+
+// peerShard returns the ordinal of the replica serving a peer of a sharded
+// server, or nil if the server is not sharded or the peer is not
+// configured on it.
+func peerShard(server *vpnv1alpha1.VPNServer, peer *vpnv1alpha1.VPNPeer) *int32 {
+	replicas := shardCount(server)
+	if replicas == 0 || peer.Spec.PublicKey == "" || peer.Spec.Revoked || !peer.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	shard := rendezvousShard(peer.Spec.PublicKey, replicas)
+	return &shard
+}
+
+// rendezvousShard returns the replica with the highest weight for a public
+// key. Scaling from n to n+1 replicas only moves the peers the new replica
+// outweighs, about 1/(n+1) of them, and scaling in only moves the peers of
+// the removed replica.
+func rendezvousShard(publicKey string, replicas int32) int32 {
+	var shard int32
+	var highest uint64
+	for ordinal := int32(0); ordinal < replicas; ordinal++ {
+		sum := sha256.Sum256([]byte(publicKey + "/" + strconv.Itoa(int(ordinal))))
+		if weight := binary.BigEndian.Uint64(sum[:8]); ordinal == 0 || weight > highest {
+			shard, highest = ordinal, weight
+		}
+	}
+	return shard
+}
+
+// shardPod returns the pod of the replica of a shard in the StatefulSet of
+// a server.
+func shardPod(server *vpnv1alpha1.VPNServer, ordinal int32) string {
+	return fmt.Sprintf("%s-%d", server.Name, ordinal)
+}
+
+// shardServiceName returns the name of the Service of the replica of a
+// shard.
+func shardServiceName(server *vpnv1alpha1.VPNServer, ordinal int32) string {
+	return fmt.Sprintf("%s-shard-%d", server.Name, ordinal)
+}
+
+// shardPort returns the port the replica of a shard listens on: the port
+// of spec.listenPortRange at its ordinal, or the port of the server.
+func shardPort(server *vpnv1alpha1.VPNServer, ordinal int32) int32 {
+	if portRange := server.Spec.ListenPortRange; portRange != nil {
+		return portRange.Start + ordinal
+	}
+	return server.Spec.Port
+}
+
+// shardClientEndpoint returns the endpoint of the shard of a peer, empty
+// until the endpoint of its replica is discovered.
+func shardClientEndpoint(server *vpnv1alpha1.VPNServer, shard int32) string {
+	for _, status := range server.Status.Shards {
+		if status.Ordinal == shard {
+			return status.Endpoint
+		}
+	}
+	return ""
+}
+
+// renderShardAssignments renders the shard assignments of the peers of a
+// sharded server for the agent: one line per peer, holding the ordinal of
+// its replica and its public key. Agents leave out the peers of other
+// replicas from their device.
+func renderShardAssignments(server *vpnv1alpha1.VPNServer, peers []vpnv1alpha1.VPNPeer) string {
+	var lines []string
+	for i := range peers {
+		peer := &peers[i]
+		if peer.Spec.ServerRef.Name != server.Name {
+			continue
+		}
+		if shard := peerShard(server, peer); shard != nil {
+			lines = append(lines, fmt.Sprintf("%d %s\n", *shard, peer.Spec.PublicKey))
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "")
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ShardingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("sharding").
+		For(&vpnv1alpha1.VPNServer{}).
+		Owns(&corev1.Service{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(serverForPod)).
+		Watches(&vpnv1alpha1.VPNPeer{}, handler.EnqueueRequestsFromMapFunc(serverForPeer)).
+		Complete(instrument(mgr, "sharding", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// ShutdownMarker sets the OperatorRestarting condition of the VPNServers
+// while the operator shuts down, e.g. during a rolling update of the
+// operator, so that a pause in reconciliation is not mistaken for a stuck
+// server. The condition is cleared once an operator leads again. In-flight
+// reconciles are completed by the manager within its graceful shutdown
+// timeout.
+type ShutdownMarker struct {
+	client.Client
+
+	// Timeout bounds the status writes on shutdown
+	Timeout time.Duration
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+func (m *ShutdownMarker) NeedLeaderElection() bool {
+	return true
+}
+
+// Start implements manager.Runnable.
+func (m *ShutdownMarker) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("shutdown-marker")
+	if err := m.mark(ctx, false); err != nil {
+		logger.Error(err, "unable to clear the OperatorRestarting condition of servers")
+	}
+	<-ctx.Done()
+
+	// The manager context is gone, the marks are written on a context of
+	// their own
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), m.Timeout)
+	defer cancel()
+	if err := m.mark(shutdownCtx, true); err != nil {
+		logger.Error(err, "unable to mark servers OperatorRestarting")
+	}
+	return nil
+}
+
+// mark sets the OperatorRestarting condition of every server.
+func (m *ShutdownMarker) mark(ctx context.Context, restarting bool) error {
+	servers := &vpnv1alpha1.VPNServerList{}
+	if err := m.List(ctx, servers); err != nil {
+		return err
+	}
+	condition := vpnv1alpha1.Condition{
+		Type:   vpnv1alpha1.ConditionOperatorRestarting,
+		Status: vpnv1alpha1.ConditionFalse,
+		Reason: "Running",
+	}
+	if restarting {
+		condition.Status = vpnv1alpha1.ConditionTrue
+		condition.Reason = "Shutdown"
+		condition.Message = "The operator is shutting down, changes are applied once it runs again"
+	}
+
+	for i := range servers.Items {
+		server := &servers.Items[i]
+		current := vpnv1alpha1.FindCondition(server.Status.Conditions, condition.Type)
+		if (current == nil && !restarting) || (current != nil && current.Status == condition.Status) {
+			continue
+		}
+		condition.ObservedGeneration = server.Generation
+		original := server.DeepCopy()
+		vpnv1alpha1.SetCondition(&server.Status.Conditions, condition)
+		if err := patchServerStatus(ctx, m.Client, server, original); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// splitDNSConfigKey is the key of the rendered config ConfigMap holding the
+// split-DNS forwarder config read by the agent
+const splitDNSConfigKey = "split-dns"
+
+// renderSplitDNSConfig renders the dnsmasq config of the split-DNS
+// forwarder of a server: the DNS zones of its routing profiles are
+// forwarded to their servers, other names to the server's DNS or, without
+// one, to the resolvers of the pod. Zones declared by several profiles are
+// forwarded to the servers of all of them. It is empty if no profile has
+// zones, which stops the forwarder.
+func renderSplitDNSConfig(server *vpnv1alpha1.VPNServer) string {
+	zones := map[string][]string{}
+	seen := map[string]bool{}
+	for _, profile := range server.Spec.ClientRoutingProfiles {
+		for _, zone := range profile.DNSZones {
+			for _, address := range zone.Servers {
+				if key := zone.Domain + " " + address; !seen[key] {
+					seen[key] = true
+					zones[zone.Domain] = append(zones[zone.Domain], address)
+				}
+			}
+		}
+	}
+	if len(zones) == 0 {
+		return ""
+	}
+
+	domains := make([]string, 0, len(zones))
+	for domain := range zones {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Split DNS of VPNServer %s/%s\n", server.Namespace, server.Name)
+	for _, domain := range domains {
+		for _, address := range zones[domain] {
+			fmt.Fprintf(&b, "server=/%s/%s\n", domain, address)
+		}
+	}
+	if upstreams := splitList(server.Spec.DNS); len(upstreams) > 0 {
+		b.WriteString("no-resolv\n")
+		for _, address := range upstreams {
+			fmt.Fprintf(&b, "server=%s\n", address)
+		}
+	}
+	return b.String()
+}
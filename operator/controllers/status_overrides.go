@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// resolveServerIdentity sets the endpoint and public key of the server
+// status from the discovered values, unless the spec overrides them. The
+// EndpointOverridden and PublicKeyOverridden conditions tell which source
+// is in effect, so that users patching the status instead of the spec find
+// out why their change does not stick.
+func resolveServerIdentity(server *vpnv1alpha1.VPNServer, endpoint, publicKey string) {
+	server.Status.Endpoint = resolveOverride(server, vpnv1alpha1.ConditionEndpointOverridden,
+		"spec.endpointOverride", server.Spec.EndpointOverride, endpoint)
+	server.Status.PublicKey = resolveOverride(server, vpnv1alpha1.ConditionPublicKeyOverridden,
+		"spec.publicKeyOverride", server.Spec.PublicKeyOverride, publicKey)
+}
+
+// resolveOverride returns override if set and discovered otherwise, and sets
+// the condition of the given type accordingly.
+func resolveOverride(server *vpnv1alpha1.VPNServer, conditionType, field, override, discovered string) string {
+	condition := vpnv1alpha1.Condition{
+		Type:               conditionType,
+		Status:             vpnv1alpha1.ConditionFalse,
+		Reason:             "Discovered",
+		Message:            "discovered by the operator, set " + field + " to override it",
+		ObservedGeneration: server.Generation,
+	}
+	value := discovered
+	if override != "" {
+		condition.Status = vpnv1alpha1.ConditionTrue
+		condition.Reason = "SpecOverride"
+		condition.Message = "taken from " + field
+		value = override
+	}
+	vpnv1alpha1.SetCondition(&server.Status.Conditions, condition)
+	return value
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const testNamespace = "vpn"
+
+// newTestClient returns a fake client holding objs, with the status
+// subresources and field indexes the controllers rely on.
+func newTestClient(t *testing.T, objs ...client.Object) (client.Client, *runtime.Scheme) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := vpnv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&vpnv1alpha1.VPNServer{}, &vpnv1alpha1.VPNPeer{}, &vpnv1alpha1.VPNNetwork{}, &vpnv1alpha1.VPNConnectivityCheck{}).
+		WithIndex(&vpnv1alpha1.VPNPeer{}, vpnv1alpha1.VPNPeerServerRefField, func(obj client.Object) []string {
+			return []string{obj.(*vpnv1alpha1.VPNPeer).Spec.ServerRef.Name}
+		}).
+		Build()
+	return c, scheme
+}
+
+// testServer returns a server in the Deployment mode.
+func testServer(name string) *vpnv1alpha1.VPNServer {
+	return &vpnv1alpha1.VPNServer{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name, UID: types.UID(name + "-uid"), Generation: 1},
+		Spec: vpnv1alpha1.VPNServerSpec{
+			Replicas:  2,
+			Image:     "wireflow/server:1.0",
+			Port:      51820,
+			Interface: "wg0",
+			Address:   "10.8.0.1/24",
+		},
+	}
+}
+
+// testPeer returns a peer of server with an address and a public key.
+func testPeer(name, server, publicKey, address string) *vpnv1alpha1.VPNPeer {
+	return &vpnv1alpha1.VPNPeer{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name},
+		Spec: vpnv1alpha1.VPNPeerSpec{
+			ServerRef: vpnv1alpha1.LocalObjectReference{Name: server},
+			PublicKey: publicKey,
+			Address:   address,
+		},
+	}
+}
+
+// testPod returns a server pod, ready or not, with annotations.
+func testPod(server *vpnv1alpha1.VPNServer, name string, ready bool, annotations map[string]string) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: server.Namespace, Name: name, Labels: serverLabels(server), Annotations: annotations},
+		Status:     corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}},
+	}
+}
+
+// reconcileServer reconciles a server with r and returns it as stored.
+func reconcileServer(t *testing.T, r interface {
+	Reconcile(context.Context, ctrl.Request) (ctrl.Result, error)
+}, c client.Client, server *vpnv1alpha1.VPNServer) *vpnv1alpha1.VPNServer {
+	t.Helper()
+	key := client.ObjectKeyFromObject(server)
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	updated := &vpnv1alpha1.VPNServer{}
+	if err := c.Get(context.Background(), key, updated); err != nil {
+		t.Fatal(err)
+	}
+	return updated
+}
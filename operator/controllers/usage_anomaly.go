@@ -0,0 +1,339 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	defaultAnomalyTrafficFactor  = 5
+	defaultAnomalyMinimumTraffic = 100 << 20
+	defaultAnomalyLearningDays   = 7
+
+	// baselineSmoothing is the weight of a completed day in the average
+	// daily traffic, comparable to a 7 day moving average
+	baselineSmoothing = 0.25
+
+	// baselinePersistInterval bounds how often baselines are written when
+	// only the traffic of the current day changed. Transfer counters are
+	// cumulative, so traffic between writes is still counted after a
+	// restart.
+	baselinePersistInterval = 15 * time.Minute
+
+	// newNetworkPeriod is how long a peer is reported after connecting from
+	// an autonomous system it has not used before
+	newNetworkPeriod = 24 * time.Hour
+
+	// maxPeerNetworks bounds the autonomous systems remembered per peer
+	maxPeerNetworks = 16
+
+	// maxBaselineCatchUpDays bounds the days folded into a baseline after
+	// the operator did not observe a peer for a while
+	maxBaselineCatchUpDays = 30
+
+	usageBaselineKey = "baselines.json"
+)
+
+// ASNResolver resolves the autonomous system an IP address is announced
+// from.
+type ASNResolver interface {
+	ASN(ctx context.Context, ip net.IP) (uint32, error)
+}
+
+// peerBaseline is the usage baseline of a peer. Field names are short to
+// keep the ConfigMap of large servers compact.
+type peerBaseline struct {
+	// Day is the UTC day, in days since the epoch, DayBytes accumulates
+	Day      int64 `json:"d"`
+	DayBytes int64 `json:"b"`
+
+	// Counter is the transfer counter of the peer at Seen, in unix seconds
+	Counter int64 `json:"c"`
+	Seen    int64 `json:"s"`
+
+	// Average is the exponentially weighted average traffic of Days
+	// completed days
+	Average float64 `json:"a"`
+	Days    int32   `json:"n"`
+
+	// Networks maps the ASNs the peer connected from to the unix time they
+	// were first seen, or 0 for networks learned with the baseline
+	Networks map[uint32]int64 `json:"asn,omitempty"`
+}
+
+// usageBaselines are the baselines of the peers of a server, by public key
+type usageBaselines struct {
+	peers     map[string]*peerBaseline
+	persisted time.Time
+	dirty     bool
+}
+
+// closeDays folds the traffic of the days since the baseline's day into the
+// average. bytes is spread evenly over them, as the transfer counters only
+// tell how much was transferred since the peer was last observed.
+func (b *peerBaseline) closeDays(today, bytes int64) {
+	days := today - b.Day
+	if days > maxBaselineCatchUpDays {
+		days = maxBaselineCatchUpDays
+	}
+	for i := int64(0); i < days; i++ {
+		daily := float64(bytes) / float64(days)
+		if b.Days == 0 {
+			b.Average = daily
+		} else {
+			b.Average = baselineSmoothing*daily + (1-baselineSmoothing)*b.Average
+		}
+		b.Days++
+	}
+	b.Day = today
+	b.DayBytes = 0
+}
+
+// observe records the transfer counter of the peer at now and returns
+// whether a day was completed.
+func (b *peerBaseline) observe(counter int64, now time.Time) bool {
+	delta := counter - b.Counter
+	// A counter going backwards means the device was recreated
+	if delta < 0 {
+		delta = counter
+	}
+	seen := time.Unix(b.Seen, 0)
+	b.Counter = counter
+	b.Seen = now.Unix()
+
+	today := now.Unix() / 86400
+	if today == b.Day {
+		b.DayBytes += delta
+		return false
+	}
+
+	// Only the part of the traffic since midnight counts against today
+	midnight := time.Unix(today*86400, 0)
+	todayBytes := delta
+	if gap := now.Sub(seen); gap > now.Sub(midnight) {
+		todayBytes = int64(float64(delta) * now.Sub(midnight).Seconds() / gap.Seconds())
+	}
+	b.closeDays(today, b.DayBytes+delta-todayBytes)
+	b.DayBytes = todayBytes
+	return true
+}
+
+// observeNetwork records that the peer connected from asn and returns when
+// it was first seen, or zero if it is one of the usual networks of the peer.
+func (b *peerBaseline) observeNetwork(asn uint32, now time.Time, learned bool) (first time.Time, added bool) {
+	if b.Networks == nil {
+		b.Networks = map[uint32]int64{}
+	}
+	seen, ok := b.Networks[asn]
+	if !ok {
+		added = true
+		// Networks seen while learning are usual ones
+		if learned && len(b.Networks) > 0 {
+			seen = now.Unix()
+		}
+		b.Networks[asn] = seen
+		for len(b.Networks) > maxPeerNetworks {
+			delete(b.Networks, oldestNetwork(b.Networks, asn))
+		}
+	}
+	if seen == 0 {
+		return time.Time{}, added
+	}
+	return time.Unix(seen, 0), added
+}
+
+// oldestNetwork returns the network seen first, other than keep.
+func oldestNetwork(networks map[uint32]int64, keep uint32) uint32 {
+	var oldest uint32
+	first := true
+	for asn, seen := range networks {
+		if asn == keep {
+			continue
+		}
+		if first || seen < networks[oldest] || seen == networks[oldest] && asn < oldest {
+			oldest, first = asn, false
+		}
+	}
+	return oldest
+}
+
+// detectAnomalies compares the usage of the peers of a server to their
+// baselines and updates the baselines.
+func (r *HealthAlertReconciler) detectAnomalies(ctx context.Context, server *vpnv1alpha1.VPNServer, now time.Time) ([]alertBreach, error) {
+	logger := log.FromContext(ctx)
+	spec := server.Spec.Alerting.UsageAnomaly
+	factor := int32(defaultAnomalyTrafficFactor)
+	if spec.TrafficFactor > 0 {
+		factor = spec.TrafficFactor
+	}
+	minimum := int64(defaultAnomalyMinimumTraffic)
+	if spec.MinimumTraffic != nil {
+		minimum = *spec.MinimumTraffic
+	}
+	learningDays := int32(defaultAnomalyLearningDays)
+	if spec.LearningDays > 0 {
+		learningDays = spec.LearningDays
+	}
+
+	baselines, err := r.loadBaselines(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+
+	var traffic, networks []string
+	present := map[string]bool{}
+	for _, peer := range server.Status.Peers {
+		present[peer.PublicKey] = true
+		name := peer.Name
+		if name == "" {
+			name = peer.PublicKey
+		}
+		counter := peer.ReceiveBytes + peer.TransmitBytes
+
+		baseline := baselines.peers[peer.PublicKey]
+		if baseline == nil {
+			baseline = &peerBaseline{Day: now.Unix() / 86400, Counter: counter, Seen: now.Unix()}
+			baselines.peers[peer.PublicKey] = baseline
+			baselines.dirty = true
+		} else if baseline.observe(counter, now) {
+			baselines.dirty = true
+		}
+		learned := baseline.Days >= learningDays
+
+		if learned && baseline.DayBytes >= minimum && float64(baseline.DayBytes) > float64(factor)*baseline.Average {
+			usual := "no usual traffic"
+			if baseline.Average > 0 {
+				usual = fmt.Sprintf("%.1f times its baseline", float64(baseline.DayBytes)/baseline.Average)
+			}
+			traffic = append(traffic, fmt.Sprintf("%s (%d bytes today, %s)", name, baseline.DayBytes, usual))
+		}
+
+		if !spec.NewNetworks || r.ASNResolver == nil || peer.Endpoint == "" {
+			continue
+		}
+		host, _, err := net.SplitHostPort(peer.Endpoint)
+		if err != nil {
+			continue
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			continue
+		}
+		asn, err := r.ASNResolver.ASN(ctx, ip)
+		if err != nil {
+			logger.Info("unable to resolve the network of a peer endpoint", "peer", name, "error", err.Error())
+			continue
+		}
+		if asn == 0 {
+			continue
+		}
+		first, added := baseline.observeNetwork(asn, now, learned)
+		if added {
+			baselines.dirty = true
+		}
+		if !first.IsZero() && now.Sub(first) < newNetworkPeriod {
+			networks = append(networks, fmt.Sprintf("%s (AS%d)", name, asn))
+		}
+	}
+	for key := range baselines.peers {
+		if !present[key] {
+			delete(baselines.peers, key)
+			baselines.dirty = true
+		}
+	}
+
+	if baselines.dirty || now.Sub(baselines.persisted) >= baselinePersistInterval {
+		if err := r.persistBaselines(ctx, server, baselines); err != nil {
+			return nil, err
+		}
+		baselines.persisted = now
+		baselines.dirty = false
+	}
+
+	var breaches []alertBreach
+	if len(traffic) > 0 {
+		sort.Strings(traffic)
+		breaches = append(breaches, alertBreach{
+			Rule:    "PeerTrafficAnomaly",
+			Message: "peers transferring more than usual: " + strings.Join(traffic, ", "),
+		})
+	}
+	if len(networks) > 0 {
+		sort.Strings(networks)
+		breaches = append(breaches, alertBreach{
+			Rule:    "PeerNewNetwork",
+			Message: "peers connecting from new networks: " + strings.Join(networks, ", "),
+		})
+	}
+	return breaches, nil
+}
+
+// usageBaselineName returns the name of the ConfigMap holding the usage
+// baselines of a server.
+func usageBaselineName(server *vpnv1alpha1.VPNServer) string {
+	return server.Name + "-usage-baseline"
+}
+
+// loadBaselines returns the baselines of a server, reading them from its
+// ConfigMap after the operator started.
+func (r *HealthAlertReconciler) loadBaselines(ctx context.Context, server *vpnv1alpha1.VPNServer) (*usageBaselines, error) {
+	key := client.ObjectKeyFromObject(server)
+	r.mu.Lock()
+	baselines, ok := r.baselines[key]
+	r.mu.Unlock()
+	if ok {
+		return baselines, nil
+	}
+
+	baselines = &usageBaselines{peers: map[string]*peerBaseline{}, persisted: time.Now()}
+	cm := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: server.Namespace, Name: usageBaselineName(server)}, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if data, found := cm.Data[usageBaselineKey]; err == nil && found {
+		if err := json.Unmarshal([]byte(data), &baselines.peers); err != nil {
+			log.FromContext(ctx).Error(err, "discarding unreadable usage baselines", "configmap", cm.Name)
+			baselines.peers = map[string]*peerBaseline{}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.baselines == nil {
+		r.baselines = map[types.NamespacedName]*usageBaselines{}
+	}
+	r.baselines[key] = baselines
+	return baselines, nil
+}
+
+// persistBaselines writes the baselines of a server to its ConfigMap, which
+// the server owns.
+func (r *HealthAlertReconciler) persistBaselines(ctx context.Context, server *vpnv1alpha1.VPNServer, baselines *usageBaselines) error {
+	data, err := json.Marshal(baselines.peers)
+	if err != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: server.Namespace, Name: usageBaselineName(server)}}
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, cm, func() error {
+		cm.Data = map[string]string{usageBaselineKey: string(data)}
+		propagateMetadata(server, cm)
+		return controllerutil.SetOwnerReference(server, cm, r.Scheme)
+	})
+	return err
+}
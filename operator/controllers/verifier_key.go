@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/vpn-devops/vpn-operator/pkg/enrollment"
+)
+
+// verifierKeyKey is the key of the verifier key in its Secret
+const verifierKeyKey = "verifier.key"
+
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;create
+
+// LoadVerifier returns a proof of possession verifier with the key held in
+// a Secret, generating it on first use. The enrollment and posture servers
+// run on every replica, and a challenge issued by one replica may be
+// answered on another, so they all share the key.
+func LoadVerifier(ctx context.Context, c client.Client, key types.NamespacedName) (*enrollment.Verifier, error) {
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, key, secret)
+	if apierrors.IsNotFound(err) {
+		raw, err := enrollment.GenerateVerifierKey()
+		if err != nil {
+			return nil, err
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Type:       corev1.SecretTypeOpaque,
+			Data:       map[string][]byte{verifierKeyKey: raw},
+		}
+		err = c.Create(ctx, secret)
+		if apierrors.IsAlreadyExists(err) {
+			// Another replica created it first, use its key
+			secret = &corev1.Secret{}
+			err = c.Get(ctx, key, secret)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	verifier, err := enrollment.NewVerifierWithKey(secret.Data[verifierKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("secret %s: %w", key, err)
+	}
+	return verifier, nil
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/vpn-devops/vpn-operator/pkg/enrollment"
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+)
+
+func TestLoadVerifierSharesKeyAcrossReplicas(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+	key := types.NamespacedName{Namespace: "vpn-system", Name: "wireflow-enrollment-verifier-key"}
+
+	issuer, err := LoadVerifier(ctx, c, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, key, secret); err != nil {
+		t.Fatalf("verifier key secret: %v", err)
+	}
+	if len(secret.Data[verifierKeyKey]) != enrollment.VerifierKeySize {
+		t.Fatalf("verifier key has %d bytes, want %d", len(secret.Data[verifierKeyKey]), enrollment.VerifierKeySize)
+	}
+	other, err := LoadVerifier(ctx, c, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	private := &escrow.Key{}
+	if _, err := rand.Read(private[:]); err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := issuer.Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := enrollment.Prove(private, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Verify(challenge, private.PublicKey().String(), proof); err != nil {
+		t.Errorf("challenge issued by another replica: %v", err)
+	}
+}
+
+func TestLoadVerifierRejectsChallengesOfOtherKeys(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+
+	issuer, err := LoadVerifier(ctx, c, types.NamespacedName{Namespace: "vpn-system", Name: "enrollment"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := LoadVerifier(ctx, c, types.NamespacedName{Namespace: "vpn-system", Name: "posture"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	private := &escrow.Key{}
+	if _, err := rand.Read(private[:]); err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := issuer.Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := enrollment.Prove(private, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Verify(challenge, private.PublicKey().String(), proof); !errors.Is(err, enrollment.ErrInvalidChallenge) {
+		t.Errorf("challenge of another key: err = %v, want ErrInvalidChallenge", err)
+	}
+}
+
+func TestLoadVerifierRejectsMalformedKey(t *testing.T) {
+	secret := &corev1.Secret{}
+	secret.Namespace, secret.Name = "vpn-system", "wireflow-enrollment-verifier-key"
+	secret.Data = map[string][]byte{verifierKeyKey: []byte("short")}
+	c, _ := newTestClient(t, secret)
+
+	if _, err := LoadVerifier(context.Background(), c, types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}); err == nil {
+		t.Error("malformed verifier key accepted")
+	}
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// VPNAccessPolicyReconciler reports whether access policies can be applied.
+// The networks they grant are applied by the VPNPeerReconciler.
+type VPNAccessPolicyReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnaccesspolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnaccesspolicies/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+
+// Reconcile checks the server reference and rules of a policy.
+func (r *VPNAccessPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	policy := &vpnv1alpha1.VPNAccessPolicy{}
+	if err := r.Get(ctx, req.NamespacedName, policy); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !policy.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionReady,
+		Status:             vpnv1alpha1.ConditionTrue,
+		Reason:             "Accepted",
+		ObservedGeneration: policy.Generation,
+	}
+	if invalid := invalidPolicyNetworks(policy); len(invalid) > 0 {
+		condition.Status = vpnv1alpha1.ConditionFalse
+		condition.Reason = "InvalidAllowedIPs"
+		condition.Message = fmt.Sprintf("rules grant invalid networks %v", invalid)
+	} else if policy.Spec.ServerRef != nil {
+		server := &vpnv1alpha1.VPNServer{}
+		err := r.Get(ctx, types.NamespacedName{Namespace: policy.Namespace, Name: policy.Spec.ServerRef.Name}, server)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if apierrors.IsNotFound(err) {
+			condition.Status = vpnv1alpha1.ConditionFalse
+			condition.Reason = "MissingRef"
+			condition.Message = fmt.Sprintf("VPNServer %q does not exist", policy.Spec.ServerRef.Name)
+		}
+	}
+
+	policy.Status.ObservedGeneration = policy.Generation
+	vpnv1alpha1.SetCondition(&policy.Status.Conditions, condition)
+	return ctrl.Result{}, r.Status().Update(ctx, policy)
+}
+
+// invalidPolicyNetworks returns the networks granted by the rules of a
+// policy that are not CIDRs.
+func invalidPolicyNetworks(policy *vpnv1alpha1.VPNAccessPolicy) []string {
+	var invalid []string
+	for _, rule := range policy.Spec.Rules {
+		for _, network := range rule.AllowedIPs {
+			if _, _, err := net.ParseCIDR(network); err != nil {
+				invalid = append(invalid, network)
+			}
+		}
+	}
+	return invalid
+}
+
+// policiesForServer maps a VPNServer to the policies referencing it.
+func (r *VPNAccessPolicyReconciler) policiesForServer(ctx context.Context, obj client.Object) []reconcile.Request {
+	policies := &vpnv1alpha1.VPNAccessPolicyList{}
+	if err := r.List(ctx, policies, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list access policies", "namespace", obj.GetNamespace())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, policy := range policies.Items {
+		if policy.Spec.ServerRef != nil && policy.Spec.ServerRef.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&policy)})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VPNAccessPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vpnv1alpha1.VPNAccessPolicy{}).
+		Watches(&vpnv1alpha1.VPNServer{}, handler.EnqueueRequestsFromMapFunc(r.policiesForServer)).
+		Complete(instrument(mgr, "vpnaccesspolicy", &vpnv1alpha1.VPNAccessPolicy{}, r))
+}
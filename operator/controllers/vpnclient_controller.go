@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// renderedConfigMountPath is where the rendered configuration of a server
+// is mounted in its pods, the directory of WG_RENDERED_CONFIG of the agent
+const renderedConfigMountPath = "/etc/wireguard/rendered"
+
+// VPNClientReconciler renders the server side of the clients of a server:
+// the WireGuard configuration holding a peer section per VPNPeer, into a
+// Secret since it holds the preshared keys, and the files the agent
+// programs the firewall, shaping and DNS from, into a ConfigMap. Both are
+// mounted in the server pods, which reload them once the checksum of all the
+// files, published in status.configChecksum, reaches them.
+type VPNClientReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpningressmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch
+
+// Reconcile renders the configuration of a server.
+func (r *VPNClientReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(server.Namespace), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: server.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	maps := &vpnv1alpha1.VPNIngressMapList{}
+	if err := r.List(ctx, maps, client.InNamespace(server.Namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	rendered := observePhase(ctx, reconcilePhaseRender)
+	config, err := r.renderServerConfig(ctx, server, peers.Items)
+	rendered()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	// The agent treats a missing file as an empty one
+	data := map[string]string{
+		isolationConfigKey: renderIsolationExceptions(server, peers.Items),
+		ingressConfigKey:   renderIngressMaps(server, maps.Items),
+		splitDNSConfigKey:  renderSplitDNSConfig(server),
+		shardsConfigKey:    renderShardAssignments(server, peers.Items),
+		bandwidthConfigKey: renderBandwidthLimits(server, peers.Items),
+		firewallConfigKey:  renderFirewallRules(server),
+	}
+	for key, value := range data {
+		if value == "" {
+			delete(data, key)
+		}
+	}
+
+	written := observePhase(ctx, reconcilePhaseSecretWrite)
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: server.Namespace, Name: serverConfigName(server)}}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		secret.Labels = serverLabels(server)
+		propagateMetadata(server, secret)
+		secret.Type = corev1.SecretTypeOpaque
+		secret.Data = map[string][]byte{serverConfigFile(server): []byte(config)}
+		return controllerutil.SetControllerReference(server, secret, r.Scheme)
+	})
+	if err != nil {
+		written()
+		return ctrl.Result{}, err
+	}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: server.Namespace, Name: serverConfigName(server)}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, cm, func() error {
+		cm.Labels = serverLabels(server)
+		propagateMetadata(server, cm)
+		cm.Data = data
+		return controllerutil.SetControllerReference(server, cm, r.Scheme)
+	}); err != nil {
+		written()
+		return ctrl.Result{}, err
+	}
+	written()
+	if result != controllerutil.OperationResultNone {
+		logger.Info("rendered server config", "secret", secret.Name, "operation", result)
+	}
+
+	original := server.DeepCopy()
+	files := map[string][]byte{serverConfigFile(server): []byte(config)}
+	for key, value := range data {
+		files[key] = []byte(value)
+	}
+	server.Status.ConfigChecksum = renderedChecksum(files)
+	server.Status.ConfigGeneration = server.Generation
+	if !equality.Semantic.DeepEqual(original.Status, server.Status) {
+		if err := patchServerStatus(ctx, r.Client, server, original); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// renderServerConfig renders the WireGuard configuration of a server with a
+// peer section per peer holding a public key. It has no private key: the
+// agent keeps the key of its device and only syncs the peers and listen
+// port. Revoked and deleted peers are left out, and so are peers whose
+// preshared key was not generated yet, until it is.
+func (r *VPNClientReconciler) renderServerConfig(ctx context.Context, server *vpnv1alpha1.VPNServer, peers []vpnv1alpha1.VPNPeer) (string, error) {
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Name < peers[j].Name })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Interface]\nListenPort = %d\n", server.Spec.Port)
+	for i := range peers {
+		peer := &peers[i]
+		if peer.Spec.PublicKey == "" || peer.Spec.Revoked || !peer.DeletionTimestamp.IsZero() {
+			continue
+		}
+		psk, err := peerPresharedKey(ctx, r.Client, peer)
+		if apierrors.IsNotFound(err) {
+			log.FromContext(ctx).Info("waiting for the preshared key of peer", "peer", peer.Name)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "\n[Peer]\n# %s\nPublicKey = %s\n", peer.Name, peer.Spec.PublicKey)
+		if psk != "" {
+			fmt.Fprintf(&b, "PresharedKey = %s\n", psk)
+		}
+		// Suspended peers have no address, their section keeps the key
+		// known to the server until they are resumed
+		var allowedIPs []string
+		if cidr := hostCIDR(peer.Spec.Address); cidr != "" {
+			allowedIPs = append(allowedIPs, cidr)
+		}
+		allowedIPs = append(allowedIPs, peer.Spec.AllowedIPs...)
+		if len(allowedIPs) > 0 {
+			fmt.Fprintf(&b, "AllowedIPs = %s\n", strings.Join(allowedIPs, ", "))
+		}
+		if peer.Spec.Endpoint != "" {
+			fmt.Fprintf(&b, "Endpoint = %s\n", peer.Spec.Endpoint)
+		}
+		if peer.Spec.PersistentKeepalive > 0 {
+			fmt.Fprintf(&b, "PersistentKeepalive = %d\n", peer.Spec.PersistentKeepalive)
+		}
+	}
+	return b.String(), nil
+}
+
+// serverConfigName returns the name of the Secret and ConfigMap holding the
+// rendered configuration of a server.
+func serverConfigName(server *vpnv1alpha1.VPNServer) string {
+	return server.Name + "-wireguard"
+}
+
+// serverConfigFile returns the key of the WireGuard configuration in the
+// rendered config Secret, the file name the agent loads.
+func serverConfigFile(server *vpnv1alpha1.VPNServer) string {
+	iface := server.Spec.Interface
+	if iface == "" {
+		iface = "wg0"
+	}
+	return iface + ".conf"
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VPNClientReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("vpnclient").
+		For(&vpnv1alpha1.VPNServer{}).
+		Owns(&corev1.Secret{}).
+		Owns(&corev1.ConfigMap{}).
+		Watches(&vpnv1alpha1.VPNPeer{}, serverForPeerCoalesced()).
+		Watches(&vpnv1alpha1.VPNIngressMap{}, handler.EnqueueRequestsFromMapFunc(serverForIngressMap)).
+		Complete(instrument(mgr, "vpnclient", &vpnv1alpha1.VPNServer{}, r))
+}
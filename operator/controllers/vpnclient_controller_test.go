@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	testKeyA = "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg="
+	testKeyB = "HIgo9xNzJMWLKASShiTqIybxZ0U3wGLiUeJ1PKf8ykw="
+	testKeyC = "TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0="
+	testPSK  = "FpCyhws9cxwWoV4xELtfJvjJN+zQVRPISllRWgeopVE="
+)
+
+// renderedConfig returns the WireGuard configuration rendered for a server
+// and the files of its rendered config ConfigMap.
+func renderedConfig(t *testing.T, c client.Client, server *vpnv1alpha1.VPNServer) (string, map[string]string) {
+	t.Helper()
+	key := types.NamespacedName{Namespace: server.Namespace, Name: serverConfigName(server)}
+	secret := &corev1.Secret{}
+	if err := c.Get(context.Background(), key, secret); err != nil {
+		t.Fatalf("config secret: %v", err)
+	}
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(context.Background(), key, cm); err != nil {
+		t.Fatalf("config map: %v", err)
+	}
+	return string(secret.Data[serverConfigFile(server)]), cm.Data
+}
+
+func TestVPNClientReconcilerRendersInterface(t *testing.T) {
+	server := testServer("edge")
+	c, scheme := newTestClient(t, server)
+	r := &VPNClientReconciler{Client: c, Scheme: scheme}
+	updated := reconcileServer(t, r, c, server)
+
+	config, _ := renderedConfig(t, c, server)
+	want := "[Interface]\nListenPort = 51820\n"
+	if config != want {
+		t.Errorf("config = %q, want %q", config, want)
+	}
+	// As sha256sum $(ls) | sha256sum computes it in the mounted directory
+	if sum := configChecksum([]byte(configChecksum([]byte(config)) + "  wg0.conf\n")); updated.Status.ConfigChecksum != sum {
+		t.Errorf("config checksum = %q, want %q", updated.Status.ConfigChecksum, sum)
+	}
+}
+
+func TestVPNClientReconcilerRendersPeers(t *testing.T) {
+	server := testServer("edge")
+	active := testPeer("laptop", "edge", testKeyA, "10.8.0.2")
+	active.Spec.AllowedIPs = []string{"192.168.10.0/24"}
+	active.Spec.PresharedKeySecretRef = &vpnv1alpha1.SecretKeyReference{Name: "laptop-psk"}
+	revoked := testPeer("phone", "edge", testKeyB, "10.8.0.3")
+	revoked.Spec.Revoked = true
+	invited := testPeer("tablet", "edge", "", "10.8.0.4")
+	suspended := testPeer("desktop", "edge", testKeyC, "")
+	other := testPeer("other", "core", testKeyB, "10.9.0.2")
+	psk := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "laptop-psk"},
+		Data:       map[string][]byte{vpnv1alpha1.DefaultPresharedKeyKey: []byte(testPSK)},
+	}
+	c, scheme := newTestClient(t, server, active, revoked, invited, suspended, other, psk)
+	r := &VPNClientReconciler{Client: c, Scheme: scheme}
+
+	updated := reconcileServer(t, r, c, server)
+	config, data := renderedConfig(t, c, server)
+	want := `[Interface]
+ListenPort = 51820
+
+[Peer]
+# desktop
+PublicKey = ` + testKeyC + `
+
+[Peer]
+# laptop
+PublicKey = ` + testKeyA + `
+PresharedKey = ` + testPSK + `
+AllowedIPs = 10.8.0.2/32, 192.168.10.0/24
+`
+	if config != want {
+		t.Errorf("config =\n%s\nwant\n%s", config, want)
+	}
+	if strings.Contains(config, "PrivateKey") {
+		t.Error("config holds a private key")
+	}
+	files := map[string][]byte{"wg0.conf": []byte(config)}
+	for key, value := range data {
+		files[key] = []byte(value)
+	}
+	if updated.Status.ConfigChecksum != renderedChecksum(files) {
+		t.Errorf("config checksum = %q, want the checksum of the rendered files", updated.Status.ConfigChecksum)
+	}
+}
+
+func TestVPNClientReconcilerWaitsForPresharedKey(t *testing.T) {
+	server := testServer("edge")
+	peer := testPeer("laptop", "edge", testKeyA, "10.8.0.2")
+	peer.Spec.PresharedKey = &vpnv1alpha1.PresharedKeyGeneration{}
+	c, scheme := newTestClient(t, server, peer)
+	r := &VPNClientReconciler{Client: c, Scheme: scheme}
+
+	reconcileServer(t, r, c, server)
+	if config, _ := renderedConfig(t, c, server); strings.Contains(config, testKeyA) {
+		t.Errorf("peer without its preshared key was rendered:\n%s", config)
+	}
+}
+
+func TestVPNClientReconcilerRendersIsolationExceptions(t *testing.T) {
+	server := testServer("edge")
+	server.Spec.ClientIsolation = true
+	server.Spec.IsolationExceptions = []string{"ops"}
+	admin := testPeer("admin", "edge", testKeyA, "10.8.0.2")
+	admin.Spec.Group = "ops"
+	guest := testPeer("guest", "edge", testKeyB, "10.8.0.3")
+	guest.Spec.Group = "guests"
+	c, scheme := newTestClient(t, server, admin, guest)
+	r := &VPNClientReconciler{Client: c, Scheme: scheme}
+
+	reconcileServer(t, r, c, server)
+	if _, files := renderedConfig(t, c, server); files[isolationConfigKey] != "ops 10.8.0.2/32\n" {
+		t.Errorf("isolation = %q, want the exception of ops", files[isolationConfigKey])
+	}
+}
+
+func TestVPNClientReconcilerRendersIngressMaps(t *testing.T) {
+	server := testServer("edge")
+	ready := &vpnv1alpha1.VPNIngressMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "grafana", Generation: 2},
+		Spec: vpnv1alpha1.VPNIngressMapSpec{
+			ServerRef: vpnv1alpha1.LocalObjectReference{Name: "edge"},
+			PeerRef:   &vpnv1alpha1.LocalObjectReference{Name: "laptop"},
+			Service:   vpnv1alpha1.IngressMapService{Name: "grafana", Port: 3000},
+		},
+		Status: vpnv1alpha1.VPNIngressMapStatus{
+			Target:     "10.8.0.2:8080",
+			Conditions: []vpnv1alpha1.Condition{{Type: vpnv1alpha1.ConditionReady, Status: vpnv1alpha1.ConditionTrue, ObservedGeneration: 2}},
+		},
+	}
+	stale := ready.DeepCopy()
+	stale.Name = "stale"
+	stale.Generation = 3
+	c, scheme := newTestClient(t, server, ready, stale)
+	r := &VPNClientReconciler{Client: c, Scheme: scheme}
+
+	reconcileServer(t, r, c, server)
+	if _, files := renderedConfig(t, c, server); files[ingressConfigKey] != "cluster tcp 3000 10.8.0.2 8080\n" {
+		t.Errorf("ingress = %q, want the ready map only", files[ingressConfigKey])
+	}
+}
+
+func TestVPNClientReconcilerRendersSplitDNS(t *testing.T) {
+	server := testServer("edge")
+	server.Spec.ClientRoutingProfiles = []vpnv1alpha1.ClientRoutingProfile{{
+		Name:       "corp-only",
+		AllowedIPs: []string{"10.20.0.0/16"},
+		DNSZones:   []vpnv1alpha1.DNSZone{{Domain: "corp.example.com", Servers: []string{"10.20.0.53"}}},
+	}}
+	c, scheme := newTestClient(t, server)
+	r := &VPNClientReconciler{Client: c, Scheme: scheme}
+
+	reconcileServer(t, r, c, server)
+	if _, files := renderedConfig(t, c, server); !strings.Contains(files[splitDNSConfigKey], "server=/corp.example.com/10.20.0.53\n") {
+		t.Errorf("split-dns = %q, want the zone of corp-only forwarded", files[splitDNSConfigKey])
+	}
+}
+
+func TestVPNClientReconcilerRendersShardAssignments(t *testing.T) {
+	server := testServer("edge")
+	server.Spec.DeploymentMode = vpnv1alpha1.DeploymentModeStatefulSet
+	server.Spec.Sharding = &vpnv1alpha1.ShardingSpec{}
+	c, scheme := newTestClient(t, server, testPeer("laptop", "edge", testKeyA, "10.8.0.2"))
+	r := &VPNClientReconciler{Client: c, Scheme: scheme}
+
+	reconcileServer(t, r, c, server)
+	want := fmt.Sprintf("%d %s\n", rendezvousShard(testKeyA, 2), testKeyA)
+	if _, files := renderedConfig(t, c, server); files[shardsConfigKey] != want {
+		t.Errorf("shards = %q, want %q", files[shardsConfigKey], want)
+	}
+}
+
+func TestVPNClientReconcilerRendersBandwidthLimits(t *testing.T) {
+	server := testServer("edge")
+	limited := testPeer("laptop", "edge", testKeyA, "10.8.0.2")
+	limited.Spec.Bandwidth = &vpnv1alpha1.PeerBandwidth{Rate: resource.MustParse("8M")}
+	unlimited := testPeer("phone", "edge", testKeyB, "10.8.0.3")
+	c, scheme := newTestClient(t, server, limited, unlimited)
+	r := &VPNClientReconciler{Client: c, Scheme: scheme}
+
+	reconcileServer(t, r, c, server)
+	if _, files := renderedConfig(t, c, server); files[bandwidthConfigKey] != "10.8.0.2/32 8000000 100000\n" {
+		t.Errorf("bandwidth = %q, want the limit of laptop only", files[bandwidthConfigKey])
+	}
+}
+
+func TestVPNClientReconcilerRendersFirewallRules(t *testing.T) {
+	server := testServer("edge")
+	server.Spec.Firewall = &vpnv1alpha1.FirewallPolicy{
+		DefaultAction: vpnv1alpha1.FirewallDeny,
+		Rules: []vpnv1alpha1.FirewallRule{{
+			Name:         "https",
+			Action:       vpnv1alpha1.FirewallAllow,
+			Destinations: []string{"10.96.0.0/12"},
+			Protocol:     "TCP",
+			Ports:        []string{"443"},
+		}},
+	}
+	c, scheme := newTestClient(t, server)
+	r := &VPNClientReconciler{Client: c, Scheme: scheme}
+
+	reconcileServer(t, r, c, server)
+	want := "allow tcp - 10.96.0.0/12 443 https\ndefault deny\n"
+	if _, files := renderedConfig(t, c, server); files[firewallConfigKey] != want {
+		t.Errorf("firewall = %q, want %q", files[firewallConfigKey], want)
+	}
+}
@@ -0,0 +1,342 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// ingressConfigKey is the key of the rendered config ConfigMap holding the
+// ingress maps read by the agent
+const ingressConfigKey = "ingress"
+
+// VPNIngressMapReconciler resolves the target of ingress maps and creates
+// the Services of the peers they expose. The DNAT rules are programmed by
+// the agent of the server from the maps rendered by renderIngressMaps.
+type VPNIngressMapReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpningressmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpningressmaps/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch
+
+// Reconcile resolves the target of an ingress map. Maps whose references do
+// not exist are not Ready and re-resolved when the referenced objects are
+// created.
+func (r *VPNIngressMapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ingress := &vpnv1alpha1.VPNIngressMap{}
+	if err := r.Get(ctx, req.NamespacedName, ingress); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !ingress.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionReady,
+		Status:             vpnv1alpha1.ConditionFalse,
+		ObservedGeneration: ingress.Generation,
+	}
+	target, err := r.resolve(ctx, ingress, &condition)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if condition.Reason == "" {
+		condition.Status = vpnv1alpha1.ConditionTrue
+		condition.Reason = "Programmed"
+		condition.Message = fmt.Sprintf("forwarding to %s", target)
+	} else {
+		target = ""
+	}
+
+	ingress.Status.ObservedGeneration = ingress.Generation
+	ingress.Status.Target = target
+	vpnv1alpha1.SetCondition(&ingress.Status.Conditions, condition)
+	return ctrl.Result{}, r.Status().Update(ctx, ingress)
+}
+
+// resolve returns the target of an ingress map, or sets the reason it
+// cannot be programmed on condition.
+func (r *VPNIngressMapReconciler) resolve(ctx context.Context, ingress *vpnv1alpha1.VPNIngressMap, condition *vpnv1alpha1.Condition) (string, error) {
+	server := &vpnv1alpha1.VPNServer{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: ingress.Namespace, Name: ingress.Spec.ServerRef.Name}, server)
+	if apierrors.IsNotFound(err) {
+		condition.Reason = "MissingRef"
+		condition.Message = fmt.Sprintf("VPNServer %q does not exist", ingress.Spec.ServerRef.Name)
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	conflict, err := r.conflictingMap(ctx, ingress)
+	if err != nil {
+		return "", err
+	}
+	if conflict != "" {
+		condition.Reason = "PortConflict"
+		condition.Message = fmt.Sprintf("VPNIngressMap %q already forwards %s port %d", conflict, ingressProtocol(ingress), ingressListenPort(ingress))
+		return "", nil
+	}
+
+	if ingressDirection(ingress) == vpnv1alpha1.IngressMapToPeers {
+		return r.resolveService(ctx, ingress, condition)
+	}
+
+	// The Service of the peer selects the server pods, so it must not
+	// capture the WireGuard ports of the server
+	if ingressProtocol(ingress) == vpnv1alpha1.IngressMapUDP && server.Spec.ListensOn(ingress.Spec.Service.Port) {
+		condition.Reason = "PortConflict"
+		condition.Message = fmt.Sprintf("port %d is a WireGuard listen port of the server", ingress.Spec.Service.Port)
+		return "", nil
+	}
+	if ingress.Spec.PeerRef == nil {
+		condition.Reason = "MissingPeerRef"
+		condition.Message = "spec.peerRef is required with direction FromPeer"
+		return "", nil
+	}
+	peer := &vpnv1alpha1.VPNPeer{}
+	err = r.Get(ctx, types.NamespacedName{Namespace: ingress.Namespace, Name: ingress.Spec.PeerRef.Name}, peer)
+	if apierrors.IsNotFound(err) {
+		condition.Reason = "MissingRef"
+		condition.Message = fmt.Sprintf("VPNPeer %q does not exist", ingress.Spec.PeerRef.Name)
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if peer.Spec.ServerRef.Name != server.Name {
+		condition.Reason = "PeerNotOnServer"
+		condition.Message = fmt.Sprintf("VPNPeer %q is bound to VPNServer %q", peer.Name, peer.Spec.ServerRef.Name)
+		return "", nil
+	}
+	address := hostCIDR(peer.Spec.Address)
+	if address == "" {
+		condition.Reason = "NoPeerAddress"
+		condition.Message = fmt.Sprintf("VPNPeer %q has no tunnel address", peer.Name)
+		return "", nil
+	}
+
+	if ok, err := r.reconcileService(ctx, ingress, server); err != nil || !ok {
+		if err == nil {
+			condition.Reason = "ServiceExists"
+			condition.Message = fmt.Sprintf("Service %q exists and is not managed by the map", ingress.Spec.Service.Name)
+		}
+		return "", err
+	}
+	ip, _, _ := strings.Cut(address, "/")
+	return net.JoinHostPort(ip, strconv.Itoa(int(ingress.Spec.TunnelPort))), nil
+}
+
+// resolveService returns the cluster IP and port of the Service published
+// to peers.
+func (r *VPNIngressMapReconciler) resolveService(ctx context.Context, ingress *vpnv1alpha1.VPNIngressMap, condition *vpnv1alpha1.Condition) (string, error) {
+	svc := &corev1.Service{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: ingress.Namespace, Name: ingress.Spec.Service.Name}, svc)
+	if apierrors.IsNotFound(err) {
+		condition.Reason = "MissingService"
+		condition.Message = fmt.Sprintf("Service %q does not exist", ingress.Spec.Service.Name)
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == corev1.ClusterIPNone {
+		condition.Reason = "NoClusterIP"
+		condition.Message = fmt.Sprintf("Service %q has no cluster IP", svc.Name)
+		return "", nil
+	}
+	for _, port := range svc.Spec.Ports {
+		if port.Port == ingress.Spec.Service.Port && string(port.Protocol) == string(ingressProtocol(ingress)) {
+			return net.JoinHostPort(svc.Spec.ClusterIP, strconv.Itoa(int(port.Port))), nil
+		}
+	}
+	condition.Reason = "MissingServicePort"
+	condition.Message = fmt.Sprintf("Service %q has no %s port %d", svc.Name, ingressProtocol(ingress), ingress.Spec.Service.Port)
+	return "", nil
+}
+
+// reconcileService creates or updates the Service exposing the peer of a
+// map. It selects the server pods, whose agent forwards the port to the
+// peer. It returns false if a Service of that name is not the map's.
+func (r *VPNIngressMapReconciler) reconcileService(ctx context.Context, ingress *vpnv1alpha1.VPNIngressMap, server *vpnv1alpha1.VPNServer) (bool, error) {
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: ingress.Namespace, Name: ingress.Spec.Service.Name}}
+	err := r.Get(ctx, client.ObjectKeyFromObject(svc), svc)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, err
+	}
+	if err == nil && !isOwnedBy(svc, ingress) {
+		return false, nil
+	}
+
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, svc, func() error {
+		svc.Labels = serverLabels(server)
+		propagateMetadata(server, svc)
+		svc.Spec.Selector = serverLabels(server)
+		protocol := corev1.Protocol(ingressProtocol(ingress))
+		svc.Spec.Ports = []corev1.ServicePort{{
+			Name:       strings.ToLower(string(protocol)),
+			Protocol:   protocol,
+			Port:       ingress.Spec.Service.Port,
+			TargetPort: intstr.FromInt32(ingress.Spec.Service.Port),
+		}}
+		return controllerutil.SetControllerReference(ingress, svc, r.Scheme)
+	})
+	return err == nil, err
+}
+
+// conflictingMap returns the name of an older map of the same server that
+// forwards the same port on the same side, if any.
+func (r *VPNIngressMapReconciler) conflictingMap(ctx context.Context, ingress *vpnv1alpha1.VPNIngressMap) (string, error) {
+	maps := &vpnv1alpha1.VPNIngressMapList{}
+	if err := r.List(ctx, maps, client.InNamespace(ingress.Namespace)); err != nil {
+		return "", err
+	}
+	sort.Slice(maps.Items, func(i, j int) bool { return ingressMapBefore(&maps.Items[i], &maps.Items[j]) })
+	for i := range maps.Items {
+		other := &maps.Items[i]
+		if other.Name == ingress.Name {
+			return "", nil
+		}
+		if other.Spec.ServerRef.Name == ingress.Spec.ServerRef.Name && other.DeletionTimestamp.IsZero() &&
+			ingressDirection(other) == ingressDirection(ingress) && ingressProtocol(other) == ingressProtocol(ingress) &&
+			ingressListenPort(other) == ingressListenPort(ingress) {
+			return other.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// ingressMapBefore orders maps by age, then name, so that the oldest of
+// conflicting maps wins.
+func ingressMapBefore(a, b *vpnv1alpha1.VPNIngressMap) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.Name < b.Name
+}
+
+func ingressDirection(ingress *vpnv1alpha1.VPNIngressMap) vpnv1alpha1.IngressMapDirection {
+	if ingress.Spec.Direction == "" {
+		return vpnv1alpha1.IngressMapFromPeer
+	}
+	return ingress.Spec.Direction
+}
+
+func ingressProtocol(ingress *vpnv1alpha1.VPNIngressMap) vpnv1alpha1.IngressMapProtocol {
+	if ingress.Spec.Protocol == "" {
+		return vpnv1alpha1.IngressMapTCP
+	}
+	return ingress.Spec.Protocol
+}
+
+// ingressListenPort returns the port the agent forwards: the Service port
+// on the cluster side with FromPeer, the tunnel port with ToPeers.
+func ingressListenPort(ingress *vpnv1alpha1.VPNIngressMap) int32 {
+	if ingressDirection(ingress) == vpnv1alpha1.IngressMapToPeers {
+		return ingress.Spec.TunnelPort
+	}
+	return ingress.Spec.Service.Port
+}
+
+// renderIngressMaps renders the ingress maps of a server for the agent: one
+// line per map ready to be programmed, holding the side traffic arrives on,
+// the protocol and port, and the target address and port.
+func renderIngressMaps(server *vpnv1alpha1.VPNServer, maps []vpnv1alpha1.VPNIngressMap) string {
+	var lines []string
+	for i := range maps {
+		ingress := &maps[i]
+		if ingress.Spec.ServerRef.Name != server.Name || !ingress.DeletionTimestamp.IsZero() || ingress.Status.Target == "" ||
+			!vpnv1alpha1.IsConditionCurrent(ingress.Status.Conditions, vpnv1alpha1.ConditionReady, ingress.Generation) {
+			continue
+		}
+		host, port, err := net.SplitHostPort(ingress.Status.Target)
+		if err != nil {
+			continue
+		}
+		side := "cluster"
+		if ingressDirection(ingress) == vpnv1alpha1.IngressMapToPeers {
+			side = "tunnel"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %d %s %s", side, strings.ToLower(string(ingressProtocol(ingress))), ingressListenPort(ingress), host, port))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// serverForIngressMap maps a VPNIngressMap to the server it references.
+func serverForIngressMap(_ context.Context, obj client.Object) []reconcile.Request {
+	ingress := obj.(*vpnv1alpha1.VPNIngressMap)
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: ingress.Namespace, Name: ingress.Spec.ServerRef.Name}}}
+}
+
+// mapsForServer maps a VPNServer to the ingress maps referencing it.
+func (r *VPNIngressMapReconciler) mapsForServer(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.mapsMatching(ctx, obj.GetNamespace(), func(ingress *vpnv1alpha1.VPNIngressMap) bool {
+		return ingress.Spec.ServerRef.Name == obj.GetName()
+	})
+}
+
+// mapsForPeer maps a VPNPeer to the ingress maps exposing it.
+func (r *VPNIngressMapReconciler) mapsForPeer(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.mapsMatching(ctx, obj.GetNamespace(), func(ingress *vpnv1alpha1.VPNIngressMap) bool {
+		return ingress.Spec.PeerRef != nil && ingress.Spec.PeerRef.Name == obj.GetName()
+	})
+}
+
+// mapsForService maps a Service to the ingress maps publishing it.
+func (r *VPNIngressMapReconciler) mapsForService(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.mapsMatching(ctx, obj.GetNamespace(), func(ingress *vpnv1alpha1.VPNIngressMap) bool {
+		return ingress.Spec.Service.Name == obj.GetName()
+	})
+}
+
+func (r *VPNIngressMapReconciler) mapsMatching(ctx context.Context, namespace string, match func(*vpnv1alpha1.VPNIngressMap) bool) []reconcile.Request {
+	maps := &vpnv1alpha1.VPNIngressMapList{}
+	if err := r.List(ctx, maps, client.InNamespace(namespace)); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list ingress maps", "namespace", namespace)
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range maps.Items {
+		if match(&maps.Items[i]) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&maps.Items[i])})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VPNIngressMapReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vpnv1alpha1.VPNIngressMap{}).
+		Watches(&vpnv1alpha1.VPNServer{}, handler.EnqueueRequestsFromMapFunc(r.mapsForServer)).
+		Watches(&vpnv1alpha1.VPNPeer{}, handler.EnqueueRequestsFromMapFunc(r.mapsForPeer)).
+		Watches(&corev1.Service{}, handler.EnqueueRequestsFromMapFunc(r.mapsForService)).
+		Complete(instrument(mgr, "vpningressmap", &vpnv1alpha1.VPNIngressMap{}, r))
+}
@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	// poolSampleInterval is the minimum time between allocation samples
+	poolSampleInterval = time.Hour
+
+	defaultExhaustionThresholdDays = 14
+	defaultExhaustionWindow        = 7 * 24 * time.Hour
+)
+
+// VPNIPPoolReconciler tracks the allocations of IP pools, predicts when
+// they run out of addresses and expands them to their secondary CIDRs.
+type VPNIPPoolReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnippools,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnippools/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile updates the allocation statistics and exhaustion prediction of
+// a pool.
+func (r *VPNIPPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	pool := &vpnv1alpha1.VPNIPPool{}
+	if err := r.Get(ctx, req.NamespacedName, pool); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !pool.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	exhaustion := pool.Spec.Exhaustion
+	if exhaustion == nil {
+		exhaustion = &vpnv1alpha1.ExhaustionSpec{}
+	}
+	threshold := int32(defaultExhaustionThresholdDays)
+	if exhaustion.ThresholdDays > 0 {
+		threshold = exhaustion.ThresholdDays
+	}
+	window := defaultExhaustionWindow
+	if exhaustion.Window != nil {
+		window = exhaustion.Window.Duration
+	}
+
+	cidrs := activeCIDRs(pool)
+	allocated, err := r.allocated(ctx, pool.Namespace, cidrs)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	now := time.Now()
+	pool.Status.History = recordSample(pool.Status.History, allocated, now, window)
+	rate := allocationRate(pool.Status.History)
+
+	capacity := poolCapacity(cidrs)
+	days := daysUntilExhaustion(capacity-allocated, rate)
+	nearlyExhausted := allocated >= capacity || days != nil && *days <= threshold
+
+	if nearlyExhausted && exhaustion.AutoExpand {
+		if next := nextSecondaryCIDR(pool, cidrs); next != "" {
+			cidrs = append(cidrs, next)
+			capacity = poolCapacity(cidrs)
+			days = daysUntilExhaustion(capacity-allocated, rate)
+			nearlyExhausted = allocated >= capacity || days != nil && *days <= threshold
+			logger.Info("expanded pool", "cidr", next)
+			r.Recorder.Eventf(pool, corev1.EventTypeNormal, "PoolExpanded", "Expanded pool to secondary CIDR %s", next)
+		}
+	}
+
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionPoolNearlyExhausted,
+		Status:             vpnv1alpha1.ConditionFalse,
+		Reason:             "SufficientCapacity",
+		ObservedGeneration: pool.Generation,
+	}
+	switch {
+	case allocated >= capacity:
+		condition.Status = vpnv1alpha1.ConditionTrue
+		condition.Reason = "Exhausted"
+		condition.Message = fmt.Sprintf("all %d addresses are allocated", capacity)
+	case nearlyExhausted:
+		condition.Status = vpnv1alpha1.ConditionTrue
+		condition.Reason = "ExhaustionPredicted"
+		condition.Message = fmt.Sprintf("%d of %d addresses left, exhausted in %d days at %.2f allocations per day",
+			capacity-allocated, capacity, *days, rate)
+	}
+	if condition.Status == vpnv1alpha1.ConditionTrue &&
+		!vpnv1alpha1.IsConditionTrue(pool.Status.Conditions, vpnv1alpha1.ConditionPoolNearlyExhausted) {
+		r.Recorder.Event(pool, corev1.EventTypeWarning, "PoolNearlyExhausted", condition.Message)
+	}
+	vpnv1alpha1.SetCondition(&pool.Status.Conditions, condition)
+
+	pool.Status.CIDRs = cidrs
+	pool.Status.Capacity = capacity
+	pool.Status.Allocated = allocated
+	pool.Status.AllocationsPerDay = strconv.FormatFloat(rate, 'f', 2, 64)
+	pool.Status.DaysUntilExhaustion = days
+	pool.Status.ObservedGeneration = pool.Generation
+	if err := r.Status().Update(ctx, pool); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: poolSampleInterval}, nil
+}
+
+// activeCIDRs returns the networks addresses are allocated from: the
+// primary CIDR and the secondary CIDRs the pool expanded to that are still
+// listed in the spec.
+func activeCIDRs(pool *vpnv1alpha1.VPNIPPool) []string {
+	cidrs := []string{pool.Spec.CIDR}
+	for _, cidr := range pool.Status.CIDRs {
+		for _, secondary := range pool.Spec.SecondaryCIDRs {
+			if cidr == secondary && cidr != pool.Spec.CIDR {
+				cidrs = append(cidrs, cidr)
+				break
+			}
+		}
+	}
+	return cidrs
+}
+
+// nextSecondaryCIDR returns the first secondary CIDR the pool has not
+// expanded to yet.
+func nextSecondaryCIDR(pool *vpnv1alpha1.VPNIPPool, cidrs []string) string {
+	for _, secondary := range pool.Spec.SecondaryCIDRs {
+		active := false
+		for _, cidr := range cidrs {
+			if cidr == secondary {
+				active = true
+				break
+			}
+		}
+		if !active {
+			return secondary
+		}
+	}
+	return ""
+}
+
+// allocated returns the number of peers of the namespace addressed from the
+// networks.
+func (r *VPNIPPoolReconciler) allocated(ctx context.Context, namespace string, cidrs []string) (int64, error) {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			networks = append(networks, network)
+		}
+	}
+
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(namespace)); err != nil {
+		return 0, err
+	}
+	var allocated int64
+	for _, peer := range peers.Items {
+		ip := net.ParseIP(strings.SplitN(peer.Spec.Address, "/", 2)[0])
+		if ip == nil {
+			continue
+		}
+		for _, network := range networks {
+			if network.Contains(ip) {
+				allocated++
+				break
+			}
+		}
+	}
+	return allocated, nil
+}
+
+// poolCapacity returns the number of host addresses of the networks.
+func poolCapacity(cidrs []string) int64 {
+	var capacity int64
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		ones, bits := network.Mask.Size()
+		hostBits := bits - ones
+		if hostBits >= 62 {
+			return math.MaxInt64
+		}
+		hosts := int64(1) << hostBits
+		// Exclude the network and broadcast addresses
+		if bits == 32 && hostBits >= 2 {
+			hosts -= 2
+		}
+		capacity += hosts
+	}
+	return capacity
+}
+
+// recordSample appends a sample to history when the last one is older than
+// the sample interval, and drops samples that left the window.
+func recordSample(history []vpnv1alpha1.AllocationSample, allocated int64, now time.Time, window time.Duration) []vpnv1alpha1.AllocationSample {
+	if len(history) == 0 || now.Sub(history[len(history)-1].Time.Time) >= poolSampleInterval {
+		history = append(history, vpnv1alpha1.AllocationSample{Time: metav1.NewTime(now), Allocated: allocated})
+	}
+	first := 0
+	for first < len(history)-1 && now.Sub(history[first].Time.Time) > window {
+		first++
+	}
+	return history[first:]
+}
+
+// allocationRate returns the allocations per day between the oldest and
+// newest samples.
+func allocationRate(history []vpnv1alpha1.AllocationSample) float64 {
+	if len(history) < 2 {
+		return 0
+	}
+	oldest, newest := history[0], history[len(history)-1]
+	days := newest.Time.Sub(oldest.Time.Time).Hours() / 24
+	if days <= 0 {
+		return 0
+	}
+	return float64(newest.Allocated-oldest.Allocated) / days
+}
+
+// daysUntilExhaustion returns the number of days until free addresses run
+// out at rate, or nil if allocations do not grow.
+func daysUntilExhaustion(free int64, rate float64) *int32 {
+	if rate <= 0 {
+		return nil
+	}
+	days := int32(math.Min(float64(free)/rate, math.MaxInt32))
+	if days < 0 {
+		days = 0
+	}
+	return &days
+}
+
+// poolsForPeer maps a VPNPeer to the pools of its namespace.
+func (r *VPNIPPoolReconciler) poolsForPeer(ctx context.Context, obj client.Object) []reconcile.Request {
+	pools := &vpnv1alpha1.VPNIPPoolList{}
+	if err := r.List(ctx, pools, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list pools", "namespace", obj.GetNamespace())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(pools.Items))
+	for _, pool := range pools.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&pool)})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VPNIPPoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vpnv1alpha1.VPNIPPool{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&vpnv1alpha1.VPNPeer{}, handler.EnqueueRequestsFromMapFunc(r.poolsForPeer)).
+		Complete(instrument(mgr, "vpnippool", &vpnv1alpha1.VPNIPPool{}, r))
+}
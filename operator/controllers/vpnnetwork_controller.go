@@ -0,0 +1,741 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	// networkFinalizer makes sure the link peers of a network are removed
+	// from every cluster, which owner references cannot do across clusters
+	networkFinalizer = "vpn.vpn-devops.com/network"
+
+	// defaultNetworkKeepalive is the keepalive interval of the links of
+	// networks that do not set one
+	defaultNetworkKeepalive = 25
+
+	// linkVerificationTimeout is how long the links changed by a change of
+	// a network have to handshake in both directions before the change is
+	// rolled back
+	linkVerificationTimeout = 3 * time.Minute
+
+	// linkVerificationInterval is how often a change being verified is
+	// checked, besides the updates of the member servers
+	linkVerificationInterval = 15 * time.Second
+
+	// networkRetryInterval is after how long a change rolled back is
+	// applied again if neither the network nor its servers changed
+	networkRetryInterval = 10 * time.Minute
+)
+
+// VPNNetworkReconciler links the member servers of VPNNetworks as a full
+// mesh or hub and spoke. Each side of a link is a VPNPeer on the server,
+// named after the network and the server on the other side, holding the
+// public key, endpoint and networks of that server. The peers of servers
+// placed on member clusters are created there, in the hub mode.
+//
+// The link peers of a network are changed as a whole: the change is
+// verified by a handshake in both directions of every changed link, and
+// rolled back on every member if a peer cannot be applied or a link does
+// not handshake in time, so that a partial failure never leaves the mesh
+// asymmetric. The link peers of removed links are pruned once the change
+// is committed.
+//
+// The status summarizes the health of the mesh from the peer stats of the
+// servers: the links whose servers handshake in both directions, and their
+// round-trip times. The network is only ready with every link established.
+type VPNNetworkReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// Clusters are the member clusters of an operator in the hub mode, nil
+	// in the standalone mode
+	Clusters *ClusterSet
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnnetworks,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnnetworks/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnnetworks/finalizers,verbs=update
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// networkTarget is the cluster and namespace the link peers of a member
+// server are written to
+type networkTarget struct {
+	client    client.Client
+	namespace string
+}
+
+// networkMember is a member server of a network being linked
+type networkMember struct {
+	status vpnv1alpha1.VPNNetworkMemberStatus
+	target networkTarget
+	usable bool
+
+	// address is the host route of the tunnel address of the server
+	address string
+
+	// peers are the peer stats of the server by public key
+	peers map[string]vpnv1alpha1.PeerStatus
+}
+
+// linkPeer is the link peer standing for the remote server of a link on
+// the local one
+type linkPeer struct {
+	local, remote *networkMember
+	name          string
+	allowedIPs    []string
+	keepalive     int32
+}
+
+// Reconcile applies the link peers of a network and prunes those of links
+// that no longer exist.
+func (r *VPNNetworkReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	network := &vpnv1alpha1.VPNNetwork{}
+	if err := r.Get(ctx, req.NamespacedName, network); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !network.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(network, networkFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		pending, err := r.prune(ctx, network, nil)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if pending {
+			// Removed once the member cluster is reachable again
+			return ctrl.Result{RequeueAfter: federationSyncInterval}, nil
+		}
+		controllerutil.RemoveFinalizer(network, networkFinalizer)
+		return ctrl.Result{}, r.Update(ctx, network)
+	}
+	if controllerutil.AddFinalizer(network, networkFinalizer) {
+		if err := r.Update(ctx, network); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	original := network.DeepCopy()
+
+	members := make([]*networkMember, 0, len(network.Spec.Members))
+	for _, spec := range network.Spec.Members {
+		member, err := r.member(ctx, network, spec)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		members = append(members, member)
+	}
+
+	// Overlapping networks would be routed to two servers at once
+	for i, a := range members {
+		for _, b := range members[i+1:] {
+			if overlap := overlappingNetwork(a.status.Networks, b.status.Networks); overlap != "" && a.usable && b.usable {
+				for _, m := range []*networkMember{a, b} {
+					m.usable = false
+					m.status.Message = fmt.Sprintf("network %s overlaps the networks of %s and %s", overlap, a.status.Server, b.status.Server)
+				}
+			}
+		}
+	}
+
+	now := time.Now()
+	status := &network.Status
+	plan := networkLinkPeers(network, members)
+	checksum := linkPeersChecksum(plan)
+	desired := map[string]map[string]bool{}
+	var failed []string
+	var pending bool
+	if rolledBack := status.RolledBack; rolledBack != nil && rolledBack.Checksum == checksum && now.Before(rolledBack.Time.Add(networkRetryInterval)) {
+		// Finish rolling back on the clusters that were not reachable
+		var err error
+		if pending, err = r.rollback(ctx, network); err != nil {
+			return ctrl.Result{}, err
+		}
+	} else {
+		status.RolledBack = nil
+		changed := map[string]bool{}
+		linked := map[string]bool{}
+		for _, peer := range plan {
+			link := linkName(peer.local, peer.remote)
+			updated, err := r.applyLink(ctx, network, peer)
+			if err != nil {
+				peer.local.status.Message = err.Error()
+				failed = append(failed, err.Error())
+				linked[link] = false
+				continue
+			}
+			if _, seen := linked[link]; !seen {
+				linked[link] = true
+			}
+			if updated {
+				changed[link] = true
+			}
+			key := peer.local.status.ClusterName + "/" + peer.local.target.namespace
+			if desired[key] == nil {
+				desired[key] = map[string]bool{}
+			}
+			desired[key][peer.name] = true
+		}
+		status.Links = 0
+		for _, ok := range linked {
+			if ok {
+				status.Links++
+			}
+		}
+
+		switch {
+		case len(failed) > 0:
+			// A partial change would leave the links asymmetric
+			if _, err := r.rollback(ctx, network); err != nil {
+				return ctrl.Result{}, err
+			}
+			status.Change = nil
+			status.RolledBack = &vpnv1alpha1.VPNNetworkChange{Checksum: checksum, Time: metav1.NewTime(now), Message: strings.Join(failed, "; ")}
+		case len(changed) > 0:
+			if status.Change != nil {
+				for _, link := range status.Change.PendingLinks {
+					changed[link] = true
+				}
+			}
+			links := make([]string, 0, len(changed))
+			for link := range changed {
+				links = append(links, link)
+			}
+			sort.Strings(links)
+			status.Change = &vpnv1alpha1.VPNNetworkChange{Checksum: checksum, Time: metav1.NewTime(now), PendingLinks: links}
+		}
+	}
+
+	if change := status.Change; change != nil {
+		var unverified []string
+		for _, link := range change.PendingLinks {
+			if !linkHandshook(members, link, change.Time.Time) {
+				unverified = append(unverified, link)
+			}
+		}
+		change.PendingLinks = unverified
+		switch {
+		case len(unverified) == 0:
+			if err := r.commit(ctx, network); err != nil {
+				return ctrl.Result{}, err
+			}
+			status.Change = nil
+		case now.After(change.Time.Add(linkVerificationTimeout)):
+			if _, err := r.rollback(ctx, network); err != nil {
+				return ctrl.Result{}, err
+			}
+			status.Change = nil
+			status.RolledBack = &vpnv1alpha1.VPNNetworkChange{
+				Checksum: change.Checksum,
+				Time:     metav1.NewTime(now),
+				Message:  fmt.Sprintf("links %s did not handshake within %s", strings.Join(unverified, ", "), linkVerificationTimeout),
+			}
+		}
+	}
+	if status.Change == nil && status.RolledBack == nil {
+		var err error
+		if pending, err = r.prune(ctx, network, desired); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	status.LinkStatuses = nil
+	status.EstablishedLinks = 0
+	var down []string
+	for _, link := range networkLinks(network, members) {
+		if !link[0].usable || !link[1].usable {
+			continue
+		}
+		linkStatus := linkStatus(link[0], link[1], now)
+		if linkStatus.Established {
+			status.EstablishedLinks++
+		} else {
+			down = append(down, linkStatus.Name)
+		}
+		status.LinkStatuses = append(status.LinkStatuses, linkStatus)
+	}
+
+	var notReady []string
+	network.Status.Members = nil
+	for _, member := range members {
+		member.status.Ready = member.usable && member.status.Message == ""
+		if !member.status.Ready {
+			notReady = append(notReady, fmt.Sprintf("%s: %s", member.status.Server, member.status.Message))
+		}
+		network.Status.Members = append(network.Status.Members, member.status)
+	}
+	network.Status.ObservedGeneration = network.Generation
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionReady,
+		Status:             vpnv1alpha1.ConditionTrue,
+		Reason:             "Linked",
+		Message:            fmt.Sprintf("%d links established between %d servers", status.EstablishedLinks, len(members)),
+		ObservedGeneration: network.Generation,
+	}
+	switch {
+	case len(notReady) > 0:
+		condition.Status = vpnv1alpha1.ConditionFalse
+		condition.Reason = "MembersNotReady"
+		condition.Message = strings.Join(notReady, "; ")
+	case status.RolledBack != nil:
+		condition.Status = vpnv1alpha1.ConditionFalse
+		condition.Reason = "RolledBack"
+		condition.Message = rolledBackMessage(status.RolledBack)
+	case status.Change != nil:
+		condition.Status = vpnv1alpha1.ConditionFalse
+		condition.Reason = "VerifyingLinks"
+		condition.Message = fmt.Sprintf("waiting for links %s to handshake", strings.Join(status.Change.PendingLinks, ", "))
+	case len(down) > 0:
+		condition.Status = vpnv1alpha1.ConditionFalse
+		condition.Reason = "LinksDown"
+		condition.Message = fmt.Sprintf("links %s did not handshake in the last %s", strings.Join(down, ", "), handshakeFreshness)
+	}
+	vpnv1alpha1.SetCondition(&network.Status.Conditions, condition)
+	for _, message := range failed {
+		r.Recorder.Event(network, corev1.EventTypeWarning, "LinkFailed", message)
+	}
+	if rolledBack := status.RolledBack; rolledBack != nil && (original.Status.RolledBack == nil || !original.Status.RolledBack.Time.Equal(&rolledBack.Time)) {
+		r.Recorder.Event(network, corev1.EventTypeWarning, "RolledBack", rolledBackMessage(rolledBack))
+	}
+
+	// Member clusters are not watched, their servers are checked again
+	// along with the federation sync
+	var result ctrl.Result
+	for _, member := range members {
+		if member.status.ClusterName != "" || !member.usable {
+			result.RequeueAfter = federationSyncInterval
+		}
+	}
+	if pending {
+		result.RequeueAfter = federationSyncInterval
+	}
+	// Links go down without an update of their servers once their
+	// handshakes age
+	if status.EstablishedLinks > 0 && (result.RequeueAfter == 0 || handshakeFreshness < result.RequeueAfter) {
+		result.RequeueAfter = handshakeFreshness
+	}
+	if status.Change != nil {
+		result.RequeueAfter = linkVerificationInterval
+	} else if rolledBack := status.RolledBack; rolledBack != nil {
+		if retry := time.Until(rolledBack.Time.Add(networkRetryInterval)); result.RequeueAfter == 0 || retry < result.RequeueAfter {
+			result.RequeueAfter = retry
+		}
+	}
+	if equality.Semantic.DeepEqual(original.Status, network.Status) {
+		return result, nil
+	}
+	return result, r.Status().Patch(ctx, network, client.MergeFrom(original))
+}
+
+// member resolves a member server of a network: its key, endpoint and
+// networks, and the cluster its link peers are written to.
+func (r *VPNNetworkReconciler) member(ctx context.Context, network *vpnv1alpha1.VPNNetwork, spec vpnv1alpha1.VPNNetworkMember) (*networkMember, error) {
+	member := &networkMember{status: vpnv1alpha1.VPNNetworkMemberStatus{Server: spec.ServerRef.Name}}
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: network.Namespace, Name: spec.ServerRef.Name}, server); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		member.status.Message = fmt.Sprintf("VPNServer %s not found", spec.ServerRef.Name)
+		return member, nil
+	}
+	member.status.ClusterName = server.Spec.ClusterName
+	member.status.PublicKey = server.Status.PublicKey
+	member.status.Endpoint = server.Status.Endpoint
+	if ip, tunnel, err := net.ParseCIDR(server.Spec.Address); err == nil {
+		member.address = hostAddress(ip)
+		member.status.Networks = append(member.status.Networks, tunnel.String())
+	}
+	member.status.Networks = append(member.status.Networks, spec.Sites...)
+	member.peers = map[string]vpnv1alpha1.PeerStatus{}
+	for _, peer := range server.Status.Peers {
+		member.peers[peer.PublicKey] = peer
+	}
+
+	target, ok := r.clusterTarget(network, server.Spec.ClusterName)
+	switch {
+	case !ok && r.Clusters == nil:
+		member.status.Message = fmt.Sprintf("the server is placed on member cluster %s, which is only reached in the hub mode", server.Spec.ClusterName)
+	case !ok:
+		member.status.Message = fmt.Sprintf("member cluster %s is not connected", server.Spec.ClusterName)
+	case member.status.PublicKey == "":
+		member.status.Message = "the server has no public key yet"
+	case member.address == "":
+		member.status.Message = fmt.Sprintf("address %s of the server is not a network", server.Spec.Address)
+	default:
+		member.usable = true
+	}
+	member.target = target
+	return member, nil
+}
+
+// clusterTarget returns where the link peers of the servers on a cluster
+// are written: the namespace of the network, or the namespace managed on
+// the member cluster clusterName.
+func (r *VPNNetworkReconciler) clusterTarget(network *vpnv1alpha1.VPNNetwork, clusterName string) (networkTarget, bool) {
+	if clusterName == "" {
+		return networkTarget{client: r.Client, namespace: network.Namespace}, true
+	}
+	if r.Clusters == nil {
+		return networkTarget{}, false
+	}
+	member, ok := r.Clusters.get(types.NamespacedName{Namespace: network.Namespace, Name: clusterName})
+	if !ok {
+		return networkTarget{}, false
+	}
+	return networkTarget{client: member.client, namespace: member.namespace}, true
+}
+
+// networkLinks returns the pairs of members linked by the topology of a
+// network.
+func networkLinks(network *vpnv1alpha1.VPNNetwork, members []*networkMember) [][2]*networkMember {
+	var links [][2]*networkMember
+	if network.Spec.Topology == vpnv1alpha1.NetworkTopologyHubAndSpoke {
+		var hub *networkMember
+		for _, member := range members {
+			if member.status.Server == network.Spec.Hub {
+				hub = member
+			}
+		}
+		if hub == nil {
+			return nil
+		}
+		for _, member := range members {
+			if member != hub {
+				links = append(links, [2]*networkMember{hub, member})
+			}
+		}
+		return links
+	}
+	for i, a := range members {
+		for _, b := range members[i+1:] {
+			links = append(links, [2]*networkMember{a, b})
+		}
+	}
+	return links
+}
+
+// networkLinkPeers returns the link peers of the links between the usable
+// members of a network, both sides of each link in turn. With the hub and
+// spoke topology, the peer of the hub on a spoke also routes the networks
+// of the other spokes.
+func networkLinkPeers(network *vpnv1alpha1.VPNNetwork, members []*networkMember) []linkPeer {
+	keepalive := network.Spec.PersistentKeepalive
+	if keepalive == 0 {
+		keepalive = defaultNetworkKeepalive
+	}
+	var peers []linkPeer
+	for _, link := range networkLinks(network, members) {
+		a, b := link[0], link[1]
+		if !a.usable || !b.usable {
+			continue
+		}
+		for _, side := range [][2]*networkMember{{a, b}, {b, a}} {
+			local, remote := side[0], side[1]
+			allowedIPs := append([]string(nil), remote.status.Networks...)
+			if network.Spec.Topology == vpnv1alpha1.NetworkTopologyHubAndSpoke && remote.status.Server == network.Spec.Hub {
+				for _, other := range members {
+					if other != local && other != remote && other.usable {
+						allowedIPs = append(allowedIPs, other.status.Networks...)
+					}
+				}
+			}
+			peers = append(peers, linkPeer{
+				local:      local,
+				remote:     remote,
+				name:       network.Name + "-" + remote.status.Server,
+				allowedIPs: allowedIPs,
+				keepalive:  keepalive,
+			})
+		}
+	}
+	return peers
+}
+
+// linkPeersChecksum returns the checksum of the link peers of a network,
+// which identifies a change of its links.
+func linkPeersChecksum(peers []linkPeer) string {
+	lines := make([]string, 0, len(peers))
+	for _, peer := range peers {
+		lines = append(lines, strings.Join([]string{
+			peer.local.status.ClusterName, peer.local.target.namespace, peer.name, peer.local.status.Server,
+			peer.remote.status.PublicKey, peer.remote.address, strings.Join(peer.allowedIPs, ","),
+			peer.remote.status.Endpoint, strconv.Itoa(int(peer.keepalive)),
+		}, " "))
+	}
+	sort.Strings(lines)
+	return configChecksum([]byte(strings.Join(lines, "\n")))
+}
+
+// rolledBackMessage tells why a change of the links of a network was
+// rolled back.
+func rolledBackMessage(change *vpnv1alpha1.VPNNetworkChange) string {
+	return "the change of the links was rolled back: " + change.Message
+}
+
+// linkName returns the name of the link between two members in the status
+// of a network.
+func linkName(a, b *networkMember) string {
+	if b.status.Server < a.status.Server {
+		a, b = b, a
+	}
+	return a.status.Server + "/" + b.status.Server
+}
+
+// linkHandshook returns whether both servers of a link handshook with each
+// other since a time. Links of members no longer in the network have
+// nothing to verify.
+func linkHandshook(members []*networkMember, link string, since time.Time) bool {
+	names := strings.SplitN(link, "/", 2)
+	var a, b *networkMember
+	for _, member := range members {
+		switch member.status.Server {
+		case names[0]:
+			a = member
+		case names[len(names)-1]:
+			b = member
+		}
+	}
+	if a == nil || b == nil {
+		return true
+	}
+	handshook := func(local, remote *networkMember) bool {
+		handshake := local.peers[remote.status.PublicKey].LatestHandshake
+		return handshake != nil && !handshake.Time.Before(since.Truncate(time.Second))
+	}
+	return handshook(a, b) && handshook(b, a)
+}
+
+// linkStatus returns the state of the link between two members from the
+// peer stats of both.
+func linkStatus(a, b *networkMember, now time.Time) vpnv1alpha1.VPNNetworkLinkStatus {
+	status := vpnv1alpha1.VPNNetworkLinkStatus{Name: linkName(a, b)}
+	var handshakes []*metav1.Time
+	var roundTrip time.Duration
+	var samples int
+	for _, side := range [][2]*networkMember{{a, b}, {b, a}} {
+		peer := side[0].peers[side[1].status.PublicKey]
+		handshakes = append(handshakes, peer.LatestHandshake)
+		if peer.RoundTripTime != nil {
+			roundTrip += peer.RoundTripTime.Duration
+			samples++
+		}
+	}
+	if handshakes[0] != nil && handshakes[1] != nil {
+		older := handshakes[0]
+		if handshakes[1].Before(older) {
+			older = handshakes[1]
+		}
+		status.LatestHandshake = older.DeepCopy()
+		status.Established = now.Sub(older.Time) <= handshakeFreshness
+	}
+	if samples > 0 {
+		status.RoundTripTime = &metav1.Duration{Duration: (roundTrip / time.Duration(samples)).Round(time.Microsecond)}
+	}
+	return status
+}
+
+// applyLink creates or updates a link peer, and returns whether it
+// changed. The spec of the peer before the first change being verified is
+// recorded on it, for the change to be rolled back.
+func (r *VPNNetworkReconciler) applyLink(ctx context.Context, network *vpnv1alpha1.VPNNetwork, link linkPeer) (bool, error) {
+	local, remote := link.local, link.remote
+	peer := &vpnv1alpha1.VPNPeer{ObjectMeta: metav1.ObjectMeta{Namespace: local.target.namespace, Name: link.name}}
+	result, err := controllerutil.CreateOrUpdate(ctx, local.target.client, peer, func() error {
+		exists := peer.ResourceVersion != ""
+		if owner, ok := peer.Labels[vpnv1alpha1.NetworkLabel]; exists && (!ok || owner != network.Name) {
+			return fmt.Errorf("VPNPeer %s/%s exists and is not a link of network %s", peer.Namespace, peer.Name, network.Name)
+		}
+		previous := peer.Spec.DeepCopy()
+		if peer.Labels == nil {
+			peer.Labels = map[string]string{}
+		}
+		peer.Labels[vpnv1alpha1.NetworkLabel] = network.Name
+		peer.Labels[vpnv1alpha1.NetworkServerLabel] = remote.status.Server
+		peer.Spec.ServerRef = vpnv1alpha1.LocalObjectReference{Name: local.status.Server}
+		peer.Spec.PublicKey = remote.status.PublicKey
+		// The address of the remote server keeps the peer out of IPAM,
+		// its tunnel network is routed along with the sites
+		peer.Spec.Address = remote.address
+		peer.Spec.AllowedIPs = link.allowedIPs
+		peer.Spec.Endpoint = remote.status.Endpoint
+		peer.Spec.PersistentKeepalive = link.keepalive
+		if _, recorded := peer.Annotations[vpnv1alpha1.NetworkPreviousLinkAnnotation]; !recorded && !equality.Semantic.DeepEqual(previous, &peer.Spec) {
+			var record string
+			if exists {
+				raw, err := json.Marshal(previous)
+				if err != nil {
+					return err
+				}
+				record = string(raw)
+			}
+			if peer.Annotations == nil {
+				peer.Annotations = map[string]string{}
+			}
+			peer.Annotations[vpnv1alpha1.NetworkPreviousLinkAnnotation] = record
+		}
+		if local.status.ClusterName == "" {
+			return controllerutil.SetControllerReference(network, peer, r.Scheme)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("linking %s to %s: %w", local.status.Server, remote.status.Server, err)
+	}
+	return result != controllerutil.OperationResultNone, nil
+}
+
+// rollback restores the link peers of a network changed by its change
+// being verified to their previous spec, and deletes those it created. It
+// returns whether a cluster could not be reached.
+func (r *VPNNetworkReconciler) rollback(ctx context.Context, network *vpnv1alpha1.VPNNetwork) (bool, error) {
+	return r.eachLinkPeer(ctx, network, func(target networkTarget, _ string, peer *vpnv1alpha1.VPNPeer) error {
+		previous, ok := peer.Annotations[vpnv1alpha1.NetworkPreviousLinkAnnotation]
+		if !ok {
+			return nil
+		}
+		if previous == "" {
+			return client.IgnoreNotFound(target.client.Delete(ctx, peer))
+		}
+		spec := vpnv1alpha1.VPNPeerSpec{}
+		if err := json.Unmarshal([]byte(previous), &spec); err != nil {
+			return fmt.Errorf("previous spec of VPNPeer %s/%s: %w", peer.Namespace, peer.Name, err)
+		}
+		peer.Spec = spec
+		delete(peer.Annotations, vpnv1alpha1.NetworkPreviousLinkAnnotation)
+		return target.client.Update(ctx, peer)
+	})
+}
+
+// commit drops the previous specs recorded on the link peers of a network
+// once its change is verified.
+func (r *VPNNetworkReconciler) commit(ctx context.Context, network *vpnv1alpha1.VPNNetwork) error {
+	_, err := r.eachLinkPeer(ctx, network, func(target networkTarget, _ string, peer *vpnv1alpha1.VPNPeer) error {
+		if _, ok := peer.Annotations[vpnv1alpha1.NetworkPreviousLinkAnnotation]; !ok {
+			return nil
+		}
+		delete(peer.Annotations, vpnv1alpha1.NetworkPreviousLinkAnnotation)
+		return target.client.Update(ctx, peer)
+	})
+	return err
+}
+
+// prune deletes the link peers of a network not in desired, keyed by
+// cluster and namespace. With a nil desired every link peer is deleted. It
+// returns whether a cluster could not be reached.
+func (r *VPNNetworkReconciler) prune(ctx context.Context, network *vpnv1alpha1.VPNNetwork, desired map[string]map[string]bool) (bool, error) {
+	return r.eachLinkPeer(ctx, network, func(target networkTarget, clusterName string, peer *vpnv1alpha1.VPNPeer) error {
+		if desired[clusterName+"/"+target.namespace][peer.Name] {
+			return nil
+		}
+		return client.IgnoreNotFound(target.client.Delete(ctx, peer))
+	})
+}
+
+// eachLinkPeer calls fn with the link peers of a network on the clusters
+// of its current and previous members, and returns whether a cluster could
+// not be reached.
+func (r *VPNNetworkReconciler) eachLinkPeer(ctx context.Context, network *vpnv1alpha1.VPNNetwork, fn func(target networkTarget, clusterName string, peer *vpnv1alpha1.VPNPeer) error) (bool, error) {
+	clusters := map[string]bool{"": true}
+	for _, member := range network.Status.Members {
+		clusters[member.ClusterName] = true
+	}
+	for _, spec := range network.Spec.Members {
+		server := &vpnv1alpha1.VPNServer{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: network.Namespace, Name: spec.ServerRef.Name}, server); err == nil {
+			clusters[server.Spec.ClusterName] = true
+		}
+	}
+	names := make([]string, 0, len(clusters))
+	for name := range clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pending := false
+	for _, clusterName := range names {
+		target, ok := r.clusterTarget(network, clusterName)
+		if !ok {
+			pending = true
+			continue
+		}
+		peers := &vpnv1alpha1.VPNPeerList{}
+		if err := target.client.List(ctx, peers, client.InNamespace(target.namespace), client.MatchingLabels{vpnv1alpha1.NetworkLabel: network.Name}); err != nil {
+			return false, err
+		}
+		for i := range peers.Items {
+			if err := fn(target, clusterName, &peers.Items[i]); err != nil {
+				return false, err
+			}
+		}
+	}
+	return pending, nil
+}
+
+// overlappingNetwork returns a network of a overlapping one of b, or an
+// empty string.
+func overlappingNetwork(a, b []string) string {
+	for _, x := range a {
+		_, nx, err := net.ParseCIDR(x)
+		if err != nil {
+			continue
+		}
+		for _, y := range b {
+			_, ny, err := net.ParseCIDR(y)
+			if err == nil && (nx.Contains(ny.IP) || ny.Contains(nx.IP)) {
+				return x
+			}
+		}
+	}
+	return ""
+}
+
+// networksForServer maps a VPNServer to the networks it is a member of.
+func (r *VPNNetworkReconciler) networksForServer(ctx context.Context, obj client.Object) []reconcile.Request {
+	networks := &vpnv1alpha1.VPNNetworkList{}
+	if err := r.List(ctx, networks, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, network := range networks.Items {
+		for _, member := range network.Spec.Members {
+			if member.ServerRef.Name == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: network.Namespace, Name: network.Name}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VPNNetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vpnv1alpha1.VPNNetwork{}).
+		Owns(&vpnv1alpha1.VPNPeer{}).
+		Watches(&vpnv1alpha1.VPNServer{}, handler.EnqueueRequestsFromMapFunc(r.networksForServer)).
+		Complete(instrument(mgr, "vpnnetwork", &vpnv1alpha1.VPNNetwork{}, r))
+}
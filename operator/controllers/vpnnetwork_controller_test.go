@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// testNetwork returns a full mesh network of east and west, and its member
+// servers.
+func testNetwork() (*vpnv1alpha1.VPNNetwork, *vpnv1alpha1.VPNServer, *vpnv1alpha1.VPNServer) {
+	east := testServer("east")
+	east.Status.PublicKey = testKeyA
+	east.Status.Endpoint = "203.0.113.1:51820"
+	west := testServer("west")
+	west.Spec.Address = "10.9.0.1/24"
+	west.Status.PublicKey = testKeyB
+	west.Status.Endpoint = "203.0.113.2:51820"
+	network := &vpnv1alpha1.VPNNetwork{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "mesh", UID: "mesh-uid", Generation: 1},
+		Spec: vpnv1alpha1.VPNNetworkSpec{
+			Topology: vpnv1alpha1.NetworkTopologyFullMesh,
+			Members: []vpnv1alpha1.VPNNetworkMember{
+				{ServerRef: vpnv1alpha1.LocalObjectReference{Name: "east"}},
+				{ServerRef: vpnv1alpha1.LocalObjectReference{Name: "west"}},
+			},
+		},
+	}
+	return network, east, west
+}
+
+// reconcileNetwork reconciles a network and returns it as stored.
+func reconcileNetwork(t *testing.T, r *VPNNetworkReconciler, network *vpnv1alpha1.VPNNetwork) *vpnv1alpha1.VPNNetwork {
+	t.Helper()
+	key := client.ObjectKeyFromObject(network)
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	updated := &vpnv1alpha1.VPNNetwork{}
+	if err := r.Get(context.Background(), key, updated); err != nil {
+		t.Fatal(err)
+	}
+	return updated
+}
+
+// handshake records a handshake of server with the peer of publicKey at a
+// time, as the peer stats of its agents do.
+func handshake(t *testing.T, c client.Client, name, publicKey string, at time.Time) {
+	t.Helper()
+	server := &vpnv1alpha1.VPNServer{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: name}, server); err != nil {
+		t.Fatal(err)
+	}
+	latest := metav1.NewTime(at)
+	server.Status.Peers = append(server.Status.Peers, vpnv1alpha1.PeerStatus{PublicKey: publicKey, LatestHandshake: &latest})
+	if err := c.Status().Update(context.Background(), server); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// linkPeerOf returns the link peer of a network on a server.
+func linkPeerOf(t *testing.T, c client.Client, name string) *vpnv1alpha1.VPNPeer {
+	t.Helper()
+	peer := &vpnv1alpha1.VPNPeer{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: name}, peer); err != nil {
+		t.Fatalf("link peer %s: %v", name, err)
+	}
+	return peer
+}
+
+// ageChange moves the change being verified of a network into the past.
+func ageChange(t *testing.T, c client.Client, network *vpnv1alpha1.VPNNetwork, age time.Duration) {
+	t.Helper()
+	network.Status.Change.Time = metav1.NewTime(network.Status.Change.Time.Add(-age))
+	if err := c.Status().Update(context.Background(), network); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVPNNetworkReconcilerCommitsVerifiedChange(t *testing.T) {
+	network, east, west := testNetwork()
+	c, scheme := newTestClient(t, network, east, west)
+	r := &VPNNetworkReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(10)}
+
+	updated := reconcileNetwork(t, r, network)
+	if change := updated.Status.Change; change == nil || len(change.PendingLinks) != 1 || change.PendingLinks[0] != "east/west" {
+		t.Fatalf("change = %+v, want east/west pending", change)
+	}
+	if condition := vpnv1alpha1.FindCondition(updated.Status.Conditions, vpnv1alpha1.ConditionReady); condition == nil || condition.Reason != "VerifyingLinks" {
+		t.Errorf("ready = %+v, want VerifyingLinks", condition)
+	}
+	toWest := linkPeerOf(t, c, "mesh-west")
+	if toWest.Spec.ServerRef.Name != "east" || toWest.Spec.PublicKey != testKeyB || toWest.Spec.Endpoint != "203.0.113.2:51820" {
+		t.Errorf("link peer of west on east = %+v", toWest.Spec)
+	}
+	if previous, ok := toWest.Annotations[vpnv1alpha1.NetworkPreviousLinkAnnotation]; !ok || previous != "" {
+		t.Errorf("previous link = %q, %v, want the peer recorded as created", previous, ok)
+	}
+
+	// One direction is not enough
+	now := time.Now().Add(time.Second)
+	handshake(t, c, "east", testKeyB, now)
+	if updated = reconcileNetwork(t, r, updated); updated.Status.Change == nil {
+		t.Fatal("change committed with a single direction handshaking")
+	}
+	handshake(t, c, "west", testKeyA, now)
+	updated = reconcileNetwork(t, r, updated)
+	if updated.Status.Change != nil || updated.Status.RolledBack != nil {
+		t.Errorf("change = %+v, rolled back = %+v, want the change committed", updated.Status.Change, updated.Status.RolledBack)
+	}
+	if !vpnv1alpha1.IsConditionTrue(updated.Status.Conditions, vpnv1alpha1.ConditionReady) {
+		t.Error("network is not ready")
+	}
+	for _, name := range []string{"mesh-east", "mesh-west"} {
+		if _, ok := linkPeerOf(t, c, name).Annotations[vpnv1alpha1.NetworkPreviousLinkAnnotation]; ok {
+			t.Errorf("%s keeps its previous link after the commit", name)
+		}
+	}
+}
+
+func TestVPNNetworkReconcilerRollsBackUnverifiedChange(t *testing.T) {
+	network, east, west := testNetwork()
+	c, scheme := newTestClient(t, network, east, west)
+	r := &VPNNetworkReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(10)}
+	// Verify the links an hour ago, before the change below
+	updated := reconcileNetwork(t, r, network)
+	ageChange(t, c, updated, time.Hour)
+	handshake(t, c, "east", testKeyB, updated.Status.Change.Time.Time)
+	handshake(t, c, "west", testKeyA, updated.Status.Change.Time.Time)
+	if updated = reconcileNetwork(t, r, updated); updated.Status.Change != nil {
+		t.Fatalf("initial change not committed: %+v", updated.Status.Change)
+	}
+
+	updated.Spec.PersistentKeepalive = 10
+	if err := c.Update(context.Background(), updated); err != nil {
+		t.Fatal(err)
+	}
+	updated = reconcileNetwork(t, r, updated)
+	if keepalive := linkPeerOf(t, c, "mesh-west").Spec.PersistentKeepalive; keepalive != 10 {
+		t.Fatalf("keepalive = %d, want the change applied", keepalive)
+	}
+	ageChange(t, c, updated, linkVerificationTimeout+time.Minute)
+
+	updated = reconcileNetwork(t, r, updated)
+	if updated.Status.RolledBack == nil || updated.Status.Change != nil {
+		t.Fatalf("change = %+v, rolled back = %+v, want the change rolled back", updated.Status.Change, updated.Status.RolledBack)
+	}
+	if condition := vpnv1alpha1.FindCondition(updated.Status.Conditions, vpnv1alpha1.ConditionReady); condition == nil || condition.Reason != "RolledBack" {
+		t.Errorf("ready = %+v, want RolledBack", condition)
+	}
+	for _, name := range []string{"mesh-east", "mesh-west"} {
+		peer := linkPeerOf(t, c, name)
+		if peer.Spec.PersistentKeepalive != defaultNetworkKeepalive {
+			t.Errorf("%s keepalive = %d, want the previous one", name, peer.Spec.PersistentKeepalive)
+		}
+		if _, ok := peer.Annotations[vpnv1alpha1.NetworkPreviousLinkAnnotation]; ok {
+			t.Errorf("%s keeps its previous link after the rollback", name)
+		}
+	}
+
+	// The change is not applied again until the retry interval
+	reconcileNetwork(t, r, updated)
+	if keepalive := linkPeerOf(t, c, "mesh-west").Spec.PersistentKeepalive; keepalive != defaultNetworkKeepalive {
+		t.Errorf("keepalive = %d, want the rolled back change kept out", keepalive)
+	}
+}
+
+func TestVPNNetworkReconcilerRollsBackPartialApply(t *testing.T) {
+	network, east, west := testNetwork()
+	// A peer of west on east that is not a link blocks one side
+	taken := testPeer("mesh-west", "east", testKeyC, "10.8.0.9")
+	c, scheme := newTestClient(t, network, east, west, taken)
+	r := &VPNNetworkReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(10)}
+
+	updated := reconcileNetwork(t, r, network)
+	if updated.Status.RolledBack == nil {
+		t.Fatal("partial apply was not rolled back")
+	}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: "mesh-east"}, &vpnv1alpha1.VPNPeer{}); err == nil {
+		t.Error("the link peer of east on west was kept on its own")
+	}
+	if peer := linkPeerOf(t, c, "mesh-west"); peer.Spec.PublicKey != testKeyC {
+		t.Errorf("the peer that is not a link was changed: %+v", peer.Spec)
+	}
+}
+
+func TestVPNNetworkReconcilerSummarizesLinks(t *testing.T) {
+	network, east, west := testNetwork()
+	now := metav1.NewTime(time.Now().Add(time.Second))
+	east.Status.Peers = []vpnv1alpha1.PeerStatus{{PublicKey: testKeyB, LatestHandshake: &now, RoundTripTime: &metav1.Duration{Duration: 10 * time.Millisecond}}}
+	west.Status.Peers = []vpnv1alpha1.PeerStatus{{PublicKey: testKeyA, LatestHandshake: &now, RoundTripTime: &metav1.Duration{Duration: 20 * time.Millisecond}}}
+	c, scheme := newTestClient(t, network, east, west)
+	r := &VPNNetworkReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(10)}
+
+	// The links handshake right after the change, which is committed
+	updated := reconcileNetwork(t, r, network)
+	if updated.Status.Links != 1 || updated.Status.EstablishedLinks != 1 {
+		t.Errorf("links = %d, established = %d, want 1 and 1", updated.Status.Links, updated.Status.EstablishedLinks)
+	}
+	if len(updated.Status.LinkStatuses) != 1 {
+		t.Fatalf("link statuses = %+v, want east/west", updated.Status.LinkStatuses)
+	}
+	link := updated.Status.LinkStatuses[0]
+	if link.Name != "east/west" || !link.Established || link.RoundTripTime == nil || link.RoundTripTime.Duration != 15*time.Millisecond {
+		t.Errorf("link = %+v, want east/west established in 15ms", link)
+	}
+	if !vpnv1alpha1.IsConditionTrue(updated.Status.Conditions, vpnv1alpha1.ConditionReady) {
+		t.Error("network with every link established is not ready")
+	}
+
+	// A link whose handshakes aged is down
+	stale := metav1.NewTime(time.Now().Add(-2 * handshakeFreshness))
+	server := &vpnv1alpha1.VPNServer{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(west), server); err != nil {
+		t.Fatal(err)
+	}
+	server.Status.Peers[0].LatestHandshake = &stale
+	if err := c.Status().Update(context.Background(), server); err != nil {
+		t.Fatal(err)
+	}
+	updated = reconcileNetwork(t, r, updated)
+	if updated.Status.EstablishedLinks != 0 || updated.Status.LinkStatuses[0].Established {
+		t.Errorf("established = %d, link = %+v, want the link down", updated.Status.EstablishedLinks, updated.Status.LinkStatuses[0])
+	}
+	if condition := vpnv1alpha1.FindCondition(updated.Status.Conditions, vpnv1alpha1.ConditionReady); condition == nil || condition.Reason != "LinksDown" {
+		t.Errorf("ready = %+v, want LinksDown", condition)
+	}
+}
+
+func TestNetworkLinkPeersHubAndSpoke(t *testing.T) {
+	network := &vpnv1alpha1.VPNNetwork{
+		ObjectMeta: metav1.ObjectMeta{Name: "star"},
+		Spec:       vpnv1alpha1.VPNNetworkSpec{Topology: vpnv1alpha1.NetworkTopologyHubAndSpoke, Hub: "hub"},
+	}
+	member := func(name, network string) *networkMember {
+		return &networkMember{status: vpnv1alpha1.VPNNetworkMemberStatus{Server: name, Networks: []string{network}}, usable: true}
+	}
+	members := []*networkMember{member("hub", "10.8.0.0/24"), member("east", "10.9.0.0/24"), member("west", "10.10.0.0/24")}
+
+	got := map[string]string{}
+	for _, peer := range networkLinkPeers(network, members) {
+		got[peer.local.status.Server+"/"+peer.name] = strings.Join(peer.allowedIPs, ",")
+	}
+	want := map[string]string{
+		"hub/star-east": "10.9.0.0/24",
+		"hub/star-west": "10.10.0.0/24",
+		// The spokes reach each other through the hub
+		"east/star-hub": "10.8.0.0/24,10.10.0.0/24",
+		"west/star-hub": "10.8.0.0/24,10.9.0.0/24",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("link peers = %v, want %v", got, want)
+	}
+}
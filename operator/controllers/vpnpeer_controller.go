@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// VPNPeerReconciler reconciles a VPNPeer object
+type VPNPeerReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// FleetDefaults are the settings of the top level of the configuration
+	// hierarchy
+	FleetDefaults vpnv1alpha1.ConfigLayer
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnaccesspolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnserverclasses,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeergroups,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile resolves the references of a peer. Peers whose references do
+// not exist are kept Pending and re-resolved when the referenced objects
+// are created.
+func (r *VPNPeerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	peer := &vpnv1alpha1.VPNPeer{}
+	if err := r.Get(ctx, req.NamespacedName, peer); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !peer.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	previous := peer.DeepCopy()
+	peer.Status.ObservedGeneration = peer.Generation
+	if peer.Spec.Revoked && !vpnv1alpha1.IsConditionTrue(peer.Status.Conditions, vpnv1alpha1.ConditionRevoked) {
+		// The transition time of the condition records when the peer was
+		// revoked
+		vpnv1alpha1.SetCondition(&peer.Status.Conditions, vpnv1alpha1.Condition{
+			Type:               vpnv1alpha1.ConditionRevoked,
+			Status:             vpnv1alpha1.ConditionTrue,
+			Reason:             "Revoked",
+			Message:            fmt.Sprintf("Public key %q is revoked", peer.Spec.PublicKey),
+			ObservedGeneration: peer.Generation,
+		})
+	}
+
+	server := &vpnv1alpha1.VPNServer{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: peer.Namespace, Name: peer.Spec.ServerRef.Name}, server)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	if apierrors.IsNotFound(err) {
+		logger.Info("referenced server does not exist", "server", peer.Spec.ServerRef.Name)
+		peer.Status.Phase = vpnv1alpha1.PeerPhasePending
+		vpnv1alpha1.SetCondition(&peer.Status.Conditions, vpnv1alpha1.Condition{
+			Type:               vpnv1alpha1.ConditionReady,
+			Status:             vpnv1alpha1.ConditionFalse,
+			Reason:             "MissingRef",
+			Message:            fmt.Sprintf("VPNServer %q does not exist", peer.Spec.ServerRef.Name),
+			ObservedGeneration: peer.Generation,
+		})
+		return ctrl.Result{}, r.Status().Update(ctx, peer)
+	}
+
+	// Owning the peer lets a cascading server deletion garbage collect it
+	moved := previous.Status.ObservedServer != "" && previous.Status.ObservedServer != server.Name
+	if moved {
+		releasePreviousServers(peer, server)
+		if err := r.moveAddress(ctx, peer, server); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	// A server being deleted does not adopt peers, so that those it retains
+	// stay orphaned
+	if !isOwnedBy(peer, server) && server.DeletionTimestamp.IsZero() {
+		if err := controllerutil.SetOwnerReference(server, peer, r.Scheme); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if !equality.Semantic.DeepEqual(previous.OwnerReferences, peer.OwnerReferences) || previous.Spec.Address != peer.Spec.Address {
+		status := peer.Status
+		if err := r.Update(ctx, peer); err != nil {
+			return ctrl.Result{}, err
+		}
+		peer.Status = status
+		peer.Status.ObservedGeneration = peer.Generation
+	}
+
+	allowedIPs, _, err := clientRoutes(server, peer.Spec.RoutingProfile)
+	if err != nil {
+		logger.Info("routing profile does not exist", "profile", peer.Spec.RoutingProfile)
+		peer.Status.Phase = vpnv1alpha1.PeerPhasePending
+		vpnv1alpha1.SetCondition(&peer.Status.Conditions, vpnv1alpha1.Condition{
+			Type:               vpnv1alpha1.ConditionReady,
+			Status:             vpnv1alpha1.ConditionFalse,
+			Reason:             "UnknownRoutingProfile",
+			Message:            err.Error(),
+			ObservedGeneration: peer.Generation,
+		})
+		return ctrl.Result{}, r.Status().Update(ctx, peer)
+	}
+
+	// Peers without a routing profile get the tunnel of their group
+	if peer.Spec.RoutingProfile == "" {
+		tunnel, err := r.groupTunnel(ctx, peer)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if tunnel != nil {
+			allowedIPs = tunnel.ClientAllowedIPs()
+		}
+	}
+
+	if port := peer.Spec.EndpointPort; port != 0 && !server.Spec.ListensOn(port) {
+		logger.Info("server does not listen on endpoint port", "port", port)
+		peer.Status.Phase = vpnv1alpha1.PeerPhasePending
+		vpnv1alpha1.SetCondition(&peer.Status.Conditions, vpnv1alpha1.Condition{
+			Type:               vpnv1alpha1.ConditionReady,
+			Status:             vpnv1alpha1.ConditionFalse,
+			Reason:             "UnknownEndpointPort",
+			Message:            fmt.Sprintf("VPNServer %q does not listen on port %d", server.Name, port),
+			ObservedGeneration: peer.Generation,
+		})
+		return ctrl.Result{}, r.Status().Update(ctx, peer)
+	}
+
+	policies := &vpnv1alpha1.VPNAccessPolicyList{}
+	if err := r.List(ctx, policies, client.InNamespace(peer.Namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+	granted, applied, governed := policyAllowedIPs(policies.Items, peer)
+	if governed {
+		allowedIPs = granted
+	}
+	peer.Status.AccessPolicies = applied
+
+	levels, err := r.configLevels(ctx, server, peer)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	peer.Status.EffectiveConfig = mergeConfig(levels)
+
+	peer.Status.ClientAllowedIPs = allowedIPs
+	peer.Status.ClientDNS = peer.Status.EffectiveConfig.DNS
+	peer.Status.ClientSearchDomains = nil
+	if profile := server.Spec.RoutingProfile(peer.Spec.RoutingProfile); profile != nil {
+		peer.Status.ClientSearchDomains = profile.SearchDomains
+	}
+	peer.Status.ClientEndpoint = clientEndpoint(server, peer.Spec.EndpointPort)
+	peer.Status.ClientEndpoints = clientEndpoints(server, peer)
+	if len(peer.Status.ClientEndpoints) > 0 {
+		peer.Status.ClientEndpoint = peer.Status.ClientEndpoints[0]
+	}
+	// The clients of sharded servers connect to the replica of their shard
+	peer.Status.Shard = peerShard(server, peer)
+	if peer.Status.Shard != nil {
+		peer.Status.ClientEndpoint = shardClientEndpoint(server, *peer.Status.Shard)
+	}
+	peer.Status.Phase = vpnv1alpha1.PeerPhaseActive
+	if peer.Status.Suspension != nil {
+		peer.Status.Phase = vpnv1alpha1.PeerPhaseSuspended
+	}
+	if peer.Spec.Invite != nil && peer.Spec.PublicKey == "" {
+		peer.Status.Phase = vpnv1alpha1.PeerPhaseInvited
+	}
+	if peer.Spec.Revoked {
+		peer.Status.Phase = vpnv1alpha1.PeerPhaseRevoked
+	}
+	vpnv1alpha1.SetCondition(&peer.Status.Conditions, vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionReady,
+		Status:             vpnv1alpha1.ConditionTrue,
+		Reason:             "Resolved",
+		ObservedGeneration: peer.Generation,
+	})
+	if previous.Status.ObservedServer != "" {
+		r.recordMigration(previous, peer)
+	}
+	peer.Status.ObservedServer = server.Name
+	peer.Status.ObservedGroup = peer.Spec.Group
+	return ctrl.Result{}, r.Status().Update(ctx, peer)
+}
+
+// peersForServer maps a VPNServer to the peers referencing it.
+func (r *VPNPeerReconciler) peersForServer(ctx context.Context, obj client.Object) []reconcile.Request {
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, client.InNamespace(obj.GetNamespace()), client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: obj.GetName()}); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list peers of server", "server", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(peers.Items))
+	for _, peer := range peers.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&peer)})
+	}
+	return requests
+}
+
+// peersForPolicy maps a VPNAccessPolicy to the peers of the servers it
+// applies to.
+func (r *VPNPeerReconciler) peersForPolicy(ctx context.Context, obj client.Object) []reconcile.Request {
+	policy := obj.(*vpnv1alpha1.VPNAccessPolicy)
+	opts := []client.ListOption{client.InNamespace(policy.Namespace)}
+	if policy.Spec.ServerRef != nil {
+		opts = append(opts, client.MatchingFields{vpnv1alpha1.VPNPeerServerRefField: policy.Spec.ServerRef.Name})
+	}
+
+	peers := &vpnv1alpha1.VPNPeerList{}
+	if err := r.List(ctx, peers, opts...); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list peers of access policy", "policy", policy.Name)
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(peers.Items))
+	for _, peer := range peers.Items {
+		if peer.Spec.Identity != nil {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&peer)})
+		}
+	}
+	return requests
+}
+
+// isOwnedBy returns whether owner is in the owner references of obj.
+func isOwnedBy(obj, owner client.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return true
+		}
+	}
+	return false
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VPNPeerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vpnv1alpha1.VPNPeer{}).
+		Watches(&vpnv1alpha1.VPNServer{}, handler.EnqueueRequestsFromMapFunc(r.peersForServer)).
+		Watches(&vpnv1alpha1.VPNAccessPolicy{}, handler.EnqueueRequestsFromMapFunc(r.peersForPolicy)).
+		Watches(&vpnv1alpha1.VPNPeerGroup{}, handler.EnqueueRequestsFromMapFunc(r.peersForGroup)).
+		Watches(&vpnv1alpha1.VPNServerClass{}, handler.EnqueueRequestsFromMapFunc(r.peersForClass)).
+		Complete(instrument(mgr, "vpnpeer", &vpnv1alpha1.VPNPeer{}, r))
+}
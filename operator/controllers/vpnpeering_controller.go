@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	"github.com/vpn-devops/vpn-operator/pkg/peering"
+)
+
+// VPNPeeringReconciler imports the peering bundles of VPNPeerings. A
+// bundle signed with a trusted key and not expired becomes a VPNPeer on
+// the local server, named after the peering, holding the public key,
+// endpoint and networks of the exported server. The peer is removed when
+// the bundle expires or is no longer trusted.
+type VPNPeeringReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeerings,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeerings/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnpeers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile verifies the bundle of a peering and applies its peer.
+func (r *VPNPeeringReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	p := &vpnv1alpha1.VPNPeering{}
+	if err := r.Get(ctx, req.NamespacedName, p); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !p.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	original := p.DeepCopy()
+	now := time.Now()
+
+	bundle, reason, err := r.verify(ctx, p, now)
+	if err == nil {
+		err = r.applyPeer(ctx, p, bundle)
+		reason = "PeerFailed"
+	}
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionReady,
+		Status:             vpnv1alpha1.ConditionTrue,
+		Reason:             "Peered",
+		ObservedGeneration: p.Generation,
+	}
+	var result ctrl.Result
+	if err != nil {
+		// A bundle that cannot be trusted any more takes its tunnel down
+		peer := &vpnv1alpha1.VPNPeer{ObjectMeta: metav1.ObjectMeta{Namespace: p.Namespace, Name: p.Name}}
+		if p.Status.Peer != "" {
+			if err := r.Delete(ctx, peer); client.IgnoreNotFound(err) != nil {
+				return ctrl.Result{}, err
+			}
+			r.Recorder.Eventf(p, corev1.EventTypeWarning, reason, "Removed the tunnel to %s: %s", p.Status.Server, err)
+		}
+		p.Status.Peer = ""
+		condition.Status = vpnv1alpha1.ConditionFalse
+		condition.Reason = reason
+		condition.Message = err.Error()
+	} else {
+		expires := metav1.NewTime(bundle.ExpiresAt)
+		p.Status.Cluster = bundle.Cluster
+		p.Status.Server = bundle.Namespace + "/" + bundle.Server
+		p.Status.PublicKey = bundle.PublicKey
+		p.Status.Endpoint = bundle.Endpoint
+		p.Status.Networks = bundle.Networks
+		p.Status.ExpiresAt = &expires
+		p.Status.Peer = p.Name
+		condition.Message = fmt.Sprintf("VPNPeer %s routes %d networks to %s until %s", p.Name, len(bundle.Networks), p.Status.Server, expires.UTC().Format(time.RFC3339))
+		result.RequeueAfter = bundle.ExpiresAt.Sub(now)
+	}
+	vpnv1alpha1.SetCondition(&p.Status.Conditions, condition)
+	p.Status.ObservedGeneration = p.Generation
+	if equality.Semantic.DeepEqual(original.Status, p.Status) {
+		return result, nil
+	}
+	return result, r.Status().Patch(ctx, p, client.MergeFrom(original))
+}
+
+// verify returns the verified bundle of a peering, or the reason and error
+// it cannot be imported for.
+func (r *VPNPeeringReconciler) verify(ctx context.Context, p *vpnv1alpha1.VPNPeering, now time.Time) (*peering.Bundle, string, error) {
+	encoded := p.Spec.Bundle
+	if ref := p.Spec.BundleSecretRef; ref != nil {
+		value, err := secretKey(ctx, r.Client, p.Namespace, *ref, peeringBundleKey)
+		if err != nil {
+			return nil, "BundleNotFound", err
+		}
+		encoded = string(value)
+	}
+	var trusted []ed25519.PublicKey
+	for _, key := range p.Spec.TrustedSigningKeys {
+		public, err := peering.ParsePublicKey(key)
+		if err != nil {
+			return nil, "InvalidSigningKey", err
+		}
+		trusted = append(trusted, public)
+	}
+	bundle, _, err := peering.Verify(encoded, trusted, now)
+	switch {
+	case errors.Is(err, peering.ErrUntrusted):
+		return nil, "Untrusted", err
+	case errors.Is(err, peering.ErrExpired):
+		return nil, "BundleExpired", fmt.Errorf("%w at %s, import a renewed bundle", err, bundle.ExpiresAt.Format(time.RFC3339))
+	case err != nil:
+		return nil, "InvalidBundle", err
+	}
+
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: p.Namespace, Name: p.Spec.ServerRef.Name}, server); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return nil, "", err
+		}
+		return nil, "ServerNotFound", fmt.Errorf("VPNServer %s not found", p.Spec.ServerRef.Name)
+	}
+	ip, _, err := net.ParseCIDR(bundle.Address)
+	if err != nil || len(bundle.Networks) == 0 {
+		return nil, "InvalidBundle", fmt.Errorf("the bundle has no tunnel address or network")
+	}
+	local := []string{server.Spec.Address}
+	if _, tunnel, err := net.ParseCIDR(server.Spec.Address); err == nil {
+		local = []string{tunnel.String()}
+	}
+	// The bundle routes its networks to the tunnel, which must not take
+	// over those of the local server
+	if overlap := overlappingNetwork(bundle.Networks, local); overlap != "" {
+		return nil, "NetworkConflict", fmt.Errorf("network %s of the bundle overlaps the tunnel network of VPNServer %s", overlap, server.Name)
+	}
+	bundle.Address = hostAddress(ip)
+	return bundle, "", nil
+}
+
+// applyPeer creates or updates the peer standing for the exported server
+// of a peering on the local server.
+func (r *VPNPeeringReconciler) applyPeer(ctx context.Context, p *vpnv1alpha1.VPNPeering, bundle *peering.Bundle) error {
+	keepalive := p.Spec.PersistentKeepalive
+	if keepalive == 0 {
+		keepalive = defaultNetworkKeepalive
+	}
+	peer := &vpnv1alpha1.VPNPeer{ObjectMeta: metav1.ObjectMeta{Namespace: p.Namespace, Name: p.Name}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, peer, func() error {
+		if !peer.CreationTimestamp.IsZero() && peer.Labels[vpnv1alpha1.PeeringLabel] != p.Name {
+			return fmt.Errorf("VPNPeer %s exists and is not managed by the peering", peer.Name)
+		}
+		if peer.Labels == nil {
+			peer.Labels = map[string]string{}
+		}
+		peer.Labels[vpnv1alpha1.PeeringLabel] = p.Name
+		peer.Spec.ServerRef = p.Spec.ServerRef
+		peer.Spec.PublicKey = bundle.PublicKey
+		peer.Spec.Address = bundle.Address
+		peer.Spec.AllowedIPs = bundle.Networks
+		peer.Spec.Endpoint = bundle.Endpoint
+		peer.Spec.PersistentKeepalive = keepalive
+		return controllerutil.SetControllerReference(p, peer, r.Scheme)
+	})
+	return err
+}
+
+// peeringsForSecret maps a Secret to the peerings whose bundle it holds.
+func (r *VPNPeeringReconciler) peeringsForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	peerings := &vpnv1alpha1.VPNPeeringList{}
+	if err := r.List(ctx, peerings, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, p := range peerings.Items {
+		if ref := p.Spec.BundleSecretRef; ref != nil && ref.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&p)})
+		}
+	}
+	return requests
+}
+
+// peeringsForServer maps a VPNServer to the peerings established from it.
+func (r *VPNPeeringReconciler) peeringsForServer(ctx context.Context, obj client.Object) []reconcile.Request {
+	peerings := &vpnv1alpha1.VPNPeeringList{}
+	if err := r.List(ctx, peerings, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, p := range peerings.Items {
+		if p.Spec.ServerRef.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&p)})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VPNPeeringReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&vpnv1alpha1.VPNPeering{}).
+		Owns(&vpnv1alpha1.VPNPeer{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.peeringsForSecret)).
+		Watches(&vpnv1alpha1.VPNServer{}, handler.EnqueueRequestsFromMapFunc(r.peeringsForServer)).
+		Complete(instrument(mgr, "vpnpeering", &vpnv1alpha1.VPNPeering{}, r))
+}
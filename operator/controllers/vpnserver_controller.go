@@ -0,0 +1,504 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+const (
+	// agentMetricsPort is the port the agent serves its metrics on
+	agentMetricsPort = 9090
+
+	// podInfoVolumeName is the downward API volume the agent reads the
+	// annotations of its pod from
+	podInfoVolumeName = "podinfo"
+
+	// renderedConfigVolumeName is the volume of the rendered configuration
+	renderedConfigVolumeName = "wireguard-config"
+)
+
+// VPNServerReconciler runs the replicas of a server: the Deployment,
+// StatefulSet or DaemonSet of its deployment mode, and the service account
+// its agents call the API with. It hands the configuration rendered by
+// VPNClientReconciler to the agents by annotating their pods with its
+// checksum, and reports the replicas and the configuration they applied.
+type VPNServerReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// The API server only lets the operator grant permissions it holds itself
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;update
+
+// Reconcile applies the workload of a server, hands the desired
+// configuration to its pods and updates its status.
+func (r *VPNServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	if err := r.applyAgentRBAC(ctx, server); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.applyWorkload(ctx, server); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, client.InNamespace(server.Namespace), client.MatchingLabels(serverLabels(server))); err != nil {
+		return ctrl.Result{}, err
+	}
+	var pods []corev1.Pod
+	for _, pod := range podList.Items {
+		if pod.DeletionTimestamp.IsZero() {
+			pods = append(pods, pod)
+		}
+	}
+	if server.Status.ConfigChecksum != "" {
+		if err := propagateConfigChecksum(ctx, r.Client, pods, server.Status.ConfigChecksum); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	original := server.DeepCopy()
+	// The scale subresource reads the replicas and their selector from the
+	// status, for kubectl scale and autoscalers
+	server.Status.Replicas = int32(len(pods))
+	server.Status.Selector = serverSelector(server)
+	var readyReplicas int32
+	for i := range pods {
+		if isPodReady(&pods[i]) {
+			readyReplicas++
+		}
+	}
+	server.Status.ReadyReplicas = readyReplicas
+	server.Status.AvailableReplicas = readyReplicas
+	server.Status.AppliedConfigChecksum = appliedConfigChecksum(pods)
+	if configApplied(server) {
+		server.Status.ObservedGeneration = server.Generation
+	}
+	vpnv1alpha1.SetCondition(&server.Status.Conditions, serverReadyCondition(server))
+	// The overrides apply to servers whose endpoint or key the operator
+	// does not discover too. A value taken from an override is not a
+	// discovered one, the service and key reconcilers set those.
+	discovered := func(conditionType, value string) string {
+		if vpnv1alpha1.IsConditionTrue(original.Status.Conditions, conditionType) {
+			return ""
+		}
+		return value
+	}
+	resolveServerIdentity(server,
+		discovered(vpnv1alpha1.ConditionEndpointOverridden, server.Status.Endpoint),
+		discovered(vpnv1alpha1.ConditionPublicKeyOverridden, server.Status.PublicKey))
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{}, patchServerStatus(ctx, r.Client, server, original)
+}
+
+// configApplied returns whether the configuration rendered from the current
+// generation of a server was applied by every ready replica. Until then the
+// observed generation of the server stays at the last one that was.
+func configApplied(server *vpnv1alpha1.VPNServer) bool {
+	status := &server.Status
+	return status.ConfigChecksum != "" && status.ConfigGeneration == server.Generation &&
+		status.ReadyReplicas > 0 && status.AppliedConfigChecksum == status.ConfigChecksum
+}
+
+// serverReadyCondition returns the Ready condition of a server for its
+// current generation: true once its configuration is applied and all its
+// replicas are ready.
+func serverReadyCondition(server *vpnv1alpha1.VPNServer) vpnv1alpha1.Condition {
+	condition := vpnv1alpha1.Condition{
+		Type:               vpnv1alpha1.ConditionReady,
+		Status:             vpnv1alpha1.ConditionFalse,
+		ObservedGeneration: server.Generation,
+	}
+	status := &server.Status
+	switch {
+	case status.ConfigChecksum == "" || status.ConfigGeneration != server.Generation:
+		condition.Reason = "ConfigPending"
+		condition.Message = fmt.Sprintf("the configuration of generation %d is not rendered yet", server.Generation)
+	case status.ReadyReplicas == 0:
+		condition.Reason = "NoReadyReplicas"
+		condition.Message = "no replica is ready"
+	case status.AppliedConfigChecksum != status.ConfigChecksum:
+		condition.Reason = "ConfigNotApplied"
+		condition.Message = fmt.Sprintf("the configuration of generation %d is not applied by every ready replica yet", server.Generation)
+	case status.ReadyReplicas < status.Replicas:
+		condition.Reason = "ReplicasNotReady"
+		condition.Message = fmt.Sprintf("%d of %d replicas are ready", status.ReadyReplicas, status.Replicas)
+	default:
+		condition.Status = vpnv1alpha1.ConditionTrue
+		condition.Reason = "ConfigApplied"
+		condition.Message = fmt.Sprintf("every replica applied the configuration of generation %d", server.Generation)
+	}
+	return condition
+}
+
+// applyWorkload creates or updates the workload of the deployment mode of a
+// server, and deletes those of the modes it was switched from.
+func (r *VPNServerReconciler) applyWorkload(ctx context.Context, server *vpnv1alpha1.VPNServer) error {
+	template, err := serverPodTemplate(server)
+	if err != nil {
+		return err
+	}
+	meta := metav1.ObjectMeta{Namespace: server.Namespace, Name: server.Name}
+	selector := &metav1.LabelSelector{MatchLabels: serverLabels(server)}
+	replicas := server.Spec.Replicas
+	if replicas < 1 {
+		replicas = 1
+	}
+
+	var workload client.Object
+	var mutate func()
+	kind := server.Spec.DeploymentMode
+	switch kind {
+	case vpnv1alpha1.DeploymentModeDaemonSet:
+		daemonSet := &appsv1.DaemonSet{ObjectMeta: meta}
+		workload = daemonSet
+		mutate = func() {
+			daemonSet.Spec.Selector = selector
+			daemonSet.Spec.Template = template
+		}
+	case vpnv1alpha1.DeploymentModeStatefulSet:
+		statefulSet := &appsv1.StatefulSet{ObjectMeta: meta}
+		workload = statefulSet
+		mutate = func() {
+			statefulSet.Spec.Replicas = &replicas
+			statefulSet.Spec.Selector = selector
+			// Replicas are independent, they need not start in order
+			statefulSet.Spec.PodManagementPolicy = appsv1.ParallelPodManagement
+			statefulSet.Spec.ServiceName = server.Name
+			statefulSet.Spec.Template = template
+		}
+	default:
+		kind = vpnv1alpha1.DeploymentModeDeployment
+		deployment := &appsv1.Deployment{ObjectMeta: meta}
+		workload = deployment
+		mutate = func() {
+			deployment.Spec.Replicas = &replicas
+			deployment.Spec.Selector = selector
+			deployment.Spec.Template = template
+		}
+	}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, workload, func() error {
+		labels := workload.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		for key, value := range serverLabels(server) {
+			labels[key] = value
+		}
+		workload.SetLabels(labels)
+		propagateMetadata(server, workload)
+		mutate()
+		return controllerutil.SetControllerReference(server, workload, r.Scheme)
+	})
+	if err != nil {
+		return err
+	}
+	if result != controllerutil.OperationResultNone {
+		log.FromContext(ctx).Info("applied workload", "kind", kind, "operation", result)
+	}
+
+	for previousKind, previous := range map[vpnv1alpha1.DeploymentMode]client.Object{
+		vpnv1alpha1.DeploymentModeDeployment:  &appsv1.Deployment{},
+		vpnv1alpha1.DeploymentModeStatefulSet: &appsv1.StatefulSet{},
+		vpnv1alpha1.DeploymentModeDaemonSet:   &appsv1.DaemonSet{},
+	} {
+		if previousKind == kind {
+			continue
+		}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(workload), previous); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if !isOwnedBy(previous, server) {
+			continue
+		}
+		if err := r.Delete(ctx, previous); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		log.FromContext(ctx).Info("deleted workload of the previous deployment mode", "kind", previousKind)
+	}
+	return nil
+}
+
+// serverPodTemplate returns the pod template of the replicas of a server.
+func serverPodTemplate(server *vpnv1alpha1.VPNServer) (corev1.PodTemplateSpec, error) {
+	resources, err := containerResources(server.Spec.Resources)
+	if err != nil {
+		return corev1.PodTemplateSpec{}, err
+	}
+	optional := true
+	container := corev1.Container{
+		Name:  wireguardContainerName,
+		Image: rolloutImage(server),
+		Env: append([]corev1.EnvVar{{
+			Name:      "WG_HOST",
+			ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.podIP"}},
+		}}, agentEnv(server)...),
+		Ports: []corev1.ContainerPort{
+			{Name: wireguardPortName, ContainerPort: server.Spec.Port, Protocol: corev1.ProtocolUDP},
+			{Name: "metrics", ContainerPort: agentMetricsPort, Protocol: corev1.ProtocolTCP},
+		},
+		Resources: resources,
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: renderedConfigVolumeName, MountPath: renderedConfigMountPath, ReadOnly: true},
+			{Name: podInfoVolumeName, MountPath: "/etc/podinfo", ReadOnly: true},
+		},
+		LivenessProbe: &corev1.Probe{
+			ProbeHandler:        corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"wg", "show"}}},
+			InitialDelaySeconds: 30,
+			PeriodSeconds:       30,
+		},
+		ReadinessProbe: &corev1.Probe{
+			ProbeHandler:        corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"wg", "show"}}},
+			InitialDelaySeconds: 5,
+			PeriodSeconds:       10,
+		},
+	}
+
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: serverLabels(server)},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: serverAgentName(server),
+			Containers:         []corev1.Container{container},
+			Volumes: []corev1.Volume{
+				{
+					// The config Secret and ConfigMap are optional so the
+					// pod can be scheduled before they are first rendered
+					Name: renderedConfigVolumeName,
+					VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{Sources: []corev1.VolumeProjection{
+						{Secret: &corev1.SecretProjection{
+							LocalObjectReference: corev1.LocalObjectReference{Name: serverConfigName(server)},
+							Optional:             &optional,
+						}},
+						{ConfigMap: &corev1.ConfigMapProjection{
+							LocalObjectReference: corev1.LocalObjectReference{Name: serverConfigName(server)},
+							Optional:             &optional,
+						}},
+					}}},
+				},
+				{
+					Name: podInfoVolumeName,
+					VolumeSource: corev1.VolumeSource{DownwardAPI: &corev1.DownwardAPIVolumeSource{Items: []corev1.DownwardAPIVolumeFile{{
+						Path:     "annotations",
+						FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.annotations"},
+					}}}},
+				},
+			},
+			NodeSelector: server.Spec.NodeSelector,
+			Affinity:     placementAffinity(server, podAffinity(server.Spec.Affinity)),
+		},
+	}
+	// Without a key store the agent generates its key into the image
+	if volumes := serverKeysVolumes(server); volumes != nil {
+		template.Spec.InitContainers = []corev1.Container{waitForKeyInitContainer(server)}
+		template.Spec.Containers[0].VolumeMounts = append(template.Spec.Containers[0].VolumeMounts, serverKeysMounts(server)...)
+		template.Spec.Volumes = append(template.Spec.Volumes, volumes...)
+	}
+	applyDataPlane(server, &template.Spec)
+	applyRuntimeClass(server, &template.Spec)
+	propagateMetadata(server, &template.ObjectMeta)
+	for _, toleration := range server.Spec.Tolerations {
+		template.Spec.Tolerations = append(template.Spec.Tolerations, corev1.Toleration{
+			Key:      toleration.Key,
+			Operator: corev1.TolerationOperator(toleration.Operator),
+			Value:    toleration.Value,
+			Effect:   corev1.TaintEffect(toleration.Effect),
+		})
+	}
+	return template, nil
+}
+
+// containerResources converts the resources of a server spec to those of
+// its container.
+func containerResources(spec vpnv1alpha1.ResourceRequirements) (corev1.ResourceRequirements, error) {
+	var resources corev1.ResourceRequirements
+	var err error
+	if resources.Requests, err = resourceList(spec.Requests); err != nil {
+		return resources, fmt.Errorf("spec.resources.requests: %w", err)
+	}
+	if resources.Limits, err = resourceList(spec.Limits); err != nil {
+		return resources, fmt.Errorf("spec.resources.limits: %w", err)
+	}
+	return resources, nil
+}
+
+// resourceList converts a resource list of a server spec, nil if empty.
+func resourceList(list vpnv1alpha1.ResourceList) (corev1.ResourceList, error) {
+	var resources corev1.ResourceList
+	for name, value := range map[corev1.ResourceName]string{corev1.ResourceCPU: list.CPU, corev1.ResourceMemory: list.Memory} {
+		if value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if resources == nil {
+			resources = corev1.ResourceList{}
+		}
+		resources[name] = quantity
+	}
+	return resources, nil
+}
+
+// podAffinity converts the affinity of a server spec to that of its pods.
+func podAffinity(affinity *vpnv1alpha1.Affinity) *corev1.Affinity {
+	if affinity == nil {
+		return nil
+	}
+	converted := &corev1.Affinity{}
+	if node := affinity.NodeAffinity; node != nil {
+		converted.NodeAffinity = &corev1.NodeAffinity{}
+		if required := node.RequiredDuringSchedulingIgnoredDuringExecution; required != nil {
+			selector := &corev1.NodeSelector{}
+			for _, term := range required.NodeSelectorTerms {
+				var expressions []corev1.NodeSelectorRequirement
+				for _, expression := range term.MatchExpressions {
+					expressions = append(expressions, corev1.NodeSelectorRequirement{
+						Key:      expression.Key,
+						Operator: corev1.NodeSelectorOperator(expression.Operator),
+						Values:   expression.Values,
+					})
+				}
+				selector.NodeSelectorTerms = append(selector.NodeSelectorTerms, corev1.NodeSelectorTerm{MatchExpressions: expressions})
+			}
+			converted.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = selector
+		}
+	}
+	if pod := affinity.PodAffinity; pod != nil {
+		converted.PodAffinity = &corev1.PodAffinity{RequiredDuringSchedulingIgnoredDuringExecution: podAffinityTerms(pod.RequiredDuringSchedulingIgnoredDuringExecution)}
+	}
+	if pod := affinity.PodAntiAffinity; pod != nil {
+		converted.PodAntiAffinity = &corev1.PodAntiAffinity{RequiredDuringSchedulingIgnoredDuringExecution: podAffinityTerms(pod.RequiredDuringSchedulingIgnoredDuringExecution)}
+	}
+	return converted
+}
+
+// podAffinityTerms converts pod affinity terms of a server spec.
+func podAffinityTerms(terms []vpnv1alpha1.PodAffinityTerm) []corev1.PodAffinityTerm {
+	var converted []corev1.PodAffinityTerm
+	for _, term := range terms {
+		podTerm := corev1.PodAffinityTerm{Namespaces: term.Namespaces, TopologyKey: term.TopologyKey}
+		if selector := term.LabelSelector; selector != nil {
+			podTerm.LabelSelector = &metav1.LabelSelector{MatchLabels: selector.MatchLabels}
+			for _, expression := range selector.MatchExpressions {
+				podTerm.LabelSelector.MatchExpressions = append(podTerm.LabelSelector.MatchExpressions, metav1.LabelSelectorRequirement{
+					Key:      expression.Key,
+					Operator: metav1.LabelSelectorOperator(expression.Operator),
+					Values:   expression.Values,
+				})
+			}
+		}
+		converted = append(converted, podTerm)
+	}
+	return converted
+}
+
+// applyAgentRBAC creates or updates the service account of the agents of a
+// server, with a Role granting what they do on the API: annotate and label
+// their pod, record events, and hold the lease of the active replica in
+// the high availability mode.
+func (r *VPNServerReconciler) applyAgentRBAC(ctx context.Context, server *vpnv1alpha1.VPNServer) error {
+	meta := metav1.ObjectMeta{Namespace: server.Namespace, Name: serverAgentName(server)}
+	account := &corev1.ServiceAccount{ObjectMeta: meta}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, account, func() error {
+		account.Labels = serverLabels(server)
+		propagateMetadata(server, account)
+		return controllerutil.SetControllerReference(server, account, r.Scheme)
+	}); err != nil {
+		return err
+	}
+
+	role := &rbacv1.Role{ObjectMeta: meta}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, role, func() error {
+		role.Labels = serverLabels(server)
+		propagateMetadata(server, role)
+		role.Rules = []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{corev1.GroupName},
+				Resources: []string{"pods"},
+				Verbs:     []string{"get", "patch"},
+			},
+			{
+				APIGroups: []string{corev1.GroupName},
+				Resources: []string{"events"},
+				Verbs:     []string{"create"},
+			},
+			{
+				APIGroups:     []string{coordinationv1.GroupName},
+				Resources:     []string{"leases"},
+				ResourceNames: []string{haLeaseName(server)},
+				Verbs:         []string{"get", "update"},
+			},
+		}
+		return controllerutil.SetControllerReference(server, role, r.Scheme)
+	}); err != nil {
+		return err
+	}
+
+	binding := &rbacv1.RoleBinding{ObjectMeta: meta}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, binding, func() error {
+		binding.Labels = serverLabels(server)
+		propagateMetadata(server, binding)
+		// The role reference of a binding is immutable, it is only set once
+		binding.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: role.Name}
+		binding.Subjects = []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: account.Name, Namespace: account.Namespace}}
+		return controllerutil.SetControllerReference(server, binding, r.Scheme)
+	})
+	return err
+}
+
+// serverAgentName returns the name of the service account of the agents of
+// a server, and of its Role and RoleBinding.
+func serverAgentName(server *vpnv1alpha1.VPNServer) string {
+	return server.Name + "-agent"
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *VPNServerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("vpnserver").
+		For(&vpnv1alpha1.VPNServer{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.StatefulSet{}).
+		Owns(&appsv1.DaemonSet{}).
+		Owns(&corev1.ServiceAccount{}).
+		Owns(&rbacv1.Role{}).
+		Owns(&rbacv1.RoleBinding{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(serverForPod)).
+		Complete(instrument(mgr, "vpnserver", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,346 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+func TestVPNServerReconcilerAppliesDeployment(t *testing.T) {
+	server := testServer("edge")
+	c, scheme := newTestClient(t, server)
+	r := &VPNServerReconciler{Client: c, Scheme: scheme}
+	reconcileServer(t, r, c, server)
+
+	deployment := &appsv1.Deployment{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: "edge"}, deployment); err != nil {
+		t.Fatalf("deployment: %v", err)
+	}
+	if *deployment.Spec.Replicas != 2 {
+		t.Errorf("replicas = %d, want 2", *deployment.Spec.Replicas)
+	}
+	spec := deployment.Spec.Template.Spec
+	if spec.ServiceAccountName != "edge-agent" {
+		t.Errorf("service account = %q, want edge-agent", spec.ServiceAccountName)
+	}
+	if len(spec.Containers) != 1 || spec.Containers[0].Name != wireguardContainerName || spec.Containers[0].Image != "wireflow/server:1.0" {
+		t.Fatalf("containers = %+v", spec.Containers)
+	}
+	var mounted bool
+	for _, mount := range spec.Containers[0].VolumeMounts {
+		if mount.MountPath == renderedConfigMountPath {
+			mounted = true
+		}
+	}
+	if !mounted {
+		t.Errorf("rendered config is not mounted at %s", renderedConfigMountPath)
+	}
+
+	role := &rbacv1.Role{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: "edge-agent"}, role); err != nil {
+		t.Fatalf("role: %v", err)
+	}
+	granted := map[string]bool{}
+	for _, rule := range role.Rules {
+		for _, resource := range rule.Resources {
+			for _, verb := range rule.Verbs {
+				granted[resource+"/"+verb] = true
+			}
+		}
+	}
+	for _, want := range []string{"pods/get", "pods/patch", "events/create", "leases/get", "leases/update"} {
+		if !granted[want] {
+			t.Errorf("agent role does not grant %s", want)
+		}
+	}
+	binding := &rbacv1.RoleBinding{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: "edge-agent"}, binding); err != nil {
+		t.Fatalf("role binding: %v", err)
+	}
+	if len(binding.Subjects) != 1 || binding.Subjects[0].Kind != rbacv1.ServiceAccountKind || binding.Subjects[0].Name != "edge-agent" {
+		t.Errorf("subjects = %+v", binding.Subjects)
+	}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: "edge-agent"}, &corev1.ServiceAccount{}); err != nil {
+		t.Errorf("service account: %v", err)
+	}
+}
+
+func TestVPNServerReconcilerSwitchesDeploymentMode(t *testing.T) {
+	server := testServer("edge")
+	c, scheme := newTestClient(t, server)
+	r := &VPNServerReconciler{Client: c, Scheme: scheme}
+	reconcileServer(t, r, c, server)
+
+	server = reconcileServer(t, r, c, server)
+	server.Spec.DeploymentMode = vpnv1alpha1.DeploymentModeStatefulSet
+	if err := c.Update(context.Background(), server); err != nil {
+		t.Fatal(err)
+	}
+	reconcileServer(t, r, c, server)
+
+	key := types.NamespacedName{Namespace: testNamespace, Name: "edge"}
+	if err := c.Get(context.Background(), key, &appsv1.Deployment{}); !apierrors.IsNotFound(err) {
+		t.Errorf("deployment of the previous mode: %v, want it deleted", err)
+	}
+	statefulSet := &appsv1.StatefulSet{}
+	if err := c.Get(context.Background(), key, statefulSet); err != nil {
+		t.Fatalf("statefulset: %v", err)
+	}
+	if statefulSet.Spec.ServiceName != "edge" {
+		t.Errorf("service name = %q, want edge", statefulSet.Spec.ServiceName)
+	}
+}
+
+func TestVPNServerReconcilerPropagatesConfigChecksum(t *testing.T) {
+	server := testServer("edge")
+	server.Status.ConfigChecksum = "desired"
+	server.Status.ConfigGeneration = server.Generation
+	stale := testPod(server, "edge-a", true, map[string]string{vpnv1alpha1.AppliedConfigChecksumAnnotation: "previous"})
+	current := testPod(server, "edge-b", true, map[string]string{
+		vpnv1alpha1.ConfigChecksumAnnotation:        "desired",
+		vpnv1alpha1.AppliedConfigChecksumAnnotation: "desired",
+	})
+	c, scheme := newTestClient(t, server, stale, current)
+	r := &VPNServerReconciler{Client: c, Scheme: scheme}
+
+	updated := reconcileServer(t, r, c, server)
+	for _, name := range []string{"edge-a", "edge-b"} {
+		pod := &corev1.Pod{}
+		if err := c.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: name}, pod); err != nil {
+			t.Fatal(err)
+		}
+		if got := pod.Annotations[vpnv1alpha1.ConfigChecksumAnnotation]; got != "desired" {
+			t.Errorf("pod %s config checksum = %q, want desired", name, got)
+		}
+	}
+	if updated.Status.AppliedConfigChecksum != "" {
+		t.Errorf("applied checksum = %q while a replica lags, want empty", updated.Status.AppliedConfigChecksum)
+	}
+	if updated.Status.ReadyReplicas != 2 {
+		t.Errorf("ready replicas = %d, want 2", updated.Status.ReadyReplicas)
+	}
+	if updated.Status.ObservedGeneration != 0 || vpnv1alpha1.IsConditionTrue(updated.Status.Conditions, vpnv1alpha1.ConditionReady) {
+		t.Errorf("observed generation = %d, ready = %v while a replica lags, want neither", updated.Status.ObservedGeneration, updated.Status.Conditions)
+	}
+
+	pod := &corev1.Pod{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: "edge-a"}, pod); err != nil {
+		t.Fatal(err)
+	}
+	pod.Annotations[vpnv1alpha1.AppliedConfigChecksumAnnotation] = "desired"
+	if err := c.Update(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+	if updated = reconcileServer(t, r, c, server); updated.Status.AppliedConfigChecksum != "desired" {
+		t.Errorf("applied checksum = %q, want desired", updated.Status.AppliedConfigChecksum)
+	}
+	if updated.Status.ObservedGeneration != 1 || !vpnv1alpha1.IsConditionCurrent(updated.Status.Conditions, vpnv1alpha1.ConditionReady, 1) {
+		t.Errorf("observed generation = %d, conditions = %+v, want generation 1 ready", updated.Status.ObservedGeneration, updated.Status.Conditions)
+	}
+}
+
+func TestPatchServerStatusReappliesOnConflict(t *testing.T) {
+	server := testServer("edge")
+	c, _ := newTestClient(t, server)
+	ctx := context.Background()
+	stale := &vpnv1alpha1.VPNServer{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(server), stale); err != nil {
+		t.Fatal(err)
+	}
+
+	current := stale.DeepCopy()
+	original := current.DeepCopy()
+	vpnv1alpha1.SetCondition(&current.Status.Conditions, vpnv1alpha1.Condition{Type: vpnv1alpha1.ConditionHealthAlert, Status: vpnv1alpha1.ConditionFalse})
+	current.Status.Endpoint = "vpn.example.com:51820"
+	if err := patchServerStatus(ctx, c, current, original); err != nil {
+		t.Fatal(err)
+	}
+
+	// Written from the server read before the first patch
+	original = stale.DeepCopy()
+	vpnv1alpha1.SetCondition(&stale.Status.Conditions, vpnv1alpha1.Condition{Type: vpnv1alpha1.ConditionConfigDrift, Status: vpnv1alpha1.ConditionTrue})
+	stale.Status.PublicKey = testKeyA
+	if err := patchServerStatus(ctx, c, stale, original); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := &vpnv1alpha1.VPNServer{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(server), updated); err != nil {
+		t.Fatal(err)
+	}
+	if vpnv1alpha1.FindCondition(updated.Status.Conditions, vpnv1alpha1.ConditionHealthAlert) == nil ||
+		!vpnv1alpha1.IsConditionTrue(updated.Status.Conditions, vpnv1alpha1.ConditionConfigDrift) {
+		t.Errorf("conditions = %+v, want those of both patches", updated.Status.Conditions)
+	}
+	if updated.Status.Endpoint != "vpn.example.com:51820" || updated.Status.PublicKey != testKeyA {
+		t.Errorf("endpoint = %q, public key = %q, want those of both patches", updated.Status.Endpoint, updated.Status.PublicKey)
+	}
+	if stale.ResourceVersion != updated.ResourceVersion {
+		t.Errorf("resource version = %s, want the one written %s", stale.ResourceVersion, updated.ResourceVersion)
+	}
+}
+
+func TestVPNServerReconcilerReportsScale(t *testing.T) {
+	server := testServer("edge")
+	ready := testPod(server, "edge-a", true, nil)
+	starting := testPod(server, "edge-b", false, nil)
+	c, scheme := newTestClient(t, server, ready, starting)
+	r := &VPNServerReconciler{Client: c, Scheme: scheme}
+
+	updated := reconcileServer(t, r, c, server)
+	if updated.Status.Replicas != 2 || updated.Status.ReadyReplicas != 1 {
+		t.Errorf("replicas = %d, ready = %d, want 2 and 1", updated.Status.Replicas, updated.Status.ReadyReplicas)
+	}
+	selector, err := labels.Parse(updated.Status.Selector)
+	if err != nil {
+		t.Fatalf("selector %q: %v", updated.Status.Selector, err)
+	}
+	if !selector.Matches(labels.Set(ready.Labels)) {
+		t.Errorf("selector %q does not match the server pods", updated.Status.Selector)
+	}
+	if selector.Matches(labels.Set(serverLabels(testServer("core")))) {
+		t.Errorf("selector %q matches the pods of another server", updated.Status.Selector)
+	}
+}
+
+func TestServerPodTemplateAvoidsSaturatedNodes(t *testing.T) {
+	server := testServer("edge")
+	server.Spec.Affinity = &vpnv1alpha1.Affinity{NodeAffinity: &vpnv1alpha1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &vpnv1alpha1.NodeSelector{NodeSelectorTerms: []vpnv1alpha1.NodeSelectorTerm{{
+			MatchExpressions: []vpnv1alpha1.NodeSelectorRequirement{{Key: "pool", Operator: "In", Values: []string{"edge"}}},
+		}}},
+	}}
+	server.Spec.Placement = &vpnv1alpha1.PlacementSpec{AvoidSaturatedNodes: vpnv1alpha1.SaturationAvoidanceRequired}
+	template, err := serverPodTemplate(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	terms := template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 || len(terms[0].MatchExpressions) != 2 {
+		t.Fatalf("node selector terms = %+v, want the term of the spec and the saturation exclusion", terms)
+	}
+	if expression := terms[0].MatchExpressions[1]; expression.Key != vpnv1alpha1.NICSaturatedLabel || expression.Operator != corev1.NodeSelectorOpDoesNotExist {
+		t.Errorf("saturation exclusion = %+v", expression)
+	}
+	if expression := terms[0].MatchExpressions[0]; expression.Key != "pool" || expression.Operator != corev1.NodeSelectorOpIn {
+		t.Errorf("term of the spec = %+v", expression)
+	}
+}
+
+func TestServerPodTemplateRuntimeClass(t *testing.T) {
+	server := testServer("edge")
+	gvisor := "gvisor"
+	server.Spec.RuntimeClassName = &gvisor
+	template, err := serverPodTemplate(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if template.Spec.RuntimeClassName == nil || *template.Spec.RuntimeClassName != "gvisor" {
+		t.Errorf("runtime class = %v, want gvisor", template.Spec.RuntimeClassName)
+	}
+}
+
+func TestServerPodTemplateDataPlane(t *testing.T) {
+	for _, tc := range []struct {
+		implementation vpnv1alpha1.WireGuardImplementation
+		capabilities   []corev1.Capability
+		mount          string
+	}{
+		{"", []corev1.Capability{"NET_ADMIN", "SYS_MODULE"}, "/lib/modules"},
+		{vpnv1alpha1.WireGuardUserspace, []corev1.Capability{"NET_ADMIN"}, "/dev/net/tun"},
+	} {
+		server := testServer("edge")
+		server.Spec.Implementation = tc.implementation
+		template, err := serverPodTemplate(server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		container := template.Spec.Containers[0]
+		if container.SecurityContext == nil || container.SecurityContext.Capabilities == nil ||
+			!reflect.DeepEqual(container.SecurityContext.Capabilities.Add, tc.capabilities) {
+			t.Errorf("%q: security context = %+v, want capabilities %v", tc.implementation, container.SecurityContext, tc.capabilities)
+		}
+		mounted := false
+		for _, mount := range container.VolumeMounts {
+			mounted = mounted || mount.MountPath == tc.mount
+		}
+		if !mounted {
+			t.Errorf("%q: %s is not mounted", tc.implementation, tc.mount)
+		}
+	}
+}
+
+func TestVPNServerReconcilerResolvesIdentityOverrides(t *testing.T) {
+	server := testServer("edge")
+	server.Spec.EndpointOverride = "vpn.example.com:51820"
+	server.Status.PublicKey = testKeyA
+	c, scheme := newTestClient(t, server)
+	r := &VPNServerReconciler{Client: c, Scheme: scheme}
+
+	updated := reconcileServer(t, r, c, server)
+	if updated.Status.Endpoint != "vpn.example.com:51820" {
+		t.Errorf("endpoint = %q, want the override", updated.Status.Endpoint)
+	}
+	if !vpnv1alpha1.IsConditionTrue(updated.Status.Conditions, vpnv1alpha1.ConditionEndpointOverridden) {
+		t.Error("EndpointOverridden is not true")
+	}
+	if updated.Status.PublicKey != testKeyA {
+		t.Errorf("public key = %q, want the discovered key kept", updated.Status.PublicKey)
+	}
+	if vpnv1alpha1.IsConditionTrue(updated.Status.Conditions, vpnv1alpha1.ConditionPublicKeyOverridden) {
+		t.Error("PublicKeyOverridden is true without an override")
+	}
+
+	updated.Spec.EndpointOverride = ""
+	if err := c.Update(context.Background(), updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated = reconcileServer(t, r, c, server); updated.Status.Endpoint != "" {
+		t.Errorf("endpoint = %q after the override was removed, want it left to discovery", updated.Status.Endpoint)
+	}
+}
+
+func TestServerPodTemplateWaitsForKey(t *testing.T) {
+	server := testServer("edge")
+	template, err := serverPodTemplate(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(template.Spec.InitContainers) != 0 {
+		t.Errorf("init containers = %+v without a key store, want none", template.Spec.InitContainers)
+	}
+
+	server.Spec.PrivateKeySecretRef = &vpnv1alpha1.SecretKeyReference{Name: "edge-key", Key: "private"}
+	if template, err = serverPodTemplate(server); err != nil {
+		t.Fatal(err)
+	}
+	if len(template.Spec.InitContainers) != 1 || template.Spec.InitContainers[0].Name != "wait-for-key" {
+		t.Fatalf("init containers = %+v, want wait-for-key", template.Spec.InitContainers)
+	}
+	var volume *corev1.Volume
+	for i := range template.Spec.Volumes {
+		if template.Spec.Volumes[i].Name == keysVolumeName {
+			volume = &template.Spec.Volumes[i]
+		}
+	}
+	if volume == nil || volume.Secret == nil || volume.Secret.SecretName != "edge-key" {
+		t.Fatalf("keys volume = %+v, want the Secret edge-key", volume)
+	}
+	var mounted bool
+	for _, mount := range template.Spec.Containers[0].VolumeMounts {
+		mounted = mounted || (mount.Name == keysVolumeName && mount.MountPath == keysMountPath)
+	}
+	if !mounted {
+		t.Errorf("keys volume is not mounted at %s", keysMountPath)
+	}
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+)
+
+// ZoneEndpointReconciler counts the ready replicas of the zones of servers
+// with zone endpoints, which the VPNPeerReconciler orders the endpoints of
+// clients by.
+type ZoneEndpointReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=vpn.vpn-devops.com,resources=vpnservers/status,verbs=get;update;patch
+
+// Reconcile sets the zone endpoints of the status of a server.
+func (r *ZoneEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	server := &vpnv1alpha1.VPNServer{}
+	if err := r.Get(ctx, req.NamespacedName, server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !server.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	original := server.DeepCopy()
+
+	ready := map[string]int32{}
+	if len(server.Spec.ZoneEndpoints) > 0 {
+		pods := &corev1.PodList{}
+		if err := r.List(ctx, pods, client.InNamespace(server.Namespace), client.MatchingLabels(serverLabels(server))); err != nil {
+			return ctrl.Result{}, err
+		}
+		zones := map[string]string{}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if !isPodReady(pod) || !pod.DeletionTimestamp.IsZero() || pod.Spec.NodeName == "" {
+				continue
+			}
+			zone, ok := zones[pod.Spec.NodeName]
+			if !ok {
+				node := &corev1.Node{}
+				if err := r.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, node); client.IgnoreNotFound(err) != nil {
+					return ctrl.Result{}, err
+				}
+				zone = node.Labels[corev1.LabelTopologyZone]
+				zones[pod.Spec.NodeName] = zone
+			}
+			ready[zone]++
+		}
+	}
+
+	server.Status.ZoneEndpoints = nil
+	for _, endpoint := range server.Spec.ZoneEndpoints {
+		server.Status.ZoneEndpoints = append(server.Status.ZoneEndpoints, vpnv1alpha1.ZoneEndpointStatus{
+			ZoneEndpoint:  endpoint,
+			ReadyReplicas: ready[endpoint.Zone],
+		})
+	}
+	if equality.Semantic.DeepEqual(original.Status, server.Status) {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{}, patchServerStatus(ctx, r.Client, server, original)
+}
+
+// clientEndpoints returns the endpoints of the client of a peer in failover
+// order: the endpoints of the zones with ready replicas, those matching the
+// earliest region hint of the peer first, on the listen port the peer
+// selected. It returns nil when no zone endpoint is healthy, and clients
+// then connect to the server endpoint.
+func clientEndpoints(server *vpnv1alpha1.VPNServer, peer *vpnv1alpha1.VPNPeer) []string {
+	hints := peer.Spec.RegionHints
+	rank := func(zone vpnv1alpha1.ZoneEndpointStatus) int {
+		for i, hint := range hints {
+			if strings.EqualFold(hint, zone.Zone) || (zone.Region != "" && strings.EqualFold(hint, zone.Region)) {
+				return i
+			}
+		}
+		return len(hints)
+	}
+
+	var healthy []vpnv1alpha1.ZoneEndpointStatus
+	for _, zone := range server.Status.ZoneEndpoints {
+		if zone.ReadyReplicas > 0 {
+			healthy = append(healthy, zone)
+		}
+	}
+	// Zones matching no hint keep the order of spec.zoneEndpoints
+	sort.SliceStable(healthy, func(i, j int) bool { return rank(healthy[i]) < rank(healthy[j]) })
+
+	var endpoints []string
+	for _, zone := range healthy {
+		endpoints = append(endpoints, withPort(zone.Endpoint, peer.Spec.EndpointPort))
+	}
+	return endpoints
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ZoneEndpointReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("zoneendpoints").
+		For(&vpnv1alpha1.VPNServer{}).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(serverForPod)).
+		Complete(instrument(mgr, "zoneendpoints", &vpnv1alpha1.VPNServer{}, r))
+}
@@ -0,0 +1,22 @@
+#!/usr/bin/env bash
+# Generates the typed clientset, listers and informers of the wireflow API
+# into client/, published as the github.com/vpn-devops/vpn-operator/client
+# module. Run from anywhere; k8s.io/code-generator is resolved from the
+# module cache unless CODEGEN_PKG points at a checkout.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+SCRIPT_ROOT=$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd -P)
+CODEGEN_VERSION=${CODEGEN_VERSION:-v0.33.3}
+CODEGEN_PKG=${CODEGEN_PKG:-$(cd "${SCRIPT_ROOT}" && go mod download -json "k8s.io/code-generator@${CODEGEN_VERSION}" | sed -n 's/^\t"Dir": "\(.*\)",$/\1/p')}
+
+source "${CODEGEN_PKG}/kube_codegen.sh"
+
+kube::codegen::gen_client \
+    --with-watch \
+    --output-dir "${SCRIPT_ROOT}/client" \
+    --output-pkg "github.com/vpn-devops/vpn-operator/client" \
+    --boilerplate "${SCRIPT_ROOT}/hack/boilerplate.go.txt" \
+    "${SCRIPT_ROOT}"
@@ -1,22 +1,41 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/yaml"
 
 	vpnv1alpha1 "github.com/vpn-devops/vpn-operator/api/v1alpha1"
+	vpnv1beta1 "github.com/vpn-devops/vpn-operator/api/v1beta1"
 	"github.com/vpn-devops/vpn-operator/controllers"
+	"github.com/vpn-devops/vpn-operator/pkg/asn"
+	"github.com/vpn-devops/vpn-operator/pkg/catalog"
+	"github.com/vpn-devops/vpn-operator/pkg/certs"
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+	"github.com/vpn-devops/vpn-operator/pkg/events"
+	"github.com/vpn-devops/vpn-operator/pkg/mail"
+	"github.com/vpn-devops/vpn-operator/pkg/nodemetrics"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -29,6 +48,9 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(vpnv1alpha1.AddToScheme(scheme))
+	// Registering the promoted versions serves their conversion webhook
+	// along with the webhooks of the hub kinds
+	utilruntime.Must(vpnv1beta1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -36,11 +58,165 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var eventBurst int
+	var eventQPS float64
+	var eventWindow time.Duration
+	var federationMode string
+	var tlsOpts certs.Options
+	var tlsMode string
+	var tlsDNSNames string
+	var missingRefPolicy string
+	var escrowRecipient string
+	var escrowNamespace string
+	var vaultRole string
+	var nodeRoutesConfigMap string
+	var nodeMetricsURL string
+	var nicSaturation controllers.NICSaturationMonitor
+	var asnLookup bool
+	var fleetDefaultsFile string
+	var gracefulShutdownTimeout time.Duration
+	var catalogBackend string
+	var catalogOpts catalog.Options
+	var catalogTokenFile string
+	var chatAddress string
+	var postureAddress string
+	var enrollmentAddress string
+	var customMetricsAddress string
+	var peerEventsAddress string
+	var peerEventTrafficThreshold int64
+	var metricsServiceSelector string
+	var metricsPortName string
+	var enrollmentURL string
+	var mailOpts mail.Options
+	var smtpPasswordFile string
+	var eventWebhookURL string
+	var eventWebhookTokenFile string
+	var eventNATSSink events.NATSSink
+	var eventNATSTokenFile string
+	var eventFilter string
+	var eventQueueSize int
+	var operatorMode string
+	var modeConfigMap string
+	var peeringClusterName string
+	var peeringSigningKeySecret string
+	var enrollmentVerifierSecret string
+	var postureVerifierSecret string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.IntVar(&eventBurst, "event-burst", 25,
+		"The number of events a single object may emit before --event-qps applies.")
+	flag.Float64Var(&eventQPS, "event-qps", 0.1,
+		"The sustained rate of events per second allowed for a single object.")
+	flag.DurationVar(&eventWindow, "event-aggregation-window", 10*time.Minute,
+		"The window during which events with the same reason for the same object are aggregated.")
+	flag.StringVar(&federationMode, "federation-mode", "standalone",
+		"Either \"standalone\" to manage VPN servers in this cluster, or \"hub\" to manage them "+
+			"across the member clusters registered with MemberCluster objects.")
+	flag.StringVar(&missingRefPolicy, "missing-ref-policy", "reject",
+		"How the admission webhook treats VPNPeers referencing objects that do not exist: "+
+			"\"reject\" them, or admit them as \"pending\" until the references resolve.")
+	flag.StringVar(&tlsMode, "tls-mode", "",
+		"How certificates for the operator's HTTPS endpoints are obtained: \"self-signed\", "+
+			"\"cert-manager\" (falls back to self-signed when cert-manager is not installed) or \"acme\" (HTTP-01). "+
+			"Use cert-manager with a DNS-01 issuer for endpoints that are not reachable over HTTP. Disabled if empty.")
+	flag.StringVar(&tlsDNSNames, "tls-dns-names", "", "Comma separated DNS names of the operator's HTTPS endpoints.")
+	flag.StringVar(&tlsOpts.SecretName, "tls-secret-name", "vpn-operator-tls", "The Secret the certificate is stored in.")
+	flag.DurationVar(&tlsOpts.RenewBefore, "tls-renew-before", 30*24*time.Hour, "How long before expiry certificates are renewed.")
+	flag.StringVar(&tlsOpts.IssuerName, "tls-issuer-name", "", "The cert-manager issuer used in cert-manager mode.")
+	flag.StringVar(&tlsOpts.IssuerKind, "tls-issuer-kind", "Issuer", "The kind of the cert-manager issuer, Issuer or ClusterIssuer.")
+	flag.StringVar(&tlsOpts.ACMEDirectoryURL, "acme-directory-url", "", "The ACME directory URL. Defaults to Let's Encrypt.")
+	flag.StringVar(&tlsOpts.ACMEEmail, "acme-email", "", "The contact email of the ACME account.")
+	flag.StringVar(&tlsOpts.ChallengeAddress, "acme-http-address", ":8082", "The address ACME HTTP-01 challenges are served on.")
+	flag.StringVar(&escrowRecipient, "escrow-recipient", "",
+		"The organisation recovery public key, in the WireGuard format, private keys of Secrets labelled "+
+			vpnv1alpha1.KeyEscrowLabel+"=true are additionally sealed to. Key escrow is disabled if empty.")
+	flag.StringVar(&escrowNamespace, "escrow-namespace", "wireflow-escrow", "The namespace escrowed keys are stored in.")
+	flag.StringVar(&vaultRole, "vault-role", "wireflow-operator",
+		"The role of the Kubernetes auth method of Vault the operator logs in with to manage the keys of servers "+
+			"with a Vault keyStore.")
+	flag.StringVar(&nodeRoutesConfigMap, "node-routes-configmap", "wireflow-node-routes",
+		"The ConfigMap in the operator namespace the routes installed by the node-routes DaemonSet are published in.")
+	flag.StringVar(&nodeMetricsURL, "node-metrics-url", "",
+		"The URL of the Prometheus API node-exporter metrics are read from to steer server replicas away from "+
+			"NIC-saturated nodes. Disabled if empty.")
+	flag.StringVar(&nicSaturation.Query, "nic-utilization-query", nodemetrics.DefaultUtilizationQuery,
+		"The query returning the NIC utilization of each node as a fraction of its link speed.")
+	flag.Float64Var(&nicSaturation.Threshold, "nic-saturation-threshold", 0.8,
+		"The NIC utilization above which a node is considered saturated.")
+	flag.DurationVar(&nicSaturation.Interval, "nic-saturation-interval", time.Minute,
+		"How often the NIC utilization of nodes is read.")
+	flag.BoolVar(&asnLookup, "asn-lookup", false,
+		"Resolve the autonomous systems of peer endpoints through the Team Cymru DNS service, so that usage "+
+			"anomaly detection can report peers connecting from new networks. Sends peer endpoint IPs to a third party.")
+	flag.StringVar(&fleetDefaultsFile, "fleet-defaults", "",
+		"A YAML file of the DNS, persistent keepalive and MTU applied to every peer unless a VPNServerClass, "+
+			"VPNServer, routing profile, VPNPeerGroup or the VPNPeer sets them.")
+	flag.DurationVar(&gracefulShutdownTimeout, "graceful-shutdown-timeout", 30*time.Second,
+		"How long in-flight reconciles and status writes may take to complete on shutdown.")
+	flag.StringVar(&catalogBackend, "catalog", "",
+		"The service catalog connected peers are published in, consul or etcd. Disabled if empty.")
+	flag.StringVar(&catalogOpts.Address, "catalog-address", "",
+		"The URL of the catalog API, e.g. http://consul:8500 or http://etcd:2379.")
+	flag.StringVar(&catalogTokenFile, "catalog-token-file", "", "A file holding the Consul ACL token.")
+	flag.StringVar(&catalogOpts.Service, "catalog-service", "wireflow-peer", "The Consul service peers are registered as.")
+	flag.StringVar(&chatAddress, "chat-bind-address", "",
+		"The HTTPS address the chat commands of VPNChatIntegrations are served on, e.g. :8083. Requires --tls-mode. "+
+			"Disabled if empty.")
+	flag.StringVar(&postureAddress, "posture-bind-address", "",
+		"The HTTPS address devices of peers with a device binding re-validate their posture on, e.g. :8084. "+
+			"Requires --tls-mode. Disabled if empty.")
+	flag.StringVar(&enrollmentAddress, "enrollment-bind-address", "",
+		"The HTTPS address invitees of placeholder peers and users of VPNSelfEnrollments submit their public key on, "+
+			"e.g. :8085. Requires --tls-mode. Disabled if empty.")
+	flag.StringVar(&enrollmentURL, "enrollment-url", "",
+		"The URL invitees reach the enrollment endpoint on, linked from invite emails, e.g. https://vpn.example.com.")
+	flag.StringVar(&mailOpts.Address, "smtp-address", "",
+		"The host:port of the SMTP relay invites are emailed through. Invites are not emailed if empty.")
+	flag.StringVar(&mailOpts.From, "smtp-from", "", "The sender address of emails, e.g. \"Wireflow <vpn@example.com>\".")
+	flag.StringVar(&mailOpts.Username, "smtp-username", "", "The username to authenticate to the SMTP relay with.")
+	flag.StringVar(&smtpPasswordFile, "smtp-password-file", "", "A file holding the password of the SMTP relay.")
+	flag.StringVar(&metricsServiceSelector, "metrics-service-selector", "control-plane=controller-manager",
+		"The labels of the operator's metrics Service, which the ServiceMonitors of servers with monitoring enabled select.")
+	flag.StringVar(&metricsPortName, "metrics-port-name", "http", "The name of the port of the operator's metrics Service.")
+	flag.StringVar(&customMetricsAddress, "custom-metrics-bind-address", "",
+		"The HTTPS address the custom and external metrics APIs are served on, for APIServices. Disabled if empty.")
+	flag.StringVar(&peerEventsAddress, "peer-events-bind-address", "",
+		"The HTTPS address the handshakes, connections and traffic of peers are streamed on, e.g. :8086. "+
+			"Requires --tls-mode. Disabled if empty.")
+	flag.Int64Var(&peerEventTrafficThreshold, "peer-event-traffic-threshold", 64<<20,
+		"The traffic of a peer in bytes between the traffic events of the peer event stream.")
+	flag.StringVar(&catalogOpts.Prefix, "catalog-prefix", "/wireflow/peers", "The etcd key prefix peers are stored under.")
+	flag.StringVar(&eventWebhookURL, "event-webhook-url", "",
+		"The URL the events of the operator are POSTed to as JSON. Disabled if empty.")
+	flag.StringVar(&eventWebhookTokenFile, "event-webhook-token-file", "", "A file holding the bearer token of the event webhook.")
+	flag.StringVar(&eventNATSSink.Address, "event-nats-address", "",
+		"The host:port of the NATS server the events of the operator are published to. Disabled if empty.")
+	flag.StringVar(&eventNATSSink.Subject, "event-nats-subject", "wireflow.events",
+		"The subject prefix events are published under, followed by the kind of their object and their reason.")
+	flag.StringVar(&eventNATSTokenFile, "event-nats-token-file", "", "A file holding the token of the NATS server.")
+	flag.StringVar(&eventFilter, "event-sink-filter", "",
+		"Comma separated event types and reasons published to the event webhook and NATS, e.g. "+
+			"\"Warning,InviteAccepted\". Every event if empty.")
+	flag.StringVar(&operatorMode, "mode", controllers.OperatorModeActive,
+		"Either \"active\" to reconcile devices and owned resources, or \"read-only\" to only reconcile status and "+
+			"serve the read APIs, for standby clusters restored from a backup, until promoted with wireflow promote.")
+	flag.StringVar(&modeConfigMap, "mode-configmap", "wireflow-operator-mode",
+		"The ConfigMap in the operator namespace wireflow promote promotes read-only operators with.")
+	flag.StringVar(&peeringClusterName, "peering-cluster-name", "",
+		"The name of this cluster recorded in the peering bundles of exported servers.")
+	flag.StringVar(&peeringSigningKeySecret, "peering-signing-key-secret", "wireflow-peering-signing-key",
+		"The Secret in the operator namespace holding the key peering bundles are signed with, generated if missing.")
+	flag.StringVar(&enrollmentVerifierSecret, "enrollment-verifier-key-secret", "wireflow-enrollment-verifier-key",
+		"The Secret in the operator namespace holding the key the enrollment server issues challenges with, "+
+			"shared by the replicas and generated if missing.")
+	flag.StringVar(&postureVerifierSecret, "posture-verifier-key-secret", "wireflow-posture-verifier-key",
+		"The Secret in the operator namespace holding the key the posture server issues challenges with, "+
+			"shared by the replicas and generated if missing.")
+	flag.IntVar(&eventQueueSize, "event-queue-size", 1000,
+		"The number of events buffered per event sink before further events are dropped.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -49,35 +225,706 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	eventBroadcaster := events.NewBroadcaster(events.Options{
+		Window: eventWindow,
+		Burst:  eventBurst,
+		QPS:    float32(eventQPS),
+	})
+	defer eventBroadcaster.Shutdown()
+
+	config := ctrl.GetConfigOrDie()
+	modeKey := client.ObjectKey{Namespace: operatorNamespace(), Name: modeConfigMap}
+	var newClient client.NewClientFunc
+	switch operatorMode {
+	case controllers.OperatorModeActive:
+	case controllers.OperatorModeReadOnly:
+		promoted, err := controllers.OperatorPromoted(context.Background(), uncachedClient(config), modeKey)
+		if err != nil {
+			setupLog.Error(err, "unable to read the operator mode", "configmap", modeKey)
+			os.Exit(1)
+		}
+		if promoted {
+			setupLog.Info("running in the active mode, the operator was promoted", "configmap", modeKey)
+			operatorMode = controllers.OperatorModeActive
+			break
+		}
+		setupLog.Info("running in the read-only mode, the enrollment, posture and chat servers and the catalog are disabled")
+		newClient = controllers.NewReadOnlyClient
+		// They act on behalf of users, which writes cannot be dropped for
+		chatAddress, postureAddress, enrollmentAddress, catalogBackend = "", "", "", ""
+	default:
+		setupLog.Error(nil, "invalid mode", "mode", operatorMode)
+		os.Exit(1)
+	}
+
+	mgr, err := ctrl.NewManager(config, ctrl.Options{
 		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
+		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "vpn-operator.vpn-devops.com",
+		// The process exits once the manager stops, so the lease can be
+		// handed over to the next operator right away
+		LeaderElectionReleaseOnCancel: true,
+		GracefulShutdownTimeout:       &gracefulShutdownTimeout,
+		EventBroadcaster:              eventBroadcaster,
+		NewClient:                     newClient,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.VPNServerReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "VPNServer")
+	eventBus := events.NewBus(eventQueueSize)
+	sinkFilter := parseEventFilter(eventFilter)
+	if eventWebhookURL != "" {
+		token, err := readSecretFile(eventWebhookTokenFile)
+		if err != nil {
+			setupLog.Error(err, "unable to read the event webhook token")
+			os.Exit(1)
+		}
+		eventBus.AddSink(&events.WebhookSink{
+			URL:        eventWebhookURL,
+			Token:      token,
+			HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		}, sinkFilter)
+	}
+	if eventNATSSink.Address != "" {
+		if eventNATSSink.Token, err = readSecretFile(eventNATSTokenFile); err != nil {
+			setupLog.Error(err, "unable to read the NATS token")
+			os.Exit(1)
+		}
+		eventBus.AddSink(&eventNATSSink, sinkFilter)
+	}
+	defer eventBus.Watch(eventBroadcaster).Stop()
+	if err := mgr.Add(eventBus); err != nil {
+		setupLog.Error(err, "unable to add event bus")
 		os.Exit(1)
 	}
-	if err = (&controllers.VPNClientReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "VPNClient")
+
+	if missingRefPolicy != "reject" && missingRefPolicy != "pending" {
+		setupLog.Error(nil, "invalid missing ref policy", "policy", missingRefPolicy)
 		os.Exit(1)
 	}
+
+	var fleetDefaults vpnv1alpha1.ConfigLayer
+	if fleetDefaultsFile != "" {
+		raw, err := os.ReadFile(fleetDefaultsFile)
+		if err == nil {
+			err = yaml.UnmarshalStrict(raw, &fleetDefaults)
+		}
+		if err != nil {
+			setupLog.Error(err, "unable to load fleet defaults", "file", fleetDefaultsFile)
+			os.Exit(1)
+		}
+	}
+
+	if err := controllers.SetupIndexes(context.Background(), mgr); err != nil {
+		setupLog.Error(err, "unable to set up field indexes")
+		os.Exit(1)
+	}
+
+	var certManager *certs.Manager
+	if tlsMode != "" {
+		tlsOpts.Mode = certs.Mode(tlsMode)
+		tlsOpts.DNSNames = strings.Split(tlsDNSNames, ",")
+		tlsOpts.Namespace = operatorNamespace()
+		certManager, err = certs.NewManager(mgr.GetClient(), tlsOpts)
+		if err != nil {
+			setupLog.Error(err, "unable to set up certificate manager")
+			os.Exit(1)
+		}
+		if err := mgr.Add(certManager); err != nil {
+			setupLog.Error(err, "unable to add certificate manager")
+			os.Exit(1)
+		}
+	} else if chatAddress != "" || postureAddress != "" || enrollmentAddress != "" || peerEventsAddress != "" {
+		// They authenticate users and devices with tokens and proofs that
+		// must not cross the network in clear text
+		setupLog.Error(nil, "the enrollment, posture, chat and peer event servers are only served over TLS, set --tls-mode")
+		os.Exit(1)
+	}
+
+	switch federationMode {
+	case "standalone":
+		if err = (&controllers.VPNServerReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VPNServer")
+			os.Exit(1)
+		}
+		if err = (&controllers.VPNClientReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VPNClient")
+			os.Exit(1)
+		}
+		if err = (&controllers.VPNPeerReconciler{
+			Client:        mgr.GetClient(),
+			Scheme:        mgr.GetScheme(),
+			Recorder:      mgr.GetEventRecorderFor("vpn-operator"),
+			FleetDefaults: fleetDefaults,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VPNPeer")
+			os.Exit(1)
+		}
+		if err = (&controllers.VPNAccessPolicyReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VPNAccessPolicy")
+			os.Exit(1)
+		}
+		if err = (&controllers.PeerEndpointReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PeerEndpoint")
+			os.Exit(1)
+		}
+		if err = (&controllers.PeerStatsReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PeerStats")
+			os.Exit(1)
+		}
+		if err = (&controllers.PathMTUReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PathMTU")
+			os.Exit(1)
+		}
+		if err = (&controllers.VPNIngressMapReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VPNIngressMap")
+			os.Exit(1)
+		}
+		if err = (&controllers.KernelCompatReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "KernelCompat")
+			os.Exit(1)
+		}
+		if err = (&controllers.PeerDelegationReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PeerDelegation")
+			os.Exit(1)
+		}
+		if err = (&controllers.IPAMReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "IPAM")
+			os.Exit(1)
+		}
+		if err = (&controllers.PresharedKeyReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PresharedKey")
+			os.Exit(1)
+		}
+		if err = (&controllers.ClientConfigReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ClientConfig")
+			os.Exit(1)
+		}
+		if err = (&controllers.DriftReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Drift")
+			os.Exit(1)
+		}
+		if err = (&controllers.PerformanceReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Performance")
+			os.Exit(1)
+		}
+		if err = (&controllers.DataPlaneReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "DataPlane")
+			os.Exit(1)
+		}
+		if err = (&controllers.BandwidthReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Bandwidth")
+			os.Exit(1)
+		}
+		if err = (&controllers.ShardingReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Sharding")
+			os.Exit(1)
+		}
+		if err = (&controllers.HighAvailabilityReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "HighAvailability")
+			os.Exit(1)
+		}
+		if err = (&controllers.MaintenanceReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Maintenance")
+			os.Exit(1)
+		}
+		if err = (&controllers.PeeringExportReconciler{
+			Client:           mgr.GetClient(),
+			Scheme:           mgr.GetScheme(),
+			Recorder:         mgr.GetEventRecorderFor("vpn-operator"),
+			ClusterName:      peeringClusterName,
+			SigningKeySecret: client.ObjectKey{Namespace: operatorNamespace(), Name: peeringSigningKeySecret},
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PeeringExport")
+			os.Exit(1)
+		}
+		if err = (&controllers.VPNPeeringReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VPNPeering")
+			os.Exit(1)
+		}
+		if err = (&controllers.AutoscalingReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Autoscaling")
+			os.Exit(1)
+		}
+		if err = (&controllers.ZoneEndpointReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ZoneEndpoint")
+			os.Exit(1)
+		}
+		if err = (&controllers.NodeEndpointReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "NodeEndpoint")
+			os.Exit(1)
+		}
+		if err = (&controllers.ServerServiceReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+			Resolver: &controllers.EndpointResolver{},
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ServerService")
+			os.Exit(1)
+		}
+		if err = (&controllers.RouteExportReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "RouteExport")
+			os.Exit(1)
+		}
+		if err = (&controllers.IdlePeerReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "IdlePeer")
+			os.Exit(1)
+		}
+		healthAlert := &controllers.HealthAlertReconciler{
+			Client:     mgr.GetClient(),
+			Scheme:     mgr.GetScheme(),
+			Recorder:   mgr.GetEventRecorderFor("vpn-operator"),
+			HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		}
+		if asnLookup {
+			healthAlert.ASNResolver = &asn.Resolver{}
+		}
+		if err = healthAlert.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "HealthAlert")
+			os.Exit(1)
+		}
+		if err = (&controllers.VPNIPPoolReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VPNIPPool")
+			os.Exit(1)
+		}
+		if err = (&controllers.VPNPoolMigrationReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VPNPoolMigration")
+			os.Exit(1)
+		}
+		if err = (&controllers.GlobalPolicyReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VPNGlobalPolicy")
+			os.Exit(1)
+		}
+		if err = (&controllers.PeerRevocationReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "PeerRevocation")
+			os.Exit(1)
+		}
+		if err = (&controllers.ConnectionAuditReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ConnectionAudit")
+			os.Exit(1)
+		}
+		serviceSelector, err := labels.ConvertSelectorToLabelsMap(metricsServiceSelector)
+		if err != nil {
+			setupLog.Error(err, "invalid metrics service selector")
+			os.Exit(1)
+		}
+		if err = (&controllers.ServiceMonitorReconciler{
+			Client:          mgr.GetClient(),
+			Scheme:          mgr.GetScheme(),
+			Recorder:        mgr.GetEventRecorderFor("vpn-operator"),
+			Namespace:       operatorNamespace(),
+			ServiceSelector: serviceSelector,
+			PortName:        metricsPortName,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ServiceMonitor")
+			os.Exit(1)
+		}
+		metrics.Registry.MustRegister(&controllers.PeerMetricsCollector{Reader: mgr.GetClient()})
+		if err = (&controllers.NodeRoutesReconciler{
+			Client:    mgr.GetClient(),
+			Scheme:    mgr.GetScheme(),
+			Namespace: operatorNamespace(),
+			Name:      nodeRoutesConfigMap,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "NodeRoutes")
+			os.Exit(1)
+		}
+		if nodeMetricsURL != "" {
+			nicSaturation.Client = mgr.GetClient()
+			nicSaturation.Metrics = &nodemetrics.Client{
+				URL:        nodeMetricsURL,
+				HTTPClient: &http.Client{Timeout: 30 * time.Second},
+			}
+			if err := mgr.Add(&nicSaturation); err != nil {
+				setupLog.Error(err, "unable to add NIC saturation monitor")
+				os.Exit(1)
+			}
+			if err = (&controllers.PlacementReconciler{
+				Client:   mgr.GetClient(),
+				Scheme:   mgr.GetScheme(),
+				Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+				Monitor:  &nicSaturation,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "Placement")
+				os.Exit(1)
+			}
+		}
+		if err = (&controllers.VPNBrandingReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VPNBranding")
+			os.Exit(1)
+		}
+		if err = (&controllers.VPNChatIntegrationReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VPNChatIntegration")
+			os.Exit(1)
+		}
+		if chatAddress != "" {
+			if err := mgr.Add(&controllers.ChatCommandServer{
+				Client:         mgr.GetClient(),
+				Address:        chatAddress,
+				GetCertificate: certManager.GetCertificate,
+				HTTPClient:     &http.Client{Timeout: 30 * time.Second},
+			}); err != nil {
+				setupLog.Error(err, "unable to add chat command server")
+				os.Exit(1)
+			}
+		}
+		if err = (&controllers.DeletionPolicyReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "DeletionPolicy")
+			os.Exit(1)
+		}
+		if err = (&controllers.ServerKeyReconciler{
+			Client:    mgr.GetClient(),
+			Scheme:    mgr.GetScheme(),
+			Recorder:  mgr.GetEventRecorderFor("vpn-operator"),
+			VaultRole: vaultRole,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ServerKey")
+			os.Exit(1)
+		}
+		if err = (&controllers.DeviceBindingReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "DeviceBinding")
+			os.Exit(1)
+		}
+		if err = (&controllers.ClientVersionReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ClientVersion")
+			os.Exit(1)
+		}
+		if postureAddress != "" {
+			verifier, err := controllers.LoadVerifier(context.Background(), uncachedClient(config),
+				client.ObjectKey{Namespace: operatorNamespace(), Name: postureVerifierSecret})
+			if err != nil {
+				setupLog.Error(err, "unable to create posture verifier")
+				os.Exit(1)
+			}
+			if err := mgr.Add(&controllers.PostureServer{
+				Client:         mgr.GetClient(),
+				Address:        postureAddress,
+				GetCertificate: certManager.GetCertificate,
+				Verifier:       verifier,
+				Recorder:       mgr.GetEventRecorderFor("vpn-operator"),
+			}); err != nil {
+				setupLog.Error(err, "unable to add posture server")
+				os.Exit(1)
+			}
+		}
+		var mailer mail.Sender
+		if mailOpts.Address != "" {
+			if smtpPasswordFile != "" {
+				password, err := os.ReadFile(smtpPasswordFile)
+				if err != nil {
+					setupLog.Error(err, "unable to read the SMTP password")
+					os.Exit(1)
+				}
+				mailOpts.Password = strings.TrimSpace(string(password))
+			}
+			if mailer, err = mail.NewSMTP(mailOpts); err != nil {
+				setupLog.Error(err, "unable to create the mailer")
+				os.Exit(1)
+			}
+		}
+		if err = (&controllers.VPNSelfEnrollmentReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VPNSelfEnrollment")
+			os.Exit(1)
+		}
+		if err = (&controllers.InviteReconciler{
+			Client:        mgr.GetClient(),
+			Scheme:        mgr.GetScheme(),
+			Recorder:      mgr.GetEventRecorderFor("vpn-operator"),
+			Mailer:        mailer,
+			EnrollmentURL: enrollmentURL,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Invite")
+			os.Exit(1)
+		}
+		if enrollmentAddress != "" {
+			verifier, err := controllers.LoadVerifier(context.Background(), uncachedClient(config),
+				client.ObjectKey{Namespace: operatorNamespace(), Name: enrollmentVerifierSecret})
+			if err != nil {
+				setupLog.Error(err, "unable to create enrollment verifier")
+				os.Exit(1)
+			}
+			if err := mgr.Add(&controllers.EnrollmentServer{
+				Client:         mgr.GetClient(),
+				Address:        enrollmentAddress,
+				GetCertificate: certManager.GetCertificate,
+				Verifier:       verifier,
+				Recorder:       mgr.GetEventRecorderFor("vpn-operator"),
+			}); err != nil {
+				setupLog.Error(err, "unable to add enrollment server")
+				os.Exit(1)
+			}
+		}
+		if catalogBackend != "" {
+			if catalogTokenFile != "" {
+				token, err := os.ReadFile(catalogTokenFile)
+				if err != nil {
+					setupLog.Error(err, "unable to read the catalog token")
+					os.Exit(1)
+				}
+				catalogOpts.Token = strings.TrimSpace(string(token))
+			}
+			catalogOpts.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+			peerCatalog, err := catalog.New(catalogBackend, catalogOpts)
+			if err != nil {
+				setupLog.Error(err, "invalid catalog")
+				os.Exit(1)
+			}
+			if err = (&controllers.CatalogReconciler{
+				Client:  mgr.GetClient(),
+				Scheme:  mgr.GetScheme(),
+				Catalog: peerCatalog,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "Catalog")
+				os.Exit(1)
+			}
+		}
+		if err = (&controllers.VPNNetworkReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VPNNetwork")
+			os.Exit(1)
+		}
+		if err = (&controllers.ConnectivityCheckReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VPNConnectivityCheck")
+			os.Exit(1)
+		}
+		if escrowRecipient != "" {
+			recipient, err := escrow.ParseKey(escrowRecipient)
+			if err != nil {
+				setupLog.Error(err, "invalid escrow recipient")
+				os.Exit(1)
+			}
+			if err = (&controllers.KeyEscrowReconciler{
+				Client:    mgr.GetClient(),
+				Scheme:    mgr.GetScheme(),
+				Recipient: recipient,
+				Namespace: escrowNamespace,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "KeyEscrow")
+				os.Exit(1)
+			}
+		}
+	case "hub":
+		clusters := controllers.NewClusterSet()
+		if err = (&controllers.MemberClusterReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Clusters: clusters,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "MemberCluster")
+			os.Exit(1)
+		}
+		if err = (&controllers.FederatedVPNServerReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Clusters: clusters,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "FederatedVPNServer")
+			os.Exit(1)
+		}
+		if err = (&controllers.VPNNetworkReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("vpn-operator"),
+			Clusters: clusters,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "VPNNetwork")
+			os.Exit(1)
+		}
+	default:
+		setupLog.Error(nil, "invalid federation mode", "mode", federationMode)
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&vpnv1alpha1.VPNServer{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "VPNServer")
+			os.Exit(1)
+		}
+		if err = (&vpnv1alpha1.VPNPeer{}).SetupWebhookWithManager(mgr, missingRefPolicy == "pending"); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "VPNPeer")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
+	if customMetricsAddress != "" {
+		metricsServer := &controllers.CustomMetricsServer{
+			Client:    mgr.GetClient(),
+			APIReader: mgr.GetAPIReader(),
+			Informers: mgr.GetCache(),
+			Address:   customMetricsAddress,
+		}
+		if certManager != nil {
+			metricsServer.GetCertificate = certManager.GetCertificate
+		}
+		if err := mgr.Add(metricsServer); err != nil {
+			setupLog.Error(err, "unable to add custom metrics server")
+			os.Exit(1)
+		}
+	}
+
+	if peerEventsAddress != "" {
+		if err := mgr.Add(&controllers.PeerEventStream{
+			Client:           mgr.GetClient(),
+			Informers:        mgr.GetCache(),
+			Address:          peerEventsAddress,
+			GetCertificate:   certManager.GetCertificate,
+			TrafficThreshold: peerEventTrafficThreshold,
+		}); err != nil {
+			setupLog.Error(err, "unable to add peer event stream")
+			os.Exit(1)
+		}
+	}
+
+	if err := mgr.Add(&controllers.ShutdownMarker{
+		Client:  mgr.GetClient(),
+		Timeout: gracefulShutdownTimeout,
+	}); err != nil {
+		setupLog.Error(err, "unable to add shutdown marker")
+		os.Exit(1)
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -87,12 +934,78 @@ func main() {
 		os.Exit(1)
 	}
 
+	ctx, cancel := context.WithCancel(ctrl.SetupSignalHandler())
+	defer cancel()
+	promoted := false
+	if operatorMode == controllers.OperatorModeReadOnly {
+		if err := mgr.Add(&controllers.PromotionWatcher{
+			Reader:   mgr.GetAPIReader(),
+			Key:      modeKey,
+			Interval: 10 * time.Second,
+			Promoted: func() {
+				promoted = true
+				cancel()
+			},
+		}); err != nil {
+			setupLog.Error(err, "unable to add promotion watcher")
+			os.Exit(1)
+		}
+	}
+
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+	if promoted {
+		setupLog.Info("exiting to restart in the active mode")
+	}
 }
 
+// parseEventFilter parses the comma separated event types and reasons
+// published to the event sinks.
+func parseEventFilter(value string) events.Filter {
+	filter := events.Filter{}
+	for _, item := range strings.Split(value, ",") {
+		switch item = strings.TrimSpace(item); item {
+		case "":
+		case corev1.EventTypeNormal, corev1.EventTypeWarning:
+			filter.Types = append(filter.Types, item)
+		default:
+			filter.Reasons = append(filter.Reasons, item)
+		}
+	}
+	return filter
+}
 
+// readSecretFile returns the trimmed content of a file holding a secret, or
+// an empty string if path is empty.
+func readSecretFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	raw, err := os.ReadFile(path)
+	return strings.TrimSpace(string(raw)), err
+}
+
+// uncachedClient returns a client reading from the API server, for setup
+// that runs before the cache of the manager is started.
+func uncachedClient(config *rest.Config) client.Client {
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		os.Exit(1)
+	}
+	return c
+}
 
+// operatorNamespace returns the namespace the operator runs in.
+func operatorNamespace() string {
+	if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+		return ns
+	}
+	if ns, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
+		return strings.TrimSpace(string(ns))
+	}
+	return "default"
+}
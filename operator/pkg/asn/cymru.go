@@ -0,0 +1,109 @@
+// Package asn resolves the autonomous system IP addresses are announced
+// from, using the DNS interface of the Team Cymru IP to ASN mapping service.
+package asn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultTTL = 24 * time.Hour
+
+// Resolver looks up the origin ASN of IP addresses. Results are cached, as
+// the endpoints of peers rarely move between networks.
+type Resolver struct {
+	// DNS is the resolver TXT records are looked up with,
+	// net.DefaultResolver if nil
+	DNS *net.Resolver
+
+	// TTL is how long results are cached, a day if zero
+	TTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+type cached struct {
+	asn     uint32
+	expires time.Time
+}
+
+// ASN returns the origin ASN of ip, or 0 for addresses that are not
+// announced, such as private ranges.
+func (r *Resolver) ASN(ctx context.Context, ip net.IP) (uint32, error) {
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+		return 0, nil
+	}
+	key := ip.String()
+
+	r.mu.Lock()
+	entry, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.asn, nil
+	}
+
+	dns := r.DNS
+	if dns == nil {
+		dns = net.DefaultResolver
+	}
+	records, err := dns.LookupTXT(ctx, queryName(ip))
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); !ok || !dnsErr.IsNotFound {
+			return 0, err
+		}
+	}
+	var asn uint32
+	if len(records) > 0 {
+		if asn, err = parseRecord(records[0]); err != nil {
+			return 0, err
+		}
+	}
+
+	ttl := r.TTL
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	r.mu.Lock()
+	if r.cache == nil {
+		r.cache = map[string]cached{}
+	}
+	r.cache[key] = cached{asn: asn, expires: time.Now().Add(ttl)}
+	r.mu.Unlock()
+	return asn, nil
+}
+
+// queryName returns the name the origin of ip is published under: the
+// reversed octets, or nibbles for IPv6, in the origin zones.
+func queryName(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.origin.asn.cymru.com", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+	ip16 := ip.To16()
+	var b strings.Builder
+	for i := len(ip16) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "%x.%x.", ip16[i]&0x0f, ip16[i]>>4)
+	}
+	b.WriteString("origin6.asn.cymru.com")
+	return b.String()
+}
+
+// parseRecord returns the ASN of an origin record such as
+// "15169 | 8.8.8.0/24 | US | arin | 2023-12-28". Prefixes announced by
+// several systems list all of them; the first is returned.
+func parseRecord(record string) (uint32, error) {
+	fields := strings.Fields(strings.SplitN(record, "|", 2)[0])
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("malformed origin record %q", record)
+	}
+	asn, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("malformed origin record %q: %w", record, err)
+	}
+	return uint32(asn), nil
+}
@@ -0,0 +1,103 @@
+// Package catalog publishes connected peers into service catalogs, Consul
+// or etcd, for service discovery driven tooling.
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Entry is a peer published in a catalog
+type Entry struct {
+	// ID identifies the entry within its scope
+	ID string `json:"id"`
+
+	// Name is the name of the peer
+	Name string `json:"name"`
+
+	// Address is the tunnel IP of the peer
+	Address string `json:"address"`
+
+	// Tags are the tags of the entry, e.g. group:engineering
+	Tags []string `json:"tags,omitempty"`
+
+	// Meta is additional metadata of the entry
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+// Scope groups the entries published for a server, so that they can be
+// listed and reconciled together
+type Scope struct {
+	// Name identifies the scope, e.g. <namespace>-<server>
+	Name string
+
+	// Address is the tunnel IP of the server
+	Address string
+}
+
+// Catalog is a service catalog peers are published in
+type Catalog interface {
+	// List returns the IDs of the entries of a scope
+	List(ctx context.Context, scope Scope) ([]string, error)
+
+	// Register creates or updates an entry
+	Register(ctx context.Context, scope Scope, entry Entry) error
+
+	// Deregister removes an entry, if it exists
+	Deregister(ctx context.Context, scope Scope, id string) error
+}
+
+// Options configure a catalog
+type Options struct {
+	// Address is the base URL of the catalog API, e.g.
+	// http://consul:8500 or http://etcd:2379
+	Address string
+
+	// Token authenticates to Consul
+	Token string
+
+	// Service is the Consul service entries are registered as
+	Service string
+
+	// Prefix is the etcd key prefix entries are stored under
+	Prefix string
+
+	// HTTPClient is the client used for requests, http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// New returns the catalog of the given backend, consul or etcd.
+func New(backend string, opts Options) (Catalog, error) {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	opts.Address = strings.TrimSuffix(opts.Address, "/")
+	switch backend {
+	case "consul":
+		return &consul{opts}, nil
+	case "etcd":
+		return &etcd{opts}, nil
+	default:
+		return nil, fmt.Errorf("unknown catalog backend %q, expected consul or etcd", backend)
+	}
+}
+
+// do sends a request and decodes the response body with decode, if not nil.
+func do(client *http.Client, req *http.Request, decode func(io.Reader) error) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if decode == nil {
+		return nil
+	}
+	return decode(resp.Body)
+}
@@ -0,0 +1,103 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// consul publishes entries as services of an external node per scope,
+// through the catalog API
+type consul struct {
+	Options
+}
+
+func (c *consul) node(scope Scope) string {
+	return "wireflow-" + scope.Name
+}
+
+func (c *consul) request(ctx context.Context, method, path string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.Address+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if c.Token != "" {
+		req.Header.Set("X-Consul-Token", c.Token)
+	}
+	return req, nil
+}
+
+// List implements Catalog.
+func (c *consul) List(ctx context.Context, scope Scope) ([]string, error) {
+	req, err := c.request(ctx, http.MethodGet, "/v1/catalog/node/"+url.PathEscape(c.node(scope)), nil)
+	if err != nil {
+		return nil, err
+	}
+	// The node is null until the first entry of the scope is registered
+	var node *struct {
+		Services map[string]struct {
+			Service string
+		}
+	}
+	if err := do(c.HTTPClient, req, func(r io.Reader) error { return json.NewDecoder(r).Decode(&node) }); err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, nil
+	}
+	var ids []string
+	for id, service := range node.Services {
+		if service.Service == c.Service {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Register implements Catalog.
+func (c *consul) Register(ctx context.Context, scope Scope, entry Entry) error {
+	meta := map[string]string{"peer": entry.Name}
+	for key, value := range entry.Meta {
+		meta[key] = value
+	}
+	req, err := c.request(ctx, http.MethodPut, "/v1/catalog/register", map[string]interface{}{
+		"Node":     c.node(scope),
+		"Address":  scope.Address,
+		"NodeMeta": map[string]string{"external-node": "true", "external-probe": "false"},
+		"Service": map[string]interface{}{
+			"ID":      entry.ID,
+			"Service": c.Service,
+			"Address": entry.Address,
+			"Tags":    entry.Tags,
+			"Meta":    meta,
+		},
+		"SkipNodeUpdate": true,
+	})
+	if err != nil {
+		return err
+	}
+	return do(c.HTTPClient, req, nil)
+}
+
+// Deregister implements Catalog.
+func (c *consul) Deregister(ctx context.Context, scope Scope, id string) error {
+	req, err := c.request(ctx, http.MethodPut, "/v1/catalog/deregister", map[string]string{
+		"Node":      c.node(scope),
+		"ServiceID": id,
+	})
+	if err != nil {
+		return err
+	}
+	return do(c.HTTPClient, req, nil)
+}
@@ -0,0 +1,83 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// etcd stores entries as JSON values under <prefix>/<scope>/<id>, through
+// the gRPC gateway of etcd v3
+type etcd struct {
+	Options
+}
+
+func (e *etcd) key(scope Scope, id string) string {
+	return strings.TrimSuffix(e.Prefix, "/") + "/" + scope.Name + "/" + id
+}
+
+func (e *etcd) call(ctx context.Context, method string, body, response interface{}) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Address+"/v3/kv/"+method, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var decode func(io.Reader) error
+	if response != nil {
+		decode = func(r io.Reader) error { return json.NewDecoder(r).Decode(response) }
+	}
+	return do(e.HTTPClient, req, decode)
+}
+
+// List implements Catalog.
+func (e *etcd) List(ctx context.Context, scope Scope) ([]string, error) {
+	prefix := e.key(scope, "")
+	// The range end of a prefix is the prefix with its last byte
+	// incremented
+	end := []byte(prefix)
+	end[len(end)-1]++
+	var response struct {
+		KVs []struct {
+			Key []byte `json:"key"`
+		} `json:"kvs"`
+	}
+	if err := e.call(ctx, "range", map[string]interface{}{
+		"key":       []byte(prefix),
+		"range_end": end,
+		"keys_only": true,
+	}, &response); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(response.KVs))
+	for _, kv := range response.KVs {
+		ids = append(ids, strings.TrimPrefix(string(kv.Key), prefix))
+	}
+	return ids, nil
+}
+
+// Register implements Catalog.
+func (e *etcd) Register(ctx context.Context, scope Scope, entry Entry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return e.call(ctx, "put", map[string]string{
+		"key":   base64.StdEncoding.EncodeToString([]byte(e.key(scope, entry.ID))),
+		"value": base64.StdEncoding.EncodeToString(value),
+	}, nil)
+}
+
+// Deregister implements Catalog.
+func (e *etcd) Deregister(ctx context.Context, scope Scope, id string) error {
+	return e.call(ctx, "deleterange", map[string][]byte{
+		"key": []byte(e.key(scope, id)),
+	}, nil)
+}
@@ -0,0 +1,387 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Mode selects how certificates for the operator's HTTPS endpoints are
+// obtained.
+type Mode string
+
+const (
+	// ModeSelfSigned generates and renews a self-signed certificate
+	ModeSelfSigned Mode = "self-signed"
+
+	// ModeCertManager requests the certificate from cert-manager. It falls
+	// back to ModeSelfSigned when cert-manager is not installed.
+	ModeCertManager Mode = "cert-manager"
+
+	// ModeACME obtains the certificate directly from an ACME CA using the
+	// HTTP-01 challenge. Use ModeCertManager with a DNS-01 issuer when the
+	// endpoints are not reachable over HTTP.
+	ModeACME Mode = "acme"
+)
+
+// checkInterval is how often the certificate is checked for renewal
+const checkInterval = time.Hour
+
+var log = ctrl.Log.WithName("certs")
+
+// Options configures the certificate Manager.
+type Options struct {
+	// Mode selects how certificates are obtained
+	Mode Mode
+
+	// DNSNames are the names the certificate is valid for
+	DNSNames []string
+
+	// Namespace and SecretName identify the Secret the certificate is stored in
+	Namespace  string
+	SecretName string
+
+	// RenewBefore is how long before expiry the certificate is renewed
+	RenewBefore time.Duration
+
+	// IssuerName and IssuerKind reference the cert-manager issuer
+	IssuerName string
+	IssuerKind string
+
+	// ACMEDirectoryURL and ACMEEmail configure the ACME account
+	ACMEDirectoryURL string
+	ACMEEmail        string
+
+	// ChallengeAddress is the address HTTP-01 challenges are served on
+	ChallengeAddress string
+}
+
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+//+kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;create;patch
+
+// Manager keeps the TLS certificate of the operator's HTTPS endpoints
+// available and renewed, and serves it to TLS listeners through
+// GetCertificate.
+type Manager struct {
+	client client.Client
+	opts   Options
+
+	mu   sync.RWMutex
+	mode Mode
+	cert *tls.Certificate
+
+	autocert *autocert.Manager
+}
+
+// NewManager returns a Manager for the given options.
+func NewManager(c client.Client, opts Options) (*Manager, error) {
+	if len(opts.DNSNames) == 0 {
+		return nil, fmt.Errorf("at least one DNS name is required")
+	}
+	if opts.RenewBefore == 0 {
+		opts.RenewBefore = 30 * 24 * time.Hour
+	}
+
+	m := &Manager{client: c, opts: opts, mode: opts.Mode}
+	switch opts.Mode {
+	case ModeSelfSigned, ModeCertManager:
+	case ModeACME:
+		directoryURL := opts.ACMEDirectoryURL
+		if directoryURL == "" {
+			directoryURL = autocert.DefaultACMEDirectory
+		}
+		m.autocert = &autocert.Manager{
+			Prompt:      autocert.AcceptTOS,
+			Cache:       &secretCache{client: c, key: m.secretKey()},
+			HostPolicy:  autocert.HostWhitelist(opts.DNSNames...),
+			Email:       opts.ACMEEmail,
+			RenewBefore: opts.RenewBefore,
+			Client:      &acme.Client{DirectoryURL: directoryURL},
+		}
+	default:
+		return nil, fmt.Errorf("unknown certificate mode %q", opts.Mode)
+	}
+	return m, nil
+}
+
+// GetCertificate returns the current certificate. It is meant to be used as
+// tls.Config.GetCertificate.
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if m.autocert != nil {
+		return m.autocert.GetCertificate(hello)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cert == nil {
+		return nil, fmt.Errorf("certificate is not available yet")
+	}
+	return m.cert, nil
+}
+
+// HTTPHandler answers ACME HTTP-01 challenges and passes any other request
+// to fallback. It returns fallback unchanged outside of ModeACME.
+func (m *Manager) HTTPHandler(fallback http.Handler) http.Handler {
+	if m.autocert == nil {
+		return fallback
+	}
+	return m.autocert.HTTPHandler(fallback)
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Every
+// replica serves HTTPS and therefore needs the certificate loaded.
+func (m *Manager) NeedLeaderElection() bool {
+	return false
+}
+
+// Start ensures the certificate exists and keeps it renewed until the
+// context is cancelled.
+func (m *Manager) Start(ctx context.Context) error {
+	if m.autocert != nil {
+		// autocert obtains and renews certificates on demand, only the
+		// challenge server needs to run
+		return m.serveChallenges(ctx)
+	}
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		if err := m.ensure(ctx); err != nil {
+			log.Error(err, "unable to ensure certificate", "secret", m.secretKey())
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// serveChallenges serves ACME HTTP-01 challenges until the context is
+// cancelled.
+func (m *Manager) serveChallenges(ctx context.Context) error {
+	if m.opts.ChallengeAddress == "" {
+		<-ctx.Done()
+		return nil
+	}
+
+	server := &http.Server{
+		Addr:              m.opts.ChallengeAddress,
+		Handler:           m.autocert.HTTPHandler(nil),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	log.Info("serving ACME challenges", "address", m.opts.ChallengeAddress)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// ensure makes sure a valid certificate is stored in the Secret and loaded.
+func (m *Manager) ensure(ctx context.Context) error {
+	if m.currentMode() == ModeCertManager {
+		if err := m.ensureCertificate(ctx); err != nil {
+			if !meta.IsNoMatchError(err) {
+				return err
+			}
+			log.Info("cert-manager is not installed, falling back to a self-signed certificate")
+			m.setMode(ModeSelfSigned)
+		}
+	}
+
+	secret := &corev1.Secret{}
+	err := m.client.Get(ctx, m.secretKey(), secret)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	if err == nil {
+		cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+		if err == nil && (m.currentMode() == ModeCertManager || !m.needsRenewal(&cert)) {
+			m.setCertificate(&cert)
+			return nil
+		}
+	}
+
+	if m.currentMode() == ModeCertManager {
+		// cert-manager has not issued the certificate yet
+		return nil
+	}
+	return m.issueSelfSigned(ctx)
+}
+
+// ensureCertificate creates or updates the cert-manager Certificate that
+// maintains the Secret.
+func (m *Manager) ensureCertificate(ctx context.Context) error {
+	certificate := &unstructured.Unstructured{}
+	certificate.SetAPIVersion("cert-manager.io/v1")
+	certificate.SetKind("Certificate")
+	certificate.SetNamespace(m.opts.Namespace)
+	certificate.SetName(m.opts.SecretName)
+
+	dnsNames := make([]interface{}, 0, len(m.opts.DNSNames))
+	for _, name := range m.opts.DNSNames {
+		dnsNames = append(dnsNames, name)
+	}
+	issuerKind := m.opts.IssuerKind
+	if issuerKind == "" {
+		issuerKind = "Issuer"
+	}
+	certificate.Object["spec"] = map[string]interface{}{
+		"secretName":  m.opts.SecretName,
+		"dnsNames":    dnsNames,
+		"renewBefore": m.opts.RenewBefore.String(),
+		"issuerRef": map[string]interface{}{
+			"name": m.opts.IssuerName,
+			"kind": issuerKind,
+		},
+	}
+
+	return m.client.Patch(ctx, certificate, client.Apply, client.FieldOwner("vpn-operator"), client.ForceOwnership)
+}
+
+// issueSelfSigned generates a new self-signed certificate and stores it.
+func (m *Manager) issueSelfSigned(ctx context.Context) error {
+	certPEM, keyPEM, err := generateSelfSigned(m.opts.DNSNames, 365*24*time.Hour)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: m.opts.Namespace, Name: m.opts.SecretName}}
+	if _, err := ctrl.CreateOrUpdate(ctx, m.client, secret, func() error {
+		secret.Type = corev1.SecretTypeTLS
+		secret.Data = map[string][]byte{
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	log.Info("issued self-signed certificate", "secret", m.secretKey(), "dnsNames", m.opts.DNSNames)
+	m.setCertificate(&cert)
+	return nil
+}
+
+// needsRenewal returns whether the certificate expires within RenewBefore
+// or does not cover the configured DNS names.
+func (m *Manager) needsRenewal(cert *tls.Certificate) bool {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return true
+	}
+	for _, name := range m.opts.DNSNames {
+		if leaf.VerifyHostname(name) != nil {
+			return true
+		}
+	}
+	return time.Until(leaf.NotAfter) < m.opts.RenewBefore
+}
+
+func (m *Manager) secretKey() types.NamespacedName {
+	return types.NamespacedName{Namespace: m.opts.Namespace, Name: m.opts.SecretName}
+}
+
+func (m *Manager) currentMode() Mode {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.mode
+}
+
+func (m *Manager) setMode(mode Mode) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mode = mode
+}
+
+func (m *Manager) setCertificate(cert *tls.Certificate) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cert = cert
+}
+
+// secretCache is an autocert.Cache storing ACME account keys and
+// certificates in a single Secret.
+type secretCache struct {
+	client client.Client
+	key    types.NamespacedName
+}
+
+func (c *secretCache) Get(ctx context.Context, name string) ([]byte, error) {
+	secret := &corev1.Secret{}
+	if err := c.client.Get(ctx, c.key, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, autocert.ErrCacheMiss
+		}
+		return nil, err
+	}
+	data, ok := secret.Data[secretDataKey(name)]
+	if !ok {
+		return nil, autocert.ErrCacheMiss
+	}
+	return data, nil
+}
+
+func (c *secretCache) Put(ctx context.Context, name string, data []byte) error {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: c.key.Namespace, Name: c.key.Name}}
+	_, err := ctrl.CreateOrUpdate(ctx, c.client, secret, func() error {
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[secretDataKey(name)] = data
+		// Certificate entries hold the private key followed by the chain. Keep
+		// the standard keys up to date for consumers mounting the Secret.
+		block, rest := pem.Decode(data)
+		if block != nil && strings.HasSuffix(block.Type, "PRIVATE KEY") && len(bytes.TrimSpace(rest)) > 0 {
+			secret.Data[corev1.TLSPrivateKeyKey] = pem.EncodeToMemory(block)
+			secret.Data[corev1.TLSCertKey] = bytes.TrimSpace(rest)
+		}
+		return nil
+	})
+	return err
+}
+
+func (c *secretCache) Delete(ctx context.Context, name string) error {
+	secret := &corev1.Secret{}
+	if err := c.client.Get(ctx, c.key, secret); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	delete(secret.Data, secretDataKey(name))
+	return c.client.Update(ctx, secret)
+}
+
+// secretDataKey maps an autocert cache key to a valid Secret data key.
+// Cache keys may contain "+", which is not allowed in Secret keys, while "_"
+// never appears in host names.
+func secretDataKey(name string) string {
+	return strings.ReplaceAll(name, "+", "_")
+}
@@ -0,0 +1,162 @@
+// Package chat verifies and parses the chat commands of Slack slash
+// commands and Microsoft Teams outgoing webhooks, and replies to them.
+//
+// Both platforms sign requests with an HMAC-SHA256 of the body: Slack with
+// the signing secret of the app over a versioned, timestamped base string,
+// Teams with the security token of the outgoing webhook.
+package chat
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Slack is the platform of Slack slash commands
+	Slack = "slack"
+
+	// Teams is the platform of Microsoft Teams outgoing webhooks
+	Teams = "teams"
+)
+
+// maxSkew is how old a signed Slack request can be, to limit replays
+const maxSkew = 5 * time.Minute
+
+// ErrInvalidSignature is returned for requests not signed with the secret
+var ErrInvalidSignature = errors.New("invalid request signature")
+
+// Command is a chat command, e.g. /vpn new-device laptop
+type Command struct {
+	// Platform is the platform the command comes from, slack or teams
+	Platform string
+
+	// Team identifies the workspace or tenant of the user
+	Team string
+
+	// User is the chat user ID of the sender
+	User string
+
+	// Verb is the subcommand, e.g. new-device
+	Verb string
+
+	// Args are the arguments of the subcommand
+	Args []string
+
+	// ResponseURL is where Slack accepts delayed replies to the command
+	ResponseURL string
+}
+
+// ParseSlack verifies the signature of a Slack slash command and parses it.
+func ParseSlack(header http.Header, body, secret []byte, now time.Time) (*Command, error) {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if skew := now.Sub(time.Unix(unix, 0)); skew > maxSkew || skew < -maxSkew {
+		return nil, ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "v0:%s:", timestamp)
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(header.Get("X-Slack-Signature"))) {
+		return nil, ErrInvalidSignature
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	command := &Command{
+		Platform:    Slack,
+		Team:        form.Get("team_id"),
+		User:        form.Get("user_id"),
+		ResponseURL: form.Get("response_url"),
+	}
+	command.Verb, command.Args = split(form.Get("text"))
+	return command, nil
+}
+
+// mention matches the mention of the webhook Teams prefixes messages with
+var mention = regexp.MustCompile(`(?s)<at>.*?</at>`)
+
+// tag matches the HTML markup of Teams messages
+var tag = regexp.MustCompile(`<[^>]*>`)
+
+// ParseTeams verifies the signature of a Teams outgoing webhook message and
+// parses it. The secret is the base64 encoded security token of the
+// webhook.
+func ParseTeams(header http.Header, body, secret []byte) (*Command, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(secret)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid security token: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	expected := "HMAC " + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(header.Get("Authorization"))) {
+		return nil, ErrInvalidSignature
+	}
+
+	var activity struct {
+		Text string `json:"text"`
+		From struct {
+			AADObjectID string `json:"aadObjectId"`
+		} `json:"from"`
+		ChannelData struct {
+			Tenant struct {
+				ID string `json:"id"`
+			} `json:"tenant"`
+		} `json:"channelData"`
+	}
+	if err := json.Unmarshal(body, &activity); err != nil {
+		return nil, err
+	}
+	text := mention.ReplaceAllString(activity.Text, " ")
+	text = html.UnescapeString(tag.ReplaceAllString(text, " "))
+	command := &Command{
+		Platform: Teams,
+		Team:     activity.ChannelData.Tenant.ID,
+		User:     activity.From.AADObjectID,
+	}
+	command.Verb, command.Args = split(text)
+	return command, nil
+}
+
+// split splits the text of a command into its verb and arguments.
+func split(text string) (string, []string) {
+	fields := strings.Fields(strings.ReplaceAll(text, " ", " "))
+	if len(fields) > 0 && fields[0] == "/vpn" {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return strings.ToLower(fields[0]), fields[1:]
+}
+
+// Reply returns the body of the synchronous reply to a command, only
+// visible to the sender on Slack.
+func Reply(platform, text string) []byte {
+	var reply interface{}
+	if platform == Teams {
+		reply = map[string]string{"type": "message", "text": text}
+	} else {
+		reply = map[string]string{"response_type": "ephemeral", "text": text}
+	}
+	body, _ := json.Marshal(reply)
+	return body
+}
@@ -0,0 +1,165 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// DefaultSlackURL is the base URL of the Slack Web API
+const DefaultSlackURL = "https://slack.com/api"
+
+// File is a file sent by direct message
+type File struct {
+	// Name is the file name, e.g. wg0.conf
+	Name string
+
+	// Title is the title displayed for the file
+	Title string
+
+	// Content is the content of the file
+	Content []byte
+}
+
+// SlackClient sends direct messages with the Slack Web API
+type SlackClient struct {
+	// Token is the bot token of the app
+	Token string
+
+	// URL is the base URL of the API, DefaultSlackURL if empty
+	URL string
+
+	// HTTPClient is the client used for requests, http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+func (s *SlackClient) httpClient() *http.Client {
+	if s.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return s.HTTPClient
+}
+
+// call calls a method of the Web API with a form, or with a JSON body if
+// not nil, and decodes the result into result, if not nil.
+func (s *SlackClient) call(ctx context.Context, method string, form url.Values, body interface{}, result interface{}) error {
+	base := s.URL
+	if base == "" {
+		base = DefaultSlackURL
+	}
+	reader := io.Reader(bytes.NewBufferString(form.Encode()))
+	contentType := "application/x-www-form-urlencoded"
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+		contentType = "application/json; charset=utf-8"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/"+method, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+s.Token)
+
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	// Errors are reported in the body of successful responses
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return fmt.Errorf("%s: %s", method, resp.Status)
+	}
+	if !status.OK {
+		return fmt.Errorf("%s: %s", method, status.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, result)
+}
+
+// SendFiles sends files with a comment to a user by direct message.
+func (s *SlackClient) SendFiles(ctx context.Context, user, comment string, files []File) error {
+	var conversation struct {
+		Channel struct {
+			ID string `json:"id"`
+		} `json:"channel"`
+	}
+	if err := s.call(ctx, "conversations.open", url.Values{"users": {user}}, nil, &conversation); err != nil {
+		return err
+	}
+
+	type uploaded struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	}
+	var uploads []uploaded
+	for _, file := range files {
+		var target struct {
+			UploadURL string `json:"upload_url"`
+			FileID    string `json:"file_id"`
+		}
+		form := url.Values{"filename": {file.Name}, "length": {strconv.Itoa(len(file.Content))}}
+		if err := s.call(ctx, "files.getUploadURLExternal", form, nil, &target); err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.UploadURL, bytes.NewReader(file.Content))
+		if err != nil {
+			return err
+		}
+		resp, err := s.httpClient().Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("uploading %s: %s", file.Name, resp.Status)
+		}
+		uploads = append(uploads, uploaded{ID: target.FileID, Title: file.Title})
+	}
+
+	return s.call(ctx, "files.completeUploadExternal", nil, map[string]interface{}{
+		"files":           uploads,
+		"channel_id":      conversation.Channel.ID,
+		"initial_comment": comment,
+	}, nil)
+}
+
+// Respond sends a delayed reply to a slash command, only visible to its
+// sender.
+func Respond(ctx context.Context, httpClient *http.Client, responseURL, text string) error {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(Reply(Slack, text)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("responding to command: %s", resp.Status)
+	}
+	return nil
+}
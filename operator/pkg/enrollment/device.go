@@ -0,0 +1,82 @@
+package enrollment
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+)
+
+// fingerprintLabel separates device fingerprints from other hashes of the
+// same identifiers
+const fingerprintLabel = "wireflow device fingerprint"
+
+// ErrNoIdentifiers is returned when a machine has no readable identifiers
+var ErrNoIdentifiers = errors.New("no machine identifiers found")
+
+// machineIdentifierFiles are the files machine identifiers are read from.
+// The DMI files are only readable by root on most distributions.
+var machineIdentifierFiles = []string{
+	"/etc/machine-id",
+	"/var/lib/dbus/machine-id",
+	"/sys/class/dmi/id/product_uuid",
+	"/sys/class/dmi/id/product_serial",
+	"/sys/class/dmi/id/board_serial",
+}
+
+// Fingerprint returns the fingerprint of a device, the hex encoded SHA-256
+// of its machine identifiers. The order of the identifiers does not matter.
+func Fingerprint(identifiers ...string) string {
+	sorted := append([]string(nil), identifiers...)
+	sort.Strings(sorted)
+	h := sha256.New()
+	h.Write([]byte(fingerprintLabel))
+	for _, identifier := range sorted {
+		fmt.Fprintf(h, "\x00%s", identifier)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// MachineIdentifiers returns the identifiers of the local machine that are
+// readable, as name=value pairs.
+func MachineIdentifiers() ([]string, error) {
+	var identifiers []string
+	for _, file := range machineIdentifierFiles {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if value := strings.TrimSpace(string(raw)); value != "" {
+			identifiers = append(identifiers, file+"="+value)
+		}
+	}
+	if len(identifiers) == 0 {
+		return nil, ErrNoIdentifiers
+	}
+	return identifiers, nil
+}
+
+// ProveDevice answers a challenge with a WireGuard private key, binding the
+// proof to the fingerprint of the device, so that the fingerprint cannot be
+// reported for the key without the key.
+func ProveDevice(privateKey *escrow.Key, challenge, fingerprint string) (string, error) {
+	if fingerprint == "" {
+		return "", errors.New("empty device fingerprint")
+	}
+	return prove(privateKey, challenge, fingerprint)
+}
+
+// VerifyDevice checks that proof answers challenge with the private key of
+// publicKey on the device with the given fingerprint. A challenge can be
+// answered once.
+func (v *Verifier) VerifyDevice(challenge, publicKey, fingerprint, proof string) error {
+	if fingerprint == "" {
+		return ErrInvalidProof
+	}
+	return v.verify(challenge, publicKey, fingerprint, proof)
+}
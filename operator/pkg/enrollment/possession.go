@@ -0,0 +1,219 @@
+// Package enrollment implements the checks of self-service peer enrollment.
+//
+// Before a VPNPeer is created for a submitted public key, the requester
+// proves possession of the matching private key by answering a challenge
+// issued by the enrollment server. WireGuard keys are Curve25519 keys, which
+// cannot sign, so the proof is a MAC over the challenge keyed with the
+// Diffie-Hellman secret of the submitted key and a key of the server. Only
+// the holder of the private key can compute it, which is also how WireGuard
+// authenticates peers.
+package enrollment
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/curve25519"
+
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+)
+
+const (
+	nonceSize = 16
+
+	// challengeSize is the size of a challenge: a nonce, its expiry in unix
+	// seconds, the public key of the server and a MAC binding them
+	challengeSize = nonceSize + 8 + 32 + sha256.Size
+
+	proofLabel = "wireflow enrollment proof of possession"
+)
+
+// DefaultChallengeTTL is how long a challenge can be answered
+const DefaultChallengeTTL = 5 * time.Minute
+
+var (
+	// ErrInvalidChallenge is returned for challenges that were not issued by
+	// the verifier, have expired or were already answered
+	ErrInvalidChallenge = errors.New("invalid or expired enrollment challenge")
+
+	// ErrInvalidProof is returned when the proof was not computed with the
+	// private key of the submitted public key
+	ErrInvalidProof = errors.New("proof of possession does not match the public key")
+)
+
+// Verifier issues challenges and verifies the proofs answering them.
+// Challenges are self-contained, so the verifier only remembers the ones
+// answered until they expire, to reject replays.
+type Verifier struct {
+	// TTL is how long a challenge can be answered
+	TTL time.Duration
+
+	key    escrow.Key
+	public *escrow.Key
+	secret [32]byte
+
+	mu       sync.Mutex
+	answered map[[nonceSize]byte]time.Time
+}
+
+// VerifierKeySize is the size of the keys of verifiers
+const VerifierKeySize = 64
+
+// GenerateVerifierKey returns a new key for NewVerifierWithKey.
+func GenerateVerifierKey() ([]byte, error) {
+	key := make([]byte, VerifierKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// NewVerifier returns a verifier with a new key. Challenges issued before a
+// restart can no longer be answered.
+func NewVerifier() (*Verifier, error) {
+	key, err := GenerateVerifierKey()
+	if err != nil {
+		return nil, err
+	}
+	return NewVerifierWithKey(key)
+}
+
+// NewVerifierWithKey returns a verifier with a key returned by
+// GenerateVerifierKey. Verifiers sharing a key answer the challenges of one
+// another, so that any replica of a server can verify the proofs answering
+// a challenge issued by another.
+func NewVerifierWithKey(key []byte) (*Verifier, error) {
+	if len(key) != VerifierKeySize {
+		return nil, fmt.Errorf("verifier key has %d bytes, want %d", len(key), VerifierKeySize)
+	}
+	v := &Verifier{TTL: DefaultChallengeTTL, answered: map[[nonceSize]byte]time.Time{}}
+	copy(v.key[:], key)
+	copy(v.secret[:], key[len(v.key):])
+	v.public = v.key.PublicKey()
+	return v, nil
+}
+
+// Challenge returns a new challenge for a requester to answer with Prove.
+func (v *Verifier) Challenge() (string, error) {
+	raw := make([]byte, 0, challengeSize)
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	raw = append(raw, nonce...)
+	raw = binary.BigEndian.AppendUint64(raw, uint64(time.Now().Add(v.TTL).Unix()))
+	raw = append(raw, v.public[:]...)
+	raw = append(raw, v.tag(raw)...)
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// Verify checks that proof answers challenge with the private key of
+// publicKey. A challenge can be answered once.
+func (v *Verifier) Verify(challenge, publicKey, proof string) error {
+	return v.verify(challenge, publicKey, "", proof)
+}
+
+func (v *Verifier) verify(challenge, publicKey, fingerprint, proof string) error {
+	raw, err := decodeChallenge(challenge)
+	if err != nil {
+		return err
+	}
+	body := raw[:challengeSize-sha256.Size]
+	if !hmac.Equal(v.tag(body), raw[len(body):]) {
+		return ErrInvalidChallenge
+	}
+	now := time.Now()
+	expires := time.Unix(int64(binary.BigEndian.Uint64(raw[nonceSize:])), 0)
+	if !now.Before(expires) {
+		return ErrInvalidChallenge
+	}
+
+	peer, err := escrow.ParseKey(publicKey)
+	if err != nil {
+		return err
+	}
+	expected, err := computeProof(&v.key, peer, body, fingerprint)
+	if err != nil {
+		return err
+	}
+	given, err := base64.RawURLEncoding.DecodeString(proof)
+	if err != nil || !hmac.Equal(expected, given) {
+		return ErrInvalidProof
+	}
+
+	var nonce [nonceSize]byte
+	copy(nonce[:], raw)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for answered, expiry := range v.answered {
+		if !now.Before(expiry) {
+			delete(v.answered, answered)
+		}
+	}
+	if _, ok := v.answered[nonce]; ok {
+		return ErrInvalidChallenge
+	}
+	v.answered[nonce] = expires
+	return nil
+}
+
+func (v *Verifier) tag(body []byte) []byte {
+	mac := hmac.New(sha256.New, v.secret[:])
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// Prove answers a challenge with a WireGuard private key. The proof is
+// submitted with the public key of privateKey.
+func Prove(privateKey *escrow.Key, challenge string) (string, error) {
+	return prove(privateKey, challenge, "")
+}
+
+func prove(privateKey *escrow.Key, challenge, fingerprint string) (string, error) {
+	raw, err := decodeChallenge(challenge)
+	if err != nil {
+		return "", err
+	}
+	body := raw[:challengeSize-sha256.Size]
+	server := &escrow.Key{}
+	copy(server[:], body[nonceSize+8:])
+	proof, err := computeProof(privateKey, server, body, fingerprint)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(proof), nil
+}
+
+// computeProof returns the MAC of the challenge body, and of the device
+// fingerprint if not empty, keyed with the shared secret of private and
+// public. Either side computes the same secret from its private key and the
+// public key of the other.
+func computeProof(private, public *escrow.Key, body []byte, fingerprint string) ([]byte, error) {
+	shared, err := curve25519.X25519(private[:], public[:])
+	if err != nil {
+		// Low order points yield an all-zero secret anyone can compute
+		return nil, fmt.Errorf("unusable public key: %w", err)
+	}
+	mac := hmac.New(sha256.New, shared)
+	mac.Write([]byte(proofLabel))
+	mac.Write(body)
+	if fingerprint != "" {
+		mac.Write([]byte(fingerprint))
+	}
+	return mac.Sum(nil), nil
+}
+
+func decodeChallenge(challenge string) ([]byte, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(challenge)
+	if err != nil || len(raw) != challengeSize {
+		return nil, ErrInvalidChallenge
+	}
+	return raw, nil
+}
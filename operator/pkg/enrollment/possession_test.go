@@ -0,0 +1,113 @@
+package enrollment
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vpn-devops/vpn-operator/pkg/escrow"
+)
+
+// generateKey returns a random WireGuard private key and its public key.
+func generateKey(t *testing.T) (*escrow.Key, string) {
+	t.Helper()
+	key := &escrow.Key{}
+	if _, err := rand.Read(key[:]); err != nil {
+		t.Fatal(err)
+	}
+	return key, key.PublicKey().String()
+}
+
+func newTestVerifier(t *testing.T) *Verifier {
+	t.Helper()
+	v, err := NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestVerifyProofOfPossession(t *testing.T) {
+	v := newTestVerifier(t)
+	private, public := generateKey(t)
+
+	challenge, err := v.Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := Prove(private, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(challenge, public, proof); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if err := v.Verify(challenge, public, proof); !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("replayed answer: err = %v, want ErrInvalidChallenge", err)
+	}
+}
+
+func TestVerifyRejectsOtherPrivateKey(t *testing.T) {
+	v := newTestVerifier(t)
+	_, public := generateKey(t)
+	other, _ := generateKey(t)
+
+	challenge, err := v.Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := Prove(other, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(challenge, public, proof); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("proof of another key: err = %v, want ErrInvalidProof", err)
+	}
+}
+
+func TestVerifyRejectsForeignAndExpiredChallenges(t *testing.T) {
+	v := newTestVerifier(t)
+	private, public := generateKey(t)
+
+	foreign, err := newTestVerifier(t).Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := Prove(private, foreign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(foreign, public, proof); !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("challenge of another verifier: err = %v, want ErrInvalidChallenge", err)
+	}
+
+	v.TTL = -time.Second
+	expired, err := v.Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof, err = Prove(private, expired); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(expired, public, proof); !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("expired challenge: err = %v, want ErrInvalidChallenge", err)
+	}
+
+	if _, err := Prove(private, "not-a-challenge"); !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("malformed challenge: err = %v, want ErrInvalidChallenge", err)
+	}
+}
+
+func TestVerifyRejectsLowOrderPublicKey(t *testing.T) {
+	v := newTestVerifier(t)
+	challenge, err := v.Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The identity point, whose shared secret with any key is all zeros
+	zero := (&escrow.Key{}).String()
+	if err := v.Verify(challenge, zero, "AAAA"); err == nil {
+		t.Error("verified a proof for a low order public key")
+	}
+}
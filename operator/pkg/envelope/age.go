@@ -0,0 +1,93 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// Data keys are wrapped as age files with a single X25519 recipient, so
+// that age -d opens them as well.
+
+// AgeRecipient wraps data keys to an age X25519 recipient.
+type AgeRecipient struct {
+	recipient *age.X25519Recipient
+}
+
+// ParseAgeRecipient parses an age1... recipient.
+func ParseAgeRecipient(s string) (*AgeRecipient, error) {
+	recipient, err := age.ParseX25519Recipient(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid age recipient: %w", err)
+	}
+	return &AgeRecipient{recipient: recipient}, nil
+}
+
+// WrapKey encrypts a data key to the recipient as an age file.
+func (r *AgeRecipient) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	var out bytes.Buffer
+	w, err := age.Encrypt(&out, r.recipient)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(key); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// AgeIdentity unwraps data keys wrapped to its recipient.
+type AgeIdentity struct {
+	identity *age.X25519Identity
+}
+
+// UnwrapKey decrypts a data key wrapped to the identity.
+func (i *AgeIdentity) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return AgeIdentities{i}.UnwrapKey(ctx, wrapped)
+}
+
+// AgeIdentities unwraps data keys wrapped to the recipient of any of its
+// identities.
+type AgeIdentities []*AgeIdentity
+
+// UnwrapKey decrypts a data key with the first identity it is wrapped to.
+func (ids AgeIdentities) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	identities := make([]age.Identity, 0, len(ids))
+	for _, id := range ids {
+		identities = append(identities, id.identity)
+	}
+	r, err := age.Decrypt(bytes.NewReader(wrapped), identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(r, 1<<10))
+}
+
+// ParseAgeIdentities parses the AGE-SECRET-KEY-1... identities of an
+// identity file, as written by age-keygen.
+func ParseAgeIdentities(data []byte) ([]*AgeIdentity, error) {
+	parsed, err := age.ParseIdentities(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid age identity: %w", err)
+	}
+	var identities []*AgeIdentity
+	for _, identity := range parsed {
+		x25519, ok := identity.(*age.X25519Identity)
+		if !ok {
+			return nil, errors.New("invalid age identity: not an X25519 identity")
+		}
+		identities = append(identities, &AgeIdentity{identity: x25519})
+	}
+	if len(identities) == 0 {
+		return nil, errors.New("no age identity found")
+	}
+	return identities, nil
+}
@@ -0,0 +1,83 @@
+package envelope
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// AWSKMS wraps data keys with an AWS KMS key. It takes credentials from the
+// default chain of the AWS SDK: the environment, the shared config files,
+// a web identity as set up by IAM roles for service accounts, or the
+// instance role.
+type AWSKMS struct {
+	// KeyID is the ARN of the key
+	KeyID string
+
+	// Region is the region of the key
+	Region string
+
+	mu     sync.Mutex
+	client *kms.Client
+}
+
+// NewAWSKMS returns the wrapper of the key with the given ARN. The region
+// is that of the ARN, or AWS_REGION for key IDs and aliases.
+func NewAWSKMS(keyID string) (*AWSKMS, error) {
+	region := os.Getenv("AWS_REGION")
+	if parts := strings.Split(keyID, ":"); len(parts) >= 6 && parts[0] == "arn" && parts[2] == "kms" {
+		region = parts[3]
+	}
+	if region == "" {
+		return nil, fmt.Errorf("AWS KMS key %q has no region, use its ARN or set AWS_REGION", keyID)
+	}
+	return &AWSKMS{KeyID: keyID, Region: region}, nil
+}
+
+// WrapKey encrypts a data key with the KMS key.
+func (k *AWSKMS) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	client, err := k.kmsClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.Encrypt(ctx, &kms.EncryptInput{KeyId: aws.String(k.KeyID), Plaintext: key})
+	if err != nil {
+		return nil, err
+	}
+	return out.CiphertextBlob, nil
+}
+
+// UnwrapKey decrypts a data key with the KMS key.
+func (k *AWSKMS) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	client, err := k.kmsClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.Decrypt(ctx, &kms.DecryptInput{KeyId: aws.String(k.KeyID), CiphertextBlob: wrapped})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+// kmsClient returns the KMS client of the region of the key, created on
+// first use.
+func (k *AWSKMS) kmsClient(ctx context.Context) (*kms.Client, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.client != nil {
+		return k.client, nil
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(k.Region))
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+	k.client = kms.NewFromConfig(cfg)
+	return k.client, nil
+}
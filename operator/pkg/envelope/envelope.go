@@ -0,0 +1,160 @@
+// Package envelope encrypts client configs with a data key that is itself
+// encrypted by a key management service, so that Secrets hold ciphertext
+// and reading a config also takes access to the key.
+//
+// The operator only needs to wrap data keys: with AWS or GCP KMS its role
+// can be limited to the encrypt permission, and with age it only knows the
+// recipient. Decryption happens in the CLI, with the credentials of the
+// user.
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Providers of key management
+const (
+	ProviderAWSKMS = "aws-kms"
+	ProviderGCPKMS = "gcp-kms"
+	ProviderAge    = "age"
+)
+
+// Suffix is appended to the data key of a value sealed in an envelope
+const Suffix = ".sealed"
+
+// version is the version of the envelope format
+const version = 1
+
+// KeyWrapper encrypts data keys.
+type KeyWrapper interface {
+	WrapKey(ctx context.Context, key []byte) ([]byte, error)
+}
+
+// KeyUnwrapper decrypts data keys.
+type KeyUnwrapper interface {
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// Envelope is a value encrypted with AES-256-GCM under a data key, along
+// with the data key wrapped by a key management service
+type Envelope struct {
+	Version    int    `json:"version"`
+	Provider   string `json:"provider"`
+	KeyID      string `json:"keyID"`
+	WrappedKey []byte `json:"wrappedKey"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// NewWrapper returns the key wrapper of a provider for the key with the
+// given ID: the ARN of an AWS KMS key, the resource name of a GCP KMS
+// crypto key or an age recipient.
+func NewWrapper(provider, keyID string) (KeyWrapper, error) {
+	switch provider {
+	case ProviderAWSKMS:
+		return NewAWSKMS(keyID)
+	case ProviderGCPKMS:
+		return &GCPKMS{KeyName: keyID}, nil
+	case ProviderAge:
+		return ParseAgeRecipient(keyID)
+	default:
+		return nil, fmt.Errorf("unknown key management provider %q", provider)
+	}
+}
+
+// NewUnwrapper returns the key unwrapper of a provider for the key with the
+// given ID. Keys wrapped with age are unwrapped with one of identities.
+func NewUnwrapper(provider, keyID string, identities []*AgeIdentity) (KeyUnwrapper, error) {
+	switch provider {
+	case ProviderAWSKMS:
+		return NewAWSKMS(keyID)
+	case ProviderGCPKMS:
+		return &GCPKMS{KeyName: keyID}, nil
+	case ProviderAge:
+		if len(identities) == 0 {
+			return nil, errors.New("keys wrapped with age need an age identity")
+		}
+		return AgeIdentities(identities), nil
+	default:
+		return nil, fmt.Errorf("unknown key management provider %q", provider)
+	}
+}
+
+// Seal encrypts plaintext under a new data key wrapped by wrapper, and
+// returns the serialized envelope.
+func Seal(ctx context.Context, provider, keyID string, wrapper KeyWrapper, plaintext []byte) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	wrapped, err := wrapper.WrapKey(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("wrapping data key with %s: %w", provider, err)
+	}
+	return json.Marshal(&Envelope{
+		Version:    version,
+		Provider:   provider,
+		KeyID:      keyID,
+		WrappedKey: wrapped,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, []byte(provider+"\x00"+keyID)),
+	})
+}
+
+// Parse decodes a serialized envelope.
+func Parse(data []byte) (*Envelope, error) {
+	envelope := &Envelope{}
+	if err := json.Unmarshal(data, envelope); err != nil {
+		return nil, fmt.Errorf("invalid envelope: %w", err)
+	}
+	if envelope.Version != version {
+		return nil, fmt.Errorf("unsupported envelope version %d", envelope.Version)
+	}
+	return envelope, nil
+}
+
+// Open unwraps the data key of the envelope with unwrapper and decrypts
+// the value.
+func (e *Envelope) Open(ctx context.Context, unwrapper KeyUnwrapper) ([]byte, error) {
+	key, err := unwrapper.UnwrapKey(ctx, e.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping data key with %s: %w", e.Provider, err)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid envelope nonce")
+	}
+	plaintext, err := aead.Open(nil, e.Nonce, e.Ciphertext, []byte(e.Provider+"\x00"+e.KeyID))
+	if err != nil {
+		return nil, errors.New("the envelope was tampered with or the data key is wrong")
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid data key: expected 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,85 @@
+package envelope
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/oauth"
+)
+
+// gcpKMSEndpoint is the gRPC endpoint of Cloud KMS
+const gcpKMSEndpoint = "cloudkms.googleapis.com:443"
+
+// GCPKMS wraps data keys with a Cloud KMS crypto key through the gRPC
+// client of the Cloud KMS API. The access token is taken from
+// GOOGLE_OAUTH_ACCESS_TOKEN, e.g. the output of gcloud auth
+// print-access-token, or else from the application default credentials,
+// which cover Workload Identity.
+type GCPKMS struct {
+	// KeyName is the resource name of the crypto key,
+	// projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>
+	KeyName string
+
+	mu     sync.Mutex
+	client kmspb.KeyManagementServiceClient
+}
+
+// WrapKey encrypts a data key with the crypto key.
+func (k *GCPKMS) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	client, err := k.kmsClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Encrypt(ctx, &kmspb.EncryptRequest{Name: k.KeyName, Plaintext: key})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Ciphertext, nil
+}
+
+// UnwrapKey decrypts a data key with the crypto key.
+func (k *GCPKMS) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	client, err := k.kmsClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Decrypt(ctx, &kmspb.DecryptRequest{Name: k.KeyName, Ciphertext: wrapped})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+// kmsClient returns the Cloud KMS client, created on first use.
+func (k *GCPKMS) kmsClient(ctx context.Context) (kmspb.KeyManagementServiceClient, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.client != nil {
+		return k.client, nil
+	}
+	var tokens oauth2.TokenSource
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		tokens = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	} else {
+		var err error
+		if tokens, err = google.DefaultTokenSource(ctx, "https://www.googleapis.com/auth/cloudkms"); err != nil {
+			return nil, fmt.Errorf("no Google credentials: set GOOGLE_OAUTH_ACCESS_TOKEN or use application default credentials: %w", err)
+		}
+	}
+	conn, err := grpc.NewClient(gcpKMSEndpoint,
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})),
+		grpc.WithPerRPCCredentials(oauth.TokenSource{TokenSource: tokens}))
+	if err != nil {
+		return nil, err
+	}
+	k.client = kmspb.NewKeyManagementServiceClient(conn)
+	return k.client, nil
+}
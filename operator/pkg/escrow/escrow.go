@@ -0,0 +1,107 @@
+// Package escrow encrypts WireGuard private keys to an organisation
+// recovery key so that they can be recovered when the original Secret is
+// lost.
+//
+// The recovery key pair uses the WireGuard key format and can be generated
+// with "wg genkey | tee recovery.key | wg pubkey". Keys are sealed with an
+// anonymous NaCl box, so only the holder of the recovery private key can
+// open them.
+package escrow
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+)
+
+const (
+	// SecretType is the type of the Secrets escrowed keys are stored in
+	SecretType = "vpn.vpn-devops.com/escrow"
+
+	// SourceAnnotation is the namespace/name of the escrowed Secret
+	SourceAnnotation = "vpn.vpn-devops.com/escrow-source"
+
+	// SourceVersionAnnotation is the resource version of the escrowed Secret
+	SourceVersionAnnotation = "vpn.vpn-devops.com/escrow-source-version"
+
+	// RecipientAnnotation is the fingerprint of the recovery public key the
+	// keys are sealed to
+	RecipientAnnotation = "vpn.vpn-devops.com/escrow-recipient"
+
+	// PublicKeySuffix is appended to the data key holding the public key of
+	// an escrowed private key, used to verify a recovery
+	PublicKeySuffix = ".pub"
+)
+
+// Key is a curve25519 key
+type Key [32]byte
+
+// ParseKey parses a base64 encoded key in the WireGuard format.
+func ParseKey(s string) (*Key, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	if len(raw) != len(Key{}) {
+		return nil, fmt.Errorf("invalid key: expected %d bytes, got %d", len(Key{}), len(raw))
+	}
+	key := &Key{}
+	copy(key[:], raw)
+	return key, nil
+}
+
+// GenerateKey returns a new WireGuard private key, like wg genkey.
+func GenerateKey() (*Key, error) {
+	key := &Key{}
+	if _, err := rand.Read(key[:]); err != nil {
+		return nil, err
+	}
+	key[0] &= 248
+	key[31] = (key[31] & 127) | 64
+	return key, nil
+}
+
+// String returns the key in the WireGuard format.
+func (k *Key) String() string {
+	return base64.StdEncoding.EncodeToString(k[:])
+}
+
+// PublicKey returns the public key of a private key.
+func (k *Key) PublicKey() *Key {
+	public := &Key{}
+	curve25519.ScalarBaseMult((*[32]byte)(public), (*[32]byte)(k))
+	return public
+}
+
+// Fingerprint returns a short identifier of a public key.
+func (k *Key) Fingerprint() string {
+	sum := sha256.Sum256(k[:])
+	return hex.EncodeToString(sum[:8])
+}
+
+// Seal encrypts plaintext to the recovery public key.
+func Seal(recipient *Key, plaintext []byte) ([]byte, error) {
+	return box.SealAnonymous(nil, plaintext, (*[32]byte)(recipient), rand.Reader)
+}
+
+// Open decrypts a sealed key with the recovery private key.
+func Open(recovery *Key, sealed []byte) ([]byte, error) {
+	plaintext, ok := box.OpenAnonymous(nil, sealed, (*[32]byte)(recovery.PublicKey()), (*[32]byte)(recovery))
+	if !ok {
+		return nil, errors.New("unable to open sealed key, it was not sealed to this recovery key")
+	}
+	return plaintext, nil
+}
+
+// IsPrivateKeyEntry returns whether a Secret data key holds a WireGuard
+// private key, following the naming used by the server image.
+func IsPrivateKeyEntry(name string) bool {
+	return strings.HasSuffix(name, "private") || name == "privateKey"
+}
@@ -0,0 +1,92 @@
+package escrow
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+// generateKey returns a random recovery private key.
+func generateKey(t *testing.T) *Key {
+	t.Helper()
+	key := &Key{}
+	if _, err := rand.Read(key[:]); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	recovery := generateKey(t)
+	serverKey := generateKey(t)
+
+	sealed, err := Seal(recovery.PublicKey(), []byte(serverKey.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte(serverKey.String())) {
+		t.Fatal("sealed key holds the plaintext")
+	}
+	opened, err := Open(recovery, sealed)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if string(opened) != serverKey.String() {
+		t.Errorf("opened %q, want %q", opened, serverKey.String())
+	}
+}
+
+func TestOpenRejectsOtherRecoveryKey(t *testing.T) {
+	sealed, err := Seal(generateKey(t).PublicKey(), []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(generateKey(t), sealed); err == nil {
+		t.Error("opened a key sealed to another recovery key")
+	}
+}
+
+func TestOpenRejectsTamperedKey(t *testing.T) {
+	recovery := generateKey(t)
+	sealed, err := Seal(recovery.PublicKey(), []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range sealed {
+		tampered := bytes.Clone(sealed)
+		tampered[i] ^= 0x01
+		if _, err := Open(recovery, tampered); err == nil {
+			t.Fatalf("opened a sealed key with byte %d flipped", i)
+		}
+	}
+	if _, err := Open(recovery, sealed[:len(sealed)-1]); err == nil {
+		t.Error("opened a truncated sealed key")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	key := generateKey(t)
+	parsed, err := ParseKey(" " + key.String() + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *parsed != *key {
+		t.Errorf("parsed %s, want %s", parsed, key)
+	}
+	for _, invalid := range []string{"", "not base64!", "c2hvcnQ="} {
+		if _, err := ParseKey(invalid); err == nil {
+			t.Errorf("parsed invalid key %q", invalid)
+		}
+	}
+}
+
+func TestPublicKeyMatchesRFC7748(t *testing.T) {
+	// The key pair of Alice in RFC 7748, section 6.1
+	private, err := ParseKey("dwdtCnMYpX08FsFyUbJmRd9ML4frwJkqsXf7pR25LCo=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := private.PublicKey().String(), "hSDwCYkwp1R0i33ctD73Wg2/Og0mOBr066SpjqqbTmo="; got != want {
+		t.Errorf("public key = %s, want %s", got, want)
+	}
+}
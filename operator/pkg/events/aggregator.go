@@ -0,0 +1,68 @@
+package events
+
+import (
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Options configures how the operator deduplicates and rate limits the
+// Events it emits.
+type Options struct {
+	// Window is the period during which events with the same reason for the
+	// same object are folded into a single Event with an increasing count
+	Window time.Duration
+
+	// Burst is the number of events a single object may emit before the
+	// rate limit applies
+	Burst int
+
+	// QPS is the sustained per-object event rate once Burst is exhausted
+	QPS float32
+}
+
+// NewBroadcaster returns an EventBroadcaster whose correlator aggregates
+// events by (reason, object) within opts.Window and drops events exceeding
+// the opts.Burst/opts.QPS budget, so that high-churn fleets do not flood
+// the events backend.
+func NewBroadcaster(opts Options) record.EventBroadcaster {
+	return record.NewBroadcasterWithCorrelatorOptions(correlatorOptions(opts))
+}
+
+// correlatorOptions returns the options of the correlator of the
+// broadcaster. Repeats of an event only increase its count. Once an object
+// emits a second event with the same reason but another message within the
+// window, it and the following ones are folded into a single event with the
+// message of the latest, prefixed as by client-go, so that a message is
+// only marked combined when it stands for several.
+func correlatorOptions(opts Options) record.CorrelatorOptions {
+	window := int(opts.Window / time.Second)
+	if window < 1 {
+		window = 1
+	}
+	return record.CorrelatorOptions{
+		BurstSize:            opts.Burst,
+		QPS:                  opts.QPS,
+		KeyFunc:              aggregateByReasonAndObject,
+		MessageFunc:          record.EventAggregatorByReasonMessageFunc,
+		MaxEvents:            2,
+		MaxIntervalInSeconds: window,
+	}
+}
+
+// aggregateByReasonAndObject groups events by the object they refer to and
+// their reason, ignoring the message so that events differing only in
+// details (peer names, byte counts, ...) are still deduplicated.
+func aggregateByReasonAndObject(event *corev1.Event) (string, string) {
+	return strings.Join([]string{
+		event.Source.Component,
+		event.InvolvedObject.Kind,
+		event.InvolvedObject.Namespace,
+		event.InvolvedObject.Name,
+		string(event.InvolvedObject.UID),
+		event.Type,
+		event.Reason,
+	}, "/"), event.Message
+}
@@ -0,0 +1,113 @@
+package events
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	testclock "k8s.io/utils/clock/testing"
+)
+
+func newTestCorrelator(opts Options) (*record.EventCorrelator, *testclock.FakeClock) {
+	clock := testclock.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	options := correlatorOptions(opts)
+	options.Clock = clock
+	return record.NewEventCorrelatorWithOptions(options), clock
+}
+
+func testEvent(object, reason, message string) *corev1.Event {
+	now := metav1.Now()
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: "vpn", Name: object + "." + reason},
+		InvolvedObject: corev1.ObjectReference{Kind: "VPNPeer", Namespace: "vpn", Name: object, UID: types.UID("uid-" + object)},
+		Source:         corev1.EventSource{Component: "vpn-operator"},
+		Type:           corev1.EventTypeWarning,
+		Reason:         reason,
+		Message:        message,
+		Count:          1,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+	}
+}
+
+func correlate(t *testing.T, correlator *record.EventCorrelator, event *corev1.Event) *record.EventCorrelateResult {
+	t.Helper()
+	result, err := correlator.EventCorrelate(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestCorrelatorKeepsSingleEventMessage(t *testing.T) {
+	correlator, _ := newTestCorrelator(Options{Window: time.Minute, Burst: 25, QPS: 1})
+
+	result := correlate(t, correlator, testEvent("laptop", "HandshakeStale", "no handshake for 3m"))
+	if result.Skip {
+		t.Fatal("first event skipped")
+	}
+	if result.Event.Message != "no handshake for 3m" {
+		t.Errorf("message = %q, want the message of the event", result.Event.Message)
+	}
+
+	// An identical event only increases the count of the first
+	result = correlate(t, correlator, testEvent("laptop", "HandshakeStale", "no handshake for 3m"))
+	if result.Event.Count != 2 || result.Event.Message != "no handshake for 3m" {
+		t.Errorf("count = %d, message = %q, want 2 and the message of the event", result.Event.Count, result.Event.Message)
+	}
+}
+
+func TestCorrelatorAggregatesByReasonAndObject(t *testing.T) {
+	correlator, clock := newTestCorrelator(Options{Window: time.Minute, Burst: 25, QPS: 1})
+
+	correlate(t, correlator, testEvent("laptop", "HandshakeStale", "no handshake for 3m"))
+	result := correlate(t, correlator, testEvent("laptop", "HandshakeStale", "no handshake for 4m"))
+	if !strings.HasPrefix(result.Event.Message, "(combined from similar events): ") || !strings.HasSuffix(result.Event.Message, "no handshake for 4m") {
+		t.Errorf("message = %q, want the latest message marked combined", result.Event.Message)
+	}
+	aggregate := result.Event.Name
+	result = correlate(t, correlator, testEvent("laptop", "HandshakeStale", "no handshake for 5m"))
+	if result.Event.Name != aggregate || result.Event.Count != 2 {
+		t.Errorf("event %s with count %d, want the aggregate %s with count 2", result.Event.Name, result.Event.Count, aggregate)
+	}
+
+	// Other reasons and other objects are not folded in
+	for _, event := range []*corev1.Event{
+		testEvent("laptop", "AddressReallocated", "moved to 10.8.0.9"),
+		testEvent("phone", "HandshakeStale", "no handshake for 6m"),
+	} {
+		if result := correlate(t, correlator, event); result.Event.Message != event.Message {
+			t.Errorf("%s of %s: message = %q, want %q", event.Reason, event.InvolvedObject.Name, result.Event.Message, event.Message)
+		}
+	}
+
+	// Past the window, events are no longer folded into the aggregate
+	clock.Step(2 * time.Minute)
+	result = correlate(t, correlator, testEvent("laptop", "HandshakeStale", "no handshake for 8m"))
+	if result.Event.Message != "no handshake for 8m" {
+		t.Errorf("message after the window = %q, want the message of the event", result.Event.Message)
+	}
+}
+
+func TestCorrelatorRateLimitsPerObject(t *testing.T) {
+	correlator, clock := newTestCorrelator(Options{Window: time.Minute, Burst: 2, QPS: 0.1})
+
+	for i, reason := range []string{"A", "B", "C"} {
+		result := correlate(t, correlator, testEvent("laptop", reason, "event "+reason))
+		if skip := i >= 2; result.Skip != skip {
+			t.Errorf("event %d: skip = %v, want %v", i, result.Skip, skip)
+		}
+	}
+	if result := correlate(t, correlator, testEvent("phone", "A", "event A")); result.Skip {
+		t.Error("event of another object skipped")
+	}
+
+	clock.Step(10 * time.Second)
+	if result := correlate(t, correlator, testEvent("laptop", "D", "event D")); result.Skip {
+		t.Error("event skipped after the budget refilled")
+	}
+}
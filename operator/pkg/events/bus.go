@@ -0,0 +1,205 @@
+package events
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// publishTimeout bounds the delivery of a single event to a sink
+const publishTimeout = 10 * time.Second
+
+// Event is an event of a subsystem of the operator, as published to the
+// sinks of the bus
+type Event struct {
+	// Time is when the event occurred
+	Time time.Time `json:"time"`
+
+	// Type is Normal or Warning
+	Type string `json:"type"`
+
+	// Reason is the short, machine readable reason of the event, e.g.
+	// InviteAccepted
+	Reason string `json:"reason"`
+
+	// Message is the human readable description of the event
+	Message string `json:"message"`
+
+	// Object is the object the event is about
+	Object ObjectReference `json:"object"`
+
+	// Source is the component that emitted the event
+	Source string `json:"source,omitempty"`
+}
+
+// ObjectReference identifies the object an event is about
+type ObjectReference struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	UID       string `json:"uid,omitempty"`
+}
+
+// Sink is a destination events are published to. Implementing it is all a
+// new destination takes.
+type Sink interface {
+	// Name identifies the sink in logs
+	Name() string
+
+	// Publish delivers an event. Failed deliveries are logged and dropped.
+	Publish(ctx context.Context, event Event) error
+}
+
+// Filter selects the events published to a sink: those whose type or
+// reason it lists. An empty filter selects every event.
+type Filter struct {
+	// Types are the event types to publish, e.g. Warning
+	Types []string
+
+	// Reasons are the event reasons to publish
+	Reasons []string
+}
+
+// Matches returns whether the filter selects event.
+func (f Filter) Matches(event Event) bool {
+	if len(f.Types) == 0 && len(f.Reasons) == 0 {
+		return true
+	}
+	return contains(f.Types, event.Type) || contains(f.Reasons, event.Reason)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// subscription is a sink with its filter and queue
+type subscription struct {
+	sink   Sink
+	filter Filter
+	queue  chan Event
+}
+
+// Bus fans the events of the operator out to its sinks. Every sink has its
+// own queue, so that a slow or unreachable sink neither delays the others
+// nor the subsystems publishing. Events are dropped when the queue of a
+// sink is full.
+//
+// Subsystems record events through the EventRecorders of the manager as
+// before: the Kubernetes Events they create remain the primary sink, and
+// Watch forwards every recorded event to the sinks of the bus.
+type Bus struct {
+	queueSize     int
+	subscriptions []*subscription
+}
+
+// NewBus returns a bus whose sinks buffer up to queueSize events each.
+func NewBus(queueSize int) *Bus {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	return &Bus{queueSize: queueSize}
+}
+
+// AddSink adds a sink publishing the events selected by filter. Sinks must
+// be added before the bus starts.
+func (b *Bus) AddSink(sink Sink, filter Filter) {
+	b.subscriptions = append(b.subscriptions, &subscription{
+		sink:   sink,
+		filter: filter,
+		queue:  make(chan Event, b.queueSize),
+	})
+}
+
+// Publish queues an event for the sinks whose filter selects it, without
+// blocking.
+func (b *Bus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for _, s := range b.subscriptions {
+		if !s.filter.Matches(event) {
+			continue
+		}
+		select {
+		case s.queue <- event:
+		default:
+			ctrl.Log.WithName("events").Info("dropped event, the sink is backed up",
+				"sink", s.sink.Name(), "reason", event.Reason, "kind", event.Object.Kind,
+				"namespace", event.Object.Namespace, "name", event.Object.Name)
+		}
+	}
+}
+
+// Watch publishes every event recorded through the broadcaster until the
+// returned watch is stopped.
+func (b *Bus) Watch(broadcaster record.EventBroadcaster) watch.Interface {
+	return broadcaster.StartEventWatcher(func(event *corev1.Event) {
+		b.Publish(fromKubernetes(event))
+	})
+}
+
+// fromKubernetes converts a Kubernetes Event.
+func fromKubernetes(event *corev1.Event) Event {
+	t := event.EventTime.Time
+	if t.IsZero() {
+		t = event.LastTimestamp.Time
+	}
+	return Event{
+		Time:    t,
+		Type:    event.Type,
+		Reason:  event.Reason,
+		Message: event.Message,
+		Object: ObjectReference{
+			Kind:      event.InvolvedObject.Kind,
+			Namespace: event.InvolvedObject.Namespace,
+			Name:      event.InvolvedObject.Name,
+			UID:       string(event.InvolvedObject.UID),
+		},
+		Source: event.Source.Component,
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Every
+// replica publishes, as the enrollment and posture servers record events
+// on every replica.
+func (b *Bus) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable. It delivers the queued events of each
+// sink until ctx is done.
+func (b *Bus) Start(ctx context.Context) error {
+	logger := ctrl.Log.WithName("events")
+	done := make(chan struct{})
+	for _, s := range b.subscriptions {
+		go func(s *subscription) {
+			defer func() { done <- struct{}{} }()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case event := <-s.queue:
+					publishCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+					if err := s.sink.Publish(publishCtx, event); err != nil {
+						logger.Error(err, "unable to publish event", "sink", s.sink.Name(), "reason", event.Reason)
+					}
+					cancel()
+				}
+			}
+		}(s)
+	}
+	for range b.subscriptions {
+		<-done
+	}
+	return nil
+}
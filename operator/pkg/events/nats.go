@@ -0,0 +1,138 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATSSink publishes events to NATS subjects <Subject>.<kind>.<reason>,
+// e.g. wireflow.events.VPNPeer.InviteAccepted, so that subscribers can
+// select events with wildcards. It speaks the core NATS protocol over a
+// plain TCP connection, which is re-established when it breaks.
+type NATSSink struct {
+	// Address is the host:port of the NATS server, optionally prefixed with
+	// nats://
+	Address string
+
+	// Subject is the prefix of the subjects events are published to
+	Subject string
+
+	// Token authenticates the connection if not empty
+	Token string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Name implements Sink.
+func (s *NATSSink) Name() string {
+	return "nats " + s.Address
+}
+
+// Publish implements Sink. The event is acknowledged by a round trip to
+// the server, so that errors such as permission violations surface.
+func (s *NATSSink) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	subject := strings.Join([]string{s.Subject, subjectToken(event.Object.Kind), subjectToken(event.Reason)}, ".")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The server closes idle connections whose pings went unanswered, which
+	// only shows on the next publish
+	for attempt := 0; ; attempt++ {
+		if err = s.publish(ctx, subject, payload); err == nil || attempt > 0 || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// publish publishes a message and waits for the server to answer a ping.
+func (s *NATSSink) publish(ctx context.Context, subject string, payload []byte) error {
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return err
+		}
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(publishTimeout)
+	}
+	_ = s.conn.SetDeadline(deadline)
+
+	_, err := fmt.Fprintf(s.conn, "PUB %s %d\r\n%s\r\nPING\r\n", subject, len(payload), payload)
+	for err == nil {
+		var line string
+		if line, err = s.reader.ReadString('\n'); err != nil {
+			break
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			_, err = fmt.Fprint(s.conn, "PONG\r\n")
+		case strings.HasPrefix(line, "-ERR"):
+			err = fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+	s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// connect connects and authenticates to the server.
+func (s *NATSSink) connect(ctx context.Context) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", strings.TrimPrefix(s.Address, "nats://"))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	reader := bufio.NewReader(conn)
+	// The server introduces itself first
+	info, err := reader.ReadString('\n')
+	if err == nil && !strings.HasPrefix(info, "INFO ") {
+		err = fmt.Errorf("nats: unexpected greeting %q", strings.TrimSpace(info))
+	}
+	if err == nil {
+		options, _ := json.Marshal(map[string]interface{}{
+			"verbose":    false,
+			"pedantic":   false,
+			"name":       "wireflow",
+			"lang":       "go",
+			"auth_token": s.Token,
+		})
+		_, err = fmt.Fprintf(conn, "CONNECT %s\r\n", options)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	s.conn, s.reader = conn, reader
+	return nil
+}
+
+// subjectToken makes a value usable as a token of a subject, which must not
+// contain dots, wildcards or whitespace.
+func subjectToken(value string) string {
+	if value == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, value)
+}
@@ -0,0 +1,61 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// WebhookSink POSTs events as JSON to a URL
+type WebhookSink struct {
+	// URL is the URL events are POSTed to
+	URL string
+
+	// Token is sent as a bearer token if not empty
+	Token string
+
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// Name implements Sink.
+func (s *WebhookSink) Name() string {
+	return "webhook " + s.URL
+}
+
+// Publish implements Sink.
+func (s *WebhookSink) Publish(ctx context.Context, event Event) error {
+	return PostJSON(ctx, s.HTTPClient, s.URL, s.Token, event)
+}
+
+// PostJSON POSTs payload as JSON to url, with token as bearer token if not
+// empty, and fails unless the response is a success.
+func PostJSON(ctx context.Context, httpClient *http.Client, url, token string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
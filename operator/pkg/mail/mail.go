@@ -0,0 +1,138 @@
+// Package mail sends the emails of the operator, e.g. invites, through an
+// SMTP relay.
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email
+type Message struct {
+	// To is the address of the recipient
+	To string
+
+	// Subject is the subject line
+	Subject string
+
+	// Body is the plain text body
+	Body string
+}
+
+// Sender sends emails
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Options configure an SMTP sender
+type Options struct {
+	// Address is the host:port of the SMTP relay. STARTTLS is used when
+	// the relay offers it.
+	Address string
+
+	// From is the sender address, e.g. "Wireflow <vpn@example.com>"
+	From string
+
+	// Username and Password authenticate with PLAIN auth, if set
+	Username string
+	Password string
+}
+
+// smtpSender sends emails through an SMTP relay
+type smtpSender struct {
+	Options
+}
+
+// NewSMTP returns a sender relaying through an SMTP server.
+func NewSMTP(opts Options) (Sender, error) {
+	if opts.Address == "" || opts.From == "" {
+		return nil, errors.New("an SMTP address and a from address are required")
+	}
+	if _, _, err := net.SplitHostPort(opts.Address); err != nil {
+		return nil, fmt.Errorf("SMTP address: %w", err)
+	}
+	if _, err := netmail.ParseAddress(opts.From); err != nil {
+		return nil, fmt.Errorf("from address: %w", err)
+	}
+	return &smtpSender{Options: opts}, nil
+}
+
+// Send implements Sender.
+func (s *smtpSender) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return errors.New("header values must not contain line breaks")
+	}
+	from, err := netmail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("from address: %w", err)
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", s.Address)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(time.Minute))
+	}
+	host, _, _ := net.SplitHostPort(s.Address)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(format(s.From, msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// format returns the message with its headers. The writer of the DATA
+// command ends lines in CRLF and escapes leading dots.
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\n", from)
+	fmt.Fprintf(&b, "To: %s\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\n\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\r\n", "\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,107 @@
+// Package nodemetrics reads node network utilization from the Prometheus
+// HTTP API, as scraped from node-exporter.
+package nodemetrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultUtilizationQuery is the fraction of the link speed used by the
+// busiest physical NIC of each node, in either direction.
+const DefaultUtilizationQuery = `max by (instance, node) (
+  rate(node_network_transmit_bytes_total{device=~"eth.*|en.*|bond.*"}[2m])
+    / (node_network_speed_bytes{device=~"eth.*|en.*|bond.*"} > 0)
+  or
+  label_replace(
+    rate(node_network_receive_bytes_total{device=~"eth.*|en.*|bond.*"}[2m])
+      / (node_network_speed_bytes{device=~"eth.*|en.*|bond.*"} > 0),
+    "direction", "receive", "", ""
+  )
+)`
+
+// Client queries a Prometheus compatible API.
+type Client struct {
+	// URL is the base URL of the API, e.g. http://prometheus:9090
+	URL string
+
+	// HTTPClient is the client used for queries, http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// queryResponse is the response of an instant query
+type queryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Value  [2]interface{}    `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// Utilization runs query and returns its value per node. Samples are
+// attributed to a node by their "node" label, or by the host of their
+// "instance" label, which resolve maps to a node name.
+func (c *Client) Utilization(ctx context.Context, query string, resolve func(host string) string) (map[string]float64, error) {
+	endpoint := strings.TrimSuffix(c.URL, "/") + "/api/v1/query?" + url.Values{"query": {query}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body queryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding query response: %w", err)
+	}
+	if body.Status != "success" {
+		return nil, fmt.Errorf("query failed: %s", body.Error)
+	}
+	if body.Data.ResultType != "vector" {
+		return nil, fmt.Errorf("query returned a %s, expected a vector", body.Data.ResultType)
+	}
+
+	utilization := map[string]float64{}
+	for _, sample := range body.Data.Result {
+		node := sample.Metric["node"]
+		if node == "" {
+			host := sample.Metric["instance"]
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			node = resolve(host)
+		}
+		if node == "" {
+			continue
+		}
+		raw, ok := sample.Value[1].(string)
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			continue
+		}
+		if value > utilization[node] {
+			utilization[node] = value
+		}
+	}
+	return utilization, nil
+}
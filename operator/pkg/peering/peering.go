@@ -0,0 +1,134 @@
+// Package peering signs and verifies peering bundles, which carry the
+// identity of a VPN server from one cluster to another: its public key,
+// endpoint and networks. A cluster exports a bundle for each of its
+// servers open to peering, and another cluster imports it to establish a
+// tunnel to the server without access to the exporting cluster.
+//
+// Bundles are signed with an Ed25519 key of the exporting operator, since
+// the Curve25519 keys of WireGuard cannot sign. The importing cluster
+// trusts bundles signed with the keys it was given out of band, so a
+// bundle cannot be forged or altered on its way, e.g. to redirect the
+// networks of a site.
+package peering
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// prefix starts the encoded bundles, versioning their format
+const prefix = "wfpb1"
+
+var (
+	// ErrUntrusted is returned for bundles not signed with a trusted key
+	ErrUntrusted = errors.New("the peering bundle is not signed with a trusted key")
+
+	// ErrExpired is returned for bundles past their expiry
+	ErrExpired = errors.New("the peering bundle has expired")
+)
+
+// Bundle is the identity of an exported server
+type Bundle struct {
+	// Cluster is the name of the exporting cluster
+	Cluster string `json:"cluster,omitempty"`
+
+	// Namespace and Server name the VPNServer on the exporting cluster
+	Namespace string `json:"namespace"`
+	Server    string `json:"server"`
+
+	// PublicKey is the WireGuard public key of the server
+	PublicKey string `json:"publicKey"`
+
+	// Endpoint is the host:port the server is reached on
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Address is the tunnel address of the server, with its prefix length
+	Address string `json:"address"`
+
+	// Networks are the tunnel network of the server and the sites behind
+	// it, routed to it by the importing cluster
+	Networks []string `json:"networks"`
+
+	// IssuedAt and ExpiresAt bound the validity of the bundle
+	IssuedAt  time.Time `json:"issuedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// GenerateSigningKey returns a new signing key.
+func GenerateSigningKey() (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	return key, err
+}
+
+// EncodePublicKey encodes the public key of a signing key, as listed in the
+// trusted keys of the importing cluster.
+func EncodePublicKey(key ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// ParsePublicKey parses a public key encoded with EncodePublicKey.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid signing key: expected %d bytes, got %d", ed25519.PublicKeySize, len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// Sign encodes and signs a bundle as a single line of text:
+// wfpb1.<payload>.<signature>, both base64url encoded.
+func Sign(bundle *Bundle, key ed25519.PrivateKey) (string, error) {
+	payload, err := json.Marshal(bundle)
+	if err != nil {
+		return "", err
+	}
+	encoded := prefix + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature := ed25519.Sign(key, []byte(encoded))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Verify decodes a bundle encoded with Sign, checking it was signed with
+// one of the trusted keys and has not expired at now. It returns the key
+// it was signed with.
+func Verify(encoded string, trusted []ed25519.PublicKey, now time.Time) (*Bundle, ed25519.PublicKey, error) {
+	parts := strings.Split(strings.TrimSpace(encoded), ".")
+	if len(parts) != 3 || parts[0] != prefix {
+		return nil, nil, fmt.Errorf("not a peering bundle, expected %s.<payload>.<signature>", prefix)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	var signer ed25519.PublicKey
+	for _, key := range trusted {
+		if ed25519.Verify(key, signed, signature) {
+			signer = key
+			break
+		}
+	}
+	if signer == nil {
+		return nil, nil, ErrUntrusted
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	bundle := &Bundle{}
+	if err := json.Unmarshal(payload, bundle); err != nil {
+		return nil, nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	if !now.Before(bundle.ExpiresAt) {
+		return bundle, signer, ErrExpired
+	}
+	return bundle, signer, nil
+}
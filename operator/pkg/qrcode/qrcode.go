@@ -0,0 +1,55 @@
+// Package qrcode renders client configs as QR codes, which the WireGuard
+// mobile apps import by scanning, as a PNG image or as text for terminals.
+package qrcode
+
+import (
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// quietZone is the width in modules of the light margin scanners need
+// around a code
+const quietZone = 4
+
+// PNG returns a client config as a QR code PNG image.
+func PNG(config string) ([]byte, error) {
+	code, err := qr.Encode(config, qr.M)
+	if err != nil {
+		return nil, err
+	}
+	return code.PNG(), nil
+}
+
+// Text returns a client config as a QR code drawn with block characters,
+// two modules per line, as qrencode -t ansiutf8. The code is drawn in black
+// on white whatever the colors of the terminal.
+func Text(config string) (string, error) {
+	code, err := qr.Encode(config, qr.M)
+	if err != nil {
+		return "", err
+	}
+	dark := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < code.Size && y < code.Size && code.Black(x, y)
+	}
+
+	var b strings.Builder
+	for y := -quietZone; y < code.Size+quietZone; y += 2 {
+		b.WriteString("\x1b[40;37m")
+		for x := -quietZone; x < code.Size+quietZone; x++ {
+			// Light modules are drawn in the foreground color
+			switch top, bottom := !dark(x, y), !dark(x, y+1); {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\x1b[0m\n")
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,245 @@
+// Package vault is a minimal client of the HashiCorp Vault HTTP API: it
+// logs in with the Kubernetes auth method, renews its token, and reads and
+// writes KV version 2 secrets and transit ciphertexts.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTokenFile is the service account token the client logs in with
+const DefaultTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// ErrNotFound is returned for secrets that do not exist
+var ErrNotFound = errors.New("not found in vault")
+
+// Client calls the API of a Vault server. It logs in on the first request
+// and renews its token before it expires, logging in again when it can no
+// longer be renewed.
+type Client struct {
+	// Address is the base URL of Vault, e.g. https://vault:8200
+	Address string
+
+	// AuthMount is the mount of the Kubernetes auth method, kubernetes if
+	// empty
+	AuthMount string
+
+	// Role is the role of the Kubernetes auth method to log in with
+	Role string
+
+	// TokenFile is the service account token to log in with,
+	// DefaultTokenFile if empty
+	TokenFile string
+
+	// HTTPClient is the client used for requests, http.DefaultClient if nil
+	HTTPClient *http.Client
+
+	mu        sync.Mutex
+	token     string
+	renewable bool
+	renewAt   time.Time
+	expires   time.Time
+}
+
+// KV is a KV version 2 secret
+type KV struct {
+	// Data are the fields of the secret
+	Data map[string]string
+
+	// CreatedTime is when the read version was written
+	CreatedTime time.Time
+}
+
+// ReadKV reads the current version of the secret at path of the KV
+// version 2 engine mounted at mount. It returns ErrNotFound if the secret
+// does not exist or its current version is deleted.
+func (c *Client) ReadKV(ctx context.Context, mount, path string) (*KV, error) {
+	var response struct {
+		Data *struct {
+			Data     map[string]string `json:"data"`
+			Metadata struct {
+				CreatedTime time.Time `json:"created_time"`
+			} `json:"metadata"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodGet, mount+"/data/"+path, nil, &response); err != nil {
+		return nil, err
+	}
+	if response.Data == nil || response.Data.Data == nil {
+		return nil, ErrNotFound
+	}
+	return &KV{Data: response.Data.Data, CreatedTime: response.Data.Metadata.CreatedTime}, nil
+}
+
+// WriteKV writes a new version of the secret at path of the KV version 2
+// engine mounted at mount.
+func (c *Client) WriteKV(ctx context.Context, mount, path string, data map[string]string) error {
+	return c.do(ctx, http.MethodPost, mount+"/data/"+path, map[string]interface{}{"data": data}, nil)
+}
+
+// Encrypt encrypts plaintext with the key of the transit engine mounted at
+// mount, returning a vault:v<version>: ciphertext.
+func (c *Client) Encrypt(ctx context.Context, mount, key string, plaintext []byte) (string, error) {
+	var response struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	body := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}
+	if err := c.do(ctx, http.MethodPost, mount+"/encrypt/"+key, body, &response); err != nil {
+		return "", err
+	}
+	return response.Data.Ciphertext, nil
+}
+
+// Decrypt decrypts a ciphertext of Encrypt.
+func (c *Client) Decrypt(ctx context.Context, mount, key, ciphertext string) ([]byte, error) {
+	var response struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodPost, mount+"/decrypt/"+key, map[string]string{"ciphertext": ciphertext}, &response); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(response.Data.Plaintext)
+}
+
+// IsCiphertext returns whether value is a transit ciphertext.
+func IsCiphertext(value string) bool {
+	return strings.HasPrefix(value, "vault:v")
+}
+
+// do calls the API with the token of the client, and decodes the response
+// into out if not nil.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	token, err := c.authToken(ctx)
+	if err != nil {
+		return err
+	}
+	status, err := c.call(ctx, method, path, token, body, out)
+	if status == http.StatusForbidden {
+		// The token may have been revoked, log in again next time
+		c.mu.Lock()
+		c.token = ""
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// authToken returns a valid token, logging in or renewing the current one
+// as needed.
+func (c *Client) authToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.token != "" && now.Before(c.renewAt) {
+		return c.token, nil
+	}
+	if c.token != "" && c.renewable && now.Before(c.expires) {
+		var response authResponse
+		if _, err := c.call(ctx, http.MethodPost, "auth/token/renew-self", c.token, nil, &response); err == nil {
+			c.setToken(response, now)
+			return c.token, nil
+		}
+	}
+
+	tokenFile := c.TokenFile
+	if tokenFile == "" {
+		tokenFile = DefaultTokenFile
+	}
+	jwt, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the service account token: %w", err)
+	}
+	mount := c.AuthMount
+	if mount == "" {
+		mount = "kubernetes"
+	}
+	var response authResponse
+	body := map[string]string{"role": c.Role, "jwt": strings.TrimSpace(string(jwt))}
+	if _, err := c.call(ctx, http.MethodPost, "auth/"+mount+"/login", "", body, &response); err != nil {
+		return "", fmt.Errorf("unable to log in to vault with role %q: %w", c.Role, err)
+	}
+	c.setToken(response, now)
+	return c.token, nil
+}
+
+type authResponse struct {
+	Auth struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int64  `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+// setToken keeps the token of a login or renewal. It is renewed once two
+// thirds of its lease elapsed.
+func (c *Client) setToken(response authResponse, now time.Time) {
+	lease := time.Duration(response.Auth.LeaseDuration) * time.Second
+	c.token = response.Auth.ClientToken
+	c.renewable = response.Auth.Renewable
+	c.expires = now.Add(lease)
+	c.renewAt = now.Add(lease * 2 / 3)
+	if lease == 0 {
+		// Tokens without a lease do not expire
+		c.expires = now.Add(100 * 365 * 24 * time.Hour)
+		c.renewAt = c.expires
+	}
+}
+
+// call sends a request to the API and returns the status of the response.
+func (c *Client) call(ctx context.Context, method, path, token string, body, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.Address, "/")+"/v1/"+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return resp.StatusCode, ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		var response struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&response)
+		return resp.StatusCode, fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(response.Errors, "; "))
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,371 @@
+//go:build linux
+
+// Package kernel is a harness for end-to-end tests of the datapath against
+// the running kernel. Each node is a network namespace with a real
+// WireGuard interface, attached to a shared underlay bridge, and the agent
+// scripts of the server image run inside it.
+//
+// Tests using the harness are skipped unless WIREFLOW_E2E_KERNEL=1 is set.
+// They need root, iproute2, wireguard-tools and a kernel with WireGuard,
+// and are run with "make e2e-kernel":
+//
+//	func TestKernelForwarding(t *testing.T) {
+//		h := kernel.New(t)
+//		server := h.AddNode("server", "10.0.0.1/24")
+//		client := h.AddNode("client", "10.0.0.2/24")
+//		server.AddPeer(client)
+//		client.AddPeer(server)
+//		h.Eventually(10*time.Second, func() error { return client.Ping("10.0.0.1") })
+//	}
+package kernel
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	// EnvEnable enables the kernel datapath tests when set to "1"
+	EnvEnable = "WIREFLOW_E2E_KERNEL"
+
+	// EnvScriptsDir overrides the directory of the agent scripts
+	EnvScriptsDir = "WIREFLOW_SCRIPTS_DIR"
+
+	// ListenPort is the WireGuard port of every node
+	ListenPort = 51820
+
+	// Interface is the name of the WireGuard interface of every node
+	Interface = "wg0"
+
+	underlayPrefix = "192.168.250.0/24"
+)
+
+// Harness manages the network namespaces of a test. Everything it creates
+// is removed when the test ends.
+type Harness struct {
+	t          testing.TB
+	id         string
+	underlay   string
+	scriptsDir string
+
+	mu        sync.Mutex
+	nodes     []*Node
+	processes []*exec.Cmd
+}
+
+// Node is a network namespace with a WireGuard interface
+type Node struct {
+	h *Harness
+
+	// Name is the name the node was added with
+	Name string
+
+	// Namespace is the name of the network namespace
+	Namespace string
+
+	// PrivateKey and PublicKey are the WireGuard keys of the interface
+	PrivateKey string
+	PublicKey  string
+
+	// Address is the tunnel address of the interface
+	Address netip.Prefix
+
+	// UnderlayIP is the address peers reach the node on
+	UnderlayIP netip.Addr
+
+	// StateDir is the state directory of the agent scripts run on the node
+	StateDir string
+}
+
+// New returns a harness for the test, skipping it when kernel tests are
+// not enabled or the environment cannot run them.
+func New(t testing.TB) *Harness {
+	t.Helper()
+	if os.Getenv(EnvEnable) != "1" {
+		t.Skipf("kernel datapath tests are disabled, set %s=1 or run make e2e-kernel", EnvEnable)
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("kernel datapath tests must run as root")
+	}
+	for _, tool := range []string{"ip", "wg"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s is not installed", tool)
+		}
+	}
+
+	id := make([]byte, 3)
+	if _, err := rand.Read(id); err != nil {
+		t.Fatal(err)
+	}
+	h := &Harness{
+		t:          t,
+		id:         hex.EncodeToString(id),
+		scriptsDir: scriptsDir(),
+	}
+	h.underlay = h.namespace("ul")
+	t.Cleanup(h.cleanup)
+
+	h.mustRun("ip", "netns", "add", h.underlay)
+	h.mustRun("ip", "-n", h.underlay, "link", "add", "br0", "type", "bridge")
+	h.mustRun("ip", "-n", h.underlay, "link", "set", "br0", "up")
+	if _, err := h.run("ip", "-n", h.underlay, "link", "add", "wgprobe", "type", "wireguard"); err != nil {
+		t.Skip("the kernel has no WireGuard support")
+	}
+	h.mustRun("ip", "-n", h.underlay, "link", "del", "wgprobe")
+	return h
+}
+
+// scriptsDir returns the directory of the agent scripts in the repository.
+func scriptsDir() string {
+	if dir := os.Getenv(EnvScriptsDir); dir != "" {
+		return dir
+	}
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "..", "docker", "wireguard", "scripts")
+}
+
+func (h *Harness) namespace(name string) string {
+	return fmt.Sprintf("wf%s-%s", h.id, name)
+}
+
+// AddNode creates a node with the given tunnel address, e.g. 10.0.0.1/24.
+func (h *Harness) AddNode(name, address string) *Node {
+	h.t.Helper()
+	prefix, err := netip.ParsePrefix(address)
+	if err != nil {
+		h.t.Fatalf("invalid address of node %s: %v", name, err)
+	}
+
+	h.mu.Lock()
+	index := len(h.nodes) + 1
+	underlay := netip.MustParsePrefix(underlayPrefix).Addr().As4()
+	underlay[3] = byte(index)
+	node := &Node{
+		h:          h,
+		Name:       name,
+		Namespace:  h.namespace(name),
+		Address:    prefix,
+		UnderlayIP: netip.AddrFrom4(underlay),
+		StateDir:   filepath.Join(h.t.TempDir(), name),
+	}
+	h.nodes = append(h.nodes, node)
+	h.mu.Unlock()
+
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	node.PrivateKey = base64.StdEncoding.EncodeToString(private.Bytes())
+	node.PublicKey = base64.StdEncoding.EncodeToString(private.PublicKey().Bytes())
+	keyFile := filepath.Join(h.t.TempDir(), name+".key")
+	if err := os.WriteFile(keyFile, []byte(node.PrivateKey), 0600); err != nil {
+		h.t.Fatal(err)
+	}
+
+	// Attach the node to the underlay bridge
+	host, peer := "v"+strconv.Itoa(index), "e"+strconv.Itoa(index)
+	h.mustRun("ip", "netns", "add", node.Namespace)
+	h.mustRun("ip", "-n", h.underlay, "link", "add", host, "type", "veth", "peer", "name", peer)
+	h.mustRun("ip", "-n", h.underlay, "link", "set", host, "master", "br0", "up")
+	h.mustRun("ip", "-n", h.underlay, "link", "set", peer, "netns", node.Namespace)
+	h.mustRun("ip", "-n", node.Namespace, "link", "set", peer, "name", "eth0")
+	h.mustRun("ip", "-n", node.Namespace, "addr", "add", node.UnderlayIP.String()+"/24", "dev", "eth0")
+	h.mustRun("ip", "-n", node.Namespace, "link", "set", "eth0", "up")
+	h.mustRun("ip", "-n", node.Namespace, "link", "set", "lo", "up")
+
+	h.mustRun("ip", "-n", node.Namespace, "link", "add", Interface, "type", "wireguard")
+	node.MustExec("wg", "set", Interface, "private-key", keyFile, "listen-port", strconv.Itoa(ListenPort))
+	h.mustRun("ip", "-n", node.Namespace, "addr", "add", address, "dev", Interface)
+	h.mustRun("ip", "-n", node.Namespace, "link", "set", Interface, "up")
+	return node
+}
+
+// AddPeer configures peer on the node. The peer's tunnel address is routed
+// to it unless allowedIPs are given.
+func (n *Node) AddPeer(peer *Node, allowedIPs ...string) {
+	n.h.t.Helper()
+	if len(allowedIPs) == 0 {
+		allowedIPs = []string{netip.PrefixFrom(peer.Address.Addr(), peer.Address.Addr().BitLen()).String()}
+	}
+	n.MustExec("wg", "set", Interface, "peer", peer.PublicKey,
+		"endpoint", fmt.Sprintf("%s:%d", peer.UnderlayIP, ListenPort),
+		"allowed-ips", strings.Join(allowedIPs, ","))
+}
+
+// RemovePeer removes peer from the node.
+func (n *Node) RemovePeer(peer *Node) {
+	n.h.t.Helper()
+	n.MustExec("wg", "set", Interface, "peer", peer.PublicKey, "remove")
+}
+
+// Exec runs a command in the namespace of the node and returns its
+// combined output.
+func (n *Node) Exec(name string, args ...string) (string, error) {
+	return n.h.run("ip", append([]string{"netns", "exec", n.Namespace, name}, args...)...)
+}
+
+// MustExec runs a command in the namespace of the node and fails the test
+// if it fails.
+func (n *Node) MustExec(name string, args ...string) string {
+	n.h.t.Helper()
+	out, err := n.Exec(name, args...)
+	if err != nil {
+		n.h.t.Fatalf("%s on %s: %v: %s", name, n.Name, err, out)
+	}
+	return out
+}
+
+// Ping sends a single ping from the node to addr.
+func (n *Node) Ping(addr string) error {
+	_, err := n.Exec("ping", "-c", "1", "-W", "1", addr)
+	return err
+}
+
+// LatestHandshake returns the time of the last handshake with peer, which
+// is zero if none completed.
+func (n *Node) LatestHandshake(peer *Node) (time.Time, error) {
+	out, err := n.Exec("wg", "show", Interface, "latest-handshakes")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != peer.PublicKey {
+			continue
+		}
+		seconds, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || seconds == 0 {
+			return time.Time{}, err
+		}
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("%s is not a peer of %s", peer.Name, n.Name)
+}
+
+// scriptCommand returns the command running an agent script on the node.
+// The scripts are configured through env on top of the node defaults.
+func (n *Node) scriptCommand(env map[string]string, script string, args ...string) *exec.Cmd {
+	cmd := exec.Command("ip", append([]string{"netns", "exec", n.Namespace, filepath.Join(n.h.scriptsDir, script)}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"WG_INTERFACE="+Interface,
+		"WG_STATE_DIR="+n.StateDir,
+		"WG_SCRIPTS_DIR="+n.h.scriptsDir,
+		"WG_EGRESS_INTERFACE=eth0",
+		"HOSTNAME="+n.Name,
+	)
+	for name, value := range env {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+	return cmd
+}
+
+// RunScript runs an agent script, e.g. "firewall.sh", on the node and
+// returns its combined output.
+func (n *Node) RunScript(env map[string]string, script string, args ...string) (string, error) {
+	out, err := n.scriptCommand(env, script, args...).CombinedOutput()
+	return string(out), err
+}
+
+// StartScript starts a long running agent script, e.g.
+// "session-recorder.sh", on the node. It is stopped when the test ends and
+// its output is logged.
+func (n *Node) StartScript(env map[string]string, script string, args ...string) {
+	n.h.t.Helper()
+	cmd := n.scriptCommand(env, script, args...)
+	output := &logWriter{t: n.h.t, prefix: n.Name + "/" + script + ": "}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Start(); err != nil {
+		n.h.t.Fatalf("starting %s on %s: %v", script, n.Name, err)
+	}
+	n.h.mu.Lock()
+	n.h.processes = append(n.h.processes, cmd)
+	n.h.mu.Unlock()
+}
+
+// Eventually retries cond until it succeeds or the timeout expires, in
+// which case the test fails with the last error.
+func (h *Harness) Eventually(timeout time.Duration, cond func() error) {
+	h.t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		err := cond()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("condition not met after %s: %v", timeout, err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func (h *Harness) run(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	return string(out), err
+}
+
+func (h *Harness) mustRun(name string, args ...string) {
+	h.t.Helper()
+	if out, err := h.run(name, args...); err != nil {
+		h.t.Fatalf("%s %s: %v: %s", name, strings.Join(args, " "), err, out)
+	}
+}
+
+// cleanup stops the scripts and deletes the namespaces, which removes the
+// interfaces, routes and firewall rules created in them.
+func (h *Harness) cleanup() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, cmd := range h.processes {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}
+	for _, node := range h.nodes {
+		if out, err := h.run("ip", "netns", "del", node.Namespace); err != nil {
+			h.t.Logf("deleting namespace %s: %v: %s", node.Namespace, err, out)
+		}
+	}
+	if out, err := h.run("ip", "netns", "del", h.underlay); err != nil {
+		h.t.Logf("deleting namespace %s: %v: %s", h.underlay, err, out)
+	}
+}
+
+// logWriter logs the lines written to it
+type logWriter struct {
+	t      testing.TB
+	prefix string
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			// Keep the incomplete line for the next write
+			w.buf.WriteString(line)
+			break
+		}
+		w.t.Log(w.prefix + strings.TrimSuffix(line, "\n"))
+	}
+	return len(p), nil
+}
@@ -0,0 +1,78 @@
+//go:build linux
+
+package kernel_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vpn-devops/vpn-operator/test/kernel"
+)
+
+func TestKernelHandshake(t *testing.T) {
+	h := kernel.New(t)
+	server := h.AddNode("server", "10.0.0.1/24")
+	client := h.AddNode("client", "10.0.0.2/24")
+	server.AddPeer(client)
+	client.AddPeer(server)
+
+	h.Eventually(10*time.Second, func() error { return client.Ping("10.0.0.1") })
+	handshake, err := server.LatestHandshake(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handshake.IsZero() {
+		t.Error("the server completed no handshake with the client")
+	}
+}
+
+// forwardingNodes returns a server forwarding between two clients that
+// route the tunnel network through it, with the firewall of the agent up.
+func forwardingNodes(t *testing.T) (*kernel.Harness, *kernel.Node, *kernel.Node, *kernel.Node) {
+	t.Helper()
+	h := kernel.New(t)
+	if _, err := exec.LookPath("nft"); err != nil {
+		if _, err := exec.LookPath("iptables-legacy"); err != nil {
+			t.Skip("neither nft nor iptables-legacy is installed")
+		}
+	}
+	server := h.AddNode("server", "10.0.0.1/24")
+	first := h.AddNode("first", "10.0.0.2/24")
+	second := h.AddNode("second", "10.0.0.3/24")
+	server.AddPeer(first)
+	server.AddPeer(second)
+	first.AddPeer(server, "10.0.0.0/24")
+	second.AddPeer(server, "10.0.0.0/24")
+	server.MustExec("sysctl", "-qw", "net.ipv4.ip_forward=1")
+	if out, err := server.RunScript(nil, "firewall.sh", "up", kernel.Interface); err != nil {
+		t.Fatalf("firewall.sh up: %v: %s", err, out)
+	}
+	return h, server, first, second
+}
+
+func TestKernelForwarding(t *testing.T) {
+	h, _, first, second := forwardingNodes(t)
+
+	h.Eventually(10*time.Second, func() error { return first.Ping("10.0.0.3") })
+	h.Eventually(10*time.Second, func() error { return second.Ping("10.0.0.2") })
+}
+
+func TestKernelFirewallFilter(t *testing.T) {
+	h, server, first, second := forwardingNodes(t)
+	h.Eventually(10*time.Second, func() error { return first.Ping("10.0.0.3") })
+
+	rules := filepath.Join(t.TempDir(), "firewall")
+	if err := os.WriteFile(rules, []byte("deny icmp - 10.0.0.3/32 - no-ping\ndefault allow\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := server.RunScript(map[string]string{"WG_FIREWALL_CONFIG": rules}, "firewall.sh", "filter", kernel.Interface); err != nil {
+		t.Fatalf("firewall.sh filter: %v: %s", err, out)
+	}
+	if err := first.Ping("10.0.0.3"); err == nil {
+		t.Error("the ping denied by the firewall was forwarded")
+	}
+	h.Eventually(10*time.Second, func() error { return second.Ping("10.0.0.2") })
+}